@@ -40,6 +40,10 @@ test() {
    go test -race ./... -count=1
 }
 
+bench() {
+    go test -run=NONE -bench=. -benchmem ./...
+}
+
 allLocal() {
     pre
     build
@@ -69,6 +73,9 @@ then
 elif [ $1 = "test" ]
 then
     test
+elif [ $1 = "bench" ]
+then
+    bench
 elif [ $1 = "allLocal" ]
 then
     allLocal
@@ -77,6 +84,6 @@ then
     allARM
 else
     echo "usage:"
-    echo "  build.sh [pre | build | buildARM | dockerBuild | test | allLocal | allARM]"
+    echo "  build.sh [pre | build | buildARM | dockerBuild | test | bench | allLocal | allARM]"
     exit 1
 fi
\ No newline at end of file
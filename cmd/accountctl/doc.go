@@ -0,0 +1,58 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package 'accountctl' (main) is a command line tool for administering the accountd service.
+
+Usage:
+
+		accountctl <command> [flags]
+
+The commands are:
+
+		seed	populate accountd with fake accounts and users
+		grpc	call accountd's gRPC API using server reflection
+		secrets	generate a secrets key and encrypt or decrypt secrets files
+
+The 'seed' command generates a dataset of accounts, each with a primary user and zero or more
+other users with realistic names, email addresses, and roles. This gives dashboards (e.g., Grafana)
+and load tests meaningful data. By default the users are created via accountd's HTTP API:
+
+		accountctl seed --dataset demo --accounts 100 --usersPerAccount 5 --url http://accountd.kube
+
+Users created via the API are pending until they're activated (see package 'users'). Alternatively
+the users can be inserted into the database directly, in which case they're active immediately:
+
+		accountctl seed --dataset demo --dsn "user:password@tcp(mysql:3306)/mockvideo?parseTime=true"
+
+The same '--randSeed' generates the same dataset. Email addresses must be unique so a dataset can only
+be loaded once, use '--firstAccountID' to load another dataset alongside it. The only dataset is 'demo'.
+
+The 'grpc' command discovers accountd's gRPC services using server reflection, so RPCs added to
+the proto can be used without changing accountctl. It lists services and their methods, describes
+services, methods, and messages, and calls unary methods with a JSON request (or '-' to read it
+from stdin). The response is printed as JSON:
+
+		accountctl grpc --addr accountd:5000 list
+		accountctl grpc --addr accountd:5000 describe accountd.UserServer
+		accountctl grpc --addr accountd:5000 call accountd.UserServer/GetUser '{"id": 1}'
+
+Request metadata is added with '--header', e.g., "--header 'authorization: Bearer ...'", which can
+be repeated.
+
+The 'secrets' command encrypts accountd's secrets files (see package 'secrets') with age so they can
+be committed, e.g., for the demo environments. 'genkey' prints a new age identity, keep it out of the
+repository and provide it to accountd using the ACCOUNTD_SECRETS_KEY environment variable or its
+'-secretsKeyFile' flag. 'encrypt' encrypts files in place, to the recipients named by '--recipient',
+which can be repeated, or else to those of the identities. 'decrypt' prints their content. Both read
+the identities from ACCOUNTD_SECRETS_KEY, or the file named by '--keyFile':
+
+		export ACCOUNTD_SECRETS_KEY=$(accountctl secrets genkey)
+		accountctl secrets encrypt testdata/secrets/dbuser testdata/secrets/dbpassword
+		accountctl secrets decrypt testdata/secrets/dbpassword
+
+The files are ordinary age files, so 'age' and 'age-keygen' can be used instead, e.g.,
+'age --encrypt --armor --recipient age1...'.
+*/
+package main
@@ -0,0 +1,403 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// headerFlag collects the 'name: value' metadata sent with a call, see the '--header' flag
+type headerFlag []string
+
+func (h *headerFlag) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlag) Set(val string) error {
+	if !strings.Contains(val, ":") {
+		return fmt.Errorf("expected 'name: value', got %q", val)
+	}
+	*h = append(*h, val)
+	return nil
+}
+
+// pairs returns the headers as metadata.Pairs arguments
+func (h headerFlag) pairs() []string {
+	kv := make([]string, 0, 2*len(h))
+	for _, header := range h {
+		nameVal := strings.SplitN(header, ":", 2)
+		kv = append(kv, strings.TrimSpace(nameVal[0]), strings.TrimSpace(nameVal[1]))
+	}
+	return kv
+}
+
+// callGRPC implements the 'grpc' command
+func callGRPC(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("grpc", flag.ContinueOnError)
+	addr := fs.String("addr", "localhost:5000", "the address of accountd's gRPC API")
+	timeout := fs.Duration("timeout", 30*time.Second, "the maximum time allowed for the command")
+	var headers headerFlag
+	fs.Var(&headers, "header", "metadata sent with a call as 'name: value', may be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("expected a subcommand, one of 'list', 'describe', or 'call'")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	cc, err := grpc.DialContext(ctx, *addr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return fmt.Errorf("unable to connect to %s: %s", *addr, err)
+	}
+	defer cc.Close()
+
+	rc, err := newReflectionClient(ctx, cc)
+	if err != nil {
+		return err
+	}
+	defer rc.close()
+
+	subArgs := fs.Args()[1:]
+	switch fs.Arg(0) {
+	case "list":
+		return listSymbols(rc, subArgs, out)
+	case "describe":
+		if len(subArgs) != 1 {
+			return errors.New("describe expects a service, method, message, or enum name")
+		}
+		d, err := rc.resolve(subArgs[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, describe(d))
+		return nil
+	case "call":
+		if len(subArgs) < 1 || len(subArgs) > 2 {
+			return errors.New("call expects a method name and, optionally, its JSON request")
+		}
+		data := "{}"
+		if len(subArgs) == 2 {
+			data = subArgs[1]
+		}
+		if data == "-" {
+			b, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				return err
+			}
+			data = string(b)
+		}
+		ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs(headers.pairs()...))
+		return invoke(ctx, cc, rc, subArgs[0], data, out)
+	default:
+		return fmt.Errorf("unknown subcommand %q, expected one of 'list', 'describe', or 'call'", fs.Arg(0))
+	}
+}
+
+// listSymbols lists the services, or the methods of the service named in 'args'
+func listSymbols(rc *reflectionClient, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		services, err := rc.listServices()
+		if err != nil {
+			return err
+		}
+		for _, s := range services {
+			fmt.Fprintln(out, s)
+		}
+		return nil
+	}
+
+	sd, err := rc.resolveService(args[0])
+	if err != nil {
+		return err
+	}
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		fmt.Fprintln(out, methods.Get(i).FullName())
+	}
+	return nil
+}
+
+// invoke calls the unary RPC 'method' with the request described by 'data', a JSON encoded
+// message, and writes the JSON encoded response to 'out'
+func invoke(ctx context.Context, cc *grpc.ClientConn, rc *reflectionClient, method, data string, out io.Writer) error {
+	serviceName, methodName, err := splitMethod(method)
+	if err != nil {
+		return err
+	}
+	sd, err := rc.resolveService(serviceName)
+	if err != nil {
+		return err
+	}
+	md := sd.Methods().ByName(protoreflect.Name(methodName))
+	if md == nil {
+		return fmt.Errorf("service %s has no method %s", serviceName, methodName)
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return fmt.Errorf("%s is a streaming method, only unary methods are supported", md.FullName())
+	}
+
+	rqst := dynamicpb.NewMessage(md.Input())
+	if err = protojson.Unmarshal([]byte(data), rqst); err != nil {
+		return fmt.Errorf("invalid %s request: %s", md.Input().FullName(), err)
+	}
+	resp := dynamicpb.NewMessage(md.Output())
+	if err = cc.Invoke(ctx, fmt.Sprintf("/%s/%s", sd.FullName(), md.Name()), rqst, resp); err != nil {
+		return err
+	}
+
+	b, err := protojson.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	// protojson's output is deliberately unstable, it varies its whitespace, so it's indented here
+	var indented bytes.Buffer
+	if err = json.Indent(&indented, b, "", "  "); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, indented.String())
+	return nil
+}
+
+// splitMethod returns the service and method names from a method name like
+// 'accountd.UserServer/GetUser', '/accountd.UserServer/GetUser', or 'accountd.UserServer.GetUser'
+func splitMethod(method string) (service, name string, err error) {
+	method = strings.TrimPrefix(method, "/")
+	sep := strings.LastIndex(method, "/")
+	if sep < 0 {
+		sep = strings.LastIndex(method, ".")
+	}
+	if sep <= 0 || sep == len(method)-1 {
+		return "", "", fmt.Errorf("expected a method name like 'accountd.UserServer/GetUser', got %q", method)
+	}
+	return method[:sep], method[sep+1:], nil
+}
+
+// describe returns a proto-like description of 'd'
+func describe(d protoreflect.Descriptor) string {
+	var b strings.Builder
+	switch d := d.(type) {
+	case protoreflect.ServiceDescriptor:
+		fmt.Fprintf(&b, "service %s {\n", d.FullName())
+		for i := 0; i < d.Methods().Len(); i++ {
+			fmt.Fprintf(&b, "\t%s;\n", describeMethod(d.Methods().Get(i)))
+		}
+		b.WriteString("}")
+	case protoreflect.MethodDescriptor:
+		b.WriteString(describeMethod(d))
+	case protoreflect.MessageDescriptor:
+		fmt.Fprintf(&b, "message %s {\n", d.FullName())
+		for i := 0; i < d.Fields().Len(); i++ {
+			f := d.Fields().Get(i)
+			fmt.Fprintf(&b, "\t%s %s = %d;\n", fieldType(f), f.Name(), f.Number())
+		}
+		b.WriteString("}")
+	case protoreflect.EnumDescriptor:
+		fmt.Fprintf(&b, "enum %s {\n", d.FullName())
+		for i := 0; i < d.Values().Len(); i++ {
+			v := d.Values().Get(i)
+			fmt.Fprintf(&b, "\t%s = %d;\n", v.Name(), v.Number())
+		}
+		b.WriteString("}")
+	default:
+		fmt.Fprintf(&b, "%s", d.FullName())
+	}
+	return b.String()
+}
+
+// describeMethod returns a proto-like declaration of 'md', e.g., 'rpc GetUser(accountd.UserID) returns (accountd.User)'
+func describeMethod(md protoreflect.MethodDescriptor) string {
+	in, out := string(md.Input().FullName()), string(md.Output().FullName())
+	if md.IsStreamingClient() {
+		in = "stream " + in
+	}
+	if md.IsStreamingServer() {
+		out = "stream " + out
+	}
+	return fmt.Sprintf("rpc %s(%s) returns (%s)", md.Name(), in, out)
+}
+
+// fieldType returns the proto type of 'f', e.g., 'int64', 'repeated accountd.User', or 'map<string, int64>'
+func fieldType(f protoreflect.FieldDescriptor) string {
+	if f.IsMap() {
+		return fmt.Sprintf("map<%s, %s>", fieldType(f.MapKey()), fieldType(f.MapValue()))
+	}
+	var t string
+	switch f.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		t = string(f.Message().FullName())
+	case protoreflect.EnumKind:
+		t = string(f.Enum().FullName())
+	default:
+		t = f.Kind().String()
+	}
+	if f.Cardinality() == protoreflect.Repeated {
+		t = "repeated " + t
+	}
+	return t
+}
+
+// reflectionClient resolves the descriptors of a server's services, and the messages they use,
+// using the server reflection service
+type reflectionClient struct {
+	stream rpb.ServerReflection_ServerReflectionInfoClient
+	files  *protoregistry.Files
+}
+
+func newReflectionClient(ctx context.Context, cc *grpc.ClientConn) (*reflectionClient, error) {
+	stream, err := rpb.NewServerReflectionClient(cc).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("server reflection unavailable: %s", err)
+	}
+	return &reflectionClient{stream: stream, files: &protoregistry.Files{}}, nil
+}
+
+func (rc *reflectionClient) close() {
+	rc.stream.CloseSend()
+}
+
+// send sends 'rqst' and returns the server's response, an ErrorResponse is returned as an error
+func (rc *reflectionClient) send(rqst *rpb.ServerReflectionRequest) (*rpb.ServerReflectionResponse, error) {
+	if err := rc.stream.Send(rqst); err != nil {
+		return nil, err
+	}
+	resp, err := rc.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if errResp := resp.GetErrorResponse(); errResp != nil {
+		return nil, errors.New(errResp.GetErrorMessage())
+	}
+	return resp, nil
+}
+
+// listServices returns the sorted names of the server's services
+func (rc *reflectionClient) listServices() ([]string, error) {
+	resp, err := rc.send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{ListServices: "*"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		names = append(names, s.GetName())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// resolve returns the descriptor of 'symbol', e.g., 'accountd.UserServer' or 'accountd.User'
+func (rc *reflectionClient) resolve(symbol string) (protoreflect.Descriptor, error) {
+	name := protoreflect.FullName(strings.TrimPrefix(symbol, "."))
+	if d, err := rc.files.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+
+	resp, err := rc.send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: string(name)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve %s: %s", name, err)
+	}
+	if err = rc.register(resp.GetFileDescriptorResponse().GetFileDescriptorProto()); err != nil {
+		return nil, fmt.Errorf("unable to resolve %s: %s", name, err)
+	}
+	return rc.files.FindDescriptorByName(name)
+}
+
+// resolveService returns the descriptor of the service named 'name'
+func (rc *reflectionClient) resolveService(name string) (protoreflect.ServiceDescriptor, error) {
+	d, err := rc.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s isn't a service", name)
+	}
+	return sd, nil
+}
+
+// register adds the serialized FileDescriptorProtos in 'raw', as returned by the reflection
+// service, to the resolved files. Dependencies that aren't in 'raw' are requested by name.
+func (rc *reflectionClient) register(raw [][]byte) error {
+	fdps := make(map[string]*descriptorpb.FileDescriptorProto)
+	for _, b := range raw {
+		fdp := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(b, fdp); err != nil {
+			return err
+		}
+		fdps[fdp.GetName()] = fdp
+	}
+	for name := range fdps {
+		if err := rc.registerFile(name, fdps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registerFile adds the file named 'name', after its dependencies, to the resolved files
+func (rc *reflectionClient) registerFile(name string, fdps map[string]*descriptorpb.FileDescriptorProto) error {
+	if _, err := rc.files.FindFileByPath(name); err == nil {
+		return nil
+	}
+
+	fdp, ok := fdps[name]
+	if !ok {
+		resp, err := rc.send(&rpb.ServerReflectionRequest{
+			MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: name},
+		})
+		if err != nil {
+			return fmt.Errorf("unable to get %s: %s", name, err)
+		}
+		for _, b := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			f := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(b, f); err != nil {
+				return err
+			}
+			fdps[f.GetName()] = f
+		}
+		if fdp, ok = fdps[name]; !ok {
+			return fmt.Errorf("server didn't return %s", name)
+		}
+	}
+
+	for _, dep := range fdp.GetDependency() {
+		if err := rc.registerFile(dep, fdps); err != nil {
+			return err
+		}
+	}
+	fd, err := protodesc.NewFile(fdp, rc.files)
+	if err != nil {
+		return err
+	}
+	return rc.files.RegisterFile(fd)
+}
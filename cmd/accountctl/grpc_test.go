@@ -0,0 +1,136 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	"github.com/youngkin/mockvideo/pkg/accountd/mockserver"
+)
+
+func TestGRPC(t *testing.T) {
+	ms := mockserver.New()
+	defer ms.Close()
+	if _, err := ms.AddUser(domain.User{AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary, Password: "pw"}); err != nil {
+		t.Fatalf("error %s was not expected adding a user", err)
+	}
+	addr, err := ms.StartGRPC()
+	if err != nil {
+		t.Fatalf("error %s was not expected starting the gRPC API", err)
+	}
+
+	tcs := []struct {
+		testName    string
+		args        []string
+		expectErr   bool
+		expectedOut []string
+	}{
+		{
+			testName:    "testListServices",
+			args:        []string{"list"},
+			expectedOut: []string{"accountd.UserServer\n", "grpc.reflection.v1alpha.ServerReflection\n"},
+		},
+		{
+			testName:    "testListMethods",
+			args:        []string{"list", "accountd.UserServer"},
+			expectedOut: []string{"accountd.UserServer.GetUser\n", "accountd.UserServer.Health\n"},
+		},
+		{
+			testName:    "testDescribeService",
+			args:        []string{"describe", "accountd.UserServer"},
+			expectedOut: []string{"service accountd.UserServer {", "\trpc GetUsers(google.protobuf.Empty) returns (accountd.Users);"},
+		},
+		{
+			testName:    "testDescribeMessage",
+			args:        []string{"describe", "accountd.Users"},
+			expectedOut: []string{"message accountd.Users {", "\trepeated accountd.User users = 1;"},
+		},
+		{
+			testName:  "testDescribeUnknown",
+			args:      []string{"describe", "accountd.Bogus"},
+			expectErr: true,
+		},
+		{
+			testName:    "testCall",
+			args:        []string{"call", "accountd.UserServer/GetUser", `{"id": 1}`},
+			expectedOut: []string{`"Name": "mickey dolenz"`, `"EMail": "mickeyd@gmail.com"`},
+		},
+		{
+			testName:    "testCallNoRequest",
+			args:        []string{"call", "/accountd.UserServer/GetUsers"},
+			expectedOut: []string{`"Name": "mickey dolenz"`},
+		},
+		{
+			testName:  "testCallNotFound",
+			args:      []string{"call", "accountd.UserServer.GetUser", `{"id": 9}`},
+			expectErr: true,
+		},
+		{
+			testName:  "testCallInvalidRequest",
+			args:      []string{"call", "accountd.UserServer/GetUser", `{"bogus": 1}`},
+			expectErr: true,
+		},
+		{
+			testName:  "testCallUnknownMethod",
+			args:      []string{"call", "accountd.UserServer/Bogus"},
+			expectErr: true,
+		},
+		{
+			testName:  "testUnknownSubcommand",
+			args:      []string{"bogus"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			err := callGRPC(append([]string{"--addr", addr}, tc.args...), out)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("expected an error, got output %s", out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error %s was not expected", err)
+			}
+			for _, expected := range tc.expectedOut {
+				if !strings.Contains(out.String(), expected) {
+					t.Errorf("expected output to contain %q, got %s", expected, out)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitMethod(t *testing.T) {
+	tcs := []struct {
+		method          string
+		expectedService string
+		expectedName    string
+		expectErr       bool
+	}{
+		{method: "accountd.UserServer/GetUser", expectedService: "accountd.UserServer", expectedName: "GetUser"},
+		{method: "/accountd.UserServer/GetUser", expectedService: "accountd.UserServer", expectedName: "GetUser"},
+		{method: "accountd.UserServer.GetUser", expectedService: "accountd.UserServer", expectedName: "GetUser"},
+		{method: "GetUser", expectErr: true},
+		{method: "accountd.UserServer/", expectErr: true},
+	}
+
+	for _, tc := range tcs {
+		service, name, err := splitMethod(tc.method)
+		if (err != nil) != tc.expectErr {
+			t.Errorf("expected error %t for %s, got %v", tc.expectErr, tc.method, err)
+			continue
+		}
+		if service != tc.expectedService || name != tc.expectedName {
+			t.Errorf("expected %s and %s for %s, got %s and %s", tc.expectedService, tc.expectedName, tc.method, service, name)
+		}
+	}
+}
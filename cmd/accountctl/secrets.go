@@ -0,0 +1,130 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/youngkin/mockvideo/internal/secrets"
+)
+
+// recipientFlag collects the age recipients secrets are encrypted to, see the '--recipient' flag
+type recipientFlag []string
+
+func (r *recipientFlag) String() string {
+	return strings.Join(*r, ", ")
+}
+
+func (r *recipientFlag) Set(val string) error {
+	*r = append(*r, val)
+	return nil
+}
+
+// manageSecrets implements the 'secrets' command
+func manageSecrets(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("secrets", flag.ContinueOnError)
+	keyFile := fs.String("keyFile", "", "the file containing the age identities, i.e., the secrets key, otherwise they're read from the "+secrets.KeyEnv+" environment variable")
+	var recipients recipientFlag
+	fs.Var(&recipients, "recipient", "an age recipient, e.g., 'age1...', 'encrypt' encrypts to, can be repeated. By default the recipients are those of the secrets key.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("expected a subcommand, one of 'genkey', 'encrypt', or 'decrypt'")
+	}
+
+	if fs.Arg(0) == "genkey" {
+		identity, err := secrets.GenerateIdentity()
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(out, identity)
+		return nil
+	}
+
+	identities, err := secrets.LoadIdentities(os.Getenv(secrets.KeyEnv), *keyFile)
+	if err != nil {
+		return err
+	}
+	fileNames := fs.Args()[1:]
+	if len(fileNames) == 0 {
+		return fmt.Errorf("%s expects one or more secrets files", fs.Arg(0))
+	}
+
+	switch fs.Arg(0) {
+	case "encrypt":
+		var to []age.Recipient
+		if len(recipients) > 0 {
+			to, err = secrets.ParseRecipients(recipients)
+		} else {
+			to, err = secrets.Recipients(identities)
+		}
+		if err != nil {
+			return err
+		}
+		if len(to) == 0 {
+			return fmt.Errorf("a recipient is required, set --recipient, %s, or --keyFile", secrets.KeyEnv)
+		}
+		for _, fileName := range fileNames {
+			if err := encryptFile(fileName, to, out); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "decrypt":
+		if len(identities) == 0 {
+			return fmt.Errorf("a secrets key is required, set %s or --keyFile", secrets.KeyEnv)
+		}
+		for _, fileName := range fileNames {
+			content, err := ioutil.ReadFile(fileName)
+			if err != nil {
+				return err
+			}
+			plaintext, err := secrets.Decrypt(filepath.Base(fileName), content, identities...)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "%s\n", plaintext)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown subcommand %q, expected one of 'genkey', 'encrypt', or 'decrypt'", fs.Arg(0))
+	}
+}
+
+// encryptFile encrypts the secret in 'fileName', in place, to 'recipients'. Files that are already
+// encrypted are left unchanged.
+func encryptFile(fileName string, recipients []age.Recipient, out io.Writer) error {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return err
+	}
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	if secrets.IsEncrypted(content) {
+		fmt.Fprintf(out, "%s: already encrypted\n", fileName)
+		return nil
+	}
+
+	encrypted, err := secrets.Encrypt(content, recipients...)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(fileName, encrypted, info.Mode().Perm()); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s: encrypted\n", fileName)
+	return nil
+}
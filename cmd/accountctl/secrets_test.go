@@ -0,0 +1,89 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/youngkin/mockvideo/internal/secrets"
+)
+
+func TestManageSecrets(t *testing.T) {
+	os.Unsetenv(secrets.KeyEnv)
+	dir, err := ioutil.TempDir("", "accountctl")
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a temp directory", err)
+	}
+	defer os.RemoveAll(dir)
+	dbpassword := filepath.Join(dir, "dbpassword")
+	if err = ioutil.WriteFile(dbpassword, []byte("somepassword"), 0600); err != nil {
+		t.Fatalf("error %s was not expected writing the secret", err)
+	}
+
+	var out bytes.Buffer
+	if err = manageSecrets([]string{"genkey"}, &out); err != nil {
+		t.Fatalf("error %s was not expected generating a key", err)
+	}
+	identity := out.String()
+	keyFile := filepath.Join(dir, "key")
+	if err = ioutil.WriteFile(keyFile, []byte(identity), 0600); err != nil {
+		t.Fatalf("error %s was not expected writing the key", err)
+	}
+	recipient := strings.TrimSpace(strings.TrimPrefix(strings.SplitN(identity, "\n", 2)[0], "# public key:"))
+
+	// dbuser is encrypted to the key's recipient by someone who doesn't have the key
+	dbuser := filepath.Join(dir, "dbuser")
+	if err = ioutil.WriteFile(dbuser, []byte("someuser"), 0600); err != nil {
+		t.Fatalf("error %s was not expected writing the secret", err)
+	}
+
+	tcs := []struct {
+		testName    string
+		args        []string
+		expectedOut string
+		expectFail  bool
+	}{
+		{testName: "testNoSubcommand", args: []string{"--keyFile", keyFile}, expectFail: true},
+		{testName: "testNoFiles", args: []string{"--keyFile", keyFile, "encrypt"}, expectFail: true},
+		{testName: "testNoKey", args: []string{"encrypt", dbpassword}, expectFail: true},
+		{testName: "testInvalidRecipient", args: []string{"--recipient", "age1notarecipient", "encrypt", dbpassword}, expectFail: true},
+		{testName: "testEncrypt", args: []string{"--keyFile", keyFile, "encrypt", dbpassword}, expectedOut: dbpassword + ": encrypted\n"},
+		{testName: "testAlreadyEncrypted", args: []string{"--keyFile", keyFile, "encrypt", dbpassword}, expectedOut: dbpassword + ": already encrypted\n"},
+		{testName: "testEncryptToRecipient", args: []string{"--recipient", recipient, "encrypt", dbuser}, expectedOut: dbuser + ": encrypted\n"},
+		{testName: "testDecryptNoKey", args: []string{"decrypt", dbpassword}, expectFail: true},
+		{testName: "testDecrypt", args: []string{"--keyFile", keyFile, "decrypt", dbpassword, dbuser}, expectedOut: "somepassword\nsomeuser\n"},
+		{testName: "testUnknownSubcommand", args: []string{"--keyFile", keyFile, "rotate", dbpassword}, expectFail: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			out.Reset()
+			err := manageSecrets(tc.args, &out)
+			if (err != nil) != tc.expectFail {
+				t.Fatalf("expected failure %t, got %v", tc.expectFail, err)
+			}
+			if !tc.expectFail && out.String() != tc.expectedOut {
+				t.Errorf("expected output %q, got %q", tc.expectedOut, out.String())
+			}
+		})
+	}
+
+	identities, err := secrets.ParseIdentities(identity)
+	if err != nil {
+		t.Fatalf("error %s was not expected parsing the key", err)
+	}
+	content, err := ioutil.ReadFile(dbpassword)
+	if err != nil {
+		t.Fatalf("error %s was not expected reading the secret", err)
+	}
+	if plaintext, err := secrets.Decrypt("dbpassword", content, identities...); err != nil || string(plaintext) != "somepassword" {
+		t.Errorf("expected the file to decrypt to 'somepassword', got %q, %v", plaintext, err)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	userdb "github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/domain"
+	"github.com/youngkin/mockvideo/pkg/client"
+)
+
+// dataset generates the users for 'accounts' accounts, numbered from 'firstAccountID', each with
+// 'usersPerAccount' users. The first user in each account must be its primary user.
+type dataset func(accounts, usersPerAccount, firstAccountID int, rnd *rand.Rand) []domain.User
+
+// datasets maps dataset names to their generators
+var datasets = map[string]dataset{
+	"demo": demoDataset,
+}
+
+// creator creates a single user, see apiCreator and repoCreator
+type creator func(ctx context.Context, u domain.User) error
+
+var (
+	firstNames = []string{"Alice", "Brian", "Carmen", "Davy", "Elena", "Farid", "Grace", "Hiro",
+		"Imani", "Jonas", "Keiko", "Liam", "Mickey", "Nadia", "Oscar", "Priya", "Quinn", "Rosa",
+		"Samir", "Tara", "Umar", "Vera", "Wes", "Ximena", "Yusuf", "Zoe"}
+	lastNames = []string{"Anderson", "Banerjee", "Castillo", "Dolenz", "Eriksen", "Fujita", "Garcia",
+		"Haddad", "Ivanova", "Jones", "Kowalski", "Lindqvist", "Moreau", "Nesmith", "Okafor",
+		"Petrov", "Quintero", "Rossi", "Schmidt", "Tork", "Umeh", "Vargas", "Wilson", "Yamamoto"}
+	emailDomains = []string{"example.com", "example.net", "example.org"}
+)
+
+// seed implements the 'seed' command
+func seed(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	datasetName := fs.String("dataset", "demo", "the dataset to generate, the only dataset is 'demo'")
+	accounts := fs.Int("accounts", 10, "the number of accounts to create")
+	usersPerAccount := fs.Int("usersPerAccount", 4, "the number of users in each account, including the account's primary user")
+	firstAccountID := fs.Int("firstAccountID", 1, "the ID of the first account created, accounts are numbered consecutively")
+	randSeed := fs.Int64("randSeed", 1, "seeds the fake data generator, the same seed generates the same dataset")
+	url := fs.String("url", "http://accountd.kube", "the base URL of accountd's HTTP API")
+	dsn := fs.String("dsn", "", "a MySQL data source name, if set users are inserted into the database instead of created via the API")
+	timeout := fs.Duration("timeout", 5*time.Minute, "the maximum time allowed to seed the dataset")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	generate, ok := datasets[*datasetName]
+	if !ok {
+		return fmt.Errorf("unknown dataset %q", *datasetName)
+	}
+	if *accounts < 1 {
+		return errors.New("accounts must be greater than 0")
+	}
+	if *usersPerAccount < 1 {
+		return errors.New("usersPerAccount must be greater than 0")
+	}
+	if *firstAccountID < 1 {
+		return errors.New("firstAccountID must be greater than 0")
+	}
+
+	var create creator
+	if *dsn != "" {
+		db, err := sql.Open("mysql", *dsn)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		if err = db.Ping(); err != nil {
+			return err
+		}
+		tbl, err := userdb.NewTable(db)
+		if err != nil {
+			return err
+		}
+		create = repoCreator(tbl)
+	} else {
+		c, err := client.NewClient(*url, 3, 30*time.Second)
+		if err != nil {
+			return err
+		}
+		create = apiCreator(c)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	users := generate(*accounts, *usersPerAccount, *firstAccountID, rand.New(rand.NewSource(*randSeed)))
+	if err := load(ctx, users, create); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "created %d users in %d accounts, account IDs %d through %d\n",
+		len(users), *accounts, *firstAccountID, *firstAccountID+*accounts-1)
+	return nil
+}
+
+// load creates 'users' in order, stopping at the first failure
+func load(ctx context.Context, users []domain.User, create creator) error {
+	for i, u := range users {
+		if err := create(ctx, u); err != nil {
+			return fmt.Errorf("created %d of %d users, unable to create user %s: %w", i, len(users), u.EMail, err)
+		}
+	}
+	return nil
+}
+
+// demoDataset generates users with realistic names and email addresses. Each account has a
+// primary user, the remaining users are a mix of unrestricted and restricted users. Email
+// addresses include the account ID so they're unique across datasets with distinct account IDs.
+func demoDataset(accounts, usersPerAccount, firstAccountID int, rnd *rand.Rand) []domain.User {
+	users := make([]domain.User, 0, accounts*usersPerAccount)
+	for accountID := firstAccountID; accountID < firstAccountID+accounts; accountID++ {
+		for i := 0; i < usersPerAccount; i++ {
+			first := firstNames[rnd.Intn(len(firstNames))]
+			last := lastNames[rnd.Intn(len(lastNames))]
+
+			role := domain.Primary
+			if i > 0 {
+				role = domain.Unrestricted
+				if rnd.Intn(3) == 0 {
+					role = domain.Restricted
+				}
+			}
+
+			users = append(users, domain.User{
+				AccountID: accountID,
+				Name:      first + " " + last,
+				EMail: fmt.Sprintf("%s.%s.%d.%d@%s", strings.ToLower(first), strings.ToLower(last),
+					accountID, i+1, emailDomains[rnd.Intn(len(emailDomains))]),
+				Role:     role,
+				Password: fmt.Sprintf("%016x", rnd.Uint64()),
+			})
+		}
+	}
+	return users
+}
+
+// apiCreator creates users via accountd's HTTP API
+func apiCreator(c *client.Client) creator {
+	return func(ctx context.Context, u domain.User) error {
+		_, err := c.CreateUser(ctx, client.User{
+			AccountID: u.AccountID,
+			Name:      u.Name,
+			EMail:     u.EMail,
+			Role:      int(u.Role),
+			Password:  u.Password,
+		})
+		return err
+	}
+}
+
+// repoCreator creates active users directly in 'repo'
+func repoCreator(repo domain.UserRepository) creator {
+	return func(ctx context.Context, u domain.User) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		u.Status = domain.Active
+		if _, err := repo.CreateUser(u); err != nil {
+			return err
+		}
+		return nil
+	}
+}
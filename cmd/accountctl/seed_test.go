@@ -0,0 +1,138 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/youngkin/mockvideo/internal/db/memory"
+	"github.com/youngkin/mockvideo/internal/domain"
+)
+
+func TestDemoDataset(t *testing.T) {
+	tcs := []struct {
+		testName        string
+		accounts        int
+		usersPerAccount int
+		firstAccountID  int
+	}{
+		{
+			testName:        "testDemoDatasetPrimaryOnly",
+			accounts:        3,
+			usersPerAccount: 1,
+			firstAccountID:  1,
+		},
+		{
+			testName:        "testDemoDataset",
+			accounts:        20,
+			usersPerAccount: 6,
+			firstAccountID:  100,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			users := demoDataset(tc.accounts, tc.usersPerAccount, tc.firstAccountID, rand.New(rand.NewSource(1)))
+			if len(users) != tc.accounts*tc.usersPerAccount {
+				t.Fatalf("expected %d users, got %d", tc.accounts*tc.usersPerAccount, len(users))
+			}
+
+			emails := make(map[string]bool)
+			primaries := make(map[int]int)
+			for _, u := range users {
+				if err := u.ValidateUser(); err != nil {
+					t.Errorf("expected a valid user, got %+v: %s", u, err)
+				}
+				if u.AccountID < tc.firstAccountID || u.AccountID >= tc.firstAccountID+tc.accounts {
+					t.Errorf("account ID %d is outside the expected range", u.AccountID)
+				}
+				if emails[u.EMail] {
+					t.Errorf("duplicate email %s", u.EMail)
+				}
+				emails[u.EMail] = true
+				if u.Role == domain.Primary {
+					primaries[u.AccountID]++
+				}
+			}
+			for id := tc.firstAccountID; id < tc.firstAccountID+tc.accounts; id++ {
+				if primaries[id] != 1 {
+					t.Errorf("expected account %d to have 1 primary user, got %d", id, primaries[id])
+				}
+			}
+
+			again := demoDataset(tc.accounts, tc.usersPerAccount, tc.firstAccountID, rand.New(rand.NewSource(1)))
+			if !reflect.DeepEqual(users, again) {
+				t.Errorf("expected the same seed to generate the same dataset")
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	repo := memory.NewUserTable()
+	users := demoDataset(5, 3, 1, rand.New(rand.NewSource(1)))
+
+	if err := load(context.Background(), users, repoCreator(repo)); err != nil {
+		t.Fatalf("error %s was not expected loading the dataset", err)
+	}
+	stored, _ := repo.GetUsers()
+	if len(stored.Users) != len(users) {
+		t.Errorf("expected %d active users, got %d", len(users), len(stored.Users))
+	}
+
+	// Email addresses are unique, so loading the same dataset again fails on the first user
+	err := load(context.Background(), users, repoCreator(repo))
+	if err == nil {
+		t.Fatalf("expected an error loading a duplicate dataset")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = load(ctx, demoDataset(1, 1, 10, rand.New(rand.NewSource(1))), repoCreator(repo))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %s, got %v", context.Canceled, err)
+	}
+}
+
+func TestSeedFlags(t *testing.T) {
+	tcs := []struct {
+		testName string
+		args     []string
+	}{
+		{
+			testName: "testSeedUnknownDataset",
+			args:     []string{"--dataset", "prod"},
+		},
+		{
+			testName: "testSeedNoAccounts",
+			args:     []string{"--accounts", "0"},
+		},
+		{
+			testName: "testSeedNoUsers",
+			args:     []string{"--usersPerAccount", "0"},
+		},
+		{
+			testName: "testSeedInvalidFirstAccountID",
+			args:     []string{"--firstAccountID", "0"},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			if err := seed(tc.args, out); err == nil {
+				t.Errorf("expected an error for args %v", tc.args)
+			}
+			if out.Len() != 0 {
+				t.Errorf("expected no output, got %s", out)
+			}
+		})
+	}
+}
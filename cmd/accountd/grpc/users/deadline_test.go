@@ -0,0 +1,63 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package users
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// slowRepository is a domain.ContextualUserRepository whose GetUser, once bound to a context with a
+// deadline, blocks until the deadline passes and then fails as a DB request abandoned by the client would
+type slowRepository struct {
+	domain.UserRepository
+	ctx context.Context
+}
+
+// WithContext returns a copy of the slowRepository bound to 'ctx'
+func (r *slowRepository) WithContext(ctx context.Context) domain.UserRepository {
+	return &slowRepository{UserRepository: r.UserRepository, ctx: ctx}
+}
+
+// GetUser blocks until the deadline of the slowRepository's context passes if it has one
+func (r *slowRepository) GetUser(id int) (*domain.User, *mverr.MVError) {
+	if r.ctx == nil {
+		return r.UserRepository.GetUser(id)
+	}
+	if _, ok := r.ctx.Deadline(); !ok {
+		return r.UserRepository.GetUser(id)
+	}
+	<-r.ctx.Done()
+	return nil, &mverr.MVError{
+		ErrCode:    mverr.UserRqstErrorCode,
+		ErrMsg:     mverr.UserRqstErrorMsg,
+		ErrDetail:  "error scanning user row",
+		WrappedErr: r.ctx.Err()}
+}
+
+func TestGetUserDeadline(t *testing.T) {
+	server, err := NewUserServer(newUserSvc(t, &slowRepository{UserRepository: newRepo(t)}), logger, domain.QueryTimeout{})
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting a UserServer", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = server.GetUser(ctx, &UserID{Id: 1})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expected code %s once the client's deadline passed, got %v", codes.DeadlineExceeded, err)
+	}
+
+	// Without a deadline the request succeeds
+	if _, err = server.GetUser(context.Background(), &UserID{Id: 1}); err != nil {
+		t.Errorf("error %s was not expected without a deadline", err)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package users
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/db/memory"
+	"github.com/youngkin/mockvideo/internal/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLogin(t *testing.T) {
+	hash, err := auth.HashPassword("heyheywerethemonkees", auth.MinPasswordCost)
+	if err != nil {
+		t.Fatalf("error %s was not expected hashing the password", err)
+	}
+	keys, err := auth.ParseJWTKeys(strings.NewReader("2020-10 fedcba9876543210fedcba9876543210"))
+	if err != nil {
+		t.Fatalf("error %s was not expected parsing the JWT keys", err)
+	}
+
+	tcs := []struct {
+		testName       string
+		rqst           *LoginRequest
+		disabled       bool
+		expectedCode   codes.Code
+		expectedUserID int64
+	}{
+		{
+			testName:       "testLoginSuccess",
+			rqst:           &LoginRequest{EMail: "mickeyd@gmail.com", Password: "heyheywerethemonkees"},
+			expectedCode:   codes.OK,
+			expectedUserID: 1,
+		},
+		{
+			testName:     "testLoginWrongPassword",
+			rqst:         &LoginRequest{EMail: "mickeyd@gmail.com", Password: "daydreambeliever"},
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			testName:     "testLoginUnknownEmail",
+			rqst:         &LoginRequest{EMail: "ptork@gmail.com", Password: "heyheywerethemonkees"},
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			testName:     "testLoginDisabled",
+			rqst:         &LoginRequest{EMail: "mickeyd@gmail.com", Password: "heyheywerethemonkees"},
+			disabled:     true,
+			expectedCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			repo := memory.NewUserTable()
+			if _, err := repo.CreateUser(domain.User{AccountID: 2, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary, Password: hash}); err != nil {
+				t.Fatalf("error %s was not expected creating a user", err)
+			}
+			userSvc, err := services.NewUserSvc(repo, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			if !tc.disabled {
+				if err = userSvc.EnableLogin(keys, services.DefaultSessionTTL); err != nil {
+					t.Fatalf("error %s was not expected enabling logins", err)
+				}
+			}
+			srv, err := NewUserServer(userSvc, logger, domain.QueryTimeout{})
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting a UserServer", err)
+			}
+
+			session, err := srv.Login(context.Background(), tc.rqst)
+			if code := status.Code(err); code != tc.expectedCode {
+				t.Fatalf("expected code %s, got %s: %v", tc.expectedCode, code, err)
+			}
+			if err != nil {
+				return
+			}
+			if session.GetUserID().GetId() != tc.expectedUserID || session.GetExpiresAt() == nil {
+				t.Errorf("expected a session for user %d, got %+v", tc.expectedUserID, session)
+			}
+			if caller, err := keys.Caller(session.GetToken()); err != nil || int64(caller.UserID) != tc.expectedUserID {
+				t.Errorf("expected a token for user %d, got %+v, error %v", tc.expectedUserID, caller, err)
+			}
+		})
+	}
+}
@@ -5,10 +5,18 @@
 package users
 
 import (
+	"context"
 	"fmt"
+	"time"
 
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
 	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // DomainUserToProtobuf converts a User domain object into a protobuf User
@@ -20,9 +28,24 @@ func DomainUserToProtobuf(u *domain.User) *User {
 		Name:      u.Name,
 		EMail:     u.EMail,
 		Role:      RoleEnum(u.Role),
+		CreatedAt: timestampProto(u.CreatedAt),
+		UpdatedAt: timestampProto(u.UpdatedAt),
 	}
 }
 
+// timestampProto converts 't' to a protobuf Timestamp. A zero or otherwise unrepresentable
+// 't' is converted to nil so the field is omitted from the message.
+func timestampProto(t time.Time) *timestamp.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	ts, err := ptypes.TimestampProto(t)
+	if err != nil {
+		return nil
+	}
+	return ts
+}
+
 // DomainUsersToProtobuf converts a Users domain object into a protobuf Users
 func DomainUsersToProtobuf(us *domain.Users) *Users {
 	pbUsers := Users{}
@@ -34,7 +57,8 @@ func DomainUsersToProtobuf(us *domain.Users) *Users {
 	return &pbUsers
 }
 
-// ProtobufToUser converts a User to a domwin.User
+// ProtobufToUser converts a User to a domwin.User. CreatedAt and UpdatedAt are maintained
+// by the repository and are ignored.
 func ProtobufToUser(ub *User) (*domain.User, error) {
 	u := &domain.User{
 		AccountID: int(ub.AccountID),
@@ -65,14 +89,34 @@ func ProtobufToUsers(users *Users) (*domain.Users, error) {
 	return &dUsers, nil
 }
 
+// responseToPB converts a services.Response to a protobuf Response. ErrMsg and ErrReason are only
+// set if the request failed, a zero ErrReason is errors.NoErrorCode.
+func responseToPB(r services.Response) *Response {
+	response := &Response{
+		Status: statusToPBStatus(r.Status),
+		UserID: &UserID{
+			Id: int64(r.User.ID),
+		},
+	}
+	if r.Failed() {
+		response.ErrMsg = r.ErrMsg
+		response.ErrReason = int64(r.ErrReason)
+	}
+	return response
+}
+
 func statusToPBStatus(status services.Status) StatusEnum {
 	var pbStatus StatusEnum
 
 	switch status {
 	case services.StatusBadRequest:
 		pbStatus = StatusEnum_StatusBadRequest
+	case services.StatusConflict:
+		pbStatus = StatusEnum_StatusConflict
 	case services.StatusCreated:
 		pbStatus = StatusEnum_StatusCreated
+	case services.StatusForbidden:
+		pbStatus = StatusEnum_StatusForbidden
 	case services.StatusNotFound:
 		pbStatus = StatusEnum_StatusNotFound
 	case services.StatusOK:
@@ -85,3 +129,65 @@ func statusToPBStatus(status services.Status) StatusEnum {
 
 	return pbStatus
 }
+
+// statusToCode maps 'status' to the gRPC status code with the same meaning as the HTTP
+// status returned by the HTTP API
+func statusToCode(status services.Status) codes.Code {
+	var code codes.Code
+
+	switch status {
+	case services.StatusBadRequest:
+		code = codes.InvalidArgument
+	case services.StatusConflict:
+		code = codes.Aborted
+	case services.StatusCreated, services.StatusOK:
+		code = codes.OK
+	case services.StatusForbidden:
+		code = codes.PermissionDenied
+	case services.StatusNotFound:
+		code = codes.NotFound
+	case services.StatusServerError:
+		code = codes.Internal
+	default:
+		code = codes.Internal
+	}
+
+	return code
+}
+
+// statusError returns a gRPC status error, with the code corresponding to 'st', formatted according
+// to 'format'
+func statusError(st services.Status, format string, a ...interface{}) error {
+	return status.Errorf(statusToCode(st), format, a...)
+}
+
+// mvCodeError returns a gRPC status error with the code errors.GRPCCode maps the error code of 'mvErr'
+// to, and its message. It's used for errors whose code has no services.Status equivalent.
+func mvCodeError(mvErr *mverr.MVError) error {
+	return status.Error(mverr.GRPCCode(mvErr.ErrCode), mvErr.ErrMsg)
+}
+
+// mvStatusError returns a gRPC status error for 'mvErr', formatted according to 'format'. Its code
+// corresponds to 'st' unless the DB is unavailable, or in read-only mode and 'mvErr' is a rejected
+// write, in which case the code is codes.Unavailable and
+// the error includes a RetryInfo detail with the time the client should wait before retrying, the
+// gRPC equivalent of the HTTP API's "Retry-After" header. A DB query that timed out, or any error
+// once the deadline of the request's 'ctx' has passed, is codes.DeadlineExceeded.
+func mvStatusError(ctx context.Context, st services.Status, mvErr *mverr.MVError, format string, a ...interface{}) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return status.Errorf(codes.DeadlineExceeded, format, a...)
+	}
+	if mvErr != nil && mvErr.ErrCode == mverr.QueryTimeoutErrorCode {
+		return status.Errorf(mverr.GRPCCode(mvErr.ErrCode), format, a...)
+	}
+	if mvErr == nil || (mvErr.ErrCode != mverr.DBUnavailableErrorCode && mvErr.ErrCode != mverr.ReadOnlyModeErrorCode) {
+		return statusError(st, format, a...)
+	}
+
+	s := status.Newf(mverr.GRPCCode(mvErr.ErrCode), format, a...)
+	withDetails, err := s.WithDetails(&errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(mvErr.RetryAfter)})
+	if err != nil {
+		return s.Err()
+	}
+	return withDetails.Err()
+}
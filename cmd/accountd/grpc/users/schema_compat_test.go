@@ -0,0 +1,264 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package users
+
+/*
+TestSchemaCompatibility protects the clients of the gRPC API, e.g., pkg/accountd, from breaking
+changes to pkg/protobuf/accountd/user_service.proto. The schema compiled into this package is
+described, one RPC, enum value, or field per line, and compared against the description in
+testdata/testSchemaCompatibility.golden, i.e., the schema clients were built against. Removing,
+renaming, or renumbering a field or enum value, changing a field's type, or changing an RPC's
+request or response is a breaking change unless the removed number is reserved. Additions aren't
+breaking, but the golden file must be updated to include them so they're protected too:
+
+	go test ./cmd/accountd/grpc/users -run TestSchemaCompatibility -update
+*/
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+var update = flag.Bool("update", false, "update .golden files")
+
+const schemaGoldenFile = "testSchemaCompatibility.golden"
+
+func TestSchemaCompatibility(t *testing.T) {
+	current := describeSchema(File_pkg_protobuf_accountd_user_service_proto)
+	gf := filepath.Join("testdata", schemaGoldenFile)
+	if *update {
+		if err := ioutil.WriteFile(gf, []byte(strings.Join(current, "\n")+"\n"), 0644); err != nil {
+			t.Fatalf("failed to update golden file: %s", err)
+		}
+		return
+	}
+	gfc, err := ioutil.ReadFile(gf)
+	if err != nil {
+		t.Fatalf("failed reading golden file: %s", err)
+	}
+	golden := strings.Split(strings.TrimSpace(string(gfc)), "\n")
+
+	for _, change := range breakingChanges(golden, current) {
+		t.Errorf("breaking change: %s", change)
+	}
+	goldenKeys := make(map[string]bool)
+	for _, g := range golden {
+		goldenKeys[schemaKey(g)] = true
+	}
+	for _, c := range current {
+		if !goldenKeys[schemaKey(c)] {
+			t.Errorf("%q isn't in %s, run the test with -update to add it", c, gf)
+		}
+	}
+}
+
+func TestBreakingChanges(t *testing.T) {
+	golden := []string{
+		"rpc accountd.UserServer.GetUser accountd.UserID accountd.User",
+		"enumvalue accountd.RoleEnum 2 RESTRICTED",
+		"field accountd.User 1 AccountID optional int64",
+		"field accountd.User 2 HREF optional string",
+	}
+
+	tcs := []struct {
+		testName        string
+		current         []string
+		expectedChanges int
+	}{
+		{
+			testName:        "testUnchanged",
+			current:         golden,
+			expectedChanges: 0,
+		},
+		{
+			testName:        "testAdditions",
+			current:         append([]string{"field accountd.User 10 Phone optional string"}, golden...),
+			expectedChanges: 0,
+		},
+		{
+			testName:        "testFieldRemoved",
+			current:         golden[:3],
+			expectedChanges: 1,
+		},
+		{
+			testName: "testFieldRenumbered",
+			current: []string{
+				golden[0],
+				golden[1],
+				"field accountd.User 1 AccountID optional int64",
+				"field accountd.User 22 HREF optional string",
+			},
+			expectedChanges: 1,
+		},
+		{
+			testName: "testFieldRetyped",
+			current: []string{
+				golden[0],
+				golden[1],
+				"field accountd.User 1 AccountID repeated int64",
+				golden[3],
+			},
+			expectedChanges: 1,
+		},
+		{
+			testName: "testEnumValueRenamed",
+			current: []string{
+				golden[0],
+				"enumvalue accountd.RoleEnum 2 LIMITED",
+				golden[2],
+				golden[3],
+			},
+			expectedChanges: 1,
+		},
+		{
+			testName: "testRPCChanged",
+			current: []string{
+				"rpc accountd.UserServer.GetUser accountd.UserID accountd.Users",
+				golden[1],
+				golden[2],
+				golden[3],
+			},
+			expectedChanges: 1,
+		},
+		{
+			testName:        "testEverythingRemoved",
+			current:         []string{},
+			expectedChanges: 4,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			changes := breakingChanges(golden, tc.current)
+			if len(changes) != tc.expectedChanges {
+				t.Errorf("expected %d breaking changes, got %d: %v", tc.expectedChanges, len(changes), changes)
+			}
+		})
+	}
+}
+
+// describeSchema returns a line for each RPC, enum value, and field in 'fd', in declaration order:
+//
+//	rpc {service}.{method} {request} {response}
+//	enumvalue {enum} {number} {name}
+//	field {message} {number} {name} {cardinality} {type}
+//
+// Names are fully qualified, and streamed requests and responses are prefixed by 'stream:'.
+func describeSchema(fd protoreflect.FileDescriptor) []string {
+	lines := []string{}
+	for i := 0; i < fd.Services().Len(); i++ {
+		sd := fd.Services().Get(i)
+		for j := 0; j < sd.Methods().Len(); j++ {
+			md := sd.Methods().Get(j)
+			in, out := string(md.Input().FullName()), string(md.Output().FullName())
+			if md.IsStreamingClient() {
+				in = "stream:" + in
+			}
+			if md.IsStreamingServer() {
+				out = "stream:" + out
+			}
+			lines = append(lines, fmt.Sprintf("rpc %s %s %s", md.FullName(), in, out))
+		}
+	}
+	lines = append(lines, describeEnums(fd.Enums())...)
+	return append(lines, describeMessages(fd.Messages())...)
+}
+
+func describeEnums(eds protoreflect.EnumDescriptors) []string {
+	lines := []string{}
+	for i := 0; i < eds.Len(); i++ {
+		ed := eds.Get(i)
+		for j := 0; j < ed.Values().Len(); j++ {
+			vd := ed.Values().Get(j)
+			lines = append(lines, fmt.Sprintf("enumvalue %s %d %s", ed.FullName(), vd.Number(), vd.Name()))
+		}
+	}
+	return lines
+}
+
+func describeMessages(mds protoreflect.MessageDescriptors) []string {
+	lines := []string{}
+	for i := 0; i < mds.Len(); i++ {
+		md := mds.Get(i)
+		for j := 0; j < md.Fields().Len(); j++ {
+			fd := md.Fields().Get(j)
+			typ := fd.Kind().String()
+			switch fd.Kind() {
+			case protoreflect.EnumKind:
+				typ = string(fd.Enum().FullName())
+			case protoreflect.MessageKind, protoreflect.GroupKind:
+				typ = string(fd.Message().FullName())
+			}
+			lines = append(lines, fmt.Sprintf("field %s %d %s %s %s", md.FullName(), fd.Number(), fd.Name(), fd.Cardinality(), typ))
+		}
+		lines = append(lines, describeEnums(md.Enums())...)
+		lines = append(lines, describeMessages(md.Messages())...)
+	}
+	return lines
+}
+
+// schemaKey returns the part of a describeSchema line identifying an RPC, enum value, or field, e.g.,
+// 'field accountd.User 1'. The rest of the line must not change.
+func schemaKey(line string) string {
+	n := 3
+	if strings.HasPrefix(line, "rpc ") {
+		n = 2
+	}
+	return strings.Join(strings.Fields(line)[:n], " ")
+}
+
+// breakingChanges returns a description of each breaking change from the 'golden' schema to the 'current'
+// schema, both described by describeSchema. A removed field or enum value isn't breaking if its number is
+// reserved in the schema compiled into this package.
+func breakingChanges(golden, current []string) []string {
+	cur := make(map[string]string)
+	for _, c := range current {
+		cur[schemaKey(c)] = c
+	}
+
+	changes := []string{}
+	for _, g := range golden {
+		key := schemaKey(g)
+		c, ok := cur[key]
+		switch {
+		case ok && c != g:
+			changes = append(changes, fmt.Sprintf("%q changed to %q", g, c))
+		case !ok && !reserved(key):
+			changes = append(changes, fmt.Sprintf("%q removed without reserving its number", g))
+		}
+	}
+	return changes
+}
+
+// reserved returns true if the number of the field or enum value identified by 'key' is reserved
+func reserved(key string) bool {
+	parts := strings.Fields(key)
+	if len(parts) != 3 {
+		return false
+	}
+	n, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return false
+	}
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(parts[1]))
+	if err != nil {
+		return false
+	}
+	switch d := d.(type) {
+	case protoreflect.MessageDescriptor:
+		return parts[0] == "field" && d.ReservedRanges().Has(protoreflect.FieldNumber(n))
+	case protoreflect.EnumDescriptor:
+		return parts[0] == "enumvalue" && d.ReservedRanges().Has(protoreflect.EnumNumber(n))
+	}
+	return false
+}
@@ -0,0 +1,606 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package users
+
+/*
+These tests verify that the HTTP and gRPC transports have the same semantics. Each scenario
+is run against both transports, each backed by a services.UserSvc and an identically
+populated in-memory repository. The results, including the state of the repository after
+the request, must be the same for both transports.
+*/
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	log "github.com/sirupsen/logrus"
+	httpusers "github.com/youngkin/mockvideo/cmd/accountd/http/users"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/db/memory"
+	"github.com/youngkin/mockvideo/internal/domain"
+	"github.com/youngkin/mockvideo/internal/idgen"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// logger is used to control code-under-test logging behavior
+var logger logging.Logger
+
+func init() {
+	logger = logging.Default()
+	// Suppress all application logging
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+}
+
+// outcome is the transport independent result of a request
+type outcome struct {
+	// status is the overall status of the request
+	status services.Status
+	// users are the users returned by the request, if any
+	users []domain.User
+	// id is the ID of a created user
+	id int
+	// results are the sorted statuses of the individual requests in a bulk request
+	results []services.Status
+	// stored are the users in the repository after the request completed
+	stored []domain.User
+}
+
+// transport performs requests using a specific protocol
+type transport interface {
+	getUsers(ctx context.Context) outcome
+	getUser(ctx context.Context, id int) outcome
+	createUser(ctx context.Context, u domain.User) outcome
+	createUsers(ctx context.Context, users domain.Users) outcome
+	updateUser(ctx context.Context, u domain.User) outcome
+	updateUsers(ctx context.Context, users domain.Users) outcome
+	deleteUser(ctx context.Context, id int) outcome
+	deleteUsers(ctx context.Context, ids []int) outcome
+}
+
+// seedUsers are added to each transport's repository before each scenario
+var seedUsers = []domain.User{
+	{AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary, Password: "pw"},
+	{AccountID: 1, Name: "peter tork", EMail: "petert@gmail.com", Role: domain.Unrestricted, Password: "pw"},
+	{AccountID: 2, Name: "mama cass", EMail: "mama@gmail.com", Role: domain.Primary, Password: "pw"},
+}
+
+// userIDs are the IDs assigned to the users created by the scenarios, by email address. Users in bulk
+// requests are created concurrently, assigning IDs by email address makes them independent of the order
+// the users are created in.
+var userIDs = idgen.Keyed{
+	"mickeyd@gmail.com": 1,
+	"petert@gmail.com":  2,
+	"mama@gmail.com":    3,
+	"davyj@gmail.com":   4,
+	"davyj2@gmail.com":  5,
+}
+
+// newRepo returns a repository containing 'seedUsers'. Their IDs are 1, 2, and 3 respectively.
+func newRepo(t *testing.T) *memory.UserTable {
+	repo := memory.NewUserTable()
+	if err := repo.SetIDGenerator(userIDs); err != nil {
+		t.Fatalf("error %s was not expected setting the ID generator", err)
+	}
+	for _, u := range seedUsers {
+		u.Status = domain.Active
+		if _, err := repo.CreateUser(u); err != nil {
+			t.Fatalf("error %s was not expected seeding the repository", err)
+		}
+	}
+	return repo
+}
+
+func newUserSvc(t *testing.T, repo domain.UserRepository) *services.UserSvc {
+	userSvc, err := services.NewUserSvc(repo, logger, 10, 10, 10)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting a UserSvc", err)
+	}
+	return userSvc
+}
+
+// storedUsers returns every user in 'repo', including pending users, in ID order
+func storedUsers(repo *memory.UserTable) []domain.User {
+	stored := []domain.User{}
+	for id := 1; id <= 10; id++ {
+		u, _ := repo.GetUser(id)
+		if u == nil {
+			continue
+		}
+		stored = append(stored, domain.User{
+			AccountID: u.AccountID,
+			ID:        u.ID,
+			Name:      u.Name,
+			EMail:     u.EMail,
+			Role:      u.Role,
+			Status:    u.Status,
+		})
+	}
+	return stored
+}
+
+// returnedUser normalizes 'u' to the fields returned by both transports
+func returnedUser(u domain.User) domain.User {
+	return domain.User{
+		AccountID: u.AccountID,
+		HREF:      u.HREF,
+		ID:        u.ID,
+		Name:      u.Name,
+		EMail:     u.EMail,
+		Role:      u.Role,
+	}
+}
+
+func sortStatuses(statuses []services.Status) []services.Status {
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i] < statuses[j] })
+	return statuses
+}
+
+// httpTransport performs requests using the HTTP handler
+type httpTransport struct {
+	repo    *memory.UserTable
+	handler http.Handler
+}
+
+func newHTTPTransport(t *testing.T) transport {
+	repo := newRepo(t)
+	handler, err := httpusers.NewUserHandler(newUserSvc(t, repo), logger, 10, false, domain.QueryTimeout{})
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting a user handler", err)
+	}
+	return &httpTransport{repo: repo, handler: handler}
+}
+
+// httpToStatus maps the HTTP statuses returned by the HTTP handler to a services.Status
+var httpToStatus = map[int]services.Status{
+	http.StatusOK:                  services.StatusOK,
+	http.StatusNoContent:           services.StatusOK,
+	http.StatusCreated:             services.StatusCreated,
+	http.StatusBadRequest:          services.StatusBadRequest,
+	http.StatusForbidden:           services.StatusForbidden,
+	http.StatusNotFound:            services.StatusNotFound,
+	http.StatusConflict:            services.StatusConflict,
+	http.StatusInternalServerError: services.StatusServerError,
+}
+
+func (h *httpTransport) do(ctx context.Context, method, url string, payload interface{}, bulk bool) *httptest.ResponseRecorder {
+	body := []byte{}
+	if payload != nil {
+		body, _ = json.Marshal(payload)
+	}
+	rqst := httptest.NewRequest(method, url, bytes.NewReader(body)).WithContext(ctx)
+	if bulk {
+		rqst.Header.Set("Bulk-Request", "true")
+	}
+	rr := httptest.NewRecorder()
+	h.handler.ServeHTTP(rr, rqst)
+	return rr
+}
+
+func (h *httpTransport) getUsers(ctx context.Context) outcome {
+	rr := h.do(ctx, http.MethodGet, "/users", nil, false)
+	o := outcome{status: httpToStatus[rr.Code]}
+	users := domain.Users{}
+	if rr.Code == http.StatusOK && json.Unmarshal(rr.Body.Bytes(), &users) == nil {
+		for _, u := range users.Users {
+			o.users = append(o.users, returnedUser(*u))
+		}
+	}
+	o.stored = storedUsers(h.repo)
+	return o
+}
+
+func (h *httpTransport) getUser(ctx context.Context, id int) outcome {
+	rr := h.do(ctx, http.MethodGet, "/users/"+strconv.Itoa(id), nil, false)
+	o := outcome{status: httpToStatus[rr.Code]}
+	u := domain.User{}
+	if rr.Code == http.StatusOK && json.Unmarshal(rr.Body.Bytes(), &u) == nil {
+		o.users = append(o.users, returnedUser(u))
+	}
+	o.stored = storedUsers(h.repo)
+	return o
+}
+
+func (h *httpTransport) createUser(ctx context.Context, u domain.User) outcome {
+	rr := h.do(ctx, http.MethodPost, "/users", u, false)
+	o := outcome{status: httpToStatus[rr.Code]}
+	if rr.Code == http.StatusCreated {
+		o.id, _ = strconv.Atoi(strings.TrimPrefix(rr.Header().Get("Location"), "/users/"))
+	}
+	o.stored = storedUsers(h.repo)
+	return o
+}
+
+func (h *httpTransport) createUsers(ctx context.Context, users domain.Users) outcome {
+	return h.bulk(h.do(ctx, http.MethodPost, "/users", users, true))
+}
+
+func (h *httpTransport) updateUser(ctx context.Context, u domain.User) outcome {
+	rr := h.do(ctx, http.MethodPut, "/users/"+strconv.Itoa(u.ID), u, false)
+	return outcome{status: httpToStatus[rr.Code], stored: storedUsers(h.repo)}
+}
+
+func (h *httpTransport) updateUsers(ctx context.Context, users domain.Users) outcome {
+	return h.bulk(h.do(ctx, http.MethodPut, "/users", users, true))
+}
+
+func (h *httpTransport) deleteUser(ctx context.Context, id int) outcome {
+	rr := h.do(ctx, http.MethodDelete, "/users/"+strconv.Itoa(id), nil, false)
+	return outcome{status: httpToStatus[rr.Code], stored: storedUsers(h.repo)}
+}
+
+func (h *httpTransport) deleteUsers(ctx context.Context, ids []int) outcome {
+	return h.bulk(h.do(ctx, http.MethodDelete, "/users", httpusers.BulkDeleteRqst{IDs: ids}, true))
+}
+
+func (h *httpTransport) bulk(rr *httptest.ResponseRecorder) outcome {
+	o := outcome{status: httpToStatus[rr.Code]}
+	br := services.BulkResponse{}
+	if json.Unmarshal(rr.Body.Bytes(), &br) == nil {
+		for _, result := range br.Results {
+			o.results = append(o.results, result.Status)
+		}
+		o.results = sortStatuses(o.results)
+	}
+	o.stored = storedUsers(h.repo)
+	return o
+}
+
+// grpcTransport performs requests using the gRPC server
+type grpcTransport struct {
+	repo   *memory.UserTable
+	server UserServerServer
+}
+
+func newGRPCTransport(t *testing.T) transport {
+	repo := newRepo(t)
+	server, err := NewUserServer(newUserSvc(t, repo), logger, domain.QueryTimeout{})
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting a UserServer", err)
+	}
+	return &grpcTransport{repo: repo, server: server}
+}
+
+// codeToStatus maps the gRPC status codes returned by the gRPC server to a services.Status
+var codeToStatus = map[codes.Code]services.Status{
+	codes.OK:               services.StatusOK,
+	codes.InvalidArgument:  services.StatusBadRequest,
+	codes.PermissionDenied: services.StatusForbidden,
+	codes.NotFound:         services.StatusNotFound,
+	codes.Aborted:          services.StatusConflict,
+	codes.Internal:         services.StatusServerError,
+}
+
+// pbToStatus maps a StatusEnum to a services.Status
+var pbToStatus = map[StatusEnum]services.Status{
+	StatusEnum_StatusBadRequest:  services.StatusBadRequest,
+	StatusEnum_StatusOK:          services.StatusOK,
+	StatusEnum_StatusCreated:     services.StatusCreated,
+	StatusEnum_StatusConflict:    services.StatusConflict,
+	StatusEnum_StatusServerError: services.StatusServerError,
+	StatusEnum_StatusNotFound:    services.StatusNotFound,
+	StatusEnum_StatusForbidden:   services.StatusForbidden,
+}
+
+// errToOutcome returns an outcome whose status corresponds to 'err'. 'okStatus' is the status
+// if 'err' is nil.
+func errToOutcome(err error, okStatus services.Status) outcome {
+	if err == nil {
+		return outcome{status: okStatus}
+	}
+	st, found := codeToStatus[status.Code(err)]
+	if !found {
+		// Unmapped codes, e.g., 'Unknown', never match the HTTP transport's outcome
+		st = services.Status(-1)
+	}
+	return outcome{status: st}
+}
+
+func (g *grpcTransport) getUsers(ctx context.Context) outcome {
+	users, err := g.server.GetUsers(ctx, &empty.Empty{})
+	o := errToOutcome(err, services.StatusOK)
+	if err == nil {
+		for _, u := range users.Users {
+			// Returned users don't include passwords so they fail validation, the conversion is still valid
+			du, _ := ProtobufToUser(u)
+			o.users = append(o.users, returnedUser(*du))
+		}
+	}
+	o.stored = storedUsers(g.repo)
+	return o
+}
+
+func (g *grpcTransport) getUser(ctx context.Context, id int) outcome {
+	u, err := g.server.GetUser(ctx, &UserID{Id: int64(id)})
+	o := errToOutcome(err, services.StatusOK)
+	if err == nil {
+		du, _ := ProtobufToUser(u)
+		o.users = append(o.users, returnedUser(*du))
+	}
+	o.stored = storedUsers(g.repo)
+	return o
+}
+
+func (g *grpcTransport) createUser(ctx context.Context, u domain.User) outcome {
+	id, err := g.server.CreateUser(ctx, toPB(u))
+	o := errToOutcome(err, services.StatusCreated)
+	if err == nil {
+		o.id = int(id.GetId())
+	}
+	o.stored = storedUsers(g.repo)
+	return o
+}
+
+func (g *grpcTransport) createUsers(ctx context.Context, users domain.Users) outcome {
+	br, err := g.server.CreateUsers(ctx, toPBUsers(users))
+	return g.bulk(br, err, services.StatusCreated)
+}
+
+func (g *grpcTransport) updateUser(ctx context.Context, u domain.User) outcome {
+	_, err := g.server.UpdateUser(ctx, toPB(u))
+	o := errToOutcome(err, services.StatusOK)
+	o.stored = storedUsers(g.repo)
+	return o
+}
+
+func (g *grpcTransport) updateUsers(ctx context.Context, users domain.Users) outcome {
+	br, err := g.server.UpdateUsers(ctx, toPBUsers(users))
+	return g.bulk(br, err, services.StatusOK)
+}
+
+func (g *grpcTransport) deleteUser(ctx context.Context, id int) outcome {
+	_, err := g.server.DeleteUser(ctx, &UserID{Id: int64(id)})
+	o := errToOutcome(err, services.StatusOK)
+	o.stored = storedUsers(g.repo)
+	return o
+}
+
+func (g *grpcTransport) deleteUsers(ctx context.Context, ids []int) outcome {
+	pbIDs := &UserIDs{}
+	for _, id := range ids {
+		pbIDs.UserID = append(pbIDs.UserID, &UserID{Id: int64(id)})
+	}
+	br, err := g.server.DeleteUsers(ctx, pbIDs)
+	return g.bulk(br, err, services.StatusOK)
+}
+
+func (g *grpcTransport) bulk(br *BulkResponse, err error, okStatus services.Status) outcome {
+	o := errToOutcome(err, okStatus)
+	if br != nil {
+		if pbToStatus[br.OverallStatus] != o.status {
+			// The overall status and the returned error must agree
+			o.status = services.Status(-1)
+		}
+		for _, result := range br.Response {
+			o.results = append(o.results, pbToStatus[result.Status])
+		}
+		o.results = sortStatuses(o.results)
+	}
+	o.stored = storedUsers(g.repo)
+	return o
+}
+
+// toPB converts 'u' to a protobuf User. Unlike DomainUserToProtobuf the password is included.
+func toPB(u domain.User) *User {
+	pbUser := DomainUserToProtobuf(&u)
+	pbUser.Password = u.Password
+	return pbUser
+}
+
+// toPBUsers converts 'users' to protobuf Users, including their passwords
+func toPBUsers(users domain.Users) *Users {
+	pbUsers := &Users{}
+	for _, u := range users.Users {
+		pbUsers.Users = append(pbUsers.Users, toPB(*u))
+	}
+	return pbUsers
+}
+
+// withPassword returns 'u' with its password populated, as required for a create or update
+func withPassword(u domain.User, id int) domain.User {
+	u.ID = id
+	u.Password = "pw"
+	return u
+}
+
+func TestTransportParity(t *testing.T) {
+	primary := &auth.Caller{UserID: 1, AccountID: 1, Role: domain.Primary}
+	unrestricted := &auth.Caller{UserID: 2, AccountID: 1, Role: domain.Unrestricted}
+	newUser := domain.User{AccountID: 1, Name: "davy jones", EMail: "davyj@gmail.com", Role: domain.Restricted, Password: "pw"}
+	updatedUser := withPassword(seedUsers[1], 2)
+	updatedUser.Name = "peter thorkelson"
+
+	tcs := []struct {
+		testName       string
+		caller         *auth.Caller
+		rqst           func(context.Context, transport) outcome
+		expectedStatus services.Status
+	}{
+		{
+			testName:       "testGetUsers",
+			rqst:           func(ctx context.Context, tp transport) outcome { return tp.getUsers(ctx) },
+			expectedStatus: services.StatusOK,
+		},
+		{
+			testName:       "testGetUser",
+			rqst:           func(ctx context.Context, tp transport) outcome { return tp.getUser(ctx, 1) },
+			expectedStatus: services.StatusOK,
+		},
+		{
+			testName:       "testGetUserNotFound",
+			rqst:           func(ctx context.Context, tp transport) outcome { return tp.getUser(ctx, 100) },
+			expectedStatus: services.StatusNotFound,
+		},
+		{
+			testName:       "testCreateUser",
+			rqst:           func(ctx context.Context, tp transport) outcome { return tp.createUser(ctx, newUser) },
+			expectedStatus: services.StatusCreated,
+		},
+		{
+			testName: "testCreateUserDuplicate",
+			rqst: func(ctx context.Context, tp transport) outcome {
+				return tp.createUser(ctx, withPassword(seedUsers[0], 0))
+			},
+			expectedStatus: services.StatusBadRequest,
+		},
+		{
+			testName: "testCreateUserInvalid",
+			rqst: func(ctx context.Context, tp transport) outcome {
+				u := newUser
+				u.Name = ""
+				return tp.createUser(ctx, u)
+			},
+			expectedStatus: services.StatusBadRequest,
+		},
+		{
+			testName:       "testCreateUserWithID",
+			rqst:           func(ctx context.Context, tp transport) outcome { return tp.createUser(ctx, withPassword(newUser, 10)) },
+			expectedStatus: services.StatusBadRequest,
+		},
+		{
+			testName:       "testCreateUserAuthorized",
+			caller:         primary,
+			rqst:           func(ctx context.Context, tp transport) outcome { return tp.createUser(ctx, newUser) },
+			expectedStatus: services.StatusCreated,
+		},
+		{
+			testName:       "testCreateUserForbidden",
+			caller:         unrestricted,
+			rqst:           func(ctx context.Context, tp transport) outcome { return tp.createUser(ctx, newUser) },
+			expectedStatus: services.StatusForbidden,
+		},
+		{
+			testName:       "testUpdateUser",
+			rqst:           func(ctx context.Context, tp transport) outcome { return tp.updateUser(ctx, updatedUser) },
+			expectedStatus: services.StatusOK,
+		},
+		{
+			testName:       "testUpdateUserNotFound",
+			rqst:           func(ctx context.Context, tp transport) outcome { return tp.updateUser(ctx, withPassword(newUser, 100)) },
+			expectedStatus: services.StatusNotFound,
+		},
+		{
+			testName: "testUpdateUserForbidden",
+			caller:   unrestricted,
+			rqst: func(ctx context.Context, tp transport) outcome {
+				return tp.updateUser(ctx, withPassword(seedUsers[0], 1))
+			},
+			expectedStatus: services.StatusForbidden,
+		},
+		{
+			testName:       "testDeleteUser",
+			rqst:           func(ctx context.Context, tp transport) outcome { return tp.deleteUser(ctx, 2) },
+			expectedStatus: services.StatusOK,
+		},
+		{
+			testName:       "testDeleteUserNotFound",
+			rqst:           func(ctx context.Context, tp transport) outcome { return tp.deleteUser(ctx, 100) },
+			expectedStatus: services.StatusOK,
+		},
+		{
+			testName:       "testDeleteUserForbidden",
+			caller:         unrestricted,
+			rqst:           func(ctx context.Context, tp transport) outcome { return tp.deleteUser(ctx, 1) },
+			expectedStatus: services.StatusForbidden,
+		},
+		{
+			// User 1 is the primary user of account 1, which has other users
+			testName:       "testDeleteUserBlocked",
+			rqst:           func(ctx context.Context, tp transport) outcome { return tp.deleteUser(ctx, 1) },
+			expectedStatus: services.StatusConflict,
+		},
+		{
+			testName: "testCreateUsers",
+			rqst: func(ctx context.Context, tp transport) outcome {
+				u := newUser
+				u.EMail = "davyj2@gmail.com"
+				return tp.createUsers(ctx, domain.Users{Users: []*domain.User{&newUser, &u}})
+			},
+			expectedStatus: services.StatusCreated,
+		},
+		{
+			testName: "testCreateUsersPartialFailure",
+			rqst: func(ctx context.Context, tp transport) outcome {
+				dup := withPassword(seedUsers[0], 0)
+				return tp.createUsers(ctx, domain.Users{Users: []*domain.User{&newUser, &dup}})
+			},
+			expectedStatus: services.StatusConflict,
+		},
+		{
+			testName: "testUpdateUsers",
+			rqst: func(ctx context.Context, tp transport) outcome {
+				return tp.updateUsers(ctx, domain.Users{Users: []*domain.User{&updatedUser}})
+			},
+			expectedStatus: services.StatusOK,
+		},
+		{
+			testName: "testUpdateUsersPartialFailure",
+			rqst: func(ctx context.Context, tp transport) outcome {
+				missing := withPassword(newUser, 100)
+				return tp.updateUsers(ctx, domain.Users{Users: []*domain.User{&updatedUser, &missing}})
+			},
+			expectedStatus: services.StatusConflict,
+		},
+		{
+			testName: "testUpdateUsersForbidden",
+			caller:   unrestricted,
+			rqst: func(ctx context.Context, tp transport) outcome {
+				u := withPassword(seedUsers[0], 1)
+				return tp.updateUsers(ctx, domain.Users{Users: []*domain.User{&u}})
+			},
+			expectedStatus: services.StatusConflict,
+		},
+		{
+			testName:       "testDeleteUsers",
+			rqst:           func(ctx context.Context, tp transport) outcome { return tp.deleteUsers(ctx, []int{2, 3, 100}) },
+			expectedStatus: services.StatusOK,
+		},
+		{
+			// User 1 is the primary user of account 1, which has other users
+			testName:       "testDeleteUsersPartialFailure",
+			rqst:           func(ctx context.Context, tp transport) outcome { return tp.deleteUsers(ctx, []int{3, 1}) },
+			expectedStatus: services.StatusConflict,
+		},
+		{
+			testName:       "testDeleteUsersForbidden",
+			caller:         unrestricted,
+			rqst:           func(ctx context.Context, tp transport) outcome { return tp.deleteUsers(ctx, []int{1}) },
+			expectedStatus: services.StatusConflict,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.caller != nil {
+				ctx = auth.NewContext(ctx, *tc.caller)
+			}
+
+			httpOutcome := tc.rqst(ctx, newHTTPTransport(t))
+			grpcOutcome := tc.rqst(ctx, newGRPCTransport(t))
+
+			if httpOutcome.status != tc.expectedStatus {
+				t.Errorf("HTTP: expected status %s, got %s", services.StatusTypeName[tc.expectedStatus], services.StatusTypeName[httpOutcome.status])
+			}
+			if grpcOutcome.status != tc.expectedStatus {
+				t.Errorf("gRPC: expected status %s, got %s", services.StatusTypeName[tc.expectedStatus], services.StatusTypeName[grpcOutcome.status])
+			}
+			if !reflect.DeepEqual(httpOutcome, grpcOutcome) {
+				t.Errorf("transports diverged:\n\tHTTP: %+v\n\tgRPC: %+v", httpOutcome, grpcOutcome)
+			}
+		})
+	}
+}
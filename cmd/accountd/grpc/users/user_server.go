@@ -12,17 +12,25 @@ import (
 
 	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
 	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/basepath"
+	"github.com/youngkin/mockvideo/internal/domain"
 	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/httpclient"
 	"github.com/youngkin/mockvideo/internal/logging"
+	"github.com/youngkin/mockvideo/internal/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 const rqstStatus = "rqstStatus"
 
+// TruncatedHeader is the response header metadata key set to "true" when GetUsers returns the
+// users read before its query timed out rather than all of them
+const TruncatedHeader = "truncated"
+
 // UserRqstDur is used to capture the length of HTTP requests
-var UserRqstDur = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-	Namespace: "mockvideo",
+var UserRqstDur = metrics.NewHistogramVec(prometheus.HistogramOpts{
 	Subsystem: "user",
 	Name:      "user_request_duration_seconds",
 	Help:      "user request duration distribution in seconds",
@@ -33,53 +41,64 @@ var UserRqstDur = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 // UserServer implements the gRPC functions required to provide access to user related services
 type UserServer struct {
 	userSvc services.UserSvcInterface
-	logger  *log.Entry
+	logger  logging.Logger
+	// getUsersTimeout bounds the query of GetUsers
+	getUsersTimeout domain.QueryTimeout
 }
 
 // GetUser returns the User identified by GetUserRqst.Id
 func (s *UserServer) GetUser(ctx context.Context, rqst *UserID) (*User, error) {
 	start := time.Now()
 
-	s.logger.WithFields(log.Fields{
-		logging.RPCFunc: "GetUser",
-		logging.UserID:  rqst.Id,
-	}).Info("GetUser RPC request received")
+	logging.Log(s.logger, logging.DebugLevel, "GetUser RPC request received", func(f logging.Fields) {
+		f[logging.RPCFunc] = "GetUser"
+		f[logging.UserID] = rqst.Id
+	})
 
-	u, err := s.userSvc.GetUser(int(rqst.Id))
+	u, err := s.userSvc.GetUser(ctx, int(rqst.Id))
 	if err != nil {
-		UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusServerError]).Observe(float64(time.Since(start)) / float64(time.Second))
-		return nil, fmt.Errorf("Error received when getting user %d. Wrapped error: %s", rqst.Id, err)
-	}
-
-	if u == nil {
-		UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusNotFound]).Observe(float64(time.Since(start)) / float64(time.Second))
-		return nil, nil
+		status := services.StatusServerError
+		if err.ErrCode == mverr.DBNoUserErrorCode {
+			status = services.StatusNotFound
+		}
+		httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[status]), start)
+		return nil, mvStatusError(ctx, status, err, "Error received when getting user %d. Wrapped error: %s", rqst.Id, err)
 	}
 
+	u.HREF = basepath.HREF(ctx, fmt.Sprintf("/users/%d", u.ID))
 	userPB := DomainUserToProtobuf(u)
 
-	UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusOK]).Observe(float64(time.Since(start)) / float64(time.Second))
+	httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusOK]), start)
 
 	return userPB, nil
 }
 
-// GetUsers returns all known users
+// GetUsers returns all known users. If the query times out before all the users are read the
+// users read so far may be returned, see NewUserServer, with the TruncatedHeader set.
 func (s *UserServer) GetUsers(ctx context.Context, x *empty.Empty) (*Users, error) {
 	start := time.Now()
 
-	s.logger.WithFields(log.Fields{
-		logging.RPCFunc: "GetUsers",
-	}).Info("GetUsers RPC request received")
+	logging.Log(s.logger, logging.DebugLevel, "GetUsers RPC request received", func(f logging.Fields) {
+		f[logging.RPCFunc] = "GetUsers"
+	})
 
-	users, err := s.userSvc.GetUsers()
+	users, err := s.userSvc.GetUsers(ctx, s.getUsersTimeout)
 	if err != nil {
-		UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusServerError]).Observe(float64(time.Since(start)) / float64(time.Second))
-		return nil, fmt.Errorf("Error received when getting users. Wrapped error: %s", err)
+		httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusServerError]), start)
+		return nil, mvStatusError(ctx, services.StatusServerError, err, "Error received when getting users. Wrapped error: %s", err)
+	}
+	if users.Truncated {
+		if err := grpc.SetHeader(ctx, metadata.Pairs(TruncatedHeader, "true")); err != nil {
+			s.logger.Warnf("unable to set the %s header: %s", TruncatedHeader, err)
+		}
 	}
 
+	for _, u := range users.Users {
+		u.HREF = basepath.HREF(ctx, fmt.Sprintf("/users/%d", u.ID))
+	}
 	usersPB := DomainUsersToProtobuf(users)
 
-	UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusOK]).Observe(float64(time.Since(start)) / float64(time.Second))
+	httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusOK]), start)
 
 	return usersPB, nil
 }
@@ -88,16 +107,21 @@ func (s *UserServer) GetUsers(ctx context.Context, x *empty.Empty) (*Users, erro
 func (s *UserServer) CreateUser(ctx context.Context, u *User) (*UserID, error) {
 	start := time.Now()
 
-	s.logger.WithFields(log.Fields{
-		logging.RPCFunc:   "CreateUser",
-		logging.UserEMail: u.GetEMail(),
-	}).Info("CreateUser RPC request received")
+	logging.Log(s.logger, logging.DebugLevel, "CreateUser RPC request received", func(f logging.Fields) {
+		f[logging.RPCFunc] = "CreateUser"
+		f[logging.UserEMail] = u.GetEMail()
+	})
 
 	du, err := ProtobufToUser(u)
 	if err != nil {
-		return nil, fmt.Errorf("invalid protobuf.User value provided: Error: %s", err)
+		httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusBadRequest]), start)
+		return nil, statusError(services.StatusBadRequest, "invalid protobuf.User value provided: Error: %s", err)
 	}
-	id, mvErr := s.userSvc.CreateUser(*du)
+	if du.ID != 0 { // User ID must *NOT* be populated (i.e., with a non-zero value) on an insert
+		httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusBadRequest]), start)
+		return nil, statusError(services.StatusBadRequest, "expected User.ID = 0, got User.ID = %d", du.ID)
+	}
+	id, mvErr := s.userSvc.CreateUser(ctx, *du)
 	if mvErr != nil {
 		status := services.StatusServerError
 		switch mvErr.ErrCode {
@@ -105,18 +129,20 @@ func (s *UserServer) CreateUser(ctx context.Context, u *User) (*UserID, error) {
 			status = services.StatusBadRequest
 		case mverr.UserValidationErrorCode:
 			status = services.StatusBadRequest
+		case mverr.UserUnauthorizedErrorCode:
+			status = services.StatusForbidden
 		case mverr.DBUpSertErrorCode:
 			status = services.StatusServerError
 		default:
 			status = services.StatusServerError
 		}
-		UserRqstDur.WithLabelValues(services.StatusTypeName[status]).Observe(float64(time.Since(start)) / float64(time.Second))
-		return nil, fmt.Errorf("Error received creating a new user. Wrapped error: %s", mvErr)
+		httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[status]), start)
+		return nil, mvStatusError(ctx, status, mvErr, "Error received creating a new user. Wrapped error: %s", mvErr)
 	}
 
 	userIDPB := UserID{Id: int64(id)}
 
-	UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusCreated]).Observe(float64(time.Since(start)) / float64(time.Second))
+	httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusCreated]), start)
 
 	return &userIDPB, nil
 }
@@ -125,42 +151,35 @@ func (s *UserServer) CreateUser(ctx context.Context, u *User) (*UserID, error) {
 func (s *UserServer) CreateUsers(ctx context.Context, users *Users) (*BulkResponse, error) {
 	start := time.Now()
 
-	s.logger.WithFields(log.Fields{
-		logging.RPCFunc: "CreateUsers",
-	}).Info("CreateUsers RPC request received")
+	logging.Log(s.logger, logging.DebugLevel, "CreateUsers RPC request received", func(f logging.Fields) {
+		f[logging.RPCFunc] = "CreateUsers"
+	})
 	for _, u := range users.Users {
-		s.logger.WithFields(log.Fields{
-			logging.RPCFunc:   "CreateUsers",
-			logging.UserEMail: u.GetEMail(),
-		}).Info("CreateUsers RPC request received")
+		logging.Log(s.logger, logging.DebugLevel, "CreateUsers RPC request received", func(f logging.Fields) {
+			f[logging.RPCFunc] = "CreateUsers"
+			f[logging.UserEMail] = u.GetEMail()
+		})
 	}
 
 	du, err := ProtobufToUsers(users)
 	if err != nil {
-		return nil, fmt.Errorf("invalid protobuf.User value provided: Error: %s", err)
+		httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusBadRequest]), start)
+		return nil, statusError(services.StatusBadRequest, "invalid protobuf.User value provided: Error: %s", err)
 	}
 
-	responses, mvErr := s.userSvc.CreateUsers(*du)
+	responses, mvErr := s.userSvc.CreateUsers(ctx, *du)
 
 	bulkResponse := BulkResponse{OverallStatus: statusToPBStatus(responses.OverallStatus)}
 	for _, result := range responses.Results {
-		response := Response{
-			Status:    statusToPBStatus(result.Status),
-			ErrMsg:    result.ErrMsg,
-			ErrReason: int64(result.ErrReason),
-			UserID: &UserID{
-				Id: int64(result.User.ID),
-			},
-		}
-		bulkResponse.Response = append(bulkResponse.Response, &response)
+		bulkResponse.Response = append(bulkResponse.Response, responseToPB(result))
 	}
 
 	var retErr error
 	if mvErr != nil {
-		retErr = fmt.Errorf("Error received creating new users, %s", mvErr.WrappedErr)
+		retErr = statusError(responses.OverallStatus, "Error received creating new users, %s", mvErr.WrappedErr)
 	}
 
-	UserRqstDur.WithLabelValues(services.StatusTypeName[responses.OverallStatus]).Observe(float64(time.Since(start)) / float64(time.Second))
+	httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[responses.OverallStatus]), start)
 
 	s.logger.Debugf("CreateUsers: BulkResponse: %+v", &bulkResponse)
 
@@ -171,42 +190,35 @@ func (s *UserServer) CreateUsers(ctx context.Context, users *Users) (*BulkRespon
 func (s *UserServer) UpdateUsers(ctx context.Context, users *Users) (*BulkResponse, error) {
 	start := time.Now()
 
-	s.logger.WithFields(log.Fields{
-		logging.RPCFunc: "UpdateUsers",
-	}).Info("UpdateUsers RPC request received")
+	logging.Log(s.logger, logging.DebugLevel, "UpdateUsers RPC request received", func(f logging.Fields) {
+		f[logging.RPCFunc] = "UpdateUsers"
+	})
 	for _, u := range users.Users {
-		s.logger.WithFields(log.Fields{
-			logging.RPCFunc:   "UpdateUsers",
-			logging.UserEMail: u.GetEMail(),
-		}).Info("UpdateUsers RPC request received")
+		logging.Log(s.logger, logging.DebugLevel, "UpdateUsers RPC request received", func(f logging.Fields) {
+			f[logging.RPCFunc] = "UpdateUsers"
+			f[logging.UserEMail] = u.GetEMail()
+		})
 	}
 
 	du, err := ProtobufToUsers(users)
 	if err != nil {
-		return nil, fmt.Errorf("invalid protobuf.User value provided: Error: %s", err)
+		httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusBadRequest]), start)
+		return nil, statusError(services.StatusBadRequest, "invalid protobuf.User value provided: Error: %s", err)
 	}
 
-	responses, mvErr := s.userSvc.UpdateUsers(*du)
+	responses, mvErr := s.userSvc.UpdateUsers(ctx, *du)
 
 	bulkResponse := BulkResponse{OverallStatus: statusToPBStatus(responses.OverallStatus)}
 	for _, result := range responses.Results {
-		response := Response{
-			Status:    statusToPBStatus(result.Status),
-			ErrMsg:    result.ErrMsg,
-			ErrReason: int64(result.ErrReason),
-			UserID: &UserID{
-				Id: int64(result.User.ID),
-			},
-		}
-		bulkResponse.Response = append(bulkResponse.Response, &response)
+		bulkResponse.Response = append(bulkResponse.Response, responseToPB(result))
 	}
 
 	var retErr error
 	if mvErr != nil {
-		retErr = fmt.Errorf("Error received updating users. Wrapped error: %s", mvErr)
+		retErr = statusError(responses.OverallStatus, "Error received updating users. Wrapped error: %s", mvErr)
 	}
 
-	UserRqstDur.WithLabelValues(services.StatusTypeName[responses.OverallStatus]).Observe(float64(time.Since(start)) / float64(time.Second))
+	httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[responses.OverallStatus]), start)
 
 	s.logger.Debugf("UpdateUsers: BulkResponse: %+v", &bulkResponse)
 
@@ -217,30 +229,36 @@ func (s *UserServer) UpdateUsers(ctx context.Context, users *Users) (*BulkRespon
 func (s *UserServer) UpdateUser(ctx context.Context, u *User) (*empty.Empty, error) {
 	start := time.Now()
 
-	s.logger.WithFields(log.Fields{
-		logging.RPCFunc:   "UpdateUser",
-		logging.UserID:    u.GetID(),
-		logging.UserEMail: u.GetEMail(),
-	}).Info("UpdateUser RPC request received")
+	logging.Log(s.logger, logging.DebugLevel, "UpdateUser RPC request received", func(f logging.Fields) {
+		f[logging.RPCFunc] = "UpdateUser"
+		f[logging.UserID] = u.GetID()
+		f[logging.UserEMail] = u.GetEMail()
+	})
 
 	du, err := ProtobufToUser(u)
 	if err != nil {
-		UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusBadRequest]).Observe(float64(time.Since(start)) / float64(time.Second))
-		return nil, err
+		httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusBadRequest]), start)
+		return nil, statusError(services.StatusBadRequest, "invalid protobuf.User value provided: Error: %s", err)
 	}
 
 	var retErr error
-	upErr := s.userSvc.UpdateUser(*du)
+	upErr := s.userSvc.UpdateUser(ctx, *du)
 	if upErr != nil {
 		status := services.StatusServerError
-		if upErr.ErrCode == mverr.DBNoUserErrorCode {
+		if upErr.ErrCode == mverr.UserValidationErrorCode {
 			status = services.StatusBadRequest
 		}
-		UserRqstDur.WithLabelValues(services.StatusTypeName[status]).Observe(float64(time.Since(start)) / float64(time.Second))
-		return nil, fmt.Errorf("error received updating user %d with email %s. Wrapped error: %s", u.GetID(), u.GetEMail(), err)
+		if upErr.ErrCode == mverr.DBNoUserErrorCode {
+			status = services.StatusNotFound
+		}
+		if upErr.ErrCode == mverr.UserUnauthorizedErrorCode {
+			status = services.StatusForbidden
+		}
+		httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[status]), start)
+		return nil, mvStatusError(ctx, status, upErr, "error received updating user %d with email %s. Wrapped error: %s", u.GetID(), u.GetEMail(), upErr)
 	}
 
-	UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusOK]).Observe(float64(time.Since(start)) / float64(time.Second))
+	httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusOK]), start)
 	return &empty.Empty{}, retErr
 }
 
@@ -248,21 +266,65 @@ func (s *UserServer) UpdateUser(ctx context.Context, u *User) (*empty.Empty, err
 func (s *UserServer) DeleteUser(ctx context.Context, id *UserID) (*empty.Empty, error) {
 	start := time.Now()
 
-	s.logger.WithFields(log.Fields{
-		logging.RPCFunc: "DeleteUser",
-		logging.UserID:  id.GetId(),
-	}).Info("DeleteUser RPC request received")
+	logging.Log(s.logger, logging.DebugLevel, "DeleteUser RPC request received", func(f logging.Fields) {
+		f[logging.RPCFunc] = "DeleteUser"
+		f[logging.UserID] = id.GetId()
+	})
 
-	err := s.userSvc.DeleteUser(int(id.GetId()))
+	err := s.userSvc.DeleteUser(ctx, int(id.GetId()))
 	if err != nil {
-		UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusServerError]).Observe(float64(time.Since(start)) / float64(time.Second))
-		return nil, fmt.Errorf("error received deleting user %d", id.GetId())
+		status := services.StatusServerError
+		switch err.ErrCode {
+		case mverr.UserUnauthorizedErrorCode:
+			status = services.StatusForbidden
+		case mverr.DeleteBlockedErrorCode:
+			status = services.StatusConflict
+		}
+		httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[status]), start)
+		return nil, mvStatusError(ctx, status, err, "error received deleting user %d. Wrapped error: %s", id.GetId(), err)
 	}
 
-	UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusOK]).Observe(float64(time.Since(start)) / float64(time.Second))
+	httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusOK]), start)
 	return &empty.Empty{}, nil
 }
 
+// DeleteUsers deletes the set of users identified by the 'ids' parameter. Like DeleteUser it's
+// idempotent, deleting a user that doesn't exist succeeds.
+func (s *UserServer) DeleteUsers(ctx context.Context, ids *UserIDs) (*BulkResponse, error) {
+	start := time.Now()
+
+	logging.Log(s.logger, logging.DebugLevel, "DeleteUsers RPC request received", func(f logging.Fields) {
+		f[logging.RPCFunc] = "DeleteUsers"
+	})
+
+	userIDs := make([]int, 0, len(ids.GetUserID()))
+	for _, id := range ids.GetUserID() {
+		logging.Log(s.logger, logging.DebugLevel, "DeleteUsers RPC request received", func(f logging.Fields) {
+			f[logging.RPCFunc] = "DeleteUsers"
+			f[logging.UserID] = id.GetId()
+		})
+		userIDs = append(userIDs, int(id.GetId()))
+	}
+
+	responses, mvErr := s.userSvc.DeleteUsers(ctx, userIDs)
+
+	bulkResponse := BulkResponse{OverallStatus: statusToPBStatus(responses.OverallStatus)}
+	for _, result := range responses.Results {
+		bulkResponse.Response = append(bulkResponse.Response, responseToPB(result))
+	}
+
+	var retErr error
+	if mvErr != nil {
+		retErr = statusError(responses.OverallStatus, "Error received deleting users. Wrapped error: %s", mvErr)
+	}
+
+	httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[responses.OverallStatus]), start)
+
+	s.logger.Debugf("DeleteUsers: BulkResponse: %+v", &bulkResponse)
+
+	return &bulkResponse, retErr
+}
+
 // Health is used to determine the status or health of the service
 func (s *UserServer) Health(ctx context.Context, _ *empty.Empty) (*HealthMsg, error) {
 	return &HealthMsg{
@@ -270,10 +332,43 @@ func (s *UserServer) Health(ctx context.Context, _ *empty.Empty) (*HealthMsg, er
 	}, nil
 }
 
-// NewUserServer returns a properly configured grpc Server
-func NewUserServer(userSvc services.UserSvcInterface, logger *log.Entry) (UserServerServer, error) {
+// Login authenticates the user with the email address and password in 'rqst' and returns their
+// session. Its token is used to authenticate their other RPCs. An unknown email address, a wrong
+// password, or a user that isn't active all fail with the same Unauthenticated status.
+func (s *UserServer) Login(ctx context.Context, rqst *LoginRequest) (*Session, error) {
+	start := time.Now()
+
+	logging.Log(s.logger, logging.DebugLevel, "Login RPC request received", func(f logging.Fields) {
+		f[logging.RPCFunc] = "Login"
+	})
+
+	session, err := s.userSvc.Login(ctx, domain.Credentials{EMail: rqst.GetEMail(), Password: rqst.GetPassword()})
+	if err != nil {
+		switch err.ErrCode {
+		case mverr.InvalidCredentialsErrorCode:
+			httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusForbidden]), start)
+			return nil, mvCodeError(err)
+		case mverr.LoginDisabledErrorCode:
+			httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusNotFound]), start)
+			return nil, mvCodeError(err)
+		}
+		httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusServerError]), start)
+		return nil, mvStatusError(ctx, services.StatusServerError, err, "error received logging in. Wrapped error: %s", err)
+	}
+
+	httpclient.ObserveSince(ctx, UserRqstDur.WithLabelValues(services.StatusTypeName[services.StatusOK]), start)
+	return &Session{
+		Token:     session.Token,
+		ExpiresAt: timestampProto(session.ExpiresAt),
+		UserID:    &UserID{Id: int64(session.UserID)},
+	}, nil
+}
+
+// NewUserServer returns a properly configured grpc Server. 'getUsersTimeout' bounds the query
+// of GetUsers.
+func NewUserServer(userSvc services.UserSvcInterface, logger logging.Logger, getUsersTimeout domain.QueryTimeout) (UserServerServer, error) {
 	if logger == nil {
-		return nil, errors.New("non-nil log.Entry  required")
+		return nil, errors.New("non-nil Logger required")
 	}
-	return &UserServer{userSvc: userSvc, logger: logger}, nil
+	return &UserServer{userSvc: userSvc, logger: logger, getUsersTimeout: getUsersTimeout}, nil
 }
@@ -0,0 +1,382 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package accounts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/cmd/accountd/http/respond"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/basepath"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/inflight"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"github.com/youngkin/mockvideo/internal/metrics"
+)
+
+// rolesPath is the path identifying an account's user roles, e.g., '/accounts/{id}/users/roles'
+const rolesPath = "users/roles"
+
+// summaryPath is the path identifying an account's summary, e.g., '/accounts/{id}/summary'
+const summaryPath = "summary"
+
+// usagePath is the path identifying an account's API usage, e.g., '/accounts/{id}/usage'
+const usagePath = "usage"
+
+// exportPath is the path identifying an account's data exports, e.g., '/accounts/{id}/export' and
+// '/accounts/{id}/export/{jobID}'
+const exportPath = "export"
+
+// downloadPath is the path identifying the content of an export, e.g., '/accounts/{id}/export/{jobID}/download'
+const downloadPath = "download"
+
+// AccountRqstDur is used to capture the length of HTTP requests
+var AccountRqstDur = metrics.NewHistogramVec(prometheus.HistogramOpts{
+	Subsystem: "account",
+	Name:      "account_request_duration_seconds",
+	Help:      "account request duration distribution in seconds",
+	Buckets:   prometheus.LinearBuckets(0.001, .004, 50),
+}, respond.Labels)
+
+type handler struct {
+	userSvc    services.UserSvcInterface
+	accountSvc services.AccountSvcInterface
+	// exportSvc is nil when account exports are disabled
+	exportSvc services.ExportSvcInterface
+	logger    logging.Logger
+}
+
+// ServeHTTP handles the request
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := respond.NewRecorder(w)
+	defer respond.Observe(r, AccountRqstDur, accountRoute(r.URL.Path), rec, start)
+
+	// The request's ID is included in every message logged while handling it, see package errsummary
+	if id := inflight.FromContext(r.Context()); id != "" {
+		h.logger = h.logger.WithFields(logging.Fields{logging.RqstID: id})
+	}
+
+	h.logger.WithFields(logging.Fields{
+		logging.Method:     r.Method,
+		logging.Path:       r.URL.Path,
+		logging.RemoteAddr: r.RemoteAddr,
+	}).Info("HTTP request received")
+
+	switch {
+	case r.Method == http.MethodPost:
+		h.handlePost(rec, r)
+	case r.Method == http.MethodGet && strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/"+summaryPath):
+		h.handleGetSummary(rec, r)
+	case r.Method == http.MethodGet && strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/"+usagePath):
+		h.handleGetUsage(rec, r)
+	case r.Method == http.MethodGet && h.exportSvc != nil && isExportPath(r.URL.Path):
+		h.handleGetExport(rec, r)
+	default:
+		rec.WriteHeader(http.StatusNotImplemented)
+		rec.Write([]byte("Sorry, only POST /accounts/{id}/users/roles, GET /accounts/{id}/summary, GET /accounts/{id}/usage, and GET /accounts/{id}/export are supported."))
+	}
+}
+
+func (h handler) handlePost(w http.ResponseWriter, r *http.Request) {
+	completeRequest := func(httpStatus int, msg string) {
+		w.WriteHeader(httpStatus)
+		w.Write([]byte(msg))
+	}
+
+	// Expecting a URL.Path like '/accounts/{id}/users/roles'
+	accountID, err := getAccountID(r.URL.Path, rolesPath)
+	if err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.MalformedURLErrorCode,
+			logging.HTTPStatus:  http.StatusBadRequest,
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: err,
+		}).Error(mverr.MalformedURLMsg)
+		completeRequest(http.StatusBadRequest, mverr.MalformedURLMsg)
+		return
+	}
+
+	roles := make(map[int]domain.Role)
+	err = json.NewDecoder(r.Body).Decode(&roles)
+	if err != nil {
+		decodingErr := respond.DecodingError(err)
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   decodingErr.ErrCode,
+			logging.HTTPStatus:  respond.HTTPStatus(decodingErr.ErrCode),
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: err.Error(),
+		}).Error(decodingErr.ErrMsg)
+		completeRequest(respond.HTTPStatus(decodingErr.ErrCode), decodingErr.ErrMsg)
+		return
+	}
+	if len(roles) == 0 {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.RqstParsingErrorCode,
+			logging.HTTPStatus:  http.StatusBadRequest,
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: "no role changes in request",
+		}).Error(mverr.RqstParsingErrorMsg)
+		completeRequest(http.StatusBadRequest, mverr.RqstParsingErrorMsg)
+		return
+	}
+
+	err2 := h.userSvc.UpdateRoles(r.Context(), accountID, roles)
+	if err2 != nil {
+		httpStatus := http.StatusInternalServerError
+		switch err2.ErrCode {
+		case mverr.UserValidationErrorCode, mverr.DBNoUserErrorCode, mverr.InvalidRoleAssignmentErrorCode:
+			httpStatus = http.StatusBadRequest
+		case mverr.UserUnauthorizedErrorCode:
+			httpStatus = http.StatusForbidden
+		}
+		completeRequest(httpStatus, err2.ErrMsg)
+		return
+	}
+
+	completeRequest(http.StatusOK, "")
+}
+
+func (h handler) handleGetSummary(w http.ResponseWriter, r *http.Request) {
+	completeRequest := func(httpStatus int, msg string) {
+		w.WriteHeader(httpStatus)
+		w.Write([]byte(msg))
+	}
+
+	// Expecting a URL.Path like '/accounts/{id}/summary'
+	accountID, err := getAccountID(r.URL.Path, summaryPath)
+	if err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.MalformedURLErrorCode,
+			logging.HTTPStatus:  http.StatusBadRequest,
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: err,
+		}).Error(mverr.MalformedURLMsg)
+		completeRequest(http.StatusBadRequest, mverr.MalformedURLMsg)
+		return
+	}
+
+	summary, err2 := h.accountSvc.GetSummary(r.Context(), accountID)
+	if err2 != nil {
+		httpStatus := http.StatusInternalServerError
+		switch err2.ErrCode {
+		case mverr.DBNoAccountErrorCode:
+			httpStatus = http.StatusNotFound
+		case mverr.UserUnauthorizedErrorCode:
+			httpStatus = http.StatusForbidden
+		}
+		completeRequest(httpStatus, err2.ErrMsg)
+		return
+	}
+
+	summary.HREF = basepath.HREF(r.Context(), fmt.Sprintf("/accounts/%d/%s", accountID, summaryPath))
+	for _, user := range summary.Users {
+		user.HREF = basepath.HREF(r.Context(), "/users/"+strconv.Itoa(user.ID))
+	}
+
+	if err = respond.JSON(w, http.StatusOK, summary); err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.JSONMarshalingErrorCode,
+			logging.HTTPStatus:  http.StatusInternalServerError,
+			logging.ErrorDetail: err.Error(),
+		}).Error(mverr.JSONMarshalingErrorMsg)
+	}
+}
+
+func (h handler) handleGetUsage(w http.ResponseWriter, r *http.Request) {
+	// Expecting a URL.Path like '/accounts/{id}/usage'
+	accountID, err := getAccountID(r.URL.Path, usagePath)
+	if err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.MalformedURLErrorCode,
+			logging.HTTPStatus:  http.StatusBadRequest,
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: err,
+		}).Error(mverr.MalformedURLMsg)
+		respond.Text(w, http.StatusBadRequest, mverr.MalformedURLMsg)
+		return
+	}
+
+	usage, mvErr := h.accountSvc.GetUsage(r.Context(), accountID)
+	if mvErr != nil {
+		respond.Error(w, mvErr)
+		return
+	}
+
+	usage.HREF = basepath.HREF(r.Context(), fmt.Sprintf("/accounts/%d/%s", accountID, usagePath))
+	if err = respond.JSON(w, http.StatusOK, usage); err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.JSONMarshalingErrorCode,
+			logging.HTTPStatus:  http.StatusInternalServerError,
+			logging.ErrorDetail: err.Error(),
+		}).Error(mverr.JSONMarshalingErrorMsg)
+	}
+}
+
+// handleGetExport starts an export for '/accounts/{id}/export', returns the state of an export for
+// '/accounts/{id}/export/{jobID}', and returns the export's zip archive for
+// '/accounts/{id}/export/{jobID}/download'
+func (h handler) handleGetExport(w http.ResponseWriter, r *http.Request) {
+	accountID, jobID, download, err := parseExportPath(r.URL.Path)
+	if err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.MalformedURLErrorCode,
+			logging.HTTPStatus:  http.StatusBadRequest,
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: err,
+		}).Error(mverr.MalformedURLMsg)
+		respond.Text(w, http.StatusBadRequest, mverr.MalformedURLMsg)
+		return
+	}
+
+	if download {
+		h.handleDownloadExport(w, r, accountID, jobID)
+		return
+	}
+
+	var (
+		job        *domain.ExportJob
+		mvErr      *mverr.MVError
+		httpStatus = http.StatusOK
+	)
+	if jobID == 0 {
+		job, mvErr = h.exportSvc.StartExport(r.Context(), accountID)
+		httpStatus = http.StatusAccepted
+	} else {
+		job, mvErr = h.exportSvc.GetExport(r.Context(), accountID, jobID)
+	}
+	if mvErr != nil {
+		respond.Error(w, mvErr)
+		return
+	}
+
+	job.HREF = basepath.HREF(r.Context(), fmt.Sprintf("/accounts/%d/%s/%d", accountID, exportPath, job.ID))
+	if job.Status == domain.ExportComplete {
+		job.DownloadHREF = job.HREF + "/" + downloadPath
+	}
+	if httpStatus == http.StatusAccepted {
+		w.Header().Set("Location", job.HREF)
+	}
+	if err = respond.JSON(w, httpStatus, job); err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.JSONMarshalingErrorCode,
+			logging.HTTPStatus:  http.StatusInternalServerError,
+			logging.ErrorDetail: err.Error(),
+		}).Error(mverr.JSONMarshalingErrorMsg)
+	}
+}
+
+func (h handler) handleDownloadExport(w http.ResponseWriter, r *http.Request, accountID, jobID int) {
+	rc, mvErr := h.exportSvc.OpenExport(r.Context(), accountID, jobID)
+	if mvErr != nil {
+		respond.Error(w, mvErr)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="account-%d-export-%d.zip"`, accountID, jobID))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, rc); err != nil {
+		// The status has already been sent, the client will see a truncated archive
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.ExportErrorCode,
+			logging.AccountID:   accountID,
+			logging.ErrorDetail: err.Error(),
+		}).Error(mverr.ExportErrorMsg)
+	}
+}
+
+// accountRoute returns the template of the route matching 'path', e.g., '/accounts/{id}/summary'
+// for '/accounts/42/summary', for use as a metric label
+func accountRoute(path string) string {
+	for _, resource := range []string{rolesPath, summaryPath, usagePath} {
+		if _, err := getAccountID(path, resource); err == nil {
+			return "/accounts/{id}/" + resource
+		}
+	}
+	if _, jobID, download, err := parseExportPath(path); err == nil {
+		switch {
+		case download:
+			return "/accounts/{id}/" + exportPath + "/{jobID}/" + downloadPath
+		case jobID != 0:
+			return "/accounts/{id}/" + exportPath + "/{jobID}"
+		default:
+			return "/accounts/{id}/" + exportPath
+		}
+	}
+	return respond.UnmatchedRoute
+}
+
+// isExportPath returns true if 'path' is like '/accounts/{id}/export...'. The path may still be
+// malformed, see parseExportPath.
+func isExportPath(path string) bool {
+	pathNodes := strings.Split(strings.TrimPrefix(path, "/accounts/"), "/")
+	return len(pathNodes) > 1 && pathNodes[1] == exportPath
+}
+
+// parseExportPath returns the account ID and export job ID from a path like
+// '/accounts/{id}/export/{jobID}', and whether the path ends with '/download'. 'jobID' is 0 for
+// '/accounts/{id}/export'.
+func parseExportPath(path string) (accountID, jobID int, download bool, err error) {
+	pathNodes := strings.Split(strings.TrimSuffix(strings.TrimPrefix(path, "/accounts/"), "/"), "/")
+	if len(pathNodes) < 2 || len(pathNodes) > 4 || pathNodes[1] != exportPath ||
+		(len(pathNodes) == 4 && pathNodes[3] != downloadPath) {
+		return 0, 0, false, fmt.Errorf("expected path like /accounts/{id}/%s[/{jobID}[/%s]], got %s", exportPath, downloadPath, path)
+	}
+
+	accountID, err = strconv.Atoi(pathNodes[0])
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("invalid account ID, must be int, got %s", pathNodes[0])
+	}
+	if len(pathNodes) > 2 {
+		jobID, err = strconv.Atoi(pathNodes[2])
+		if err != nil || jobID <= 0 {
+			return 0, 0, false, fmt.Errorf("invalid export ID, must be a positive int, got %s", pathNodes[2])
+		}
+	}
+
+	return accountID, jobID, len(pathNodes) == 4, nil
+}
+
+// getAccountID returns the account ID from a path like '/accounts/{id}/{resource}', e.g.,
+// '/accounts/{id}/users/roles'
+func getAccountID(path, resource string) (int, error) {
+	pathNodes := strings.SplitN(strings.TrimPrefix(path, "/accounts/"), "/", 2)
+	if len(pathNodes) != 2 || strings.TrimSuffix(pathNodes[1], "/") != resource {
+		return 0, fmt.Errorf("expected path like /accounts/{id}/%s, got %s", resource, path)
+	}
+
+	id, err := strconv.Atoi(pathNodes[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid account ID, must be int, got %s", pathNodes[0])
+	}
+
+	return id, nil
+}
+
+// NewAccountHandler returns a properly configured *http.Handler. 'exportSvc' may be nil, in which
+// case account exports are disabled.
+func NewAccountHandler(userSvc services.UserSvcInterface, accountSvc services.AccountSvcInterface, exportSvc services.ExportSvcInterface, logger logging.Logger) (http.Handler, error) {
+	if userSvc == nil {
+		return nil, errors.New("non-nil services.UserSvcInterface required")
+	}
+	if accountSvc == nil {
+		return nil, errors.New("non-nil services.AccountSvcInterface required")
+	}
+	if logger == nil {
+		return nil, errors.New("non-nil Logger required")
+	}
+	return handler{userSvc: userSvc, accountSvc: accountSvc, exportSvc: exportSvc, logger: logger}, nil
+}
@@ -0,0 +1,650 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package accounts
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/cmd/accountd/http/respond"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/db/memory"
+	"github.com/youngkin/mockvideo/internal/db/tests"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// logger is used to control code-under-test logging behavior
+var logger logging.Logger
+
+func init() {
+	logger = logging.Default()
+	// Suppress all application logging
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+}
+
+func TestPOSTRoles(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		method             string
+		url                string
+		body               string
+		caller             *auth.Caller
+		expectedHTTPStatus int
+		setupFunc          func(*testing.T) (*sql.DB, sqlmock.Sqlmock)
+		teardownFunc       func(*testing.T, sqlmock.Sqlmock)
+	}{
+		{
+			testName:           "testPOSTRolesSuccess",
+			method:             http.MethodPost,
+			url:                "/accounts/1/users/roles",
+			body:               `{"1":1,"2":0}`,
+			expectedHTTPStatus: http.StatusOK,
+			setupFunc:          tests.DBUpdateRolesSetupHelper,
+			teardownFunc:       tests.DBCallTeardownHelper,
+		},
+		{
+			testName:           "testPOSTRolesByPrimary",
+			method:             http.MethodPost,
+			url:                "/accounts/1/users/roles",
+			body:               `{"1":1,"2":0}`,
+			caller:             &auth.Caller{UserID: 1, AccountID: 1, Role: domain.Primary},
+			expectedHTTPStatus: http.StatusOK,
+			setupFunc:          tests.DBUpdateRolesSetupHelper,
+			teardownFunc:       tests.DBCallTeardownHelper,
+		},
+		{
+			testName:           "testPOSTRolesTwoPrimaries",
+			method:             http.MethodPost,
+			url:                "/accounts/1/users/roles",
+			body:               `{"3":0}`,
+			expectedHTTPStatus: http.StatusBadRequest,
+			setupFunc:          tests.DBUpdateRolesRejectedSetupHelper,
+			teardownFunc:       tests.DBCallTeardownHelper,
+		},
+		{
+			testName:           "testPOSTRolesForbidden",
+			method:             http.MethodPost,
+			url:                "/accounts/1/users/roles",
+			body:               `{"1":1,"2":0}`,
+			caller:             &auth.Caller{UserID: 2, AccountID: 1, Role: domain.Unrestricted},
+			expectedHTTPStatus: http.StatusForbidden,
+			setupFunc:          tests.DBUpdateRolesNoCallSetupHelper,
+			teardownFunc:       tests.DBCallTeardownHelper,
+		},
+		{
+			testName:           "testPOSTRolesMalformedURL",
+			method:             http.MethodPost,
+			url:                "/accounts/one/users/roles",
+			body:               `{"1":1,"2":0}`,
+			expectedHTTPStatus: http.StatusBadRequest,
+			setupFunc:          tests.DBUpdateRolesNoCallSetupHelper,
+			teardownFunc:       tests.DBCallTeardownHelper,
+		},
+		{
+			testName:           "testPOSTRolesMalformedJSON",
+			method:             http.MethodPost,
+			url:                "/accounts/1/users/roles",
+			body:               `{"1":"primary"}`,
+			expectedHTTPStatus: http.StatusBadRequest,
+			setupFunc:          tests.DBUpdateRolesNoCallSetupHelper,
+			teardownFunc:       tests.DBCallTeardownHelper,
+		},
+		{
+			testName:           "testPOSTRolesEmpty",
+			method:             http.MethodPost,
+			url:                "/accounts/1/users/roles",
+			body:               `{}`,
+			expectedHTTPStatus: http.StatusBadRequest,
+			setupFunc:          tests.DBUpdateRolesNoCallSetupHelper,
+			teardownFunc:       tests.DBCallTeardownHelper,
+		},
+		{
+			testName:           "testGETRolesNotImplemented",
+			method:             http.MethodGet,
+			url:                "/accounts/1/users/roles",
+			expectedHTTPStatus: http.StatusNotImplemented,
+			setupFunc:          tests.DBUpdateRolesNoCallSetupHelper,
+			teardownFunc:       tests.DBCallTeardownHelper,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock := tc.setupFunc(t)
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			defer dbase.Close()
+
+			userSvc, err := services.NewUserSvc(ut, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+
+			accountSvc, err := services.NewAccountSvc(userSvc, nil, logger)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting AccountSvc", err)
+			}
+
+			h, err := NewAccountHandler(userSvc, accountSvc, nil, logger)
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting an account handler", err)
+			}
+
+			req := httptest.NewRequest(tc.method, tc.url, bytes.NewBufferString(tc.body))
+			if tc.caller != nil {
+				req = req.WithContext(auth.NewContext(req.Context(), *tc.caller))
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Errorf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, rr.Code)
+			}
+
+			tc.teardownFunc(t, mock)
+		})
+	}
+}
+
+// stubInvoiceSvc returns the same outstanding invoice total for every account
+type stubInvoiceSvc struct {
+	total float64
+}
+
+func (s stubInvoiceSvc) OutstandingTotal(ctx context.Context, accountID int) (float64, error) {
+	return s.total, nil
+}
+
+func TestGETSummary(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		url                string
+		caller             *auth.Caller
+		expectedHTTPStatus int
+		expectedSummary    *domain.AccountSummary
+	}{
+		{
+			testName:           "testGETSummarySuccess",
+			url:                "/accounts/1/summary",
+			caller:             &auth.Caller{UserID: 1, AccountID: 1, Role: domain.Primary},
+			expectedHTTPStatus: http.StatusOK,
+			expectedSummary: &domain.AccountSummary{
+				AccountID: 1,
+				HREF:      "/accounts/1/summary",
+				Users: []*domain.User{
+					{AccountID: 1, HREF: "/users/1", ID: 1, Name: "porgy tirebiter", EMail: "porgytirebiter@email.com", Role: domain.Primary, Status: domain.Active},
+					{AccountID: 1, HREF: "/users/2", ID: 2, Name: "mickey dolenz", EMail: "mdolenz@themonkeys.com", Role: domain.Restricted, Status: domain.Active},
+				},
+				RoleCounts:              map[domain.Role]int{domain.Primary: 1, domain.Restricted: 1},
+				OutstandingInvoiceTotal: func() *float64 { t := 24.99; return &t }(),
+			},
+		},
+		{
+			testName:           "testGETSummaryTrailingSlash",
+			url:                "/accounts/1/summary/",
+			expectedHTTPStatus: http.StatusOK,
+		},
+		{
+			testName:           "testGETSummaryNoAccount",
+			url:                "/accounts/9/summary",
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:           "testGETSummaryForbidden",
+			url:                "/accounts/1/summary",
+			caller:             &auth.Caller{UserID: 3, AccountID: 2, Role: domain.Primary},
+			expectedHTTPStatus: http.StatusForbidden,
+		},
+		{
+			testName:           "testGETSummaryMalformedURL",
+			url:                "/accounts/one/summary",
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			repo := memory.NewUserTable()
+			for _, u := range []domain.User{
+				{AccountID: 1, Name: "porgy tirebiter", EMail: "porgytirebiter@email.com", Role: domain.Primary, Status: domain.Active, Password: "pw"},
+				{AccountID: 1, Name: "mickey dolenz", EMail: "mdolenz@themonkeys.com", Role: domain.Restricted, Status: domain.Active, Password: "pw"},
+				{AccountID: 2, Name: "davy jones", EMail: "djones@themonkeys.com", Role: domain.Primary, Status: domain.Active, Password: "pw"},
+			} {
+				if _, err := repo.CreateUser(u); err != nil {
+					t.Fatalf("error %s was not expected creating a user", err)
+				}
+			}
+
+			userSvc, err := services.NewUserSvc(repo, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			accountSvc, err := services.NewAccountSvc(userSvc, stubInvoiceSvc{total: 24.99}, logger)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting AccountSvc", err)
+			}
+			h, err := NewAccountHandler(userSvc, accountSvc, nil, logger)
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting an account handler", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			if tc.caller != nil {
+				req = req.WithContext(auth.NewContext(req.Context(), *tc.caller))
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Fatalf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, rr.Code)
+			}
+			if tc.expectedSummary == nil {
+				return
+			}
+
+			actual := domain.AccountSummary{}
+			if err := json.NewDecoder(rr.Body).Decode(&actual); err != nil {
+				t.Fatalf("an error '%s' was not expected decoding response body", err)
+			}
+			// timestamps are set by the repository, only verify they were populated
+			for _, u := range actual.Users {
+				if u.CreatedAt.IsZero() || u.UpdatedAt.IsZero() {
+					t.Errorf("expected CreatedAt and UpdatedAt to be set, got %+v", u)
+				}
+				u.CreatedAt, u.UpdatedAt = time.Time{}, time.Time{}
+			}
+			expected, _ := json.Marshal(tc.expectedSummary)
+			got, _ := json.Marshal(actual)
+			if string(expected) != string(got) {
+				t.Errorf("expected summary %s, got %s", expected, got)
+			}
+		})
+	}
+}
+
+func TestGETUsage(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		url                string
+		caller             *auth.Caller
+		usageDisabled      bool
+		expectedHTTPStatus int
+		expectedUsage      *domain.AccountUsage
+	}{
+		{
+			testName:           "testGETUsageSuccess",
+			url:                "/accounts/1/usage",
+			caller:             &auth.Caller{UserID: 1, AccountID: 1, Role: domain.Primary},
+			expectedHTTPStatus: http.StatusOK,
+			expectedUsage: &domain.AccountUsage{
+				AccountID: 1,
+				HREF:      "/accounts/1/usage",
+				Window:    domain.UsageCounts{Requests: 4, Errors: 1, ErrorRate: 0.25},
+				Total:     domain.UsageCounts{Requests: 4, Errors: 1, ErrorRate: 0.25},
+			},
+		},
+		{
+			testName:           "testGETUsageNoRequests",
+			url:                "/accounts/9/usage",
+			expectedHTTPStatus: http.StatusOK,
+			expectedUsage:      &domain.AccountUsage{AccountID: 9, HREF: "/accounts/9/usage"},
+		},
+		{
+			testName:           "testGETUsageForbidden",
+			url:                "/accounts/1/usage",
+			caller:             &auth.Caller{UserID: 3, AccountID: 2, Role: domain.Primary},
+			expectedHTTPStatus: http.StatusForbidden,
+		},
+		{
+			testName:           "testGETUsageDisabled",
+			url:                "/accounts/1/usage",
+			usageDisabled:      true,
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:           "testGETUsageMalformedURL",
+			url:                "/accounts/one/usage",
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			userSvc, err := services.NewUserSvc(memory.NewUserTable(), logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			accountSvc, err := services.NewAccountSvc(userSvc, nil, logger)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting AccountSvc", err)
+			}
+			if !tc.usageDisabled {
+				tracker, err := services.NewUsageTracker(time.Hour, 10)
+				if err != nil {
+					t.Fatalf("error %s was not expected when getting UsageTracker", err)
+				}
+				for _, failed := range []bool{false, true, false, false} {
+					tracker.Record(1, failed)
+				}
+				if err = accountSvc.SetUsageReporter(tracker); err != nil {
+					t.Fatalf("error %s was not expected setting the UsageReporter", err)
+				}
+			}
+			h, err := NewAccountHandler(userSvc, accountSvc, nil, logger)
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting an account handler", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			if tc.caller != nil {
+				req = req.WithContext(auth.NewContext(req.Context(), *tc.caller))
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Fatalf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, rr.Code)
+			}
+			if tc.expectedUsage == nil {
+				return
+			}
+
+			actual := domain.AccountUsage{}
+			if err := json.NewDecoder(rr.Body).Decode(&actual); err != nil {
+				t.Fatalf("an error '%s' was not expected decoding response body", err)
+			}
+			// the window is started by the tracker's clock, only verify it was populated
+			if actual.WindowStart.IsZero() {
+				t.Errorf("expected WindowStart to be set, got %+v", actual)
+			}
+			actual.WindowStart = time.Time{}
+			if actual != *tc.expectedUsage {
+				t.Errorf("expected usage %+v, got %+v", *tc.expectedUsage, actual)
+			}
+		})
+	}
+}
+
+// stubUsageRecorder records the requests passed to Record
+type stubUsageRecorder struct {
+	accountIDs []int
+	failed     []bool
+}
+
+func (s *stubUsageRecorder) Record(accountID int, failed bool) {
+	s.accountIDs = append(s.accountIDs, accountID)
+	s.failed = append(s.failed, failed)
+}
+
+func TestUsageMiddleware(t *testing.T) {
+	tcs := []struct {
+		testName          string
+		url               string
+		caller            *auth.Caller
+		status            int
+		expectedRecorded  bool
+		expectedAccountID int
+		expectedFailed    bool
+	}{
+		{
+			testName:          "testUsageCaller",
+			url:               "/users/2",
+			caller:            &auth.Caller{UserID: 2, AccountID: 3, Role: domain.Restricted},
+			status:            http.StatusOK,
+			expectedRecorded:  true,
+			expectedAccountID: 3,
+		},
+		{
+			testName:          "testUsageCallerWinsOverURL",
+			url:               "/accounts/1/summary",
+			caller:            &auth.Caller{UserID: 2, AccountID: 3, Role: domain.Restricted},
+			status:            http.StatusForbidden,
+			expectedRecorded:  true,
+			expectedAccountID: 3,
+			expectedFailed:    true,
+		},
+		{
+			testName:          "testUsageAccountURL",
+			url:               "/accounts/1/summary",
+			status:            http.StatusServiceUnavailable,
+			expectedRecorded:  true,
+			expectedAccountID: 1,
+			expectedFailed:    true,
+		},
+		{
+			testName: "testUsageNoAccount",
+			url:      "/users/2",
+			status:   http.StatusOK,
+		},
+		{
+			testName: "testUsageMalformedAccountURL",
+			url:      "/accounts/one/summary",
+			status:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			usage := &stubUsageRecorder{}
+			h := UsageMiddleware(usage, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			if tc.caller != nil {
+				req = req.WithContext(auth.NewContext(req.Context(), *tc.caller))
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != tc.status {
+				t.Errorf("expected StatusCode = %d, got %d", tc.status, rr.Code)
+			}
+			if !tc.expectedRecorded {
+				if len(usage.accountIDs) != 0 {
+					t.Errorf("expected no usage to be recorded, got accounts %v", usage.accountIDs)
+				}
+				return
+			}
+			if len(usage.accountIDs) != 1 || usage.accountIDs[0] != tc.expectedAccountID || usage.failed[0] != tc.expectedFailed {
+				t.Errorf("expected account %d recorded with failed %t, got accounts %v, failed %v", tc.expectedAccountID, tc.expectedFailed, usage.accountIDs, usage.failed)
+			}
+		})
+	}
+}
+
+// stubExportSvc has a single export job, 7, for account 1 whose status is 'status'
+type stubExportSvc struct {
+	status domain.ExportStatus
+}
+
+func (s stubExportSvc) StartExport(ctx context.Context, accountID int) (*domain.ExportJob, *mverr.MVError) {
+	if accountID != 1 {
+		return nil, &mverr.MVError{ErrCode: mverr.UserUnauthorizedErrorCode, ErrMsg: mverr.UserUnauthorizedErrorMsg}
+	}
+	return &domain.ExportJob{ID: 7, AccountID: accountID, Status: domain.ExportPending}, nil
+}
+
+func (s stubExportSvc) GetExport(ctx context.Context, accountID, jobID int) (*domain.ExportJob, *mverr.MVError) {
+	if accountID != 1 || jobID != 7 {
+		return nil, &mverr.MVError{ErrCode: mverr.DBNoExportErrorCode, ErrMsg: mverr.DBNoExportErrorMsg}
+	}
+	return &domain.ExportJob{ID: 7, AccountID: accountID, Status: s.status}, nil
+}
+
+func (s stubExportSvc) OpenExport(ctx context.Context, accountID, jobID int) (io.ReadCloser, *mverr.MVError) {
+	job, err := s.GetExport(ctx, accountID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != domain.ExportComplete {
+		return nil, &mverr.MVError{ErrCode: mverr.ExportNotReadyErrorCode, ErrMsg: mverr.ExportNotReadyErrorMsg}
+	}
+	return ioutil.NopCloser(bytes.NewBufferString("zip archive")), nil
+}
+
+func TestGETExport(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		url                string
+		exportSvc          services.ExportSvcInterface
+		expectedHTTPStatus int
+		expectedHeaders    map[string]string
+		expectedJob        *domain.ExportJob
+		expectedBody       string
+	}{
+		{
+			testName:           "testGETExportStart",
+			url:                "/accounts/1/export",
+			exportSvc:          stubExportSvc{},
+			expectedHTTPStatus: http.StatusAccepted,
+			expectedHeaders:    map[string]string{"Location": "/accounts/1/export/7"},
+			expectedJob:        &domain.ExportJob{ID: 7, AccountID: 1, HREF: "/accounts/1/export/7", Status: domain.ExportPending},
+		},
+		{
+			testName:           "testGETExportStartForbidden",
+			url:                "/accounts/2/export",
+			exportSvc:          stubExportSvc{},
+			expectedHTTPStatus: http.StatusForbidden,
+		},
+		{
+			testName:           "testGETExportPending",
+			url:                "/accounts/1/export/7",
+			exportSvc:          stubExportSvc{status: domain.ExportPending},
+			expectedHTTPStatus: http.StatusOK,
+			expectedJob:        &domain.ExportJob{ID: 7, AccountID: 1, HREF: "/accounts/1/export/7", Status: domain.ExportPending},
+		},
+		{
+			testName:           "testGETExportComplete",
+			url:                "/accounts/1/export/7/",
+			exportSvc:          stubExportSvc{status: domain.ExportComplete},
+			expectedHTTPStatus: http.StatusOK,
+			expectedJob: &domain.ExportJob{ID: 7, AccountID: 1, HREF: "/accounts/1/export/7", Status: domain.ExportComplete,
+				DownloadHREF: "/accounts/1/export/7/download"},
+		},
+		{
+			testName:           "testGETExportNotFound",
+			url:                "/accounts/1/export/8",
+			exportSvc:          stubExportSvc{},
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:           "testGETExportDownload",
+			url:                "/accounts/1/export/7/download",
+			exportSvc:          stubExportSvc{status: domain.ExportComplete},
+			expectedHTTPStatus: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"Content-Type":        "application/zip",
+				"Content-Disposition": `attachment; filename="account-1-export-7.zip"`,
+			},
+			expectedBody: "zip archive",
+		},
+		{
+			testName:           "testGETExportDownloadNotReady",
+			url:                "/accounts/1/export/7/download",
+			exportSvc:          stubExportSvc{status: domain.ExportPending},
+			expectedHTTPStatus: http.StatusConflict,
+		},
+		{
+			testName:           "testGETExportMalformedURL",
+			url:                "/accounts/1/export/seven",
+			exportSvc:          stubExportSvc{},
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+		{
+			testName:           "testGETExportMalformedDownloadURL",
+			url:                "/accounts/1/export/7/bogus",
+			exportSvc:          stubExportSvc{},
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+		{
+			testName:           "testGETExportDisabled",
+			url:                "/accounts/1/export",
+			expectedHTTPStatus: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			userSvc, err := services.NewUserSvc(memory.NewUserTable(), logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			accountSvc, err := services.NewAccountSvc(userSvc, nil, logger)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting AccountSvc", err)
+			}
+			h, err := NewAccountHandler(userSvc, accountSvc, tc.exportSvc, logger)
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting an account handler", err)
+			}
+
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.url, nil))
+
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Fatalf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, rr.Code)
+			}
+			for name, expected := range tc.expectedHeaders {
+				if actual := rr.Header().Get(name); actual != expected {
+					t.Errorf("expected header %s to be %q, got %q", name, expected, actual)
+				}
+			}
+			if tc.expectedBody != "" && rr.Body.String() != tc.expectedBody {
+				t.Errorf("expected body %q, got %q", tc.expectedBody, rr.Body.String())
+			}
+			if tc.expectedJob == nil {
+				return
+			}
+
+			actual := domain.ExportJob{}
+			if err := json.NewDecoder(rr.Body).Decode(&actual); err != nil {
+				t.Fatalf("an error '%s' was not expected decoding response body", err)
+			}
+			if actual != *tc.expectedJob {
+				t.Errorf("expected job %+v, got %+v", *tc.expectedJob, actual)
+			}
+		})
+	}
+}
+
+func TestAccountRoute(t *testing.T) {
+	tcs := []struct {
+		path     string
+		expected string
+	}{
+		{path: "/accounts/1/users/roles", expected: "/accounts/{id}/users/roles"},
+		{path: "/accounts/1/summary/", expected: "/accounts/{id}/summary"},
+		{path: "/accounts/1/usage", expected: "/accounts/{id}/usage"},
+		{path: "/accounts/1/export", expected: "/accounts/{id}/export"},
+		{path: "/accounts/1/export/7", expected: "/accounts/{id}/export/{jobID}"},
+		{path: "/accounts/1/export/7/download", expected: "/accounts/{id}/export/{jobID}/download"},
+		{path: "/accounts/1/export/7/bogus", expected: respond.UnmatchedRoute},
+		{path: "/accounts/1", expected: respond.UnmatchedRoute},
+		{path: "/accounts/1/bogus", expected: respond.UnmatchedRoute},
+	}
+
+	for _, tc := range tcs {
+		if route := accountRoute(tc.path); route != tc.expected {
+			t.Errorf("expected route %s for path %s, got %s", tc.expected, tc.path, route)
+		}
+	}
+}
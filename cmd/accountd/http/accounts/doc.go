@@ -0,0 +1,136 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package accounts contains the implementations of the HTTP handlers for the 'accounts' resource. These
+handlers operate on an account as a whole, as opposed to the individual users in the account (see
+package 'users').
+
+Here are the supported resource URLs (prepended with '/accountd'):
+
+		/accounts/{id}/users/roles
+		/accounts/{id}/summary
+		/accounts/{id}/usage
+		/accounts/{id}/export
+		/accounts/{id}/export/{jobID}
+		/accounts/{id}/export/{jobID}/download
+		/signup
+
+Supported HTTP Verbs:
+
+		GET, POST
+
+A POST to '/accounts/{id}/users/roles' changes the roles of several users in the account at once, e.g.,
+when the account's primary user changes. The JSON body maps user IDs to their new roles. Valid values for
+a role are 0 (primary), 1 (unrestricted), 2 (restricted). Here's an example that makes user 2 the primary
+user and user 1 an unrestricted user:
+
+		curl -i -X POST http://accountd.kube/accounts/1/users/roles -H "Content-Type: application/json" -d "{\"1\":1,\"2\":0}"
+
+The role changes are applied in a single transaction, either all of them are applied or none are. A 200
+HTTP status indicates the roles were changed. Other HTTP status codes indicate various errors. These are:
+
+1. 400 Bad Request - The request was malformed, a user isn't in the account, a role is invalid, or the
+	change would leave the account without exactly one primary user.
+2. 403 Forbidden - The caller isn't a primary user of the account.
+3. 500 Internal Server Error - There was a problem with the server fulfilling the request. The request can be retried.
+4. 501 Not Implemented - The request is not supported (e.g., a GET request).
+
+A GET to '/accounts/{id}/summary' returns the account's users, the number of users in each role, and,
+when a billing service is available, the account's outstanding invoice total. The caller must be a user
+in the account, only primary users see the invoice total. Here's an example:
+
+		curl -i http://accountd.kube/accounts/1/summary
+
+		{
+			"accountid": 1,
+			"href": "/accounts/1/summary",
+			"users": [{"accountid":1,"href":"/users/1","id":1,"name":"porgy tirebiter","email":"porgytirebiter@email.com","role":0,"status":"active"}],
+			"rolecounts": {"0": 1},
+			"outstandinginvoicetotal": 24.99
+		}
+
+The users and the invoice total are retrieved concurrently. If one of them can't be retrieved the summary
+is still returned, with a 200 HTTP status, and the missing fields are listed in 'unavailable', e.g.,
+"unavailable": ["outstandinginvoicetotal"]. Other HTTP status codes indicate various errors. These are:
+
+1. 400 Bad Request - The URL was malformed.
+2. 403 Forbidden - The caller isn't a user in the account.
+3. 404 Not Found - The account has no users.
+4. 500 Internal Server Error - None of the summary could be retrieved. The request can be retried.
+
+A GET to '/accounts/{id}/usage' returns the account's API usage, the number of requests made on behalf
+of the account and how many of them failed with a 4xx or 5xx HTTP status, in the current window and in
+total. The caller must be a user in the account. Here's an example:
+
+		curl -i http://accountd.kube/accounts/1/usage
+
+		{
+			"accountid": 1,
+			"href": "/accounts/1/usage",
+			"windowstart": "2020-07-04T09:00:00Z",
+			"window": {"requests": 4, "errors": 1, "errorrate": 0.25},
+			"total": {"requests": 4, "errors": 1, "errorrate": 0.25}
+		}
+
+Usage is only kept in memory by each accountd instance, see UsageMiddleware. Other HTTP status codes
+indicate various errors. These are:
+
+1. 400 Bad Request - The URL was malformed.
+2. 403 Forbidden - The caller isn't a user in the account.
+3. 404 Not Found - Usage isn't being tracked.
+
+A GET to '/accounts/{id}/export' starts an export of the account's data, its users including their PII but
+excluding passwords. Exports are only available when accountd is configured with an export directory,
+otherwise the request returns 501 (Not Implemented). The export is generated in the background, the
+response is a 202 HTTP status with the export job and its location:
+
+		curl -i http://accountd.kube/accounts/1/export
+
+		HTTP/1.1 202 Accepted
+		Location: /accounts/1/export/1
+
+		{"id":1,"accountid":1,"href":"/accounts/1/export/1","status":"pending","created":"2020-05-01T12:00:00Z"}
+
+A GET to the job's location returns its current status, one of "pending", "complete", or "failed". Once
+complete, 'downloadhref' is the location of the export's zip archive, which contains the export as
+'account.json':
+
+		curl -o export.zip http://accountd.kube/accounts/1/export/1/download
+
+Export jobs are only kept in memory, they're lost when accountd restarts. The caller must be the account's
+primary user. Other HTTP status codes indicate various errors. These are:
+
+1. 400 Bad Request - The URL was malformed.
+2. 403 Forbidden - The caller isn't the account's primary user.
+3. 404 Not Found - The export job doesn't exist.
+4. 409 Conflict - The export was downloaded before it was complete. Retry after its status is "complete".
+5. 500 Internal Server Error - The export failed or couldn't be read.
+
+A POST to '/signup' creates a new account and its primary user. Signups don't require a caller, they're
+how new customers are onboarded. The account and user are created in a single transaction, either both
+are created or neither is. The user is created as the account's primary user, pending activation, and is
+sent an activation email as with 'POST /users'. Here's an example:
+
+		curl -i -X POST http://accountd.kube/signup -H "Content-Type: application/json" -d '{"account":{"accountholdername":"Porgy Tirebiter","serviceaddress":"1 Main St","billingaddress":"1 Main St","email":"porgytirebiter@email.com","phone":"5555550100"},"user":{"name":"porgy tirebiter","email":"porgytirebiter@email.com","password":"pw"}}'
+
+		HTTP/1.1 201 Created
+		Location: /accounts/3/summary
+
+		{"accounthref":"/accounts/3/summary","userhref":"/users/42"}
+
+The account may include a 'billingcontact', who's billed for the account, with a name, email, optional phone,
+and address. The address's 'country' must be an ISO 3166-1 alpha-2 code, e.g., "US", and its 'postalcode' must
+be valid in the country if its format is known, see domain.PostalValidator. Here's an example:
+
+		"billingcontact": {"name":"Porgy Tirebiter","email":"billing@email.com","address":{"street":"1 Main St","city":"Springfield","region":"OR","postalcode":"97477","country":"US"}}
+
+Other HTTP status codes indicate various errors. These are:
+
+1. 400 Bad Request - The request was malformed, the account or user is invalid, an ID was provided, or
+	there's already an account or user with the email address.
+2. 404 Not Found - Signups aren't enabled, i.e., accountd isn't using a MySQL database.
+3. 500 Internal Server Error - There was a problem with the server fulfilling the request. The request can be retried.
+*/
+package accounts
@@ -0,0 +1,124 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package accounts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/youngkin/mockvideo/cmd/accountd/http/respond"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/basepath"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/inflight"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// signupRoute is the route of signup requests
+const signupRoute = "/signup"
+
+// signupResponse is the response body of a successful signup
+type signupResponse struct {
+	AccountHREF string `json:"accounthref"`
+	UserHREF    string `json:"userhref"`
+}
+
+type signupHandler struct {
+	userSvc services.UserSvcInterface
+	logger  logging.Logger
+}
+
+// ServeHTTP handles the request
+func (h signupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := respond.NewRecorder(w)
+	route := signupRoute
+	if r.URL.Path != signupRoute {
+		route = respond.UnmatchedRoute
+	}
+	defer respond.Observe(r, AccountRqstDur, route, rec, start)
+
+	// The request's ID is included in every message logged while handling it, see package errsummary
+	if id := inflight.FromContext(r.Context()); id != "" {
+		h.logger = h.logger.WithFields(logging.Fields{logging.RqstID: id})
+	}
+
+	h.logger.WithFields(logging.Fields{
+		logging.Method:     r.Method,
+		logging.Path:       r.URL.Path,
+		logging.RemoteAddr: r.RemoteAddr,
+	}).Info("HTTP request received")
+
+	if r.Method != http.MethodPost || r.URL.Path != signupRoute {
+		respond.Text(rec, http.StatusNotImplemented, "Sorry, only POST /signup is supported.")
+		return
+	}
+	h.handlePost(rec, r)
+}
+
+func (h signupHandler) handlePost(w http.ResponseWriter, r *http.Request) {
+	signup := domain.Signup{}
+	err := json.NewDecoder(r.Body).Decode(&signup)
+	if err != nil {
+		decodingErr := respond.DecodingError(err)
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   decodingErr.ErrCode,
+			logging.HTTPStatus:  respond.HTTPStatus(decodingErr.ErrCode),
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: err.Error(),
+		}).Error(decodingErr.ErrMsg)
+		respond.Text(w, respond.HTTPStatus(decodingErr.ErrCode), decodingErr.ErrMsg)
+		return
+	}
+
+	// IDs are assigned by accountd, they must *NOT* be populated on a signup
+	if signup.Account.ID != 0 || signup.User.ID != 0 || signup.User.AccountID != 0 {
+		errMsg := fmt.Sprintf("expected Account.ID, User.ID, and User.AccountID = 0, got %d, %d, and %d",
+			signup.Account.ID, signup.User.ID, signup.User.AccountID)
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.InvalidInsertErrorCode,
+			logging.HTTPStatus:  http.StatusBadRequest,
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: errMsg,
+		}).Error(mverr.InvalidInsertErrorMsg)
+		respond.Text(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	accountID, userID, err2 := h.userSvc.Signup(r.Context(), signup)
+	if err2 != nil {
+		respond.Error(w, err2)
+		return
+	}
+
+	resp := signupResponse{
+		AccountHREF: basepath.HREF(r.Context(), fmt.Sprintf("/accounts/%d/%s", accountID, summaryPath)),
+		UserHREF:    basepath.HREF(r.Context(), fmt.Sprintf("/users/%d", userID)),
+	}
+	w.Header().Add("Location", resp.AccountHREF)
+	if err = respond.JSON(w, http.StatusCreated, resp); err != nil {
+		w.Header().Del("Location")
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.JSONMarshalingErrorCode,
+			logging.HTTPStatus:  http.StatusInternalServerError,
+			logging.ErrorDetail: err.Error(),
+		}).Error(mverr.JSONMarshalingErrorMsg)
+	}
+}
+
+// NewSignupHandler returns the *http.Handler for 'POST /signup'
+func NewSignupHandler(userSvc services.UserSvcInterface, logger logging.Logger) (http.Handler, error) {
+	if userSvc == nil {
+		return nil, errors.New("non-nil services.UserSvcInterface required")
+	}
+	if logger == nil {
+		return nil, errors.New("non-nil Logger required")
+	}
+	return signupHandler{userSvc: userSvc, logger: logger}, nil
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package accounts
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/db/memory"
+	"github.com/youngkin/mockvideo/internal/domain"
+)
+
+func TestPOSTSignup(t *testing.T) {
+	validBody := `{"account":{"accountholdername":"mickey dolenz","serviceaddress":"1 Main St","billingaddress":"1 Main St","email":"mickeyd@gmail.com","phone":"5555550100"},` +
+		`"user":{"name":"mickey dolenz","email":"mickeyd@gmail.com","password":"pw"}}`
+
+	tcs := []struct {
+		testName           string
+		method             string
+		url                string
+		body               string
+		disabled           bool
+		expectedHTTPStatus int
+		expected           *signupResponse
+	}{
+		{
+			testName:           "testPOSTSignupSuccess",
+			method:             http.MethodPost,
+			url:                "/signup",
+			body:               validBody,
+			expectedHTTPStatus: http.StatusCreated,
+			expected:           &signupResponse{AccountHREF: "/accounts/1/summary", UserHREF: "/users/2"},
+		},
+		{
+			testName:           "testPOSTSignupMalformedJSON",
+			method:             http.MethodPost,
+			url:                "/signup",
+			body:               `{"account":`,
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+		{
+			testName:           "testPOSTSignupWithAccountID",
+			method:             http.MethodPost,
+			url:                "/signup",
+			body:               `{"account":{"id":7},"user":{}}`,
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+		{
+			testName:           "testPOSTSignupInvalidAccount",
+			method:             http.MethodPost,
+			url:                "/signup",
+			body:               `{"account":{"email":"mickeyd@gmail.com"},"user":{"name":"mickey dolenz","email":"mickeyd@gmail.com","password":"pw"}}`,
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+		{
+			testName:           "testPOSTSignupDisabled",
+			method:             http.MethodPost,
+			url:                "/signup",
+			body:               validBody,
+			disabled:           true,
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:           "testGETSignupNotImplemented",
+			method:             http.MethodGet,
+			url:                "/signup",
+			expectedHTTPStatus: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			ut := memory.NewUserTable()
+			// Occupy user ID 1 so the account and user IDs differ
+			if _, err := ut.CreateUser(domain.User{AccountID: 9, Name: "davy jones", EMail: "davyj@gmail.com", Role: domain.Primary, Password: "pw"}); err != nil {
+				t.Fatalf("error %s was not expected creating a user", err)
+			}
+			userSvc, err := services.NewUserSvc(ut, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			if !tc.disabled {
+				userSvc.EnableSignup(memory.NewAccountTable())
+			}
+
+			handler, err := NewSignupHandler(userSvc, logger)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting a signup handler", err)
+			}
+
+			req := httptest.NewRequest(tc.method, tc.url, bytes.NewBufferString(tc.body))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Fatalf("expected HTTP status %d, got %d: %s", tc.expectedHTTPStatus, rr.Code, rr.Body.String())
+			}
+			if tc.expected == nil {
+				return
+			}
+
+			var actual signupResponse
+			if err = json.Unmarshal(rr.Body.Bytes(), &actual); err != nil {
+				t.Fatalf("error %s was not expected unmarshaling the response", err)
+			}
+			if actual != *tc.expected {
+				t.Errorf("expected %+v, got %+v", *tc.expected, actual)
+			}
+			if location := rr.Header().Get("Location"); location != tc.expected.AccountHREF {
+				t.Errorf("expected Location %s, got %s", tc.expected.AccountHREF, location)
+			}
+			if u, _ := ut.GetUser(2); u == nil || u.AccountID != 1 || u.Role != domain.Primary {
+				t.Errorf("expected user 2 to be account 1's primary user, got %+v", u)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package accounts
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/youngkin/mockvideo/cmd/accountd/http/respond"
+	"github.com/youngkin/mockvideo/internal/auth"
+)
+
+// UsageRecorder records the API requests made on behalf of an account, e.g., a services.UsageTracker
+type UsageRecorder interface {
+	Record(accountID int, failed bool)
+}
+
+// UsageMiddleware records each request handled by 'next' in 'usage'. A request is made on behalf
+// of the caller's account if the caller has been identified, e.g., by admin.ImpersonationMiddleware,
+// otherwise on behalf of the account in an '/accounts/{id}/...' URL. Other requests aren't
+// recorded. A request failed if its HTTP status is 4xx or 5xx.
+func UsageMiddleware(usage UsageRecorder, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accountID, ok := usageAccountID(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := respond.NewRecorder(w)
+		next.ServeHTTP(rec, r)
+		usage.Record(accountID, rec.Status() >= http.StatusBadRequest)
+	})
+}
+
+// usageAccountID returns the ID of the account that 'r' is made on behalf of, if any
+func usageAccountID(r *http.Request) (int, bool) {
+	if caller, ok := auth.FromContext(r.Context()); ok {
+		return caller.AccountID, true
+	}
+	if !strings.HasPrefix(r.URL.Path, "/accounts/") {
+		return 0, false
+	}
+	pathNodes := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/accounts/"), "/", 2)
+	id, err := strconv.Atoi(pathNodes[0])
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
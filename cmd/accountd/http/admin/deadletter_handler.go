@@ -0,0 +1,190 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/youngkin/mockvideo/cmd/accountd/http/respond"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/basepath"
+	"github.com/youngkin/mockvideo/internal/clientinfo"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// deadLettersPath is the path of dead letter requests, it's also the route label of their metrics
+const deadLettersPath = "/admin/deadletters"
+
+// replayPath is the final segment of the path of requests to replay a dead letter
+const replayPath = "replay"
+
+type deadLetterHandler struct {
+	admins      AdminAuthenticator
+	deadLetters services.DeadLetterSvcInterface
+	logger      logging.Logger
+}
+
+// ServeHTTP handles the request
+func (h deadLetterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := respond.NewRecorder(w)
+	defer respond.Observe(r, AdminRqstDur, deadLettersPath, rec, start)
+
+	h.logger.WithFields(logging.Fields{
+		logging.Method:     r.Method,
+		logging.Path:       r.URL.Path,
+		logging.RemoteAddr: r.RemoteAddr,
+	}).Info("HTTP request received")
+
+	if !h.admins.IsAdminToken(bearerToken(r)) {
+		h.logger.WithFields(logging.Fields{
+			logging.Audit:      true,
+			logging.Client:     clientinfo.FromContext(r.Context()),
+			logging.ErrorCode:  mverr.InvalidAdminTokenErrorCode,
+			logging.HTTPStatus: http.StatusUnauthorized,
+			logging.Path:       r.URL.Path,
+			logging.RemoteAddr: r.RemoteAddr,
+		}).Warn(mverr.InvalidAdminTokenErrorMsg)
+		respond.Text(rec, http.StatusUnauthorized, mverr.InvalidAdminTokenErrorMsg)
+		return
+	}
+
+	// Valid paths are '/admin/deadletters', '/admin/deadletters/{id}', and '/admin/deadletters/{id}/replay'
+	pathNodes := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, deadLettersPath), "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && pathNodes[0] == "":
+		h.handleGetAll(rec, r)
+	case r.Method == http.MethodGet && len(pathNodes) == 1:
+		h.handleGetOne(rec, r, pathNodes[0])
+	case r.Method == http.MethodPost && len(pathNodes) == 2 && pathNodes[1] == replayPath:
+		h.handleReplay(rec, r, pathNodes[0])
+	default:
+		respond.Text(rec, http.StatusNotImplemented, "Sorry, only GET /admin/deadletters[/{id}] and POST /admin/deadletters/{id}/replay are supported.")
+	}
+}
+
+func (h deadLetterHandler) handleGetAll(w http.ResponseWriter, r *http.Request) {
+	dls, err := h.deadLetters.GetDeadLetters(r.Context())
+	if err != nil {
+		h.logError(err, r)
+		respond.Error(w, err)
+		return
+	}
+	for _, dl := range dls.DeadLetters {
+		setDeadLetterHREFs(r.Context(), dl)
+	}
+	h.writeJSON(w, dls)
+}
+
+func (h deadLetterHandler) handleGetOne(w http.ResponseWriter, r *http.Request, idNode string) {
+	id, ok := h.parseID(w, r, idNode)
+	if !ok {
+		return
+	}
+	dl, err := h.deadLetters.GetDeadLetter(r.Context(), id)
+	if err != nil {
+		h.logError(err, r)
+		respond.Error(w, err)
+		return
+	}
+	setDeadLetterHREFs(r.Context(), dl)
+	h.writeJSON(w, dl)
+}
+
+func (h deadLetterHandler) handleReplay(w http.ResponseWriter, r *http.Request, idNode string) {
+	id, ok := h.parseID(w, r, idNode)
+	if !ok {
+		return
+	}
+	// The dead letter is retrieved first so the replayed work can be identified in the response
+	dl, err := h.deadLetters.GetDeadLetter(r.Context(), id)
+	if err == nil {
+		err = h.deadLetters.ReplayDeadLetter(r.Context(), id)
+	}
+	if err != nil {
+		h.logError(err, r)
+		respond.Error(w, err)
+		return
+	}
+
+	h.logger.WithFields(logging.Fields{
+		logging.Audit:        true,
+		logging.Client:       clientinfo.FromContext(r.Context()),
+		logging.DeadLetterID: id,
+		logging.RemoteAddr:   r.RemoteAddr,
+	}).Info("dead letter replay requested")
+
+	// The work is processed asynchronously, its status is available at its own resource
+	setDeadLetterHREFs(r.Context(), dl)
+	if dl.SourceHREF != "" {
+		w.Header().Set("Location", dl.SourceHREF)
+	}
+	respond.Status(w, http.StatusAccepted)
+}
+
+// parseID returns the dead letter ID in 'idNode'. If it's invalid a 400 response is written and
+// false is returned.
+func (h deadLetterHandler) parseID(w http.ResponseWriter, r *http.Request, idNode string) (int, bool) {
+	id, err := strconv.Atoi(idNode)
+	if err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:  mverr.MalformedURLErrorCode,
+			logging.HTTPStatus: http.StatusBadRequest,
+			logging.Path:       r.URL.Path,
+		}).Error(mverr.MalformedURLMsg)
+		respond.Text(w, http.StatusBadRequest, mverr.MalformedURLMsg)
+		return 0, false
+	}
+	return id, true
+}
+
+func (h deadLetterHandler) writeJSON(w http.ResponseWriter, body interface{}) {
+	if err := respond.JSON(w, http.StatusOK, body); err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.JSONMarshalingErrorCode,
+			logging.HTTPStatus:  http.StatusInternalServerError,
+			logging.ErrorDetail: err.Error(),
+		}).Error(mverr.JSONMarshalingErrorMsg)
+	}
+}
+
+func (h deadLetterHandler) logError(err *mverr.MVError, r *http.Request) {
+	h.logger.WithFields(logging.Fields{
+		logging.ErrorCode:   err.ErrCode,
+		logging.ErrorDetail: err.ErrDetail,
+		logging.HTTPStatus:  respond.HTTPStatus(err.ErrCode),
+		logging.Path:        r.URL.Path,
+	}).Error(err.ErrMsg)
+}
+
+// setDeadLetterHREFs sets the HREFs of 'dl' and, if it has a resource, the work it records
+func setDeadLetterHREFs(ctx context.Context, dl *domain.DeadLetter) {
+	dl.HREF = basepath.HREF(ctx, fmt.Sprintf("%s/%d", deadLettersPath, dl.ID))
+	if dl.Source == domain.DeadLetterUserCreate {
+		dl.SourceHREF = basepath.HREF(ctx, fmt.Sprintf("/users/pending/%d", dl.SourceID))
+	}
+}
+
+// NewDeadLetterHandler returns a properly configured *http.Handler for '/admin/deadletters'
+func NewDeadLetterHandler(admins AdminAuthenticator, deadLetters services.DeadLetterSvcInterface, logger logging.Logger) (http.Handler, error) {
+	if admins == nil {
+		return nil, errors.New("non-nil AdminAuthenticator required")
+	}
+	if deadLetters == nil {
+		return nil, errors.New("non-nil DeadLetterSvcInterface required")
+	}
+	if logger == nil {
+		return nil, errors.New("non-nil Logger required")
+	}
+	return deadLetterHandler{admins: admins, deadLetters: deadLetters, logger: logger}, nil
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+// fakeDeadLetterSvc is a services.DeadLetterSvcInterface with a single dead letter, ID 1
+type fakeDeadLetterSvc struct {
+	replayed []int
+}
+
+func (s *fakeDeadLetterSvc) GetDeadLetters(ctx context.Context) (*domain.DeadLetters, *mverr.MVError) {
+	dl, _ := s.GetDeadLetter(ctx, 1)
+	return &domain.DeadLetters{DeadLetters: []*domain.DeadLetter{dl}}, nil
+}
+
+func (s *fakeDeadLetterSvc) GetDeadLetter(ctx context.Context, id int) (*domain.DeadLetter, *mverr.MVError) {
+	if id != 1 {
+		return nil, &mverr.MVError{ErrCode: mverr.DBNoDeadLetterErrorCode, ErrMsg: mverr.DBNoDeadLetterErrorMsg}
+	}
+	return &domain.DeadLetter{ID: 1, Source: domain.DeadLetterUserCreate, SourceID: 7, Attempts: 3, ErrMsg: mverr.DBUnavailableErrorMsg}, nil
+}
+
+func (s *fakeDeadLetterSvc) ReplayDeadLetter(ctx context.Context, id int) *mverr.MVError {
+	s.replayed = append(s.replayed, id)
+	return nil
+}
+
+func TestDeadLetterHandler(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		method             string
+		url                string
+		adminToken         string
+		expectedHTTPStatus int
+		expectedLocation   string
+		expectedBody       string
+		expectedReplayed   int
+	}{
+		{
+			testName:           "testGETDeadLetters",
+			method:             http.MethodGet,
+			url:                "/admin/deadletters",
+			adminToken:         adminToken,
+			expectedHTTPStatus: http.StatusOK,
+			expectedBody:       `{"deadletters":[{"id":1,"href":"/admin/deadletters/1","source":"usercreate","sourceid":7,"sourcehref":"/users/pending/7","attempts":3,"errmsg":"DB unavailable, retry later","created":"0001-01-01T00:00:00Z"}]}`,
+		},
+		{
+			testName:           "testGETDeadLetter",
+			method:             http.MethodGet,
+			url:                "/admin/deadletters/1",
+			adminToken:         adminToken,
+			expectedHTTPStatus: http.StatusOK,
+			expectedBody:       `{"id":1,"href":"/admin/deadletters/1","source":"usercreate","sourceid":7,"sourcehref":"/users/pending/7","attempts":3,"errmsg":"DB unavailable, retry later","created":"0001-01-01T00:00:00Z"}`,
+		},
+		{
+			testName:           "testGETDeadLetterNotFound",
+			method:             http.MethodGet,
+			url:                "/admin/deadletters/2",
+			adminToken:         adminToken,
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:           "testGETDeadLetterInvalidID",
+			method:             http.MethodGet,
+			url:                "/admin/deadletters/one",
+			adminToken:         adminToken,
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+		{
+			testName:           "testPOSTReplay",
+			method:             http.MethodPost,
+			url:                "/admin/deadletters/1/replay",
+			adminToken:         adminToken,
+			expectedHTTPStatus: http.StatusAccepted,
+			expectedLocation:   "/users/pending/7",
+			expectedReplayed:   1,
+		},
+		{
+			testName:           "testPOSTReplayNotFound",
+			method:             http.MethodPost,
+			url:                "/admin/deadletters/2/replay",
+			adminToken:         adminToken,
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:           "testPOSTReplayBadAdminToken",
+			method:             http.MethodPost,
+			url:                "/admin/deadletters/1/replay",
+			adminToken:         "guess",
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testDELETEDeadLetterNotImplemented",
+			method:             http.MethodDelete,
+			url:                "/admin/deadletters/1",
+			adminToken:         adminToken,
+			expectedHTTPStatus: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			svc := &fakeDeadLetterSvc{}
+			h, err := NewDeadLetterHandler(newImpersonations(t), svc, logger)
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting a dead letter handler", err)
+			}
+
+			req := httptest.NewRequest(tc.method, tc.url, nil)
+			req.Header.Set("Authorization", "Bearer "+tc.adminToken)
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Fatalf("expected StatusCode = %d, got %d: %s", tc.expectedHTTPStatus, rr.Code, rr.Body.String())
+			}
+			if location := rr.Header().Get("Location"); location != tc.expectedLocation {
+				t.Errorf("expected Location %q, got %q", tc.expectedLocation, location)
+			}
+			if tc.expectedBody != "" {
+				var expected, actual interface{}
+				json.Unmarshal([]byte(tc.expectedBody), &expected)
+				if err = json.Unmarshal(rr.Body.Bytes(), &actual); err != nil {
+					t.Fatalf("error '%s' was not expected unmarshaling the response", err)
+				}
+				if string(mustMarshal(t, actual)) != string(mustMarshal(t, expected)) {
+					t.Errorf("expected body %s, got %s", tc.expectedBody, rr.Body.String())
+				}
+			}
+			if tc.expectedReplayed != 0 && (len(svc.replayed) != 1 || svc.replayed[0] != tc.expectedReplayed) {
+				t.Errorf("expected dead letter %d to be replayed, got %v", tc.expectedReplayed, svc.replayed)
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("error '%s' was not expected marshaling %v", err, v)
+	}
+	return b
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package admin contains the implementations of HTTP handlers and middleware used by support staff.
+They're only enabled when the 'adminToken' secret is configured.
+
+Here are the supported resource URLs (prepended with '/accountd'):
+
+		/admin/impersonate
+		/admin/debug/heapdump
+		/admin/deadletters
+		/admin/deadletters/{id}
+		/admin/deadletters/{id}/replay
+		/admin/requests
+		/admin/requests/{id}/cancel
+		/admin/errors
+
+Supported HTTP Verbs:
+
+		GET, POST
+
+Support staff can act as a user, e.g., to troubleshoot a problem the user has reported. A POST to
+'/admin/impersonate' exchanges the admin token for an impersonation token scoped to a single user. The
+request must include the admin token in an 'Authorization' header. The JSON body identifies the member
+of staff, the user to be impersonated, and the reason for the impersonation. All are required:
+
+		curl -i -X POST http://accountd.kube/admin/impersonate -H "Authorization: Bearer {adminToken}" -H "Content-Type: application/json" -d "{\"admin\":\"jsmith\",\"userid\":5,\"reason\":\"ticket 1234\"}"
+
+A 201 HTTP status indicates the token was granted. The response body contains the token and when it
+expires:
+
+		{
+			token: "8c3d...e1f0"
+			admin: "jsmith"
+			reason: "ticket 1234"
+			userid: 5
+			expires: "2020-07-04T09:45:00Z"
+		}
+
+The token is valid for 'impersonationTTLMinutes' (15 by default). This can't be configured to be more
+than 60 minutes. A new token must be requested once it has expired.
+
+Requests to '/users' and '/accounts' that include the token in an 'Authorization' header are made as
+the impersonated user, i.e., they're authorized exactly as if the user had made them:
+
+		curl -i http://accountd.kube/users/5 -H "Authorization: Bearer 8c3d...e1f0"
+
+Every impersonated request, along with every granted token and rejected admin or impersonation token,
+is logged with an 'Audit' field of 'true'. Impersonated requests also include the 'Impersonator' field
+identifying the member of staff. Impersonation isn't supported by the gRPC API.
+
+Services calling accountd use an API key, configured by the 'apiKeys' secret, instead of an impersonation
+token (see auth.ParseAPIKeys). APIKeyMiddleware adds the key's caller, with its scopes, to the request's
+context. Requests with an unknown key are rejected with a 401 HTTP status unless impersonation is enabled,
+in which case the key is checked as an impersonation token. Once API keys or impersonation are enabled,
+requests to '/users' and '/accounts' without a token are rejected with a 401 HTTP status.
+
+Users call accountd with a JWT signed by one of the keys configured by the 'jwtKeys' secret (see
+auth.ParseJWTKeys). JWTMiddleware adds the token's caller to the context of requests to '/users' and
+'/accounts'. Once JWTs are configured those requests must be authenticated, requests without a token or
+with an invalid JWT are rejected with a 401 HTTP status. User activations, which pending users make before
+they can log in, are never authenticated. Tokens that aren't JWTs are left to APIKeyMiddleware and
+ImpersonationMiddleware, if they're enabled.
+
+Support staff investigating memory usage can request a heap dump. A POST to '/admin/debug/heapdump'
+runs a garbage collection, writes a heap profile to the blob store, and returns its location. It's only
+enabled when the 'heapDumpDir' configuration identifies the directory the profiles are written to. The
+request must include the admin token and has no body:
+
+		curl -i -X POST http://accountd.kube/admin/debug/heapdump -H "Authorization: Bearer {adminToken}"
+
+A 201 HTTP status indicates the heap dump was created. The 'Location' header and the response body
+identify the profile, which can be examined using 'go tool pprof':
+
+		{
+			location: "file:///var/mockvideo/heapdumps/heap-20200704T093000.000Z.pprof"
+			created: "2020-07-04T09:30:00Z"
+		}
+
+The garbage collection stops the service briefly, so only one heap dump is created per
+'heapDumpIntervalSecs' (60 by default). Requests made sooner are rejected with a 429 HTTP status and a
+'Retry-After' header indicating when the next heap dump can be requested.
+
+Asynchronous work that fails too many times to be retried automatically, currently user creations queued in
+write-behind mode, is recorded as a dead letter. A GET to '/admin/deadletters' lists the dead letters, oldest
+first, and a GET to '/admin/deadletters/{id}' returns a single dead letter. Both require the admin token:
+
+		curl -i http://accountd.kube/admin/deadletters -H "Authorization: Bearer {adminToken}"
+
+		{
+			deadletters: [
+				{
+					id: 1
+					href: "/admin/deadletters/1"
+					source: "usercreate"
+					sourceid: 7
+					sourcehref: "/users/pending/7"
+					attempts: 3
+					errmsg: "DB unavailable, retry later"
+					created: "2020-07-04T09:30:00Z"
+				}
+			]
+		}
+
+Once the cause of the failures has been fixed a POST to '/admin/deadletters/{id}/replay' resubmits the work and
+removes the dead letter. The request has no body:
+
+		curl -i -X POST http://accountd.kube/admin/deadletters/1/replay -H "Authorization: Bearer {adminToken}"
+
+A 202 HTTP status indicates the work was resubmitted, the 'Location' header identifies the work so its progress
+can be followed. The dead letter endpoints are only enabled in write-behind mode. The number of dead letters is
+available in the 'service_dead_letters' metric.
+
+Every HTTP request is given an ID while it's being handled, returned in the 'X-Request-ID' response header.
+A GET to '/admin/requests' lists the requests currently in-flight, oldest first, including itself:
+
+		curl -i http://accountd.kube/admin/requests -H "Authorization: Bearer {adminToken}"
+
+		{
+			requests: [
+				{
+					id: "17"
+					method: "POST"
+					path: "/users"
+					started: "2020-07-04T09:30:00Z"
+					duration: "1m32.5s"
+				}
+			]
+		}
+
+A runaway request, e.g., a bulk request hogging the DB, can be cancelled with a POST to
+'/admin/requests/{id}/cancel'. The request has no body:
+
+		curl -i -X POST http://accountd.kube/admin/requests/17/cancel -H "Authorization: Bearer {adminToken}"
+
+A 202 HTTP status indicates the request's context was cancelled. It completes, with an error, once its
+handler notices, e.g., before its next DB query. gRPC requests aren't tracked.
+
+A GET to '/admin/errors' returns a summary of the errors logged since accountd started, most recently occurring
+first, so on-call engineers can see what's going wrong without querying the log aggregator (see package
+errsummary). It requires the admin token:
+
+		curl -i http://accountd.kube/admin/errors -H "Authorization: Bearer {adminToken}"
+
+		{
+			errors: [
+				{
+					code: 15
+					message: "DB query failed"
+					count: 3
+					lastoccurrence: "2020-07-04T09:30:00Z"
+					requestid: "17"
+				}
+			]
+		}
+
+'requestid' identifies the most recent request the error occurred in, it's omitted for errors that didn't occur
+while handling an HTTP request. Only the 'errorSummarySize' (50 by default) most recently occurring error codes
+are summarized.
+
+Other HTTP status codes indicate various errors. These are:
+
+1. 400 Bad Request - The request body was malformed or incomplete.
+2. 401 Unauthorized - The admin token, or for impersonated requests the impersonation token, is invalid
+	or has expired.
+3. 404 Not Found - The user to be impersonated, the dead letter, or the in-flight request doesn't exist.
+4. 429 Too Many Requests - A heap dump was requested too soon after the previous one.
+5. 500 Internal Server Error - There was a problem fulfilling the request. The request can be retried.
+*/
+package admin
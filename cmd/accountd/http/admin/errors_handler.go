@@ -0,0 +1,84 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/youngkin/mockvideo/cmd/accountd/http/respond"
+	"github.com/youngkin/mockvideo/internal/clientinfo"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/errsummary"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// errorsPath is the path of error summary requests, it's also the route label of their metrics
+const errorsPath = "/admin/errors"
+
+// errorSummary is the response body of 'GET /admin/errors'
+type errorSummary struct {
+	Errors []errsummary.Error `json:"errors"`
+}
+
+type errorsHandler struct {
+	admins  AdminAuthenticator
+	summary *errsummary.Summary
+	logger  logging.Logger
+}
+
+// ServeHTTP handles the request
+func (h errorsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := respond.NewRecorder(w)
+	defer respond.Observe(r, AdminRqstDur, errorsPath, rec, start)
+
+	h.logger.WithFields(logging.Fields{
+		logging.Method:     r.Method,
+		logging.Path:       r.URL.Path,
+		logging.RemoteAddr: r.RemoteAddr,
+	}).Info("HTTP request received")
+
+	if !h.admins.IsAdminToken(bearerToken(r)) {
+		h.logger.WithFields(logging.Fields{
+			logging.Audit:      true,
+			logging.Client:     clientinfo.FromContext(r.Context()),
+			logging.ErrorCode:  mverr.InvalidAdminTokenErrorCode,
+			logging.HTTPStatus: http.StatusUnauthorized,
+			logging.Path:       r.URL.Path,
+			logging.RemoteAddr: r.RemoteAddr,
+		}).Warn(mverr.InvalidAdminTokenErrorMsg)
+		respond.Text(rec, http.StatusUnauthorized, mverr.InvalidAdminTokenErrorMsg)
+		return
+	}
+
+	if r.Method != http.MethodGet || r.URL.Path != errorsPath {
+		respond.Text(rec, http.StatusNotImplemented, "Sorry, only GET /admin/errors is supported.")
+		return
+	}
+
+	if err := respond.JSON(rec, http.StatusOK, errorSummary{Errors: h.summary.Errors()}); err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.JSONMarshalingErrorCode,
+			logging.HTTPStatus:  http.StatusInternalServerError,
+			logging.ErrorDetail: err.Error(),
+		}).Error(mverr.JSONMarshalingErrorMsg)
+	}
+}
+
+// NewErrorsHandler returns a properly configured *http.Handler for '/admin/errors'
+func NewErrorsHandler(admins AdminAuthenticator, summary *errsummary.Summary, logger logging.Logger) (http.Handler, error) {
+	if admins == nil {
+		return nil, errors.New("non-nil AdminAuthenticator required")
+	}
+	if summary == nil {
+		return nil, errors.New("non-nil *errsummary.Summary required")
+	}
+	if logger == nil {
+		return nil, errors.New("non-nil Logger required")
+	}
+	return errorsHandler{admins: admins, summary: summary, logger: logger}, nil
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/clock"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/errsummary"
+)
+
+func TestErrorsHandler(t *testing.T) {
+	occurred := time.Date(2020, 7, 4, 9, 30, 0, 0, time.UTC)
+
+	tcs := []struct {
+		testName           string
+		method             string
+		url                string
+		adminToken         string
+		expectedHTTPStatus int
+		expectedErrors     []errsummary.Error
+	}{
+		{
+			testName:           "testGETErrors",
+			method:             http.MethodGet,
+			url:                "/admin/errors",
+			adminToken:         adminToken,
+			expectedHTTPStatus: http.StatusOK,
+			expectedErrors: []errsummary.Error{
+				{Code: mverr.DBQueryErrorCode, Message: mverr.DBQueryErrorMsg, Count: 2, LastOccurrence: occurred, RequestID: "17"},
+			},
+		},
+		{
+			testName:           "testGETErrorsBadAdminToken",
+			method:             http.MethodGet,
+			url:                "/admin/errors",
+			adminToken:         "guess",
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testDELETEErrorsNotImplemented",
+			method:             http.MethodDelete,
+			url:                "/admin/errors",
+			adminToken:         adminToken,
+			expectedHTTPStatus: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			summary, err := errsummary.NewSummary(errsummary.DefaultSize)
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting a Summary", err)
+			}
+			if err = summary.SetClock(clock.NewFrozen(occurred)); err != nil {
+				t.Fatalf("error '%s' was not expected when setting the clock", err)
+			}
+			summary.Record(mverr.DBQueryErrorCode, mverr.DBQueryErrorMsg, "17")
+			summary.Record(mverr.DBQueryErrorCode, mverr.DBQueryErrorMsg, "")
+			h, err := NewErrorsHandler(newImpersonations(t), summary, logger)
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting an errors handler", err)
+			}
+
+			req := httptest.NewRequest(tc.method, tc.url, nil)
+			req.Header.Set("Authorization", "Bearer "+tc.adminToken)
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Fatalf("expected StatusCode = %d, got %d: %s", tc.expectedHTTPStatus, rr.Code, rr.Body.String())
+			}
+			if tc.expectedErrors == nil {
+				return
+			}
+			actual := errorSummary{}
+			if err = json.Unmarshal(rr.Body.Bytes(), &actual); err != nil {
+				t.Fatalf("error '%s' was not expected unmarshaling the response", err)
+			}
+			if string(mustMarshal(t, actual.Errors)) != string(mustMarshal(t, tc.expectedErrors)) {
+				t.Errorf("expected errors %+v, got %s", tc.expectedErrors, rr.Body.String())
+			}
+		})
+	}
+}
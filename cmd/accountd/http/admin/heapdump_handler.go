@@ -0,0 +1,185 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/youngkin/mockvideo/cmd/accountd/http/respond"
+	"github.com/youngkin/mockvideo/internal/blob"
+	"github.com/youngkin/mockvideo/internal/clientinfo"
+	"github.com/youngkin/mockvideo/internal/clock"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// heapDumpPath is the path of requests for a heap dump
+const heapDumpPath = "/admin/debug/heapdump"
+
+// DefaultHeapDumpInterval is the minimum time between heap dumps unless configured otherwise
+const DefaultHeapDumpInterval = time.Minute
+
+// AdminAuthenticator verifies the admin token presented with an administrative request.
+// *auth.Impersonations is an AdminAuthenticator.
+type AdminAuthenticator interface {
+	IsAdminToken(token string) bool
+}
+
+// heapDump describes a stored heap profile
+type heapDump struct {
+	Location string    `json:"location"`
+	Created  time.Time `json:"created"`
+}
+
+type heapDumpHandler struct {
+	admins   AdminAuthenticator
+	store    blob.Store
+	interval time.Duration
+	logger   logging.Logger
+
+	mu       sync.Mutex
+	clock    clock.Clock
+	lastDump time.Time
+}
+
+// ServeHTTP handles the request
+func (h *heapDumpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := respond.NewRecorder(w)
+	defer respond.Observe(r, AdminRqstDur, heapDumpPath, rec, start)
+
+	h.logger.WithFields(logging.Fields{
+		logging.Method:     r.Method,
+		logging.Path:       r.URL.Path,
+		logging.RemoteAddr: r.RemoteAddr,
+	}).Info("HTTP request received")
+
+	if r.Method != http.MethodPost {
+		respond.Text(rec, http.StatusNotImplemented, "Sorry, only POST /admin/debug/heapdump is supported.")
+		return
+	}
+	h.handlePost(rec, r)
+}
+
+func (h *heapDumpHandler) handlePost(w http.ResponseWriter, r *http.Request) {
+	if !h.admins.IsAdminToken(bearerToken(r)) {
+		h.logger.WithFields(logging.Fields{
+			logging.Audit:      true,
+			logging.Client:     clientinfo.FromContext(r.Context()),
+			logging.ErrorCode:  mverr.InvalidAdminTokenErrorCode,
+			logging.HTTPStatus: http.StatusUnauthorized,
+			logging.Path:       r.URL.Path,
+			logging.RemoteAddr: r.RemoteAddr,
+		}).Warn(mverr.InvalidAdminTokenErrorMsg)
+		respond.Text(w, http.StatusUnauthorized, mverr.InvalidAdminTokenErrorMsg)
+		return
+	}
+
+	// Creating a heap dump stops the world for a garbage collection, so dumps are limited to one
+	// per interval. The slot is reserved before the dump is created so concurrent requests can't
+	// both pass the check.
+	now, retryAfter := h.reserve()
+	if retryAfter > 0 {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:  mverr.HeapDumpRateLimitedErrorCode,
+			logging.HTTPStatus: http.StatusTooManyRequests,
+			logging.RemoteAddr: r.RemoteAddr,
+		}).Warn(mverr.HeapDumpRateLimitedErrorMsg)
+		respond.RetryAfter(w, retryAfter)
+		respond.Text(w, http.StatusTooManyRequests, mverr.HeapDumpRateLimitedErrorMsg)
+		return
+	}
+
+	// A GC first so the profile reflects live objects rather than garbage awaiting collection
+	runtime.GC()
+	var profile bytes.Buffer
+	err := pprof.Lookup("heap").WriteTo(&profile, 0)
+	location := ""
+	if err == nil {
+		location, err = h.store.Put(r.Context(), heapDumpName(now), &profile)
+	}
+	if err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.HeapDumpErrorCode,
+			logging.HTTPStatus:  http.StatusInternalServerError,
+			logging.ErrorDetail: err.Error(),
+		}).Error(mverr.HeapDumpErrorMsg)
+		respond.Text(w, http.StatusInternalServerError, mverr.HeapDumpErrorMsg)
+		return
+	}
+
+	h.logger.WithFields(logging.Fields{
+		logging.Audit:      true,
+		logging.Client:     clientinfo.FromContext(r.Context()),
+		logging.Location:   location,
+		logging.RemoteAddr: r.RemoteAddr,
+	}).Info("heap dump created")
+
+	w.Header().Set("Location", location)
+	if err = respond.JSON(w, http.StatusCreated, heapDump{Location: location, Created: now}); err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.JSONMarshalingErrorCode,
+			logging.HTTPStatus:  http.StatusInternalServerError,
+			logging.ErrorDetail: err.Error(),
+		}).Error(mverr.JSONMarshalingErrorMsg)
+	}
+}
+
+// reserve returns the current time if a heap dump is allowed, recording it as the time of the
+// latest dump. Otherwise it returns how long until the next dump is allowed.
+func (h *heapDumpHandler) reserve() (time.Time, time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.clock.Now()
+	if !h.lastDump.IsZero() {
+		if wait := h.lastDump.Add(h.interval).Sub(now); wait > 0 {
+			return now, wait
+		}
+	}
+	h.lastDump = now
+	return now, 0
+}
+
+// SetClock replaces the Clock, clock.System by default, used to rate limit heap dumps. 'c' must be non-nil.
+func (h *heapDumpHandler) SetClock(c clock.Clock) error {
+	if c == nil {
+		return errors.New("non-nil Clock required")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clock = c
+	return nil
+}
+
+// heapDumpName returns the blob name of a heap dump created at 't'
+func heapDumpName(t time.Time) string {
+	return fmt.Sprintf("heap-%s.pprof", t.UTC().Format("20060102T150405.000Z"))
+}
+
+// NewHeapDumpHandler returns a properly configured *http.Handler for '/admin/debug/heapdump'. Heap
+// profiles are written to 'store'. At most one heap dump is created per 'interval'.
+func NewHeapDumpHandler(admins AdminAuthenticator, store blob.Store, interval time.Duration, logger logging.Logger) (http.Handler, error) {
+	if admins == nil {
+		return nil, errors.New("non-nil AdminAuthenticator required")
+	}
+	if store == nil {
+		return nil, errors.New("non-nil blob.Store required")
+	}
+	if interval <= 0 {
+		return nil, errors.New("interval must be greater than 0")
+	}
+	if logger == nil {
+		return nil, errors.New("non-nil Logger required")
+	}
+	return &heapDumpHandler{admins: admins, store: store, interval: interval, logger: logger, clock: clock.System}, nil
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/clock"
+)
+
+// fakeStore is a blob.Store that keeps the most recent blob in memory
+type fakeStore struct {
+	name    string
+	content []byte
+	err     error
+}
+
+func (fs *fakeStore) Put(ctx context.Context, name string, r io.Reader) (string, error) {
+	if fs.err != nil {
+		return "", fs.err
+	}
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	fs.name, fs.content = name, content
+	return "mem://" + name, nil
+}
+
+func (fs *fakeStore) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	if fs.err != nil {
+		return nil, fs.err
+	}
+	return ioutil.NopCloser(bytes.NewReader(fs.content)), nil
+}
+
+func TestPOSTHeapDump(t *testing.T) {
+	tcs := []struct {
+		testName             string
+		method               string
+		adminToken           string
+		storeErr             error
+		advance              time.Duration
+		expectedHTTPStatuses []int
+		expectedRetryAfter   string
+	}{
+		{
+			testName:             "testPOSTHeapDumpSuccess",
+			method:               http.MethodPost,
+			adminToken:           adminToken,
+			expectedHTTPStatuses: []int{http.StatusCreated},
+		},
+		{
+			testName:             "testPOSTHeapDumpRateLimited",
+			method:               http.MethodPost,
+			adminToken:           adminToken,
+			advance:              20 * time.Second,
+			expectedHTTPStatuses: []int{http.StatusCreated, http.StatusTooManyRequests},
+			expectedRetryAfter:   "40",
+		},
+		{
+			testName:             "testPOSTHeapDumpAfterInterval",
+			method:               http.MethodPost,
+			adminToken:           adminToken,
+			advance:              DefaultHeapDumpInterval,
+			expectedHTTPStatuses: []int{http.StatusCreated, http.StatusCreated},
+		},
+		{
+			testName:             "testPOSTHeapDumpBadAdminToken",
+			method:               http.MethodPost,
+			adminToken:           "guess",
+			expectedHTTPStatuses: []int{http.StatusUnauthorized},
+		},
+		{
+			testName:             "testPOSTHeapDumpStoreError",
+			method:               http.MethodPost,
+			adminToken:           adminToken,
+			storeErr:             errors.New("disk full"),
+			expectedHTTPStatuses: []int{http.StatusInternalServerError},
+		},
+		{
+			testName:             "testGETHeapDumpNotImplemented",
+			method:               http.MethodGet,
+			adminToken:           adminToken,
+			expectedHTTPStatuses: []int{http.StatusNotImplemented},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			store := &fakeStore{err: tc.storeErr}
+			h, err := NewHeapDumpHandler(newImpersonations(t), store, DefaultHeapDumpInterval, logger)
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting a heap dump handler", err)
+			}
+			clk := clock.NewFrozen(time.Date(2020, 7, 4, 9, 30, 0, 0, time.UTC))
+			if err = h.(*heapDumpHandler).SetClock(clk); err != nil {
+				t.Fatalf("error '%s' was not expected when setting the clock", err)
+			}
+
+			var rr *httptest.ResponseRecorder
+			for i, expectedStatus := range tc.expectedHTTPStatuses {
+				if i > 0 {
+					clk.Advance(tc.advance)
+				}
+				req := httptest.NewRequest(tc.method, heapDumpPath, nil)
+				req.Header.Set("Authorization", "Bearer "+tc.adminToken)
+				rr = httptest.NewRecorder()
+				h.ServeHTTP(rr, req)
+
+				if rr.Code != expectedStatus {
+					t.Fatalf("request %d: expected StatusCode = %d, got %d", i+1, expectedStatus, rr.Code)
+				}
+			}
+
+			if ra := rr.Header().Get("Retry-After"); ra != tc.expectedRetryAfter {
+				t.Errorf("expected Retry-After %q, got %q", tc.expectedRetryAfter, ra)
+			}
+			if rr.Code != http.StatusCreated {
+				return
+			}
+
+			dump := heapDump{}
+			if err := json.NewDecoder(rr.Body).Decode(&dump); err != nil {
+				t.Fatalf("an error '%s' was not expected decoding response body", err)
+			}
+			if dump.Location != "mem://"+store.name || rr.Header().Get("Location") != dump.Location {
+				t.Errorf("expected location mem://%s in the body and Location header, got %s and %s", store.name, dump.Location, rr.Header().Get("Location"))
+			}
+			if !dump.Created.Equal(clk.Now()) {
+				t.Errorf("expected created %s, got %s", clk.Now(), dump.Created)
+			}
+			if len(store.content) == 0 {
+				t.Errorf("expected a heap profile to be stored, got an empty blob")
+			}
+		})
+	}
+}
+
+func TestHeapDumpName(t *testing.T) {
+	name := heapDumpName(time.Date(2020, 7, 4, 9, 30, 0, 123e6, time.FixedZone("MDT", -6*60*60)))
+	if expected := "heap-20200704T153000.123Z.pprof"; name != expected {
+		t.Errorf("expected %s, got %s", expected, name)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/cmd/accountd/http/respond"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/clientinfo"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"github.com/youngkin/mockvideo/internal/metrics"
+)
+
+// impersonatePath is the path of requests for an impersonation token
+const impersonatePath = "/admin/impersonate"
+
+// AdminRqstDur is used to capture the length of HTTP requests
+var AdminRqstDur = metrics.NewHistogramVec(prometheus.HistogramOpts{
+	Subsystem: "admin",
+	Name:      "admin_request_duration_seconds",
+	Help:      "admin request duration distribution in seconds",
+	Buckets:   prometheus.LinearBuckets(0.001, .004, 50),
+}, respond.Labels)
+
+// impersonationRqst is the body of a request for an impersonation token
+type impersonationRqst struct {
+	Admin  string `json:"admin"`
+	UserID int    `json:"userid"`
+	Reason string `json:"reason"`
+}
+
+type impersonationHandler struct {
+	userSvc        services.UserSvcInterface
+	impersonations *auth.Impersonations
+	logger         logging.Logger
+}
+
+// ServeHTTP handles the request
+func (h impersonationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := respond.NewRecorder(w)
+	defer respond.Observe(r, AdminRqstDur, impersonatePath, rec, start)
+
+	h.logger.WithFields(logging.Fields{
+		logging.Method:     r.Method,
+		logging.Path:       r.URL.Path,
+		logging.RemoteAddr: r.RemoteAddr,
+	}).Info("HTTP request received")
+
+	if r.Method != http.MethodPost {
+		rec.WriteHeader(http.StatusNotImplemented)
+		rec.Write([]byte("Sorry, only POST /admin/impersonate is supported."))
+		return
+	}
+	h.handlePost(rec, r)
+}
+
+func (h impersonationHandler) handlePost(w http.ResponseWriter, r *http.Request) {
+	completeRequest := func(httpStatus int, msg string) {
+		w.WriteHeader(httpStatus)
+		w.Write([]byte(msg))
+	}
+
+	// The admin token is checked before anything else so unauthenticated callers learn nothing
+	// about the request, e.g., whether the target user exists
+	adminToken := bearerToken(r)
+	if !h.impersonations.IsAdminToken(adminToken) {
+		h.logger.WithFields(logging.Fields{
+			logging.Audit:      true,
+			logging.Client:     clientinfo.FromContext(r.Context()),
+			logging.ErrorCode:  mverr.InvalidAdminTokenErrorCode,
+			logging.HTTPStatus: http.StatusUnauthorized,
+			logging.RemoteAddr: r.RemoteAddr,
+		}).Warn(mverr.InvalidAdminTokenErrorMsg)
+		completeRequest(http.StatusUnauthorized, mverr.InvalidAdminTokenErrorMsg)
+		return
+	}
+
+	rqst := impersonationRqst{}
+	err := json.NewDecoder(r.Body).Decode(&rqst)
+	if err != nil {
+		decodingErr := respond.DecodingError(err)
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   decodingErr.ErrCode,
+			logging.HTTPStatus:  respond.HTTPStatus(decodingErr.ErrCode),
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: err.Error(),
+		}).Error(decodingErr.ErrMsg)
+		completeRequest(respond.HTTPStatus(decodingErr.ErrCode), decodingErr.ErrMsg)
+		return
+	}
+	if rqst.Admin == "" || rqst.Reason == "" || rqst.UserID < 1 {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.RqstParsingErrorCode,
+			logging.HTTPStatus:  http.StatusBadRequest,
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: "admin, userid, and reason are required",
+		}).Error(mverr.RqstParsingErrorMsg)
+		completeRequest(http.StatusBadRequest, mverr.RqstParsingErrorMsg)
+		return
+	}
+
+	// The target user is looked up on behalf of the service, not a caller, so it's always authorized
+	u, err2 := h.userSvc.GetUser(context.Background(), rqst.UserID)
+	if err2 != nil {
+		httpStatus := http.StatusInternalServerError
+		if err2.ErrCode == mverr.DBNoUserErrorCode {
+			httpStatus = http.StatusNotFound
+		}
+		completeRequest(httpStatus, err2.ErrMsg)
+		return
+	}
+	if u == nil {
+		completeRequest(http.StatusNotFound, mverr.DBNoUserErrorMsg)
+		return
+	}
+
+	grant, err := h.impersonations.Grant(adminToken, rqst.Admin, rqst.Reason, auth.Caller{
+		UserID:    u.ID,
+		AccountID: u.AccountID,
+		Role:      u.Role,
+	})
+	if err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.UnknownErrorCode,
+			logging.HTTPStatus:  http.StatusInternalServerError,
+			logging.ErrorDetail: err.Error(),
+		}).Error(mverr.UnknownErrorMsg)
+		completeRequest(http.StatusInternalServerError, mverr.UnknownErrorMsg)
+		return
+	}
+
+	h.logger.WithFields(logging.Fields{
+		logging.Audit:     true,
+		logging.Client:    clientinfo.FromContext(r.Context()),
+		logging.Admin:     grant.Admin,
+		logging.UserID:    grant.UserID,
+		logging.AccountID: u.AccountID,
+		logging.Reason:    grant.Reason,
+		logging.Expires:   grant.Expires,
+	}).Info("impersonation token granted")
+
+	if err = respond.JSON(w, http.StatusCreated, grant); err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.JSONMarshalingErrorCode,
+			logging.HTTPStatus:  http.StatusInternalServerError,
+			logging.ErrorDetail: err.Error(),
+		}).Error(mverr.JSONMarshalingErrorMsg)
+	}
+}
+
+// bearerToken returns the token from an 'Authorization: Bearer {token}' header, or an empty
+// string if the request doesn't have one
+func bearerToken(r *http.Request) string {
+	const prefix = "Bearer "
+	authz := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authz, prefix) {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(authz, prefix))
+}
+
+// NewImpersonationHandler returns a properly configured *http.Handler for '/admin/impersonate'
+func NewImpersonationHandler(userSvc services.UserSvcInterface, impersonations *auth.Impersonations, logger logging.Logger) (http.Handler, error) {
+	if userSvc == nil {
+		return nil, errors.New("non-nil services.UserSvcInterface required")
+	}
+	if impersonations == nil {
+		return nil, errors.New("non-nil *auth.Impersonations required")
+	}
+	if logger == nil {
+		return nil, errors.New("non-nil Logger required")
+	}
+	return impersonationHandler{userSvc: userSvc, impersonations: impersonations, logger: logger}, nil
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/db/memory"
+	"github.com/youngkin/mockvideo/internal/domain"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+const adminToken = "secret"
+
+// logger is used to control code-under-test logging behavior
+var logger logging.Logger
+
+func init() {
+	logger = logging.Default()
+	// Suppress all application logging
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+}
+
+func newUserSvc(t *testing.T) services.UserSvcInterface {
+	repo := memory.NewUserTable()
+	if _, err := repo.CreateUser(domain.User{AccountID: 2, Name: "mama cass", EMail: "mama@gmail.com", Role: domain.Primary, Password: "pw"}); err != nil {
+		t.Fatalf("error %s was not expected creating a user", err)
+	}
+	userSvc, err := services.NewUserSvc(repo, logger, 10, 10, 10)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting UserSvc", err)
+	}
+	return userSvc
+}
+
+func newImpersonations(t *testing.T) *auth.Impersonations {
+	im, err := auth.NewImpersonations(adminToken, auth.DefaultImpersonationTTL)
+	if err != nil {
+		t.Fatalf("error %s was not expected creating Impersonations", err)
+	}
+	return im
+}
+
+func TestPOSTImpersonate(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		method             string
+		adminToken         string
+		body               string
+		expectedHTTPStatus int
+	}{
+		{
+			testName:           "testPOSTImpersonateSuccess",
+			method:             http.MethodPost,
+			adminToken:         adminToken,
+			body:               `{"admin":"jsmith","userid":1,"reason":"ticket 1234"}`,
+			expectedHTTPStatus: http.StatusCreated,
+		},
+		{
+			testName:           "testPOSTImpersonateBadAdminToken",
+			method:             http.MethodPost,
+			adminToken:         "guess",
+			body:               `{"admin":"jsmith","userid":1,"reason":"ticket 1234"}`,
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testPOSTImpersonateNoAdminToken",
+			method:             http.MethodPost,
+			body:               `{"admin":"jsmith","userid":1,"reason":"ticket 1234"}`,
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testPOSTImpersonateNoReason",
+			method:             http.MethodPost,
+			adminToken:         adminToken,
+			body:               `{"admin":"jsmith","userid":1}`,
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+		{
+			testName:           "testPOSTImpersonateMalformedJSON",
+			method:             http.MethodPost,
+			adminToken:         adminToken,
+			body:               `{"admin":"jsmith",`,
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+		{
+			testName:           "testPOSTImpersonateNoUser",
+			method:             http.MethodPost,
+			adminToken:         adminToken,
+			body:               `{"admin":"jsmith","userid":9,"reason":"ticket 1234"}`,
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:           "testGETImpersonateNotImplemented",
+			method:             http.MethodGet,
+			adminToken:         adminToken,
+			expectedHTTPStatus: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			im := newImpersonations(t)
+			h, err := NewImpersonationHandler(newUserSvc(t), im, logger)
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting an impersonation handler", err)
+			}
+
+			req := httptest.NewRequest(tc.method, "/admin/impersonate", bytes.NewBufferString(tc.body))
+			if tc.adminToken != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.adminToken)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Fatalf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, rr.Code)
+			}
+			if rr.Code != http.StatusCreated {
+				return
+			}
+
+			grant := auth.Grant{}
+			if err := json.NewDecoder(rr.Body).Decode(&grant); err != nil {
+				t.Fatalf("an error '%s' was not expected decoding response body", err)
+			}
+			caller, ok := im.Caller(grant.Token)
+			expected := auth.Caller{UserID: 1, AccountID: 2, Role: domain.Primary, Impersonator: "jsmith"}
+			if !ok || !reflect.DeepEqual(caller, expected) {
+				t.Errorf("expected the granted token to identify caller %+v, got %+v, %t", expected, caller, ok)
+			}
+		})
+	}
+}
+
+func TestImpersonationMiddleware(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		token              func(im *auth.Impersonations) string
+		expectedHTTPStatus int
+		expectedCaller     *auth.Caller
+		expectAudit        bool
+	}{
+		{
+			testName: "testImpersonationMiddlewareImpersonated",
+			token: func(im *auth.Impersonations) string {
+				g, _ := im.Grant(adminToken, "jsmith", "ticket 1234", auth.Caller{UserID: 1, AccountID: 2, Role: domain.Primary})
+				return g.Token
+			},
+			expectedHTTPStatus: http.StatusOK,
+			expectedCaller:     &auth.Caller{UserID: 1, AccountID: 2, Role: domain.Primary, Impersonator: "jsmith"},
+			expectAudit:        true,
+		},
+		{
+			testName:           "testImpersonationMiddlewareNoToken",
+			token:              func(im *auth.Impersonations) string { return "" },
+			expectedHTTPStatus: http.StatusUnauthorized,
+			expectAudit:        true,
+		},
+		{
+			testName:           "testImpersonationMiddlewareInvalidToken",
+			token:              func(im *auth.Impersonations) string { return "notatoken" },
+			expectedHTTPStatus: http.StatusUnauthorized,
+			expectAudit:        true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			im := newImpersonations(t)
+			testLogger, hook := test.NewNullLogger()
+
+			var caller *auth.Caller
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if c, ok := auth.FromContext(r.Context()); ok {
+					caller = &c
+				}
+			})
+			h := ImpersonationMiddleware(im, logging.NewLogrusLogger(log.NewEntry(testLogger)), next)
+
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			if token := tc.token(im); token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Errorf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, rr.Code)
+			}
+			if (caller == nil) != (tc.expectedCaller == nil) || (caller != nil && !reflect.DeepEqual(*caller, *tc.expectedCaller)) {
+				t.Errorf("expected caller %+v, got %+v", tc.expectedCaller, caller)
+			}
+
+			audited := false
+			for _, e := range hook.AllEntries() {
+				if e.Data[logging.Audit] == true {
+					audited = true
+					if tc.expectedCaller != nil && e.Data[logging.Impersonator] != tc.expectedCaller.Impersonator {
+						t.Errorf("expected audit entry for impersonator %s, got %+v", tc.expectedCaller.Impersonator, e.Data)
+					}
+				}
+			}
+			if audited != tc.expectAudit {
+				t.Errorf("expected audit log entry %t, got %t", tc.expectAudit, audited)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/clientinfo"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// statusRecorder captures the HTTP status written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// Flush sends any buffered data to the client if the underlying http.ResponseWriter supports it
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ImpersonationMiddleware authenticates requests made with an impersonation token, i.e., those
+// with an 'Authorization: Bearer {token}' header. The impersonated user is added to the request's
+// context as its auth.Caller, so the request is authorized as that user. Every impersonated
+// request is recorded in an audit log entry identifying the administrator. Requests with an
+// unknown or expired token, or without a token, are rejected with a 401 HTTP status. Requests whose
+// caller has already been identified, e.g., by APIKeyMiddleware, are passed to 'next' unchanged.
+func ImpersonationMiddleware(impersonations *auth.Impersonations, logger logging.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, identified := auth.FromContext(r.Context()); identified {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token := bearerToken(r)
+		if token == "" {
+			rejectMissingToken(w, r, logger)
+			return
+		}
+
+		caller, ok := impersonations.Caller(token)
+		if !ok {
+			logger.WithFields(logging.Fields{
+				logging.Audit:      true,
+				logging.Client:     clientinfo.FromContext(r.Context()),
+				logging.ErrorCode:  mverr.InvalidImpersonationErrorCode,
+				logging.HTTPStatus: http.StatusUnauthorized,
+				logging.Method:     r.Method,
+				logging.Path:       r.URL.Path,
+				logging.RemoteAddr: r.RemoteAddr,
+			}).Warn(mverr.InvalidImpersonationErrorMsg)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(mverr.InvalidImpersonationErrorMsg))
+			return
+		}
+
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sr, r.WithContext(auth.NewContext(r.Context(), caller)))
+
+		logger.WithFields(logging.Fields{
+			logging.Audit:        true,
+			logging.Client:       clientinfo.FromContext(r.Context()),
+			logging.Impersonator: caller.Impersonator,
+			logging.UserID:       caller.UserID,
+			logging.AccountID:    caller.AccountID,
+			logging.Method:       r.Method,
+			logging.Path:         r.URL.Path,
+			logging.HTTPStatus:   sr.status,
+		}).Info("impersonated request")
+	})
+}
+
+// APIKeyMiddleware authenticates requests made with an API key, i.e., those with an 'Authorization:
+// Bearer {key}' header. The key's caller, and its scopes, are added to the request's context as its
+// auth.Caller. Requests with any other token are passed to 'next' unchanged so they can be authenticated
+// by ImpersonationMiddleware. If 'impersonation' is false, i.e., there's no other kind of token, requests
+// with an unknown key are rejected with a 401 HTTP status. Requests without a token are always rejected
+// with a 401 HTTP status. Requests whose caller has already been identified, e.g., by JWTMiddleware, are
+// passed to 'next' unchanged.
+func APIKeyMiddleware(keys *auth.APIKeys, impersonation bool, logger logging.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, identified := auth.FromContext(r.Context()); identified {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token := bearerToken(r)
+		if token == "" {
+			rejectMissingToken(w, r, logger)
+			return
+		}
+
+		caller, ok := keys.Caller(token)
+		if !ok {
+			if impersonation {
+				next.ServeHTTP(w, r)
+				return
+			}
+			logger.WithFields(logging.Fields{
+				logging.Audit:      true,
+				logging.Client:     clientinfo.FromContext(r.Context()),
+				logging.ErrorCode:  mverr.InvalidAPIKeyErrorCode,
+				logging.HTTPStatus: http.StatusUnauthorized,
+				logging.Method:     r.Method,
+				logging.Path:       r.URL.Path,
+				logging.RemoteAddr: r.RemoteAddr,
+			}).Warn(mverr.InvalidAPIKeyErrorMsg)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(mverr.InvalidAPIKeyErrorMsg))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), caller)))
+	})
+}
+
+// rejectMissingToken rejects 'r', a request that must be authenticated but has no bearer token, with a
+// 401 HTTP status
+func rejectMissingToken(w http.ResponseWriter, r *http.Request, logger logging.Logger) {
+	logger.WithFields(logging.Fields{
+		logging.Audit:      true,
+		logging.Client:     clientinfo.FromContext(r.Context()),
+		logging.ErrorCode:  mverr.MissingTokenErrorCode,
+		logging.HTTPStatus: http.StatusUnauthorized,
+		logging.Method:     r.Method,
+		logging.Path:       r.URL.Path,
+		logging.RemoteAddr: r.RemoteAddr,
+	}).Warn(mverr.MissingTokenErrorMsg)
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write([]byte(mverr.MissingTokenErrorMsg))
+}
+
+// JWTMiddleware requires requests to be made with a JWT signed by one of 'keys', i.e., with an
+// 'Authorization: Bearer {jwt}' header. The JWT's caller is added to the request's context as its
+// auth.Caller. If 'others' is true, i.e., there are other kinds of token, requests with a token that
+// isn't a JWT are passed to 'next' unchanged so they can be authenticated by APIKeyMiddleware or
+// ImpersonationMiddleware. Other requests, including those without a token, are rejected with a 401
+// HTTP status.
+func JWTMiddleware(keys *auth.JWTKeys, others bool, logger logging.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token != "" && !auth.IsJWT(token) && others {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var caller auth.Caller
+		err := errors.New("no bearer token")
+		if auth.IsJWT(token) {
+			caller, err = keys.Caller(token)
+		}
+		if err != nil {
+			logger.WithFields(logging.Fields{
+				logging.Audit:       true,
+				logging.Client:      clientinfo.FromContext(r.Context()),
+				logging.ErrorCode:   mverr.InvalidJWTErrorCode,
+				logging.ErrorDetail: err.Error(),
+				logging.HTTPStatus:  http.StatusUnauthorized,
+				logging.Method:      r.Method,
+				logging.Path:        r.URL.Path,
+				logging.RemoteAddr:  r.RemoteAddr,
+			}).Warn(mverr.InvalidJWTErrorMsg)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(mverr.InvalidJWTErrorMsg))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), caller)))
+	})
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+func TestJWTMiddleware(t *testing.T) {
+	keys, err := auth.ParseJWTKeys(strings.NewReader("2020-10 fedcba9876543210fedcba9876543210"))
+	if err != nil {
+		t.Fatalf("error %s was not expected parsing the JWT keys", err)
+	}
+	sign := func(expires time.Time) string {
+		token, err := keys.Sign("2020-10", auth.JWTClaims{Subject: "1", AccountID: 2, Role: "primary", ExpiresAt: expires.Unix()})
+		if err != nil {
+			t.Fatalf("error %s was not expected signing a JWT", err)
+		}
+		return token
+	}
+
+	tcs := []struct {
+		testName           string
+		token              string
+		others             bool
+		expectedHTTPStatus int
+		expectedCaller     *auth.Caller
+	}{
+		{
+			testName:           "testJWTMiddlewareValid",
+			token:              sign(time.Now().Add(time.Hour)),
+			expectedHTTPStatus: http.StatusOK,
+			expectedCaller:     &auth.Caller{UserID: 1, AccountID: 2, Role: domain.Primary},
+		},
+		{
+			testName:           "testJWTMiddlewareExpired",
+			token:              sign(time.Now().Add(-time.Minute)),
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testJWTMiddlewareNoToken",
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testJWTMiddlewareNoTokenWithOthers",
+			others:             true,
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testJWTMiddlewareOtherToken",
+			token:              "0123456789abcdef0123456789abcdef",
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testJWTMiddlewareOtherTokenWithOthers",
+			token:              "0123456789abcdef0123456789abcdef",
+			others:             true,
+			expectedHTTPStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			testLogger, hook := test.NewNullLogger()
+
+			var caller *auth.Caller
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if c, ok := auth.FromContext(r.Context()); ok {
+					caller = &c
+				}
+			})
+			h := JWTMiddleware(keys, tc.others, logging.NewLogrusLogger(log.NewEntry(testLogger)), next)
+
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Errorf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, rr.Code)
+			}
+			if (caller == nil) != (tc.expectedCaller == nil) || (caller != nil && !reflect.DeepEqual(*caller, *tc.expectedCaller)) {
+				t.Errorf("expected caller %+v, got %+v", tc.expectedCaller, caller)
+			}
+
+			rejected := false
+			for _, e := range hook.AllEntries() {
+				if e.Data[logging.ErrorCode] == mverr.InvalidJWTErrorCode && e.Data[logging.Audit] == true {
+					rejected = true
+				}
+			}
+			if rejected != (tc.expectedHTTPStatus == http.StatusUnauthorized) {
+				t.Errorf("expected a rejection log entry %t, got %t", tc.expectedHTTPStatus == http.StatusUnauthorized, rejected)
+			}
+		})
+	}
+}
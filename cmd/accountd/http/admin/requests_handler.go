@@ -0,0 +1,121 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/youngkin/mockvideo/cmd/accountd/http/respond"
+	"github.com/youngkin/mockvideo/internal/clientinfo"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/inflight"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// requestsPath is the path of in-flight request requests, it's also the route label of their metrics
+const requestsPath = "/admin/requests"
+
+// cancelPath is the final segment of the path of requests to cancel an in-flight request
+const cancelPath = "cancel"
+
+// inFlightRequests is the response body of 'GET /admin/requests'
+type inFlightRequests struct {
+	Requests []inflight.Request `json:"requests"`
+}
+
+type requestsHandler struct {
+	admins  AdminAuthenticator
+	tracker *inflight.Tracker
+	logger  logging.Logger
+}
+
+// ServeHTTP handles the request
+func (h requestsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := respond.NewRecorder(w)
+	defer respond.Observe(r, AdminRqstDur, requestsPath, rec, start)
+
+	h.logger.WithFields(logging.Fields{
+		logging.Method:     r.Method,
+		logging.Path:       r.URL.Path,
+		logging.RemoteAddr: r.RemoteAddr,
+	}).Info("HTTP request received")
+
+	if !h.admins.IsAdminToken(bearerToken(r)) {
+		h.logger.WithFields(logging.Fields{
+			logging.Audit:      true,
+			logging.Client:     clientinfo.FromContext(r.Context()),
+			logging.ErrorCode:  mverr.InvalidAdminTokenErrorCode,
+			logging.HTTPStatus: http.StatusUnauthorized,
+			logging.Path:       r.URL.Path,
+			logging.RemoteAddr: r.RemoteAddr,
+		}).Warn(mverr.InvalidAdminTokenErrorMsg)
+		respond.Text(rec, http.StatusUnauthorized, mverr.InvalidAdminTokenErrorMsg)
+		return
+	}
+
+	// Valid paths are '/admin/requests' and '/admin/requests/{id}/cancel'
+	pathNodes := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, requestsPath), "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && pathNodes[0] == "":
+		h.handleGetAll(rec, r)
+	case r.Method == http.MethodPost && len(pathNodes) == 2 && pathNodes[1] == cancelPath:
+		h.handleCancel(rec, r, pathNodes[0])
+	default:
+		respond.Text(rec, http.StatusNotImplemented, "Sorry, only GET /admin/requests and POST /admin/requests/{id}/cancel are supported.")
+	}
+}
+
+func (h requestsHandler) handleGetAll(w http.ResponseWriter, r *http.Request) {
+	// The list includes this request, it's in-flight too
+	if err := respond.JSON(w, http.StatusOK, inFlightRequests{Requests: h.tracker.Requests()}); err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.JSONMarshalingErrorCode,
+			logging.HTTPStatus:  http.StatusInternalServerError,
+			logging.ErrorDetail: err.Error(),
+		}).Error(mverr.JSONMarshalingErrorMsg)
+	}
+}
+
+func (h requestsHandler) handleCancel(w http.ResponseWriter, r *http.Request, id string) {
+	if !h.tracker.Cancel(id) {
+		err := &mverr.MVError{ErrCode: mverr.RqstNotFoundErrorCode, ErrMsg: mverr.RqstNotFoundErrorMsg}
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:  err.ErrCode,
+			logging.HTTPStatus: respond.HTTPStatus(err.ErrCode),
+			logging.Path:       r.URL.Path,
+			logging.RqstID:     id,
+		}).Error(err.ErrMsg)
+		respond.Error(w, err)
+		return
+	}
+
+	h.logger.WithFields(logging.Fields{
+		logging.Audit:      true,
+		logging.Client:     clientinfo.FromContext(r.Context()),
+		logging.RqstID:     id,
+		logging.RemoteAddr: r.RemoteAddr,
+	}).Info("in-flight request cancelled")
+
+	// The request completes once its handler notices the cancellation
+	respond.Status(w, http.StatusAccepted)
+}
+
+// NewRequestsHandler returns a properly configured *http.Handler for '/admin/requests'
+func NewRequestsHandler(admins AdminAuthenticator, tracker *inflight.Tracker, logger logging.Logger) (http.Handler, error) {
+	if admins == nil {
+		return nil, errors.New("non-nil AdminAuthenticator required")
+	}
+	if tracker == nil {
+		return nil, errors.New("non-nil *inflight.Tracker required")
+	}
+	if logger == nil {
+		return nil, errors.New("non-nil Logger required")
+	}
+	return requestsHandler{admins: admins, tracker: tracker, logger: logger}, nil
+}
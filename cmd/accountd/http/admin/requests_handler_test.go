@@ -0,0 +1,100 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/clock"
+	"github.com/youngkin/mockvideo/internal/inflight"
+)
+
+func TestRequestsHandler(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		method             string
+		url                string
+		adminToken         string
+		expectedHTTPStatus int
+		expectedRequests   []inflight.Request
+	}{
+		{
+			testName:           "testGETRequests",
+			method:             http.MethodGet,
+			url:                "/admin/requests",
+			adminToken:         adminToken,
+			expectedHTTPStatus: http.StatusOK,
+			// The request lists itself
+			expectedRequests: []inflight.Request{
+				{ID: "1", Method: http.MethodGet, Path: "/admin/requests", Started: time.Date(2020, 7, 4, 9, 30, 0, 0, time.UTC), Duration: "0s"},
+			},
+		},
+		{
+			// The request cancels itself
+			testName:           "testPOSTCancel",
+			method:             http.MethodPost,
+			url:                "/admin/requests/1/cancel",
+			adminToken:         adminToken,
+			expectedHTTPStatus: http.StatusAccepted,
+		},
+		{
+			testName:           "testPOSTCancelNotFound",
+			method:             http.MethodPost,
+			url:                "/admin/requests/2/cancel",
+			adminToken:         adminToken,
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:           "testGETRequestsBadAdminToken",
+			method:             http.MethodGet,
+			url:                "/admin/requests",
+			adminToken:         "guess",
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testDELETERequestNotImplemented",
+			method:             http.MethodDelete,
+			url:                "/admin/requests/1",
+			adminToken:         adminToken,
+			expectedHTTPStatus: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			tracker := inflight.NewTracker()
+			if err := tracker.SetClock(clock.NewFrozen(time.Date(2020, 7, 4, 9, 30, 0, 0, time.UTC))); err != nil {
+				t.Fatalf("error '%s' was not expected when setting the clock", err)
+			}
+			h, err := NewRequestsHandler(newImpersonations(t), tracker, logger)
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting a requests handler", err)
+			}
+
+			req := httptest.NewRequest(tc.method, tc.url, nil)
+			req.Header.Set("Authorization", "Bearer "+tc.adminToken)
+			rr := httptest.NewRecorder()
+			inflight.Middleware(tracker)(h).ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Fatalf("expected StatusCode = %d, got %d: %s", tc.expectedHTTPStatus, rr.Code, rr.Body.String())
+			}
+			if tc.expectedRequests == nil {
+				return
+			}
+			actual := inFlightRequests{}
+			if err = json.Unmarshal(rr.Body.Bytes(), &actual); err != nil {
+				t.Fatalf("error '%s' was not expected unmarshaling the response", err)
+			}
+			if string(mustMarshal(t, actual.Requests)) != string(mustMarshal(t, tc.expectedRequests)) {
+				t.Errorf("expected requests %+v, got %s", tc.expectedRequests, rr.Body.String())
+			}
+		})
+	}
+}
@@ -6,5 +6,8 @@
 Package handlers contains the implementations of the HTTP handlers for the 'accountd' service. There
 are several resources that are part of an account. These include the users that are part of an account,
 and the account that has one or more associated users.
+
+Handlers write their responses using the 'respond' package so content types, error bodies, and request
+duration metrics are consistent across endpoints.
 */
 package handlers
@@ -0,0 +1,53 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/cmd/accountd/http/respond"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"github.com/youngkin/mockvideo/internal/metrics"
+)
+
+// UnknownResourceRqsts counts the requests whose URL doesn't identify any resource. Most of them
+// are probes by scanners, so they're counted rather than logged as errors.
+var UnknownResourceRqsts = metrics.NewCounter(prometheus.CounterOpts{
+	Subsystem: "http",
+	Name:      "unknown_resource_requests_total",
+	Help:      "number of requests whose URL doesn't identify any resource",
+})
+
+// NotFound is the body of a response to a request whose URL doesn't identify any resource
+type NotFound struct {
+	ErrMsg string `json:"errmsg"`
+	// Resources are the top-level resources that are available
+	Resources []string `json:"resources"`
+}
+
+// NewNotFoundHandler returns the handler for requests that don't match any other route. It responds
+// with a 404 (Not Found) and a JSON NotFound body listing 'resources'. The requested path isn't
+// included in the body, and browsers are told not to sniff its content type, so a crafted URL can't
+// be reflected into a page. Requests are logged at debug level and counted in UnknownResourceRqsts.
+func NewNotFoundHandler(resources []string, logger logging.Logger) (http.Handler, error) {
+	if logger == nil {
+		return nil, errors.New("non-nil Logger required")
+	}
+	body := NotFound{ErrMsg: mverr.UnknownResourceErrorMsg, Resources: append([]string{}, resources...)}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		UnknownResourceRqsts.Inc()
+		logging.Log(logger, logging.DebugLevel, mverr.UnknownResourceErrorMsg, func(f logging.Fields) {
+			f[logging.ErrorCode] = mverr.UnknownResourceErrorCode
+			f[logging.Path] = r.URL.Path
+		})
+
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		respond.JSON(w, http.StatusNotFound, body)
+	}), nil
+}
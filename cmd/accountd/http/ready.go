@@ -0,0 +1,53 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/youngkin/mockvideo/cmd/accountd/http/respond"
+)
+
+// Modes reported by '/readyz'
+const (
+	ReadWriteMode = "read-write"
+	ReadOnlyMode  = "read-only"
+)
+
+// ReadOnlyReporter reports whether writes are being rejected, e.g., by a db.ReadOnlyRepository
+type ReadOnlyReporter interface {
+	ReadOnly() bool
+}
+
+// DrainReporter reports whether accountd is draining, i.e., shutting down, e.g., a lifecycle.Drain
+type DrainReporter interface {
+	Draining() bool
+}
+
+// Readiness is the body of a '/readyz' response
+type Readiness struct {
+	Status string `json:"status"`
+	Mode   string `json:"mode"`
+}
+
+// NewReadyHandler returns the handler for '/readyz'. accountd is ready while in read-only mode
+// since it still serves reads, so the response is a 200 (OK) unless accountd is draining. Then it's
+// a 503 (Service Unavailable) so load balancers stop routing new requests to it. Its body reports
+// the mode. 'readOnly' may be nil, in which case the mode is always ReadWriteMode, and 'drain' may
+// be nil, in which case accountd is never reported as draining.
+func NewReadyHandler(readOnly ReadOnlyReporter, drain DrainReporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		readiness := Readiness{Status: "ready", Mode: ReadWriteMode}
+		if readOnly != nil && readOnly.ReadOnly() {
+			readiness.Mode = ReadOnlyMode
+		}
+		if drain != nil && drain.Draining() {
+			readiness.Status = "draining"
+			respond.JSON(w, http.StatusServiceUnavailable, readiness)
+			return
+		}
+		respond.JSON(w, http.StatusOK, readiness)
+	})
+}
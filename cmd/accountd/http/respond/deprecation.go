@@ -0,0 +1,91 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package respond
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/internal/clientinfo"
+	"github.com/youngkin/mockvideo/internal/metrics"
+)
+
+// Deprecated features, i.e., behaviors kept for compatibility that will be removed once clients no
+// longer depend on them. They're the FeatureLabel values of DeprecatedRqsts.
+const (
+	// UnpagedUsers is a 'GET /users' without the 'limit' query parameter, i.e., one that returns
+	// every user rather than a page of them
+	UnpagedUsers = "unpagedusers"
+	// LegacyJSONCasing is a request body with JSON keys that aren't lowercase, e.g., 'AccountID'
+	// rather than 'accountid'. They're accepted because keys are matched case insensitively.
+	LegacyJSONCasing = "legacyjsoncasing"
+)
+
+// deprecationWarnings are the texts of the "Warning" headers of responses to requests using each
+// deprecated feature
+var deprecationWarnings = map[string]string{
+	UnpagedUsers:     "GET /users without 'limit' is deprecated, page through the users using 'limit' and 'pagetoken'",
+	LegacyJSONCasing: "JSON keys that aren't lowercase are deprecated, e.g., use 'accountid' rather than 'AccountID'",
+}
+
+// FeatureLabel is the label of DeprecatedRqsts identifying the deprecated feature used
+const FeatureLabel = "feature"
+
+// DeprecatedRqsts counts the requests using each deprecated feature by client, see package clientinfo.
+// A feature can be removed once its count stops increasing. It's incremented by Deprecated.
+var DeprecatedRqsts = metrics.NewCounterVec(prometheus.CounterOpts{
+	Subsystem: "http",
+	Name:      "deprecated_requests_total",
+	Help:      "number of requests using deprecated features by feature and client",
+}, []string{FeatureLabel, ClientLabel})
+
+// Deprecated records that 'r' uses the deprecated 'feature', one of the deprecated feature constants
+// (e.g., UnpagedUsers), in DeprecatedRqsts. The response gets a "Deprecation" header and a
+// "Warning" header describing the feature, so it must be called before the response's status is
+// written.
+func Deprecated(w http.ResponseWriter, r *http.Request, feature string) {
+	w.Header().Set("Deprecation", "true")
+	w.Header().Add("Warning", fmt.Sprintf("299 - %q", deprecationWarnings[feature]))
+	DeprecatedRqsts.WithLabelValues(feature, clientinfo.FromContext(r.Context())).Inc()
+}
+
+// HasLegacyCasing returns true if the JSON 'data' contains an object key that isn't lowercase, i.e., it
+// uses the LegacyJSONCasing feature. Invalid JSON is only checked up to the first error.
+func HasLegacyCasing(data []byte) bool {
+	d := json.NewDecoder(bytes.NewReader(data))
+	// inObject records, for each enclosing object or array, whether it's an object. keyNext is true
+	// when the next token is a key of the innermost object.
+	var inObject []bool
+	keyNext := false
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return false
+		}
+		switch t := tok.(type) {
+		case json.Delim:
+			if t == '{' || t == '[' {
+				inObject = append(inObject, t == '{')
+				keyNext = t == '{'
+				continue
+			}
+			inObject = inObject[:len(inObject)-1]
+		case string:
+			if keyNext {
+				if t != strings.ToLower(t) {
+					return true
+				}
+				keyNext = false
+				continue
+			}
+		}
+		// A complete value, the next token of an enclosing object is a key
+		keyNext = len(inObject) > 0 && inObject[len(inObject)-1]
+	}
+}
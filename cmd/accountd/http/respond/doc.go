@@ -0,0 +1,18 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package respond writes HTTP responses for accountd's handlers. It centralizes the details every
+handler branch would otherwise repeat:
+
+1.	Header ordering. Headers, e.g., 'Content-Type', are set before the status is written, headers
+	set after 'WriteHeader' are silently dropped.
+2.	Content type. JSON responses are 'application/json', all others are 'text/plain'.
+3.	Error formatting. An error response's body is the MVError's ErrMsg, and its status is derived
+	from the MVError's ErrCode (see HTTPStatus).
+4.	Metric observation. A Recorder captures the status actually written so a handler can observe
+	its request duration once, regardless of which branch completed the request (see Observe).
+	Durations are labeled with the request's method, route template, and status.
+*/
+package respond
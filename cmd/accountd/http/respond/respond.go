@@ -0,0 +1,237 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package respond
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/internal/bodytimeout"
+	"github.com/youngkin/mockvideo/internal/clientinfo"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/httpclient"
+	"github.com/youngkin/mockvideo/internal/jsonlimit"
+	"github.com/youngkin/mockvideo/internal/metrics"
+)
+
+// Request duration metrics are labeled with the request's method, the template of the route that
+// matched the request (e.g., '/users/{id}'), and the response's HTTP status. Labeling with the
+// route template rather than the path keeps the metrics' cardinality independent of resource IDs.
+const (
+	MethodLabel = "rqstMethod"
+	RouteLabel  = "rqstRoute"
+	StatusLabel = "rqstStatus"
+)
+
+// Labels are the labels, in order, of a request duration metric observed using Observe
+var Labels = []string{MethodLabel, RouteLabel, StatusLabel}
+
+// ClientLabel is the label of ClientRqsts identifying the client that made the request, see package
+// clientinfo
+const ClientLabel = "client"
+
+// ClientRqsts counts requests by method, route, and client so the clients, and client versions, still
+// using deprecated routes can be identified. It's incremented by Observe.
+var ClientRqsts = metrics.NewCounterVec(prometheus.CounterOpts{
+	Subsystem: "http",
+	Name:      "client_requests_total",
+	Help:      "number of requests by method, route, and client",
+}, []string{MethodLabel, RouteLabel, ClientLabel})
+
+// clientCounterKey identifies one of ClientRqsts' counters
+type clientCounterKey struct {
+	method, route, client string
+}
+
+// clientCounters caches ClientRqsts' counters. Unlike ClientRqsts.WithLabelValues, looking up a
+// cached counter doesn't allocate. Its size is bounded by ClientRqsts' cardinality.
+var clientCounters = struct {
+	sync.RWMutex
+	m map[clientCounterKey]metrics.Counter
+}{m: make(map[clientCounterKey]metrics.Counter)}
+
+// clientCounter returns the ClientRqsts counter for 'method', 'route', and 'client'
+func clientCounter(method, route, client string) metrics.Counter {
+	key := clientCounterKey{method: method, route: route, client: client}
+	clientCounters.RLock()
+	c, ok := clientCounters.m[key]
+	clientCounters.RUnlock()
+	if ok {
+		return c
+	}
+
+	clientCounters.Lock()
+	defer clientCounters.Unlock()
+	if c, ok = clientCounters.m[key]; !ok {
+		c = ClientRqsts.WithLabelValues(method, route, client)
+		clientCounters.m[key] = c
+	}
+	return c
+}
+
+// JSONMarshalingFailures counts the response bodies that couldn't be marshaled by JSON. These are
+// server bugs, e.g., a NaN float, so any failure is worth investigating.
+var JSONMarshalingFailures = metrics.NewCounter(prometheus.CounterOpts{
+	Subsystem: "http",
+	Name:      "json_marshaling_failures_total",
+	Help:      "number of response bodies that couldn't be marshaled to JSON",
+})
+
+// UnmatchedRoute is the RouteLabel value for a request whose path doesn't match any route
+const UnmatchedRoute = "unmatched"
+
+// otherMethod is the MethodLabel value for methods other than the standard HTTP methods
+const otherMethod = "OTHER"
+
+// Recorder is an http.ResponseWriter that records the HTTP status of the response
+type Recorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// NewRecorder returns a Recorder that writes to 'w'
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w}
+}
+
+// WriteHeader records 'status' and writes it to the underlying http.ResponseWriter
+func (rec *Recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes 'b' to the underlying http.ResponseWriter. As with an http.ResponseWriter, a
+// 200 (OK) status is recorded if WriteHeader hasn't been called.
+func (rec *Recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Flush sends any buffered data to the client, e.g., for a streamed response, if the underlying
+// http.ResponseWriter supports it
+func (rec *Recorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Status returns the HTTP status of the response. It's 200 (OK) if nothing has been written.
+func (rec *Recorder) Status() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
+
+// Observe records the duration of 'r', which started at 'start', in 'hist'. 'hist' must have been
+// created with Labels. 'route' is the template of the route that matched 'r' and the status is
+// the one recorded by 'rec'. The request is also counted in ClientRqsts.
+func Observe(r *http.Request, hist *metrics.HistogramVec, route string, rec *Recorder, start time.Time) {
+	method := methodLabel(r.Method)
+	obs := hist.WithLabelValues(method, route, strconv.Itoa(rec.Status()))
+	httpclient.ObserveSince(r.Context(), obs, start)
+	clientCounter(method, route, clientinfo.FromContext(r.Context())).Inc()
+}
+
+// methodLabel returns the MethodLabel value for 'method'. Nonstandard methods share a single value
+// so a client can't create arbitrarily many label values.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodConnect, http.MethodDelete, http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodPatch, http.MethodPost, http.MethodPut, http.MethodTrace:
+		return method
+	default:
+		return otherMethod
+	}
+}
+
+// Status writes a response with 'status' and no body
+func Status(w http.ResponseWriter, status int) {
+	w.WriteHeader(status)
+}
+
+// Text writes a plain text response with 'status' and body 'msg'
+func Text(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(msg))
+}
+
+// JSON writes a JSON response with 'status' and 'body' as its content. If 'body' can't be
+// marshaled a 500 (Internal Server Error) response is written instead, JSONMarshalingFailures is
+// incremented, and the marshaling error is returned so the caller can log it.
+func JSON(w http.ResponseWriter, status int, body interface{}) error {
+	marshBody, err := json.Marshal(body)
+	if err != nil {
+		JSONMarshalingFailures.Inc()
+		Text(w, http.StatusInternalServerError, mverr.JSONMarshalingErrorMsg)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(marshBody)
+	return nil
+}
+
+// Error writes an error response for 'err'. The response's status is HTTPStatus(err.ErrCode). If
+// 'err.RetryAfter' is set the response includes a "Retry-After" header, see RetryAfter.
+func Error(w http.ResponseWriter, err *mverr.MVError) {
+	if err.RetryAfter > 0 {
+		RetryAfter(w, err.RetryAfter)
+	}
+	Text(w, HTTPStatus(err.ErrCode), err.ErrMsg)
+}
+
+// DecodingError returns the error for a request body that couldn't be decoded because of 'err'. It's a
+// RqstBodyTimeoutErrorCode error if the body wasn't received in time, see package bodytimeout, a
+// JSONTooComplexErrorCode error if the body exceeded its complexity limits, see package jsonlimit,
+// otherwise it's a JSONDecodingErrorCode error.
+func DecodingError(err error) *mverr.MVError {
+	if bodytimeout.IsTimeout(err) {
+		return &mverr.MVError{
+			ErrCode:    mverr.RqstBodyTimeoutErrorCode,
+			ErrMsg:     mverr.RqstBodyTimeoutErrorMsg,
+			ErrDetail:  err.Error(),
+			WrappedErr: err,
+		}
+	}
+	if jsonlimit.IsTooComplex(err) {
+		return &mverr.MVError{
+			ErrCode:    mverr.JSONTooComplexErrorCode,
+			ErrMsg:     mverr.JSONTooComplexErrorMsg,
+			ErrDetail:  err.Error(),
+			WrappedErr: err,
+		}
+	}
+	return &mverr.MVError{
+		ErrCode:    mverr.JSONDecodingErrorCode,
+		ErrMsg:     mverr.JSONDecodingErrorMsg,
+		ErrDetail:  err.Error(),
+		WrappedErr: err,
+	}
+}
+
+// RetryAfter sets the "Retry-After" header of the response to 'd' rounded up to whole seconds
+func RetryAfter(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
+
+// HTTPStatus returns the HTTP status that corresponds to an error code. Errors caused by the
+// request are 4xx statuses, an unavailable DB is 503 (Service Unavailable), a DB query that timed
+// out is 504 (Gateway Timeout), all others are 500 (Internal Server Error). The statuses are
+// defined alongside the error codes, see mverr.HTTPStatus.
+func HTTPStatus(code mverr.ErrCode) int {
+	return mverr.HTTPStatus(code)
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package respond
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/internal/bodytimeout"
+	"github.com/youngkin/mockvideo/internal/clientinfo"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/jsonlimit"
+)
+
+func TestRespond(t *testing.T) {
+	tcs := []struct {
+		testName            string
+		respond             func(w http.ResponseWriter)
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+		expectedHeader      string
+		expectedRetryAfter  string
+	}{
+		{
+			testName: "testJSON",
+			respond: func(w http.ResponseWriter) {
+				w.Header().Set("Location", "/users/1")
+				JSON(w, http.StatusCreated, map[string]int{"id": 1})
+			},
+			expectedStatus:      http.StatusCreated,
+			expectedContentType: "application/json",
+			expectedBody:        `{"id":1}`,
+			expectedHeader:      "/users/1",
+		},
+		{
+			testName:            "testJSONMarshalingError",
+			respond:             func(w http.ResponseWriter) { JSON(w, http.StatusOK, math.NaN()) },
+			expectedStatus:      http.StatusInternalServerError,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody:        mverr.JSONMarshalingErrorMsg,
+		},
+		{
+			testName: "testError",
+			respond: func(w http.ResponseWriter) {
+				Error(w, &mverr.MVError{ErrCode: mverr.DBNoUserErrorCode, ErrMsg: mverr.DBNoUserErrorMsg})
+			},
+			expectedStatus:      http.StatusNotFound,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody:        mverr.DBNoUserErrorMsg,
+		},
+		{
+			testName: "testErrorRetryAfter",
+			respond: func(w http.ResponseWriter) {
+				Error(w, &mverr.MVError{ErrCode: mverr.DBUnavailableErrorCode, ErrMsg: mverr.DBUnavailableErrorMsg, RetryAfter: 1500 * time.Millisecond})
+			},
+			expectedStatus:      http.StatusServiceUnavailable,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody:        mverr.DBUnavailableErrorMsg,
+			expectedRetryAfter:  "2",
+		},
+		{
+			testName:            "testText",
+			respond:             func(w http.ResponseWriter) { Text(w, http.StatusBadRequest, "bad") },
+			expectedStatus:      http.StatusBadRequest,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody:        "bad",
+		},
+		{
+			testName:       "testStatus",
+			respond:        func(w http.ResponseWriter) { Status(w, http.StatusNoContent) },
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			rec := NewRecorder(rr)
+			tc.respond(rec)
+
+			if rr.Code != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, rr.Code)
+			}
+			if rec.Status() != tc.expectedStatus {
+				t.Errorf("expected recorded status %d, got %d", tc.expectedStatus, rec.Status())
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != tc.expectedContentType {
+				t.Errorf("expected Content-Type %q, got %q", tc.expectedContentType, ct)
+			}
+			if body := rr.Body.String(); body != tc.expectedBody {
+				t.Errorf("expected body %q, got %q", tc.expectedBody, body)
+			}
+			if loc := rr.Header().Get("Location"); loc != tc.expectedHeader {
+				t.Errorf("expected Location %q, got %q", tc.expectedHeader, loc)
+			}
+			if ra := rr.Header().Get("Retry-After"); ra != tc.expectedRetryAfter {
+				t.Errorf("expected Retry-After %q, got %q", tc.expectedRetryAfter, ra)
+			}
+		})
+	}
+}
+
+func TestRecorderDefaultStatus(t *testing.T) {
+	rec := NewRecorder(httptest.NewRecorder())
+	if rec.Status() != http.StatusOK {
+		t.Errorf("expected status %d before anything is written, got %d", http.StatusOK, rec.Status())
+	}
+	rec.Write([]byte("ok"))
+	rec.WriteHeader(http.StatusTeapot)
+	if rec.Status() != http.StatusOK {
+		t.Errorf("expected the implicit status %d, got %d", http.StatusOK, rec.Status())
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tcs := []struct {
+		code     mverr.ErrCode
+		expected int
+	}{
+		{code: mverr.MalformedURLErrorCode, expected: http.StatusBadRequest},
+		{code: mverr.UserValidationErrorCode, expected: http.StatusBadRequest},
+		{code: mverr.DBInsertDuplicateUserErrorCode, expected: http.StatusBadRequest},
+		{code: mverr.DBInsertDuplicateAccountErrorCode, expected: http.StatusBadRequest},
+		{code: mverr.AccountValidationErrorCode, expected: http.StatusBadRequest},
+		{code: mverr.UserUnauthorizedErrorCode, expected: http.StatusForbidden},
+		{code: mverr.PolicyDeniedErrorCode, expected: http.StatusForbidden},
+		{code: mverr.DBNoQueuedUserErrorCode, expected: http.StatusNotFound},
+		{code: mverr.DBNoExportErrorCode, expected: http.StatusNotFound},
+		{code: mverr.SignupDisabledErrorCode, expected: http.StatusNotFound},
+		{code: mverr.UsageDisabledErrorCode, expected: http.StatusNotFound},
+		{code: mverr.UnknownResourceErrorCode, expected: http.StatusNotFound},
+		{code: mverr.DBNoDeadLetterErrorCode, expected: http.StatusNotFound},
+		{code: mverr.DeadLettersDisabledErrorCode, expected: http.StatusNotFound},
+		{code: mverr.DeleteBlockedErrorCode, expected: http.StatusConflict},
+		{code: mverr.ExportNotReadyErrorCode, expected: http.StatusConflict},
+		{code: mverr.RqstBodyTimeoutErrorCode, expected: http.StatusRequestTimeout},
+		{code: mverr.ChangesExpiredErrorCode, expected: http.StatusGone},
+		{code: mverr.DBUnavailableErrorCode, expected: http.StatusServiceUnavailable},
+		{code: mverr.ReadOnlyModeErrorCode, expected: http.StatusServiceUnavailable},
+		{code: mverr.QueryTimeoutErrorCode, expected: http.StatusGatewayTimeout},
+		{code: mverr.DBQueryErrorCode, expected: http.StatusInternalServerError},
+		{code: mverr.UserRqstErrorCode, expected: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tcs {
+		if status := HTTPStatus(tc.code); status != tc.expected {
+			t.Errorf("expected status %d for error code %d, got %d", tc.expected, tc.code, status)
+		}
+	}
+}
+
+func TestDecodingError(t *testing.T) {
+	tcs := []struct {
+		testName     string
+		err          error
+		expectedCode mverr.ErrCode
+	}{
+		{testName: "testMalformed", err: &json.SyntaxError{}, expectedCode: mverr.JSONDecodingErrorCode},
+		{testName: "testBodyTimeout", err: bodytimeout.ErrTimeout, expectedCode: mverr.RqstBodyTimeoutErrorCode},
+		{testName: "testDeadlineExceeded", err: context.DeadlineExceeded, expectedCode: mverr.RqstBodyTimeoutErrorCode},
+		{testName: "testCancelled", err: context.Canceled, expectedCode: mverr.JSONDecodingErrorCode},
+		{testName: "testTooComplex", err: fmt.Errorf("%w, too deep", jsonlimit.ErrTooComplex), expectedCode: mverr.JSONTooComplexErrorCode},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			err := DecodingError(tc.err)
+			if err.ErrCode != tc.expectedCode || err.WrappedErr != tc.err {
+				t.Errorf("expected error code %d wrapping %v, got %+v", tc.expectedCode, tc.err, err)
+			}
+		})
+	}
+}
+
+func TestMethodLabel(t *testing.T) {
+	tcs := []struct {
+		method   string
+		expected string
+	}{
+		{method: http.MethodGet, expected: http.MethodGet},
+		{method: http.MethodDelete, expected: http.MethodDelete},
+		{method: "PROPFIND", expected: otherMethod},
+		{method: "get", expected: otherMethod},
+	}
+
+	for _, tc := range tcs {
+		if label := methodLabel(tc.method); label != tc.expected {
+			t.Errorf("expected label %s for method %s, got %s", tc.expected, tc.method, label)
+		}
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(DeprecatedRqsts)
+
+	r := httptest.NewRequest(http.MethodGet, "/users", nil)
+	r = r.WithContext(clientinfo.NewContext(r.Context(), "curl/other"))
+	w := httptest.NewRecorder()
+	Deprecated(w, r, UnpagedUsers)
+	Deprecated(w, r, LegacyJSONCasing)
+
+	if dep := w.Header().Get("Deprecation"); dep != "true" {
+		t.Errorf("expected Deprecation header 'true', got %q", dep)
+	}
+	warnings := w.Header().Values("Warning")
+	if len(warnings) != 2 || warnings[0] != `299 - "`+deprecationWarnings[UnpagedUsers]+`"` {
+		t.Errorf("expected a Warning header for each feature, got %q", warnings)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("error %s was not expected gathering metrics", err)
+	}
+	if len(families) != 1 || len(families[0].GetMetric()) != 2 {
+		t.Fatalf("expected a count for each feature, got %v", families)
+	}
+	for _, m := range families[0].GetMetric() {
+		labels := map[string]string{}
+		for _, l := range m.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if labels[ClientLabel] != "curl/other" || m.GetCounter().GetValue() != 1 {
+			t.Errorf("expected 1 request from 'curl/other', got %v", m)
+		}
+	}
+}
+
+func TestHasLegacyCasing(t *testing.T) {
+	tcs := []struct {
+		testName string
+		data     string
+		expected bool
+	}{
+		{testName: "testLowercase", data: `{"accountid":1,"name":"Mickey Dolenz","email":"MickeyD@gmail.com"}`, expected: false},
+		{testName: "testUppercaseKey", data: `{"AccountID":1,"name":"mickey dolenz"}`, expected: true},
+		{testName: "testUppercaseValue", data: `{"name":"Mickey","role":1}`, expected: false},
+		{testName: "testNestedKey", data: `{"users":[{"accountid":1},{"Name":"mickey"}]}`, expected: true},
+		{testName: "testNestedLowercase", data: `{"users":[{"accountid":1,"name":"A"},{"name":"B"}],"next":"X"}`, expected: false},
+		{testName: "testKeyAfterNestedObject", data: `{"a":{"b":"C"},"D":1}`, expected: true},
+		{testName: "testArrayOfStrings", data: `{"a":["B","C"]}`, expected: false},
+		{testName: "testInvalid", data: `{"a":`, expected: false},
+		{testName: "testEmpty", data: ``, expected: false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			if legacy := HasLegacyCasing([]byte(tc.data)); legacy != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, legacy)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/youngkin/mockvideo/cmd/accountd/http/respond"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+)
+
+// NewStatusBoardHandler returns the handler for '/statusboard'. It reports the consolidated health of
+// the services checked by 'board'. The response is a 200 (OK) unless every service is unhealthy, in
+// which case it's a 503 (Service Unavailable), so that a partial failure doesn't look like an outage.
+func NewStatusBoardHandler(board services.StatusBoardSvcInterface) (http.Handler, error) {
+	if board == nil {
+		return nil, errors.New("non-nil StatusBoardSvcInterface required")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respond.Text(w, http.StatusNotImplemented, "Sorry, only the GET method is supported.")
+			return
+		}
+		sb := board.Check(r.Context())
+		status := http.StatusOK
+		if sb.Status == services.StatusBoardDown {
+			status = http.StatusServiceUnavailable
+		}
+		respond.JSON(w, status, sb)
+	}), nil
+}
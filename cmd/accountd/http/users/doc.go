@@ -52,7 +52,12 @@ All verbs operate on a 'User' object encouded in JSON. It has the following stru
 			name: {string}
 			email: {string}
 			role: {int} // Valid values for 'role' are 0 (primary), 1 (unrestricted), 2 (restricted)
-			password: {string}
+			status: {string} // Read only, either "pending" or "active"
+			password: {string} // Write only, hashed with bcrypt before it's stored and never returned, at most 72 bytes
+			createdat: {string} // Read only, RFC 3339 time the user was created
+			updatedat: {string} // Read only, RFC 3339 time the user was last changed
+			lastlogin: {string} // Read only, RFC 3339 time the user last logged in, omitted if they never have
+			lastmodifiedby: {string} // Read only, the caller that last created or changed the user, e.g., "user:1" or "apikey:billing"
 		}
 
 Here's an example of the above:
@@ -79,32 +84,209 @@ resource id and the JSON having an 'id' field are indicative of an update and sh
 in a POST request will result in a '400' (BadRequest) status. The 'id' of the newly created User can be found in the "Location" header
 field. HTTP status of 201 indicates that the resource was successfully created.
 
+New users are created in the "pending" state and are emailed an activation token. Pending users aren't included
+in the results of 'GET /users'. A user is activated, i.e., changed to the "active" state, with a POST request that
+includes the token:
+
+		curl -i -X POST http://accountd.kube/users/6/activate?token=9f86d081884c7d659a2feaa0c55ad015
+
+A 200 HTTP status indicates the user was activated. A 400 HTTP status indicates the token is missing, doesn't match,
+or has expired. Pending users that aren't activated within 'activationTTLHours' (48 by default) are deleted.
+
+If the service is configured with a non-zero 'writeBehindRate' it runs in write-behind mode. In this mode a single
+user POST is queued and applied later, at no more than 'writeBehindRate' creations per second. The response has an
+HTTP status of 202 (Accepted) and its "Location" header contains a provisional resource path like '/users/pending/{id}'.
+A GET on the provisional resource path returns the status of the creation:
+
+		curl -i http://accountd.kube/users/pending/7
+
+		{
+			id: 7
+			href: "/users/pending/7"
+			status: "complete" // One of "pending", "processing", "complete", "failed", or "deadlettered"
+			attempts: 1 // The number of failed attempts, omitted if there weren't any
+			userid: 42 // Only present when status is "complete"
+			userhref: "/users/42" // Only present when status is "complete"
+			errmsg: "" // The reason for the last failed attempt
+		}
+
+A creation that's rejected, e.g., because the user is invalid or a duplicate, fails immediately. Creations that fail
+for other reasons, e.g., the database being unavailable, are retried with an increasing delay. They're
+"deadlettered" once they've been attempted 'writeBehindMaxAttempts' times (3 by default) and can then be replayed
+by support staff, see package admin.
+
+Bulk POST requests are not queued. Users in a bulk POST that share an email address, ignoring case, are all
+rejected with a 400 HTTP status before any user is created, so which of them would be created doesn't depend on
+the order of the concurrent creations.
+
+The response to a bulk POST or PUT includes each user's ID, name, and email. The 'verbosity' query parameter
+or the 'Bulk-Verbosity' header can instead ask for only the IDs, 'ids', or the whole user, 'full'. Passwords are
+never included. A result's 'errmsg' and 'errcode' are only included if the user's request failed.
+Each result's 'item' is the index of its user in the request, the results are in request order.
+
+Every user of a bulk POST or PUT is validated before any user is written. An invalid user fails with a 400 HTTP
+status and an 'errmsg' prefixed with its index, e.g., "item 3: ...". The valid users are still processed unless
+the 'bulkValidation' configuration item is 'failfast'. Then nothing is written if any user is invalid, and the
+response only includes the invalid users' results and has a 400 HTTP status.
+
+A bulk POST or PUT is only validated, nothing is written, if it includes the 'dryRun=true' query parameter or
+the 'Bulk-DryRun: true' header. The response lists the result each user would have, in request order, and has
+a 200 HTTP status if all of the users are valid or a 409 otherwise:
+
+		curl -i -X POST "http://accountd.kube/users?dryRun=true" -H "Bulk-Request: true" -H "Content-Type: application/json" -d "{\"users\":[{\"accountid\":1,\"name\":\"Brian Wilson\",\"email\":\"goodvibrations@gmail.com\",\"role\":1,\"password\":\"helpmerhonda\"}]}"
+
+A bulk POST with the 'mode=upsert' query parameter or the 'Bulk-Mode: upsert' header, e.g., to import users that
+may already exist, creates the users that don't exist and updates the name, role, and password of those that do,
+matching users by email address. Each result includes an 'outcome' of "created", "updated", or "skipped", a user
+that already matches isn't changed, and the response includes a 'summary' counting them:
+
+		{
+			overallstatus: 1
+			summary: {created: 1, updated: 1, skipped: 0, failed: 0}
+			results: [
+				{status: 2, outcome: "created", user: {id: 7, name: "Brian Wilson", email: "goodvibrations@gmail.com"}}
+				{status: 1, outcome: "updated", user: {id: 3, name: "Mike Love", email: "kokomo@gmail.com"}}
+			]
+		}
+
+A user whose email address is in use in another account isn't changed, its result fails with a 400 HTTP status.
+Upserts can't be dry runs.
+
 Here's an example of a PUT request:
 
 		curl -i -X PUT http://accountd.kube/users/1 -H "Content-Type: application/json" -d "{\"id\":1,\"accountid\":1,\"name\":\"Brian Wilson\",\"email\":\"goodvibrations@gmail.com\",\"role\":1,\"password\":\"inmyroom\"}"
 
-A successful request will result in an HTTP status of 200.
+A successful request will result in an HTTP status of 200. A PUT only updates an existing user, it never
+creates one. A PUT of a user that doesn't exist results in a 404 HTTP status.
 
 Here's are examples of GET requests (the second requests a 'User' identified by '1')
 
 		curl -i http://accountd.kube/users
 		curl -i http://accountd.kube/users/1
 
-JSON similar to the example above will be returned in the response body with a status 200.
+JSON similar to the example above will be returned in the response body with a status 200. The response to
+a GET of a single user includes "Last-Modified" and "ETag" headers derived from the user's 'updatedat' time.
+
+The users returned by 'GET /users' can be ordered with the 'sort' query parameter. Valid values are 'id',
+'createdat', and 'updatedat', optionally prefixed with '-' for descending order. Any other value results in a
+400 HTTP status:
+
+		curl -i http://accountd.kube/users?sort=-updatedat
+
+Large collections of users should be paged through rather than retrieved with a single 'GET /users', which is
+deprecated for them, its response has 'Deprecation' and 'Warning' headers. The 'limit' query parameter requests a page of at most 'limit' users, from 1 to 1000. The
+response includes a 'next' token when there are more users, pass it in the 'pagetoken' query parameter to
+request the next page (100 users by default), or follow the 'nexthref' link. Tokens are opaque. Pages are ordered by 'id' and continue after
+the last user of the previous page, so users created or deleted while paging don't cause other users to be
+skipped or returned twice. A 'sort' other than 'id' results in a 400 HTTP status, as does an invalid token:
+
+		curl -i http://accountd.kube/users?limit=100
+
+		{"users":[{"accountid":1,"href":"/users/1","id":1, ...}, ...],"next":"aWQ6MTAw","nexthref":"/users?limit=100&pagetoken=aWQ6MTAw"}
+
+		curl -i http://accountd.kube/users?limit=100&pagetoken=aWQ6MTAw
+
+The users that haven't logged in for a period are returned, ordered by 'id', by 'GET /users?inactiveSince={period}'.
+The period is a number of days, e.g., '90d', or a Go duration, e.g., '36h'. Users that have never logged in are
+included if they were created before the period began. Activating a user records their first login. The inactive
+users can't be paged, an invalid or non-positive period, or a 'limit' or 'pagetoken', results in a 400 HTTP status:
+
+		curl -i http://accountd.kube/users?inactiveSince=90d
+
+The response to an unpaged 'GET /users' includes "Last-Modified" and "ETag" headers derived from the latest 'updatedat'
+time and the number of users. Clients that poll the users, e.g., dashboards, can make the request conditional
+with an "If-None-Match" or "If-Modified-Since" header. If the users haven't changed a 304 HTTP status is
+returned without a body, which only requires a much cheaper query than retrieving the users. "If-None-Match"
+is preferred since the "ETag" also changes when users are deleted, "Last-Modified" may not:
+
+		curl -i http://accountd.kube/users -H 'If-None-Match: W/"2-1588334400"'
+
+The query behind an unpaged 'GET /users' can be bounded by 'httpGetUsersQueryTimeoutMillis', independently of
+the time allowed for the request. If the query times out a 504 HTTP status is returned, unless
+'httpGetUsersPartialResults' is true, in which case the users read before the query timed out are returned,
+without the "Last-Modified" and "ETag" headers, along with '"truncated":true'.
+
+Changes to the users returned by 'GET /users' can be polled for with 'GET /users/changes?since={seq}'. It
+returns the changes after sequence number 'seq', each identifying the user and whether it was created (i.e.,
+activated), updated, or deleted, along with the sequence number to use in the next request:
+
+		curl -i http://accountd.kube/users/changes?since=41
+
+		{"changes":[{"seq":42,"op":"update","userid":1,"at":"2020-05-01T12:00:00Z"}],"next":42}
+
+If there aren't any changes the request waits up to 'changesWaitSecs' (30 by default) for one before returning
+an empty list. Without 'since' the sequence number of the latest change is returned immediately. To start
+polling, get it before retrieving the users with 'GET /users'. Only the latest 'changeLogSize' (1000 by default)
+changes are kept, in memory, by each accountd instance. A 410 HTTP status is returned if changes after 'seq'
+are no longer available, e.g., because accountd restarted, in which case the users must be retrieved again.
+
+Rather than polling, a client such as a dashboard can stream user events with 'GET /users/events'. Events are
+sent as server-sent events, named after the event's topic, i.e., "user.created", "user.activated",
+"user.updated", or "user.deleted", with the sequence number of the change, if any, as the event's ID:
+
+		curl -N http://accountd.kube/users/events
+
+		id: 42
+		event: user.updated
+		data: {"userid":1,"seq":42,"at":"2020-05-01T12:00:00Z"}
+
+Up to 'eventStreamBufferSize' (100 by default) events are queued for each client, so a slow client never
+delays changes to users or the other clients. What happens when a client's queue is full is decided by
+'eventStreamPolicy'. With "disconnect", the default, a "resync" event is sent and the stream ends,
+with "dropoldest" the oldest queued events are dropped and a "dropped" event with their count is sent before
+the next event, e.g., 'data: {"count":3}'. Either way the client should retrieve the users again. Streams end
+after 'changesWaitSecs', the client is expected to reconnect.
+
+Users can be searched for by name or email address with 'GET /users/search?q={query}'. Up to 'limit' users
+are returned, from 1 to 1000 (100 by default). A missing or empty 'q' results in a 400 HTTP status:
+
+		curl -i http://accountd.kube/users/search?q=dolenz&limit=10
+
+		{"users":[{"accountid":1,"href":"/users/2","id":2,"name":"mickey dolenz", ...}]}
+
+If 'searchURL' is configured users are mirrored into an Elasticsearch, or OpenSearch, index named by
+'searchIndex' ("users" by default), and searches query it. Users whose name or email address contains words
+starting with the words in 'q' are returned in order of relevance. Changes are mirrored shortly after they're
+made. Otherwise, or if the index is unavailable, the database is searched for users whose name or email
+address contains 'q', ignoring case, and they're returned in 'id' order.
 
 Here's an example of a DELETE request:
 
 		curl -i -X DELETE http://accountd.kube/users/1
 
-A 200 HTTP status indicates a successful result.
+A 204 HTTP status indicates a successful result. DELETE is idempotent, deleting a user that doesn't exist
+also results in a 204 HTTP status. This makes it safe to retry a DELETE whose response was lost.
+
+Before a user is deleted the records that depend on it are checked. The primary user of an account that
+has other users ('accountusers') can't be deleted, the account would be left without a primary user. Nor can
+a user that accountUser records still associate with an account ('accountlinks'). The DELETE fails with a 409
+HTTP status and a JSON body listing the kinds of records blocking it and how many there are:
+
+		{"errmsg":"user can't be deleted, other records depend on it","dependencies":[{"kind":"accountusers","count":2}],"resolution":"..."}
+
+Either another user must first be made the account's primary user using 'POST /accounts/{id}/users/roles', or
+the DELETE names a successor, another user of the account, using the 'successor' query parameter or a JSON body:
+
+		curl -i -X DELETE http://accountd.kube/users/1?successor=2
+		curl -i -X DELETE -d '{"successor": 2}' http://accountd.kube/users/1
+
+The successor is made the account's primary user and the user is deleted in a single transaction. A successor
+is only valid when deleting a primary user. If the successor isn't another user of the same account the DELETE
+fails with a 409 HTTP status and an 'errmsg' describing the problem. Records that don't block a DELETE are
+removed along with the user, currently that's the impersonation tokens for the user, which are revoked.
 
 Other HTTP status codes indicate various errors. These are:
 
-1. 400 Bad Request - This indicates there was a problem with the request and it was not accepted. These request should not be retried.
-2. 404 Not Found - This indicates that the requested user, whether for GET or PUT, could not be found
-3. 500 Internal Server Error - This indicates that there was a problem with the server fulfilling the request. It does not indicate that the request was invalid. It's possible the problem could be resolved if the request is retried.
-4. 501 Not Implemented - The request is not supported (e.g., a HEAD request).
-5. 502 Bad Gateway - This is not returned directly by the service. It is returned by an upstream proxy or Kubernetes ingress. The request can be retried.
-6. 503 Service Unavailable - This may be returned if the server is overloaded. If so, there will beha a 'Retry-After' header indicating how much time should pass before the request is retried.
+1. 400 Bad Request - This indicates there was a problem with the request and it was not accepted, e.g., its JSON body was nested deeper than 'jsonMaxDepth' or had more than 'jsonMaxItems' fields and array elements. These request should not be retried.
+2. 403 Forbidden - This indicates the caller isn't authorized to make the request. Only a primary user of an account can create, update, or delete the users in that account. Other users can only update their own details, excluding their role and account.
+3. 404 Not Found - This indicates that the requested user, whether for GET or PUT, could not be found
+4. 408 Request Timeout - This indicates the request body wasn't received within 'requestBodyTimeoutMillis', e.g., because the client sent it too slowly. An HTTP/1 connection is closed. The request can be retried.
+5. 409 Conflict - This indicates a DELETE was rejected because other records depend on the user, or its successor is invalid, see above. The request shouldn't be retried until the blocking records, or the successor, have been changed.
+6. 410 Gone - This indicates the changes requested from 'GET /users/changes' are no longer available
+7. 500 Internal Server Error - This indicates that there was a problem with the server fulfilling the request. It does not indicate that the request was invalid. It's possible the problem could be resolved if the request is retried.
+8. 501 Not Implemented - The request is not supported (e.g., a HEAD request).
+9. 502 Bad Gateway - This is not returned directly by the service. It is returned by an upstream proxy or Kubernetes ingress. The request can be retried.
+10. 503 Service Unavailable - This is returned if the database is unavailable, i.e., its circuit breaker is open after repeated failures. There will be a 'Retry-After' header indicating how much time should pass, until the circuit breaker allows a trial request, before the request is retried. It's also returned for a POST, PUT, or DELETE while accountd is in read-only mode, i.e., after writes to the database have failed persistently. Reads are still served. The 'Retry-After' header indicates when the next write will be attempted, read-only mode ends as soon as a write succeeds. 'GET /readyz' reports the current mode.
+11. 504 Gateway Timeout - This indicates the query behind 'GET /users' timed out, see above. The request can be retried.
 */
 package users
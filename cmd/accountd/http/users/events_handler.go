@@ -0,0 +1,133 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/youngkin/mockvideo/cmd/accountd/http/respond"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/eventbus"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+const (
+	// eventsSubscriber is the name of the event bus subscriptions of event streams, e.g., in metrics.
+	// All streams share it so the metrics' cardinality doesn't depend on the number of clients.
+	eventsSubscriber = "sse"
+	// droppedEvent tells a client events weren't delivered to it, it should resynchronize
+	droppedEvent = "dropped"
+	// resyncEvent tells a client its stream ended because it fell too far behind, it should
+	// resynchronize before reconnecting
+	resyncEvent = "resync"
+)
+
+// eventsHandler streams user lifecycle events to clients as server-sent events, see the package documentation
+type eventsHandler struct {
+	bus    *eventbus.Bus
+	buffer int
+	policy eventbus.SlowConsumerPolicy
+	// maxDuration limits how long a stream lasts, the client reconnects once it ends
+	maxDuration time.Duration
+	logger      logging.Logger
+}
+
+// NewEventsHandler returns a properly configured *http.Handler for '/users/events'. Up to 'buffer'
+// events are queued for each client, after which 'policy' applies. Streams last up to 'maxDuration'.
+func NewEventsHandler(bus *eventbus.Bus, buffer int, policy eventbus.SlowConsumerPolicy, maxDuration time.Duration, logger logging.Logger) (http.Handler, error) {
+	if bus == nil {
+		return nil, errors.New("non-nil *eventbus.Bus required")
+	}
+	if buffer < 1 {
+		return nil, errors.New("buffer must be greater than 0")
+	}
+	if logger == nil {
+		return nil, errors.New("non-nil Logger required")
+	}
+	return eventsHandler{bus: bus, buffer: buffer, policy: policy, maxDuration: maxDuration, logger: logger}, nil
+}
+
+// ServeHTTP handles the request
+func (h eventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respond.Text(w, http.StatusNotImplemented, "Sorry, only the GET method is supported.")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respond.Text(w, http.StatusInternalServerError, "Sorry, streaming isn't supported.")
+		return
+	}
+
+	sub, err := h.bus.Subscribe(eventsSubscriber, h.buffer, h.policy, services.UserCreated, services.UserActivated, services.UserUpdated, services.UserDeleted)
+	if err != nil {
+		// The bus is only closed when accountd is shutting down
+		respond.Text(w, http.StatusServiceUnavailable, "Sorry, accountd is shutting down.")
+		return
+	}
+	defer sub.Unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	timer := time.NewTimer(h.maxDuration)
+	defer timer.Stop()
+	dropped := uint64(0)
+	for {
+		select {
+		case e, ok := <-sub.C():
+			if !ok {
+				// Unsubscribed for being a slow consumer, or accountd is shutting down
+				writeEvent(w, 0, resyncEvent, struct{}{})
+				flusher.Flush()
+				return
+			}
+			if n := sub.Dropped(); n > dropped {
+				if err = writeEvent(w, 0, droppedEvent, struct {
+					Count uint64 `json:"count"`
+				}{Count: n - dropped}); err != nil {
+					return
+				}
+				dropped = n
+			}
+			ue := e.Payload.(services.UserEvent)
+			if err = writeEvent(w, ue.Seq, e.Topic.Name(), ue); err != nil {
+				h.logger.WithFields(logging.Fields{
+					logging.ErrorDetail: err,
+					logging.Path:        r.URL.Path,
+				}).Debug("user event stream ended")
+				return
+			}
+			flusher.Flush()
+		case <-timer.C:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes a server-sent event named 'name' whose data is 'payload' encoded as JSON. The
+// event's ID is 'id' unless it's 0.
+func writeEvent(w io.Writer, id int64, name string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if id != 0 {
+		if _, err = fmt.Fprintf(w, "id: %d\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
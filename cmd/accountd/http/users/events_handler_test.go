@@ -0,0 +1,104 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package users
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/eventbus"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+func TestUserEventsStream(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	at := time.Date(2020, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+	tcs := []struct {
+		testName     string
+		method       string
+		events       []eventbus.Event
+		closeBus     bool
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			testName: "testEvents",
+			method:   http.MethodGet,
+			events: []eventbus.Event{
+				{Topic: services.UserCreated, Payload: services.UserEvent{UserID: 1, At: at}},
+				{Topic: services.UserUpdated, Payload: services.UserEvent{UserID: 1, Seq: 42, At: at}},
+			},
+			closeBus:     true,
+			expectedCode: http.StatusOK,
+			expectedBody: "event: user.created\ndata: {\"userid\":1,\"at\":\"2020-05-01T12:00:00Z\"}\n\n" +
+				"id: 42\nevent: user.updated\ndata: {\"userid\":1,\"seq\":42,\"at\":\"2020-05-01T12:00:00Z\"}\n\n" +
+				"event: resync\ndata: {}\n\n",
+		},
+		{
+			testName:     "testMaxDuration",
+			method:       http.MethodGet,
+			expectedCode: http.StatusOK,
+			expectedBody: "",
+		},
+		{
+			testName:     "testNotGET",
+			method:       http.MethodPost,
+			expectedCode: http.StatusNotImplemented,
+			expectedBody: "Sorry, only the GET method is supported.",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			bus := eventbus.New()
+			defer bus.Close()
+			h, err := NewEventsHandler(bus, 10, eventbus.Unsubscribe, 100*time.Millisecond, logger)
+			if err != nil {
+				t.Fatalf("error %s was not expected creating the handler", err)
+			}
+			testSrv := httptest.NewServer(h)
+			defer testSrv.Close()
+
+			rqst, err := http.NewRequest(tc.method, testSrv.URL+"/users/events", nil)
+			if err != nil {
+				t.Fatalf("error %s was not expected creating the request", err)
+			}
+			resp, err := http.DefaultClient.Do(rqst)
+			if err != nil {
+				t.Fatalf("error %s was not expected sending the request", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tc.expectedCode {
+				t.Fatalf("expected status %d, got %d", tc.expectedCode, resp.StatusCode)
+			}
+
+			// The stream has subscribed once its headers have been received
+			for _, e := range tc.events {
+				if err = bus.Publish(e.Topic, e.Payload); err != nil {
+					t.Fatalf("error %s was not expected publishing %s", err, e.Topic.Name())
+				}
+			}
+			if tc.closeBus {
+				bus.Close()
+			}
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("error %s was not expected reading the body", err)
+			}
+			if string(body) != tc.expectedBody {
+				t.Errorf("expected body %q, got %q", tc.expectedBody, string(body))
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/youngkin/mockvideo/cmd/accountd/http/respond"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/basepath"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/inflight"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// loginRoute is the route of login requests
+const loginRoute = "/login"
+
+// loginResponse is the response body of a successful login. The token is used as a bearer token,
+// i.e., in an 'Authorization: Bearer {token}' header.
+type loginResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresat"`
+	UserHREF  string    `json:"userhref"`
+}
+
+type loginHandler struct {
+	userSvc services.UserSvcInterface
+	logger  logging.Logger
+}
+
+// ServeHTTP handles the request
+func (h loginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := respond.NewRecorder(w)
+	route := loginRoute
+	if r.URL.Path != loginRoute {
+		route = respond.UnmatchedRoute
+	}
+	defer respond.Observe(r, UserRqstDur, route, rec, start)
+
+	// The request's ID is included in every message logged while handling it, see package errsummary
+	if id := inflight.FromContext(r.Context()); id != "" {
+		h.logger = h.logger.WithFields(logging.Fields{logging.RqstID: id})
+	}
+
+	h.logger.WithFields(logging.Fields{
+		logging.Method:     r.Method,
+		logging.Path:       r.URL.Path,
+		logging.RemoteAddr: r.RemoteAddr,
+	}).Info("HTTP request received")
+
+	if r.Method != http.MethodPost || r.URL.Path != loginRoute {
+		respond.Text(rec, http.StatusNotImplemented, "Sorry, only POST /login is supported.")
+		return
+	}
+	h.handlePost(rec, r)
+}
+
+func (h loginHandler) handlePost(w http.ResponseWriter, r *http.Request) {
+	creds := domain.Credentials{}
+	err := json.NewDecoder(r.Body).Decode(&creds)
+	if err != nil {
+		decodingErr := respond.DecodingError(err)
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   decodingErr.ErrCode,
+			logging.HTTPStatus:  respond.HTTPStatus(decodingErr.ErrCode),
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: err.Error(),
+		}).Error(decodingErr.ErrMsg)
+		respond.Text(w, respond.HTTPStatus(decodingErr.ErrCode), decodingErr.ErrMsg)
+		return
+	}
+
+	session, err2 := h.userSvc.Login(r.Context(), creds)
+	if err2 != nil {
+		respond.Error(w, err2)
+		return
+	}
+
+	resp := loginResponse{
+		Token:     session.Token,
+		ExpiresAt: session.ExpiresAt.UTC(),
+		UserHREF:  basepath.HREF(r.Context(), fmt.Sprintf("/users/%d", session.UserID)),
+	}
+	// The token is a credential, it mustn't be stored by caches
+	w.Header().Set("Cache-Control", "no-store")
+	if err = respond.JSON(w, http.StatusOK, resp); err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.JSONMarshalingErrorCode,
+			logging.HTTPStatus:  http.StatusInternalServerError,
+			logging.ErrorDetail: err.Error(),
+		}).Error(mverr.JSONMarshalingErrorMsg)
+	}
+}
+
+// NewLoginHandler returns the *http.Handler for 'POST /login'
+func NewLoginHandler(userSvc services.UserSvcInterface, logger logging.Logger) (http.Handler, error) {
+	if userSvc == nil {
+		return nil, errors.New("non-nil services.UserSvcInterface required")
+	}
+	if logger == nil {
+		return nil, errors.New("non-nil Logger required")
+	}
+	return loginHandler{userSvc: userSvc, logger: logger}, nil
+}
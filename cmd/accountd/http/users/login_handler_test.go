@@ -0,0 +1,128 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package users
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/db/memory"
+	"github.com/youngkin/mockvideo/internal/domain"
+)
+
+func TestPOSTLogin(t *testing.T) {
+	hash, err := auth.HashPassword("heyheywerethemonkees", auth.MinPasswordCost)
+	if err != nil {
+		t.Fatalf("error %s was not expected hashing the password", err)
+	}
+	keys, err := auth.ParseJWTKeys(strings.NewReader("2020-10 fedcba9876543210fedcba9876543210"))
+	if err != nil {
+		t.Fatalf("error %s was not expected parsing the JWT keys", err)
+	}
+
+	tcs := []struct {
+		testName           string
+		method             string
+		body               string
+		disabled           bool
+		expectedHTTPStatus int
+		expectedUserHREF   string
+	}{
+		{
+			testName:           "testPOSTLoginSuccess",
+			method:             http.MethodPost,
+			body:               `{"email":"mickeyd@gmail.com","password":"heyheywerethemonkees"}`,
+			expectedHTTPStatus: http.StatusOK,
+			expectedUserHREF:   "/users/1",
+		},
+		{
+			testName:           "testPOSTLoginWrongPassword",
+			method:             http.MethodPost,
+			body:               `{"email":"mickeyd@gmail.com","password":"daydreambeliever"}`,
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testPOSTLoginUnknownEmail",
+			method:             http.MethodPost,
+			body:               `{"email":"ptork@gmail.com","password":"heyheywerethemonkees"}`,
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testPOSTLoginMalformedJSON",
+			method:             http.MethodPost,
+			body:               `{"email":`,
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+		{
+			testName:           "testPOSTLoginDisabled",
+			method:             http.MethodPost,
+			body:               `{"email":"mickeyd@gmail.com","password":"heyheywerethemonkees"}`,
+			disabled:           true,
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:           "testGETLoginNotImplemented",
+			method:             http.MethodGet,
+			expectedHTTPStatus: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			ut := memory.NewUserTable()
+			if _, err := ut.CreateUser(domain.User{AccountID: 2, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary, Password: hash}); err != nil {
+				t.Fatalf("error %s was not expected creating a user", err)
+			}
+			userSvc, err := services.NewUserSvc(ut, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			if !tc.disabled {
+				if err = userSvc.EnableLogin(keys, services.DefaultSessionTTL); err != nil {
+					t.Fatalf("error %s was not expected enabling logins", err)
+				}
+			}
+
+			handler, err := NewLoginHandler(userSvc, logger)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting a login handler", err)
+			}
+
+			req := httptest.NewRequest(tc.method, "/login", bytes.NewBufferString(tc.body))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Fatalf("expected HTTP status %d, got %d: %s", tc.expectedHTTPStatus, rr.Code, rr.Body.String())
+			}
+			if strings.Contains(rr.Body.String(), hash) {
+				t.Errorf("expected the response not to include the password hash, got %s", rr.Body.String())
+			}
+			if tc.expectedUserHREF == "" {
+				return
+			}
+
+			var actual loginResponse
+			if err = json.Unmarshal(rr.Body.Bytes(), &actual); err != nil {
+				t.Fatalf("error %s was not expected unmarshaling the response", err)
+			}
+			if actual.UserHREF != tc.expectedUserHREF {
+				t.Errorf("expected userhref %s, got %s", tc.expectedUserHREF, actual.UserHREF)
+			}
+			if caller, err := keys.Caller(actual.Token); err != nil || caller.UserID != 1 || caller.AccountID != 2 || caller.Role != domain.Primary {
+				t.Errorf("expected a token for user 1 in account 2, got %+v, error %v", caller, err)
+			}
+			if cc := rr.Header().Get("Cache-Control"); cc != "no-store" {
+				t.Errorf("expected Cache-Control no-store, got %q", cc)
+			}
+		})
+	}
+}
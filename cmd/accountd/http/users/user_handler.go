@@ -23,39 +23,120 @@ This file attempts to showcase several best practices including:
 */
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	//"github.com/juju/errors"
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/cmd/accountd/http/respond"
 	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/basepath"
 	"github.com/youngkin/mockvideo/internal/domain"
 	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/inflight"
 	"github.com/youngkin/mockvideo/internal/logging"
+	"github.com/youngkin/mockvideo/internal/metrics"
 )
 
-const rqstStatus = "rqstStatus"
+// pendingPath is the path node identifying queued (write-behind) user creations, e.g., '/users/pending/{id}'
+const pendingPath = "pending"
+
+// sortParam is the query parameter used to order the results of 'GET /users', e.g., '/users?sort=-updatedat'
+const sortParam = "sort"
+
+// limitParam and pageTokenParam are the query parameters used to page through the results of 'GET /users',
+// e.g., '/users?limit=100&pagetoken={token}'. Either one selects paging.
+const (
+	limitParam     = "limit"
+	pageTokenParam = "pagetoken"
+)
+
+const (
+	// defaultPageLimit is the number of users in a page when only a page token is requested
+	defaultPageLimit = 100
+	// maxPageLimit is the largest number of users that can be requested in a page
+	maxPageLimit = 1000
+)
+
+// inactiveSinceParam is the query parameter selecting the users that haven't logged in for a period,
+// e.g., '/users?inactiveSince=90d'. The period is a number of days, e.g., '90d', or a duration, e.g., '36h'.
+const inactiveSinceParam = "inactiveSince"
+
+// maxInactiveDays is the longest period, in days, of inactiveSinceParam. It keeps the period from
+// overflowing a time.Duration.
+const maxInactiveDays = 100 * 365
+
+// changesPath is the path node identifying the user changes long-poll, e.g., '/users/changes?since={seq}'
+const changesPath = "changes"
+
+// sinceParam is the query parameter of the sequence number changes are requested after, see changesPath
+const sinceParam = "since"
+
+// searchPath is the path node identifying a search for users, e.g., '/users/search?q={query}&limit=10'
+const searchPath = "search"
+
+// queryParam is the query parameter of the words users are searched for, see searchPath
+const queryParam = "q"
+
+// activatePath is the path node identifying a user activation request, e.g., '/users/{id}/activate?token={token}'
+const activatePath = "activate"
+
+// methodUpsert identifies a bulk POST that upserts its users to handleRqstMultipleUsers, see isUpsert
+const methodUpsert = "UPSERT"
 
 // UserRqstDur is used to capture the length of HTTP requests
-var UserRqstDur = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-	Namespace: "mockvideo",
+var UserRqstDur = metrics.NewHistogramVec(prometheus.HistogramOpts{
 	Subsystem: "user",
 	Name:      "user_request_duration_seconds",
 	Help:      "user request duration distribution in seconds",
 	// Buckets:   prometheus.ExponentialBuckets(0.005, 1.1, 40),
 	Buckets: prometheus.LinearBuckets(0.001, .004, 50),
-}, []string{rqstStatus})
+}, respond.Labels)
+
+// DeleteBlocked is the body of the 409 (Conflict) response to a DELETE of a user that can't be
+// deleted because other records depend on it. Resolution describes how the DELETE can succeed, if
+// it can without changing the blocking records first.
+type DeleteBlocked struct {
+	ErrMsg       string                  `json:"errmsg"`
+	Dependencies []domain.UserDependency `json:"dependencies"`
+	Resolution   string                  `json:"resolution,omitempty"`
+}
+
+// BulkDeleteRqst is the body of a bulk DELETE, i.e., 'DELETE /users' with a "Bulk-Request: true"
+// header. IDs identifies the users to delete.
+type BulkDeleteRqst struct {
+	IDs []int `json:"ids"`
+}
+
+// DeleteRqst is the optional body of a DELETE of a user. Successor identifies the user that becomes
+// the account's primary user when the account's primary user is deleted, it's an alternative to
+// the 'successor' query parameter.
+type DeleteRqst struct {
+	Successor int `json:"successor"`
+}
+
+// successorResolution is the DeleteBlocked.Resolution of a DELETE blocked by the account's other users
+const successorResolution = "name another user of the account as the successor, the account's new primary user, using the 'successor' query parameter or request body"
 
 type handler struct {
 	userSvc    services.UserSvcInterface
-	logger     *log.Entry
+	logger     logging.Logger
 	maxBulkOps int
+	// writeBehind indicates single user creations are queued and applied later
+	writeBehind bool
+	// getUsersTimeout bounds the query of 'GET /users' when it isn't paged
+	getUsersTimeout domain.QueryTimeout
 }
 
 // TODO:
@@ -63,113 +144,441 @@ type handler struct {
 
 // ServeHTTP handles the request
 func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := respond.NewRecorder(w)
+	defer respond.Observe(r, UserRqstDur, userRoute(r.URL.Path), rec, start)
+
+	// The request's ID is included in every message logged while handling it, see package errsummary
+	if id := inflight.FromContext(r.Context()); id != "" {
+		h.logger = h.logger.WithFields(logging.Fields{logging.RqstID: id})
+	}
+
 	h.logRqstRcvd(r)
 	switch r.Method {
 	case http.MethodGet:
-		h.handleGet(w, r)
+		h.handleGet(rec, r)
 	case http.MethodPost:
-		h.handlePost(w, r)
+		h.handlePost(rec, r)
 	case http.MethodPut:
-		h.handlePut(w, r)
+		h.handlePut(rec, r)
 	case http.MethodDelete:
-		h.handleDelete(w, r)
+		h.handleDelete(rec, r)
 	default:
-		fmt.Fprintf(w, "Sorry, only GET, PUT, POST, and DELETE methods are supported.")
-		w.WriteHeader(http.StatusNotImplemented)
-		w.Write([]byte("Sorry, only GET, PUT, POST, and DELETE methods are supported."))
+		respond.Text(rec, http.StatusNotImplemented, "Sorry, only GET, PUT, POST, and DELETE methods are supported.")
 	}
 
 }
 
-func (h handler) handleGet(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-
-	completeRequest := func(httpStatus int, msg string) {
-		w.WriteHeader(httpStatus)
-		w.Write([]byte(msg))
-		UserRqstDur.WithLabelValues(strconv.Itoa(httpStatus)).
-			Observe(float64(time.Since(start)) / float64(time.Second))
+// userRoute returns the template of the route matching 'path', e.g., '/users/{id}' for '/users/42',
+// for use as a metric label. It's called for every request so it avoids splitting 'path'.
+func userRoute(path string) string {
+	p := strings.Trim(path, "/")
+	switch strings.Count(p, "/") {
+	case 0:
+		return "/users"
+	case 1:
+		if strings.HasSuffix(p, "/"+changesPath) {
+			return "/users/" + changesPath
+		}
+		if strings.HasSuffix(p, "/"+searchPath) {
+			return "/users/" + searchPath
+		}
+		if !strings.HasSuffix(p, "/"+pendingPath) {
+			return "/users/{id}"
+		}
+	case 2:
+		if strings.Contains(p, "/"+pendingPath+"/") {
+			return "/users/" + pendingPath + "/{id}"
+		}
+		if strings.HasSuffix(p, "/"+activatePath) {
+			return "/users/{id}/" + activatePath
+		}
 	}
+	return respond.UnmatchedRoute
+}
 
-	// Expecting a URL.Path like '/users' or '/users/{id}'
+func (h handler) handleGet(w http.ResponseWriter, r *http.Request) {
+	// Expecting a URL.Path like '/users', '/users/{id}', '/users/changes', '/users/search', or '/users/pending/{id}'
 	pathNodes, err := h.getURLPathNodes(r.URL.Path)
 	if err != nil {
-		h.logger.WithFields(log.Fields{
+		h.logger.WithFields(logging.Fields{
 			logging.ErrorCode:   mverr.MalformedURLErrorCode,
 			logging.HTTPStatus:  http.StatusBadRequest,
 			logging.Path:        r.URL.Path,
 			logging.ErrorDetail: err,
 		}).Error(mverr.MalformedURLMsg)
-		completeRequest(http.StatusBadRequest, mverr.MalformedURLMsg)
+		respond.Text(w, http.StatusBadRequest, mverr.MalformedURLMsg)
 		return
 	}
 
 	var payload interface{}
 	var err2 *mverr.MVError
 
-	if len(pathNodes) == 1 {
-		payload, err2 = h.handleGetUsers(pathNodes[0])
+	// paged is true when a page of the users, rather than all of them, is requested
+	query := r.URL.Query()
+	_, hasLimit := query[limitParam]
+	_, hasPageToken := query[pageTokenParam]
+	paged := len(pathNodes) == 1 && (hasLimit || hasPageToken)
+	_, hasInactiveSince := query[inactiveSinceParam]
+	inactive := len(pathNodes) == 1 && hasInactiveSince
+
+	if inactive {
+		payload, err2 = h.handleGetInactiveUsers(r.Context(), pathNodes[0], query.Get(inactiveSinceParam), paged)
+	} else if paged {
+		payload, err2 = h.handleGetUsersPage(r.Context(), pathNodes[0], query.Get(sortParam), query.Get(limitParam), query.Get(pageTokenParam))
+	} else if len(pathNodes) == 1 {
+		respond.Deprecated(w, r, respond.UnpagedUsers)
+		sortBy := query.Get(sortParam)
+		if h.usersNotModified(w, r, sortBy) {
+			return
+		}
+		payload, err2 = h.handleGetUsers(r.Context(), pathNodes[0], sortBy)
+	} else if pathNodes[1] == changesPath && len(pathNodes) == 2 {
+		payload, err2 = h.handleGetChanges(r.Context(), r.URL.Query().Get(sinceParam))
+		// Each poll must reach accountd
+		w.Header().Set("Cache-Control", "no-store")
+	} else if pathNodes[1] == searchPath && len(pathNodes) == 2 {
+		payload, err2 = h.handleSearchUsers(r.Context(), pathNodes[0], query.Get(queryParam), query.Get(limitParam))
+	} else if pathNodes[1] == pendingPath {
+		payload, err2 = h.handleGetQueuedUser(r.Context(), pathNodes[0], pathNodes[2:])
 	} else {
-		payload, err2 = h.handleGetOneUser(pathNodes[0], pathNodes[1:])
+		payload, err2 = h.handleGetOneUser(r.Context(), pathNodes[0], pathNodes[1:])
 	}
 
 	if err2 != nil {
-		httpStatus := http.StatusInternalServerError
-		switch err2.ErrCode {
-		case mverr.MalformedURLErrorCode:
-			httpStatus = http.StatusBadRequest
-			h.logger.WithFields(log.Fields{
+		if err2.ErrCode == mverr.MalformedURLErrorCode {
+			h.logger.WithFields(logging.Fields{
 				logging.ErrorCode:   err2.ErrCode,
 				logging.ErrorDetail: err2.Error(),
-				logging.HTTPStatus:  httpStatus,
+				logging.HTTPStatus:  http.StatusBadRequest,
 				logging.Path:        r.URL.Path,
 			}).Error(err2.ErrMsg)
-		case mverr.DBNoUserErrorCode:
-			httpStatus = http.StatusNotFound
 		}
 
 		// For non-mverr.MalformedURLErrors logging done in the service layer
-		completeRequest(httpStatus, err2.ErrMsg)
+		respond.Error(w, err2)
 		return
 	}
 
-	marshPayload, err := json.Marshal(payload)
+	if u, ok := payload.(*domain.User); ok && u != nil && !u.UpdatedAt.IsZero() {
+		w.Header().Set("Last-Modified", u.UpdatedAt.UTC().Format(http.TimeFormat))
+		w.Header().Set("ETag", userETag(u))
+	}
+	// The validators identify the version of all the users, so they don't apply to a page, to the
+	// inactive users, or to users truncated by the query timing out
+	if us, ok := payload.(*domain.Users); ok && !paged && !inactive && !us.Truncated {
+		setUsersValidators(w, us.Version(), query.Get(sortParam))
+	}
+	if err = respond.JSON(w, http.StatusOK, payload); err != nil {
+		h.logJSONMarshalingError(err)
+	}
+}
+
+func (h handler) handleGetUsers(ctx context.Context, path string, sortBy string) (interface{}, *mverr.MVError) {
+	less, err1 := userOrdering(sortBy)
+	if err1 != nil {
+		return nil, &mverr.MVError{
+			ErrCode:    mverr.MalformedURLErrorCode,
+			ErrMsg:     mverr.MalformedURLMsg,
+			ErrDetail:  err1.Error(),
+			WrappedErr: err1}
+	}
+
+	usrs, err := h.userSvc.GetUsers(ctx, h.getUsersTimeout)
 	if err != nil {
-		h.logger.WithFields(log.Fields{
-			logging.ErrorCode:   mverr.JSONMarshalingErrorCode,
-			logging.HTTPStatus:  http.StatusBadRequest,
-			logging.ErrorDetail: err.Error(),
-		}).Error(mverr.JSONMarshalingErrorMsg)
-		completeRequest(http.StatusBadRequest, mverr.JSONMarshalingErrorMsg)
-		return
+		return nil, err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(marshPayload)
+	if less != nil {
+		sort.SliceStable(usrs.Users, func(i, j int) bool {
+			return less(usrs.Users[i], usrs.Users[j])
+		})
+	}
 
-	UserRqstDur.WithLabelValues(strconv.Itoa(http.StatusFound)).Observe(float64(time.Since(start)) / float64(time.Second))
+	h.logger.Debugf("GetAllUsers() results: %+v", usrs)
+
+	for _, user := range usrs.Users {
+		user.HREF = basepath.HREF(ctx, "/"+path+"/"+strconv.Itoa(user.ID))
+	}
+
+	return usrs, nil
 }
 
-func (h handler) handleGetUsers(path string) (interface{}, *mverr.MVError) {
-	usrs, err := h.userSvc.GetUsers()
+// handleGetUsersPage returns the page of users following the one identified by 'token', or the first
+// page if 'token' is empty. Pages are ordered by user ID, so the only valid 'sortBy' is 'id'. The
+// page includes the token of the next page unless it's the last one.
+func (h handler) handleGetUsersPage(ctx context.Context, path, sortBy, limitStr, token string) (interface{}, *mverr.MVError) {
+	limit, afterID, err1 := pageParams(sortBy, limitStr, token)
+	if err1 != nil {
+		return nil, &mverr.MVError{
+			ErrCode:    mverr.MalformedURLErrorCode,
+			ErrMsg:     mverr.MalformedURLMsg,
+			ErrDetail:  err1.Error(),
+			WrappedErr: err1}
+	}
+
+	// The extra user, if any, shows there's a next page
+	usrs, err := h.userSvc.GetUsersPage(ctx, afterID, limit+1)
 	if err != nil {
 		return nil, err
 	}
+	if len(usrs.Users) > limit {
+		usrs.Users = usrs.Users[:limit]
+		usrs.Next = pageToken(usrs.Users[limit-1].ID)
+		usrs.NextHREF = basepath.HREF(ctx, fmt.Sprintf("/%s?%s=%d&%s=%s", path, limitParam, limit, pageTokenParam, url.QueryEscape(usrs.Next)))
+	}
 
-	h.logger.Debugf("GetAllUsers() results: %+v", usrs)
+	h.logger.Debugf("GetUsersPage() results: %+v", usrs)
 
 	for _, user := range usrs.Users {
-		user.HREF = "/" + path + "/" + strconv.Itoa(user.ID)
+		user.HREF = basepath.HREF(ctx, "/"+path+"/"+strconv.Itoa(user.ID))
 	}
 
 	return usrs, nil
 }
 
+// handleSearchUsers returns up to 'limitStr' users, 100 by default, whose name or email address matches 'q'
+func (h handler) handleSearchUsers(ctx context.Context, path, q, limitStr string) (interface{}, *mverr.MVError) {
+	if strings.TrimSpace(q) == "" {
+		return nil, &mverr.MVError{
+			ErrCode:   mverr.MalformedURLErrorCode,
+			ErrMsg:    mverr.MalformedURLMsg,
+			ErrDetail: fmt.Sprintf("expected non-empty '%s' parameter", queryParam)}
+	}
+	limit, _, err1 := pageParams("", limitStr, "")
+	if err1 != nil {
+		return nil, &mverr.MVError{
+			ErrCode:    mverr.MalformedURLErrorCode,
+			ErrMsg:     mverr.MalformedURLMsg,
+			ErrDetail:  err1.Error(),
+			WrappedErr: err1}
+	}
+
+	usrs, err := h.userSvc.SearchUsers(ctx, q, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	h.logger.Debugf("SearchUsers() results: %+v", usrs)
+
+	for _, user := range usrs.Users {
+		user.HREF = basepath.HREF(ctx, "/"+path+"/"+strconv.Itoa(user.ID))
+	}
+
+	return usrs, nil
+}
+
+// handleGetInactiveUsers returns, ordered by ID, the users that haven't logged in for the period
+// 'periodStr', see inactiveSinceParam. The inactive users can't be paged, 'paged' is true if paging
+// was requested.
+func (h handler) handleGetInactiveUsers(ctx context.Context, path, periodStr string, paged bool) (interface{}, *mverr.MVError) {
+	if paged {
+		return nil, &mverr.MVError{
+			ErrCode:   mverr.MalformedURLErrorCode,
+			ErrMsg:    mverr.MalformedURLMsg,
+			ErrDetail: fmt.Sprintf("'%s' can't be combined with '%s' or '%s'", inactiveSinceParam, limitParam, pageTokenParam)}
+	}
+	period, err1 := parseInactivePeriod(periodStr)
+	if err1 != nil {
+		return nil, &mverr.MVError{
+			ErrCode:    mverr.MalformedURLErrorCode,
+			ErrMsg:     mverr.MalformedURLMsg,
+			ErrDetail:  err1.Error(),
+			WrappedErr: err1}
+	}
+
+	usrs, err := h.userSvc.GetInactiveUsers(ctx, time.Now().Add(-period))
+	if err != nil {
+		return nil, err
+	}
+
+	h.logger.Debugf("GetInactiveUsers() results: %+v", usrs)
+
+	for _, user := range usrs.Users {
+		user.HREF = basepath.HREF(ctx, "/"+path+"/"+strconv.Itoa(user.ID))
+	}
+
+	return usrs, nil
+}
+
+// parseInactivePeriod parses the value of inactiveSinceParam, a number of days, e.g., '90d', or a
+// duration as parsed by time.ParseDuration, e.g., '36h'. The period must be positive.
+func parseInactivePeriod(s string) (time.Duration, error) {
+	var period time.Duration
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil || n > maxInactiveDays {
+			return 0, fmt.Errorf("invalid %s %q, expected a number of days, e.g., '90d', or a duration, e.g., '36h'", inactiveSinceParam, s)
+		}
+		period = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if period, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("invalid %s %q, expected a number of days, e.g., '90d', or a duration, e.g., '36h'", inactiveSinceParam, s)
+		}
+	}
+	if period <= 0 {
+		return 0, fmt.Errorf("invalid %s %q, the period must be positive", inactiveSinceParam, s)
+	}
+	return period, nil
+}
+
+// pageParams validates the paging query parameters of 'GET /users' and returns the number of users
+// in the page and the ID of the user the page follows
+func pageParams(sortBy, limitStr, token string) (limit, afterID int, err error) {
+	if sortBy != "" && sortBy != "id" {
+		return 0, 0, fmt.Errorf("unsupported sort order %q when paging, pages are sorted by 'id'", sortBy)
+	}
+
+	limit = defaultPageLimit
+	if limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return 0, 0, fmt.Errorf("invalid limit %q, expected an integer from 1 to %d", limitStr, maxPageLimit)
+		}
+	}
+
+	if token != "" {
+		afterID, err = parsePageToken(token)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return limit, afterID, nil
+}
+
+// pageTokenPrefix versions the format of page tokens
+const pageTokenPrefix = "id:"
+
+// pageToken returns the opaque token identifying the page of users following the user identified by 'id'.
+// Tokens are base64 encoded so that clients don't depend on their contents.
+func pageToken(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(pageTokenPrefix + strconv.Itoa(id)))
+}
+
+// parsePageToken returns the ID of the user encoded in 'token', see pageToken
+func parsePageToken(token string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil && strings.HasPrefix(string(b), pageTokenPrefix) {
+		id, err := strconv.Atoi(strings.TrimPrefix(string(b), pageTokenPrefix))
+		if err == nil && id >= 0 {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid page token %q", token)
+}
+
+// userOrdering returns a comparison function implementing the ordering requested by 'sortBy'.
+// 'sortBy' is one of 'id', 'createdat', or 'updatedat', optionally prefixed with '-' for
+// descending order. An empty 'sortBy' returns a nil function, i.e., the repository's order.
+func userOrdering(sortBy string) (func(u1, u2 *domain.User) bool, error) {
+	if sortBy == "" {
+		return nil, nil
+	}
+
+	desc := strings.HasPrefix(sortBy, "-")
+	key := strings.TrimPrefix(sortBy, "-")
+
+	var less func(u1, u2 *domain.User) bool
+	switch key {
+	case "id":
+		less = func(u1, u2 *domain.User) bool { return u1.ID < u2.ID }
+	case "createdat":
+		less = func(u1, u2 *domain.User) bool { return u1.CreatedAt.Before(u2.CreatedAt) }
+	case "updatedat":
+		less = func(u1, u2 *domain.User) bool { return u1.UpdatedAt.Before(u2.UpdatedAt) }
+	default:
+		return nil, fmt.Errorf("unsupported sort order %q, expected one of 'id', 'createdat', or 'updatedat'", sortBy)
+	}
+
+	if desc {
+		return func(u1, u2 *domain.User) bool { return less(u2, u1) }, nil
+	}
+	return less, nil
+}
+
+// usersNotModified responds with a 304 HTTP status, returning true, if the request for the users has
+// an If-None-Match or If-Modified-Since header and the users haven't changed since. The version of the
+// users is only retrieved for conditional requests, it's much cheaper than retrieving the users. If
+// the version can't be retrieved, or 'sortBy' is invalid, the request is handled unconditionally.
+func (h handler) usersNotModified(w http.ResponseWriter, r *http.Request, sortBy string) bool {
+	inm := r.Header.Get("If-None-Match")
+	ims := r.Header.Get("If-Modified-Since")
+	if inm == "" && ims == "" {
+		return false
+	}
+	if _, err := userOrdering(sortBy); err != nil {
+		return false
+	}
+
+	v, err := h.userSvc.GetUsersVersion(r.Context())
+	if err != nil {
+		// Logging done in the service layer
+		return false
+	}
+
+	// If-Modified-Since is ignored when If-None-Match is present, see RFC 7232 section 6
+	notModified := false
+	if inm != "" {
+		notModified = etagMatch(inm, usersETag(*v, sortBy))
+	} else if t, err := http.ParseTime(ims); err == nil && !v.LastModified.IsZero() {
+		notModified = !v.LastModified.Truncate(time.Second).After(t)
+	}
+	if !notModified {
+		return false
+	}
+
+	setUsersValidators(w, *v, sortBy)
+	respond.Status(w, http.StatusNotModified)
+	return true
+}
+
+// setUsersValidators sets the Last-Modified and ETag headers of a response containing the users
+// identified by 'v' in the order requested by 'sortBy'
+func setUsersValidators(w http.ResponseWriter, v domain.UsersVersion, sortBy string) {
+	if !v.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", v.LastModified.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("ETag", usersETag(v, sortBy))
+}
+
+// usersETag returns a weak entity tag for the users identified by 'v' in the order requested by
+// 'sortBy'. The count is included so that deleting users changes the tag.
+func usersETag(v domain.UsersVersion, sortBy string) string {
+	var modified int64
+	if !v.LastModified.IsZero() {
+		modified = v.LastModified.Unix()
+	}
+	if sortBy == "" {
+		return fmt.Sprintf(`W/"%d-%d"`, v.Count, modified)
+	}
+	return fmt.Sprintf(`W/"%d-%d-%s"`, v.Count, modified, sortBy)
+}
+
+// etagMatch returns true if 'etag' matches any of the entity tags in the If-None-Match header
+// value 'inm'. The weak comparison function is used, see RFC 7232 section 2.3.2.
+func etagMatch(inm, etag string) bool {
+	if strings.TrimSpace(inm) == "*" {
+		return true
+	}
+	for _, t := range strings.Split(inm, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(t), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// userETag returns a weak entity tag for 'u' derived from its ID and last update time
+func userETag(u *domain.User) string {
+	return fmt.Sprintf(`W/"%d-%d"`, u.ID, u.UpdatedAt.Unix())
+}
+
 // handleGetOneUser will return the user referenced by the provided resource path,
 // an error reason and error if there was a problem retrieving the user, or a nil user and a nil
 // error if the user was not found. The error reason will only be relevant when the error
 // is non-nil.
-func (h handler) handleGetOneUser(path string, pathNodes []string) (interface{}, *mverr.MVError) {
+func (h handler) handleGetOneUser(ctx context.Context, path string, pathNodes []string) (interface{}, *mverr.MVError) {
 	if len(pathNodes) != 1 {
 		return nil, &mverr.MVError{
 			ErrCode:    mverr.MalformedURLErrorCode,
@@ -187,7 +596,7 @@ func (h handler) handleGetOneUser(path string, pathNodes []string) (interface{},
 			WrappedErr: err1}
 	}
 
-	u, err2 := h.userSvc.GetUser(id)
+	u, err2 := h.userSvc.GetUser(ctx, id)
 	if err2 != nil {
 		return nil, err2
 	}
@@ -199,216 +608,370 @@ func (h handler) handleGetOneUser(path string, pathNodes []string) (interface{},
 
 	h.logger.Debugf("GetUser() results: %+v", u)
 
-	u.HREF = "/" + path + "/" + strconv.Itoa(u.ID)
+	u.HREF = basepath.HREF(ctx, "/"+path+"/"+strconv.Itoa(u.ID))
 
 	return u, nil
 }
 
+// handleGetQueuedUser will return the status of the queued user creation referenced by the
+// provided resource path. Once the user has been created the returned status includes the
+// HREF of the new user.
+// handleGetChanges returns the changes to the users after sequence number 'since', waiting for a
+// change if there aren't any. If 'since' is empty only the sequence number to poll from is returned.
+func (h handler) handleGetChanges(ctx context.Context, since string) (interface{}, *mverr.MVError) {
+	var seq *int64
+	if since != "" {
+		s, err := strconv.ParseInt(since, 10, 64)
+		if err != nil || s < 0 {
+			return nil, &mverr.MVError{
+				ErrCode:    mverr.MalformedURLErrorCode,
+				ErrMsg:     mverr.MalformedURLMsg,
+				ErrDetail:  fmt.Sprintf("expected non-negative numeric '%s' parameter, got %s", sinceParam, since),
+				WrappedErr: err}
+		}
+		seq = &s
+	}
+
+	changes, err := h.userSvc.GetChanges(ctx, seq)
+	if err != nil {
+		return nil, err
+	}
+
+	h.logger.Debugf("GetChanges() results: %+v", changes)
+
+	return changes, nil
+}
+
+func (h handler) handleGetQueuedUser(ctx context.Context, path string, pathNodes []string) (interface{}, *mverr.MVError) {
+	if len(pathNodes) != 1 {
+		return nil, &mverr.MVError{
+			ErrCode:    mverr.MalformedURLErrorCode,
+			ErrMsg:     mverr.MalformedURLMsg,
+			ErrDetail:  fmt.Sprintf("expected 1 pathNode after '%s', got %d, pathNode: %s", pendingPath, len(pathNodes), pathNodes),
+			WrappedErr: nil}
+	}
+
+	id, err1 := strconv.Atoi(pathNodes[0])
+	if err1 != nil {
+		return nil, &mverr.MVError{
+			ErrCode:    mverr.MalformedURLErrorCode,
+			ErrMsg:     mverr.MalformedURLMsg,
+			ErrDetail:  fmt.Sprintf("expected numeric queued user ID, got %s", pathNodes[0]),
+			WrappedErr: err1}
+	}
+
+	qu, err2 := h.userSvc.GetQueuedUser(ctx, id)
+	if err2 != nil {
+		return nil, err2
+	}
+
+	h.logger.Debugf("GetQueuedUser() results: %+v", qu)
+
+	qu.HREF = basepath.HREF(ctx, "/"+path+"/"+pendingPath+"/"+strconv.Itoa(qu.ID))
+	if qu.Status == domain.QueueComplete {
+		qu.UserHREF = basepath.HREF(ctx, "/"+path+"/"+strconv.Itoa(qu.UserID))
+	}
+
+	return qu, nil
+}
+
 func (h handler) handlePost(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
+	// Activation requests, i.e., '/users/{id}/activate?token={token}', don't have a request body
+	if pathNodes, err := h.getURLPathNodes(r.URL.Path); err == nil && len(pathNodes) == 3 && pathNodes[2] == activatePath {
+		h.handleActivateUser(w, r, pathNodes[1])
+		return
+	}
 
 	users := domain.Users{}
 	user := domain.User{}
-	isBulkRqst, err := h.decodeRequest(r, &user, &users)
+	isBulkRqst, err := h.decodeRequest(w, r, &user, &users)
 	if err != nil {
-		h.logger.WithFields(log.Fields{
+		h.logger.WithFields(logging.Fields{
 			logging.ErrorCode:   err.ErrCode,
-			logging.HTTPStatus:  http.StatusBadRequest,
+			logging.HTTPStatus:  decodingStatus(err),
 			logging.Path:        r.URL.Path,
 			logging.ErrorDetail: err.ErrDetail,
 		}).Error(err.ErrMsg)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(err.ErrDetail))
-		UserRqstDur.WithLabelValues(strconv.Itoa(http.StatusBadRequest)).Observe(float64(time.Since(start)) / float64(time.Second))
+		respond.Text(w, decodingStatus(err), err.ErrDetail)
 		return
 	}
 
 	// Expecting URL.Path '/users'
 	pathNodes, err2 := h.getURLPathNodes(r.URL.Path)
 	if err2 != nil {
-		h.logger.WithFields(log.Fields{
+		h.logger.WithFields(logging.Fields{
 			logging.ErrorCode:   mverr.MalformedURLErrorCode,
 			logging.HTTPStatus:  http.StatusBadRequest,
 			logging.Path:        r.URL.Path,
 			logging.ErrorMsg:    err2,
 			logging.ErrorDetail: fmt.Sprintf("error parsing URL Path %s", r.URL.Path),
 		}).Error(mverr.MalformedURLMsg)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(mverr.MalformedURLMsg))
-		UserRqstDur.WithLabelValues(strconv.Itoa(http.StatusBadRequest)).Observe(float64(time.Since(start)) / float64(time.Second))
+		respond.Text(w, http.StatusBadRequest, mverr.MalformedURLMsg)
 		return
 	}
 
 	if len(pathNodes) != 1 {
-		h.logger.WithFields(log.Fields{
+		h.logger.WithFields(logging.Fields{
 			logging.ErrorCode:   mverr.MalformedURLErrorCode,
 			logging.HTTPStatus:  http.StatusBadRequest,
 			logging.Path:        r.URL.Path,
 			logging.ErrorDetail: fmt.Sprintf("expected '/users', got %s", pathNodes),
 		}).Error(mverr.MalformedURLMsg)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(mverr.MalformedURLMsg))
-		UserRqstDur.WithLabelValues(strconv.Itoa(http.StatusBadRequest)).Observe(float64(time.Since(start)) / float64(time.Second))
+		respond.Text(w, http.StatusBadRequest, mverr.MalformedURLMsg)
 		return
 	}
 
-	if isBulkRqst {
-		h.handleRqstMultipleUsers(start, w, r.URL.Path, users, http.MethodPost)
+	dryRun, err := h.isDryRun(r, isBulkRqst)
+	if err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   err.ErrCode,
+			logging.HTTPStatus:  http.StatusBadRequest,
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: err.ErrDetail,
+		}).Error(err.ErrMsg)
+		respond.Text(w, http.StatusBadRequest, err.ErrDetail)
 		return
 	}
 
-	status := h.handlePostSingleUser(w, user)
+	if isBulkRqst {
+		verbosity, err := h.verbosity(r)
+		if err != nil {
+			h.logger.WithFields(logging.Fields{
+				logging.ErrorCode:   err.ErrCode,
+				logging.HTTPStatus:  http.StatusBadRequest,
+				logging.Path:        r.URL.Path,
+				logging.ErrorDetail: err.ErrDetail,
+			}).Error(err.ErrMsg)
+			respond.Text(w, http.StatusBadRequest, err.ErrDetail)
+			return
+		}
+		upsert, err := h.isUpsert(r, dryRun)
+		if err != nil {
+			h.logger.WithFields(logging.Fields{
+				logging.ErrorCode:   err.ErrCode,
+				logging.HTTPStatus:  http.StatusBadRequest,
+				logging.Path:        r.URL.Path,
+				logging.ErrorDetail: err.ErrDetail,
+			}).Error(err.ErrMsg)
+			respond.Text(w, http.StatusBadRequest, err.ErrDetail)
+			return
+		}
+		if upsert {
+			h.handleRqstMultipleUsers(r.Context(), w, users, methodUpsert, dryRun, verbosity)
+			return
+		}
+		h.handleRqstMultipleUsers(r.Context(), w, users, http.MethodPost, dryRun, verbosity)
+		return
+	}
 
-	UserRqstDur.WithLabelValues(strconv.Itoa(status)).Observe(float64(time.Since(start)) / float64(time.Second))
+	h.handlePostSingleUser(r.Context(), w, user)
 }
 
-func (h handler) handlePostSingleUser(w http.ResponseWriter, user domain.User) int {
+func (h handler) handlePostSingleUser(ctx context.Context, w http.ResponseWriter, user domain.User) {
 	h.logger.Debugf("handlePostSingleUser: user %+v", user)
 	if user.ID != 0 { // User ID must *NOT* be populated (i.e., with a non-zero value) on an insert
 		errMsg := fmt.Sprintf("expected User.ID = 0, got User.ID = %d", user.ID)
-		h.logger.WithFields(log.Fields{
+		h.logger.WithFields(logging.Fields{
 			logging.ErrorCode:   mverr.InvalidInsertErrorCode,
 			logging.HTTPStatus:  http.StatusBadRequest,
 			logging.Path:        fmt.Sprintf("/users/%d", user.ID),
 			logging.ErrorDetail: errMsg,
 		}).Error(mverr.InvalidInsertErrorMsg)
+		respond.Text(w, http.StatusBadRequest, errMsg)
+		return
+	}
 
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(errMsg))
-		return http.StatusBadRequest
-
+	if h.writeBehind {
+		h.handleEnqueueSingleUser(ctx, w, user)
+		return
 	}
 
-	userID, err := h.userSvc.CreateUser(user)
+	userID, err := h.userSvc.CreateUser(ctx, user)
 	if err != nil {
-		status := http.StatusInternalServerError
-		if err.ErrCode == mverr.DBInsertDuplicateUserErrorCode || err.ErrCode == mverr.UserValidationErrorCode {
-			status = http.StatusBadRequest
-		}
-		w.WriteHeader(status)
-		w.Write([]byte(err.ErrMsg))
-		return status
+		respond.Error(w, err)
+		return
 	}
 
 	user.ID = userID
-	user.HREF = fmt.Sprintf("/users/%d", userID)
+	user.HREF = basepath.HREF(ctx, fmt.Sprintf("/users/%d", userID))
 
 	w.Header().Add("Location", user.HREF)
-	w.WriteHeader(http.StatusCreated)
-	return http.StatusCreated
+	respond.Status(w, http.StatusCreated)
 }
 
-func (h handler) handlePut(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
+// handleActivateUser activates the pending user identified by 'idNode'. The request's 'token' query
+// parameter must match the activation token sent to the user.
+func (h handler) handleActivateUser(w http.ResponseWriter, r *http.Request, idNode string) {
+	id, err := strconv.Atoi(idNode)
+	if err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.MalformedURLErrorCode,
+			logging.HTTPStatus:  http.StatusBadRequest,
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: fmt.Sprintf("Invalid resource ID, must be int, got %v", idNode),
+		}).Error(mverr.MalformedURLMsg)
+		respond.Text(w, http.StatusBadRequest, mverr.MalformedURLMsg)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if len(token) == 0 {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.InvalidActivationErrorCode,
+			logging.HTTPStatus:  http.StatusBadRequest,
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: "missing 'token' query parameter",
+		}).Error(mverr.InvalidActivationErrorMsg)
+		respond.Text(w, http.StatusBadRequest, mverr.InvalidActivationErrorMsg)
+		return
+	}
+
+	err2 := h.userSvc.ActivateUser(r.Context(), id, token)
+	if err2 != nil {
+		respond.Error(w, err2)
+		return
+	}
+
+	respond.Status(w, http.StatusOK)
+}
 
+// handleEnqueueSingleUser queues the user creation and responds with a 202 (Accepted) status. The
+// "Location" header and response body provide the provisional HREF that can be used to check
+// on the status of the user creation.
+func (h handler) handleEnqueueSingleUser(ctx context.Context, w http.ResponseWriter, user domain.User) {
+	queueID, err := h.userSvc.EnqueueUser(ctx, user)
+	if err != nil {
+		respond.Error(w, err)
+		return
+	}
+
+	qu := domain.QueuedUser{
+		ID:     queueID,
+		HREF:   basepath.HREF(ctx, fmt.Sprintf("/users/%s/%d", pendingPath, queueID)),
+		Status: domain.QueuePending,
+	}
+
+	w.Header().Add("Location", qu.HREF)
+	if err2 := respond.JSON(w, http.StatusAccepted, qu); err2 != nil {
+		w.Header().Del("Location")
+		h.logJSONMarshalingError(err2)
+	}
+}
+
+func (h handler) handlePut(w http.ResponseWriter, r *http.Request) {
 	users := &domain.Users{}
 	user := &domain.User{}
-	isBulkRqst, err := h.decodeRequest(r, user, users)
+	isBulkRqst, err := h.decodeRequest(w, r, user, users)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(err.ErrDetail))
-		UserRqstDur.WithLabelValues(strconv.Itoa(http.StatusBadRequest)).Observe(float64(time.Since(start)) / float64(time.Second))
+		respond.Text(w, decodingStatus(err), err.ErrDetail)
+		return
 	}
 
 	pathNodes, err2 := h.getURLPathNodes(r.URL.Path)
 	if err2 != nil {
-		h.logger.WithFields(log.Fields{
+		h.logger.WithFields(logging.Fields{
 			logging.ErrorCode:   mverr.MalformedURLErrorCode,
 			logging.HTTPStatus:  http.StatusBadRequest,
 			logging.Path:        r.URL.Path,
 			logging.ErrorDetail: err2,
 		}).Error(mverr.MalformedURLMsg)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(mverr.MalformedURLMsg))
-		UserRqstDur.WithLabelValues(strconv.Itoa(http.StatusBadRequest)).Observe(float64(time.Since(start)) / float64(time.Second))
+		respond.Text(w, http.StatusBadRequest, mverr.MalformedURLMsg)
 		return
 	}
 
 	// Expecting URL.Path '/users/{id}' or '/users' (on a bulk PUT)
 	if len(pathNodes) != 1 && len(pathNodes) != 2 {
 		errMsg := fmt.Sprintf("expecting resource path like '/users' or '/users/{id}', got %+v", pathNodes)
-		h.logger.WithFields(log.Fields{
+		h.logger.WithFields(logging.Fields{
 			logging.ErrorCode:   mverr.MalformedURLErrorCode,
 			logging.HTTPStatus:  http.StatusBadRequest,
 			logging.Path:        r.URL.Path,
 			logging.ErrorDetail: errMsg,
 		}).Error(mverr.MalformedURLMsg)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(errMsg))
-		UserRqstDur.WithLabelValues(strconv.Itoa(http.StatusBadRequest)).Observe(float64(time.Since(start)) / float64(time.Second))
+		respond.Text(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	dryRun, err := h.isDryRun(r, isBulkRqst)
+	if err != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   err.ErrCode,
+			logging.HTTPStatus:  http.StatusBadRequest,
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: err.ErrDetail,
+		}).Error(err.ErrMsg)
+		respond.Text(w, http.StatusBadRequest, err.ErrDetail)
 		return
 	}
 
 	if isBulkRqst {
-		h.handleRqstMultipleUsers(start, w, r.URL.Path, *users, http.MethodPut)
+		verbosity, err := h.verbosity(r)
+		if err != nil {
+			h.logger.WithFields(logging.Fields{
+				logging.ErrorCode:   err.ErrCode,
+				logging.HTTPStatus:  http.StatusBadRequest,
+				logging.Path:        r.URL.Path,
+				logging.ErrorDetail: err.ErrDetail,
+			}).Error(err.ErrMsg)
+			respond.Text(w, http.StatusBadRequest, err.ErrDetail)
+			return
+		}
+		h.handleRqstMultipleUsers(r.Context(), w, *users, http.MethodPut, dryRun, verbosity)
 		return
 	}
 
-	status := h.handlePutSingleUser(w, *user)
-	UserRqstDur.WithLabelValues(strconv.Itoa(status)).Observe(float64(time.Since(start)) / float64(time.Second))
+	h.handlePutSingleUser(r.Context(), w, *user)
 }
 
-func (h handler) handlePutSingleUser(w http.ResponseWriter, user domain.User) int {
-	err := h.userSvc.UpdateUser(user)
+func (h handler) handlePutSingleUser(ctx context.Context, w http.ResponseWriter, user domain.User) {
+	err := h.userSvc.UpdateUser(ctx, user)
 	if err != nil {
-		errMsg := mverr.DBUpSertErrorMsg
-		httpStatus := http.StatusInternalServerError
-		if err.ErrCode == mverr.UserValidationErrorCode {
-			httpStatus = http.StatusBadRequest
-			errMsg = mverr.UserValidationErrorMsg
-		}
-		if err.ErrCode == mverr.DBNoUserErrorCode {
-			httpStatus = http.StatusBadRequest
-			errMsg = mverr.DBNoUserErrorMsg
+		switch err.ErrCode {
+		case mverr.UserValidationErrorCode, mverr.UserUnauthorizedErrorCode, mverr.DBNoUserErrorCode, mverr.DBUnavailableErrorCode, mverr.ReadOnlyModeErrorCode:
+			// A PUT never creates a user, a missing user is not found
+			respond.Error(w, err)
+		default:
+			respond.Text(w, http.StatusInternalServerError, mverr.DBUpSertErrorMsg)
 		}
-
-		w.WriteHeader(httpStatus)
-		w.Write([]byte(errMsg))
-		return httpStatus
+		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	return http.StatusOK
-
+	respond.Status(w, http.StatusOK)
 }
 
-func (h handler) handleRqstMultipleUsers(start time.Time, w http.ResponseWriter, path string, users domain.Users, method string) {
-	h.logger.Debugf("handleRqstMultipleUsers for %s", method)
+// handleRqstMultipleUsers creates, 'method' POST, updates, PUT, or upserts, methodUpsert, 'users'. If
+// 'dryRun' the users are only validated, see services.UserSvc.ValidateUsers. 'verbosity' decides how
+// much of each user is included in the response.
+func (h handler) handleRqstMultipleUsers(ctx context.Context, w http.ResponseWriter, users domain.Users, method string, dryRun bool, verbosity services.Verbosity) {
+	h.logger.Debugf("handleRqstMultipleUsers for %s, dry run %t", method, dryRun)
 
 	var responses *services.BulkResponse
-	switch method {
-	case http.MethodPost:
-		responses, _ = h.userSvc.CreateUsers(users)
-	case http.MethodPut:
-		responses, _ = h.userSvc.UpdateUsers(users)
+	switch {
+	case method == http.MethodPost && dryRun:
+		responses, _ = h.userSvc.ValidateUsers(ctx, users, services.CREATE)
+	case method == http.MethodPut && dryRun:
+		responses, _ = h.userSvc.ValidateUsers(ctx, users, services.UPDATE)
+	case method == http.MethodPost:
+		responses, _ = h.userSvc.CreateUsers(ctx, users)
+	case method == http.MethodPut:
+		responses, _ = h.userSvc.UpdateUsers(ctx, users)
+	case method == methodUpsert:
+		responses, _ = h.userSvc.UpsertUsers(ctx, users)
 	default:
-		h.logger.WithFields(log.Fields{
+		h.logger.WithFields(logging.Fields{
 			logging.ErrorCode:   mverr.BulkRequestErrorCode,
 			logging.ErrorDetail: fmt.Errorf("unsupported HTTP Method %s specified in bulk request, only POST and PUT are supported", method),
 		}).Error(mverr.BulkRequestErrorMsg)
 		responses = &services.BulkResponse{OverallStatus: http.StatusBadRequest}
 	}
 
-	marshResp, err := json.Marshal(*responses)
-	if err != nil {
-		h.logger.WithFields(log.Fields{
-			logging.ErrorCode:   mverr.JSONMarshalingErrorCode,
-			logging.HTTPStatus:  http.StatusInternalServerError,
-			logging.ErrorDetail: err.Error(),
-		}).Error(mverr.JSONMarshalingErrorMsg)
-		w.WriteHeader(http.StatusInternalServerError)
-		UserRqstDur.WithLabelValues(strconv.Itoa(http.StatusInternalServerError)).Observe(float64(time.Since(start)) / float64(time.Second))
+	overallStatus := mapStatusToHTTPStatus(responses.OverallStatus)
+	if err := respond.JSON(w, overallStatus, responses.View(verbosity)); err != nil {
+		h.logJSONMarshalingError(err)
 		return
 	}
 
-	overallStatus := mapStatusToHTTPStatus(responses.OverallStatus)
-	w.WriteHeader(overallStatus)
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(marshResp)
-
-	h.logger.Debugf("handleRqstMultipleUsers: response %s for method %s with HTTP Status %d", marshResp, method, overallStatus)
-	UserRqstDur.WithLabelValues(strconv.Itoa(overallStatus)).Observe(float64(time.Since(start)) / float64(time.Second))
-	return
+	h.logger.Debugf("handleRqstMultipleUsers: response %+v for method %s with HTTP Status %d", *responses, method, overallStatus)
 }
 
 func mapStatusToHTTPStatus(status services.Status) int {
@@ -420,6 +983,8 @@ func mapStatusToHTTPStatus(status services.Status) int {
 		httpStatus = http.StatusConflict
 	case services.StatusCreated:
 		httpStatus = http.StatusCreated
+	case services.StatusForbidden:
+		httpStatus = http.StatusForbidden
 	case services.StatusNotFound:
 		httpStatus = http.StatusNotFound
 	case services.StatusOK:
@@ -433,41 +998,32 @@ func mapStatusToHTTPStatus(status services.Status) int {
 	return httpStatus
 }
 
-func (h handler) decodeRequest(r *http.Request, user *domain.User, users *domain.Users) (bool, *mverr.MVError) {
-	// Get user(s) out of request body and validate
-	d := json.NewDecoder(r.Body)
+// decodeRequest decodes the user, or users if it's a bulk request, in the body of 'r'. A body using
+// respond.LegacyJSONCasing is marked as deprecated in the response, 'w'.
+func (h handler) decodeRequest(w http.ResponseWriter, r *http.Request, user *domain.User, users *domain.Users) (bool, *mverr.MVError) {
+	// Get user(s) out of request body and validate. The body is kept to check its casing once it's decoded.
+	var body bytes.Buffer
+	d := json.NewDecoder(io.TeeReader(r.Body, &body))
 	d.DisallowUnknownFields() // error if user sends extra data
-	var err error
-	isBulkRqst := false
-	hVal, ok := r.Header["Bulk-Request"]
-
-	if ok {
-		isBulkRqst, err = strconv.ParseBool(hVal[0])
-		if err != nil {
-			return isBulkRqst, &mverr.MVError{
-				ErrCode:    mverr.UserRqstErrorCode,
-				ErrDetail:  fmt.Sprintf("Expected 'true' or 'false' value for 'Bulk-Request' header, got %s", hVal[0]),
-				ErrMsg:     mverr.UserRqstErrorMsg,
-				WrappedErr: err,
-			}
-		}
+	isBulkRqst, mvErr := bulkRqst(r)
+	if mvErr != nil {
+		return isBulkRqst, mvErr
 	}
 
+	var err error
 	if isBulkRqst {
 		err = d.Decode(users)
 	} else {
 		err = d.Decode(user)
 	}
 	if err != nil {
-		return isBulkRqst, &mverr.MVError{
-			ErrCode:    mverr.JSONDecodingErrorCode,
-			ErrDetail:  err.Error(),
-			ErrMsg:     mverr.JSONDecodingErrorMsg,
-			WrappedErr: err,
-		}
+		return isBulkRqst, respond.DecodingError(err)
+	}
+	if respond.HasLegacyCasing(body.Bytes()) {
+		respond.Deprecated(w, r, respond.LegacyJSONCasing)
 	}
 	if d.More() {
-		h.logger.WithFields(log.Fields{
+		h.logger.WithFields(logging.Fields{
 			logging.ErrorCode:   mverr.JSONDecodingErrorCode,
 			logging.ErrorMsg:    mverr.JSONDecodingErrorMsg,
 			logging.ErrorDetail: fmt.Sprintf("JSON request body contained unexpected data: %s", r.Body),
@@ -477,76 +1033,319 @@ func (h handler) decodeRequest(r *http.Request, user *domain.User, users *domain
 	return isBulkRqst, nil
 }
 
+// bulkRqst returns true if 'r' is a bulk request, i.e., it has a "Bulk-Request: true" header
+func bulkRqst(r *http.Request) (bool, *mverr.MVError) {
+	hVal, ok := r.Header["Bulk-Request"]
+	if !ok {
+		return false, nil
+	}
+	isBulkRqst, err := strconv.ParseBool(hVal[0])
+	if err != nil {
+		return isBulkRqst, &mverr.MVError{
+			ErrCode:    mverr.UserRqstErrorCode,
+			ErrDetail:  fmt.Sprintf("Expected 'true' or 'false' value for 'Bulk-Request' header, got %s", hVal[0]),
+			ErrMsg:     mverr.UserRqstErrorMsg,
+			WrappedErr: err,
+		}
+	}
+	return isBulkRqst, nil
+}
+
+// decodingStatus returns the HTTP status of a request whose body couldn't be decoded by decodeRequest
+// because of 'err'. It's 408 (Request Timeout) if the body wasn't received in time, otherwise it's
+// 400 (Bad Request).
+func decodingStatus(err *mverr.MVError) int {
+	if err.ErrCode == mverr.RqstBodyTimeoutErrorCode {
+		return http.StatusRequestTimeout
+	}
+	return http.StatusBadRequest
+}
+
+// isDryRun returns true if the request asks for its users to only be validated, using either the
+// 'dryRun' query parameter or the 'Bulk-DryRun' header. Only bulk requests, 'isBulkRqst', can be
+// dry runs.
+func (h handler) isDryRun(r *http.Request, isBulkRqst bool) (bool, *mverr.MVError) {
+	params := []struct{ name, val string }{
+		{name: "'dryRun' query parameter", val: r.URL.Query().Get("dryRun")},
+		{name: "'Bulk-DryRun' header", val: r.Header.Get("Bulk-DryRun")},
+	}
+	for _, p := range params {
+		name, val := p.name, p.val
+		if val == "" {
+			continue
+		}
+		dryRun, err := strconv.ParseBool(val)
+		if err != nil {
+			return false, &mverr.MVError{
+				ErrCode:    mverr.UserRqstErrorCode,
+				ErrDetail:  fmt.Sprintf("Expected 'true' or 'false' value for %s, got %s", name, val),
+				ErrMsg:     mverr.UserRqstErrorMsg,
+				WrappedErr: err,
+			}
+		}
+		if !dryRun {
+			continue
+		}
+		if !isBulkRqst {
+			return false, &mverr.MVError{
+				ErrCode:   mverr.UserRqstErrorCode,
+				ErrDetail: fmt.Sprintf("%s is only supported by bulk requests", name),
+				ErrMsg:    mverr.UserRqstErrorMsg,
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// isUpsert returns true if a bulk POST asks for its users to be upserted, i.e., created or, if a user with
+// the same email address exists, updated, see services.UserSvc.UpsertUsers. It uses either the 'mode'
+// query parameter or the 'Bulk-Mode' header, whose value is 'create', the default, or 'upsert'. Upserts
+// can't be dry runs, 'dryRun'.
+func (h handler) isUpsert(r *http.Request, dryRun bool) (bool, *mverr.MVError) {
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = r.Header.Get("Bulk-Mode")
+	}
+	switch strings.ToLower(mode) {
+	case "", "create":
+		return false, nil
+	case "upsert":
+		if dryRun {
+			return false, &mverr.MVError{
+				ErrCode:   mverr.UserRqstErrorCode,
+				ErrDetail: "an upsert can't be a dry run",
+				ErrMsg:    mverr.UserRqstErrorMsg,
+			}
+		}
+		return true, nil
+	}
+	return false, &mverr.MVError{
+		ErrCode:   mverr.UserRqstErrorCode,
+		ErrDetail: fmt.Sprintf("unknown bulk mode %q, expected one of 'create' or 'upsert'", mode),
+		ErrMsg:    mverr.UserRqstErrorMsg,
+	}
+}
+
+// verbosity returns how much of each user the response to a bulk request should include, using
+// either the 'verbosity' query parameter or the 'Bulk-Verbosity' header, see services.Verbosity
+func (h handler) verbosity(r *http.Request) (services.Verbosity, *mverr.MVError) {
+	name := r.URL.Query().Get("verbosity")
+	if name == "" {
+		name = r.Header.Get("Bulk-Verbosity")
+	}
+	v, err := services.ParseVerbosity(name)
+	if err != nil {
+		return v, &mverr.MVError{
+			ErrCode:    mverr.UserRqstErrorCode,
+			ErrDetail:  err.Error(),
+			ErrMsg:     mverr.UserRqstErrorMsg,
+			WrappedErr: err,
+		}
+	}
+	return v, nil
+}
+
 func (h handler) handleDelete(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
+	isBulkRqst, mvErr := bulkRqst(r)
+	if mvErr != nil {
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mvErr.ErrCode,
+			logging.HTTPStatus:  http.StatusBadRequest,
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: mvErr.ErrDetail,
+		}).Error(mvErr.ErrMsg)
+		respond.Text(w, http.StatusBadRequest, mvErr.ErrDetail)
+		return
+	}
 
 	pathNodes, err := h.getURLPathNodes(r.URL.Path)
 	if err != nil {
-		h.logger.WithFields(log.Fields{
+		h.logger.WithFields(logging.Fields{
 			logging.ErrorCode:   mverr.MalformedURLErrorCode,
 			logging.HTTPStatus:  http.StatusBadRequest,
 			logging.Path:        r.URL.Path,
 			logging.ErrorDetail: err,
 		}).Error(mverr.MalformedURLMsg)
+		respond.Text(w, http.StatusBadRequest, mverr.MalformedURLMsg)
+		return
+	}
 
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(mverr.MalformedURLMsg))
-		UserRqstDur.WithLabelValues(strconv.Itoa(http.StatusBadRequest)).Observe(float64(time.Since(start)) / float64(time.Second))
+	// Expecting URL.Path '/users' on a bulk DELETE
+	if isBulkRqst && len(pathNodes) == 1 {
+		h.handleDeleteMultipleUsers(w, r)
 		return
 	}
 
 	if len(pathNodes) != 2 {
-		h.logger.WithFields(log.Fields{
+		h.logger.WithFields(logging.Fields{
 			logging.ErrorCode:   mverr.MalformedURLErrorCode,
 			logging.HTTPStatus:  http.StatusBadRequest,
 			logging.Path:        r.URL.Path,
 			logging.ErrorDetail: fmt.Sprintf("expecting resource path like /users/{id}, got %+v", pathNodes),
 		}).Error(mverr.MalformedURLMsg)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(mverr.MalformedURLMsg))
-		UserRqstDur.WithLabelValues(strconv.Itoa(http.StatusBadRequest)).Observe(float64(time.Since(start)) / float64(time.Second))
+		respond.Text(w, http.StatusBadRequest, mverr.MalformedURLMsg)
 		return
 	}
 
 	uid, err := strconv.Atoi(pathNodes[1])
 	if err != nil {
-		h.logger.WithFields(log.Fields{
+		h.logger.WithFields(logging.Fields{
 			logging.ErrorCode:   mverr.MalformedURLErrorCode,
 			logging.HTTPStatus:  http.StatusBadRequest,
 			logging.Path:        r.URL.Path,
 			logging.ErrorDetail: fmt.Sprintf("Invalid resource ID, must be int, got %v", pathNodes[1]),
 		}).Error(mverr.MalformedURLMsg)
-
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(mverr.MalformedURLMsg))
-		UserRqstDur.WithLabelValues(strconv.Itoa(http.StatusBadRequest)).Observe(float64(time.Since(start)) / float64(time.Second))
+		respond.Text(w, http.StatusBadRequest, mverr.MalformedURLMsg)
 		return
 	}
-	err2 := h.userSvc.DeleteUser(uid)
+	successorID, err2 := h.successor(r)
 	if err2 != nil {
-		h.logger.WithFields(log.Fields{
+		h.logger.WithFields(logging.Fields{
 			logging.ErrorCode:   err2.ErrCode,
-			logging.HTTPStatus:  http.StatusInternalServerError,
+			logging.HTTPStatus:  decodingStatus(err2),
 			logging.Path:        r.URL.Path,
 			logging.ErrorDetail: err2.ErrDetail,
 		}).Error(err2.ErrMsg)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(mverr.DBDeleteErrorMsg))
-		UserRqstDur.WithLabelValues(strconv.Itoa(http.StatusInternalServerError)).Observe(float64(time.Since(start)) / float64(time.Second))
+		respond.Text(w, decodingStatus(err2), err2.ErrDetail)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	if successorID != 0 {
+		err2 = h.userSvc.DeleteUserWithSuccessor(r.Context(), uid, successorID)
+	} else {
+		err2 = h.userSvc.DeleteUser(r.Context(), uid)
+	}
+	if err2 != nil {
+		httpStatus := http.StatusInternalServerError
+		errMsg := mverr.DBDeleteErrorMsg
+		switch err2.ErrCode {
+		case mverr.UserUnauthorizedErrorCode:
+			httpStatus = http.StatusForbidden
+			errMsg = mverr.UserUnauthorizedErrorMsg
+		case mverr.DeleteBlockedErrorCode:
+			httpStatus = http.StatusConflict
+			errMsg = err2.ErrMsg
+		case mverr.InvalidSuccessorErrorCode:
+			// The detail identifies the problem with the successor, the caller is authorized to know it
+			httpStatus = http.StatusConflict
+			errMsg = fmt.Sprintf("%s, %s", err2.ErrMsg, err2.ErrDetail)
+		case mverr.DBUnavailableErrorCode, mverr.ReadOnlyModeErrorCode:
+			httpStatus = http.StatusServiceUnavailable
+			errMsg = err2.ErrMsg
+			respond.RetryAfter(w, err2.RetryAfter)
+		}
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   err2.ErrCode,
+			logging.HTTPStatus:  httpStatus,
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: err2.ErrDetail,
+		}).Error(err2.ErrMsg)
 
-	UserRqstDur.WithLabelValues(strconv.Itoa(http.StatusCreated)).Observe(float64(time.Since(start)) / float64(time.Second))
+		var depErr *domain.DependentsError
+		if errors.As(err2.WrappedErr, &depErr) {
+			body := DeleteBlocked{ErrMsg: errMsg, Dependencies: depErr.Dependencies}
+			for _, dep := range depErr.Dependencies {
+				if dep.Kind == domain.DependentAccountUsers {
+					body.Resolution = successorResolution
+				}
+			}
+			if err := respond.JSON(w, httpStatus, body); err != nil {
+				h.logJSONMarshalingError(err)
+			}
+			return
+		}
+		respond.Text(w, httpStatus, errMsg)
+		return
+	}
+
+	// DELETE is idempotent, deleting a user that doesn't exist, e.g., a retried DELETE, also succeeds
+	respond.Status(w, http.StatusNoContent)
+}
+
+// handleDeleteMultipleUsers deletes the users identified by the BulkDeleteRqst body of 'r', see
+// services.UserSvc.DeleteUsers. Only the ID of each user is included in the response.
+func (h handler) handleDeleteMultipleUsers(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	var rqst BulkDeleteRqst
+	if err := d.Decode(&rqst); err != nil {
+		decodingErr := respond.DecodingError(err)
+		h.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   decodingErr.ErrCode,
+			logging.HTTPStatus:  decodingStatus(decodingErr),
+			logging.Path:        r.URL.Path,
+			logging.ErrorDetail: decodingErr.ErrDetail,
+		}).Error(decodingErr.ErrMsg)
+		respond.Text(w, decodingStatus(decodingErr), decodingErr.ErrDetail)
+		return
+	}
+
+	responses, _ := h.userSvc.DeleteUsers(r.Context(), rqst.IDs)
+
+	overallStatus := mapStatusToHTTPStatus(responses.OverallStatus)
+	if err := respond.JSON(w, overallStatus, responses.View(services.VerbosityIDs)); err != nil {
+		h.logJSONMarshalingError(err)
+		return
+	}
+
+	h.logger.Debugf("handleDeleteMultipleUsers: response %+v with HTTP Status %d", *responses, overallStatus)
+}
+
+// successor returns the ID of the user named as the successor of a deleted primary user, see
+// services.UserSvc.DeleteUserWithSuccessor, or 0 if there isn't one. It's named by the 'successor'
+// query parameter or, if there isn't one, by a DeleteRqst body.
+func (h handler) successor(r *http.Request) (int, *mverr.MVError) {
+	invalid := func(val interface{}) *mverr.MVError {
+		return &mverr.MVError{
+			ErrCode:   mverr.UserRqstErrorCode,
+			ErrMsg:    mverr.UserRqstErrorMsg,
+			ErrDetail: fmt.Sprintf("Expected a user ID for 'successor', got %v", val),
+		}
+	}
+
+	if val := r.URL.Query().Get("successor"); val != "" {
+		id, err := strconv.Atoi(val)
+		if err != nil || id < 1 {
+			return 0, invalid(val)
+		}
+		return id, nil
+	}
+
+	if r.Body == nil {
+		return 0, nil
+	}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	var rqst DeleteRqst
+	if err := d.Decode(&rqst); err != nil {
+		if err == io.EOF {
+			// There's no body
+			return 0, nil
+		}
+		return 0, respond.DecodingError(err)
+	}
+	if rqst.Successor < 1 {
+		return 0, invalid(rqst.Successor)
+	}
+	return rqst.Successor, nil
+}
+
+// logJSONMarshalingError logs a failure to marshal a response body, see respond.JSON
+func (h handler) logJSONMarshalingError(err error) {
+	h.logger.WithFields(logging.Fields{
+		logging.ErrorCode:   mverr.JSONMarshalingErrorCode,
+		logging.HTTPStatus:  http.StatusInternalServerError,
+		logging.ErrorDetail: err.Error(),
+	}).Error(mverr.JSONMarshalingErrorMsg)
 }
 
 func (h handler) logRqstRcvd(r *http.Request) {
-	h.logger.WithFields(log.Fields{
-		logging.Method:     r.Method,
-		logging.Path:       r.URL.Path,
-		logging.RemoteAddr: r.RemoteAddr,
-	}).Info("HTTP request received")
+	logging.Log(h.logger, logging.InfoLevel, "HTTP request received", func(f logging.Fields) {
+		f[logging.Method] = r.Method
+		f[logging.Path] = r.URL.Path
+		f[logging.RemoteAddr] = r.RemoteAddr
+	})
 }
 
 func (h handler) getURLPathNodes(path string) ([]string, error) {
@@ -576,7 +1375,7 @@ func (h handler) parseRqst(r *http.Request) (domain.User, []string, error) {
 	u := domain.User{}
 	err := d.Decode(&u)
 	if err != nil {
-		h.logger.WithFields(log.Fields{
+		h.logger.WithFields(logging.Fields{
 			logging.ErrorCode:   mverr.JSONDecodingErrorCode,
 			logging.HTTPStatus:  http.StatusBadRequest,
 			logging.ErrorDetail: err.Error(),
@@ -585,7 +1384,7 @@ func (h handler) parseRqst(r *http.Request) (domain.User, []string, error) {
 		return domain.User{}, nil, err
 	}
 	if d.More() {
-		h.logger.WithFields(log.Fields{
+		h.logger.WithFields(logging.Fields{
 			logging.ErrorCode:   mverr.JSONDecodingErrorCode,
 			logging.ErrorDetail: fmt.Sprintf("Additional JSON after User data: %v", u),
 		}).Warn(mverr.JSONDecodingErrorMsg)
@@ -594,7 +1393,7 @@ func (h handler) parseRqst(r *http.Request) (domain.User, []string, error) {
 	// Expecting a URL.Path like '/users/{id}'
 	pathNodes, err := h.getURLPathNodes(r.URL.Path)
 	if err != nil {
-		h.logger.WithFields(log.Fields{
+		h.logger.WithFields(logging.Fields{
 			logging.ErrorCode:   mverr.MalformedURLErrorCode,
 			logging.HTTPStatus:  http.StatusBadRequest,
 			logging.Path:        r.URL.Path,
@@ -607,13 +1406,30 @@ func (h handler) parseRqst(r *http.Request) (domain.User, []string, error) {
 	return u, pathNodes, nil
 }
 
-// NewUserHandler returns a properly configured *http.Handler
-func NewUserHandler(userSvc services.UserSvcInterface, logger *log.Entry, maxBulkOps int) (http.Handler, error) {
+// NewUserHandler returns a properly configured *http.Handler. If 'writeBehind' is true single
+// user creations (POST) are queued and applied later instead of being applied immediately.
+// 'getUsersTimeout' bounds the query of 'GET /users' when it isn't paged, if the users are
+// truncated the response includes '"truncated": true'.
+func NewUserHandler(userSvc services.UserSvcInterface, logger logging.Logger, maxBulkOps int, writeBehind bool, getUsersTimeout domain.QueryTimeout) (http.Handler, error) {
 	if logger == nil {
-		return nil, errors.New("non-nil log.Entry  required")
+		return nil, errors.New("non-nil Logger required")
 	}
 	if maxBulkOps == 0 {
 		return nil, errors.New("maxBulkOps must be greater than zero")
 	}
-	return handler{userSvc: userSvc, maxBulkOps: maxBulkOps, logger: logger}, nil
+	return handler{userSvc: userSvc, maxBulkOps: maxBulkOps, logger: logger, writeBehind: writeBehind, getUsersTimeout: getUsersTimeout}, nil
+}
+
+// ActivationMiddleware routes user activation requests, i.e., 'POST /users/{id}/activate?token={token}',
+// to 'activations', the handler returned by NewUserHandler, and any other request to 'next'. Pending
+// users can't log in, an activation is authenticated by its token instead, so like logins and signups
+// activations must bypass the authentication middleware applied by 'next'.
+func ActivationMiddleware(activations http.Handler, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && userRoute(r.URL.Path) == "/users/{id}/"+activatePath {
+			activations.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
@@ -0,0 +1,133 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package users
+
+/*
+These benchmarks measure the handler's overhead, e.g., routing, JSON encoding and decoding,
+logging, and metrics. The handler is backed by an in-memory repository so database access
+isn't included. The handler is called directly, without a network round trip.
+*/
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/db/memory"
+	"github.com/youngkin/mockvideo/internal/domain"
+)
+
+// getUserAllocBudget is the maximum number of allocations allowed for a 'GET /users/{id}'.
+// If a change legitimately requires more allocations, increase the budget in the same change
+// and explain why in the commit message.
+const getUserAllocBudget = 35
+
+// newBenchmarkHandler returns a handler backed by an in-memory repository containing 'numUsers'
+// active users with IDs 1 through 'numUsers'
+func newBenchmarkHandler(tb testing.TB, numUsers int) http.Handler {
+	repo := memory.NewUserTable()
+	for i := 1; i <= numUsers; i++ {
+		_, err := repo.CreateUser(domain.User{
+			AccountID: 1,
+			Name:      fmt.Sprintf("user %d", i),
+			EMail:     fmt.Sprintf("user%d@gmail.com", i),
+			Role:      domain.Unrestricted,
+			Password:  "myawesomepassword",
+			Status:    domain.Active,
+		})
+		if err != nil {
+			tb.Fatalf("error %s was not expected creating user %d", err, i)
+		}
+	}
+
+	userSvc, err := services.NewUserSvc(repo, logger, 10, 10, 10)
+	if err != nil {
+		tb.Fatalf("error %s was not expected when getting UserSvc", err)
+	}
+	h, err := NewUserHandler(userSvc, logger, 10, false, domain.QueryTimeout{})
+	if err != nil {
+		tb.Fatalf("error '%s' was not expected when getting a user handler", err)
+	}
+	return h
+}
+
+func getUser(h http.Handler) int {
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	return rr.Code
+}
+
+func BenchmarkHandleGetUser(b *testing.B) {
+	h := newBenchmarkHandler(b, 1)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if status := getUser(h); status != http.StatusOK {
+			b.Fatalf("expected StatusCode = %d, got %d", http.StatusOK, status)
+		}
+	}
+}
+
+func BenchmarkHandleGetUsers(b *testing.B) {
+	h := newBenchmarkHandler(b, 100)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users", nil))
+		if rr.Code != http.StatusOK {
+			b.Fatalf("expected StatusCode = %d, got %d", http.StatusOK, rr.Code)
+		}
+	}
+}
+
+func BenchmarkHandlePost(b *testing.B) {
+	// Each user must have a unique email address
+	bodies := make([]string, b.N)
+	for i := range bodies {
+		bodies[i] = fmt.Sprintf(`{"accountid":1,"name":"user %d","email":"user%d@gmail.com","role":1,"password":"myawesomepassword"}`, i, i)
+	}
+
+	var h http.Handler
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// The in-memory repository checks every user for a duplicate email address, so it's
+		// periodically replaced to keep the number of users, and the cost of the check, small
+		if i%100 == 0 {
+			b.StopTimer()
+			h = newBenchmarkHandler(b, 0)
+			b.StartTimer()
+		}
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(bodies[i])))
+		if rr.Code != http.StatusCreated {
+			b.Fatalf("expected StatusCode = %d, got %d", http.StatusCreated, rr.Code)
+		}
+	}
+}
+
+func TestGetUserAllocBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping allocation budget test in short mode")
+	}
+
+	h := newBenchmarkHandler(t, 1)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if status := getUser(h); status != http.StatusOK {
+			t.Fatalf("expected StatusCode = %d, got %d", http.StatusOK, status)
+		}
+	})
+	if allocs > getUserAllocBudget {
+		t.Errorf("GET /users/{id} made %.0f allocations, the budget is %d", allocs, getUserAllocBudget)
+	}
+}
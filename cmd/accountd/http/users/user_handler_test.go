@@ -16,32 +16,41 @@ These tests and supporting code demonstrate the following:
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/cmd/accountd/http/respond"
 	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/clock"
 	"github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/db/memory"
 	"github.com/youngkin/mockvideo/internal/db/tests"
 	"github.com/youngkin/mockvideo/internal/domain"
 	logging "github.com/youngkin/mockvideo/internal/logging"
 )
 
 // logger is used to control code-under-test logging behavior
-var logger *log.Entry
+var logger logging.Logger
 
 func init() {
-	logger = logging.GetLogger()
+	logger = logging.Default()
 	// Uncomment for more verbose logging
-	// logger.Logger.SetLevel(log.DebugLevel)
+	// logging.GetLogger().Logger.SetLevel(log.DebugLevel)
 	// Suppress all application logging
-	logger.Logger.SetLevel(log.PanicLevel)
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
 	// Uncomment for non-tty logging
 	// log.SetFormatter(&log.TextFormatter{
 	// 	DisableColors: true,
@@ -49,6 +58,17 @@ func init() {
 	//  })
 }
 
+// withCaller wraps 'h' so that requests carry 'caller', as if it had been authenticated.
+// Requests don't carry a caller if 'caller' is nil.
+func withCaller(h http.Handler, caller *auth.Caller) http.Handler {
+	if caller == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), *caller)))
+	})
+}
+
 func TestPOSTUser(t *testing.T) {
 	tcs := []struct {
 		testName           string
@@ -150,12 +170,12 @@ func TestPOSTUser(t *testing.T) {
 			}
 			defer dbase.Close()
 
-			userSvc, err := services.NewUserSvc(ut, logger, 10)
+			userSvc, err := services.NewUserSvc(ut, logger, 10, 10, 10)
 			if err != nil {
 				t.Fatalf("error %s was not expected when getting UserSvc", err)
 			}
 
-			srvHandler, err := NewUserHandler(userSvc, logger, 10)
+			srvHandler, err := NewUserHandler(userSvc, logger, 10, false, domain.QueryTimeout{})
 			if err != nil {
 				t.Fatalf("error '%s' was not expected when getting a user handler", err)
 			}
@@ -231,12 +251,11 @@ func TestPUTUser(t *testing.T) {
 			teardownFunc: tests.DBCallTeardownHelper,
 		},
 		{
-			// MySQL UPDATE will insert a row if it doesn't exist. Needed to prohibit
-			// this hence this test.
+			// A PUT must never create a user
 			testName:           "testUpdateNonExistUser",
 			shouldPass:         false,
 			url:                "/users/100",
-			expectedHTTPStatus: http.StatusBadRequest,
+			expectedHTTPStatus: http.StatusNotFound,
 			updateResourceID:   "users/100",
 			expectedResourceID: "",
 			postData: `
@@ -327,12 +346,12 @@ func TestPUTUser(t *testing.T) {
 			}
 			defer dbase.Close()
 
-			userSvc, err := services.NewUserSvc(ut, logger, 10)
+			userSvc, err := services.NewUserSvc(ut, logger, 10, 10, 10)
 			if err != nil {
 				t.Fatalf("error %s was not expected when getting UserSvc", err)
 			}
 
-			srvHandler, err := NewUserHandler(userSvc, logger, 10)
+			srvHandler, err := NewUserHandler(userSvc, logger, 10, false, domain.QueryTimeout{})
 			if err != nil {
 				t.Fatalf("error '%s' was not expected when getting a user handler", err)
 			}
@@ -375,8 +394,10 @@ func TestDELETEUser(t *testing.T) {
 		testName           string
 		shouldPass         bool
 		url                string
+		body               string
 		expectedHTTPStatus int
 		user               domain.User
+		caller             *auth.Caller
 		setupFunc          func(*testing.T, domain.User) (*sql.DB, sqlmock.Sqlmock)
 		teardownFunc       func(*testing.T, sqlmock.Sqlmock)
 	}{
@@ -384,7 +405,7 @@ func TestDELETEUser(t *testing.T) {
 			testName:           "testDeleteUserSuccess",
 			shouldPass:         true,
 			url:                "/users/2",
-			expectedHTTPStatus: http.StatusOK,
+			expectedHTTPStatus: http.StatusNoContent,
 			user: domain.User{
 				ID:        2,
 				AccountID: 1,
@@ -396,6 +417,18 @@ func TestDELETEUser(t *testing.T) {
 			setupFunc:    tests.DBDeleteSetupHelper,
 			teardownFunc: tests.DBCallTeardownHelper,
 		},
+		{
+			// A retried DELETE finds nothing to delete, it must have the same result as the first
+			testName:           "testDeleteNonExistUser",
+			shouldPass:         true,
+			url:                "/users/100",
+			expectedHTTPStatus: http.StatusNoContent,
+			user: domain.User{
+				ID: 100,
+			},
+			setupFunc:    tests.DBDeleteNonExistingRowSetupHelper,
+			teardownFunc: tests.DBCallTeardownHelper,
+		},
 		{
 			testName:           "testDeleteUserFailed",
 			shouldPass:         false,
@@ -412,6 +445,105 @@ func TestDELETEUser(t *testing.T) {
 			setupFunc:    tests.DBDeleteErrorSetupHelper,
 			teardownFunc: tests.DBCallTeardownHelper,
 		},
+		{
+			testName:           "testDeleteUserForbidden",
+			shouldPass:         false,
+			url:                "/users/2",
+			expectedHTTPStatus: http.StatusForbidden,
+			user: domain.User{
+				ID:        2,
+				AccountID: 1,
+				Name:      "mickey dolenz",
+				EMail:     "mickeyd@gmail.com",
+				Role:      1,
+				Password:  "myawesomepassword",
+			},
+			caller:       &auth.Caller{UserID: 3, AccountID: 1, Role: domain.Restricted},
+			setupFunc:    tests.DBDeleteUnauthorizedSetupHelper,
+			teardownFunc: tests.DBCallTeardownHelper,
+		},
+		{
+			// The account's primary user can't be deleted while the account has other users
+			testName:           "testDeleteUserBlocked",
+			shouldPass:         false,
+			url:                "/users/1",
+			expectedHTTPStatus: http.StatusConflict,
+			user: domain.User{
+				ID: 1,
+			},
+			setupFunc:    tests.DBDeleteBlockedSetupHelper,
+			teardownFunc: tests.DBCallTeardownHelper,
+		},
+		{
+			testName:           "testDeleteUserSuccessorQuery",
+			shouldPass:         true,
+			url:                "/users/1?successor=2",
+			expectedHTTPStatus: http.StatusNoContent,
+			user: domain.User{
+				ID: 1,
+			},
+			setupFunc: func(t *testing.T, _ domain.User) (*sql.DB, sqlmock.Sqlmock) {
+				return tests.DBDeleteWithSuccessorSetupHelper(t)
+			},
+			teardownFunc: tests.DBCallTeardownHelper,
+		},
+		{
+			testName:           "testDeleteUserSuccessorBody",
+			shouldPass:         true,
+			url:                "/users/1",
+			body:               `{"successor": 2}`,
+			expectedHTTPStatus: http.StatusNoContent,
+			user: domain.User{
+				ID: 1,
+			},
+			setupFunc: func(t *testing.T, _ domain.User) (*sql.DB, sqlmock.Sqlmock) {
+				return tests.DBDeleteWithSuccessorSetupHelper(t)
+			},
+			teardownFunc: tests.DBCallTeardownHelper,
+		},
+		{
+			// The successor must be a user of the same account
+			testName:           "testDeleteUserSuccessorOtherAccount",
+			shouldPass:         false,
+			url:                "/users/1?successor=2",
+			expectedHTTPStatus: http.StatusConflict,
+			user: domain.User{
+				ID: 1,
+			},
+			setupFunc: func(t *testing.T, _ domain.User) (*sql.DB, sqlmock.Sqlmock) {
+				return tests.DBDeleteWithSuccessorOtherAccountSetupHelper(t)
+			},
+			teardownFunc: tests.DBCallTeardownHelper,
+		},
+		{
+			testName:           "testDeleteUserInvalidSuccessor",
+			shouldPass:         false,
+			url:                "/users/1?successor=abc",
+			expectedHTTPStatus: http.StatusBadRequest,
+			user: domain.User{
+				ID: 1,
+			},
+			setupFunc: func(t *testing.T, _ domain.User) (*sql.DB, sqlmock.Sqlmock) {
+				dbase, mock, _ := tests.DBCallNoExpectationsSetupHelper(t)
+				return dbase, mock
+			},
+			teardownFunc: tests.DBCallTeardownHelper,
+		},
+		{
+			testName:           "testDeleteUserInvalidSuccessorBody",
+			shouldPass:         false,
+			url:                "/users/1",
+			body:               `{"successor": "abc"}`,
+			expectedHTTPStatus: http.StatusBadRequest,
+			user: domain.User{
+				ID: 1,
+			},
+			setupFunc: func(t *testing.T, _ domain.User) (*sql.DB, sqlmock.Sqlmock) {
+				dbase, mock, _ := tests.DBCallNoExpectationsSetupHelper(t)
+				return dbase, mock
+			},
+			teardownFunc: tests.DBCallTeardownHelper,
+		},
 	}
 
 	for _, tc := range tcs {
@@ -423,17 +555,17 @@ func TestDELETEUser(t *testing.T) {
 			}
 			defer dbase.Close()
 
-			userSvc, err := services.NewUserSvc(ut, logger, 10)
+			userSvc, err := services.NewUserSvc(ut, logger, 10, 10, 10)
 			if err != nil {
 				t.Fatalf("error %s was not expected when getting UserSvc", err)
 			}
 
-			srvHandler, err := NewUserHandler(userSvc, logger, 10)
+			srvHandler, err := NewUserHandler(userSvc, logger, 10, false, domain.QueryTimeout{})
 			if err != nil {
 				t.Fatalf("error '%s' was not expected when getting a user handler", err)
 			}
 
-			testSrv := httptest.NewServer(http.HandlerFunc(srvHandler.ServeHTTP))
+			testSrv := httptest.NewServer(withCaller(srvHandler, tc.caller))
 			defer testSrv.Close()
 
 			// NOTE: As there is no http.DELETE creating an update request/DELETE requires
@@ -443,7 +575,7 @@ func TestDELETEUser(t *testing.T) {
 			//
 			// Kind of round-about, but it works
 			url := testSrv.URL + tc.url
-			req, err := http.NewRequest(http.MethodDelete, url, bytes.NewBuffer([]byte{}))
+			req, err := http.NewRequest(http.MethodDelete, url, bytes.NewBufferString(tc.body))
 			if err != nil && tc.shouldPass {
 				t.Fatalf("an error '%s' was not expected creating HTTP request", err)
 			}
@@ -470,6 +602,8 @@ func TestGetAllUsers(t *testing.T) {
 		setupFunc          func(*testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.Users)
 		teardownFunc       func(*testing.T, sqlmock.Sqlmock)
 		expectedHTTPStatus int
+		// reversed indicates the users are expected in the reverse of the repository's order
+		reversed bool
 	}{
 		{
 			testName:           "testGetAllUsersSuccess",
@@ -487,6 +621,34 @@ func TestGetAllUsers(t *testing.T) {
 			teardownFunc:       tests.DBCallTeardownHelper,
 			expectedHTTPStatus: http.StatusOK,
 		},
+		{
+			testName:           "testGetAllUsersSortDescending",
+			url:                "/users?sort=-id",
+			shouldPass:         true,
+			setupFunc:          tests.DBCallSetupHelper,
+			teardownFunc:       tests.DBCallTeardownHelper,
+			expectedHTTPStatus: http.StatusOK,
+			reversed:           true,
+		},
+		{
+			testName:           "testGetAllUsersSortByUpdatedAt",
+			url:                "/users?sort=updatedat",
+			shouldPass:         true,
+			setupFunc:          tests.DBCallSetupHelper,
+			teardownFunc:       tests.DBCallTeardownHelper,
+			expectedHTTPStatus: http.StatusOK,
+		},
+		{
+			testName:   "testGetAllUsersInvalidSort",
+			url:        "/users?sort=name",
+			shouldPass: false,
+			setupFunc: func(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.Users) {
+				dbase, mock, _ := tests.DBCallNoExpectationsSetupHelper(t)
+				return dbase, mock, nil
+			},
+			teardownFunc:       tests.DBCallTeardownHelper,
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
 		{
 			testName:           "testGetAllUsersQueryFailure",
 			url:                "/users",
@@ -514,12 +676,12 @@ func TestGetAllUsers(t *testing.T) {
 			}
 			defer dbase.Close()
 
-			userSvc, err := services.NewUserSvc(ut, logger, 10)
+			userSvc, err := services.NewUserSvc(ut, logger, 10, 10, 10)
 			if err != nil {
 				t.Fatalf("error %s was not expected when getting UserSvc", err)
 			}
 
-			userHandler, err := NewUserHandler(userSvc, logger, 10)
+			userHandler, err := NewUserHandler(userSvc, logger, 10, false, domain.QueryTimeout{})
 			if err != nil {
 				t.Fatalf("error '%s' was not expected when getting a user handler", err)
 			}
@@ -544,6 +706,11 @@ func TestGetAllUsers(t *testing.T) {
 				for _, user := range expected.Users {
 					user.HREF = "/users/" + strconv.Itoa(user.ID)
 				}
+				if tc.reversed {
+					for i, j := 0, len(expected.Users)-1; i < j; i, j = i+1, j-1 {
+						expected.Users[i], expected.Users[j] = expected.Users[j], expected.Users[i]
+					}
+				}
 
 				actual, err := ioutil.ReadAll(resp.Body)
 				if err != nil {
@@ -568,105 +735,1312 @@ func TestGetAllUsers(t *testing.T) {
 	}
 }
 
-func TestGetUser(t *testing.T) {
+func TestGetAllUsersConditional(t *testing.T) {
+	type validators struct {
+		etag         string
+		lastModified string
+	}
+	start := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+
 	tcs := []struct {
-		testName           string
-		url                string
-		shouldPass         bool
-		setupFunc          func(*testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.User)
-		teardownFunc       func(*testing.T, sqlmock.Sqlmock)
+		testName string
+		url      string
+		// headers returns the conditional request headers given the validators of the unmodified users
+		headers func(v validators) map[string]string
+		// modify changes the users after the validators were retrieved
+		modify             func(repo *memory.UserTable, clk *clock.Frozen)
 		expectedHTTPStatus int
 	}{
 		{
-			testName:           "testGetUserSuccess",
-			url:                "/users/1",
-			shouldPass:         true,
-			setupFunc:          tests.GetUserSetupHelper,
-			teardownFunc:       tests.DBCallTeardownHelper,
+			testName:           "testIfNoneMatchUnchanged",
+			url:                "/users",
+			headers:            func(v validators) map[string]string { return map[string]string{"If-None-Match": v.etag} },
+			expectedHTTPStatus: http.StatusNotModified,
+		},
+		{
+			testName:           "testIfNoneMatchAny",
+			url:                "/users",
+			headers:            func(v validators) map[string]string { return map[string]string{"If-None-Match": "*"} },
+			expectedHTTPStatus: http.StatusNotModified,
+		},
+		{
+			testName: "testIfNoneMatchList",
+			url:      "/users",
+			headers: func(v validators) map[string]string {
+				return map[string]string{"If-None-Match": `W/"1-1", ` + v.etag}
+			},
+			expectedHTTPStatus: http.StatusNotModified,
+		},
+		{
+			testName: "testIfNoneMatchUpdated",
+			url:      "/users",
+			headers:  func(v validators) map[string]string { return map[string]string{"If-None-Match": v.etag} },
+			modify: func(repo *memory.UserTable, clk *clock.Frozen) {
+				clk.Advance(time.Minute)
+				repo.UpdateUser(domain.User{ID: 1, AccountID: 1, Name: "micky dolenz", EMail: "mickey@monkees.com", Role: domain.Primary, Password: "pw"})
+			},
 			expectedHTTPStatus: http.StatusOK,
 		},
 		{
-			testName:           "testGetUserURLTooLong",
-			url:                "/users/1/extraNode",
-			shouldPass:         false,
-			setupFunc:          tests.DBCallNoExpectationsSetupHelper,
-			teardownFunc:       tests.DBCallTeardownHelper,
-			expectedHTTPStatus: http.StatusBadRequest,
+			testName:           "testIfNoneMatchDeleted",
+			url:                "/users",
+			headers:            func(v validators) map[string]string { return map[string]string{"If-None-Match": v.etag} },
+			modify:             func(repo *memory.UserTable, clk *clock.Frozen) { repo.DeleteUser(1) },
+			expectedHTTPStatus: http.StatusOK,
 		},
 		{
-			testName:           "testGetUserURLNonNumericID",
-			url:                "/users/notanumber",
-			shouldPass:         false,
-			setupFunc:          tests.DBCallNoExpectationsSetupHelper,
-			teardownFunc:       tests.DBCallTeardownHelper,
-			expectedHTTPStatus: http.StatusBadRequest,
+			testName:           "testIfNoneMatchOtherSort",
+			url:                "/users?sort=-id",
+			headers:            func(v validators) map[string]string { return map[string]string{"If-None-Match": v.etag} },
+			expectedHTTPStatus: http.StatusOK,
 		},
 		{
-			testName:           "testGetUserErrNoRow",
-			url:                "/users/notanumber",
-			shouldPass:         false,
-			setupFunc:          tests.DBCallNoExpectationsSetupHelper,
-			teardownFunc:       tests.DBCallTeardownHelper,
+			testName:           "testIfNoneMatchInvalidSort",
+			url:                "/users?sort=name",
+			headers:            func(v validators) map[string]string { return map[string]string{"If-None-Match": "*"} },
 			expectedHTTPStatus: http.StatusBadRequest,
 		},
+		{
+			testName: "testIfModifiedSinceUnchanged",
+			url:      "/users",
+			headers: func(v validators) map[string]string {
+				return map[string]string{"If-Modified-Since": v.lastModified}
+			},
+			expectedHTTPStatus: http.StatusNotModified,
+		},
+		{
+			testName: "testIfModifiedSinceUpdated",
+			url:      "/users",
+			headers: func(v validators) map[string]string {
+				return map[string]string{"If-Modified-Since": v.lastModified}
+			},
+			modify: func(repo *memory.UserTable, clk *clock.Frozen) {
+				clk.Advance(time.Minute)
+				repo.CreateUser(domain.User{AccountID: 1, Name: "peter tork", EMail: "peter@monkees.com", Role: domain.Restricted, Password: "pw"})
+			},
+			expectedHTTPStatus: http.StatusOK,
+		},
+		{
+			testName: "testIfModifiedSinceInvalid",
+			url:      "/users",
+			headers: func(v validators) map[string]string {
+				return map[string]string{"If-Modified-Since": "yesterday"}
+			},
+			expectedHTTPStatus: http.StatusOK,
+		},
+		{
+			testName: "testIfNoneMatchPrecedence",
+			url:      "/users",
+			headers: func(v validators) map[string]string {
+				return map[string]string{"If-None-Match": `W/"1-1"`, "If-Modified-Since": v.lastModified}
+			},
+			expectedHTTPStatus: http.StatusOK,
+		},
 	}
 
 	for _, tc := range tcs {
 		t.Run(tc.testName, func(t *testing.T) {
-			dbase, mock, expected := tc.setupFunc(t)
-			ut, err := db.NewTable(dbase)
-			if err != nil {
-				t.Fatalf("error creating user table instance: %s", err)
-			}
-			defer dbase.Close()
+			clk := clock.NewFrozen(start)
+			repo := memory.NewUserTable()
+			repo.SetClock(clk)
+			repo.CreateUser(domain.User{AccountID: 1, Name: "mickey dolenz", EMail: "mickey@monkees.com", Role: domain.Primary, Password: "pw"})
+			clk.Advance(time.Minute)
+			repo.CreateUser(domain.User{AccountID: 1, Name: "davy jones", EMail: "davy@monkees.com", Role: domain.Restricted, Password: "pw"})
 
-			userSvc, err := services.NewUserSvc(ut, logger, 10)
+			userSvc, err := services.NewUserSvc(repo, logger, 10, 10, 10)
 			if err != nil {
 				t.Fatalf("error %s was not expected when getting UserSvc", err)
 			}
+			userHandler, err := NewUserHandler(userSvc, logger, 10, false, domain.QueryTimeout{})
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting a user handler", err)
+			}
 
-			// populate User.HREF from User.ID
-			if expected != nil {
-				expected.HREF = tc.url
+			rr := httptest.NewRecorder()
+			userHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users", nil))
+			v := validators{etag: rr.Header().Get("ETag"), lastModified: rr.Header().Get("Last-Modified")}
+			if v.etag == "" || v.lastModified != clk.Now().Format(http.TimeFormat) {
+				t.Fatalf("expected ETag and Last-Modified %s headers, got %+v", clk.Now().Format(http.TimeFormat), v)
 			}
 
-			userHandler, err := NewUserHandler(userSvc, logger, 10)
-			if err != nil {
-				t.Fatalf("error '%s' was not expected when getting a user handler", err)
+			if tc.modify != nil {
+				tc.modify(repo, clk)
 			}
 
-			testSrv := httptest.NewServer(http.HandlerFunc(userHandler.ServeHTTP))
-			defer testSrv.Close()
+			r := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			for name, value := range tc.headers(v) {
+				r.Header.Set(name, value)
+			}
+			rr = httptest.NewRecorder()
+			userHandler.ServeHTTP(rr, r)
 
-			url := testSrv.URL + tc.url
-			resp, err := http.Get(url)
-			if err != nil {
-				t.Fatalf("an error '%s' was not expected calling accountd server", err)
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Fatalf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, rr.Code)
 			}
-			defer resp.Body.Close()
+			switch rr.Code {
+			case http.StatusNotModified:
+				if rr.Body.Len() != 0 {
+					t.Errorf("expected an empty body, got %s", rr.Body.String())
+				}
+				if rr.Header().Get("ETag") != v.etag {
+					t.Errorf("expected ETag %s, got %s", v.etag, rr.Header().Get("ETag"))
+				}
+			case http.StatusOK:
+				if tc.modify != nil && rr.Header().Get("ETag") == v.etag {
+					t.Errorf("expected an ETag other than %s", v.etag)
+				}
+			}
+		})
+	}
+}
 
-			status := resp.StatusCode
+// newPagingHandler returns a user handler whose repository has 'n' active users with IDs 1 through 'n'
+func newPagingHandler(t *testing.T, n int) (http.Handler, *memory.UserTable) {
+	repo := memory.NewUserTable()
+	for i := 1; i <= n; i++ {
+		repo.CreateUser(domain.User{AccountID: 1, Name: "user" + strconv.Itoa(i), EMail: strconv.Itoa(i) + "@monkees.com", Role: domain.Restricted, Password: "pw"})
+	}
+	userSvc, err := services.NewUserSvc(repo, logger, 10, 10, 10)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting UserSvc", err)
+	}
+	userHandler, err := NewUserHandler(userSvc, logger, 10, false, domain.QueryTimeout{})
+	if err != nil {
+		t.Fatalf("error '%s' was not expected when getting a user handler", err)
+	}
+	return userHandler, repo
+}
+
+// getPage returns the users page at 'url'
+func getPage(t *testing.T, h http.Handler, url string) (int, domain.Users, http.Header) {
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, url, nil))
+	page := domain.Users{}
+	if rr.Code == http.StatusOK {
+		if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+			t.Fatalf("error %s was not expected unmarshaling %s", err, rr.Body.String())
+		}
+	}
+	return rr.Code, page, rr.Header()
+}
+
+// pageIDs returns the IDs of the users in 'page'
+func pageIDs(page domain.Users) []int {
+	ids := []int{}
+	for _, u := range page.Users {
+		ids = append(ids, u.ID)
+	}
+	return ids
+}
+
+func TestGetUsersPage(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		url                string
+		expectedHTTPStatus int
+		expectedIDs        []int
+		expectNext         bool
+	}{
+		{testName: "testFirstPage", url: "/users?limit=2", expectedHTTPStatus: http.StatusOK, expectedIDs: []int{1, 2}, expectNext: true},
+		{testName: "testNextPage", url: "/users?limit=2&pagetoken=" + pageToken(2), expectedHTTPStatus: http.StatusOK, expectedIDs: []int{3, 4}, expectNext: true},
+		{testName: "testLastPage", url: "/users?limit=2&pagetoken=" + pageToken(4), expectedHTTPStatus: http.StatusOK, expectedIDs: []int{5}},
+		{testName: "testExactLastPage", url: "/users?limit=5", expectedHTTPStatus: http.StatusOK, expectedIDs: []int{1, 2, 3, 4, 5}},
+		{testName: "testDefaultLimit", url: "/users?pagetoken=" + pageToken(3), expectedHTTPStatus: http.StatusOK, expectedIDs: []int{4, 5}},
+		{testName: "testSortByID", url: "/users?limit=1&sort=id", expectedHTTPStatus: http.StatusOK, expectedIDs: []int{1}, expectNext: true},
+		{testName: "testOtherSort", url: "/users?limit=2&sort=-id", expectedHTTPStatus: http.StatusBadRequest},
+		{testName: "testZeroLimit", url: "/users?limit=0", expectedHTTPStatus: http.StatusBadRequest},
+		{testName: "testExcessiveLimit", url: "/users?limit=1001", expectedHTTPStatus: http.StatusBadRequest},
+		{testName: "testNonNumericLimit", url: "/users?limit=all", expectedHTTPStatus: http.StatusBadRequest},
+		{testName: "testInvalidToken", url: "/users?pagetoken=bogus", expectedHTTPStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			userHandler, _ := newPagingHandler(t, 5)
+
+			status, page, header := getPage(t, userHandler, tc.url)
 			if status != tc.expectedHTTPStatus {
-				t.Errorf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, status)
+				t.Fatalf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, status)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			if !reflect.DeepEqual(pageIDs(page), tc.expectedIDs) {
+				t.Errorf("expected users %v, got %v", tc.expectedIDs, pageIDs(page))
 			}
+			if (page.Next != "") != tc.expectNext {
+				t.Errorf("expected a next page token %t, got %q", tc.expectNext, page.Next)
+			}
+			if page.Next != "" && !strings.HasSuffix(page.NextHREF, "pagetoken="+page.Next) {
+				t.Errorf("expected the next page's HREF to include its token %s, got %s", page.Next, page.NextHREF)
+			}
+			if header.Get("ETag") != "" {
+				t.Errorf("expected no ETag for a page, got %s", header.Get("ETag"))
+			}
+		})
+	}
+}
 
-			if tc.shouldPass {
-				actual, err := ioutil.ReadAll(resp.Body)
-				if err != nil {
-					t.Fatalf("an error '%s' was not expected reading response body", err)
-				}
+func TestGetUsersPageConcurrentChanges(t *testing.T) {
+	userHandler, repo := newPagingHandler(t, 5)
 
-				mExpected, err := json.Marshal(expected)
-				if err != nil {
-					t.Fatalf("an error '%s' was not expected Marshaling %+v", err, expected)
-				}
+	// Users created and deleted while paging mustn't cause other users to be skipped or repeated
+	seen := map[int]int{}
+	url := "/users?limit=2"
+	for i := 0; url != ""; i++ {
+		status, page, _ := getPage(t, userHandler, url)
+		if status != http.StatusOK {
+			t.Fatalf("expected StatusCode = %d, got %d", http.StatusOK, status)
+		}
+		for _, id := range pageIDs(page) {
+			seen[id]++
+		}
+		if i == 0 {
+			repo.DeleteUser(1)
+			repo.CreateUser(domain.User{AccountID: 1, Name: "user6", EMail: "6@monkees.com", Role: domain.Restricted, Password: "pw"})
+		}
+		// The next page's HREF is followed, as a client would
+		url = page.NextHREF
+	}
 
-				if bytes.Compare(mExpected, actual) != 0 {
-					t.Errorf("expected %+v, got %+v", string(mExpected), string(actual))
+	expected := map[int]int{1: 1, 2: 1, 3: 1, 4: 1, 5: 1, 6: 1}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Errorf("expected each user once %v, got %v", expected, seen)
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		url                string
+		expectedHTTPStatus int
+		expectedIDs        []int
+	}{
+		{testName: "testSearchName", url: "/users/search?q=USER1", expectedHTTPStatus: http.StatusOK, expectedIDs: []int{1}},
+		{testName: "testSearchEmail", url: "/users/search?q=monkees", expectedHTTPStatus: http.StatusOK, expectedIDs: []int{1, 2, 3}},
+		{testName: "testSearchLimit", url: "/users/search/?q=monkees&limit=2", expectedHTTPStatus: http.StatusOK, expectedIDs: []int{1, 2}},
+		{testName: "testSearchNoMatch", url: "/users/search?q=dolenz", expectedHTTPStatus: http.StatusOK, expectedIDs: []int{}},
+		{testName: "testSearchNoQuery", url: "/users/search", expectedHTTPStatus: http.StatusBadRequest},
+		{testName: "testSearchBlankQuery", url: "/users/search?q=%20", expectedHTTPStatus: http.StatusBadRequest},
+		{testName: "testSearchInvalidLimit", url: "/users/search?q=user&limit=0", expectedHTTPStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			userHandler, _ := newPagingHandler(t, 3)
+
+			status, page, _ := getPage(t, userHandler, tc.url)
+			if status != tc.expectedHTTPStatus {
+				t.Fatalf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, status)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			if !reflect.DeepEqual(pageIDs(page), tc.expectedIDs) {
+				t.Errorf("expected users %v, got %v", tc.expectedIDs, pageIDs(page))
+			}
+			for _, u := range page.Users {
+				if u.HREF != "/users/"+strconv.Itoa(u.ID) {
+					t.Errorf("expected HREF /users/%d, got %s", u.ID, u.HREF)
 				}
 			}
+		})
+	}
+}
+
+func TestGetAllUsersQueryTimeout(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		qt                 domain.QueryTimeout
+		expectedHTTPStatus int
+		expectTruncated    bool
+	}{
+		{
+			testName:           "testQueryTimedOut",
+			qt:                 domain.QueryTimeout{Timeout: 10 * time.Millisecond},
+			expectedHTTPStatus: http.StatusGatewayTimeout,
+		},
+		{
+			testName:           "testQueryTimedOutPartial",
+			qt:                 domain.QueryTimeout{Timeout: 10 * time.Millisecond, Partial: true},
+			expectedHTTPStatus: http.StatusOK,
+			expectTruncated:    true,
+		},
+		{
+			testName:           "testQueryWithinTimeout",
+			qt:                 domain.QueryTimeout{Timeout: time.Minute, Partial: true},
+			expectedHTTPStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, _, _ := tests.DBCallSlowQuerySetupHelper(t)
+			defer dbase.Close()
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			userSvc, err := services.NewUserSvc(ut, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			userHandler, err := NewUserHandler(userSvc, logger, 10, false, tc.qt)
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting a user handler", err)
+			}
+
+			status, users, header := getPage(t, userHandler, "/users")
+			if status != tc.expectedHTTPStatus {
+				t.Fatalf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, status)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			if users.Truncated != tc.expectTruncated {
+				t.Errorf("expected truncated %t, got %t", tc.expectTruncated, users.Truncated)
+			}
+			// Truncated users aren't a version of the users so they mustn't have validators
+			if tc.expectTruncated && header.Get("ETag") != "" {
+				t.Errorf("expected no ETag for truncated users, got %s", header.Get("ETag"))
+			}
+			if !tc.expectTruncated && header.Get("ETag") == "" {
+				t.Errorf("expected an ETag")
+			}
+		})
+	}
+}
+
+func TestBulkDryRun(t *testing.T) {
+	const (
+		newUsers = `{"users":[{"accountid":1,"name":"peter tork","email":"petert@gmail.com","role":1,"password":"pw"},` +
+			`{"accountid":1,"name":"mike nesmith","email":"miken@gmail.com","role":1,"password":"pw"}]}`
+		dupUsers = `{"users":[{"accountid":1,"name":"peter tork","email":"petert@gmail.com","role":1,"password":"pw"},` +
+			`{"accountid":1,"name":"peter tork","email":"petert@gmail.com","role":1,"password":"pw"}]}`
+		missingUsers = `{"users":[{"accountid":1,"id":99,"name":"peter tork","email":"petert@gmail.com","role":1,"password":"pw"}]}`
+		newUser      = `{"accountid":1,"name":"peter tork","email":"petert@gmail.com","role":1,"password":"pw"}`
+	)
+
+	tcs := []struct {
+		testName           string
+		method             string
+		url                string
+		header             map[string]string
+		body               string
+		expectedHTTPStatus int
+		expectedDryRun     bool
+		expectedStored     int
+	}{
+		{
+			testName:           "testDryRunQueryParam",
+			method:             http.MethodPost,
+			url:                "/users?dryRun=true",
+			header:             map[string]string{"Bulk-Request": "true"},
+			body:               newUsers,
+			expectedHTTPStatus: http.StatusOK,
+			expectedDryRun:     true,
+		},
+		{
+			testName:           "testDryRunHeader",
+			method:             http.MethodPost,
+			url:                "/users",
+			header:             map[string]string{"Bulk-Request": "true", "Bulk-DryRun": "true"},
+			body:               dupUsers,
+			expectedHTTPStatus: http.StatusConflict,
+			expectedDryRun:     true,
+		},
+		{
+			testName:           "testDryRunPUT",
+			method:             http.MethodPut,
+			url:                "/users?dryRun=true",
+			header:             map[string]string{"Bulk-Request": "true"},
+			body:               missingUsers,
+			expectedHTTPStatus: http.StatusConflict,
+			expectedDryRun:     true,
+		},
+		{
+			testName:           "testNotDryRun",
+			method:             http.MethodPost,
+			url:                "/users?dryRun=false",
+			header:             map[string]string{"Bulk-Request": "true"},
+			body:               newUsers,
+			expectedHTTPStatus: http.StatusCreated,
+			expectedStored:     2,
+		},
+		{
+			testName:           "testDryRunNotBulk",
+			method:             http.MethodPost,
+			url:                "/users?dryRun=true",
+			body:               newUser,
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+		{
+			testName:           "testDryRunInvalid",
+			method:             http.MethodPost,
+			url:                "/users",
+			header:             map[string]string{"Bulk-Request": "true", "Bulk-DryRun": "maybe"},
+			body:               newUsers,
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			h, repo := newPagingHandler(t, 2)
+
+			rqst := httptest.NewRequest(tc.method, tc.url, bytes.NewBufferString(tc.body))
+			for k, v := range tc.header {
+				rqst.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, rqst)
+
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Fatalf("expected StatusCode = %d, got %d: %s", tc.expectedHTTPStatus, rr.Code, rr.Body.String())
+			}
+			if tc.expectedHTTPStatus != http.StatusBadRequest {
+				var resp services.BulkResponse
+				if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("error %s was not expected unmarshaling the response", err)
+				}
+				if resp.DryRun != tc.expectedDryRun {
+					t.Errorf("expected DryRun %t, got %t", tc.expectedDryRun, resp.DryRun)
+				}
+			}
+
+			stored := 0
+			for id := 3; id <= 4; id++ {
+				if u, _ := repo.GetUser(id); u != nil {
+					stored++
+				}
+			}
+			if stored != tc.expectedStored {
+				t.Errorf("expected %d users to be created, got %d", tc.expectedStored, stored)
+			}
+		})
+	}
+}
+
+func TestBulkVerbosity(t *testing.T) {
+	const newUsers = `{"users":[{"accountid":1,"name":"peter tork","email":"petert@gmail.com","role":1,"password":"pw"},` +
+		`{"accountid":1,"name":"mike nesmith","email":"miken@gmail.com","role":1,"password":"pw"}]}`
+
+	tcs := []struct {
+		testName           string
+		url                string
+		header             map[string]string
+		expectedHTTPStatus int
+		// expectedFields are the fields of each result's user
+		expectedFields []string
+	}{
+		{
+			testName:           "testDefaultVerbosity",
+			url:                "/users",
+			expectedHTTPStatus: http.StatusCreated,
+			expectedFields:     []string{"email", "id", "name"},
+		},
+		{
+			testName:           "testIDsQueryParam",
+			url:                "/users?verbosity=ids",
+			expectedHTTPStatus: http.StatusCreated,
+			expectedFields:     []string{"id"},
+		},
+		{
+			testName:           "testFullHeader",
+			url:                "/users",
+			header:             map[string]string{"Bulk-Verbosity": "full"},
+			expectedHTTPStatus: http.StatusCreated,
+			expectedFields:     []string{"accountid", "createdat", "email", "href", "id", "name", "role", "status", "updatedat"},
+		},
+		{
+			testName:           "testDryRunFull",
+			url:                "/users?dryRun=true&verbosity=full",
+			expectedHTTPStatus: http.StatusOK,
+			expectedFields:     []string{"accountid", "createdat", "email", "href", "id", "name", "role", "status", "updatedat"},
+		},
+		{
+			testName:           "testInvalidVerbosity",
+			url:                "/users?verbosity=everything",
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			h, _ := newPagingHandler(t, 2)
+
+			rqst := httptest.NewRequest(http.MethodPost, tc.url, bytes.NewBufferString(newUsers))
+			rqst.Header.Set("Bulk-Request", "true")
+			for k, v := range tc.header {
+				rqst.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, rqst)
+
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Fatalf("expected StatusCode = %d, got %d: %s", tc.expectedHTTPStatus, rr.Code, rr.Body.String())
+			}
+			if tc.expectedHTTPStatus == http.StatusBadRequest {
+				return
+			}
+
+			resp := struct {
+				Results []struct {
+					ErrMsg  *string                `json:"errmsg"`
+					ErrCode *int                   `json:"errcode"`
+					User    map[string]interface{} `json:"user"`
+				} `json:"results"`
+			}{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("error %s was not expected unmarshaling the response", err)
+			}
+			if len(resp.Results) != 2 {
+				t.Fatalf("expected 2 results, got %d", len(resp.Results))
+			}
+			for _, result := range resp.Results {
+				// The error fields are omitted from successful results
+				if result.ErrMsg != nil || result.ErrCode != nil {
+					t.Errorf("expected no errmsg or errcode, got %s", rr.Body.String())
+				}
+				fields := []string{}
+				for f := range result.User {
+					fields = append(fields, f)
+				}
+				sort.Strings(fields)
+				if !reflect.DeepEqual(fields, tc.expectedFields) {
+					t.Errorf("expected user fields %v, got %v", tc.expectedFields, fields)
+				}
+			}
+		})
+	}
+}
+
+func TestBulkDELETE(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		body               string
+		bulkHeader         string
+		expectedHTTPStatus int
+		expectedIDs        []int
+		expectedRemaining  int
+	}{
+		{
+			// Deleting a user that doesn't exist, e.g., user 5, succeeds
+			testName:           "testBulkDeleteSuccess",
+			body:               `{"ids":[3,1,5]}`,
+			bulkHeader:         "true",
+			expectedHTTPStatus: http.StatusOK,
+			expectedIDs:        []int{3, 1, 5},
+			expectedRemaining:  1,
+		},
+		{
+			testName:           "testBulkDeleteMalformedJSON",
+			body:               `{"ids":[3,`,
+			bulkHeader:         "true",
+			expectedHTTPStatus: http.StatusBadRequest,
+			expectedRemaining:  3,
+		},
+		{
+			testName:           "testBulkDeleteUnknownField",
+			body:               `{"users":[3]}`,
+			bulkHeader:         "true",
+			expectedHTTPStatus: http.StatusBadRequest,
+			expectedRemaining:  3,
+		},
+		{
+			testName:           "testBulkDeleteInvalidHeader",
+			body:               `{"ids":[3]}`,
+			bulkHeader:         "maybe",
+			expectedHTTPStatus: http.StatusBadRequest,
+			expectedRemaining:  3,
+		},
+		{
+			testName:           "testDeleteUsersNotBulk",
+			body:               `{"ids":[3]}`,
+			bulkHeader:         "false",
+			expectedHTTPStatus: http.StatusBadRequest,
+			expectedRemaining:  3,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			h, repo := newPagingHandler(t, 3)
+
+			rqst := httptest.NewRequest(http.MethodDelete, "/users", bytes.NewBufferString(tc.body))
+			rqst.Header.Set("Bulk-Request", tc.bulkHeader)
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, rqst)
+
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Fatalf("expected StatusCode = %d, got %d: %s", tc.expectedHTTPStatus, rr.Code, rr.Body.String())
+			}
+			if users, _ := repo.GetUsers(); len(users.Users) != tc.expectedRemaining {
+				t.Errorf("expected %d remaining users, got %d", tc.expectedRemaining, len(users.Users))
+			}
+			if tc.expectedIDs == nil {
+				return
+			}
+
+			resp := struct {
+				OverallStatus services.Status `json:"overallstatus"`
+				Results       []struct {
+					Status services.Status        `json:"status"`
+					User   map[string]interface{} `json:"user"`
+				} `json:"results"`
+			}{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("error %s was not expected unmarshaling the response", err)
+			}
+			if resp.OverallStatus != services.StatusOK || len(resp.Results) != len(tc.expectedIDs) {
+				t.Fatalf("expected %d successful results, got %s", len(tc.expectedIDs), rr.Body.String())
+			}
+			for i, result := range resp.Results {
+				// Only the ID of each user is included
+				if result.Status != services.StatusOK || len(result.User) != 1 || result.User["id"] != float64(tc.expectedIDs[i]) {
+					t.Errorf("expected result %d to be a deleted user %d, got %+v", i, tc.expectedIDs[i], result)
+				}
+			}
+		})
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		url                string
+		shouldPass         bool
+		setupFunc          func(*testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.User)
+		teardownFunc       func(*testing.T, sqlmock.Sqlmock)
+		expectedHTTPStatus int
+	}{
+		{
+			testName:           "testGetUserSuccess",
+			url:                "/users/1",
+			shouldPass:         true,
+			setupFunc:          tests.GetUserSetupHelper,
+			teardownFunc:       tests.DBCallTeardownHelper,
+			expectedHTTPStatus: http.StatusOK,
+		},
+		{
+			testName:           "testGetUserURLTooLong",
+			url:                "/users/1/extraNode",
+			shouldPass:         false,
+			setupFunc:          tests.DBCallNoExpectationsSetupHelper,
+			teardownFunc:       tests.DBCallTeardownHelper,
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+		{
+			testName:           "testGetUserURLNonNumericID",
+			url:                "/users/notanumber",
+			shouldPass:         false,
+			setupFunc:          tests.DBCallNoExpectationsSetupHelper,
+			teardownFunc:       tests.DBCallTeardownHelper,
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+		{
+			testName:           "testGetUserErrNoRow",
+			url:                "/users/notanumber",
+			shouldPass:         false,
+			setupFunc:          tests.DBCallNoExpectationsSetupHelper,
+			teardownFunc:       tests.DBCallTeardownHelper,
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, expected := tc.setupFunc(t)
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			defer dbase.Close()
+
+			userSvc, err := services.NewUserSvc(ut, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+
+			// populate User.HREF from User.ID
+			if expected != nil {
+				expected.HREF = tc.url
+			}
+
+			userHandler, err := NewUserHandler(userSvc, logger, 10, false, domain.QueryTimeout{})
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting a user handler", err)
+			}
+
+			testSrv := httptest.NewServer(http.HandlerFunc(userHandler.ServeHTTP))
+			defer testSrv.Close()
+
+			url := testSrv.URL + tc.url
+			resp, err := http.Get(url)
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected calling accountd server", err)
+			}
+			defer resp.Body.Close()
+
+			status := resp.StatusCode
+			if status != tc.expectedHTTPStatus {
+				t.Errorf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, status)
+			}
+
+			if tc.shouldPass {
+				actual, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatalf("an error '%s' was not expected reading response body", err)
+				}
+
+				mExpected, err := json.Marshal(expected)
+				if err != nil {
+					t.Fatalf("an error '%s' was not expected Marshaling %+v", err, expected)
+				}
+
+				if bytes.Compare(mExpected, actual) != 0 {
+					t.Errorf("expected %+v, got %+v", string(mExpected), string(actual))
+				}
+
+				lastModified := expected.UpdatedAt.Format(http.TimeFormat)
+				if resp.Header.Get("Last-Modified") != lastModified {
+					t.Errorf("expected Last-Modified %s, got %s", lastModified, resp.Header.Get("Last-Modified"))
+				}
+				eTag := `W/"` + strconv.Itoa(expected.ID) + "-" + strconv.FormatInt(expected.UpdatedAt.Unix(), 10) + `"`
+				if resp.Header.Get("ETag") != eTag {
+					t.Errorf("expected ETag %s, got %s", eTag, resp.Header.Get("ETag"))
+				}
+			}
+
+			// we make sure that all post-conditions were met
+			tests.DBCallTeardownHelper(t, mock)
+		})
+	}
+}
+
+func TestPOSTUserWriteBehind(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		expectedHTTPStatus int
+		expectedResourceID string
+		postData           string
+		user               domain.User
+		setupFunc          func(*testing.T, domain.User) (*sql.DB, sqlmock.Sqlmock)
+		teardownFunc       func(*testing.T, sqlmock.Sqlmock)
+	}{
+		{
+			testName:           "testEnqueueUserSuccess",
+			expectedHTTPStatus: http.StatusAccepted,
+			expectedResourceID: "/users/pending/7",
+			postData: `
+				{
+					"AccountID":1,
+					"Name":"mickey dolenz",
+					"eMail":"mickeyd@gmail.com",
+					"role":1,
+					"password":"myawesomepassword"
+				}
+				`,
+			user: domain.User{
+				AccountID: 1,
+				Name:      "mickey dolenz",
+				EMail:     "mickeyd@gmail.com",
+				Role:      1,
+				Password:  "myawesomepassword",
+			},
+			setupFunc:    tests.DBEnqueueSetupHelper,
+			teardownFunc: tests.DBCallTeardownHelper,
+		},
+		{
+			testName:           "testEnqueueUserFailMissingPassword",
+			expectedHTTPStatus: http.StatusBadRequest,
+			postData: `
+				{
+					"AccountID":1,
+					"Name":"mickey dolenz",
+					"eMail":"mickeyd@gmail.com",
+					"role":1
+				}
+				`,
+			setupFunc:    tests.DBNoCallSetupHelper,
+			teardownFunc: tests.DBCallTeardownHelper,
+		},
+		{
+			testName:           "testEnqueueUserDBError",
+			expectedHTTPStatus: http.StatusInternalServerError,
+			postData: `
+				{
+					"AccountID":1,
+					"Name":"mickey dolenz",
+					"eMail":"mickeyd@gmail.com",
+					"role":1,
+					"password":"myawesomepassword"
+				}
+				`,
+			user: domain.User{
+				AccountID: 1,
+				Name:      "mickey dolenz",
+				EMail:     "mickeyd@gmail.com",
+				Role:      1,
+				Password:  "myawesomepassword",
+			},
+			setupFunc:    tests.DBEnqueueErrorSetupHelper,
+			teardownFunc: tests.DBCallTeardownHelper,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock := tc.setupFunc(t, tc.user)
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			qt, err := db.NewQueueTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating queue table instance: %s", err)
+			}
+			defer dbase.Close()
+
+			userSvc, err := services.NewUserSvc(ut, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			userSvc.EnableWriteBehind(qt)
+
+			srvHandler, err := NewUserHandler(userSvc, logger, 10, true, domain.QueryTimeout{})
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting a user handler", err)
+			}
+
+			testSrv := httptest.NewServer(http.HandlerFunc(srvHandler.ServeHTTP))
+			defer testSrv.Close()
+
+			resp, err := http.Post(testSrv.URL+"/users", "application/json", bytes.NewBuffer([]byte(tc.postData)))
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected calling accountd server", err)
+			}
+			defer resp.Body.Close()
+
+			status := resp.StatusCode
+			if status != tc.expectedHTTPStatus {
+				t.Errorf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, status)
+			}
+
+			resourceURL := resp.Header.Get("Location")
+			if resourceURL != tc.expectedResourceID {
+				t.Errorf("expected resource %s, got %s", tc.expectedResourceID, resourceURL)
+			}
+
+			tc.teardownFunc(t, mock)
+		})
+	}
+}
+
+func TestGetQueuedUser(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		url                string
+		shouldPass         bool
+		setupFunc          func(*testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.QueuedUser)
+		expectedHTTPStatus int
+	}{
+		{
+			testName:           "testGetQueuedUserComplete",
+			url:                "/users/pending/7",
+			shouldPass:         true,
+			setupFunc:          tests.DBGetQueuedUserSetupHelper,
+			expectedHTTPStatus: http.StatusOK,
+		},
+		{
+			testName:           "testGetQueuedUserNotFound",
+			url:                "/users/pending/7",
+			shouldPass:         false,
+			setupFunc:          tests.DBGetQueuedUserErrNoRowsSetupHelper,
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:           "testGetQueuedUserURLNonNumericID",
+			url:                "/users/pending/notanumber",
+			shouldPass:         false,
+			setupFunc:          tests.DBQueueNoCallSetupHelper,
+			expectedHTTPStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, expected := tc.setupFunc(t)
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			qt, err := db.NewQueueTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating queue table instance: %s", err)
+			}
+			defer dbase.Close()
+
+			userSvc, err := services.NewUserSvc(ut, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			userSvc.EnableWriteBehind(qt)
+
+			userHandler, err := NewUserHandler(userSvc, logger, 10, true, domain.QueryTimeout{})
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting a user handler", err)
+			}
+
+			testSrv := httptest.NewServer(http.HandlerFunc(userHandler.ServeHTTP))
+			defer testSrv.Close()
+
+			resp, err := http.Get(testSrv.URL + tc.url)
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected calling accountd server", err)
+			}
+			defer resp.Body.Close()
+
+			status := resp.StatusCode
+			if status != tc.expectedHTTPStatus {
+				t.Errorf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, status)
+			}
+
+			if tc.shouldPass {
+				actual := domain.QueuedUser{}
+				err = json.NewDecoder(resp.Body).Decode(&actual)
+				if err != nil {
+					t.Fatalf("an error '%s' was not expected decoding response body", err)
+				}
+				if actual.Status != expected.Status {
+					t.Errorf("expected status %s, got %s", expected.Status, actual.Status)
+				}
+				if actual.HREF != tc.url {
+					t.Errorf("expected HREF %s, got %s", tc.url, actual.HREF)
+				}
+				if actual.UserHREF != "/users/"+strconv.Itoa(expected.UserID) {
+					t.Errorf("expected UserHREF /users/%d, got %s", expected.UserID, actual.UserHREF)
+				}
+			}
+
+			tests.DBCallTeardownHelper(t, mock)
+		})
+	}
+}
+
+func TestGetInactiveUsers(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		url                string
+		expectedHTTPStatus int
+		expectedIDs        []int
+	}{
+		{testName: "testInactiveDays", url: "/users?inactiveSince=2d", expectedHTTPStatus: http.StatusOK, expectedIDs: []int{1, 3}},
+		{testName: "testInactiveDuration", url: "/users?inactiveSince=12h", expectedHTTPStatus: http.StatusOK, expectedIDs: []int{1, 2, 3}},
+		{testName: "testNoneInactive", url: "/users?inactiveSince=90d", expectedHTTPStatus: http.StatusOK, expectedIDs: []int{}},
+		{testName: "testZeroPeriod", url: "/users?inactiveSince=0d", expectedHTTPStatus: http.StatusBadRequest},
+		{testName: "testNegativePeriod", url: "/users?inactiveSince=-1h", expectedHTTPStatus: http.StatusBadRequest},
+		{testName: "testExcessivePeriod", url: "/users?inactiveSince=1000000d", expectedHTTPStatus: http.StatusBadRequest},
+		{testName: "testInvalidPeriod", url: "/users?inactiveSince=3w", expectedHTTPStatus: http.StatusBadRequest},
+		{testName: "testMissingPeriod", url: "/users?inactiveSince=", expectedHTTPStatus: http.StatusBadRequest},
+		{testName: "testInactivePaged", url: "/users?inactiveSince=2d&limit=1", expectedHTTPStatus: http.StatusBadRequest},
+	}
+
+	// The users were created 10 days ago, user 2 logged in yesterday
+	now := time.Now()
+	clk := clock.NewFrozen(now.Add(-10 * 24 * time.Hour))
+	repo := memory.NewUserTable()
+	if err := repo.SetClock(clk); err != nil {
+		t.Fatalf("error %s was not expected setting the clock", err)
+	}
+	for i := 1; i <= 3; i++ {
+		repo.CreateUser(domain.User{AccountID: 1, Name: "user" + strconv.Itoa(i), EMail: strconv.Itoa(i) + "@monkees.com", Role: domain.Restricted, Password: "pw"})
+	}
+	clk.Advance(9 * 24 * time.Hour)
+	repo.RecordLogin(2)
+	userSvc, err := services.NewUserSvc(repo, logger, 10, 10, 10)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting UserSvc", err)
+	}
+	userHandler, err := NewUserHandler(userSvc, logger, 10, false, domain.QueryTimeout{})
+	if err != nil {
+		t.Fatalf("error '%s' was not expected when getting a user handler", err)
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			status, page, header := getPage(t, userHandler, tc.url)
+			if status != tc.expectedHTTPStatus {
+				t.Fatalf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, status)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			if !reflect.DeepEqual(pageIDs(page), tc.expectedIDs) {
+				t.Errorf("expected users %v, got %v", tc.expectedIDs, pageIDs(page))
+			}
+			for _, u := range page.Users {
+				if u.HREF != "/users/"+strconv.Itoa(u.ID) {
+					t.Errorf("expected HREF /users/%d, got %s", u.ID, u.HREF)
+				}
+				if (u.LastLogin != nil) != (u.ID == 2) {
+					t.Errorf("expected only user 2 to have a lastlogin, user %d has %v", u.ID, u.LastLogin)
+				}
+			}
+			if header.Get("ETag") != "" {
+				t.Errorf("expected no ETag for the inactive users, got %s", header.Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestActivateUser(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		url                string
+		expectedHTTPStatus int
+		setupFunc          func(*testing.T) (*sql.DB, sqlmock.Sqlmock)
+	}{
+		{
+			testName:           "testActivateUserSuccess",
+			url:                "/users/1/activate?token=goodtoken",
+			expectedHTTPStatus: http.StatusOK,
+			setupFunc:          tests.DBActivateUserRecordLoginSetupHelper,
+		},
+		{
+			testName:           "testActivateUserBadToken",
+			url:                "/users/1/activate?token=badtoken",
+			expectedHTTPStatus: http.StatusBadRequest,
+			setupFunc:          tests.DBActivateUserNoMatchSetupHelper,
+		},
+		{
+			testName:           "testActivateUserMissingToken",
+			url:                "/users/1/activate",
+			expectedHTTPStatus: http.StatusBadRequest,
+			setupFunc:          tests.DBActivateUserNoCallSetupHelper,
+		},
+		{
+			testName:           "testActivateUserNonNumericID",
+			url:                "/users/notanumber/activate?token=goodtoken",
+			expectedHTTPStatus: http.StatusBadRequest,
+			setupFunc:          tests.DBActivateUserNoCallSetupHelper,
+		},
+		{
+			testName:           "testActivateUserDBError",
+			url:                "/users/1/activate?token=goodtoken",
+			expectedHTTPStatus: http.StatusInternalServerError,
+			setupFunc:          tests.DBActivateUserErrorSetupHelper,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock := tc.setupFunc(t)
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			defer dbase.Close()
+
+			userSvc, err := services.NewUserSvc(ut, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+
+			srvHandler, err := NewUserHandler(userSvc, logger, 10, false, domain.QueryTimeout{})
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting a user handler", err)
+			}
+
+			testSrv := httptest.NewServer(http.HandlerFunc(srvHandler.ServeHTTP))
+			defer testSrv.Close()
+
+			resp, err := http.Post(testSrv.URL+tc.url, "application/json", nil)
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected calling accountd server", err)
+			}
+			defer resp.Body.Close()
+
+			status := resp.StatusCode
+			if status != tc.expectedHTTPStatus {
+				t.Errorf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, status)
+			}
+
+			tests.DBCallTeardownHelper(t, mock)
+		})
+	}
+}
+
+func TestGetChanges(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		url                string
+		expectedHTTPStatus int
+		expectedNext       int64
+		expectedUserIDs    []int
+	}{
+		{testName: "testGetChangesLatest", url: "/users/changes", expectedHTTPStatus: http.StatusOK, expectedNext: 2, expectedUserIDs: []int{}},
+		{testName: "testGetChangesFromStart", url: "/users/changes?since=0", expectedHTTPStatus: http.StatusOK, expectedNext: 2, expectedUserIDs: []int{1, 2}},
+		{testName: "testGetChangesAfterSeq", url: "/users/changes/?since=1", expectedHTTPStatus: http.StatusOK, expectedNext: 2, expectedUserIDs: []int{2}},
+		{testName: "testGetChangesNoneTimeout", url: "/users/changes?since=2", expectedHTTPStatus: http.StatusOK, expectedNext: 2, expectedUserIDs: []int{}},
+		{testName: "testGetChangesUnknownSeq", url: "/users/changes?since=3", expectedHTTPStatus: http.StatusGone},
+		{testName: "testGetChangesInvalidSeq", url: "/users/changes?since=bogus", expectedHTTPStatus: http.StatusBadRequest},
+		{testName: "testGetChangesNegativeSeq", url: "/users/changes?since=-1", expectedHTTPStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			repo := memory.NewUserTable()
+			repo.CreateUser(domain.User{AccountID: 1, Name: "mickey dolenz", EMail: "mickey@monkees.com", Role: domain.Primary, Password: "pw"})
+			repo.CreateUser(domain.User{AccountID: 1, Name: "davy jones", EMail: "davy@monkees.com", Role: domain.Restricted, Password: "pw"})
+
+			userSvc, err := services.NewUserSvc(repo, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			changes, err := services.NewChangeLog(10, 10*time.Millisecond)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting ChangeLog", err)
+			}
+			userSvc.SetChangeLog(changes)
+			for _, u := range []domain.User{
+				{ID: 1, AccountID: 1, Name: "micky dolenz", EMail: "mickey@monkees.com", Role: domain.Primary, Password: "pw"},
+				{ID: 2, AccountID: 1, Name: "davy jones", EMail: "davyj@monkees.com", Role: domain.Restricted, Password: "pw"},
+			} {
+				if mvErr := userSvc.UpdateUser(context.Background(), u); mvErr != nil {
+					t.Fatalf("error %s was not expected updating user %d", mvErr, u.ID)
+				}
+			}
+
+			userHandler, err := NewUserHandler(userSvc, logger, 10, false, domain.QueryTimeout{})
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting a user handler", err)
+			}
+
+			rr := httptest.NewRecorder()
+			userHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.url, nil))
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Fatalf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, rr.Code)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+
+			var actual domain.UserChanges
+			if err = json.NewDecoder(rr.Body).Decode(&actual); err != nil {
+				t.Fatalf("an error '%s' was not expected decoding the response body", err)
+			}
+			userIDs := []int{}
+			for _, c := range actual.Changes {
+				userIDs = append(userIDs, c.UserID)
+			}
+			if actual.Next != tc.expectedNext || !reflect.DeepEqual(userIDs, tc.expectedUserIDs) {
+				t.Errorf("expected changes to users %v, next %d, got %v, next %d", tc.expectedUserIDs, tc.expectedNext, userIDs, actual.Next)
+			}
+			if rr.Header().Get("Cache-Control") != "no-store" {
+				t.Errorf("expected Cache-Control no-store, got %q", rr.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestUserRoute(t *testing.T) {
+	tcs := []struct {
+		path     string
+		expected string
+	}{
+		{path: "/users", expected: "/users"},
+		{path: "/users/", expected: "/users"},
+		{path: "/users/42", expected: "/users/{id}"},
+		{path: "/users/42/", expected: "/users/{id}"},
+		{path: "/users/pending/7", expected: "/users/pending/{id}"},
+		{path: "/users/changes", expected: "/users/changes"},
+		{path: "/users/search", expected: "/users/search"},
+		{path: "/users/42/activate", expected: "/users/{id}/activate"},
+		{path: "/users/pending", expected: respond.UnmatchedRoute},
+		{path: "/users/42/bogus", expected: respond.UnmatchedRoute},
+		{path: "/users/1/2/3", expected: respond.UnmatchedRoute},
+	}
+
+	for _, tc := range tcs {
+		if route := userRoute(tc.path); route != tc.expected {
+			t.Errorf("expected route %s for path %s, got %s", tc.expected, tc.path, route)
+		}
+	}
+}
+
+func TestDeprecationHeaders(t *testing.T) {
+	u := domain.User{AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: 1, Password: "myawesomepassword"}
+	getAll := func(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+		dbase, mock, _ := tests.DBCallSetupHelper(t)
+		return dbase, mock
+	}
+	insert := func(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+		return tests.DBInsertSetupHelper(t, u)
+	}
+
+	tcs := []struct {
+		testName          string
+		method            string
+		url               string
+		body              string
+		setupFunc         func(*testing.T) (*sql.DB, sqlmock.Sqlmock)
+		expectDeprecation bool
+	}{
+		{
+			testName:          "testGetAllUsersUnpaged",
+			method:            http.MethodGet,
+			url:               "/users",
+			setupFunc:         getAll,
+			expectDeprecation: true,
+		},
+		{
+			testName:  "testInsertUserLowercaseKeys",
+			method:    http.MethodPost,
+			url:       "/users",
+			body:      `{"accountid":1,"name":"mickey dolenz","email":"mickeyd@gmail.com","role":1,"password":"myawesomepassword"}`,
+			setupFunc: insert,
+		},
+		{
+			testName:          "testInsertUserLegacyCasing",
+			method:            http.MethodPost,
+			url:               "/users",
+			body:              `{"AccountID":1,"Name":"mickey dolenz","eMail":"mickeyd@gmail.com","role":1,"password":"myawesomepassword"}`,
+			setupFunc:         insert,
+			expectDeprecation: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock := tc.setupFunc(t)
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			defer dbase.Close()
+
+			userSvc, err := services.NewUserSvc(ut, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			srvHandler, err := NewUserHandler(userSvc, logger, 10, false, domain.QueryTimeout{})
+			if err != nil {
+				t.Fatalf("error '%s' was not expected when getting a user handler", err)
+			}
+
+			r := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			srvHandler.ServeHTTP(w, r)
+
+			if w.Code >= http.StatusBadRequest {
+				t.Fatalf("expected a successful request, got status %d", w.Code)
+			}
+			deprecated := w.Header().Get("Deprecation") == "true" && w.Header().Get("Warning") != ""
+			if deprecated != tc.expectDeprecation {
+				t.Errorf("expected deprecation %t, got headers %v", tc.expectDeprecation, w.Header())
+			}
 
-			// we make sure that all post-conditions were met
 			tests.DBCallTeardownHelper(t, mock)
 		})
 	}
@@ -5,16 +5,12 @@
 package integrationtests
 
 import (
-	"context"
 	"encoding/json"
-	"log"
 	"strings"
 	"testing"
-	"time"
 
 	"github.com/golang/protobuf/ptypes/empty"
 	pb "github.com/youngkin/mockvideo/cmd/accountd/grpc/users"
-	"google.golang.org/grpc"
 )
 
 type CallType int
@@ -42,17 +38,7 @@ func TestGetUsersGRPC(t *testing.T) {
 		return
 	}
 
-	// Takes a while for the accountd container to start
-	time.Sleep(500 * time.Millisecond)
-
-	opts := grpc.WithInsecure()
-	cc, err := grpc.Dial("localhost:5000", opts)
-	if err != nil {
-		log.Fatalf("\terror %s attempting to connect to Accountd gRPC server", err)
-	}
-	defer cc.Close()
-
-	client := pb.NewUserServerClient(cc)
+	client := userServerClient(t)
 
 	tcs := []struct {
 		testName           string
@@ -84,13 +70,15 @@ func TestGetUsersGRPC(t *testing.T) {
 		t.Run(tc.testName, func(t *testing.T) {
 			switch tc.callType {
 			case GETUSERS:
-				resp, err := client.GetUsers(context.Background(), &empty.Empty{})
+				resp, err := client.GetUsers(rpcContext(t), &empty.Empty{})
 				testPreconditions(t, resp, err, tc.shouldPass)
 				if tc.shouldPass {
 					actual, err := json.Marshal(resp)
 					if err != nil {
 						t.Fatalf("error '%s' was not expected while marshaling %v", err, resp)
 					}
+					actual = normalizeTimestamps(actual)
+
 					if *update {
 						updateGoldenFile(t, tc.testName, string(actual))
 					}
@@ -102,13 +90,15 @@ func TestGetUsersGRPC(t *testing.T) {
 					}
 				}
 			case GETUSER:
-				resp, err := client.GetUser(context.Background(), tc.userID)
+				resp, err := client.GetUser(rpcContext(t), tc.userID)
 				testPreconditions(t, resp, err, tc.shouldPass)
 				if tc.shouldPass {
 					actual, err := json.Marshal(resp)
 					if err != nil {
 						t.Fatalf("error '%s' was not expected while marshaling %v", err, resp)
 					}
+					actual = normalizeTimestamps(actual)
+
 					if *update {
 						updateGoldenFile(t, tc.testName, string(actual))
 					}
@@ -131,17 +121,7 @@ func TestAddUpdateDeleteUserGRPC(t *testing.T) {
 		return
 	}
 
-	// Takes a while for the accountd container to start
-	time.Sleep(500 * time.Millisecond)
-
-	opts := grpc.WithInsecure()
-	cc, err := grpc.Dial("localhost:5000", opts)
-	if err != nil {
-		log.Fatalf("\terror %s attempting to connect to Accountd gRPC server", err)
-	}
-	defer cc.Close()
-
-	client := pb.NewUserServerClient(cc)
+	client := userServerClient(t)
 
 	tcs := []struct {
 		testName   string
@@ -219,11 +199,11 @@ func TestAddUpdateDeleteUserGRPC(t *testing.T) {
 			var err error
 			switch tc.callType {
 			case CREATEUSER:
-				id, err = client.CreateUser(context.Background(), tc.rqstData)
+				id, err = client.CreateUser(rpcContext(t), tc.rqstData)
 			case UPDATEUSER:
-				_, err = client.UpdateUser(context.Background(), tc.rqstData)
+				_, err = client.UpdateUser(rpcContext(t), tc.rqstData)
 			case DELETEUSER:
-				_, err = client.DeleteUser(context.Background(), tc.expectedID)
+				_, err = client.DeleteUser(rpcContext(t), tc.expectedID)
 			}
 
 			testPreconditions(t, id, err, tc.shouldPass)
@@ -236,7 +216,7 @@ func TestAddUpdateDeleteUserGRPC(t *testing.T) {
 			}
 
 			if tc.shouldPass {
-				resp, err := client.GetUser(context.Background(), id)
+				resp, err := client.GetUser(rpcContext(t), id)
 				if err != nil {
 					t.Fatalf("error '%s' was not expected calling accountd server", err)
 				}
@@ -246,6 +226,8 @@ func TestAddUpdateDeleteUserGRPC(t *testing.T) {
 					t.Fatalf("error '%s' was not expected while marshaling %v", err, resp)
 				}
 
+				actual = normalizeTimestamps(actual)
+
 				if *update {
 					updateGoldenFile(t, tc.testName, string(actual))
 				}
@@ -266,17 +248,7 @@ func TestBulkAddUpdateUserGRPC(t *testing.T) {
 		return
 	}
 
-	// Takes a while for the accountd container to start
-	time.Sleep(500 * time.Millisecond)
-
-	opts := grpc.WithInsecure()
-	cc, err := grpc.Dial("localhost:5000", opts)
-	if err != nil {
-		log.Fatalf("\terror %s attempting to connect to Accountd gRPC server", err)
-	}
-	defer cc.Close()
-
-	client := pb.NewUserServerClient(cc)
+	client := userServerClient(t)
 
 	tcs := []struct {
 		testName              string
@@ -404,9 +376,9 @@ func TestBulkAddUpdateUserGRPC(t *testing.T) {
 			var err error
 			switch tc.callType {
 			case CREATEUSER:
-				resp, err = client.CreateUsers(context.Background(), tc.rqstData)
+				resp, err = client.CreateUsers(rpcContext(t), tc.rqstData)
 			case UPDATEUSER:
-				resp, err = client.UpdateUsers(context.Background(), tc.rqstData)
+				resp, err = client.UpdateUsers(rpcContext(t), tc.rqstData)
 			}
 
 			testPreconditions(t, resp, err, tc.shouldPass)
@@ -423,7 +395,7 @@ func TestBulkAddUpdateUserGRPC(t *testing.T) {
 				var actual string
 
 				for _, result := range resp.Response {
-					u, err := client.GetUser(context.Background(), result.UserID)
+					u, err := client.GetUser(rpcContext(t), result.UserID)
 					if err != nil {
 						t.Fatalf("error '%s' was not expected calling accountd server", err)
 					}
@@ -9,8 +9,8 @@
 //
 // It can also be run manually if the following requirements are met:
 //	1.	A running gRPC accountd service listening on 'localhost:5000' which 'may' be started using:
-//		mockvideo/cmd/accountd/accountd -configFile "testdata/config/config" -secretsDir "testdata/secrets" -protocol "grpc" &
-//	2.	MySQL running on port 3306 or 6603 depending on the 'dbPort' value in mockvideo/cmd/accountd/testdata/config/config
+//		mockvideo/cmd/accountd/accountd -configFile "testdata/config/config" -env dev -secretsDir "testdata/secrets" -protocol "grpc" &
+//	2.	MySQL running on port 3306 or 6603 depending on the 'dbPort' value in mockvideo/cmd/accountd/testdata/config/config.dev
 
 package main
 
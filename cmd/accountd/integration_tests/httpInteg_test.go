@@ -31,19 +31,19 @@ func TestGetUsers(t *testing.T) {
 	}{
 		{
 			testName:           "testGetAllUsersSuccess",
-			url:                "http://localhost:5000/users",
+			url:                "http://" + accountdAddr + "/users",
 			shouldPass:         true,
 			expectedHTTPStatus: http.StatusOK,
 		},
 		{
 			testName:           "testGetOneUsersSuccess",
-			url:                "http://localhost:5000/users/1",
+			url:                "http://" + accountdAddr + "/users/1",
 			shouldPass:         true,
 			expectedHTTPStatus: http.StatusOK,
 		},
 		{
 			testName:           "testGetAllUsersUnexpectedResource",
-			url:                "http://localhost:5000/unexpectedresource",
+			url:                "http://" + accountdAddr + "/unexpectedresource",
 			shouldPass:         false,
 			expectedHTTPStatus: http.StatusBadRequest,
 		},
@@ -68,6 +68,8 @@ func TestGetUsers(t *testing.T) {
 					t.Fatalf("an error '%s' was not expected reading response body", err)
 				}
 
+				actual = normalizeTimestamps(actual)
+
 				if *update {
 					updateGoldenFile(t, tc.testName, string(actual))
 				}
@@ -104,11 +106,11 @@ func TestPOSTPUTDELETEUser(t *testing.T) {
 			testName:                 "testPOSTUserSuccess",
 			shouldPass:               true,
 			method:                   http.MethodPost,
-			url:                      "http://localhost:5000/users",
+			url:                      "http://" + accountdAddr + "/users",
 			expectedHTTPStatus:       http.StatusCreated,
 			expectedGETSTatus:        http.StatusOK,
 			expectedResourceLocation: "/users/6",
-			newResourceURL:           "http://localhost:5000/users/6",
+			newResourceURL:           "http://" + accountdAddr + "/users/6",
 			rqstData: `{
 				"accountid":1,
 				"name":"Brian Wilson",
@@ -120,7 +122,7 @@ func TestPOSTPUTDELETEUser(t *testing.T) {
 			testName:                 "testPOSTDuplicateUserFailure", //Dup email address
 			shouldPass:               false,
 			method:                   http.MethodPost,
-			url:                      "http://localhost:5000/users",
+			url:                      "http://" + accountdAddr + "/users",
 			expectedHTTPStatus:       http.StatusBadRequest,
 			expectedGETSTatus:        http.StatusTeapot, // NA, shouldn't even test this
 			expectedResourceLocation: "",
@@ -136,11 +138,11 @@ func TestPOSTPUTDELETEUser(t *testing.T) {
 			testName:                 "testPUTUserSuccess",
 			shouldPass:               true,
 			method:                   http.MethodPut,
-			url:                      "http://localhost:5000/users/6",
+			url:                      "http://" + accountdAddr + "/users/6",
 			expectedHTTPStatus:       http.StatusOK,
 			expectedGETSTatus:        http.StatusOK,
 			expectedResourceLocation: "NA, this is a PUT, not a POST",
-			newResourceURL:           "http://localhost:5000/users/6",
+			newResourceURL:           "http://" + accountdAddr + "/users/6",
 			rqstData: `{
 				"accountid":1,
 				"id":6,
@@ -153,18 +155,18 @@ func TestPOSTPUTDELETEUser(t *testing.T) {
 			testName:                 "testDELETEUserSuccess",
 			shouldPass:               true,
 			method:                   http.MethodDelete,
-			url:                      "http://localhost:5000/users/6",
+			url:                      "http://" + accountdAddr + "/users/6",
 			expectedHTTPStatus:       http.StatusOK,
 			expectedGETSTatus:        http.StatusNotFound,
 			expectedResourceLocation: "NA, this is a DELETE, not a POST",
-			newResourceURL:           "http://localhost:5000/users/6",
+			newResourceURL:           "http://" + accountdAddr + "/users/6",
 			rqstData:                 "",
 		},
 		{
 			testName:                 "testPUTNonExistingUserFailure",
 			shouldPass:               false,
 			method:                   http.MethodPut,
-			url:                      "http://localhost:5000/users/6",
+			url:                      "http://" + accountdAddr + "/users/6",
 			expectedHTTPStatus:       http.StatusBadRequest,
 			expectedGETSTatus:        http.StatusTeapot, // NA, shouldn't even test this
 			expectedResourceLocation: "",
@@ -222,6 +224,8 @@ func TestPOSTPUTDELETEUser(t *testing.T) {
 					t.Errorf("an error '%s' was not expected reading response body", err)
 				}
 
+				actual = normalizeTimestamps(actual)
+
 				if *update {
 					updateGoldenFile(t, tc.testName, string(actual))
 				}
@@ -267,7 +271,7 @@ func TestBulkPOSTPUTUser(t *testing.T) {
 			testName:           "testBulkPOST1UserSuccess",
 			shouldPass:         true,
 			method:             http.MethodPost,
-			url:                "http://localhost:5000/users",
+			url:                "http://" + accountdAddr + "/users",
 			expectedHTTPStatus: http.StatusCreated,
 			expectedGETSTatus:  http.StatusOK,
 			shouldContain:      []string{"BeachBoy Mike Love"},
@@ -286,7 +290,7 @@ func TestBulkPOSTPUTUser(t *testing.T) {
 			testName:           "testBulkPUT1UserSuccess",
 			shouldPass:         true,
 			method:             http.MethodPut,
-			url:                "http://localhost:5000/users",
+			url:                "http://" + accountdAddr + "/users",
 			expectedHTTPStatus: http.StatusOK,
 			expectedGETSTatus:  http.StatusOK,
 			shouldContain:      []string{"Solo Mike Love"},
@@ -306,7 +310,7 @@ func TestBulkPOSTPUTUser(t *testing.T) {
 			testName:           "testBulkPOST2UsersSuccess",
 			shouldPass:         true,
 			method:             http.MethodPost,
-			url:                "http://localhost:5000/users",
+			url:                "http://" + accountdAddr + "/users",
 			expectedHTTPStatus: http.StatusCreated,
 			expectedGETSTatus:  http.StatusOK,
 			shouldContain:      []string{"Brian Wilson", "Frank Zappa"},
@@ -334,7 +338,7 @@ func TestBulkPOSTPUTUser(t *testing.T) {
 			testName:           "testBulkPOSTHeaderNotSet",
 			shouldPass:         false,
 			method:             http.MethodPost,
-			url:                "http://localhost:5000/users",
+			url:                "http://" + accountdAddr + "/users",
 			expectedHTTPStatus: http.StatusBadRequest,
 			expectedGETSTatus:  http.StatusTeapot, // NA, shouldn't even test this
 			shouldContain:      []string{"", ""},
@@ -360,7 +364,7 @@ func TestBulkPOSTPUTUser(t *testing.T) {
 			testName:           "testBulkPOSTHeaderInvalid",
 			shouldPass:         false,
 			method:             http.MethodPost,
-			url:                "http://localhost:5000/users",
+			url:                "http://" + accountdAddr + "/users",
 			expectedHTTPStatus: http.StatusBadRequest,
 			expectedGETSTatus:  http.StatusTeapot, // NA, shouldn't even test this
 			shouldContain:      []string{"Brian Wilson", "Frank Zappa"},
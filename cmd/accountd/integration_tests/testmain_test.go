@@ -5,15 +5,21 @@
 package integrationtests
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"syscall"
 	"testing"
 	"time"
+
+	pb "github.com/youngkin/mockvideo/cmd/accountd/grpc/users"
+	"google.golang.org/grpc"
 )
 
 const (
@@ -21,6 +27,14 @@ const (
 	buildFailed
 	initDBFailed
 	svcFailedToStart
+	grpcDialFailed
+)
+
+const (
+	// grpcDialTimeout bounds the wait for accountd's gRPC server to accept connections
+	grpcDialTimeout = 10 * time.Second
+	// rpcTimeout bounds each RPC made by a test, see rpcContext
+	rpcTimeout = 5 * time.Second
 )
 
 var (
@@ -28,26 +42,40 @@ var (
 	goldenFileDir    = "testdata"
 	goldenFileSuffix = ".golden"
 	protocol         = "http"
+	// accountdAddr is the host:port accountd is listening on, see startAccountdSvc
+	accountdAddr = "localhost:5000"
+	// grpcConn is the connection shared by the gRPC tests, it's only set when 'protocol' is 'grpc'.
+	// See userServerClient.
+	grpcConn *grpc.ClientConn
 )
 
 func TestMain(m *testing.M) {
 	accountdPID := setup()
 	code := m.Run()
+	accountdPID.Signal(syscall.SIGTERM) // accountd is run in the background, need to terminate it
 	if code != 0 {
 		teardown()
-		accountdPID.Signal(syscall.SIGTERM) // accountd is run in the background, need to terminate it
+		os.Exit(code)
 	}
 
 	// Rerun tests with protocol set to 'grpc'
-	accountdPID.Signal(syscall.SIGTERM)
 	protocol = "grpc"
 	err := initDB()
 	if err != nil {
+		teardown()
 		os.Exit(initDBFailed)
 	}
 	accountdPID = startAccountdSvc()
+	grpcConn, err = dialAccountd(accountdAddr, grpcDialTimeout)
+	if err != nil {
+		fmt.Println("Error:", err)
+		teardown()
+		accountdPID.Signal(syscall.SIGTERM)
+		os.Exit(grpcDialFailed)
+	}
 	code = m.Run()
 
+	grpcConn.Close()
 	teardown()
 	accountdPID.Signal(syscall.SIGTERM) // accountd is run in the background, need to terminate it
 	os.Exit(code)
@@ -74,11 +102,13 @@ func startAccountdSvc() *os.Process {
 	// Start accountd service
 	// Uncomment to run accoutd in docker. If this is uncommented the next 'dCmd := ...' line will have to
 	// be commented-out.
-	// dCmd := fmt.Sprintf("docker run --name accountd -d -p 5000:5000 -v %s/cmd/accountd/testdata:/opt/mockvideo/accountd local/accountd:latest", getBuildDir())
-	dCmd := fmt.Sprintf(`../accountd -configFile ../testdata/config/config -secretsDir ../testdata/secrets -protocol %s`, protocol)
+	// dCmd := fmt.Sprintf("docker run --name accountd -d -p 5000:5000 -e ACCOUNTD_ENV=dev -v %s/cmd/accountd/testdata:/opt/mockvideo/accountd local/accountd:latest", getBuildDir())
+	addrFile := filepath.Join(os.TempDir(), fmt.Sprintf("accountd-%s.addr", protocol))
+	os.Remove(addrFile)
+	dCmd := fmt.Sprintf(`../accountd -configFile ../testdata/config/config -env dev -secretsDir ../testdata/secrets -protocol %s -addrFile %s`, protocol, addrFile)
 	if _, found := os.LookupEnv("TRAVIS_BUILD_DIR"); found { // For Travis CI need to tweak config path
-		dCmd = fmt.Sprintf("%s/accountd -configFile %s/cmd/accountd/testdata/travis/config/config -secretsDir %s/cmd/accountd/testdata/travis/secrets -protocol %s",
-			getBuildDir(), getBuildDir(), getBuildDir(), protocol)
+		dCmd = fmt.Sprintf("%s/accountd -configFile %s/cmd/accountd/testdata/config/config -env travis -secretsDir %s/cmd/accountd/testdata/travis/secrets -protocol %s -addrFile %s",
+			getBuildDir(), getBuildDir(), getBuildDir(), protocol, addrFile)
 	}
 	fmt.Printf("\n\n%s\n\n", dCmd)
 	// Use 'startCmd()' here so accountd will be started in the background. We need this, the main goroutine,
@@ -87,12 +117,67 @@ func startAccountdSvc() *os.Process {
 	if err != nil {
 		os.Exit(svcFailedToStart)
 	}
-	// Pause while service starts
-	time.Sleep(time.Millisecond * 100)
+	// Wait for the service to start, it writes its listen address to 'addrFile' once it's
+	// accepting connections. The address may use any port, e.g., when the config has 'port=0'.
+	addr, err := waitForAddr(addrFile, 10*time.Second)
+	if err != nil {
+		fmt.Println("Error:", err)
+		p.Signal(syscall.SIGTERM)
+		os.Exit(svcFailedToStart)
+	}
+	accountdAddr = addr
 
 	return p
 }
 
+// waitForAddr returns the 'localhost:port' address accountd is listening on once it's been written
+// to 'addrFile'
+func waitForAddr(addrFile string, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		addr, err := ioutil.ReadFile(addrFile)
+		if err == nil {
+			_, port, err := net.SplitHostPort(string(addr))
+			if err != nil {
+				return "", err
+			}
+			return net.JoinHostPort("localhost", port), nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return "", fmt.Errorf("accountd didn't write its address to %s within %s", addrFile, timeout)
+}
+
+// dialAccountd returns a connection to accountd's gRPC server at 'addr' once the server is accepting
+// connections, or an error if it isn't within 'timeout'
+func dialAccountd(addr string, timeout time.Duration) (*grpc.ClientConn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cc, err := grpc.DialContext(ctx, addr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return nil, fmt.Errorf("accountd's gRPC server at %s wasn't ready within %s: %w", addr, timeout, err)
+	}
+	return cc, nil
+}
+
+// userServerClient returns a UserServerClient using the connection shared by the gRPC tests. It's
+// closed by TestMain, tests mustn't close it.
+func userServerClient(t *testing.T) pb.UserServerClient {
+	t.Helper()
+	if grpcConn == nil {
+		t.Fatal("no connection to accountd's gRPC server, it's only available when 'protocol' is 'grpc'")
+	}
+	return pb.NewUserServerClient(grpcConn)
+}
+
+// rpcContext returns the context for an RPC made by 't'. The RPC fails if it takes longer than
+// rpcTimeout, rather than hanging the suite. The context is cancelled when 't' completes.
+func rpcContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+	t.Cleanup(cancel)
+	return ctx
+}
+
 func setupDB(retries int) {
 	// Takes a while for the MySQL container to start
 	var err error
@@ -204,3 +289,14 @@ func readGoldenFile(t *testing.T, testName string) string {
 	}
 	return string(gfc)
 }
+
+// timestampRE matches the user timestamps in JSON encoded responses. They're
+// encoded as RFC 3339 strings by the HTTP API and as objects by the gRPC API.
+var timestampRE = regexp.MustCompile(`"(?i:(createdat|updatedat))":("[^"]*"|\{[^}]*\})`)
+
+// normalizeTimestamps replaces the user timestamps in 'actual' with a placeholder.
+// The timestamps are set by the database when users are created and updated so
+// they can't be captured in the golden files.
+func normalizeTimestamps(actual []byte) []byte {
+	return timestampRE.ReplaceAll(actual, []byte(`"$1":"<timestamp>"`))
+}
@@ -0,0 +1,372 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/blob"
+	userdb "github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/errsummary"
+	"github.com/youngkin/mockvideo/internal/eventbus"
+	"github.com/youngkin/mockvideo/internal/lifecycle"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"github.com/youngkin/mockvideo/internal/policy"
+	"google.golang.org/grpc"
+)
+
+// Overrides replace components of the object graph constructed by New, e.g., to use an
+// in-memory repository in tests or to add environment specific middleware. Zero valued
+// fields use the default components.
+type Overrides struct {
+	// UserRepository replaces ProvideUserRepository
+	UserRepository func(db *sql.DB) (domain.UserRepository, error)
+	// AccountRepository replaces ProvideAccountRepository
+	AccountRepository func(db *sql.DB, repo domain.UserRepository) (domain.AccountRepository, error)
+	// Middleware is applied to the HTTP handler, see ProvideHTTPHandler
+	Middleware []func(http.Handler) http.Handler
+	// Canary, if non-nil, replaces components of the canary that requests are routed to, see
+	// ProvideCanaryHandler. Only the components it replaces, and those that depend on them, are
+	// constructed for the canary, the rest are shared with the primary. Its Canary is ignored.
+	Canary *Overrides
+}
+
+// App contains accountd's fully constructed components
+type App struct {
+	UserSvc           *services.UserSvc
+	AccountSvc        *services.AccountSvc
+	Impersonations    *auth.Impersonations
+	ActivationExpirer *services.ActivationExpirer
+	// WriteBehindWorker is nil unless write-behind mode is enabled
+	WriteBehindWorker *services.WriteBehindWorker
+	HTTPHandler       http.Handler
+	GRPCServer        *grpc.Server
+	// ChangeLog must be closed before the HTTP server is shut down so long-polls don't delay it
+	ChangeLog *services.ChangeLog
+	// UserIndex is nil unless a search cluster is configured
+	UserIndex *services.ElasticsearchUserIndex
+	// EventBus is where user lifecycle events are published, see services.UserCreated
+	EventBus *eventbus.Bus
+	// Drain must be registered with the lifecycle manager after the servers so they report that
+	// they're no longer ready before they're stopped
+	Drain *lifecycle.Drain
+}
+
+// New constructs accountd's object graph from 'cfg'. 'db' is used by the default repositories
+// and may be nil if they are all replaced in 'overrides'.
+func New(cfg Config, db *sql.DB, logger logging.Logger, overrides Overrides) (*App, *mverr.MVError) {
+	// Every component logs its errors to the summary
+	errSummary, err := ProvideErrorSummary(cfg)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateHTTPHandlerErrorCode, mverr.UnableToCreateHTTPHandlerMsg, "unable to create an errsummary.Summary instance", err)
+	}
+	logger = errsummary.Logger(logger, errSummary)
+
+	queue, err := ProvideUserQueueRepository(cfg, db)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateRepositoryErrorCode, mverr.UnableToCreateRepositoryMsg, "unable to create a domain.UserQueueRepository instance", err)
+	}
+	deadLetters, err := ProvideDeadLetterRepository(db, queue)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateRepositoryErrorCode, mverr.UnableToCreateRepositoryMsg, "unable to create a domain.DeadLetterRepository instance", err)
+	}
+	repos, mvErr := newRepositories(cfg, db, queue, overrides)
+	if mvErr != nil {
+		return nil, mvErr
+	}
+
+	changeLog, err := ProvideChangeLog(cfg)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to create a services.ChangeLog instance", err)
+	}
+	eventBus, err := ProvideEventBus()
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to create an eventbus.Bus instance", err)
+	}
+	userSvc, err := ProvideUserSvc(cfg, repos.users, queue, deadLetters, repos.accounts, repos.uow, changeLog, eventBus, logger)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to create a services.UserSvc instance", err)
+	}
+	activationExpirer, err := ProvideActivationExpirer(cfg, userSvc, logger)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to create a services.ActivationExpirer instance", err)
+	}
+	writeBehindWorker, err := ProvideWriteBehindWorker(cfg, queue, userSvc, logger)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to create a services.WriteBehindWorker instance", err)
+	}
+	userIndex, err := ProvideUserIndex(cfg, userSvc, changeLog, logger)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to create a services.ElasticsearchUserIndex instance", err)
+	}
+	invoiceSvc, err := ProvideInvoiceSvc(cfg)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to create a services.BillingdInvoiceSvc instance", err)
+	}
+	usage, err := ProvideUsageTracker(cfg)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to create a services.UsageTracker instance", err)
+	}
+	accountSvc, err := ProvideAccountSvc(userSvc, invoiceSvc, usage, logger)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to create a services.AccountSvc instance", err)
+	}
+	impersonations, err := ProvideImpersonations(cfg, userSvc)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to create an auth.Impersonations instance", err)
+	}
+
+	apiKeys, err := ProvideAPIKeys(cfg)
+	if err != nil {
+		return nil, newError(mverr.UnableToLoadConfigErrorCode, mverr.UnableToLoadConfigMsg, "unable to load the API keys", err)
+	}
+	jwtKeys, err := ProvideJWTKeys(cfg, userSvc)
+	if err != nil {
+		return nil, newError(mverr.UnableToLoadConfigErrorCode, mverr.UnableToLoadConfigMsg, "unable to load the JWT signing keys", err)
+	}
+
+	engine, err := ProvidePolicyEngine(cfg, logger)
+	if err != nil {
+		return nil, newError(mverr.UnableToLoadConfigErrorCode, mverr.UnableToLoadConfigMsg, "unable to load the authorization policy", err)
+	}
+
+	store, err := ProvideBlobStore(cfg)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateHTTPHandlerErrorCode, mverr.UnableToCreateHTTPHandlerMsg, "unable to create a blob.Store instance", err)
+	}
+
+	exportSvc, err := ProvideExportSvc(cfg, userSvc, logger)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to create a services.ExportSvc instance", err)
+	}
+	// Requests must be authenticated when there are JWT keys, API keys, or impersonation tokens, the
+	// services deny any that reach them without a caller
+	if jwtKeys != nil || apiKeys != nil || impersonations != nil {
+		requireCallers(userSvc, accountSvc, exportSvc)
+	}
+
+	drain, err := ProvideDrain(cfg)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateHTTPHandlerErrorCode, mverr.UnableToCreateHTTPHandlerMsg, "unable to create a lifecycle.Drain instance", err)
+	}
+
+	statusBoard, err := ProvideStatusBoardSvc(cfg)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateHTTPHandlerErrorCode, mverr.UnableToCreateHTTPHandlerMsg, "unable to create a services.StatusBoardSvc instance", err)
+	}
+
+	httpHandler, err := ProvideHTTPHandler(cfg, userSvc, accountSvc, exportSvc, impersonations, apiKeys, jwtKeys, engine, store, deadLetters, repos.readOnly, drain, usage, eventBus, statusBoard, errSummary, logger, overrides.Middleware)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateHTTPHandlerErrorCode, mverr.UnableToCreateHTTPHandlerMsg, "unable to create the HTTP handler", err)
+	}
+	if overrides.Canary != nil {
+		primary := shared{
+			userSvc:        userSvc,
+			queue:          queue,
+			deadLetters:    deadLetters,
+			changeLog:      changeLog,
+			eventBus:       eventBus,
+			userIndex:      userIndex,
+			invoiceSvc:     invoiceSvc,
+			usage:          usage,
+			impersonations: impersonations,
+			apiKeys:        apiKeys,
+			jwtKeys:        jwtKeys,
+			engine:         engine,
+			store:          store,
+			drain:          drain,
+			statusBoard:    statusBoard,
+			errSummary:     errSummary,
+		}
+		canaryHandler, mvErr := newCanaryHandler(cfg, db, logger, *overrides.Canary, primary)
+		if mvErr != nil {
+			return nil, newError(mvErr.ErrCode, mvErr.ErrMsg, "unable to create the canary", mvErr)
+		}
+		httpHandler = ProvideCanaryHandler(cfg, httpHandler, canaryHandler)
+	}
+	grpcServer, err := ProvideGRPCServer(cfg, userSvc, apiKeys, jwtKeys, engine, drain, logger)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateRPCServerErrorCode, mverr.UnableToCreateRPCServerErrorMsg, "unable to create the gRPC server", err)
+	}
+
+	return &App{
+		UserSvc:           userSvc,
+		AccountSvc:        accountSvc,
+		Impersonations:    impersonations,
+		ActivationExpirer: activationExpirer,
+		WriteBehindWorker: writeBehindWorker,
+		HTTPHandler:       httpHandler,
+		GRPCServer:        grpcServer,
+		ChangeLog:         changeLog,
+		UserIndex:         userIndex,
+		EventBus:          eventBus,
+		Drain:             drain,
+	}, nil
+}
+
+// Start starts the App's background workers and registers them with 'lc' so they're stopped
+// when accountd shuts down
+func (a *App) Start(lc *lifecycle.Manager) {
+	// Closed once the servers and workers publishing events have stopped
+	lc.Register("event bus", lifecycle.Func(a.EventBus.Close))
+	a.ActivationExpirer.Start()
+	lc.Register("activation expirer", lifecycle.Func(a.ActivationExpirer.Stop))
+	if a.WriteBehindWorker != nil {
+		a.WriteBehindWorker.Start()
+		lc.Register("write-behind worker", lifecycle.Func(a.WriteBehindWorker.Stop))
+	}
+	if a.UserIndex != nil {
+		a.UserIndex.Start()
+		lc.Register("search index", lifecycle.Func(a.UserIndex.Stop))
+	}
+}
+
+// repositories are the repositories the services of an object graph use
+type repositories struct {
+	// users is protected by a circuit breaker and by read-only mode, see readOnly
+	users    domain.UserRepository
+	accounts domain.AccountRepository
+	uow      domain.UnitOfWork
+	readOnly *userdb.ReadOnlyRepository
+}
+
+// newRepositories constructs the user and account repositories, replacing them with those in
+// 'overrides', and the UnitOfWork spanning them and 'queue'
+func newRepositories(cfg Config, db *sql.DB, queue domain.UserQueueRepository, overrides Overrides) (*repositories, *mverr.MVError) {
+	provideUserRepository := ProvideUserRepository
+	if cfg.DemoMode {
+		provideUserRepository = ProvideDemoUserRepository
+	}
+	if overrides.UserRepository != nil {
+		provideUserRepository = overrides.UserRepository
+	}
+
+	repo, err := provideUserRepository(db)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateRepositoryErrorCode, mverr.UnableToCreateRepositoryMsg, "unable to create a domain.UserRepository instance", err)
+	}
+
+	provideAccountRepository := ProvideAccountRepository
+	if overrides.AccountRepository != nil {
+		provideAccountRepository = overrides.AccountRepository
+	}
+	accountRepo, err := provideAccountRepository(db, repo)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateRepositoryErrorCode, mverr.UnableToCreateRepositoryMsg, "unable to create a domain.AccountRepository instance", err)
+	}
+
+	uow, err := ProvideUnitOfWork(db, repo, queue, accountRepo)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateRepositoryErrorCode, mverr.UnableToCreateRepositoryMsg, "unable to create a domain.UnitOfWork instance", err)
+	}
+	// The UnitOfWork requires the unprotected repository. Failed writes rejected in read-only mode
+	// don't count towards opening the circuit breaker so reads continue to be served.
+	readOnly, err := ProvideReadOnlyRepository(cfg, repo)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateRepositoryErrorCode, mverr.UnableToCreateRepositoryMsg, "unable to create a db.ReadOnlyRepository instance", err)
+	}
+	repo, err = ProvideBreakerRepository(readOnly)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateRepositoryErrorCode, mverr.UnableToCreateRepositoryMsg, "unable to create a db.BreakerRepository instance", err)
+	}
+
+	return &repositories{users: repo, accounts: accountRepo, uow: uow, readOnly: readOnly}, nil
+}
+
+// shared are the components of the primary's object graph that the canary's shares
+type shared struct {
+	userSvc        *services.UserSvc
+	queue          domain.UserQueueRepository
+	deadLetters    domain.DeadLetterRepository
+	changeLog      *services.ChangeLog
+	eventBus       *eventbus.Bus
+	userIndex      *services.ElasticsearchUserIndex
+	invoiceSvc     services.InvoiceSvc
+	usage          *services.UsageTracker
+	impersonations *auth.Impersonations
+	apiKeys        *auth.APIKeys
+	jwtKeys        *auth.JWTKeys
+	engine         *policy.Engine
+	store          blob.Store
+	drain          *lifecycle.Drain
+	statusBoard    *services.StatusBoardSvc
+	errSummary     *errsummary.Summary
+}
+
+// newCanaryHandler returns the canary's HTTP handler. Only the repositories, which 'overrides' may
+// replace, the services using them, and the handler are constructed for the canary. Everything else
+// is shared with 'primary', so the canary's changes are recorded in the primary's change log and
+// published on its event bus, and its requests count against the primary's bulkheads. The primary's
+// workers, e.g., its ActivationExpirer, and its gRPC server serve both.
+func newCanaryHandler(cfg Config, db *sql.DB, logger logging.Logger, overrides Overrides, primary shared) (http.Handler, *mverr.MVError) {
+	repos, mvErr := newRepositories(cfg, db, primary.queue, overrides)
+	if mvErr != nil {
+		return nil, mvErr
+	}
+
+	userSvc, err := ProvideUserSvc(cfg, repos.users, primary.queue, primary.deadLetters, repos.accounts, repos.uow, primary.changeLog, primary.eventBus, logger)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to create a services.UserSvc instance", err)
+	}
+	if err = userSvc.ShareBulkheads(primary.userSvc); err != nil {
+		return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to share the bulkheads", err)
+	}
+	if primary.userIndex != nil {
+		if err = userSvc.SetSearcher(primary.userIndex); err != nil {
+			return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to share the search index", err)
+		}
+	}
+	if primary.impersonations != nil {
+		if err = userSvc.SetImpersonations(primary.impersonations); err != nil {
+			return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to share the impersonations", err)
+		}
+	}
+	if primary.jwtKeys != nil {
+		if err = userSvc.EnableLogin(primary.jwtKeys, cfg.SessionTTL); err != nil {
+			return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to enable logins", err)
+		}
+	}
+	accountSvc, err := ProvideAccountSvc(userSvc, primary.invoiceSvc, primary.usage, logger)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to create a services.AccountSvc instance", err)
+	}
+	exportSvc, err := ProvideExportSvc(cfg, userSvc, logger)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateUserSvcErrorCode, mverr.UnableToCreateUserSvcMsg, "unable to create a services.ExportSvc instance", err)
+	}
+	if primary.jwtKeys != nil || primary.apiKeys != nil || primary.impersonations != nil {
+		requireCallers(userSvc, accountSvc, exportSvc)
+	}
+
+	handler, err := ProvideHTTPHandler(cfg, userSvc, accountSvc, exportSvc, primary.impersonations, primary.apiKeys, primary.jwtKeys, primary.engine, primary.store, primary.deadLetters, repos.readOnly, primary.drain, primary.usage, primary.eventBus, primary.statusBoard, primary.errSummary, logger, overrides.Middleware)
+	if err != nil {
+		return nil, newError(mverr.UnableToCreateHTTPHandlerErrorCode, mverr.UnableToCreateHTTPHandlerMsg, "unable to create the HTTP handler", err)
+	}
+	return handler, nil
+}
+
+// requireCallers makes the services deny requests without a caller. 'exportSvc' may be nil.
+func requireCallers(userSvc *services.UserSvc, accountSvc *services.AccountSvc, exportSvc *services.ExportSvc) {
+	userSvc.RequireCallers()
+	accountSvc.RequireCallers()
+	if exportSvc != nil {
+		exportSvc.RequireCallers()
+	}
+}
+
+func newError(code mverr.ErrCode, msg, detail string, err error) *mverr.MVError {
+	return &mverr.MVError{
+		ErrCode:    code,
+		ErrMsg:     msg,
+		ErrDetail:  fmt.Sprintf("%s: %s", detail, err),
+		WrappedErr: err,
+	}
+}
@@ -0,0 +1,1147 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/protocol"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/db/memory"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/eventbus"
+	"github.com/youngkin/mockvideo/internal/jsonlimit"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// logger is used to control code-under-test logging behavior
+var logger logging.Logger
+
+func init() {
+	logger = logging.Default()
+	// Suppress all application logging
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+}
+
+func memoryRepo(*sql.DB) (domain.UserRepository, error) {
+	return memory.NewUserTable(), nil
+}
+
+// envMiddleware returns middleware that sets the 'X-Env' response header to 'env'
+func envMiddleware(env string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Env", env)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func memoryAccountRepo(*sql.DB, domain.UserRepository) (domain.AccountRepository, error) {
+	return memory.NewAccountTable(), nil
+}
+
+// signupBody is a valid 'POST /signup' request body
+const signupBody = `{"account":{"accountholdername":"mickey dolenz","serviceaddress":"1 Main St","billingaddress":"1 Main St","email":"mickeyd@gmail.com","phone":"5555550100"},` +
+	`"user":{"name":"mickey dolenz","email":"mickeyd@gmail.com","password":"pw"}}`
+
+func TestNewConfig(t *testing.T) {
+	tcs := []struct {
+		testName string
+		configs  map[string]string
+		secrets  map[string]string
+		expected Config
+	}{
+		{
+			testName: "testDefaults",
+			configs:  map[string]string{},
+			secrets:  map[string]string{},
+			expected: Config{
+				MetricsEnabled:           true,
+				TracingEnabled:           true,
+				MaxBulkOps:               10,
+				BulkValidation:           "continue",
+				MaxReads:                 50,
+				MaxWrites:                20,
+				WriteBehindMaxAttempts:   3,
+				ActivationTTL:            48 * time.Hour,
+				ActivationExpiryInterval: time.Hour,
+				PasswordHashCost:         10,
+				SearchIndex:              "users",
+				DownstreamTimeout:        time.Second,
+				DownstreamMaxRetries:     2,
+				ImpersonationTTL:         auth.DefaultImpersonationTTL,
+				SessionTTL:               services.DefaultSessionTTL,
+				HeapDumpInterval:         time.Minute,
+				ChangeLogSize:            1000,
+				ChangesWait:              30 * time.Second,
+				EventStreamBufferSize:    100,
+				EventStreamPolicy:        "disconnect",
+				ShutdownTimeout:          10 * time.Second,
+				DrainDelay:               5 * time.Second,
+				ClientAllowlist:          "curl,go-http-client",
+				ErrorSummarySize:         50,
+				AccessLogRules:           "/accountdhealth,/readyz,/metrics",
+				CacheControlRules:        "/accountdhealth=5,/readyz=5,/accounts/*/usage=private:30",
+				ReadOnlyWriteFailures:    3,
+				ReadOnlyProbeInterval:    30 * time.Second,
+				UsageWindow:              time.Hour,
+				UsageMaxAccounts:         10000,
+				RequestBodyTimeout:       10 * time.Second,
+				JSONLimits:               jsonlimit.Limits{MaxDepth: 32, MaxItems: 100000},
+				GRPCLogSampleRate:        1,
+				GRPCSlowRPC:              time.Second,
+			},
+		},
+		{
+			testName: "testDemoMode",
+			configs:  map[string]string{"demoMode": "true", "writeBehindRate": "100"},
+			secrets:  map[string]string{},
+			expected: Config{
+				DemoMode:                 true,
+				MetricsEnabled:           true,
+				TracingEnabled:           true,
+				MaxBulkOps:               10,
+				BulkValidation:           "continue",
+				MaxReads:                 50,
+				MaxWrites:                20,
+				WriteBehindMaxAttempts:   3,
+				ActivationTTL:            48 * time.Hour,
+				ActivationExpiryInterval: time.Hour,
+				PasswordHashCost:         10,
+				SearchIndex:              "users",
+				DownstreamTimeout:        time.Second,
+				DownstreamMaxRetries:     2,
+				ImpersonationTTL:         auth.DefaultImpersonationTTL,
+				SessionTTL:               services.DefaultSessionTTL,
+				HeapDumpInterval:         time.Minute,
+				ChangeLogSize:            1000,
+				ChangesWait:              30 * time.Second,
+				EventStreamBufferSize:    100,
+				EventStreamPolicy:        "disconnect",
+				ShutdownTimeout:          10 * time.Second,
+				DrainDelay:               5 * time.Second,
+				ClientAllowlist:          "curl,go-http-client",
+				ErrorSummarySize:         50,
+				AccessLogRules:           "/accountdhealth,/readyz,/metrics",
+				CacheControlRules:        "/accountdhealth=5,/readyz=5,/accounts/*/usage=private:30",
+				ReadOnlyWriteFailures:    3,
+				ReadOnlyProbeInterval:    30 * time.Second,
+				UsageWindow:              time.Hour,
+				UsageMaxAccounts:         10000,
+				RequestBodyTimeout:       10 * time.Second,
+				JSONLimits:               jsonlimit.Limits{MaxDepth: 32, MaxItems: 100000},
+				GRPCLogSampleRate:        1,
+				GRPCSlowRPC:              time.Second,
+			},
+		},
+		{
+			testName: "testConfigured",
+			configs: map[string]string{
+				"productionMode":               "true",
+				"metricsEnabled":               "false",
+				"tracingEnabled":               "false",
+				"maxConcurrentBulkOperations":  "5",
+				"bulkValidation":               "failfast",
+				"maxConcurrentReads":           "bogus",
+				"maxConcurrentWrites":          "7",
+				"writeBehindRate":              "100",
+				"writeBehindMaxAttempts":       "5",
+				"activationTTLHours":           "1",
+				"activationExpiryIntervalMins": "2",
+				"billingdURL":                  "http://billingd:5000",
+				"searchURL":                    "http://elasticsearch:9200",
+				"searchIndex":                  "accountd-users",
+				"downstreamTimeoutMillis":      "250",
+				"downstreamMaxRetries":         "0",
+				"statusBoardServices":          "customerd=http://customerd:5000/customerdhealth",
+				"impersonationTTLMinutes":      "600",
+				"heapDumpDir":                  "/tmp",
+				"heapDumpIntervalSecs":         "300",
+				"exportDir":                    "/var/exports",
+				"changeLogSize":                "50",
+				"changesWaitSecs":              "5",
+				"eventStreamBufferSize":        "10",
+				"eventStreamPolicy":            "dropoldest",
+				"shutdownTimeoutSecs":          "30",
+				"drainDelaySecs":               "45",
+				"basePath":                     "/accountd",
+				"absoluteHREFs":                "true",
+				"trustForwardedHeaders":        "true",
+				"clientAllowlist":              "accountctl/1.2.0,curl",
+				"errorSummarySize":             "20",
+				"accessLogRules":               "/metrics,/readyz=10",
+				"cacheControlRules":            "/readyz=10",
+				"authzPolicyFile":              "/etc/accountd/policy",
+				"authzDecisionLog":             "true",
+				"readOnlyWriteFailures":        "10",
+				"readOnlyProbeSecs":            "5",
+				"usageWindowMins":              "15",
+				"usageMaxAccounts":             "100",
+				"requestBodyTimeoutMillis":     "0",
+				"jsonMaxDepth":                 "0",
+				"jsonMaxItems":                 "500",
+				"grpcLogSampleRate":            "100",
+				"passwordHashCost":             "12",
+				"sessionTTLMinutes":            "30",
+				"grpcSlowRPCMillis":            "250",
+				"grpcRequireClientCert":        "true",
+				"canaryPercent":                "5",
+			},
+			secrets: map[string]string{"adminToken": "secret", "tlsCert": "cert", "tlsKey": "key", "tlsClientCA": "ca"},
+			expected: Config{
+				ProductionMode:           true,
+				TLSCert:                  "cert",
+				TLSKey:                   "key",
+				GRPCRequireClientCert:    true,
+				TLSClientCA:              "ca",
+				MaxBulkOps:               5,
+				BulkValidation:           "failfast",
+				MaxReads:                 50,
+				MaxWrites:                7,
+				WriteBehindRate:          100,
+				WriteBehindMaxAttempts:   5,
+				ActivationTTL:            time.Hour,
+				ActivationExpiryInterval: 2 * time.Minute,
+				PasswordHashCost:         12,
+				BillingdURL:              "http://billingd:5000",
+				SearchURL:                "http://elasticsearch:9200",
+				SearchIndex:              "accountd-users",
+				DownstreamTimeout:        250 * time.Millisecond,
+				DownstreamMaxRetries:     0,
+				StatusBoardServices:      "customerd=http://customerd:5000/customerdhealth",
+				AdminToken:               "secret",
+				ImpersonationTTL:         auth.MaxImpersonationTTL,
+				SessionTTL:               30 * time.Minute,
+				HeapDumpDir:              "/tmp",
+				HeapDumpInterval:         5 * time.Minute,
+				ExportDir:                "/var/exports",
+				ChangeLogSize:            50,
+				ChangesWait:              5 * time.Second,
+				EventStreamBufferSize:    10,
+				EventStreamPolicy:        "dropoldest",
+				ShutdownTimeout:          30 * time.Second,
+				DrainDelay:               15 * time.Second,
+				BasePath:                 "/accountd",
+				AbsoluteHREFs:            true,
+				TrustForwardedHeaders:    true,
+				ClientAllowlist:          "accountctl/1.2.0,curl",
+				ErrorSummarySize:         20,
+				AccessLogRules:           "/metrics,/readyz=10",
+				CacheControlRules:        "/readyz=10",
+				AuthzPolicyFile:          "/etc/accountd/policy",
+				AuthzDecisionLog:         true,
+				ReadOnlyWriteFailures:    10,
+				ReadOnlyProbeInterval:    5 * time.Second,
+				UsageWindow:              15 * time.Minute,
+				UsageMaxAccounts:         100,
+				JSONLimits:               jsonlimit.Limits{MaxItems: 500},
+				GRPCLogSampleRate:        100,
+				GRPCSlowRPC:              250 * time.Millisecond,
+				CanaryPercent:            5,
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			cfg := NewConfig(tc.configs, tc.secrets, logger)
+			if cfg != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, cfg)
+			}
+		})
+	}
+}
+
+func TestHarden(t *testing.T) {
+	cert, err := ioutil.ReadFile("testdata/tls.crt")
+	if err != nil {
+		t.Fatalf("error %s was not expected reading the test certificate", err)
+	}
+	key, err := ioutil.ReadFile("testdata/tls.key")
+	if err != nil {
+		t.Fatalf("error %s was not expected reading the test key", err)
+	}
+	hardened := Config{ProductionMode: true, TLSCert: string(cert), TLSKey: string(key), JWTKeys: "2020-10 fedcba9876543210fedcba9876543210", HeapDumpDir: "/tmp"}
+
+	tcs := []struct {
+		testName        string
+		cfg             func(cfg Config) Config
+		dbPassword      string
+		protocols       protocol.Set
+		expectErr       bool
+		expectedHeapDir string
+	}{
+		{
+			testName:        "testNotProductionMode",
+			cfg:             func(cfg Config) Config { cfg.ProductionMode = false; cfg.TLSCert = ""; return cfg },
+			protocols:       protocol.Set{protocol.GRPC},
+			expectedHeapDir: "/tmp",
+		},
+		{
+			testName:   "testHardened",
+			cfg:        func(cfg Config) Config { return cfg },
+			dbPassword: "s3cr3t-Pa55",
+			protocols:  protocol.Set{protocol.HTTP},
+		},
+		{
+			testName: "testAPIKeys",
+			cfg: func(cfg Config) Config {
+				cfg.JWTKeys = ""
+				cfg.APIKeys = "billing 0123456789abcdef0123456789abcdef 1 unrestricted users:read"
+				return cfg
+			},
+			dbPassword: "s3cr3t-Pa55",
+			protocols:  protocol.Set{protocol.HTTP},
+		},
+		{
+			testName: "testPolicyWithoutKeys",
+			cfg: func(cfg Config) Config {
+				cfg.JWTKeys = ""
+				cfg.AuthzPolicyFile = "testdata/authz.policy"
+				return cfg
+			},
+			dbPassword: "s3cr3t-Pa55",
+			protocols:  protocol.Set{protocol.HTTP},
+			expectErr:  true,
+		},
+		{
+			testName:   "testAdminTokenWithoutKeys",
+			cfg:        func(cfg Config) Config { cfg.JWTKeys = ""; cfg.AdminToken = "secret"; return cfg },
+			dbPassword: "s3cr3t-Pa55",
+			protocols:  protocol.Set{protocol.HTTP},
+			expectErr:  true,
+		},
+		{
+			testName:   "testNoTLS",
+			cfg:        func(cfg Config) Config { cfg.TLSCert = ""; cfg.TLSKey = ""; return cfg },
+			dbPassword: "s3cr3t-Pa55",
+			protocols:  protocol.Set{protocol.HTTP},
+			expectErr:  true,
+		},
+		{
+			testName:   "testMissingTLSKey",
+			cfg:        func(cfg Config) Config { cfg.TLSKey = ""; return cfg },
+			dbPassword: "s3cr3t-Pa55",
+			protocols:  protocol.Set{protocol.HTTP},
+			expectErr:  true,
+		},
+		{
+			testName:   "testInvalidTLSCert",
+			cfg:        func(cfg Config) Config { cfg.TLSCert = "bogus"; return cfg },
+			dbPassword: "s3cr3t-Pa55",
+			protocols:  protocol.Set{protocol.HTTP},
+			expectErr:  true,
+		},
+		{
+			testName:   "testGRPC",
+			cfg:        func(cfg Config) Config { return cfg },
+			dbPassword: "s3cr3t-Pa55",
+			protocols:  protocol.Set{protocol.GRPC},
+		},
+		{
+			testName:   "testBoth",
+			cfg:        func(cfg Config) Config { return cfg },
+			dbPassword: "s3cr3t-Pa55",
+			protocols:  protocol.Set{protocol.HTTP, protocol.GRPC},
+		},
+		{
+			testName: "testGRPCClientCert",
+			cfg: func(cfg Config) Config {
+				cfg.GRPCRequireClientCert = true
+				cfg.TLSClientCA = cfg.TLSCert
+				return cfg
+			},
+			dbPassword: "s3cr3t-Pa55",
+			protocols:  protocol.Set{protocol.GRPC},
+		},
+		{
+			testName:   "testGRPCClientCertWithoutCA",
+			cfg:        func(cfg Config) Config { cfg.GRPCRequireClientCert = true; return cfg },
+			dbPassword: "s3cr3t-Pa55",
+			protocols:  protocol.Set{protocol.GRPC},
+			expectErr:  true,
+		},
+		{
+			testName:   "testNoAuth",
+			cfg:        func(cfg Config) Config { cfg.JWTKeys = ""; return cfg },
+			dbPassword: "s3cr3t-Pa55",
+			protocols:  protocol.Set{protocol.HTTP},
+			expectErr:  true,
+		},
+		{
+			testName:  "testDemoModeWithoutDBPassword",
+			cfg:       func(cfg Config) Config { cfg.DemoMode = true; return cfg },
+			protocols: protocol.Set{protocol.HTTP},
+		},
+		{
+			testName:   "testEmptyDBPassword",
+			cfg:        func(cfg Config) Config { return cfg },
+			dbPassword: " \n",
+			protocols:  protocol.Set{protocol.HTTP},
+			expectErr:  true,
+		},
+		{
+			testName:   "testDefaultDBPassword",
+			cfg:        func(cfg Config) Config { return cfg },
+			dbPassword: "Admin\n",
+			protocols:  protocol.Set{protocol.HTTP},
+			expectErr:  true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			cfg, protections, mvErr := Harden(tc.cfg(hardened), tc.dbPassword, tc.protocols)
+			if tc.expectErr {
+				if mvErr == nil || mvErr.ErrCode != mverr.ProductionModeErrorCode {
+					t.Fatalf("expected a ProductionModeErrorCode error, got %v", mvErr)
+				}
+				return
+			}
+			if mvErr != nil {
+				t.Fatalf("error %s was not expected", mvErr)
+			}
+			if cfg.HeapDumpDir != tc.expectedHeapDir {
+				t.Errorf("expected HeapDumpDir %q, got %q", tc.expectedHeapDir, cfg.HeapDumpDir)
+			}
+			if cfg.ProductionMode && len(protections) == 0 {
+				t.Errorf("expected the applied protections to be listed")
+			}
+			if !cfg.ProductionMode && len(protections) != 0 {
+				t.Errorf("expected no protections, got %v", protections)
+			}
+		})
+	}
+}
+
+func TestConfigSchema(t *testing.T) {
+	tcs := []struct {
+		testName     string
+		configs      map[string]string
+		withDefaults bool
+		shouldPass   bool
+	}{
+		{
+			testName:   "testRequiredOnly",
+			configs:    map[string]string{"dbHost": "mysql", "dbPort": "3306", "dbName": "mockvideo"},
+			shouldPass: true,
+		},
+		{
+			testName:     "testDefaults",
+			configs:      map[string]string{"dbHost": "mysql", "dbPort": "3306", "dbName": "mockvideo"},
+			withDefaults: true,
+			shouldPass:   true,
+		},
+		{
+			testName:   "testMissingRequired",
+			configs:    map[string]string{"dbHost": "mysql"},
+			shouldPass: false,
+		},
+		{
+			testName:   "testDBSocket",
+			configs:    map[string]string{"dbSocket": "/var/run/mysqld/mysqld.sock", "dbName": "mockvideo"},
+			shouldPass: true,
+		},
+		{
+			testName:   "testInvalidDBTLS",
+			configs:    map[string]string{"dbHost": "mysql", "dbPort": "3306", "dbName": "mockvideo", "dbTLS": "preferred"},
+			shouldPass: false,
+		},
+		{
+			testName:   "testOutOfRange",
+			configs:    map[string]string{"dbHost": "mysql", "dbPort": "3306", "dbName": "mockvideo", "impersonationTTLMinutes": "600"},
+			shouldPass: false,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			if tc.withDefaults {
+				// Every default must itself be valid
+				for _, k := range ConfigSchema {
+					if _, ok := tc.configs[k.Name]; !ok && k.Default != "" {
+						tc.configs[k.Name] = k.Default
+					}
+				}
+			}
+			err := ConfigSchema.Validate(tc.configs)
+			if tc.shouldPass && err != nil {
+				t.Errorf("error %s was not expected", err)
+			}
+			if !tc.shouldPass && err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	const apiKeys = "billing 0123456789abcdef0123456789abcdef 1 unrestricted users:read"
+	const jwtKeys = "2020-10 fedcba9876543210fedcba9876543210"
+	keys, err := auth.ParseJWTKeys(strings.NewReader(jwtKeys))
+	if err != nil {
+		t.Fatalf("error %s was not expected parsing the JWT keys", err)
+	}
+	jwt, err := keys.Sign("2020-10", auth.JWTClaims{Subject: "1", AccountID: 1, Role: "primary", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("error %s was not expected signing a JWT", err)
+	}
+	tcs := []struct {
+		testName           string
+		cfg                Config
+		overrides          Overrides
+		method             string
+		path               string
+		body               string
+		apiKey             string
+		draining           bool
+		expectedErrCode    mverr.ErrCode
+		expectedHTTPStatus int
+		expectedHeader     string
+	}{
+		{
+			testName:           "testGetUsers",
+			cfg:                NewConfig(map[string]string{}, map[string]string{}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodGet,
+			path:               "/users",
+			expectedHTTPStatus: http.StatusOK,
+		},
+		{
+			testName:           "testReadyz",
+			cfg:                NewConfig(map[string]string{}, map[string]string{}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodGet,
+			path:               "/readyz",
+			expectedHTTPStatus: http.StatusOK,
+		},
+		{
+			testName:           "testReadyzDraining",
+			cfg:                NewConfig(map[string]string{"drainDelaySecs": "0"}, map[string]string{}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodGet,
+			path:               "/readyz",
+			draining:           true,
+			expectedHTTPStatus: http.StatusServiceUnavailable,
+		},
+		{
+			testName:           "testUnknownResource",
+			cfg:                NewConfig(map[string]string{}, map[string]string{}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodGet,
+			path:               "/wp-login.php",
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:           "testUsage",
+			cfg:                NewConfig(map[string]string{}, map[string]string{}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodGet,
+			path:               "/accounts/1/usage",
+			expectedHTTPStatus: http.StatusOK,
+		},
+		{
+			testName:           "testImpersonationDisabled",
+			cfg:                NewConfig(map[string]string{}, map[string]string{}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodPost,
+			path:               "/admin/impersonate",
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:           "testImpersonationEnabled",
+			cfg:                NewConfig(map[string]string{}, map[string]string{"adminToken": "secret"}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodPost,
+			path:               "/admin/impersonate",
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testHeapDumpDisabled",
+			cfg:                NewConfig(map[string]string{}, map[string]string{"adminToken": "secret"}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodPost,
+			path:               "/admin/debug/heapdump",
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:           "testHeapDumpEnabled",
+			cfg:                NewConfig(map[string]string{"heapDumpDir": os.TempDir()}, map[string]string{"adminToken": "secret"}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodPost,
+			path:               "/admin/debug/heapdump",
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testDeadLettersDisabled",
+			cfg:                NewConfig(map[string]string{}, map[string]string{"adminToken": "secret"}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodGet,
+			path:               "/admin/deadletters",
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:           "testRequestsEnabled",
+			cfg:                NewConfig(map[string]string{}, map[string]string{"adminToken": "secret"}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodGet,
+			path:               "/admin/requests",
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testRequestsProductionMode",
+			cfg:                NewConfig(map[string]string{"productionMode": "true"}, map[string]string{"adminToken": "secret"}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodGet,
+			path:               "/admin/requests",
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:        "testInvalidHeapDumpDir",
+			cfg:             NewConfig(map[string]string{"heapDumpDir": "/nonexistent/heapdumps"}, map[string]string{"adminToken": "secret"}, logger),
+			overrides:       Overrides{UserRepository: memoryRepo},
+			expectedErrCode: mverr.UnableToCreateHTTPHandlerErrorCode,
+		},
+		{
+			testName:           "testExportDisabled",
+			cfg:                NewConfig(map[string]string{}, map[string]string{}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodGet,
+			path:               "/accounts/1/export",
+			expectedHTTPStatus: http.StatusNotImplemented,
+		},
+		{
+			testName:           "testSignupDisabled",
+			cfg:                NewConfig(map[string]string{}, map[string]string{}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodPost,
+			path:               "/signup",
+			body:               signupBody,
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:           "testSignupEnabled",
+			cfg:                NewConfig(map[string]string{}, map[string]string{}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo, AccountRepository: memoryAccountRepo},
+			method:             http.MethodPost,
+			path:               "/signup",
+			body:               signupBody,
+			expectedHTTPStatus: http.StatusCreated,
+		},
+		{
+			testName:        "testInvalidExportDir",
+			cfg:             NewConfig(map[string]string{"exportDir": "/nonexistent/exports"}, map[string]string{}, logger),
+			overrides:       Overrides{UserRepository: memoryRepo},
+			expectedErrCode: mverr.UnableToCreateUserSvcErrorCode,
+		},
+		{
+			testName:           "testPolicyEnabled",
+			cfg:                NewConfig(map[string]string{"authzPolicyFile": "testdata/authz.policy", "authzDecisionLog": "true"}, map[string]string{"adminToken": "secret", "apiKeys": apiKeys}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodGet,
+			path:               "/users",
+			apiKey:             "0123456789abcdef0123456789abcdef",
+			expectedHTTPStatus: http.StatusForbidden,
+		},
+		{
+			testName:           "testImpersonationTokenMissing",
+			cfg:                NewConfig(map[string]string{}, map[string]string{"adminToken": "secret"}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodPut,
+			path:               "/users/1",
+			body:               `{"accountid":1,"name":"mickey dolenz","email":"mickeyd@gmail.com","role":1}`,
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testAPIKeyReadScope",
+			cfg:                NewConfig(map[string]string{}, map[string]string{"apiKeys": apiKeys}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodGet,
+			path:               "/users",
+			apiKey:             "0123456789abcdef0123456789abcdef",
+			expectedHTTPStatus: http.StatusOK,
+		},
+		{
+			testName:           "testAPIKeyWriteScopeMissing",
+			cfg:                NewConfig(map[string]string{}, map[string]string{"apiKeys": apiKeys}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodDelete,
+			path:               "/users/1",
+			apiKey:             "0123456789abcdef0123456789abcdef",
+			expectedHTTPStatus: http.StatusForbidden,
+		},
+		{
+			testName:           "testAPIKeyMissing",
+			cfg:                NewConfig(map[string]string{}, map[string]string{"apiKeys": apiKeys}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodPut,
+			path:               "/users/1",
+			body:               `{"accountid":1,"name":"mickey dolenz","email":"mickeyd@gmail.com","role":1}`,
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testUnknownAPIKey",
+			cfg:                NewConfig(map[string]string{}, map[string]string{"apiKeys": apiKeys}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodGet,
+			path:               "/users",
+			apiKey:             "fedcba9876543210fedcba9876543210",
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:        "testInvalidAPIKeys",
+			cfg:             NewConfig(map[string]string{}, map[string]string{"apiKeys": "billing shortkey 1 unrestricted users:read"}, logger),
+			overrides:       Overrides{UserRepository: memoryRepo},
+			expectedErrCode: mverr.UnableToLoadConfigErrorCode,
+		},
+		{
+			testName:           "testJWT",
+			cfg:                NewConfig(map[string]string{}, map[string]string{"jwtKeys": jwtKeys}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodGet,
+			path:               "/users",
+			apiKey:             jwt,
+			expectedHTTPStatus: http.StatusOK,
+		},
+		{
+			testName:           "testJWTMissing",
+			cfg:                NewConfig(map[string]string{}, map[string]string{"jwtKeys": jwtKeys}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodGet,
+			path:               "/users",
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testJWTMissingAccountSummary",
+			cfg:                NewConfig(map[string]string{}, map[string]string{"jwtKeys": jwtKeys}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodGet,
+			path:               "/accounts/1/summary",
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testJWTMissingAccountExport",
+			cfg:                NewConfig(map[string]string{}, map[string]string{"jwtKeys": jwtKeys}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodGet,
+			path:               "/accounts/1/export",
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testJWTMissingAccountRoles",
+			cfg:                NewConfig(map[string]string{}, map[string]string{"jwtKeys": jwtKeys}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodPost,
+			path:               "/accounts/1/users/roles",
+			body:               `{"userID":1,"role":"restricted"}`,
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:           "testJWTAndAPIKey",
+			cfg:                NewConfig(map[string]string{}, map[string]string{"jwtKeys": jwtKeys, "apiKeys": apiKeys}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodGet,
+			path:               "/users",
+			apiKey:             "0123456789abcdef0123456789abcdef",
+			expectedHTTPStatus: http.StatusOK,
+		},
+		{
+			testName:           "testLoginDisabled",
+			cfg:                NewConfig(map[string]string{}, map[string]string{}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodPost,
+			path:               "/login",
+			body:               `{"email":"ptork@gmail.com","password":"pw"}`,
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:           "testLoginWithoutToken",
+			cfg:                NewConfig(map[string]string{}, map[string]string{"jwtKeys": jwtKeys}, logger),
+			overrides:          Overrides{UserRepository: memoryRepo},
+			method:             http.MethodPost,
+			path:               "/login",
+			body:               `{"email":"ptork@gmail.com","password":"pw"}`,
+			expectedHTTPStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:        "testInvalidJWTKeys",
+			cfg:             NewConfig(map[string]string{}, map[string]string{"jwtKeys": "2020-10 shortkey"}, logger),
+			overrides:       Overrides{UserRepository: memoryRepo},
+			expectedErrCode: mverr.UnableToLoadConfigErrorCode,
+		},
+		{
+			testName:        "testInvalidAccessLogRules",
+			cfg:             NewConfig(map[string]string{"accessLogRules": "/readyz=0"}, map[string]string{}, logger),
+			overrides:       Overrides{UserRepository: memoryRepo},
+			expectedErrCode: mverr.UnableToCreateHTTPHandlerErrorCode,
+		},
+		{
+			testName:        "testInvalidCacheControlRules",
+			cfg:             NewConfig(map[string]string{"cacheControlRules": "/readyz=private"}, map[string]string{}, logger),
+			overrides:       Overrides{UserRepository: memoryRepo},
+			expectedErrCode: mverr.UnableToCreateHTTPHandlerErrorCode,
+		},
+		{
+			testName:        "testInvalidPolicy",
+			cfg:             NewConfig(map[string]string{"authzPolicyFile": "testdata/invalid.policy"}, map[string]string{}, logger),
+			overrides:       Overrides{UserRepository: memoryRepo},
+			expectedErrCode: mverr.UnableToLoadConfigErrorCode,
+		},
+		{
+			testName:        "testMissingPolicy",
+			cfg:             NewConfig(map[string]string{"authzPolicyFile": "testdata/nonexistent.policy"}, map[string]string{}, logger),
+			overrides:       Overrides{UserRepository: memoryRepo},
+			expectedErrCode: mverr.UnableToLoadConfigErrorCode,
+		},
+		{
+			testName: "testMiddleware",
+			cfg:      NewConfig(map[string]string{}, map[string]string{}, logger),
+			overrides: Overrides{
+				UserRepository: memoryRepo,
+				Middleware:     []func(http.Handler) http.Handler{envMiddleware("test")},
+			},
+			method:             http.MethodGet,
+			path:               "/accountdhealth",
+			expectedHTTPStatus: http.StatusOK,
+			expectedHeader:     "test",
+		},
+		{
+			testName: "testCanary",
+			cfg:      NewConfig(map[string]string{"canaryPercent": "100"}, map[string]string{}, logger),
+			overrides: Overrides{
+				UserRepository: memoryRepo,
+				Middleware:     []func(http.Handler) http.Handler{envMiddleware("primary")},
+				Canary: &Overrides{
+					UserRepository: memoryRepo,
+					Middleware:     []func(http.Handler) http.Handler{envMiddleware("canary")},
+				},
+			},
+			method:             http.MethodGet,
+			path:               "/users",
+			expectedHTTPStatus: http.StatusOK,
+			expectedHeader:     "canary",
+		},
+		{
+			testName: "testNoCanaryTraffic",
+			cfg:      NewConfig(map[string]string{}, map[string]string{}, logger),
+			overrides: Overrides{
+				UserRepository: memoryRepo,
+				Middleware:     []func(http.Handler) http.Handler{envMiddleware("primary")},
+				Canary: &Overrides{
+					UserRepository: memoryRepo,
+					Middleware:     []func(http.Handler) http.Handler{envMiddleware("canary")},
+				},
+			},
+			method:             http.MethodGet,
+			path:               "/users",
+			expectedHTTPStatus: http.StatusOK,
+			expectedHeader:     "primary",
+		},
+		{
+			testName: "testCanaryReadyzDraining",
+			cfg:      NewConfig(map[string]string{"canaryPercent": "100", "drainDelaySecs": "0"}, map[string]string{}, logger),
+			overrides: Overrides{
+				UserRepository: memoryRepo,
+				Middleware:     []func(http.Handler) http.Handler{envMiddleware("primary")},
+				Canary: &Overrides{
+					UserRepository: memoryRepo,
+					Middleware:     []func(http.Handler) http.Handler{envMiddleware("canary")},
+				},
+			},
+			method:             http.MethodGet,
+			path:               "/readyz",
+			draining:           true,
+			expectedHTTPStatus: http.StatusServiceUnavailable,
+			expectedHeader:     "canary",
+		},
+		{
+			testName: "testCanaryError",
+			cfg:      NewConfig(map[string]string{}, map[string]string{}, logger),
+			overrides: Overrides{
+				UserRepository: memoryRepo,
+				Canary: &Overrides{
+					UserRepository: func(*sql.DB) (domain.UserRepository, error) {
+						return nil, errors.New("no database")
+					},
+				},
+			},
+			expectedErrCode: mverr.UnableToCreateRepositoryErrorCode,
+		},
+		{
+			testName:           "testDemoModeGetUser",
+			cfg:                NewConfig(map[string]string{"demoMode": "true"}, map[string]string{}, logger),
+			method:             http.MethodGet,
+			path:               "/users/1",
+			expectedHTTPStatus: http.StatusOK,
+		},
+		{
+			testName:           "testDemoModeCreateUser",
+			cfg:                NewConfig(map[string]string{"demoMode": "true"}, map[string]string{}, logger),
+			method:             http.MethodPost,
+			path:               "/users",
+			body:               `{"accountid":1,"name":"mickey dolenz","email":"mickeyd@gmail.com","role":1,"password":"pw"}`,
+			expectedHTTPStatus: http.StatusForbidden,
+		},
+		{
+			testName: "testRepositoryError",
+			cfg:      NewConfig(map[string]string{}, map[string]string{}, logger),
+			overrides: Overrides{
+				UserRepository: func(*sql.DB) (domain.UserRepository, error) {
+					return nil, errors.New("no database")
+				},
+			},
+			expectedErrCode: mverr.UnableToCreateRepositoryErrorCode,
+		},
+		{
+			testName:        "testInvalidActivationExpiryInterval",
+			cfg:             Config{MaxBulkOps: 1, MaxReads: 1, MaxWrites: 1, ActivationTTL: time.Hour, PasswordHashCost: auth.MinPasswordCost, ReadOnlyWriteFailures: 1, ReadOnlyProbeInterval: time.Second, UsageWindow: time.Hour, UsageMaxAccounts: 1, ErrorSummarySize: 1},
+			overrides:       Overrides{UserRepository: memoryRepo},
+			expectedErrCode: mverr.UnableToCreateUserSvcErrorCode,
+		},
+		{
+			testName:        "testGRPCClientCertWithoutTLS",
+			cfg:             NewConfig(map[string]string{"grpcRequireClientCert": "true"}, map[string]string{}, logger),
+			overrides:       Overrides{UserRepository: memoryRepo},
+			expectedErrCode: mverr.UnableToCreateRPCServerErrorCode,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			a, mvErr := New(tc.cfg, nil, logger, tc.overrides)
+			if tc.expectedErrCode != 0 {
+				if mvErr == nil {
+					t.Fatalf("expected error code %d, got no error", tc.expectedErrCode)
+				}
+				if mvErr.ErrCode != tc.expectedErrCode {
+					t.Errorf("expected error code %d, got %d", tc.expectedErrCode, mvErr.ErrCode)
+				}
+				return
+			}
+			if mvErr != nil {
+				t.Fatalf("error %s was not expected", mvErr)
+			}
+			if tc.draining {
+				if err := a.Drain.Stop(context.Background()); err != nil {
+					t.Fatalf("error %s was not expected draining", err)
+				}
+			}
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if tc.apiKey != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.apiKey)
+			}
+			a.HTTPHandler.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedHTTPStatus, rr.Code)
+			}
+			if got := rr.Header().Get("X-Env"); got != tc.expectedHeader {
+				t.Errorf("expected X-Env header %q, got %q", tc.expectedHeader, got)
+			}
+		})
+	}
+}
+
+func TestGRPCReflection(t *testing.T) {
+	tcs := []struct {
+		testName         string
+		productionMode   string
+		expectReflection bool
+	}{
+		{testName: "testReflection", productionMode: "false", expectReflection: true},
+		{testName: "testProductionMode", productionMode: "true", expectReflection: false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			cfg := NewConfig(map[string]string{"productionMode": tc.productionMode}, map[string]string{}, logger)
+			a, mvErr := New(cfg, nil, logger, Overrides{UserRepository: memoryRepo})
+			if mvErr != nil {
+				t.Fatalf("error %s was not expected", mvErr)
+			}
+			_, registered := a.GRPCServer.GetServiceInfo()["grpc.reflection.v1alpha.ServerReflection"]
+			if registered != tc.expectReflection {
+				t.Errorf("expected the reflection service registered to be %t, got %t", tc.expectReflection, registered)
+			}
+		})
+	}
+}
+
+func TestCanarySharesPrimary(t *testing.T) {
+	canaryRepo := memory.NewUserTable()
+	id, mvErr := canaryRepo.CreateUser(domain.User{AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary, Status: domain.Active, Password: "pw"})
+	if mvErr != nil {
+		t.Fatalf("error %s was not expected creating a user", mvErr)
+	}
+	cfg := NewConfig(map[string]string{"canaryPercent": "100"}, map[string]string{}, logger)
+	a, mvErr := New(cfg, nil, logger, Overrides{
+		UserRepository: memoryRepo,
+		Canary: &Overrides{
+			UserRepository: func(*sql.DB) (domain.UserRepository, error) { return canaryRepo, nil },
+			Middleware:     []func(http.Handler) http.Handler{envMiddleware("canary")},
+		},
+	})
+	if mvErr != nil {
+		t.Fatalf("error %s was not expected", mvErr)
+	}
+	sub, err := a.EventBus.Subscribe("test", 10, eventbus.DropNewest, services.UserDeleted)
+	if err != nil {
+		t.Fatalf("error %s was not expected subscribing", err)
+	}
+
+	rr := httptest.NewRecorder()
+	a.HTTPHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/users/%d", id), nil))
+	if rr.Code != http.StatusNoContent || rr.Header().Get("X-Env") != "canary" {
+		t.Fatalf("expected the canary to delete user %d, got status %d from %q", id, rr.Code, rr.Header().Get("X-Env"))
+	}
+
+	// The canary's deletion is recorded in, and published by, the primary's components
+	if latest := a.ChangeLog.Latest(); latest != 1 {
+		t.Errorf("expected the primary's change log to record the deletion, latest is %d", latest)
+	}
+	if len(sub.C()) != 1 {
+		t.Errorf("expected the primary's event bus to publish the deletion, got %d events", len(sub.C()))
+	}
+}
+
+// tokenMailer is a services.Mailer that records the activation tokens it's asked to send
+type tokenMailer struct {
+	tokens map[int]string
+}
+
+func (m *tokenMailer) SendActivation(ctx context.Context, user domain.User, token string) error {
+	m.tokens[user.ID] = token
+	return nil
+}
+
+func TestSignupActivateLogin(t *testing.T) {
+	cfg := NewConfig(map[string]string{"passwordHashCost": "4"}, map[string]string{"jwtKeys": "2020-10 fedcba9876543210fedcba9876543210"}, logger)
+	a, mvErr := New(cfg, nil, logger, Overrides{UserRepository: memoryRepo, AccountRepository: memoryAccountRepo})
+	if mvErr != nil {
+		t.Fatalf("error %s was not expected", mvErr)
+	}
+	mailer := &tokenMailer{tokens: make(map[int]string)}
+	if err := a.UserSvc.ConfigureActivation(mailer, time.Hour); err != nil {
+		t.Fatalf("error %s was not expected configuring activation", err)
+	}
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		a.HTTPHandler.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := serve(http.MethodPost, "/signup", signupBody); rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d signing up, got %d", http.StatusCreated, rr.Code)
+	}
+	if len(mailer.tokens) != 1 {
+		t.Fatalf("expected 1 activation token to be sent, got %d", len(mailer.tokens))
+	}
+	// A pending user can't log in
+	login := `{"email":"mickeyd@gmail.com","password":"pw"}`
+	if rr := serve(http.MethodPost, "/login", login); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d logging in before activating, got %d", http.StatusUnauthorized, rr.Code)
+	}
+	for id, token := range mailer.tokens {
+		if rr := serve(http.MethodPost, fmt.Sprintf("/users/%d/activate?token=%s", id, token), ""); rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d activating, got %d", http.StatusOK, rr.Code)
+		}
+	}
+	if rr := serve(http.MethodPost, "/login", login); rr.Code != http.StatusOK {
+		t.Errorf("expected status %d logging in, got %d", http.StatusOK, rr.Code)
+	}
+	// Only activations bypass authentication
+	if rr := serve(http.MethodGet, "/users/1", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d getting a user without a token, got %d", http.StatusUnauthorized, rr.Code)
+	}
+}
+
+func TestGRPCTLS(t *testing.T) {
+	cert, err := ioutil.ReadFile("testdata/tls.crt")
+	if err != nil {
+		t.Fatalf("error %s was not expected reading the test certificate", err)
+	}
+	key, err := ioutil.ReadFile("testdata/tls.key")
+	if err != nil {
+		t.Fatalf("error %s was not expected reading the test key", err)
+	}
+	clientCert, err := tls.X509KeyPair(cert, key)
+	if err != nil {
+		t.Fatalf("error %s was not expected loading the client certificate", err)
+	}
+	// The test certificate is self-signed, so it's both the server's CA and the client's
+	cas := x509.NewCertPool()
+	cas.AppendCertsFromPEM(cert)
+
+	tcs := []struct {
+		testName          string
+		requireClientCert bool
+		clientCert        bool
+		shouldPass        bool
+	}{
+		{testName: "testTLS", shouldPass: true},
+		{testName: "testClientCert", requireClientCert: true, clientCert: true, shouldPass: true},
+		{testName: "testMissingClientCert", requireClientCert: true, shouldPass: false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			cfg := NewConfig(map[string]string{}, map[string]string{"tlsCert": string(cert), "tlsKey": string(key)}, logger)
+			cfg.GRPCRequireClientCert = tc.requireClientCert
+			cfg.TLSClientCA = string(cert)
+			a, mvErr := New(cfg, nil, logger, Overrides{UserRepository: memoryRepo})
+			if mvErr != nil {
+				t.Fatalf("error %s was not expected", mvErr)
+			}
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("error %s was not expected listening", err)
+			}
+			go a.GRPCServer.Serve(ln)
+			defer a.GRPCServer.Stop()
+
+			clientCfg := &tls.Config{RootCAs: cas, ServerName: "localhost"}
+			if tc.clientCert {
+				clientCfg.Certificates = []tls.Certificate{clientCert}
+			}
+			conn, err := grpc.Dial(ln.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(clientCfg)))
+			if err != nil {
+				t.Fatalf("error %s was not expected dialing", err)
+			}
+			defer conn.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+			if tc.shouldPass && err != nil {
+				t.Errorf("error %s was not expected checking health", err)
+			}
+			if !tc.shouldPass && err == nil {
+				t.Errorf("expected an error checking health without a client certificate")
+			}
+		})
+	}
+}
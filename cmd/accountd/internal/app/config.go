@@ -0,0 +1,390 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/youngkin/mockvideo/cmd/accountd/http/admin"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/config"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/accesslog"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/cachecontrol"
+	"github.com/youngkin/mockvideo/internal/clientinfo"
+	userdb "github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/domain"
+	"github.com/youngkin/mockvideo/internal/errsummary"
+	"github.com/youngkin/mockvideo/internal/eventbus"
+	"github.com/youngkin/mockvideo/internal/jsonlimit"
+	"github.com/youngkin/mockvideo/internal/lifecycle"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"github.com/youngkin/mockvideo/internal/metrics"
+)
+
+// unbounded is the Max of integer configuration items that don't have a maximum
+const unbounded = math.MaxInt32
+
+// ConfigSchema describes accountd's configuration items. 'main' validates the configuration
+// against it when it's loaded. NewConfig uses its defaults.
+var ConfigSchema = config.Schema{
+	// Used by 'main' to start the servers and open the DB connection
+	{Name: "port", Type: config.Int, Default: "5000", Min: 0, Max: 65535},
+	{Name: "grpcPort", Type: config.Int, Default: "5001", Min: 0, Max: 65535},
+	{Name: "listen", Type: config.String},
+	{Name: "listenSocketMode", Type: config.String, Default: "0660"},
+	{Name: "logLevel", Type: config.Int, Default: strconv.Itoa(int(logging.InfoLevel)), Min: int(logging.PanicLevel), Max: int(logging.TraceLevel)},
+	{Name: "logger", Type: config.String, Default: logging.Logrus, Allowed: []string{logging.Logrus, "zap", "zerolog"}},
+	{Name: "metricsNamespace", Type: config.String, Default: metrics.DefaultNamespace},
+	{Name: "metricsSubsystem", Type: config.String},
+	{Name: "dbHost", Type: config.String},
+	{Name: "dbPort", Type: config.Int, Min: 1, Max: 65535},
+	{Name: "dbSocket", Type: config.String},
+	{Name: "dbName", Type: config.String, Required: true},
+	{Name: "dbTLS", Type: config.String, Default: userdb.TLSDisabled, Allowed: userdb.TLSModes},
+	{Name: "dbCharset", Type: config.String},
+	{Name: "dbTimeoutMillis", Type: config.Int, Default: "0", Min: 0, Max: unbounded},
+	{Name: "dbReadTimeoutMillis", Type: config.Int, Default: "0", Min: 0, Max: unbounded},
+	{Name: "dbWriteTimeoutMillis", Type: config.Int, Default: "0", Min: 0, Max: unbounded},
+	{Name: "dbParseTime", Type: config.Bool, Default: "true"},
+	{Name: "dbInterpolateParams", Type: config.Bool, Default: "true"},
+	// Used by NewConfig
+	{Name: "productionMode", Type: config.Bool, Default: "false"},
+	{Name: "demoMode", Type: config.Bool, Default: "false"},
+	{Name: "metricsEnabled", Type: config.Bool, Default: "true"},
+	{Name: "tracingEnabled", Type: config.Bool, Default: "true"},
+	{Name: "maxConcurrentBulkOperations", Type: config.Int, Default: "10", Min: 1, Max: unbounded},
+	{Name: "bulkValidation", Type: config.String, Default: services.BulkValidationName[services.ContinueOnError], Allowed: []string{services.BulkValidationName[services.ContinueOnError], services.BulkValidationName[services.FailFast]}},
+	{Name: "maxConcurrentReads", Type: config.Int, Default: "50", Min: 1, Max: unbounded},
+	{Name: "maxConcurrentWrites", Type: config.Int, Default: "20", Min: 1, Max: unbounded},
+	{Name: "writeBehindRate", Type: config.Int, Default: "0", Min: 0, Max: unbounded},
+	{Name: "writeBehindMaxAttempts", Type: config.Int, Default: strconv.Itoa(services.DefaultWriteBehindMaxAttempts), Min: 1, Max: unbounded},
+	{Name: "activationTTLHours", Type: config.Int, Default: strconv.Itoa(int(services.DefaultActivationTTL / time.Hour)), Min: 1, Max: unbounded},
+	{Name: "activationExpiryIntervalMins", Type: config.Int, Default: "60", Min: 1, Max: unbounded},
+	{Name: "passwordHashCost", Type: config.Int, Default: strconv.Itoa(auth.DefaultPasswordCost), Min: auth.MinPasswordCost, Max: auth.MaxPasswordCost},
+	{Name: "sessionTTLMinutes", Type: config.Int, Default: strconv.Itoa(int(services.DefaultSessionTTL / time.Minute)), Min: 1, Max: unbounded},
+	{Name: "billingdURL", Type: config.String},
+	{Name: "searchURL", Type: config.String},
+	{Name: "searchIndex", Type: config.String, Default: services.DefaultSearchIndex},
+	{Name: "downstreamTimeoutMillis", Type: config.Int, Default: "1000", Min: 1, Max: unbounded},
+	{Name: "downstreamMaxRetries", Type: config.Int, Default: "2", Min: 0, Max: unbounded},
+	{Name: "statusBoardServices", Type: config.String},
+	{Name: "impersonationTTLMinutes", Type: config.Int, Default: strconv.Itoa(int(auth.DefaultImpersonationTTL / time.Minute)), Min: 1, Max: int(auth.MaxImpersonationTTL / time.Minute)},
+	{Name: "heapDumpDir", Type: config.String},
+	{Name: "heapDumpIntervalSecs", Type: config.Int, Default: strconv.Itoa(int(admin.DefaultHeapDumpInterval / time.Second)), Min: 1, Max: unbounded},
+	{Name: "exportDir", Type: config.String},
+	{Name: "changeLogSize", Type: config.Int, Default: strconv.Itoa(services.DefaultChangeLogSize), Min: 1, Max: unbounded},
+	{Name: "changesWaitSecs", Type: config.Int, Default: strconv.Itoa(int(services.DefaultChangesWait / time.Second)), Min: 0, Max: unbounded},
+	{Name: "eventStreamBufferSize", Type: config.Int, Default: "100", Min: 1, Max: unbounded},
+	{Name: "eventStreamPolicy", Type: config.String, Default: eventbus.SlowConsumerPolicyName[eventbus.Unsubscribe], Allowed: []string{eventbus.SlowConsumerPolicyName[eventbus.Unsubscribe], eventbus.SlowConsumerPolicyName[eventbus.DropOldest]}},
+	{Name: "shutdownTimeoutSecs", Type: config.Int, Default: strconv.Itoa(int(lifecycle.DefaultShutdownTimeout / time.Second)), Min: 1, Max: unbounded},
+	{Name: "drainDelaySecs", Type: config.Int, Default: strconv.Itoa(int(lifecycle.DefaultDrainDelay / time.Second)), Min: 0, Max: unbounded},
+	{Name: "basePath", Type: config.String},
+	{Name: "absoluteHREFs", Type: config.Bool, Default: "false"},
+	{Name: "trustForwardedHeaders", Type: config.Bool, Default: "false"},
+	{Name: "clientAllowlist", Type: config.String, Default: strings.Join(clientinfo.DefaultAllowlist, ",")},
+	{Name: "errorSummarySize", Type: config.Int, Default: strconv.Itoa(errsummary.DefaultSize), Min: 1, Max: unbounded},
+	{Name: "accessLogRules", Type: config.String, Default: strings.Join(accesslog.DefaultRules, ",")},
+	{Name: "cacheControlRules", Type: config.String, Default: strings.Join(cachecontrol.DefaultRules, ",")},
+	{Name: "authzPolicyFile", Type: config.String},
+	{Name: "authzDecisionLog", Type: config.Bool, Default: "false"},
+	{Name: "readOnlyWriteFailures", Type: config.Int, Default: "3", Min: 1, Max: unbounded},
+	{Name: "readOnlyProbeSecs", Type: config.Int, Default: "30", Min: 1, Max: unbounded},
+	{Name: "usageWindowMins", Type: config.Int, Default: strconv.Itoa(int(services.DefaultUsageWindow / time.Minute)), Min: 1, Max: unbounded},
+	{Name: "usageMaxAccounts", Type: config.Int, Default: strconv.Itoa(services.DefaultUsageMaxAccounts), Min: 1, Max: unbounded},
+	{Name: "requestBodyTimeoutMillis", Type: config.Int, Default: "10000", Min: 0, Max: unbounded},
+	{Name: "jsonMaxDepth", Type: config.Int, Default: "32", Min: 0, Max: unbounded},
+	{Name: "jsonMaxItems", Type: config.Int, Default: "100000", Min: 0, Max: unbounded},
+	{Name: "httpGetUsersQueryTimeoutMillis", Type: config.Int, Default: "0", Min: 0, Max: unbounded},
+	{Name: "httpGetUsersPartialResults", Type: config.Bool, Default: "false"},
+	{Name: "grpcGetUsersQueryTimeoutMillis", Type: config.Int, Default: "0", Min: 0, Max: unbounded},
+	{Name: "grpcGetUsersPartialResults", Type: config.Bool, Default: "false"},
+	{Name: "grpcLogSampleRate", Type: config.Int, Default: "1", Min: 1, Max: unbounded},
+	{Name: "grpcRequireClientCert", Type: config.Bool, Default: "false"},
+	{Name: "grpcSlowRPCMillis", Type: config.Int, Default: strconv.Itoa(int(accesslog.DefaultSlowRPC / time.Millisecond)), Min: 0, Max: unbounded},
+	{Name: "canaryPercent", Type: config.Int, Default: "0", Min: 0, Max: 100},
+}
+
+// Config contains the settings used to construct accountd's components
+type Config struct {
+	// ProductionMode enforces the production hardening profile, see Harden
+	ProductionMode bool
+	// DemoMode serves a built-in synthetic dataset, without a DB, and rejects all changes, see
+	// package demo. Write-behind mode, which requires the DB, is disabled.
+	DemoMode bool
+	// MetricsEnabled serves '/metrics'. When it's false metrics aren't registered or exposed, for a
+	// minimal footprint where nothing scrapes accountd. main also disables them, see metrics.Disable.
+	MetricsEnabled bool
+	// TracingEnabled propagates W3C trace context from incoming requests to downstream services.
+	// When it's false main sets httpclient.NoopTracer, incoming traceparent headers are ignored and
+	// none are sent downstream.
+	TracingEnabled bool
+	// TLSCert and TLSKey are the PEM encoded certificate and private key the HTTP and gRPC servers
+	// use to serve TLS. Plain HTTP, and insecure gRPC, are served when neither is configured.
+	TLSCert string
+	TLSKey  string
+	// GRPCRequireClientCert requires gRPC clients to present a certificate signed by one of the PEM
+	// encoded CA certificates in TLSClientCA, see GRPCTLSConfig
+	GRPCRequireClientCert bool
+	TLSClientCA           string
+	// MaxBulkOps limits the number of concurrent operations in a bulk request
+	MaxBulkOps int
+	// BulkValidation, 'continue' or 'failfast', decides whether the valid users of a bulk request
+	// are processed when some of its users are invalid, see services.BulkValidation
+	BulkValidation string
+	// MaxReads and MaxWrites are the sizes of the read and write bulkheads. Separate limits keep
+	// slow bulk writes from starving reads.
+	MaxReads  int
+	MaxWrites int
+	// WriteBehindRate enables write-behind mode when non-zero. User creations are queued and
+	// applied at this rate per second. Failed user creations are attempted up to
+	// WriteBehindMaxAttempts times before they're dead-lettered.
+	WriteBehindRate        int
+	WriteBehindMaxAttempts int
+	// New users must activate their account within ActivationTTL or they're deleted. Expired
+	// users are checked for every ActivationExpiryInterval.
+	ActivationTTL            time.Duration
+	ActivationExpiryInterval time.Duration
+	// PasswordHashCost is the bcrypt cost users' passwords are hashed with, see auth.HashPassword
+	PasswordHashCost int
+	// BillingdURL is the base URL of the billingd service. Account summaries don't include
+	// invoices if it's empty.
+	BillingdURL string
+	// SearchURL is the base URL of the Elasticsearch, or OpenSearch, cluster users are mirrored to
+	// for 'GET /users/search', into the index named SearchIndex. The DB is searched if it's empty.
+	SearchURL   string
+	SearchIndex string
+	// Calls to downstream services, e.g., billingd, are limited by these timeouts and retries
+	DownstreamTimeout    time.Duration
+	DownstreamMaxRetries int
+	// StatusBoardServices enables 'GET /statusboard' when non-empty. It's a comma separated list of
+	// 'name=url' entries identifying the health endpoints of the services to check, see
+	// services.ParseHealthTargets. Each check is limited by DownstreamTimeout and isn't retried.
+	StatusBoardServices string
+	// AdminToken enables impersonation when non-empty. Impersonation tokens are valid for
+	// ImpersonationTTL, up to auth.MaxImpersonationTTL.
+	AdminToken       string
+	ImpersonationTTL time.Duration
+	// APIKeys are the definitions of the API keys services, e.g., billingd, use to call accountd,
+	// see auth.ParseAPIKeys. Each key is limited to its scopes, e.g., 'users:read'.
+	APIKeys string
+	// JWTKeys are the definitions of the keys JWTs are signed with, see auth.ParseJWTKeys. When
+	// non-empty every request to the users endpoints, and every RPC, must be made with a JWT signed
+	// by one of them, an API key, or an impersonation token. It also enables 'POST /login' and the
+	// Login RPC, which issue users session tokens, signed with the last key, valid for SessionTTL.
+	JWTKeys    string
+	SessionTTL time.Duration
+	// HeapDumpDir enables 'POST /admin/debug/heapdump' when non-empty and AdminToken is
+	// configured. Heap profiles are written to this directory, at most one per HeapDumpInterval.
+	HeapDumpDir      string
+	HeapDumpInterval time.Duration
+	// ExportDir enables account data exports, 'GET /accounts/{id}/export', when non-empty.
+	// Exports are written to this directory. It's separate from HeapDumpDir since exports
+	// contain users' PII.
+	ExportDir string
+	// ChangeLogSize is the number of user changes retained for 'GET /users/changes'. Long-polls
+	// wait up to ChangesWait for a change when there aren't any.
+	ChangeLogSize int
+	ChangesWait   time.Duration
+	// Up to EventStreamBufferSize events are queued for each client of 'GET /users/events', after
+	// which EventStreamPolicy, 'disconnect' or 'dropoldest', applies. Streams last up to ChangesWait.
+	EventStreamBufferSize int
+	EventStreamPolicy     string
+	// ShutdownTimeout is the time allowed for the servers, background workers, and DB connection
+	// pool to stop once accountd is asked to shut down
+	ShutdownTimeout time.Duration
+	// DrainDelay is the time, within ShutdownTimeout, that '/readyz' and the gRPC health service
+	// report that accountd isn't ready before the servers stop, so load balancers stop routing new
+	// requests to it, see lifecycle.Drain
+	DrainDelay time.Duration
+	// BasePath, e.g., '/accountd', is the path the HTTP resources are served under and is included in
+	// the HREFs returned to clients. Resources are served at their bare paths, e.g., '/users', when
+	// it's empty. See basepath.Clean.
+	BasePath string
+	// AbsoluteHREFs makes the HREFs and Location headers returned by the HTTP API absolute URLs, e.g.,
+	// 'https://mockvideo.kube/accountd/users/1', rather than paths
+	AbsoluteHREFs bool
+	// TrustForwardedHeaders uses the X-Forwarded-Proto, X-Forwarded-Host, and X-Forwarded-Prefix headers
+	// set by a proxy to build HREFs. It must only be set when every request is received via such a proxy.
+	TrustForwardedHeaders bool
+	// ClientAllowlist is a comma separated list of the clients, and client versions, that are
+	// labeled individually in request metrics and audit records, see clientinfo.NewAllowlist
+	ClientAllowlist string
+	// ErrorSummarySize is the number of error codes summarized by 'GET /admin/errors', see
+	// errsummary.NewSummary
+	ErrorSummarySize int
+	// AccessLogRules is a comma separated list of the rules deciding which requests are logged and
+	// counted by the access log, see accesslog.NewFilter
+	AccessLogRules string
+	// CacheControlRules is a comma separated list of the rules deciding which responses can be cached,
+	// and for how long, see cachecontrol.NewPolicy
+	CacheControlRules string
+	// AuthzPolicyFile enables the authorization policy engine when non-empty. The policy is read
+	// from this file, see package policy. AuthzDecisionLog logs every policy decision.
+	AuthzPolicyFile  string
+	AuthzDecisionLog bool
+	// accountd enters read-only mode after ReadOnlyWriteFailures consecutive failed writes. A
+	// trial write is allowed every ReadOnlyProbeInterval, read-only mode ends when one succeeds.
+	ReadOnlyWriteFailures int
+	ReadOnlyProbeInterval time.Duration
+	// API usage is tracked for up to UsageMaxAccounts accounts, 'GET /accounts/{id}/usage' reports
+	// the usage in the current UsageWindow as well as in total
+	UsageWindow      time.Duration
+	UsageMaxAccounts int
+	// RequestBodyTimeout is the time allowed to receive an HTTP request's body, requests whose bodies
+	// take longer fail with a 408 HTTP status. Zero doesn't limit the time allowed. See package bodytimeout.
+	RequestBodyTimeout time.Duration
+	// JSONLimits limit the nesting depth and the number of fields and array elements of JSON request
+	// bodies, more complex bodies fail with a 400 HTTP status. Zero doesn't limit them. See package jsonlimit.
+	JSONLimits jsonlimit.Limits
+	// The queries of the HTTP API's 'GET /users' and the gRPC API's GetUsers are bounded by these
+	// timeouts, independently of the time allowed for the request. Zero doesn't bound the query.
+	HTTPGetUsersQueryTimeout domain.QueryTimeout
+	GRPCGetUsersQueryTimeout domain.QueryTimeout
+	// 1 in GRPCLogSampleRate successful RPCs is logged. Failed RPCs, and RPCs taking at least
+	// GRPCSlowRPC, are always logged. Zero doesn't identify slow RPCs. See accesslog.RPCSampler.
+	GRPCLogSampleRate int
+	GRPCSlowRPC       time.Duration
+	// CanaryPercent is the percentage of HTTP requests routed to the canary, if Overrides.Canary
+	// provides one. See package canary.
+	CanaryPercent int
+}
+
+// NewConfig returns the Config described by 'configs' and 'secrets'. Missing or invalid
+// configuration items are replaced by their default values, see ConfigSchema.
+func NewConfig(configs, secrets map[string]string, logger logging.Logger) Config {
+	cfg := Config{
+		ProductionMode:           boolConfig(configs, "productionMode", logger),
+		DemoMode:                 boolConfig(configs, "demoMode", logger),
+		MetricsEnabled:           boolConfig(configs, "metricsEnabled", logger),
+		TracingEnabled:           boolConfig(configs, "tracingEnabled", logger),
+		TLSCert:                  secrets["tlsCert"],
+		TLSKey:                   secrets["tlsKey"],
+		GRPCRequireClientCert:    boolConfig(configs, "grpcRequireClientCert", logger),
+		TLSClientCA:              secrets["tlsClientCA"],
+		MaxBulkOps:               intConfig(configs, "maxConcurrentBulkOperations", logger),
+		BulkValidation:           stringConfig(configs, "bulkValidation"),
+		MaxReads:                 intConfig(configs, "maxConcurrentReads", logger),
+		MaxWrites:                intConfig(configs, "maxConcurrentWrites", logger),
+		WriteBehindRate:          intConfig(configs, "writeBehindRate", logger),
+		WriteBehindMaxAttempts:   intConfig(configs, "writeBehindMaxAttempts", logger),
+		ActivationTTL:            time.Duration(intConfig(configs, "activationTTLHours", logger)) * time.Hour,
+		ActivationExpiryInterval: time.Duration(intConfig(configs, "activationExpiryIntervalMins", logger)) * time.Minute,
+		PasswordHashCost:         intConfig(configs, "passwordHashCost", logger),
+		BillingdURL:              configs["billingdURL"],
+		SearchURL:                configs["searchURL"],
+		SearchIndex:              stringConfig(configs, "searchIndex"),
+		DownstreamTimeout:        time.Duration(intConfig(configs, "downstreamTimeoutMillis", logger)) * time.Millisecond,
+		DownstreamMaxRetries:     intConfig(configs, "downstreamMaxRetries", logger),
+		StatusBoardServices:      configs["statusBoardServices"],
+		AdminToken:               secrets["adminToken"],
+		APIKeys:                  secrets["apiKeys"],
+		JWTKeys:                  secrets["jwtKeys"],
+		SessionTTL:               time.Duration(intConfig(configs, "sessionTTLMinutes", logger)) * time.Minute,
+		ImpersonationTTL:         time.Duration(intConfig(configs, "impersonationTTLMinutes", logger)) * time.Minute,
+		HeapDumpDir:              configs["heapDumpDir"],
+		HeapDumpInterval:         time.Duration(intConfig(configs, "heapDumpIntervalSecs", logger)) * time.Second,
+		ExportDir:                configs["exportDir"],
+		ChangeLogSize:            intConfig(configs, "changeLogSize", logger),
+		ChangesWait:              time.Duration(intConfig(configs, "changesWaitSecs", logger)) * time.Second,
+		EventStreamBufferSize:    intConfig(configs, "eventStreamBufferSize", logger),
+		EventStreamPolicy:        stringConfig(configs, "eventStreamPolicy"),
+		ShutdownTimeout:          time.Duration(intConfig(configs, "shutdownTimeoutSecs", logger)) * time.Second,
+		DrainDelay:               time.Duration(intConfig(configs, "drainDelaySecs", logger)) * time.Second,
+		BasePath:                 configs["basePath"],
+		AbsoluteHREFs:            boolConfig(configs, "absoluteHREFs", logger),
+		TrustForwardedHeaders:    boolConfig(configs, "trustForwardedHeaders", logger),
+		ClientAllowlist:          stringConfig(configs, "clientAllowlist"),
+		ErrorSummarySize:         intConfig(configs, "errorSummarySize", logger),
+		AccessLogRules:           stringConfig(configs, "accessLogRules"),
+		CacheControlRules:        stringConfig(configs, "cacheControlRules"),
+		AuthzPolicyFile:          configs["authzPolicyFile"],
+		AuthzDecisionLog:         boolConfig(configs, "authzDecisionLog", logger),
+		ReadOnlyWriteFailures:    intConfig(configs, "readOnlyWriteFailures", logger),
+		ReadOnlyProbeInterval:    time.Duration(intConfig(configs, "readOnlyProbeSecs", logger)) * time.Second,
+		UsageWindow:              time.Duration(intConfig(configs, "usageWindowMins", logger)) * time.Minute,
+		UsageMaxAccounts:         intConfig(configs, "usageMaxAccounts", logger),
+		RequestBodyTimeout:       time.Duration(intConfig(configs, "requestBodyTimeoutMillis", logger)) * time.Millisecond,
+		JSONLimits: jsonlimit.Limits{
+			MaxDepth: intConfig(configs, "jsonMaxDepth", logger),
+			MaxItems: intConfig(configs, "jsonMaxItems", logger),
+		},
+		HTTPGetUsersQueryTimeout: domain.QueryTimeout{
+			Timeout: time.Duration(intConfig(configs, "httpGetUsersQueryTimeoutMillis", logger)) * time.Millisecond,
+			Partial: boolConfig(configs, "httpGetUsersPartialResults", logger),
+		},
+		GRPCGetUsersQueryTimeout: domain.QueryTimeout{
+			Timeout: time.Duration(intConfig(configs, "grpcGetUsersQueryTimeoutMillis", logger)) * time.Millisecond,
+			Partial: boolConfig(configs, "grpcGetUsersPartialResults", logger),
+		},
+		GRPCLogSampleRate: intConfig(configs, "grpcLogSampleRate", logger),
+		GRPCSlowRPC:       time.Duration(intConfig(configs, "grpcSlowRPCMillis", logger)) * time.Millisecond,
+		CanaryPercent:     intConfig(configs, "canaryPercent", logger),
+	}
+
+	if cfg.ImpersonationTTL > auth.MaxImpersonationTTL {
+		logger.Warnf("impersonationTTLMinutes <%s> exceeds the maximum, defaulting to %s", configs["impersonationTTLMinutes"], auth.MaxImpersonationTTL)
+		cfg.ImpersonationTTL = auth.MaxImpersonationTTL
+	}
+	if cfg.DrainDelay >= cfg.ShutdownTimeout {
+		logger.Warnf("drainDelaySecs <%s> leaves no time for the servers to stop within shutdownTimeoutSecs, defaulting to %s", configs["drainDelaySecs"], cfg.ShutdownTimeout/2)
+		cfg.DrainDelay = cfg.ShutdownTimeout / 2
+	}
+	if cfg.DemoMode && cfg.WriteBehindRate > 0 {
+		logger.Warnf("writeBehindRate <%s> ignored, write-behind mode requires the DB and is disabled in demo mode", configs["writeBehindRate"])
+		cfg.WriteBehindRate = 0
+	}
+
+	return cfg
+}
+
+// stringConfig returns the value of the configuration item identified by 'key', or its default
+// value if it isn't present
+func stringConfig(configs map[string]string, key string) string {
+	if val, ok := configs[key]; ok {
+		return val
+	}
+	k, _ := ConfigSchema.Key(key)
+	return k.Default
+}
+
+// intConfig returns the integer value of the configuration item identified by 'key'. Its default
+// value is returned if the configuration item isn't present or isn't a valid integer.
+func intConfig(configs map[string]string, key string, logger logging.Logger) int {
+	k, _ := ConfigSchema.Key(key)
+	defaultVal, _ := strconv.Atoi(k.Default)
+	valStr, ok := configs[key]
+	if !ok {
+		logger.Infof("%s configuration unavailable (configs[%s]), defaulting to %d", key, key, defaultVal)
+		return defaultVal
+	}
+	val, err := strconv.Atoi(valStr)
+	if err != nil {
+		logger.Warnf("%s <%s> invalid, defaulting to %d", key, valStr, defaultVal)
+		return defaultVal
+	}
+	return val
+}
+
+// boolConfig returns the boolean value of the configuration item identified by 'key'. Its default
+// value is returned if the configuration item isn't present or isn't a valid boolean.
+func boolConfig(configs map[string]string, key string, logger logging.Logger) bool {
+	k, _ := ConfigSchema.Key(key)
+	defaultVal, _ := strconv.ParseBool(k.Default)
+	valStr, ok := configs[key]
+	if !ok {
+		logger.Infof("%s configuration unavailable (configs[%s]), defaulting to %t", key, key, defaultVal)
+		return defaultVal
+	}
+	val, err := strconv.ParseBool(valStr)
+	if err != nil {
+		logger.Warnf("%s <%s> invalid, defaulting to %t", key, valStr, defaultVal)
+		return defaultVal
+	}
+	return val
+}
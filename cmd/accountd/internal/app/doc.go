@@ -0,0 +1,33 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package app constructs accountd's object graph, i.e., the repositories, services, HTTP handler, and
+gRPC server, from its configuration. It is accountd's composition root, 'main' only obtains the
+configuration and database connection, calls New, and starts the servers.
+
+The package follows the structure used by google/wire. Each component is built by a provider
+function (e.g., ProvideUserSvc) that takes its dependencies as parameters and returns the component
+and an error. New is the injector, it calls the providers in dependency order and maps any failure
+to an MVError. The injector is written by hand rather than generated because wire, or an alternative
+like uber/fx, would be a new module dependency for what is a small graph. If the graph grows New can
+be replaced by a generated injector without changing the providers.
+
+Components are swapped per environment using Overrides, e.g., a test can use the in-memory user
+repository ('internal/db/memory') and no database connection:
+
+	a, err := app.New(cfg, nil, logger, app.Overrides{
+		UserRepository: func(*sql.DB) (domain.UserRepository, error) { return memory.NewUserTable(), nil },
+	})
+
+A canary can be deployed, e.g., to roll out a new repository backend safely. Its repositories are
+replaced by Overrides.Canary and Config.CanaryPercent of the HTTP requests are routed to its HTTP handler,
+see ProvideCanaryHandler and package 'internal/canary'. Only the repositories, the services using them, and
+the handler are constructed for the canary, it shares everything else, e.g., the event bus, change log, and
+bulkheads, with the primary.
+
+Optional components (billingd invoices, impersonation, and write-behind mode) are enabled by the
+Config. Their providers return nil if they are disabled.
+*/
+package app
@@ -0,0 +1,159 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"strings"
+
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/protocol"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+// defaultDBPasswords are well known DB passwords, e.g., the one used by the sample secrets, that
+// production mode refuses to start with
+var defaultDBPasswords = []string{"admin", "changeme", "mysql", "password", "root"}
+
+// TLSConfig returns the TLS configuration described by TLSCert and TLSKey. It returns nil if
+// neither is configured.
+func (cfg Config) TLSConfig() (*tls.Config, error) {
+	if cfg.TLSCert == "" && cfg.TLSKey == "" {
+		return nil, nil
+	}
+	if cfg.TLSCert == "" || cfg.TLSKey == "" {
+		return nil, errors.New("both the 'tlsCert' and 'tlsKey' secrets are required to serve TLS")
+	}
+	cert, err := tls.X509KeyPair([]byte(cfg.TLSCert), []byte(cfg.TLSKey))
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// GRPCTLSConfig returns the TLS configuration the gRPC server uses, TLSConfig's, requiring clients
+// to present a certificate signed by one of the CAs in TLSClientCA if GRPCRequireClientCert is set.
+// It returns nil if TLS isn't configured, and an error if client certificates are required without
+// TLS or a CA.
+func (cfg Config) GRPCTLSConfig() (*tls.Config, error) {
+	tlsCfg, err := cfg.TLSConfig()
+	if err != nil || !cfg.GRPCRequireClientCert {
+		return tlsCfg, err
+	}
+	if tlsCfg == nil {
+		return nil, errors.New("the 'tlsCert' and 'tlsKey' secrets are required to require client certificates")
+	}
+	if cfg.TLSClientCA == "" {
+		return nil, errors.New("the 'tlsClientCA' secret is required to require client certificates")
+	}
+	cas := x509.NewCertPool()
+	if !cas.AppendCertsFromPEM([]byte(cfg.TLSClientCA)) {
+		return nil, errors.New("the 'tlsClientCA' secret doesn't contain any PEM encoded certificates")
+	}
+	tlsCfg.ClientCAs = cas
+	tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsCfg, nil
+}
+
+// Harden applies the production hardening profile to 'cfg' if cfg.ProductionMode is set. It
+// returns the hardened Config and a checklist of the protections that were applied, or an error
+// listing every requirement that isn't met. In production mode:
+//
+//   - TLS must be configured, for the gRPC server too if 'protocols' includes protocol.GRPC
+//   - Debug endpoints, i.e., 'POST /admin/debug/heapdump' and the '/admin/requests' endpoints, and
+//     the gRPC server reflection service are disabled
+//   - Authentication must be configured, i.e., the 'jwtKeys' or 'apiKeys' secret, so that every
+//     request to the users and accounts endpoints, and every RPC, must identify its caller
+//   - 'dbPassword' must not be empty or a well known default
+//
+// 'cfg' is returned unchanged, with no checklist, if cfg.ProductionMode isn't set.
+func Harden(cfg Config, dbPassword string, protocols protocol.Set) (Config, []string, *mverr.MVError) {
+	if !cfg.ProductionMode {
+		return cfg, nil, nil
+	}
+
+	var unmet, applied []string
+
+	tlsCfg, err := cfg.TLSConfig()
+	if protocols.Has(protocol.GRPC) {
+		// The gRPC server's TLS configuration also includes its client CAs, if any
+		tlsCfg, err = cfg.GRPCTLSConfig()
+	}
+	switch {
+	case err != nil:
+		unmet = append(unmet, "invalid TLS configuration: "+err.Error())
+	case tlsCfg == nil:
+		unmet = append(unmet, "TLS isn't configured, the 'tlsCert' and 'tlsKey' secrets are required")
+	default:
+		if protocols.Has(protocol.HTTP) {
+			applied = append(applied, "TLS enforced for all HTTP listeners")
+		}
+		if protocols.Has(protocol.GRPC) {
+			applied = append(applied, "TLS enforced for all gRPC listeners")
+		}
+		if protocols.Has(protocol.GRPC) && cfg.GRPCRequireClientCert {
+			applied = append(applied, "gRPC clients must present a certificate signed by a CA in 'tlsClientCA'")
+		}
+	}
+
+	if cfg.HeapDumpDir != "" {
+		cfg.HeapDumpDir = ""
+		applied = append(applied, "debug endpoints disabled, 'heapDumpDir' ignored")
+	} else {
+		applied = append(applied, "debug endpoints disabled")
+	}
+	if protocols.Has(protocol.GRPC) {
+		applied = append(applied, "gRPC server reflection disabled")
+	}
+
+	// The admin token and the authorization policy don't identify the callers of the users and
+	// accounts endpoints, only JWTs and API keys do
+	switch {
+	case cfg.JWTKeys == "" && cfg.APIKeys == "":
+		unmet = append(unmet, "authentication isn't configured, the 'jwtKeys' or 'apiKeys' secret is required")
+	case cfg.JWTKeys == "":
+		applied = append(applied, "requests must be made with an API key")
+	case cfg.APIKeys == "":
+		applied = append(applied, "requests must be made with a JWT")
+	default:
+		applied = append(applied, "requests must be made with a JWT or an API key")
+	}
+	if cfg.AuthzPolicyFile != "" {
+		applied = append(applied, "requests authorized by the policy in "+cfg.AuthzPolicyFile)
+	}
+
+	if cfg.DemoMode {
+		applied = append(applied, "demo mode, the DB isn't used")
+	} else if problem := checkDBPassword(dbPassword); problem != "" {
+		unmet = append(unmet, problem)
+	} else {
+		applied = append(applied, "DB password isn't empty or a well known default")
+	}
+
+	if len(unmet) > 0 {
+		return cfg, nil, &mverr.MVError{
+			ErrCode:   mverr.ProductionModeErrorCode,
+			ErrMsg:    mverr.ProductionModeErrorMsg,
+			ErrDetail: strings.Join(unmet, "; "),
+		}
+	}
+	return cfg, applied, nil
+}
+
+// checkDBPassword returns the production mode requirement that 'dbPassword' doesn't meet, or ""
+// if it meets them all
+func checkDBPassword(dbPassword string) string {
+	pw := strings.TrimSpace(dbPassword)
+	if pw == "" {
+		return "the DB password is empty"
+	}
+	for _, d := range defaultDBPasswords {
+		if strings.EqualFold(pw, d) {
+			return "the DB password is a well known default"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,670 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"database/sql"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	grpcuser "github.com/youngkin/mockvideo/cmd/accountd/grpc/users"
+	handlers "github.com/youngkin/mockvideo/cmd/accountd/http"
+	"github.com/youngkin/mockvideo/cmd/accountd/http/accounts"
+	"github.com/youngkin/mockvideo/cmd/accountd/http/admin"
+	"github.com/youngkin/mockvideo/cmd/accountd/http/users"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/accesslog"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/basepath"
+	"github.com/youngkin/mockvideo/internal/blob"
+	"github.com/youngkin/mockvideo/internal/bodytimeout"
+	"github.com/youngkin/mockvideo/internal/cachecontrol"
+	"github.com/youngkin/mockvideo/internal/canary"
+	"github.com/youngkin/mockvideo/internal/clientinfo"
+	userdb "github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/demo"
+	"github.com/youngkin/mockvideo/internal/domain"
+	"github.com/youngkin/mockvideo/internal/errsummary"
+	"github.com/youngkin/mockvideo/internal/eventbus"
+	"github.com/youngkin/mockvideo/internal/eventschema"
+	"github.com/youngkin/mockvideo/internal/httpclient"
+	"github.com/youngkin/mockvideo/internal/inflight"
+	"github.com/youngkin/mockvideo/internal/jsonlimit"
+	"github.com/youngkin/mockvideo/internal/lifecycle"
+	"github.com/youngkin/mockvideo/internal/locale"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"github.com/youngkin/mockvideo/internal/policy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+//
+// Each provider constructs one component of the object graph from its dependencies. A provider
+// returns a nil component, and a nil error, if the component is disabled by the configuration.
+//
+
+// ProvideUserRepository returns the MySQL backed UserRepository
+func ProvideUserRepository(db *sql.DB) (domain.UserRepository, error) {
+	return userdb.NewTable(db)
+}
+
+// ProvideDemoUserRepository returns the frozen UserRepository holding demo mode's synthetic users, see
+// package demo. 'db' isn't used.
+func ProvideDemoUserRepository(db *sql.DB) (domain.UserRepository, error) {
+	return demo.NewUserTable()
+}
+
+// ProvideUserQueueRepository returns the MySQL backed UserQueueRepository used in write-behind mode
+func ProvideUserQueueRepository(cfg Config, db *sql.DB) (domain.UserQueueRepository, error) {
+	if cfg.WriteBehindRate <= 0 {
+		return nil, nil
+	}
+	return userdb.NewQueueTable(db)
+}
+
+// ProvideDeadLetterRepository returns the MySQL backed DeadLetterRepository. It's disabled unless
+// 'queue', the source of dead letters, is MySQL backed.
+func ProvideDeadLetterRepository(db *sql.DB, queue domain.UserQueueRepository) (domain.DeadLetterRepository, error) {
+	if _, ok := queue.(*userdb.QueueTable); !ok {
+		return nil, nil
+	}
+	return userdb.NewDeadLetterTable(db)
+}
+
+// ProvideAccountRepository returns the MySQL backed AccountRepository used by signups. It's nil,
+// disabling signups, if 'repo' isn't MySQL backed, e.g., it's been replaced in Overrides.
+func ProvideAccountRepository(db *sql.DB, repo domain.UserRepository) (domain.AccountRepository, error) {
+	if _, ok := repo.(*userdb.Table); !ok {
+		return nil, nil
+	}
+	return userdb.NewAccountTable(db)
+}
+
+// ProvideUnitOfWork returns the UnitOfWork the UserSvc uses to apply multi-step operations atomically.
+// It's nil if 'repo' isn't MySQL backed, e.g., it's been replaced in Overrides.
+func ProvideUnitOfWork(db *sql.DB, repo domain.UserRepository, queue domain.UserQueueRepository, accounts domain.AccountRepository) (domain.UnitOfWork, error) {
+	users, ok := repo.(*userdb.Table)
+	if !ok {
+		return nil, nil
+	}
+	queueTbl, _ := queue.(*userdb.QueueTable)
+	uow, err := userdb.NewUnitOfWork(db, users, queueTbl)
+	if err != nil {
+		return nil, err
+	}
+	if accountTbl, ok := accounts.(*userdb.AccountTable); ok {
+		if err = uow.SetAccountTable(accountTbl); err != nil {
+			return nil, err
+		}
+	}
+	return uow, nil
+}
+
+// ProvideReadOnlyRepository returns 'repo' with writes protected by read-only mode, see
+// userdb.ReadOnlyRepository. Like the circuit breaker it doesn't protect the UnitOfWork's
+// repositories.
+func ProvideReadOnlyRepository(cfg Config, repo domain.UserRepository) (*userdb.ReadOnlyRepository, error) {
+	breaker, err := httpclient.NewBreaker(cfg.ReadOnlyWriteFailures, cfg.ReadOnlyProbeInterval)
+	if err != nil {
+		return nil, err
+	}
+	return userdb.NewReadOnlyRepository(repo, breaker)
+}
+
+// ProvideBreakerRepository returns 'repo' protected by a circuit breaker. While the breaker is open
+// requests fail immediately rather than waiting on an unavailable DB. The UnitOfWork's repositories
+// aren't protected, see ProvideUnitOfWork.
+func ProvideBreakerRepository(repo domain.UserRepository) (domain.UserRepository, error) {
+	breaker, err := httpclient.NewBreaker(5, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return userdb.NewBreakerRepository(repo, breaker)
+}
+
+// ProvideChangeLog returns the ChangeLog that user changes are recorded in for 'GET /users/changes'
+func ProvideChangeLog(cfg Config) (*services.ChangeLog, error) {
+	return services.NewChangeLog(cfg.ChangeLogSize, cfg.ChangesWait)
+}
+
+// ProvideEventBus returns the Bus user lifecycle events are published to. Payloads that don't match
+// their topic's registered schema, see services.RegisterEventSchemas, aren't published.
+func ProvideEventBus() (*eventbus.Bus, error) {
+	schemas := eventschema.NewRegistry()
+	if err := services.RegisterEventSchemas(schemas); err != nil {
+		return nil, err
+	}
+	bus := eventbus.New()
+	if err := bus.SetValidator(schemas); err != nil {
+		return nil, err
+	}
+	return bus, nil
+}
+
+// ProvideDrain returns the Drain that marks accountd as draining when it's asked to shut down, so
+// '/readyz' and the gRPC health service report that it's no longer ready for cfg.DrainDelay before
+// the servers are stopped
+func ProvideDrain(cfg Config) (*lifecycle.Drain, error) {
+	return lifecycle.NewDrain(cfg.DrainDelay)
+}
+
+// ProvideUserSvc returns the UserSvc. Write-behind mode is enabled if 'queue' is non-nil, dead
+// letters if 'deadLetters' is non-nil, signups if 'accounts' is non-nil, and multi-step operations
+// are only atomic if 'uow' is non-nil. User changes are recorded in 'changes' and published to 'events'.
+func ProvideUserSvc(cfg Config, repo domain.UserRepository, queue domain.UserQueueRepository, deadLetters domain.DeadLetterRepository, accounts domain.AccountRepository, uow domain.UnitOfWork, changes *services.ChangeLog, events *eventbus.Bus, logger logging.Logger) (*services.UserSvc, error) {
+	userSvc, err := services.NewUserSvc(repo, logger, cfg.MaxBulkOps, cfg.MaxReads, cfg.MaxWrites)
+	if err != nil {
+		return nil, err
+	}
+
+	// TODO: Replace the LogMailer with one that actually sends email
+	mailer, err := services.NewLogMailer(logger)
+	if err != nil {
+		return nil, err
+	}
+	if err = userSvc.ConfigureActivation(mailer, cfg.ActivationTTL); err != nil {
+		return nil, err
+	}
+	if err = userSvc.SetPasswordCost(cfg.PasswordHashCost); err != nil {
+		return nil, err
+	}
+
+	if err = userSvc.SetChangeLog(changes); err != nil {
+		return nil, err
+	}
+	if err = userSvc.SetEventBus(events); err != nil {
+		return nil, err
+	}
+	bulkValidation, err := services.ParseBulkValidation(cfg.BulkValidation)
+	if err != nil {
+		return nil, err
+	}
+	if err = userSvc.SetBulkValidation(bulkValidation); err != nil {
+		return nil, err
+	}
+
+	if queue != nil {
+		userSvc.EnableWriteBehind(queue)
+	}
+	if deadLetters != nil {
+		userSvc.EnableDeadLetters(deadLetters)
+	}
+	if accounts != nil {
+		userSvc.EnableSignup(accounts)
+	}
+	if uow != nil {
+		if err = userSvc.SetUnitOfWork(uow); err != nil {
+			return nil, err
+		}
+	}
+	return userSvc, nil
+}
+
+// ProvideActivationExpirer returns the ActivationExpirer that deletes users that weren't activated in time
+func ProvideActivationExpirer(cfg Config, userSvc *services.UserSvc, logger logging.Logger) (*services.ActivationExpirer, error) {
+	return services.NewActivationExpirer(userSvc, logger, cfg.ActivationExpiryInterval)
+}
+
+// ProvideWriteBehindWorker returns the WriteBehindWorker that applies queued user creations
+func ProvideWriteBehindWorker(cfg Config, queue domain.UserQueueRepository, userSvc *services.UserSvc, logger logging.Logger) (*services.WriteBehindWorker, error) {
+	if queue == nil {
+		return nil, nil
+	}
+	w, err := services.NewWriteBehindWorker(queue, userSvc, logger, cfg.WriteBehindRate)
+	if err != nil {
+		return nil, err
+	}
+	if err = w.SetRetryPolicy(cfg.WriteBehindMaxAttempts, services.DefaultWriteBehindRetryBackoff); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// ProvideInvoiceSvc returns an InvoiceSvc for billingd. Requests to billingd are protected by a
+// circuit breaker.
+func ProvideInvoiceSvc(cfg Config) (services.InvoiceSvc, error) {
+	if cfg.BillingdURL == "" {
+		return nil, nil
+	}
+	breaker, err := httpclient.NewBreaker(5, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	client, err := httpclient.New("billingd", cfg.DownstreamTimeout, cfg.DownstreamMaxRetries, breaker)
+	if err != nil {
+		return nil, err
+	}
+	return services.NewBillingdInvoiceSvc(cfg.BillingdURL, client)
+}
+
+// ProvideStatusBoardSvc returns the StatusBoardSvc for 'GET /statusboard'. Each service is checked
+// using its own client, with its own circuit breaker, and checks aren't retried so the status board
+// responds promptly. It's nil if no services are configured.
+func ProvideStatusBoardSvc(cfg Config) (*services.StatusBoardSvc, error) {
+	if cfg.StatusBoardServices == "" {
+		return nil, nil
+	}
+	targets, err := services.ParseHealthTargets(cfg.StatusBoardServices)
+	if err != nil {
+		return nil, err
+	}
+	clients := []*httpclient.Client{}
+	for _, t := range targets {
+		breaker, err := httpclient.NewBreaker(5, 30*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		client, err := httpclient.New(t.Name, cfg.DownstreamTimeout, 0, breaker)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	return services.NewStatusBoardSvc(targets, clients)
+}
+
+// ProvideUserIndex returns the search index users are mirrored to for 'GET /users/search' and sets
+// it as 'userSvc's UserSearcher. Requests to the search cluster are protected by a circuit breaker.
+// It's nil, and the DB is searched, if no search cluster is configured.
+func ProvideUserIndex(cfg Config, userSvc *services.UserSvc, changes *services.ChangeLog, logger logging.Logger) (*services.ElasticsearchUserIndex, error) {
+	if cfg.SearchURL == "" {
+		return nil, nil
+	}
+	breaker, err := httpclient.NewBreaker(5, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	client, err := httpclient.New("search", cfg.DownstreamTimeout, cfg.DownstreamMaxRetries, breaker)
+	if err != nil {
+		return nil, err
+	}
+	index, err := services.NewElasticsearchUserIndex(cfg.SearchURL, cfg.SearchIndex, client, userSvc, changes, logger)
+	if err != nil {
+		return nil, err
+	}
+	if err = userSvc.SetSearcher(index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// ProvideErrorSummary returns the Summary of the errors logged by accountd for 'GET /admin/errors'
+func ProvideErrorSummary(cfg Config) (*errsummary.Summary, error) {
+	return errsummary.NewSummary(cfg.ErrorSummarySize)
+}
+
+// ProvideUsageTracker returns the UsageTracker that API usage is recorded in for 'GET /accounts/{id}/usage'
+func ProvideUsageTracker(cfg Config) (*services.UsageTracker, error) {
+	return services.NewUsageTracker(cfg.UsageWindow, cfg.UsageMaxAccounts)
+}
+
+// ProvideAccountSvc returns the AccountSvc. Account summaries don't include invoices if 'invoiceSvc'
+// is nil. Account usage is reported by 'usage'.
+func ProvideAccountSvc(userSvc *services.UserSvc, invoiceSvc services.InvoiceSvc, usage *services.UsageTracker, logger logging.Logger) (*services.AccountSvc, error) {
+	accountSvc, err := services.NewAccountSvc(userSvc, invoiceSvc, logger)
+	if err != nil {
+		return nil, err
+	}
+	if err = accountSvc.SetUsageReporter(usage); err != nil {
+		return nil, err
+	}
+	return accountSvc, nil
+}
+
+// ProvideImpersonations returns the Impersonations used by support staff to act as a user. The
+// tokens for a user are revoked when 'userSvc' deletes the user.
+func ProvideImpersonations(cfg Config, userSvc *services.UserSvc) (*auth.Impersonations, error) {
+	if cfg.AdminToken == "" {
+		return nil, nil
+	}
+	impersonations, err := auth.NewImpersonations(cfg.AdminToken, cfg.ImpersonationTTL)
+	if err != nil {
+		return nil, err
+	}
+	if err = userSvc.SetImpersonations(impersonations); err != nil {
+		return nil, err
+	}
+	return impersonations, nil
+}
+
+// ProvideAPIKeys returns the APIKeys used by services to call accountd, or nil if none are configured
+func ProvideAPIKeys(cfg Config) (*auth.APIKeys, error) {
+	if cfg.APIKeys == "" {
+		return nil, nil
+	}
+	return auth.ParseAPIKeys(strings.NewReader(cfg.APIKeys))
+}
+
+// ProvideJWTKeys returns the JWTKeys users' tokens are signed with, or nil if none are configured.
+// When there are keys users can log in via 'userSvc', which issues them session tokens valid for cfg.SessionTTL.
+func ProvideJWTKeys(cfg Config, userSvc *services.UserSvc) (*auth.JWTKeys, error) {
+	if cfg.JWTKeys == "" {
+		return nil, nil
+	}
+	keys, err := auth.ParseJWTKeys(strings.NewReader(cfg.JWTKeys))
+	if err != nil {
+		return nil, err
+	}
+	if err = userSvc.EnableLogin(keys, cfg.SessionTTL); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// ProvidePolicyEngine returns the authorization policy Engine. It's disabled if no policy file is configured.
+func ProvidePolicyEngine(cfg Config, logger logging.Logger) (*policy.Engine, error) {
+	if cfg.AuthzPolicyFile == "" {
+		return nil, nil
+	}
+	f, err := os.Open(cfg.AuthzPolicyFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rules, err := policy.Parse(f)
+	if err != nil {
+		return nil, err
+	}
+	engine, err := policy.NewEngine(rules)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.AuthzDecisionLog {
+		if err = engine.SetDecisionLog(logger); err != nil {
+			return nil, err
+		}
+	}
+	return engine, nil
+}
+
+// ProvideBlobStore returns the Store used for diagnostic artifacts, e.g., heap dumps
+func ProvideBlobStore(cfg Config) (blob.Store, error) {
+	if cfg.HeapDumpDir == "" {
+		return nil, nil
+	}
+	return blob.NewFileStore(cfg.HeapDumpDir)
+}
+
+// ProvideExportSvc returns the ExportSvc, or nil if account exports aren't configured
+func ProvideExportSvc(cfg Config, userSvc *services.UserSvc, logger logging.Logger) (*services.ExportSvc, error) {
+	if cfg.ExportDir == "" {
+		return nil, nil
+	}
+	store, err := blob.NewFileStore(cfg.ExportDir)
+	if err != nil {
+		return nil, err
+	}
+	return services.NewExportSvc(userSvc, store, logger)
+}
+
+// ProvideHTTPHandler returns the handler for all of the HTTP API's endpoints. The admin endpoints are
+// disabled if 'impersonations' is nil, and heap dumps are also disabled if 'store' is nil. Account
+// exports are disabled if 'exportSvc' is nil, and the dead letter endpoints are also disabled if
+// 'deadLetters' is nil. 'GET /admin/errors' reports 'errSummary'. The status board is disabled if 'statusBoard' is nil. Callers of the users and accounts
+// endpoints are identified by impersonation tokens, if the admin endpoints are enabled, or 'apiKeys', if non-nil,
+// and requests to them without a token are rejected.
+// If 'jwtKeys' is non-nil callers of the users and accounts endpoints can also be identified by a JWT, and requests
+// to them that aren't identified are rejected, 'POST /login' issues users JWTs. User activations are never
+// authenticated, pending users can't log in. Identified callers' scopes are checked, and the authorization policy is evaluated if 'engine' is non-nil.
+// Requests to the users and accounts endpoints are recorded in 'usage', including those denied by the policy.
+// All requests are access logged unless excluded by cfg.AccessLogRules. Responses aren't cached unless
+// allowed by cfg.CacheControlRules. Requests are tracked while they're in-flight so the admin endpoints
+// can list and cancel them, unless in production mode. In demo mode requests that would change the users are rejected, see package demo.
+// 'middleware' is applied to the handler in order, i.e., the last middleware sees a request first.
+func ProvideHTTPHandler(cfg Config, userSvc *services.UserSvc, accountSvc *services.AccountSvc, exportSvc *services.ExportSvc, impersonations *auth.Impersonations, apiKeys *auth.APIKeys, jwtKeys *auth.JWTKeys, engine *policy.Engine, store blob.Store, deadLetters domain.DeadLetterRepository, readOnly handlers.ReadOnlyReporter, drain handlers.DrainReporter, usage *services.UsageTracker, events *eventbus.Bus, statusBoard *services.StatusBoardSvc, errSummary *errsummary.Summary, logger logging.Logger, middleware []func(http.Handler) http.Handler) (http.Handler, error) {
+	usersHandler, err := users.NewUserHandler(userSvc, logger, cfg.MaxBulkOps, cfg.WriteBehindRate > 0, cfg.HTTPGetUsersQueryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	eventsPolicy, err := eventbus.ParseSlowConsumerPolicy(cfg.EventStreamPolicy)
+	if err != nil {
+		return nil, err
+	}
+	eventsHandler, err := users.NewEventsHandler(events, cfg.EventStreamBufferSize, eventsPolicy, cfg.ChangesWait, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var allowlistEntries []string
+	if cfg.ClientAllowlist != "" {
+		allowlistEntries = strings.Split(cfg.ClientAllowlist, ",")
+	}
+	allowlist, err := clientinfo.NewAllowlist(allowlistEntries)
+	if err != nil {
+		return nil, err
+	}
+	var accessLogRules []string
+	if cfg.AccessLogRules != "" {
+		accessLogRules = strings.Split(cfg.AccessLogRules, ",")
+	}
+	accessLogFilter, err := accesslog.NewFilter(accessLogRules)
+	if err != nil {
+		return nil, err
+	}
+	var cacheControlRules []string
+	if cfg.CacheControlRules != "" {
+		cacheControlRules = strings.Split(cfg.CacheControlRules, ",")
+	}
+	cachePolicy, err := cachecontrol.NewPolicy(cacheControlRules)
+	if err != nil {
+		return nil, err
+	}
+
+	// Avoid a non-nil interface holding a nil *ExportSvc
+	var exports services.ExportSvcInterface
+	if exportSvc != nil {
+		exports = exportSvc
+	}
+	accountsHandler, err := accounts.NewAccountHandler(userSvc, accountSvc, exports, logger)
+	if err != nil {
+		return nil, err
+	}
+	signupHandler, err := accounts.NewSignupHandler(userSvc, logger)
+	if err != nil {
+		return nil, err
+	}
+	// Users logging in aren't identified yet, so none of the auth middleware is applied
+	loginHandler, err := users.NewLoginHandler(userSvc, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	tracker := inflight.NewTracker()
+	// Pending users can't log in, so activations, like logins and signups, aren't authenticated
+	activationHandler := usersHandler
+
+	// The policy is evaluated once JWTMiddleware, APIKeyMiddleware, or ImpersonationMiddleware has
+	// identified the caller, and usage is recorded for the caller's account
+	if impersonations != nil || apiKeys != nil || jwtKeys != nil {
+		usersHandler = policy.Middleware(engine, logger)(usersHandler)
+		eventsHandler = policy.Middleware(engine, logger)(eventsHandler)
+		accountsHandler = policy.Middleware(engine, logger)(accountsHandler)
+	}
+	usersHandler = accounts.UsageMiddleware(usage, usersHandler)
+	eventsHandler = accounts.UsageMiddleware(usage, eventsHandler)
+	accountsHandler = accounts.UsageMiddleware(usage, accountsHandler)
+
+	if impersonations != nil {
+		impersonationHandler, err := admin.NewImpersonationHandler(userSvc, impersonations, logger)
+		if err != nil {
+			return nil, err
+		}
+		mux.Handle("/admin/impersonate", impersonationHandler)
+		if store != nil {
+			heapDumpHandler, err := admin.NewHeapDumpHandler(impersonations, store, cfg.HeapDumpInterval, logger)
+			if err != nil {
+				return nil, err
+			}
+			mux.Handle("/admin/debug/heapdump", heapDumpHandler)
+		}
+		if deadLetters != nil {
+			deadLetterHandler, err := admin.NewDeadLetterHandler(impersonations, userSvc, logger)
+			if err != nil {
+				return nil, err
+			}
+			mux.Handle("/admin/deadletters", deadLetterHandler)
+			mux.Handle("/admin/deadletters/", deadLetterHandler)
+		}
+		// Like the heap dumps, the in-flight requests are debug endpoints, disabled in production mode
+		if !cfg.ProductionMode {
+			requestsHandler, err := admin.NewRequestsHandler(impersonations, tracker, logger)
+			if err != nil {
+				return nil, err
+			}
+			mux.Handle("/admin/requests", requestsHandler)
+			mux.Handle("/admin/requests/", requestsHandler)
+		}
+		errorsHandler, err := admin.NewErrorsHandler(impersonations, errSummary, logger)
+		if err != nil {
+			return nil, err
+		}
+		mux.Handle("/admin/errors", errorsHandler)
+		usersHandler = admin.ImpersonationMiddleware(impersonations, logger, usersHandler)
+		eventsHandler = admin.ImpersonationMiddleware(impersonations, logger, eventsHandler)
+		accountsHandler = admin.ImpersonationMiddleware(impersonations, logger, accountsHandler)
+	}
+	if apiKeys != nil {
+		usersHandler = admin.APIKeyMiddleware(apiKeys, impersonations != nil, logger, usersHandler)
+		eventsHandler = admin.APIKeyMiddleware(apiKeys, impersonations != nil, logger, eventsHandler)
+		accountsHandler = admin.APIKeyMiddleware(apiKeys, impersonations != nil, logger, accountsHandler)
+	}
+	if jwtKeys != nil {
+		usersHandler = admin.JWTMiddleware(jwtKeys, impersonations != nil || apiKeys != nil, logger, usersHandler)
+		eventsHandler = admin.JWTMiddleware(jwtKeys, impersonations != nil || apiKeys != nil, logger, eventsHandler)
+		accountsHandler = admin.JWTMiddleware(jwtKeys, impersonations != nil || apiKeys != nil, logger, accountsHandler)
+	}
+
+	healthHandler := http.HandlerFunc(handlers.HealthFunc)
+
+	usersHandler = users.ActivationMiddleware(activationHandler, usersHandler)
+
+	mux.Handle("/users", usersHandler)  // Desired to prevent redirects. Can remove if redirects for '/users/' are OK
+	mux.Handle("/users/", usersHandler) // Required to properly route requests to '/users/{id}. Don't understand why the above route isn't sufficient
+	mux.Handle("/users/events", eventsHandler)
+	mux.Handle("/accounts/", accountsHandler)
+	mux.Handle("/signup", signupHandler)
+	mux.Handle("/login", loginHandler)
+	mux.Handle("/accountdhealth", healthHandler)
+	mux.Handle("/readyz", handlers.NewReadyHandler(readOnly, drain))
+	if statusBoard != nil {
+		statusBoardHandler, err := handlers.NewStatusBoardHandler(statusBoard)
+		if err != nil {
+			return nil, err
+		}
+		mux.Handle("/statusboard", statusBoardHandler)
+	}
+	resources := []string{"/users", "/accounts/{id}", "/signup", "/login", "/accountdhealth", "/readyz"}
+	if cfg.MetricsEnabled {
+		mux.Handle("/metrics", promhttp.Handler())
+		resources = append(resources, "/metrics")
+	}
+	base, err := basepath.Clean(cfg.BasePath)
+	if err != nil {
+		return nil, err
+	}
+	for i := range resources {
+		resources[i] = base + resources[i]
+	}
+	// The admin endpoints aren't advertised
+	notFoundHandler, err := handlers.NewNotFoundHandler(resources, logger)
+	if err != nil {
+		return nil, err
+	}
+	basePath, err := basepath.Middleware(base, notFoundHandler)
+	if err != nil {
+		return nil, err
+	}
+	mux.Handle("/", notFoundHandler)
+
+	var h http.Handler = mux
+	for _, m := range middleware {
+		h = m(h)
+	}
+	if cfg.DemoMode {
+		h = demo.Middleware(h)
+	}
+	h = cachecontrol.Middleware(cachePolicy)(h)
+	// Reads of request bodies stop when the request is cancelled, so the tracker is applied first
+	h = jsonlimit.Middleware(cfg.JSONLimits)(h)
+	h = bodytimeout.Middleware(cfg.RequestBodyTimeout)(h)
+	h = inflight.Middleware(tracker)(h)
+	h = accesslog.Middleware(accessLogFilter, logger)(locale.Middleware(clientinfo.Middleware(allowlist)(h)))
+	// The base path is removed first so that everything else, e.g., the access log rules, sees bare paths.
+	// The request's location is added to its context so that handlers can build HREFs, see basepath.HREF.
+	h = basepath.LocationMiddleware(cfg.AbsoluteHREFs, cfg.TrustForwardedHeaders)(basePath(h))
+	return httpclient.TraceMiddleware(h), nil
+}
+
+// ProvideCanaryHandler returns 'primary' routing cfg.CanaryPercent percent of the requests, and those
+// with an "X-Canary: true" header, to 'canaryHandler' instead, see package canary. 'primary' is returned
+// as is if 'canaryHandler' is nil.
+func ProvideCanaryHandler(cfg Config, primary, canaryHandler http.Handler) http.Handler {
+	return canary.Middleware(cfg.CanaryPercent, canaryHandler)(primary)
+}
+
+// ProvideGRPCServer returns the gRPC server with the UserServer and, unless in production mode, the server
+// reflection service registered, the latter lets tools such as 'accountctl grpc' discover the API. Callers are identified
+// by 'apiKeys', if non-nil, RPCs other than health checks and logins without a key fail, and their requests' scopes are checked and evaluated against the authorization
+// policy unless 'engine' is nil. If 'jwtKeys' is non-nil callers can also be identified by a JWT, and RPCs,
+// other than health checks, that aren't identified fail. In demo mode requests that would change the users are rejected.
+// RPCs are logged as sampled by cfg.GRPCLogSampleRate and cfg.GRPCSlowRPC, see accesslog.RPCSampler.
+// The standard gRPC health service is registered too, it reports NOT_SERVING once 'drain' starts
+// draining. The server serves TLS if it's configured, see Config.GRPCTLSConfig.
+func ProvideGRPCServer(cfg Config, userSvc *services.UserSvc, apiKeys *auth.APIKeys, jwtKeys *auth.JWTKeys, engine *policy.Engine, drain *lifecycle.Drain, logger logging.Logger) (*grpc.Server, error) {
+	usersServer, err := grpcuser.NewUserServer(userSvc, logger, cfg.GRPCGetUsersQueryTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := basepath.Clean(cfg.BasePath)
+	if err != nil {
+		return nil, err
+	}
+	rpcSampler, err := accesslog.NewRPCSampler(cfg.GRPCLogSampleRate, cfg.GRPCSlowRPC)
+	if err != nil {
+		return nil, err
+	}
+	interceptors := []grpc.UnaryServerInterceptor{accesslog.UnaryServerInterceptor(rpcSampler, logger)}
+	if base != "" {
+		interceptors = append(interceptors, basepath.UnaryServerInterceptor(base))
+	}
+	if jwtKeys != nil {
+		interceptors = append(interceptors, auth.JWTUnaryServerInterceptor(jwtKeys, apiKeys != nil, logger))
+	}
+	if apiKeys != nil {
+		interceptors = append(interceptors, auth.UnaryServerInterceptor(apiKeys, logger))
+	}
+	if apiKeys != nil || jwtKeys != nil || engine != nil {
+		interceptors = append(interceptors, policy.UnaryServerInterceptor(engine, logger))
+	}
+	if cfg.DemoMode {
+		interceptors = append(interceptors, demo.UnaryServerInterceptor())
+	}
+	opts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(interceptors...)}
+	tlsCfg, err := cfg.GRPCTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsCfg != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+	s := grpc.NewServer(opts...)
+	grpcuser.RegisterUserServerServer(s, usersServer)
+	healthServer := health.NewServer()
+	drain.OnDrain(healthServer.Shutdown)
+	healthpb.RegisterHealthServer(s, healthServer)
+	if !cfg.ProductionMode {
+		reflection.Register(s)
+	}
+	return s, nil
+}
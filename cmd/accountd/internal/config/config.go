@@ -8,41 +8,122 @@ import (
 	"bufio"
 	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
 
+	"filippo.io/age"
 	"github.com/juju/errors"
+	"github.com/youngkin/mockvideo/internal/secrets"
 )
 
-// LoadConfig loads the accountd service configuration and returns a map of key/value pairs or an error
+// LoadConfig loads the accountd service configuration and returns a map of key/value pairs or an error.
+// Blank lines are ignored. All lines that aren't 'key=value' pairs are reported in a *ValidationError.
 func LoadConfig(configData io.Reader) (map[string]string, error) {
 	config := make(map[string]string)
+	verr := &ValidationError{}
 	lineReader := bufio.NewScanner(configData)
-	for lineReader.Scan() {
+	for lineNum := 1; lineReader.Scan(); lineNum++ {
 		line := lineReader.Text()
-		keyVal := strings.Split(line, "=")
-		key := keyVal[0]
-		val := keyVal[1]
-		config[key] = val
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		keyVal := strings.SplitN(line, "=", 2)
+		if len(keyVal) != 2 || keyVal[0] == "" {
+			verr.add("line %d <%s>: not a key=value pair", lineNum, line)
+			continue
+		}
+		config[keyVal[0]] = keyVal[1]
+	}
+	if err := lineReader.Err(); err != nil {
+		return nil, errors.Annotate(err, "configuration could not be read")
+	}
+	if len(verr.Problems) > 0 {
+		return nil, verr
 	}
 
 	return config, nil
 }
 
-// LoadSecrets loads the accountd service's secrets and returns a map of key/value pairs or an error
-func LoadSecrets(secretsDir string) (map[string]string, error) {
+// ProfileFileNames returns the names of the configuration files that make up the configuration
+// profile of environment 'env', e.g., 'dev' or 'prod'. They are the base configuration file,
+// 'baseFileName', followed by the environment's overlay, 'baseFileName' with an '.env' suffix, e.g.,
+// 'config.prod'. Only the base configuration file is used if 'env' is empty.
+func ProfileFileNames(baseFileName, env string) ([]string, error) {
+	if env == "" {
+		return []string{baseFileName}, nil
+	}
+	if strings.ContainsAny(env, `/\.`) {
+		return nil, errors.NotValidf("environment %q", env)
+	}
+	return []string{baseFileName, baseFileName + "." + env}, nil
+}
+
+// Merge returns the configuration resulting from applying 'overlays', in order, to 'base'. An item
+// in an overlay replaces the same item in 'base' and in any preceding overlay. Neither 'base' nor
+// 'overlays' are modified.
+func Merge(base map[string]string, overlays ...map[string]string) map[string]string {
+	merged := make(map[string]string, len(base))
+	for key, val := range base {
+		merged[key] = val
+	}
+	for _, overlay := range overlays {
+		for key, val := range overlay {
+			merged[key] = val
+		}
+	}
+	return merged
+}
+
+// LoadSecrets loads the accountd service's secrets and returns a map of key/value pairs or an error.
+// The DB credentials are required. The admin token, the API keys, the JWT signing keys, the TLS
+// certificate and key, the CA certificates of gRPC clients, and the DB's CA certificate are optional,
+// they're only included in the map if their files exist. Files containing an encrypted secret, an age
+// file, see secrets.Encrypt, are decrypted with 'identities', which may be empty if none of the secrets
+// are encrypted.
+func LoadSecrets(secretsDir string, identities []age.Identity) (map[string]string, error) {
 	secrets := make(map[string]string)
 
 	secretFiles := []string{"dbuser", "dbpassword"}
+	optionalSecretFiles := []string{"adminToken", "apiKeys", "jwtKeys", "tlsCert", "tlsKey", "tlsClientCA", "dbCACert"}
 
 	for _, fileName := range secretFiles {
 		content, err := ioutil.ReadFile(filepath.Join(secretsDir, fileName))
 		if err != nil {
 			return nil, errors.Annotatef(err, "Secrets file %s could not be read", filepath.Join(secretsDir, fileName))
 		}
+		if content, err = decodeSecret(fileName, content, identities); err != nil {
+			return nil, err
+		}
+
+		secrets[fileName] = string(content)
+	}
+
+	for _, fileName := range optionalSecretFiles {
+		content, err := ioutil.ReadFile(filepath.Join(secretsDir, fileName))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Annotatef(err, "Secrets file %s could not be read", filepath.Join(secretsDir, fileName))
+		}
+		if content, err = decodeSecret(fileName, content, identities); err != nil {
+			return nil, err
+		}
 
 		secrets[fileName] = string(content)
 	}
 
 	return secrets, nil
 }
+
+// decodeSecret returns the plaintext of secret 'name', decrypting 'content' with 'identities' if it's encrypted
+func decodeSecret(name string, content []byte, identities []age.Identity) ([]byte, error) {
+	if !secrets.IsEncrypted(content) {
+		return content, nil
+	}
+	if len(identities) == 0 {
+		return nil, errors.Errorf("secret %s is encrypted but no secrets key was provided, set %s", name, secrets.KeyEnv)
+	}
+	return secrets.Decrypt(name, content, identities...)
+}
@@ -18,11 +18,21 @@ import (
 	"io"
 	"reflect"
 	"testing"
+
+	"github.com/youngkin/mockvideo/internal/secrets"
+)
+
+// testSecretsKey is the age identity the secrets in testdata/encrypted are encrypted to, and
+// otherSecretsKey is another identity
+const (
+	testSecretsKey  = "AGE-SECRET-KEY-1VD35AVAPCDS64SU5QHF37JEUFCGT7T2WVT45KX88C7NHMDRJSP3S7G0NYE"
+	otherSecretsKey = "AGE-SECRET-KEY-1SU7EURA2DZ6RGTVX7HLZQ09DD2AMS839P892K4M9UQCS0TADKZYSAH0386"
 )
 
 type Test struct {
 	testName   string
 	testDir    string
+	key        string
 	input      string
 	expected   map[string]string
 	expectFail bool
@@ -41,6 +51,23 @@ func init() {
 			expected:   map[string]string{"a": "1", "b": "2", "c": "3"},
 			expectFail: false,
 		},
+		{
+			testName:   "BlankLinesTest",
+			input:      "a=1\n\n  \nb=2\n",
+			expected:   map[string]string{"a": "1", "b": "2"},
+			expectFail: false,
+		},
+		{
+			testName:   "ValueWithEqualsTest",
+			input:      "a=1\nb=x=y",
+			expected:   map[string]string{"a": "1", "b": "x=y"},
+			expectFail: false,
+		},
+		{
+			testName:   "MalformedLinesTest",
+			input:      "a=1\nb\n=2",
+			expectFail: true,
+		},
 	}
 
 	secretTests = []Test{
@@ -50,12 +77,43 @@ func init() {
 			expected:   map[string]string{"dbuser": "someuser", "dbpassword": "somepassword"},
 			expectFail: false,
 		},
+		{
+			testName:   "OptionalSecretTest",
+			testDir:    "./testdata/optional",
+			expected:   map[string]string{"dbuser": "someuser", "dbpassword": "somepassword", "adminToken": "sometoken"},
+			expectFail: false,
+		},
 		{
 			testName:   "SecretMissingDirectory",
 			testDir:    "someNonExistentDirectory",
 			expected:   map[string]string{"dbuser": "someuser", "dbpassword": "somepassword"},
 			expectFail: true,
 		},
+		{
+			testName:   "EncryptedSecretTest",
+			testDir:    "./testdata/encrypted",
+			key:        testSecretsKey,
+			expected:   map[string]string{"dbuser": "someuser", "dbpassword": "somepassword"},
+			expectFail: false,
+		},
+		{
+			testName:   "EncryptedSecretNoKeyTest",
+			testDir:    "./testdata/encrypted",
+			expectFail: true,
+		},
+		{
+			testName:   "EncryptedSecretWrongKeyTest",
+			testDir:    "./testdata/encrypted",
+			key:        otherSecretsKey,
+			expectFail: true,
+		},
+		{
+			testName:   "EncryptedSecretMultipleKeysTest",
+			testDir:    "./testdata/encrypted",
+			key:        otherSecretsKey + "\n" + testSecretsKey,
+			expected:   map[string]string{"dbuser": "someuser", "dbpassword": "somepassword"},
+			expectFail: false,
+		},
 	}
 }
 
@@ -102,7 +160,11 @@ func TestLoadConfig(t *testing.T) {
 func TestLoadSecrets(t *testing.T) {
 	for _, test := range secretTests {
 		t.Run(test.testName, func(t *testing.T) {
-			secrets, err := LoadSecrets(test.testDir)
+			identities, err := secrets.LoadIdentities(test.key, "")
+			if err != nil {
+				t.Fatalf("TestName: %s, error %s was not expected parsing the key", test.testName, err)
+			}
+			loaded, err := LoadSecrets(test.testDir, identities)
 			if err != nil && test.expectFail == false {
 				t.Errorf("TestName: %s, Expected nil error, got %v", test.testName, err)
 			}
@@ -110,8 +172,84 @@ func TestLoadSecrets(t *testing.T) {
 				t.Errorf("TestName: %s, Expected non-nil error", test.testName)
 			}
 
-			if !test.expectFail && !reflect.DeepEqual(secrets, test.expected) {
-				t.Errorf("TestName: %s, expected %v, got %v", test.testName, test.expected, secrets)
+			if !test.expectFail && !reflect.DeepEqual(loaded, test.expected) {
+				t.Errorf("TestName: %s, expected %v, got %v", test.testName, test.expected, loaded)
+			}
+		})
+	}
+}
+
+func TestProfileFileNames(t *testing.T) {
+	tcs := []struct {
+		testName   string
+		env        string
+		expected   []string
+		expectFail bool
+	}{
+		{
+			testName: "NoEnvironmentTest",
+			expected: []string{"/opt/config/config"},
+		},
+		{
+			testName: "EnvironmentTest",
+			env:      "prod",
+			expected: []string{"/opt/config/config", "/opt/config/config.prod"},
+		},
+		{
+			testName:   "InvalidEnvironmentTest",
+			env:        "../secrets/dbpassword",
+			expectFail: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			fileNames, err := ProfileFileNames("/opt/config/config", tc.env)
+			if err != nil && !tc.expectFail {
+				t.Errorf("Expected nil error, got %v", err)
+			}
+			if err == nil && tc.expectFail {
+				t.Errorf("Expected non-nil error")
+			}
+			if !tc.expectFail && !reflect.DeepEqual(fileNames, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, fileNames)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := map[string]string{"port": "5000", "logLevel": "4", "dbHost": "mysql"}
+
+	tcs := []struct {
+		testName string
+		overlays []map[string]string
+		expected map[string]string
+	}{
+		{
+			testName: "NoOverlayTest",
+			expected: map[string]string{"port": "5000", "logLevel": "4", "dbHost": "mysql"},
+		},
+		{
+			testName: "OverlayTest",
+			overlays: []map[string]string{{"logLevel": "5", "listen": "unix:///tmp/accountd.sock"}},
+			expected: map[string]string{"port": "5000", "logLevel": "5", "dbHost": "mysql", "listen": "unix:///tmp/accountd.sock"},
+		},
+		{
+			testName: "LastOverlayWinsTest",
+			overlays: []map[string]string{{"logLevel": "5"}, {"logLevel": "6"}},
+			expected: map[string]string{"port": "5000", "logLevel": "6", "dbHost": "mysql"},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			merged := Merge(base, tc.overlays...)
+			if !reflect.DeepEqual(merged, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, merged)
+			}
+			if base["logLevel"] != "4" {
+				t.Errorf("base configuration was modified: %v", base)
 			}
 		})
 	}
@@ -0,0 +1,147 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is the type of a configuration item's value
+type Type int
+
+const (
+	// String items can have any value
+	String Type = iota
+	// Int items must be decimal integers
+	Int
+	// Bool items must be one of the values accepted by strconv.ParseBool, e.g., 'true' or '0'
+	Bool
+)
+
+// String returns the name of 't'
+func (t Type) String() string {
+	switch t {
+	case Int:
+		return "integer"
+	case Bool:
+		return "boolean"
+	default:
+		return "string"
+	}
+}
+
+// Key describes a configuration item
+type Key struct {
+	Name string
+	Type Type
+	// Required items must be configured
+	Required bool
+	// Default is the value used when an optional item isn't configured
+	Default string
+	// Min and Max are the inclusive range of an Int item's value. The range isn't checked
+	// if both are zero.
+	Min int
+	Max int
+	// Allowed lists the values a String item may have. Any value is allowed if it's empty.
+	Allowed []string
+}
+
+// Schema describes all of a service's configuration items
+type Schema []Key
+
+// Key returns the description of the configuration item identified by 'name'
+func (s Schema) Key(name string) (Key, bool) {
+	for _, k := range s {
+		if k.Name == name {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// Validate checks 'configs', e.g., as returned by LoadConfig, against the schema. Every problem
+// found, including configuration items that aren't in the schema, is reported in the returned
+// *ValidationError.
+func (s Schema) Validate(configs map[string]string) error {
+	verr := &ValidationError{}
+	for _, k := range s {
+		val, ok := configs[k.Name]
+		if !ok {
+			if k.Required {
+				verr.add("%s: required", k.Name)
+			}
+			continue
+		}
+		if problem := k.check(val); problem != "" {
+			verr.add("%s <%s>: %s", k.Name, val, problem)
+		}
+	}
+
+	unknown := []string{}
+	for name := range configs {
+		if _, ok := s.Key(name); !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		verr.add("%s: unknown configuration item", name)
+	}
+
+	if len(verr.Problems) == 0 {
+		return nil
+	}
+	return verr
+}
+
+// check returns a description of why 'val' isn't a valid value of 'k', or "" if it is valid
+func (k Key) check(val string) string {
+	switch k.Type {
+	case Int:
+		i, err := strconv.Atoi(val)
+		if err != nil {
+			return "not an integer"
+		}
+		if (k.Min != 0 || k.Max != 0) && (i < k.Min || i > k.Max) {
+			return fmt.Sprintf("must be between %d and %d", k.Min, k.Max)
+		}
+	case Bool:
+		if _, err := strconv.ParseBool(val); err != nil {
+			return "not a boolean"
+		}
+	default:
+		if len(k.Allowed) == 0 {
+			return ""
+		}
+		for _, a := range k.Allowed {
+			if val == a {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(k.Allowed, ", ")
+	}
+	return ""
+}
+
+// ValidationError reports all of the problems found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+// Error returns all of the problems in a single message
+func (e *ValidationError) Error() string {
+	noun := "problems"
+	if len(e.Problems) == 1 {
+		noun = "problem"
+	}
+	return fmt.Sprintf("%d configuration %s: %s", len(e.Problems), noun, strings.Join(e.Problems, "; "))
+}
+
+func (e *ValidationError) add(format string, args ...interface{}) {
+	e.Problems = append(e.Problems, fmt.Sprintf(format, args...))
+}
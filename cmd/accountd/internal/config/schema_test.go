@@ -0,0 +1,82 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	schema := Schema{
+		{Name: "host", Type: String, Required: true},
+		{Name: "port", Type: Int, Default: "5000", Min: 0, Max: 65535},
+		{Name: "retries", Type: Int, Default: "2"},
+		{Name: "verbose", Type: Bool, Default: "false"},
+		{Name: "logger", Type: String, Default: "logrus", Allowed: []string{"logrus", "zap"}},
+	}
+
+	tcs := []struct {
+		testName         string
+		configs          map[string]string
+		expectedProblems []string
+	}{
+		{
+			testName: "testValid",
+			configs:  map[string]string{"host": "localhost", "port": "0", "retries": "-1", "verbose": "true", "logger": "zap"},
+		},
+		{
+			testName: "testRequiredOnly",
+			configs:  map[string]string{"host": "localhost"},
+		},
+		{
+			testName: "testAllProblems",
+			configs: map[string]string{
+				"port":    "70000",
+				"retries": "two",
+				"verbose": "yes",
+				"logger":  "glog",
+				"prot":    "5000",
+				"dbhost":  "mysql",
+			},
+			expectedProblems: []string{
+				"host: required",
+				"port <70000>: must be between 0 and 65535",
+				"retries <two>: not an integer",
+				"verbose <yes>: not a boolean",
+				"logger <glog>: must be one of logrus, zap",
+				"dbhost: unknown configuration item",
+				"prot: unknown configuration item",
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			err := schema.Validate(tc.configs)
+			if tc.expectedProblems == nil {
+				if err != nil {
+					t.Errorf("error %s was not expected", err)
+				}
+				return
+			}
+			verr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("expected a *ValidationError, got %v", err)
+			}
+			if !reflect.DeepEqual(verr.Problems, tc.expectedProblems) {
+				t.Errorf("expected problems %q, got %q", tc.expectedProblems, verr.Problems)
+			}
+		})
+	}
+}
+
+func TestValidationErrorMessage(t *testing.T) {
+	err := &ValidationError{Problems: []string{"host: required", "prot: unknown configuration item"}}
+	expected := "2 configuration problems: host: required; prot: unknown configuration item"
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+}
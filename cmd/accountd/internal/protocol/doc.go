@@ -0,0 +1,19 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package protocol parses the APIs accountd serves, its '-protocol' flag. Set is a flag.Value so the
+flag is validated when the command line is parsed, and an invalid value is reported along with the
+flag's usage, rather than when the servers are started. Its value is a comma separated list of
+protocols, e.g.:
+
+	http
+	grpc
+	http,grpc
+	both
+
+'both' is shorthand for 'http,grpc'. Protocols are case insensitive and may be listed in any order,
+but each may only be listed once.
+*/
+package protocol
@@ -0,0 +1,120 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Protocol is an API protocol accountd serves
+type Protocol string
+
+// The protocols accountd serves
+const (
+	HTTP Protocol = "http"
+	GRPC Protocol = "grpc"
+)
+
+// Both is shorthand for all of the protocols
+const Both = "both"
+
+// All are the protocols in the order they're started
+var All = []Protocol{HTTP, GRPC}
+
+// Usage describes the values of a Set, e.g., for a flag's usage
+var Usage = fmt.Sprintf("a comma separated list of %s, or '%s' for all of them", quote(All), Both)
+
+// Set is a flag.Value holding the protocols to serve, see the package comment for its syntax. Setting
+// it replaces its protocols, they're always held in the order of All.
+type Set []Protocol
+
+// String returns the protocols as a comma separated list
+func (s *Set) String() string {
+	if s == nil {
+		return ""
+	}
+	names := make([]string, 0, len(*s))
+	for _, p := range *s {
+		names = append(names, string(p))
+	}
+	return strings.Join(names, ",")
+}
+
+// Set parses 'val' replacing the current protocols
+func (s *Set) Set(val string) error {
+	parsed, err := Parse(val)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// Has returns true if 'p' is one of the protocols
+func (s Set) Has(p Protocol) bool {
+	for _, sp := range s {
+		if sp == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse returns the Set described by 'val', see the package comment for its syntax
+func Parse(val string) (Set, error) {
+	if strings.EqualFold(strings.TrimSpace(val), Both) {
+		return append(Set{}, All...), nil
+	}
+
+	requested := make(map[Protocol]bool)
+	for _, name := range strings.Split(val, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			return nil, errors.New("empty protocol, expected " + Usage)
+		}
+		p := Protocol(name)
+		if !known(p) {
+			if name == Both {
+				return nil, fmt.Errorf("'%s' can't be combined with other protocols", Both)
+			}
+			return nil, fmt.Errorf("unknown protocol %q, expected %s", name, Usage)
+		}
+		if requested[p] {
+			return nil, fmt.Errorf("protocol %q is listed more than once", name)
+		}
+		requested[p] = true
+	}
+
+	s := Set{}
+	for _, p := range All {
+		if requested[p] {
+			s = append(s, p)
+		}
+	}
+	return s, nil
+}
+
+func known(p Protocol) bool {
+	for _, a := range All {
+		if a == p {
+			return true
+		}
+	}
+	return false
+}
+
+// quote returns 'ps' as a list of quoted names, e.g., "'http' and 'grpc'"
+func quote(ps []Protocol) string {
+	names := make([]string, 0, len(ps))
+	for _, p := range ps {
+		names = append(names, "'"+string(p)+"'")
+	}
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tcs := []struct {
+		testName   string
+		val        string
+		expected   Set
+		shouldPass bool
+	}{
+		{testName: "testHTTP", val: "http", expected: Set{HTTP}, shouldPass: true},
+		{testName: "testGRPC", val: "grpc", expected: Set{GRPC}, shouldPass: true},
+		{testName: "testBoth", val: "both", expected: Set{HTTP, GRPC}, shouldPass: true},
+		{testName: "testList", val: "grpc, http", expected: Set{HTTP, GRPC}, shouldPass: true},
+		{testName: "testCaseInsensitive", val: "HTTP,gRPC", expected: Set{HTTP, GRPC}, shouldPass: true},
+		{testName: "testEmpty", val: "", shouldPass: false},
+		{testName: "testEmptyInList", val: "http,", shouldPass: false},
+		{testName: "testUnknown", val: "websocket", shouldPass: false},
+		{testName: "testDuplicate", val: "http,http", shouldPass: false},
+		{testName: "testBothInList", val: "both,grpc", shouldPass: false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			s, err := Parse(tc.val)
+			if tc.shouldPass != (err == nil) {
+				t.Fatalf("expected success %t, got error %v", tc.shouldPass, err)
+			}
+			if !reflect.DeepEqual(s, tc.expected) {
+				t.Errorf("expected protocols %v, got %v", tc.expected, s)
+			}
+		})
+	}
+}
+
+func TestFlag(t *testing.T) {
+	tcs := []struct {
+		testName       string
+		args           []string
+		expected       string
+		expectedOutput string
+		shouldPass     bool
+	}{
+		{testName: "testDefault", expected: "http", shouldPass: true},
+		{testName: "testBoth", args: []string{"-protocol", "both"}, expected: "http,grpc", shouldPass: true},
+		{testName: "testLastWins", args: []string{"-protocol", "both", "-protocol", "grpc"}, expected: "grpc", shouldPass: true},
+		{
+			testName: "testInvalid",
+			args:     []string{"-protocol", "htp"},
+			// The error and then the flag's usage are reported
+			expectedOutput: `invalid value "htp" for flag -protocol: unknown protocol "htp", expected ` + Usage,
+			shouldPass:     false,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			var out bytes.Buffer
+			fs := flag.NewFlagSet("accountd", flag.ContinueOnError)
+			fs.SetOutput(&out)
+			protocols := Set{HTTP}
+			fs.Var(&protocols, "protocol", "the protocols served, "+Usage)
+
+			err := fs.Parse(tc.args)
+			if tc.shouldPass != (err == nil) {
+				t.Fatalf("expected success %t, got error %v", tc.shouldPass, err)
+			}
+			if !tc.shouldPass {
+				if !strings.HasPrefix(out.String(), tc.expectedOutput) {
+					t.Errorf("expected output to start with %q, got %q", tc.expectedOutput, out.String())
+				}
+				if !strings.Contains(out.String(), "-protocol value") {
+					t.Errorf("expected the usage to be output, got %q", out.String())
+				}
+				return
+			}
+			if protocols.String() != tc.expected {
+				t.Errorf("expected protocols %q, got %q", tc.expected, protocols.String())
+			}
+		})
+	}
+}
+
+func TestHas(t *testing.T) {
+	s := Set{GRPC}
+	if s.Has(HTTP) {
+		t.Errorf("expected %v not to have %s", s, HTTP)
+	}
+	if !s.Has(GRPC) {
+		t.Errorf("expected %v to have %s", s, GRPC)
+	}
+}
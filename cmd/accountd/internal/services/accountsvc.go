@@ -0,0 +1,185 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// AccountSvcInterface defines the operations to be supported by any types that provide
+// the implementations of account related usecases
+type AccountSvcInterface interface {
+	GetSummary(ctx context.Context, accountID int) (*domain.AccountSummary, *mverr.MVError)
+	GetUsage(ctx context.Context, accountID int) (*domain.AccountUsage, *mverr.MVError)
+}
+
+// InvoiceSvc provides the billing information about an account, e.g., from billingd
+type InvoiceSvc interface {
+	// OutstandingTotal returns the total of the account's unpaid invoices
+	OutstandingTotal(ctx context.Context, accountID int) (float64, error)
+}
+
+// AccountSvc provides the capability needed to interact with application usecases
+// related to accounts as a whole
+type AccountSvc struct {
+	authorizer
+	userSvc UserSvcInterface
+	// invoiceSvc is nil when billing information isn't available
+	invoiceSvc InvoiceSvc
+	// usage is nil when API usage isn't tracked
+	usage  UsageReporter
+	logger logging.Logger
+}
+
+// NewAccountSvc returns a new instance that handles application usecases related to accounts.
+// 'userSvc' and 'logger' must be non-nil. 'invoiceSvc' may be nil, in which case account
+// summaries won't include billing information.
+func NewAccountSvc(userSvc UserSvcInterface, invoiceSvc InvoiceSvc, logger logging.Logger) (*AccountSvc, error) {
+	if userSvc == nil {
+		return nil, errors.New("non-nil UserSvcInterface required")
+	}
+	if logger == nil {
+		return nil, errors.New("non-nil Logger required")
+	}
+	return &AccountSvc{userSvc: userSvc, invoiceSvc: invoiceSvc, logger: logger}, nil
+}
+
+// SetUsageReporter enables GetUsage, usage is reported by 'usage'. 'usage' must be non-nil.
+func (as *AccountSvc) SetUsageReporter(usage UsageReporter) error {
+	if usage == nil {
+		return errors.New("non-nil UsageReporter required")
+	}
+	as.usage = usage
+	return nil
+}
+
+// GetSummary returns the account's users, the number of users in each role, and the account's
+// outstanding invoice total. The users and invoices are retrieved concurrently. If one of them
+// can't be retrieved the summary is still returned with the corresponding fields listed in
+// AccountSummary.Unavailable. An error is only returned if nothing could be retrieved, the
+// account doesn't exist, or the caller isn't a user in the account, see authorizeAccount.
+func (as *AccountSvc) GetSummary(ctx context.Context, accountID int) (*domain.AccountSummary, *mverr.MVError) {
+	if err := as.authorizeAccount(ctx, accountID); err != nil {
+		return nil, err
+	}
+	caller, ok := auth.FromContext(ctx)
+	// Billing information is only available to primary users
+	includeInvoices := as.invoiceSvc != nil && (!ok || caller.Role == domain.Primary)
+
+	var (
+		wg       sync.WaitGroup
+		users    []*domain.User
+		usersErr *mverr.MVError
+		total    float64
+		totalErr error
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		users, usersErr = accountUsers(ctx, as.userSvc, accountID)
+	}()
+	if includeInvoices {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			total, totalErr = as.invoiceSvc.OutstandingTotal(ctx, accountID)
+		}()
+	}
+	wg.Wait()
+	// A NaN or infinite total can't be marshaled to JSON, treat it like any other bad response
+	if totalErr == nil && (math.IsNaN(total) || math.IsInf(total, 0)) {
+		totalErr = fmt.Errorf("invalid outstanding invoice total %v", total)
+	}
+
+	summary := &domain.AccountSummary{AccountID: accountID}
+
+	if usersErr != nil {
+		as.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   usersErr.ErrCode,
+			logging.ErrorDetail: usersErr.Error(),
+			logging.AccountID:   accountID,
+		}).Warn("account summary users unavailable")
+		summary.Unavailable = append(summary.Unavailable, domain.SummaryUsers)
+	} else {
+		if len(users) == 0 {
+			return nil, &mverr.MVError{
+				ErrCode:   mverr.DBNoAccountErrorCode,
+				ErrMsg:    mverr.DBNoAccountErrorMsg,
+				ErrDetail: fmt.Sprintf("account %d has no users", accountID),
+			}
+		}
+		summary.Users = users
+		summary.RoleCounts = make(map[domain.Role]int)
+		for _, u := range users {
+			summary.RoleCounts[u.Role]++
+		}
+	}
+
+	if includeInvoices {
+		if totalErr != nil {
+			as.logger.WithFields(logging.Fields{
+				logging.ErrorDetail: totalErr.Error(),
+				logging.AccountID:   accountID,
+			}).Warn("account summary invoices unavailable")
+			summary.Unavailable = append(summary.Unavailable, domain.SummaryInvoices)
+		} else {
+			summary.OutstandingInvoiceTotal = &total
+		}
+	}
+
+	// Nothing was retrieved
+	if usersErr != nil && (!includeInvoices || totalErr != nil) {
+		return nil, usersErr
+	}
+
+	return summary, nil
+}
+
+// GetUsage returns the account's API usage. The caller must be a user in the account. A
+// UsageDisabledErrorCode error is returned if usage isn't tracked, see SetUsageReporter.
+func (as *AccountSvc) GetUsage(ctx context.Context, accountID int) (*domain.AccountUsage, *mverr.MVError) {
+	if err := as.authorizeAccount(ctx, accountID); err != nil {
+		return nil, err
+	}
+	if as.usage == nil {
+		return nil, &mverr.MVError{
+			ErrCode: mverr.UsageDisabledErrorCode,
+			ErrMsg:  mverr.UsageDisabledErrorMsg,
+		}
+	}
+
+	usage := as.usage.Usage(accountID)
+	return &usage, nil
+}
+
+// accountUsers returns the users in account 'accountID' ordered by user ID. The query isn't
+// bounded, an account's users mustn't be truncated.
+func accountUsers(ctx context.Context, userSvc UserSvcInterface, accountID int) ([]*domain.User, *mverr.MVError) {
+	all, err := userSvc.GetUsers(ctx, domain.QueryTimeout{})
+	if err != nil {
+		return nil, err
+	}
+
+	users := []*domain.User{}
+	for _, u := range all.Users {
+		if u.AccountID == accountID {
+			users = append(users, u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	return users, nil
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// summaryUserSvc is a UserSvcInterface that only supports GetUsers
+type summaryUserSvc struct {
+	UserSvcInterface
+	users *domain.Users
+	err   *mverr.MVError
+}
+
+func (s summaryUserSvc) GetUsers(ctx context.Context, qt domain.QueryTimeout) (*domain.Users, *mverr.MVError) {
+	return s.users, s.err
+}
+
+type stubInvoiceSvc struct {
+	total float64
+	err   error
+}
+
+func (s stubInvoiceSvc) OutstandingTotal(ctx context.Context, accountID int) (float64, error) {
+	return s.total, s.err
+}
+
+func TestGetSummary(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	users := &domain.Users{Users: []*domain.User{
+		{AccountID: 1, ID: 2, Name: "mickey dolenz", Role: domain.Restricted},
+		{AccountID: 2, ID: 3, Name: "davy jones", Role: domain.Primary},
+		{AccountID: 1, ID: 1, Name: "porgy tirebiter", Role: domain.Primary},
+	}}
+	usersErr := &mverr.MVError{ErrCode: mverr.DBQueryErrorCode, ErrMsg: "query failed"}
+	total := 24.99
+	primary := auth.Caller{UserID: 1, AccountID: 1, Role: domain.Primary}
+
+	tcs := []struct {
+		testName            string
+		accountID           int
+		caller              *auth.Caller
+		usersErr            *mverr.MVError
+		invoiceSvc          InvoiceSvc
+		expectedUserIDs     []int
+		expectedRoleCounts  map[domain.Role]int
+		expectedTotal       *float64
+		expectedUnavailable []string
+		expectedErrCode     mverr.ErrCode
+	}{
+		{
+			testName:           "testSummaryComplete",
+			accountID:          1,
+			caller:             &primary,
+			invoiceSvc:         stubInvoiceSvc{total: total},
+			expectedUserIDs:    []int{1, 2},
+			expectedRoleCounts: map[domain.Role]int{domain.Primary: 1, domain.Restricted: 1},
+			expectedTotal:      &total,
+		},
+		{
+			testName:           "testSummaryNoInvoiceSvc",
+			accountID:          1,
+			expectedUserIDs:    []int{1, 2},
+			expectedRoleCounts: map[domain.Role]int{domain.Primary: 1, domain.Restricted: 1},
+		},
+		{
+			testName:           "testSummaryNonPrimaryCallerNoInvoices",
+			accountID:          1,
+			caller:             &auth.Caller{UserID: 2, AccountID: 1, Role: domain.Restricted},
+			invoiceSvc:         stubInvoiceSvc{total: total},
+			expectedUserIDs:    []int{1, 2},
+			expectedRoleCounts: map[domain.Role]int{domain.Primary: 1, domain.Restricted: 1},
+		},
+		{
+			testName:            "testSummaryInvoicesUnavailable",
+			accountID:           1,
+			invoiceSvc:          stubInvoiceSvc{err: errors.New("billingd unavailable")},
+			expectedUserIDs:     []int{1, 2},
+			expectedRoleCounts:  map[domain.Role]int{domain.Primary: 1, domain.Restricted: 1},
+			expectedUnavailable: []string{domain.SummaryInvoices},
+		},
+		{
+			testName:            "testSummaryInvalidInvoiceTotal",
+			accountID:           1,
+			invoiceSvc:          stubInvoiceSvc{total: math.NaN()},
+			expectedUserIDs:     []int{1, 2},
+			expectedRoleCounts:  map[domain.Role]int{domain.Primary: 1, domain.Restricted: 1},
+			expectedUnavailable: []string{domain.SummaryInvoices},
+		},
+		{
+			testName:            "testSummaryUsersUnavailable",
+			accountID:           1,
+			usersErr:            usersErr,
+			invoiceSvc:          stubInvoiceSvc{total: total},
+			expectedTotal:       &total,
+			expectedUnavailable: []string{domain.SummaryUsers},
+		},
+		{
+			testName:        "testSummaryUsersUnavailableNoInvoiceSvc",
+			accountID:       1,
+			usersErr:        usersErr,
+			expectedErrCode: mverr.DBQueryErrorCode,
+		},
+		{
+			testName:        "testSummaryAllUnavailable",
+			accountID:       1,
+			usersErr:        usersErr,
+			invoiceSvc:      stubInvoiceSvc{err: errors.New("billingd unavailable")},
+			expectedErrCode: mverr.DBQueryErrorCode,
+		},
+		{
+			testName:        "testSummaryNoAccount",
+			accountID:       9,
+			invoiceSvc:      stubInvoiceSvc{total: total},
+			expectedErrCode: mverr.DBNoAccountErrorCode,
+		},
+		{
+			testName:        "testSummaryOtherAccountForbidden",
+			accountID:       2,
+			caller:          &primary,
+			expectedErrCode: mverr.UserUnauthorizedErrorCode,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			userSvc := summaryUserSvc{users: users, err: tc.usersErr}
+			if tc.usersErr != nil {
+				userSvc.users = nil
+			}
+			accountSvc, err := NewAccountSvc(userSvc, tc.invoiceSvc, logger)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting AccountSvc", err)
+			}
+
+			ctx := context.Background()
+			if tc.caller != nil {
+				ctx = auth.NewContext(ctx, *tc.caller)
+			}
+			summary, err2 := accountSvc.GetSummary(ctx, tc.accountID)
+
+			if tc.expectedErrCode != mverr.NoErrorCode {
+				if err2 == nil || err2.ErrCode != tc.expectedErrCode {
+					t.Fatalf("expected error code %d, got %v", tc.expectedErrCode, err2)
+				}
+				return
+			}
+			if err2 != nil {
+				t.Fatalf("error %s was not expected", err2)
+			}
+
+			userIDs := []int{}
+			for _, u := range summary.Users {
+				userIDs = append(userIDs, u.ID)
+			}
+			if tc.expectedUserIDs == nil {
+				tc.expectedUserIDs = []int{}
+			}
+			if !reflect.DeepEqual(tc.expectedUserIDs, userIDs) {
+				t.Errorf("expected users %v, got %v", tc.expectedUserIDs, userIDs)
+			}
+			if !reflect.DeepEqual(tc.expectedRoleCounts, summary.RoleCounts) {
+				t.Errorf("expected role counts %v, got %v", tc.expectedRoleCounts, summary.RoleCounts)
+			}
+			if !reflect.DeepEqual(tc.expectedTotal, summary.OutstandingInvoiceTotal) {
+				t.Errorf("expected outstanding invoice total %v, got %v", tc.expectedTotal, summary.OutstandingInvoiceTotal)
+			}
+			if !reflect.DeepEqual(tc.expectedUnavailable, summary.Unavailable) {
+				t.Errorf("expected unavailable %v, got %v", tc.expectedUnavailable, summary.Unavailable)
+			}
+		})
+	}
+}
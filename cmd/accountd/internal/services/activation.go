@@ -0,0 +1,98 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"github.com/youngkin/mockvideo/internal/metrics"
+)
+
+// DefaultActivationTTL is how long a new user has to activate their account before it is deleted
+const DefaultActivationTTL = 48 * time.Hour
+
+// activationTokenLen is the number of random bytes in an activation token
+const activationTokenLen = 16
+
+// PendingUsersExpired counts the pending users deleted because they weren't activated in time
+var PendingUsersExpired = metrics.NewCounter(prometheus.CounterOpts{
+	Subsystem: "service",
+	Name:      "pending_users_expired_total",
+	Help:      "number of pending users deleted because they weren't activated in time",
+})
+
+// newActivationToken returns a random, hex encoded, activation token
+func newActivationToken() (string, error) {
+	b := make([]byte, activationTokenLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ActivationExpirer periodically deletes pending users whose activation token has expired
+type ActivationExpirer struct {
+	userSvc  *UserSvc
+	logger   logging.Logger
+	interval time.Duration
+	stopC    chan struct{}
+	doneC    chan struct{}
+}
+
+// NewActivationExpirer returns an ActivationExpirer that checks for expired pending users
+// every 'interval'. Start() must be called to begin checking.
+func NewActivationExpirer(userSvc *UserSvc, logger logging.Logger, interval time.Duration) (*ActivationExpirer, error) {
+	if userSvc == nil {
+		return nil, errors.New("non-nil *UserSvc required")
+	}
+	if logger == nil {
+		return nil, errors.New("non-nil Logger required")
+	}
+	if interval <= 0 {
+		return nil, errors.New("interval must be greater than 0")
+	}
+	return &ActivationExpirer{
+		userSvc:  userSvc,
+		logger:   logger,
+		interval: interval,
+		stopC:    make(chan struct{}),
+		doneC:    make(chan struct{}),
+	}, nil
+}
+
+// Start begins checking for expired pending users in a separate goroutine
+func (e *ActivationExpirer) Start() {
+	go func() {
+		defer close(e.doneC)
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stopC:
+				return
+			case <-ticker.C:
+				n, err := e.userSvc.ExpirePendingUsers()
+				if err != nil {
+					// Already logged by the UserSvc
+					continue
+				}
+				if n > 0 {
+					e.logger.WithFields(logging.Fields{logging.Status: "expired"}).Infof("deleted %d pending users with expired activation tokens", n)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops checking for expired pending users
+func (e *ActivationExpirer) Stop() {
+	close(e.stopC)
+	<-e.doneC
+}
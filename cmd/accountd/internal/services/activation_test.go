@@ -0,0 +1,97 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/internal/clock"
+	"github.com/youngkin/mockvideo/internal/domain"
+	"github.com/youngkin/mockvideo/internal/locale"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// fakeMailer records the activation emails it is asked to send
+type fakeMailer struct {
+	user    domain.User
+	token   string
+	locale  string
+	sendErr error
+}
+
+func (m *fakeMailer) SendActivation(ctx context.Context, user domain.User, token string) error {
+	m.user = user
+	m.token = token
+	m.locale = locale.Match(ctx, "en", "fr")
+	return m.sendErr
+}
+
+func TestCreateUserPendingActivation(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	tcs := []struct {
+		testName string
+		sendErr  error
+	}{
+		{
+			testName: "testCreateUserActivationSent",
+		},
+		{
+			// The user is still created, it will expire if never activated
+			testName: "testCreateUserActivationSendFailed",
+			sendErr:  errors.New("mail server unavailable"),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			repo := &fakeUserRepo{}
+			mailer := &fakeMailer{sendErr: tc.sendErr}
+			userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			err = userSvc.ConfigureActivation(mailer, time.Hour)
+			if err != nil {
+				t.Fatalf("error %s was not expected when configuring activation", err)
+			}
+			now := time.Date(2020, time.July, 4, 9, 30, 0, 0, time.UTC)
+			err = userSvc.SetClock(clock.NewFrozen(now))
+			if err != nil {
+				t.Fatalf("error %s was not expected when setting the clock", err)
+			}
+
+			ctx := locale.NewContext(context.Background(), []string{"fr-CA", "en"})
+			id, err2 := userSvc.CreateUser(ctx, domain.User{AccountID: 1, Name: "porgy tirebiter", Status: domain.Active})
+			if err2 != nil {
+				t.Fatalf("error %s was not expected when creating user", err2)
+			}
+
+			if repo.created.Status != domain.Pending {
+				t.Errorf("expected user to be created with status %s, got %s", domain.Pending, repo.created.Status)
+			}
+			if len(repo.created.ActivationToken) != 2*activationTokenLen {
+				t.Errorf("expected a %d character activation token, got %q", 2*activationTokenLen, repo.created.ActivationToken)
+			}
+			if !repo.created.ActivationExpiry.Equal(now.Add(time.Hour)) {
+				t.Errorf("expected activation expiry %s, got %s", now.Add(time.Hour), repo.created.ActivationExpiry)
+			}
+			if mailer.token != repo.created.ActivationToken {
+				t.Errorf("expected activation token %s to be mailed, got %s", repo.created.ActivationToken, mailer.token)
+			}
+			if mailer.user.ID != id {
+				t.Errorf("expected activation to be mailed to user %d, got %d", id, mailer.user.ID)
+			}
+			if mailer.locale != "fr" {
+				t.Errorf("expected activation to be rendered in fr, got %s", mailer.locale)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+// authorizer authorizes the requests made to the services it's embedded in, see authorize
+type authorizer struct {
+	// callerRequired is set by RequireCallers
+	callerRequired bool
+}
+
+// RequireCallers denies requests without a caller, see auth.FromContext, unless they originate
+// within the service, e.g., a signup or a queued user created by the write-behind worker. It's
+// meant to be called at startup when requests must be authenticated, e.g., with a JWT.
+func (a *authorizer) RequireCallers() {
+	a.callerRequired = true
+}
+
+// serviceKey is the context key marking work that originates within the service, see withinService
+type serviceKey struct{}
+
+// withinService returns a copy of 'ctx' for work that originates within the service rather than
+// from a caller. It's authorized without a caller even if one is required, see RequireCallers.
+func withinService(ctx context.Context) context.Context {
+	return context.WithValue(ctx, serviceKey{}, true)
+}
+
+// identified returns true if there's a caller in 'ctx'. An error is returned if there isn't one
+// but one is required, see RequireCallers.
+func (a *authorizer) identified(ctx context.Context, rqstType RqstType) (bool, *mverr.MVError) {
+	if _, ok := auth.FromContext(ctx); ok {
+		return true, nil
+	}
+	if internal, _ := ctx.Value(serviceKey{}).(bool); a.callerRequired && !internal {
+		return false, &mverr.MVError{
+			ErrCode:   mverr.UserUnauthorizedErrorCode,
+			ErrMsg:    mverr.UserUnauthorizedErrorMsg,
+			ErrDetail: fmt.Sprintf("%s request without an authenticated caller denied", RqstTypeName[rqstType]),
+		}
+	}
+	return false, nil
+}
+
+// authorize enforces the delegation rule that only a primary user of an account can create,
+// update, or delete the users in that account. 'accountIDs' are the accounts affected by
+// the request. Requests without a caller in 'ctx' originate within the service (e.g., the
+// write-behind worker) and are authorized unless callers are required, see RequireCallers.
+func (a *authorizer) authorize(ctx context.Context, rqstType RqstType, accountIDs ...int) *mverr.MVError {
+	if ok, err := a.identified(ctx, rqstType); !ok {
+		return err
+	}
+	caller, _ := auth.FromContext(ctx)
+
+	if caller.Role != domain.Primary {
+		return unauthorizedError(caller, rqstType, "caller is not a primary user")
+	}
+	for _, accountID := range accountIDs {
+		if accountID != caller.AccountID {
+			return unauthorizedError(caller, rqstType, fmt.Sprintf("target user is in account %d", accountID))
+		}
+	}
+
+	return nil
+}
+
+// authorizeUpdate is like authorize, but also allows non-primary users to update their own
+// details as long as they don't change their role or account. 'existing' is the user being
+// updated as currently stored, it will be nil if the user doesn't exist.
+func (a *authorizer) authorizeUpdate(ctx context.Context, existing *domain.User, u domain.User) *mverr.MVError {
+	if existing == nil {
+		return a.authorize(ctx, UPDATE, u.AccountID)
+	}
+
+	caller, ok := auth.FromContext(ctx)
+	if ok && caller.UserID == existing.ID && existing.Role == u.Role && existing.AccountID == u.AccountID {
+		return nil
+	}
+
+	return a.authorize(ctx, UPDATE, existing.AccountID, u.AccountID)
+}
+
+// authorizeAccount allows any user in account 'accountID' to read it. Like authorize, requests
+// without a caller are authorized unless callers are required.
+func (a *authorizer) authorizeAccount(ctx context.Context, accountID int) *mverr.MVError {
+	if ok, err := a.identified(ctx, READ); !ok {
+		return err
+	}
+	if caller, _ := auth.FromContext(ctx); caller.AccountID != accountID {
+		return unauthorizedError(caller, READ, fmt.Sprintf("target account is %d", accountID))
+	}
+	return nil
+}
+
+func unauthorizedError(caller auth.Caller, rqstType RqstType, reason string) *mverr.MVError {
+	return &mverr.MVError{
+		ErrCode: mverr.UserUnauthorizedErrorCode,
+		ErrMsg:  mverr.UserUnauthorizedErrorMsg,
+		ErrDetail: fmt.Sprintf("%s request by user %d in account %d with role %d denied: %s",
+			RqstTypeName[rqstType], caller.UserID, caller.AccountID, caller.Role, reason),
+	}
+}
+
+// modifiedBy returns the User.LastModifiedBy of a user created or updated by the caller in 'ctx',
+// the caller's auth.Caller.Identity. It's empty for requests originating within the service.
+func modifiedBy(ctx context.Context) string {
+	caller, ok := auth.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return caller.Identity()
+}
@@ -0,0 +1,268 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// authzUserRepo is a domain.UserRepository that supports the operations needed to
+// authorize user management requests
+type authzUserRepo struct {
+	fakeUserRepo
+	existing *domain.User
+	updated  bool
+	deleted  bool
+}
+
+func (r *authzUserRepo) GetUser(id int) (*domain.User, *mverr.MVError) {
+	if r.existing == nil || r.existing.ID != id {
+		return nil, nil
+	}
+	u := *r.existing
+	return &u, nil
+}
+
+func (r *authzUserRepo) UpdateUser(user domain.User) *mverr.MVError {
+	r.updated = true
+	return nil
+}
+
+func (r *authzUserRepo) GetUserDependencies(id int) ([]domain.UserDependency, *mverr.MVError) {
+	return nil, nil
+}
+
+func (r *authzUserRepo) DeleteUser(id int) (bool, *mverr.MVError) {
+	r.deleted = true
+	return true, nil
+}
+
+func TestAuthorization(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	primary := auth.Caller{UserID: 1, AccountID: 1, Role: domain.Primary}
+	restricted := auth.Caller{UserID: 2, AccountID: 1, Role: domain.Restricted}
+	otherPrimary := auth.Caller{UserID: 3, AccountID: 2, Role: domain.Primary}
+	existing := domain.User{ID: 2, AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Restricted}
+
+	tcs := []struct {
+		testName       string
+		caller         *auth.Caller
+		callerRequired bool
+		rqstType       RqstType
+		user           domain.User
+		shouldSucceed  bool
+	}{
+		{
+			testName:      "testCreateNoCaller",
+			rqstType:      CREATE,
+			user:          domain.User{AccountID: 1, Name: "porgy tirebiter", Role: domain.Unrestricted},
+			shouldSucceed: true,
+		},
+		{
+			testName:       "testCreateNoCallerRequired",
+			callerRequired: true,
+			rqstType:       CREATE,
+			user:           domain.User{AccountID: 1, Name: "porgy tirebiter", Role: domain.Unrestricted},
+			shouldSucceed:  false,
+		},
+		{
+			testName:      "testCreatePrimarySameAccount",
+			caller:        &primary,
+			rqstType:      CREATE,
+			user:          domain.User{AccountID: 1, Name: "porgy tirebiter", Role: domain.Unrestricted},
+			shouldSucceed: true,
+		},
+		{
+			testName:      "testCreatePrimaryOtherAccount",
+			caller:        &otherPrimary,
+			rqstType:      CREATE,
+			user:          domain.User{AccountID: 1, Name: "porgy tirebiter", Role: domain.Unrestricted},
+			shouldSucceed: false,
+		},
+		{
+			testName:      "testCreateNotPrimary",
+			caller:        &restricted,
+			rqstType:      CREATE,
+			user:          domain.User{AccountID: 1, Name: "porgy tirebiter", Role: domain.Unrestricted},
+			shouldSucceed: false,
+		},
+		{
+			testName:      "testUpdatePrimarySameAccount",
+			caller:        &primary,
+			rqstType:      UPDATE,
+			user:          domain.User{ID: 2, AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Unrestricted},
+			shouldSucceed: true,
+		},
+		{
+			testName:      "testUpdatePrimaryOtherAccount",
+			caller:        &otherPrimary,
+			rqstType:      UPDATE,
+			user:          domain.User{ID: 2, AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Restricted},
+			shouldSucceed: false,
+		},
+		{
+			testName:      "testUpdatePrimaryMoveToOtherAccount",
+			caller:        &primary,
+			rqstType:      UPDATE,
+			user:          domain.User{ID: 2, AccountID: 2, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Restricted},
+			shouldSucceed: false,
+		},
+		{
+			testName:      "testUpdateSelf",
+			caller:        &restricted,
+			rqstType:      UPDATE,
+			user:          domain.User{ID: 2, AccountID: 1, Name: "micky dolenz", EMail: "mickyd@gmail.com", Role: domain.Restricted},
+			shouldSucceed: true,
+		},
+		{
+			testName:      "testUpdateSelfChangeRole",
+			caller:        &restricted,
+			rqstType:      UPDATE,
+			user:          domain.User{ID: 2, AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary},
+			shouldSucceed: false,
+		},
+		{
+			testName:      "testUpdateNoCaller",
+			rqstType:      UPDATE,
+			user:          domain.User{ID: 2, AccountID: 2, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary},
+			shouldSucceed: true,
+		},
+		{
+			testName:       "testUpdateNoCallerRequired",
+			callerRequired: true,
+			rqstType:       UPDATE,
+			user:           domain.User{ID: 2, AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Restricted},
+			shouldSucceed:  false,
+		},
+		{
+			testName:      "testDeletePrimarySameAccount",
+			caller:        &primary,
+			rqstType:      DELETE,
+			user:          existing,
+			shouldSucceed: true,
+		},
+		{
+			testName:      "testDeletePrimaryOtherAccount",
+			caller:        &otherPrimary,
+			rqstType:      DELETE,
+			user:          existing,
+			shouldSucceed: false,
+		},
+		{
+			testName:      "testDeleteSelf",
+			caller:        &restricted,
+			rqstType:      DELETE,
+			user:          existing,
+			shouldSucceed: false,
+		},
+		{
+			testName:       "testDeleteNoCallerRequired",
+			callerRequired: true,
+			rqstType:       DELETE,
+			user:           existing,
+			shouldSucceed:  false,
+		},
+		{
+			testName:       "testDeletePrimaryCallerRequired",
+			caller:         &primary,
+			callerRequired: true,
+			rqstType:       DELETE,
+			user:           existing,
+			shouldSucceed:  true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			e := existing
+			repo := &authzUserRepo{existing: &e}
+			userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			if tc.callerRequired {
+				userSvc.RequireCallers()
+			}
+
+			ctx := context.Background()
+			if tc.caller != nil {
+				ctx = auth.NewContext(ctx, *tc.caller)
+			}
+
+			var err2 *mverr.MVError
+			applied := false
+			switch tc.rqstType {
+			case CREATE:
+				_, err2 = userSvc.CreateUser(ctx, tc.user)
+				applied = repo.created.Name == tc.user.Name
+			case UPDATE:
+				err2 = userSvc.UpdateUser(ctx, tc.user)
+				applied = repo.updated
+			case DELETE:
+				err2 = userSvc.DeleteUser(ctx, tc.user.ID)
+				applied = repo.deleted
+			}
+
+			if tc.shouldSucceed {
+				if err2 != nil {
+					t.Errorf("error %+v was not expected", err2)
+				}
+				if !applied {
+					t.Errorf("expected the %s request to be applied", RqstTypeName[tc.rqstType])
+				}
+				return
+			}
+
+			if err2 == nil || err2.ErrCode != mverr.UserUnauthorizedErrorCode {
+				t.Errorf("expected error code %d, got %+v", mverr.UserUnauthorizedErrorCode, err2)
+			}
+			if applied {
+				t.Errorf("expected the %s request not to be applied", RqstTypeName[tc.rqstType])
+			}
+		})
+	}
+}
+
+func TestBulkAuthorization(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	userSvc, err := NewUserSvc(&authzUserRepo{}, logger, 10, 10, 10)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting UserSvc", err)
+	}
+
+	ctx := auth.NewContext(context.Background(), auth.Caller{UserID: 1, AccountID: 1, Role: domain.Primary})
+	users := domain.Users{
+		Users: []*domain.User{
+			{AccountID: 1, Name: "porgy tirebiter", EMail: "porgy@gmail.com", Role: domain.Unrestricted, Password: "pw"},
+			{AccountID: 2, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Unrestricted, Password: "pw"},
+		},
+	}
+
+	resp, err2 := userSvc.CreateUsers(ctx, users)
+	if err2 == nil {
+		t.Fatalf("expected an error for a partially unauthorized bulk request")
+	}
+	expected := map[string]Status{
+		"porgy tirebiter": StatusCreated,
+		"mickey dolenz":   StatusForbidden,
+	}
+	for _, result := range resp.Results {
+		if result.Status != expected[result.User.Name] {
+			t.Errorf("expected status %s for user %s, got %s", StatusTypeName[expected[result.User.Name]],
+				result.User.Name, StatusTypeName[result.Status])
+		}
+	}
+}
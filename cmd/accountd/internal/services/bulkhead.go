@@ -0,0 +1,85 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/internal/metrics"
+)
+
+// Bulkhead pool names, also used as the 'pool' label value for the bulkhead metrics
+const (
+	// ReadPool is the name of the bulkhead that limits concurrent read requests
+	ReadPool = "read"
+	// WritePool is the name of the bulkhead that limits concurrent write (create, update, delete) requests
+	WritePool = "write"
+)
+
+// BulkheadInUse captures the number of slots currently in use for each bulkhead pool.
+// The 'pool' label should be one of 'read|write'.
+var BulkheadInUse = metrics.NewGaugeVec(prometheus.GaugeOpts{
+	Subsystem: "service",
+	Name:      "bulkhead_slots_in_use",
+	Help:      "number of bulkhead slots currently in use",
+}, []string{"pool"})
+
+// BulkheadCapacity captures the configured number of slots for each bulkhead pool. Together
+// with BulkheadInUse it can be used to calculate pool utilization.
+var BulkheadCapacity = metrics.NewGaugeVec(prometheus.GaugeOpts{
+	Subsystem: "service",
+	Name:      "bulkhead_slots_capacity",
+	Help:      "number of bulkhead slots configured",
+}, []string{"pool"})
+
+// BulkheadWaitDur captures how long requests wait for a bulkhead slot. Long waits in
+// one pool indicate that pool is saturated.
+var BulkheadWaitDur = metrics.NewHistogramVec(prometheus.HistogramOpts{
+	Subsystem: "service",
+	Name:      "bulkhead_wait_duration_seconds",
+	Help:      "bulkhead slot wait duration distribution in seconds",
+	Buckets:   prometheus.LinearBuckets(0.001, .004, 50),
+}, []string{"pool"})
+
+// Bulkhead limits the number of concurrent requests of a given kind. Separate bulkheads
+// for reads and writes prevent a flood of slow (bulk) writes from starving simple reads.
+type Bulkhead struct {
+	name string
+	// slots acts as a semaphore. To use, pass a message into the channel to acquire a slot;
+	// to free up the slot accept a message from the channel.
+	slots chan struct{}
+}
+
+// NewBulkhead returns a Bulkhead identified by 'name' that allows at most 'size' concurrent requests
+func NewBulkhead(name string, size int) *Bulkhead {
+	BulkheadCapacity.WithLabelValues(name).Set(float64(size))
+	return &Bulkhead{name: name, slots: make(chan struct{}, size)}
+}
+
+// Acquire blocks until a slot is available in the bulkhead. Every call to Acquire must be
+// paired with a call to Release.
+func (b *Bulkhead) Acquire() {
+	start := time.Now()
+	b.slots <- struct{}{}
+	BulkheadWaitDur.WithLabelValues(b.name).Observe(float64(time.Since(start)) / float64(time.Second))
+	BulkheadInUse.WithLabelValues(b.name).Inc()
+}
+
+// Release frees up a slot previously obtained via Acquire
+func (b *Bulkhead) Release() {
+	<-b.slots
+	BulkheadInUse.WithLabelValues(b.name).Dec()
+}
+
+// InUse returns the number of slots currently in use
+func (b *Bulkhead) InUse() int {
+	return len(b.slots)
+}
+
+// Capacity returns the maximum number of concurrent requests allowed by the bulkhead
+func (b *Bulkhead) Capacity() int {
+	return cap(b.slots)
+}
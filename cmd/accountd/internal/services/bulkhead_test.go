@@ -0,0 +1,79 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBulkhead(t *testing.T) {
+	tcs := []struct {
+		testName  string
+		capacity  int
+		acquires  int
+		releases  int
+		expectBlk bool
+	}{
+		{
+			testName:  "testBulkheadUnderCapacity",
+			capacity:  2,
+			acquires:  1,
+			expectBlk: false,
+		},
+		{
+			testName:  "testBulkheadAtCapacity",
+			capacity:  2,
+			acquires:  2,
+			expectBlk: true,
+		},
+		{
+			testName:  "testBulkheadAtCapacityAfterRelease",
+			capacity:  2,
+			acquires:  2,
+			releases:  1,
+			expectBlk: false,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			b := NewBulkhead(tc.testName, tc.capacity)
+			if b.Capacity() != tc.capacity {
+				t.Errorf("expected capacity %d, got %d", tc.capacity, b.Capacity())
+			}
+
+			for i := 0; i < tc.acquires; i++ {
+				b.Acquire()
+			}
+			for i := 0; i < tc.releases; i++ {
+				b.Release()
+			}
+			if b.InUse() != tc.acquires-tc.releases {
+				t.Errorf("expected %d slots in use, got %d", tc.acquires-tc.releases, b.InUse())
+			}
+
+			acquired := make(chan struct{})
+			go func() {
+				b.Acquire()
+				close(acquired)
+			}()
+
+			select {
+			case <-acquired:
+				if tc.expectBlk {
+					t.Errorf("expected Acquire() to block when the bulkhead is at capacity")
+				}
+			case <-time.After(50 * time.Millisecond):
+				if !tc.expectBlk {
+					t.Errorf("expected Acquire() to succeed when the bulkhead has free slots")
+				}
+				// Free a slot so the blocked goroutine can complete
+				b.Release()
+				<-acquired
+			}
+		})
+	}
+}
@@ -5,13 +5,47 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"time"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/youngkin/mockvideo/internal/domain"
 	"github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/httpclient"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"github.com/youngkin/mockvideo/internal/metrics"
 )
 
+// BulkBatchSize captures the number of users in each bulk request. The 'rqstType' label should be
+// one of 'CREATE|UPDATE|UPSERT|DELETE'.
+var BulkBatchSize = metrics.NewHistogramVec(prometheus.HistogramOpts{
+	Subsystem: "service",
+	Name:      "bulk_batch_size",
+	Help:      "number of users in each bulk request",
+	Buckets:   prometheus.ExponentialBuckets(1, 2, 11),
+}, []string{"rqstType"})
+
+// BulkItemWaitDur captures how long each user of a bulk request waits for one of the request's
+// concurrency slots, see NewBulkProcessor. Waits that are long relative to BulkItemDur indicate the
+// concurrency limit, rather than the batch size, is the bottleneck.
+var BulkItemWaitDur = metrics.NewHistogramVec(prometheus.HistogramOpts{
+	Subsystem: "service",
+	Name:      "bulk_item_wait_duration_seconds",
+	Help:      "bulk request item concurrency slot wait duration distribution in seconds",
+	Buckets:   prometheus.LinearBuckets(0.001, .004, 50),
+}, []string{"rqstType"})
+
+// BulkItemDur captures how long each user of a bulk request takes to process once it has a
+// concurrency slot. The 'status' label is the item's Status, see StatusTypeName.
+var BulkItemDur = metrics.NewHistogramVec(prometheus.HistogramOpts{
+	Subsystem: "service",
+	Name:      "bulk_item_duration_seconds",
+	Help:      "bulk request item duration distribution in seconds",
+	Buckets:   prometheus.LinearBuckets(0.001, .004, 50),
+}, []string{"rqstType", "status"})
+
 // RqstType is used to indicate what kind of request is being made
 type RqstType int
 
@@ -24,6 +58,8 @@ const (
 	READ
 	// DELETE indicates a User is to be deleted
 	DELETE
+	// UPSERT indicates a User is to be created, or updated if a user with the same email address exists
+	UPSERT
 )
 
 // RqstTypeName maps a specific RqstType value to a descriptive string
@@ -32,6 +68,7 @@ var RqstTypeName = map[RqstType]string{
 	UPDATE: "UPDATE",
 	READ:   "READ",
 	DELETE: "DELETE",
+	UPSERT: "UPSERT",
 }
 
 // Status indicates the result of a bulk operation
@@ -50,6 +87,8 @@ const (
 	StatusServerError
 	// StatusNotFound indicates the requested resource does not exist
 	StatusNotFound
+	// StatusForbidden indicates the caller isn't authorized to make the request
+	StatusForbidden
 )
 
 // StatusTypeName maps a specific Status value to a descriptive string
@@ -60,29 +99,203 @@ var StatusTypeName = map[Status]string{
 	StatusConflict:    "StatusConflict",
 	StatusServerError: "StatusServerError",
 	StatusNotFound:    "StatusNotFound",
+	StatusForbidden:   "StatusForbidden",
 }
 
-// Response contains the results of in individual User request
+// Response contains the results of in individual User request. ErrMsg and ErrReason are only set
+// if the request failed, ErrReason is errors.NoErrorCode, and both are omitted from JSON, otherwise.
+// Outcome is only set for successful UPSERT requests. Item is the index of the user in the bulk
+// request.
 type Response struct {
-	Status    Status         `json:"status"`
-	ErrMsg    string         `json:"errmsg"`
-	ErrReason errors.ErrCode `json:"-"`
-	User      domain.User    `json:"user,omitempty"`
+	Item      int                  `json:"item"`
+	Status    Status               `json:"status"`
+	ErrMsg    string               `json:"errmsg,omitempty"`
+	ErrReason errors.ErrCode       `json:"errcode,omitempty"`
+	Outcome   domain.UpsertOutcome `json:"outcome,omitempty"`
+	User      domain.User          `json:"user,omitempty"`
+}
+
+// Failed returns true if the request failed, i.e., 'r' has an error code
+func (r Response) Failed() bool {
+	return r.ErrReason != errors.NoErrorCode
 }
 
 // BulkResponse contains the results of in bulk  User request
 type BulkResponse struct {
 	OverallStatus Status     `json:"overallstatus"`
 	Results       []Response `json:"results"`
+	// DryRun is true if the users were only validated, nothing was written, see UserSvc.ValidateUsers
+	DryRun bool `json:"dryrun,omitempty"`
+	// Summary counts the results of an upsert, see UserSvc.UpsertUsers. It's nil otherwise.
+	Summary *UpsertSummary `json:"summary,omitempty"`
+}
+
+// UpsertSummary counts the users of a bulk upsert by what happened to them, see domain.UpsertOutcome.
+// Failed counts the users that couldn't be upserted.
+type UpsertSummary struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
+
+// summarize returns the UpsertSummary of 'results'
+func summarize(results []Response) *UpsertSummary {
+	summary := &UpsertSummary{}
+	for _, r := range results {
+		switch {
+		case r.Failed():
+			summary.Failed++
+		case r.Outcome == domain.UpsertCreated:
+			summary.Created++
+		case r.Outcome == domain.UpsertUpdated:
+			summary.Updated++
+		case r.Outcome == domain.UpsertSkipped:
+			summary.Skipped++
+		}
+	}
+	return summary
+}
+
+// BulkValidation decides what happens to a bulk request when some of its users fail validation
+// before anything is written, see UserSvc.SetBulkValidation
+type BulkValidation int
+
+const (
+	// ContinueOnError processes the valid users, the invalid users' results report why they failed.
+	// It's the default.
+	ContinueOnError BulkValidation = iota
+	// FailFast rejects the request, nothing is written, if any of its users are invalid
+	FailFast
+)
+
+// BulkValidationName maps a specific BulkValidation value to its name in the configuration
+var BulkValidationName = map[BulkValidation]string{
+	ContinueOnError: "continue",
+	FailFast:        "failfast",
+}
+
+// ParseBulkValidation returns the BulkValidation named 'name', see BulkValidationName. An empty
+// 'name' is ContinueOnError.
+func ParseBulkValidation(name string) (BulkValidation, error) {
+	if name == "" {
+		return ContinueOnError, nil
+	}
+	for v, n := range BulkValidationName {
+		if strings.EqualFold(name, n) {
+			return v, nil
+		}
+	}
+	return ContinueOnError, fmt.Errorf("unknown bulk validation %q, expected 'continue' or 'failfast'", name)
+}
+
+// Verbosity controls how much of each user is included in the results of a BulkResponse
+// returned to a client, see BulkResponse.View. Passwords are never included.
+type Verbosity int
+
+const (
+	// VerbositySummary includes each user's ID, name, and email. It's the default.
+	VerbositySummary Verbosity = iota
+	// VerbosityIDs includes only each user's ID, which is 0 for users that weren't created
+	VerbosityIDs
+	// VerbosityFull includes all of each user except their password
+	VerbosityFull
+)
+
+// VerbosityName maps a specific Verbosity value to the name clients use to request it
+var VerbosityName = map[Verbosity]string{
+	VerbositySummary: "summary",
+	VerbosityIDs:     "ids",
+	VerbosityFull:    "full",
+}
+
+// ParseVerbosity returns the Verbosity named 'name', see VerbosityName. An empty 'name' is
+// VerbositySummary.
+func ParseVerbosity(name string) (Verbosity, error) {
+	if name == "" {
+		return VerbositySummary, nil
+	}
+	for v, n := range VerbosityName {
+		if strings.EqualFold(name, n) {
+			return v, nil
+		}
+	}
+	return VerbositySummary, fmt.Errorf("unknown verbosity %q, expected one of 'ids', 'summary', or 'full'", name)
+}
+
+// UserID is the user included in a result with VerbosityIDs
+type UserID struct {
+	ID int `json:"id"`
+}
+
+// UserSummary is the user included in a result with VerbositySummary
+type UserSummary struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	EMail string `json:"email"`
+}
+
+// ResponseView is a Response as returned to a client, 'User' is a UserID, UserSummary, or
+// domain.User without a password depending on the Verbosity. 'ErrMsg' and 'ErrCode' are omitted
+// if the request succeeded, 'Outcome' unless it was an upsert.
+type ResponseView struct {
+	Item    int                  `json:"item"`
+	Status  Status               `json:"status"`
+	ErrMsg  string               `json:"errmsg,omitempty"`
+	ErrCode errors.ErrCode       `json:"errcode,omitempty"`
+	Outcome domain.UpsertOutcome `json:"outcome,omitempty"`
+	User    interface{}          `json:"user"`
+}
+
+// BulkResponseView is a BulkResponse as returned to a client, see BulkResponse.View
+type BulkResponseView struct {
+	OverallStatus Status         `json:"overallstatus"`
+	Results       []ResponseView `json:"results"`
+	DryRun        bool           `json:"dryrun,omitempty"`
+	Summary       *UpsertSummary `json:"summary,omitempty"`
+}
+
+// View returns 'br' with each result's user reduced to what 'v' includes
+func (br BulkResponse) View(v Verbosity) BulkResponseView {
+	view := BulkResponseView{OverallStatus: br.OverallStatus, DryRun: br.DryRun, Summary: br.Summary, Results: []ResponseView{}}
+	for _, r := range br.Results {
+		rv := ResponseView{Item: r.Item, Status: r.Status, Outcome: r.Outcome}
+		if r.Failed() {
+			rv.ErrMsg = r.ErrMsg
+			rv.ErrCode = r.ErrReason
+		}
+		switch v {
+		case VerbosityIDs:
+			rv.User = UserID{ID: r.User.ID}
+		case VerbosityFull:
+			rv.User = withoutPassword(r.User)
+		default:
+			rv.User = UserSummary{ID: r.User.ID, Name: r.User.Name, EMail: r.User.EMail}
+		}
+		view.Results = append(view.Results, rv)
+	}
+	return view
+}
+
+// withoutPassword returns 'u' without its password so it can be included in a Response
+func withoutPassword(u domain.User) domain.User {
+	u.Password = ""
+	return u
 }
 
 // Request contains the information needed to process a request as well
 // as capture to result of processing that request.
 type Request struct {
+	ctx       context.Context
 	userSvc   UserSvcInterface
 	ResponseC chan Response
 	user      domain.User
-	rqstType  RqstType
+	// item is the index of 'user' in the bulk request
+	item     int
+	rqstType RqstType
+	// submitted is when the request was created, the time until it's processed is spent waiting
+	// for a concurrency slot
+	submitted time.Time
 }
 
 // BulkRequest contains a set of requests to be processed and the single channel to listen to for results
@@ -93,20 +306,25 @@ type BulkRequest struct {
 
 // NewBulkRequest returns a Request. This is the only way to create a valid Request. The
 // returned request contains a channel to listen on for concurrent request completion,
-// and the individual user instances that are the target of the operation.
-func NewBulkRequest(users domain.Users, rqstType RqstType, userSvc UserSvcInterface) BulkRequest {
+// and the individual user instances that are the target of the operation. 'ctx' is passed
+// on to each individual request.
+func NewBulkRequest(ctx context.Context, users domain.Users, rqstType RqstType, userSvc UserSvcInterface) BulkRequest {
 	// responseC must be a buffered channel of at least 1. This is required to handle a
 	// potential race condition that occurs when the client 'Stop()'s a BulkPost while
 	// one or more requests are being actively processed but not yet handled by the client.
 	responseC := make(chan Response, len(users.Users))
 	requests := []Request{}
+	submitted := time.Now()
 
-	for _, u := range users.Users {
+	for i, u := range users.Users {
 		rqst := Request{
+			ctx:       ctx,
 			userSvc:   userSvc,
 			ResponseC: responseC,
 			user:      *u,
+			item:      i,
 			rqstType:  rqstType,
+			submitted: submitted,
 		}
 		requests = append(requests, rqst)
 	}
@@ -123,12 +341,12 @@ type BulkProcesor struct {
 	// a message from the channel. These operations should surround any calls requiring
 	// resources (i.e., processing Request-s on 'RequestC'.
 	limitRqstsC chan struct{}
-	logger      *log.Entry
+	logger      logging.Logger
 }
 
 // NewBulkProcessor returns a BulkProcessor which will support bulk user operations with a
 // maximum number of concurrent requests limited by concurrencyLimit
-func NewBulkProcessor(concurrencyLimit int, logger *log.Entry) *BulkProcesor {
+func NewBulkProcessor(concurrencyLimit int, logger logging.Logger) *BulkProcesor {
 	bp := BulkProcesor{
 		RequestC:    make(chan Request, concurrencyLimit),
 		close:       make(chan struct{}),
@@ -168,17 +386,23 @@ func (bp BulkProcesor) process(rqst Request) {
 	}
 	defer releaseResource()
 
+	rqstType := RqstTypeName[rqst.rqstType]
+	httpclient.ObserveSince(rqst.ctx, BulkItemWaitDur.WithLabelValues(rqstType), rqst.submitted)
+	// Each item is a child of the bulk request's span, see UserSvc.handleRqstMultipleUsers
+	ctx, span := httpclient.StartSpan(rqst.ctx, "bulk item")
+	rqst.ctx = ctx
+
 	r := Response{}
 
 	switch rqst.rqstType {
 	case CREATE:
 		bp.logger.Debugf("BulkProcessor processing CREATE request: %+v", rqst)
-		id, err := rqst.userSvc.CreateUser(rqst.user)
+		id, err := rqst.userSvc.CreateUser(rqst.ctx, rqst.user)
 		if err != nil {
 			r = Response{
 				ErrMsg:    err.ErrMsg,
 				ErrReason: err.ErrCode,
-				Status:    StatusBadRequest,
+				Status:    errToStatus(err),
 				User:      rqst.user,
 			}
 		} else {
@@ -188,12 +412,45 @@ func (bp BulkProcesor) process(rqst Request) {
 		}
 	case UPDATE:
 		bp.logger.Debugf("BulkProcessor processing UPDATE request: %+v", rqst)
-		err := rqst.userSvc.UpdateUser(rqst.user)
+		err := rqst.userSvc.UpdateUser(rqst.ctx, rqst.user)
+		if err != nil {
+			r = Response{
+				ErrMsg:    err.ErrMsg,
+				ErrReason: err.ErrCode,
+				Status:    errToStatus(err),
+				User:      rqst.user,
+			}
+		} else {
+			r.Status = StatusOK
+			r.User = rqst.user
+		}
+	case UPSERT:
+		bp.logger.Debugf("BulkProcessor processing UPSERT request: %+v", rqst)
+		id, outcome, err := rqst.userSvc.UpsertUser(rqst.ctx, rqst.user)
 		if err != nil {
 			r = Response{
 				ErrMsg:    err.ErrMsg,
 				ErrReason: err.ErrCode,
-				Status:    StatusBadRequest,
+				Status:    errToStatus(err),
+				User:      rqst.user,
+			}
+		} else {
+			r.Status = StatusOK
+			if outcome == domain.UpsertCreated {
+				r.Status = StatusCreated
+			}
+			r.Outcome = outcome
+			r.User = rqst.user
+			r.User.ID = id
+		}
+	case DELETE:
+		bp.logger.Debugf("BulkProcessor processing DELETE request: %+v", rqst)
+		err := rqst.userSvc.DeleteUser(rqst.ctx, rqst.user.ID)
+		if err != nil {
+			r = Response{
+				ErrMsg:    err.ErrMsg,
+				ErrReason: err.ErrCode,
+				Status:    errToStatus(err),
 				User:      rqst.user,
 			}
 		} else {
@@ -210,6 +467,38 @@ func (bp BulkProcesor) process(rqst Request) {
 		}
 	}
 
+	span.End()
+	BulkItemDur.WithLabelValues(rqstType, StatusTypeName[r.Status]).Observe(span.Duration.Seconds())
+	logSpan(bp.logger, span, StatusTypeName[r.Status])
+
+	// Responses are logged and returned to clients, they mustn't include passwords
+	r.User = withoutPassword(r.User)
+	r.Item = rqst.item
 	rqst.ResponseC <- r
 	bp.logger.Debugf("BulkProcessor.process sent response: %+v", r)
 }
+
+// errToStatus maps the error from an individual request to the request's Status
+func errToStatus(err *errors.MVError) Status {
+	switch err.ErrCode {
+	case errors.UserUnauthorizedErrorCode:
+		return StatusForbidden
+	case errors.DBNoUserErrorCode:
+		return StatusNotFound
+	case errors.DeleteBlockedErrorCode:
+		return StatusConflict
+	}
+	return StatusBadRequest
+}
+
+// logSpan logs 'span', which has ended, at debug level with the status of its work
+func logSpan(logger logging.Logger, span *httpclient.Span, status string) {
+	logging.Log(logger, logging.DebugLevel, "span ended", func(f logging.Fields) {
+		f[logging.SpanName] = span.Name
+		f[logging.TraceID] = span.TraceID
+		f[logging.SpanID] = span.SpanID
+		f[logging.ParentSpanID] = span.ParentID
+		f[logging.Duration] = span.Duration.String()
+		f[logging.Status] = status
+	})
+}
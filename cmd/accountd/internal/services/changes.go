@@ -0,0 +1,159 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/clock"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+const (
+	// DefaultChangeLogSize is the default number of changes retained by a ChangeLog
+	DefaultChangeLogSize = 1000
+	// DefaultChangesWait is the default time a ChangeLog waits for a change when there aren't any
+	DefaultChangesWait = 30 * time.Second
+)
+
+// ChangeLog records the most recent user changes so clients can poll for them, see Since.
+// Changes are only retained in memory, so they're lost when accountd restarts and aren't
+// visible to other accountd instances. Clients recover by retrieving all the users when
+// a ChangesExpiredErrorCode error is returned.
+type ChangeLog struct {
+	size int
+	wait time.Duration
+
+	mu      sync.Mutex
+	clock   clock.Clock
+	changes []domain.UserChange
+	lastSeq int64
+	// changed is closed, and replaced, when a change is recorded to wake up waiting pollers
+	changed chan struct{}
+	closed  bool
+}
+
+// NewChangeLog returns a ChangeLog that retains the last 'size' changes. Pollers wait up to 'wait'
+// for a change when there aren't any. Both must be greater than 0.
+func NewChangeLog(size int, wait time.Duration) (*ChangeLog, error) {
+	if size < 1 {
+		return nil, errors.New("size must be greater than 0")
+	}
+	if wait <= 0 {
+		return nil, errors.New("wait must be greater than 0")
+	}
+	return &ChangeLog{
+		size:    size,
+		wait:    wait,
+		clock:   clock.System,
+		changed: make(chan struct{}),
+	}, nil
+}
+
+// SetClock replaces the Clock, clock.System by default, used to timestamp changes. 'c' must be non-nil.
+func (cl *ChangeLog) SetClock(c clock.Clock) error {
+	if c == nil {
+		return errors.New("non-nil Clock required")
+	}
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	cl.clock = c
+	return nil
+}
+
+// Record records that user 'userID' was changed by 'op', wakes up any waiting pollers, and returns
+// the recorded change
+func (cl *ChangeLog) Record(op domain.ChangeOp, userID int) domain.UserChange {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.lastSeq++
+	c := domain.UserChange{Seq: cl.lastSeq, Op: op, UserID: userID, At: cl.clock.Now()}
+	if len(cl.changes) == cl.size {
+		cl.changes = cl.changes[1:]
+	}
+	cl.changes = append(cl.changes, c)
+
+	close(cl.changed)
+	cl.changed = make(chan struct{})
+	return c
+}
+
+// Latest returns the sequence number of the most recent change, 0 if nothing has been recorded
+func (cl *ChangeLog) Latest() int64 {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	return cl.lastSeq
+}
+
+// Since returns the changes after sequence number 'seq'. If there aren't any it waits until a
+// change is recorded, the ChangeLog's wait elapses, 'ctx' is done, or the ChangeLog is closed,
+// whichever is first, and then returns the changes, if any. A ChangesExpiredErrorCode error is
+// returned if changes after 'seq' are no longer retained, or 'seq' is unknown, e.g., it was
+// returned before accountd restarted.
+func (cl *ChangeLog) Since(ctx context.Context, seq int64) (*domain.UserChanges, *mverr.MVError) {
+	changes, changed, err := cl.since(seq)
+	if err != nil || len(changes.Changes) > 0 || changed == nil {
+		return changes, err
+	}
+
+	timer := time.NewTimer(cl.wait)
+	defer timer.Stop()
+	select {
+	case <-changed:
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	changes, _, err = cl.since(seq)
+	return changes, err
+}
+
+// since returns the changes after 'seq' and, if the ChangeLog isn't closed, the channel that's
+// closed when the next change is recorded
+func (cl *ChangeLog) since(seq int64) (*domain.UserChanges, <-chan struct{}, *mverr.MVError) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	// The first retained change must immediately follow 'seq', otherwise changes were discarded
+	oldest := cl.lastSeq - int64(len(cl.changes)) + 1
+	if seq < 0 || seq > cl.lastSeq || seq+1 < oldest {
+		return nil, nil, &mverr.MVError{
+			ErrCode:   mverr.ChangesExpiredErrorCode,
+			ErrMsg:    mverr.ChangesExpiredErrorMsg,
+			ErrDetail: fmt.Sprintf("changes since %d requested, changes %d through %d are available", seq, oldest, cl.lastSeq),
+		}
+	}
+
+	changes := &domain.UserChanges{Changes: []*domain.UserChange{}, Next: seq}
+	for i := seq + 1 - oldest; i < int64(len(cl.changes)); i++ {
+		c := cl.changes[i]
+		changes.Changes = append(changes.Changes, &c)
+		changes.Next = c.Seq
+	}
+
+	if cl.closed {
+		return changes, nil, nil
+	}
+	return changes, cl.changed, nil
+}
+
+// Close wakes up all waiting pollers, subsequent calls to Since don't wait. Changes can still
+// be recorded. It's used to avoid delaying accountd's shutdown.
+func (cl *ChangeLog) Close() {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if cl.closed {
+		return
+	}
+	cl.closed = true
+	close(cl.changed)
+	cl.changed = make(chan struct{})
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/db/memory"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// seqs returns the sequence numbers of 'changes'
+func seqs(changes *domain.UserChanges) []int64 {
+	s := []int64{}
+	for _, c := range changes.Changes {
+		s = append(s, c.Seq)
+	}
+	return s
+}
+
+func TestChangeLogSince(t *testing.T) {
+	tcs := []struct {
+		testName     string
+		recorded     int
+		since        int64
+		expectedSeqs []int64
+		expectedNext int64
+		expectedErr  mverr.ErrCode
+	}{
+		{testName: "testFromStart", recorded: 3, since: 0, expectedSeqs: []int64{1, 2, 3}, expectedNext: 3},
+		{testName: "testAfterSeq", recorded: 3, since: 1, expectedSeqs: []int64{2, 3}, expectedNext: 3},
+		{testName: "testOldestRetained", recorded: 7, since: 2, expectedSeqs: []int64{3, 4, 5, 6, 7}, expectedNext: 7},
+		{testName: "testEvicted", recorded: 7, since: 1, expectedErr: mverr.ChangesExpiredErrorCode},
+		{testName: "testFutureSeq", recorded: 3, since: 4, expectedErr: mverr.ChangesExpiredErrorCode},
+		{testName: "testNegativeSeq", recorded: 3, since: -1, expectedErr: mverr.ChangesExpiredErrorCode},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			cl, err := NewChangeLog(5, time.Minute)
+			if err != nil {
+				t.Fatalf("error %s was not expected creating a ChangeLog", err)
+			}
+			for i := 1; i <= tc.recorded; i++ {
+				cl.Record(domain.ChangeUpdate, i)
+			}
+
+			changes, mvErr := cl.Since(context.Background(), tc.since)
+			if tc.expectedErr != 0 {
+				if mvErr == nil || mvErr.ErrCode != tc.expectedErr {
+					t.Fatalf("expected error code %d, got %v", tc.expectedErr, mvErr)
+				}
+				return
+			}
+			if mvErr != nil {
+				t.Fatalf("error %s was not expected", mvErr)
+			}
+			if !reflect.DeepEqual(seqs(changes), tc.expectedSeqs) || changes.Next != tc.expectedNext {
+				t.Errorf("expected changes %v, next %d, got %v, next %d", tc.expectedSeqs, tc.expectedNext, seqs(changes), changes.Next)
+			}
+		})
+	}
+}
+
+func TestChangeLogWait(t *testing.T) {
+	tcs := []struct {
+		testName string
+		// wake ends the wait, it's nil if the wait should time out
+		wake         func(cl *ChangeLog, cancel context.CancelFunc)
+		expectedSeqs []int64
+	}{
+		{
+			testName:     "testWakeOnChange",
+			wake:         func(cl *ChangeLog, cancel context.CancelFunc) { cl.Record(domain.ChangeDelete, 1) },
+			expectedSeqs: []int64{1},
+		},
+		{
+			testName:     "testWakeOnCancel",
+			wake:         func(cl *ChangeLog, cancel context.CancelFunc) { cancel() },
+			expectedSeqs: []int64{},
+		},
+		{
+			testName:     "testWakeOnClose",
+			wake:         func(cl *ChangeLog, cancel context.CancelFunc) { cl.Close() },
+			expectedSeqs: []int64{},
+		},
+		{
+			testName:     "testTimeout",
+			expectedSeqs: []int64{},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			wait := 50 * time.Millisecond
+			if tc.wake != nil {
+				// Long enough that the test fails if the poller isn't woken up
+				wait = time.Minute
+			}
+			cl, err := NewChangeLog(5, wait)
+			if err != nil {
+				t.Fatalf("error %s was not expected creating a ChangeLog", err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			done := make(chan *domain.UserChanges, 1)
+			go func() {
+				changes, _ := cl.Since(ctx, 0)
+				done <- changes
+			}()
+			if tc.wake != nil {
+				// Give the poller a chance to start waiting, the test passes either way
+				time.Sleep(10 * time.Millisecond)
+				tc.wake(cl, cancel)
+			}
+
+			select {
+			case changes := <-done:
+				if !reflect.DeepEqual(seqs(changes), tc.expectedSeqs) {
+					t.Errorf("expected changes %v, got %v", tc.expectedSeqs, seqs(changes))
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Since didn't return")
+			}
+		})
+	}
+}
+
+func TestUserSvcRecordsChanges(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	repo := memory.NewUserTable()
+	userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting UserSvc", err)
+	}
+	mailer := &fakeMailer{}
+	if err = userSvc.ConfigureActivation(mailer, time.Hour); err != nil {
+		t.Fatalf("error %s was not expected configuring activation", err)
+	}
+	ctx := auth.NewContext(context.Background(), auth.Caller{UserID: 1, AccountID: 1, Role: domain.Primary})
+
+	start, mvErr := userSvc.GetChanges(ctx, nil)
+	if mvErr != nil || start.Next != 0 || len(start.Changes) != 0 {
+		t.Fatalf("expected no changes and next 0, got %+v, error %v", start, mvErr)
+	}
+
+	// Creating a pending user isn't a change to the active users, activating it is
+	id, mvErr := userSvc.CreateUser(ctx, domain.User{AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Restricted, Password: "pw"})
+	if mvErr != nil {
+		t.Fatalf("error %s was not expected creating a user", mvErr)
+	}
+	if mvErr = userSvc.ActivateUser(ctx, id, mailer.token); mvErr != nil {
+		t.Fatalf("error %s was not expected activating user %d", mvErr, id)
+	}
+	u, _ := repo.GetUser(id)
+	u.Name = "micky dolenz"
+	u.Password = "pw"
+	if mvErr = userSvc.UpdateUser(ctx, *u); mvErr != nil {
+		t.Fatalf("error %s was not expected updating user %d", mvErr, id)
+	}
+	if mvErr = userSvc.DeleteUser(ctx, id); mvErr != nil {
+		t.Fatalf("error %s was not expected deleting user %d", mvErr, id)
+	}
+	// Deleting a user that doesn't exist succeeds but isn't a change
+	if mvErr = userSvc.DeleteUser(ctx, id); mvErr != nil {
+		t.Fatalf("error %s was not expected deleting deleted user %d", mvErr, id)
+	}
+
+	changes, mvErr := userSvc.GetChanges(ctx, &start.Next)
+	if mvErr != nil {
+		t.Fatalf("error %s was not expected getting changes", mvErr)
+	}
+	expected := []domain.UserChange{
+		{Seq: 1, Op: domain.ChangeCreate, UserID: id},
+		{Seq: 2, Op: domain.ChangeUpdate, UserID: id},
+		{Seq: 3, Op: domain.ChangeDelete, UserID: id},
+	}
+	if len(changes.Changes) != len(expected) || changes.Next != 3 {
+		t.Fatalf("expected %d changes and next 3, got %+v", len(expected), changes)
+	}
+	for i, c := range changes.Changes {
+		if c.Seq != expected[i].Seq || c.Op != expected[i].Op || c.UserID != expected[i].UserID || c.At.IsZero() {
+			t.Errorf("expected change %+v, got %+v", expected[i], c)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"github.com/youngkin/mockvideo/internal/metrics"
+)
+
+// DeadLetterDepth is the number of dead letters. It's incremented as work is dead-lettered and
+// decremented as it's replayed. Since the dead letters are shared by all service instances it's
+// reset to the actual number whenever the dead letters are listed.
+var DeadLetterDepth = metrics.NewGauge(prometheus.GaugeOpts{
+	Subsystem: "service",
+	Name:      "dead_letters",
+	Help:      "number of asynchronous work items that failed too many times to be retried automatically",
+})
+
+// DeadLetterSvcInterface defines the administrative operations on dead letters, i.e., asynchronous
+// work that failed too many times to be retried automatically
+type DeadLetterSvcInterface interface {
+	GetDeadLetters(ctx context.Context) (*domain.DeadLetters, *mverr.MVError)
+	GetDeadLetter(ctx context.Context, id int) (*domain.DeadLetter, *mverr.MVError)
+	ReplayDeadLetter(ctx context.Context, id int) *mverr.MVError
+}
+
+// EnableDeadLetters allows dead letters to be listed and replayed. Dead letters are currently only
+// created in write-behind mode, see EnableWriteBehind and WriteBehindWorker.
+func (us *UserSvc) EnableDeadLetters(deadLetters domain.DeadLetterRepository) {
+	us.deadLetters = deadLetters
+}
+
+// GetDeadLetters retrieves all dead letters, oldest first
+func (us *UserSvc) GetDeadLetters(ctx context.Context) (*domain.DeadLetters, *mverr.MVError) {
+	if err := us.checkDeadLetters("GetDeadLetters"); err != nil {
+		return nil, err
+	}
+
+	dls, err := us.deadLetters.GetDeadLetters()
+	if err != nil {
+		us.logUserError(err)
+		return nil, err
+	}
+	DeadLetterDepth.Set(float64(len(dls.DeadLetters)))
+	return dls, nil
+}
+
+// GetDeadLetter retrieves the dead letter identified by 'id'
+func (us *UserSvc) GetDeadLetter(ctx context.Context, id int) (*domain.DeadLetter, *mverr.MVError) {
+	if err := us.checkDeadLetters("GetDeadLetter"); err != nil {
+		return nil, err
+	}
+
+	dl, err := us.deadLetters.GetDeadLetter(id)
+	if err != nil {
+		us.logUserError(err)
+		return nil, err
+	}
+	if dl == nil {
+		err = &mverr.MVError{
+			ErrCode:   mverr.DBNoDeadLetterErrorCode,
+			ErrMsg:    mverr.DBNoDeadLetterErrorMsg,
+			ErrDetail: fmt.Sprintf("Dead letter %d not found", id),
+		}
+		us.logUserError(err)
+		return nil, err
+	}
+	return dl, nil
+}
+
+// ReplayDeadLetter resubmits the work recorded by the dead letter identified by 'id' and removes
+// the dead letter. The work is processed as if it had just been submitted, e.g., a dead-lettered
+// user creation is retried by the WriteBehindWorker. Replaying a dead letter more than once has
+// the same effect as replaying it once.
+func (us *UserSvc) ReplayDeadLetter(ctx context.Context, id int) *mverr.MVError {
+	dl, err := us.GetDeadLetter(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	switch dl.Source {
+	case domain.DeadLetterUserCreate:
+		if us.queue == nil {
+			err = &mverr.MVError{
+				ErrCode:   mverr.WriteBehindDisabledErrorCode,
+				ErrMsg:    mverr.WriteBehindDisabledErrorMsg,
+				ErrDetail: fmt.Sprintf("dead letter %d can't be replayed without a user queue", id),
+			}
+			us.logUserError(err)
+			return err
+		}
+		// The queued user creation is replayed before the dead letter is deleted, if the deletion
+		// fails a retried replay deletes it without replaying the user creation again
+		if _, err = us.queue.ReplayUser(dl.SourceID); err != nil {
+			us.logUserError(err)
+			return err
+		}
+	default:
+		err = &mverr.MVError{
+			ErrCode:   mverr.DeadLetterReplayErrorCode,
+			ErrMsg:    mverr.DeadLetterReplayErrorMsg,
+			ErrDetail: fmt.Sprintf("dead letter %d has unsupported source %q", id, dl.Source),
+		}
+		us.logUserError(err)
+		return err
+	}
+
+	if err = us.deadLetters.DeleteDeadLetter(id); err != nil {
+		us.logUserError(err)
+		return err
+	}
+	DeadLetterDepth.Dec()
+
+	us.logger.WithFields(logging.Fields{
+		logging.Audit:        true,
+		logging.DeadLetterID: id,
+		logging.Attempts:     dl.Attempts,
+		logging.QueueID:      dl.SourceID,
+	}).Info("dead letter replayed")
+	return nil
+}
+
+// checkDeadLetters returns an error if dead letters aren't enabled. 'op' is the operation
+// being attempted.
+func (us *UserSvc) checkDeadLetters(op string) *mverr.MVError {
+	if us.deadLetters != nil {
+		return nil
+	}
+	err := &mverr.MVError{
+		ErrCode:   mverr.DeadLettersDisabledErrorCode,
+		ErrMsg:    mverr.DeadLettersDisabledErrorMsg,
+		ErrDetail: op + " called without a dead letter repository",
+	}
+	us.logUserError(err)
+	return err
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// fakeDeadLetters is an in-memory domain.DeadLetterRepository
+type fakeDeadLetters struct {
+	letters   map[int]*domain.DeadLetter
+	deleteErr *mverr.MVError
+}
+
+func (dls *fakeDeadLetters) GetDeadLetters() (*domain.DeadLetters, *mverr.MVError) {
+	result := &domain.DeadLetters{}
+	for _, dl := range dls.letters {
+		result.DeadLetters = append(result.DeadLetters, dl)
+	}
+	return result, nil
+}
+
+func (dls *fakeDeadLetters) GetDeadLetter(id int) (*domain.DeadLetter, *mverr.MVError) {
+	return dls.letters[id], nil
+}
+
+func (dls *fakeDeadLetters) DeleteDeadLetter(id int) *mverr.MVError {
+	if dls.deleteErr != nil {
+		return dls.deleteErr
+	}
+	delete(dls.letters, id)
+	return nil
+}
+
+func TestReplayDeadLetter(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	deleteErr := &mverr.MVError{ErrCode: mverr.DBDeleteErrorCode, ErrMsg: mverr.DBDeleteErrorMsg}
+
+	tcs := []struct {
+		testName        string
+		source          domain.DeadLetterSource
+		id              int
+		disabled        bool
+		noQueue         bool
+		deleteErr       *mverr.MVError
+		expectedErrCode mverr.ErrCode
+		expectedStatus  domain.QueueStatus
+		expectedDeleted bool
+	}{
+		{
+			testName:        "testReplayDeadLetter",
+			source:          domain.DeadLetterUserCreate,
+			id:              1,
+			expectedStatus:  domain.QueuePending,
+			expectedDeleted: true,
+		},
+		{
+			testName:        "testReplayDeadLetterNotFound",
+			source:          domain.DeadLetterUserCreate,
+			id:              2,
+			expectedErrCode: mverr.DBNoDeadLetterErrorCode,
+			expectedStatus:  domain.QueueDeadLettered,
+		},
+		{
+			testName:        "testReplayDeadLetterDeleteFailed",
+			source:          domain.DeadLetterUserCreate,
+			id:              1,
+			deleteErr:       deleteErr,
+			expectedErrCode: mverr.DBDeleteErrorCode,
+			expectedStatus:  domain.QueuePending,
+		},
+		{
+			testName:        "testReplayDeadLetterUnsupportedSource",
+			source:          "webhook",
+			id:              1,
+			expectedErrCode: mverr.DeadLetterReplayErrorCode,
+			expectedStatus:  domain.QueueDeadLettered,
+		},
+		{
+			testName:        "testReplayDeadLetterWriteBehindDisabled",
+			source:          domain.DeadLetterUserCreate,
+			id:              1,
+			noQueue:         true,
+			expectedErrCode: mverr.WriteBehindDisabledErrorCode,
+			expectedStatus:  domain.QueueDeadLettered,
+		},
+		{
+			testName:        "testReplayDeadLetterDisabled",
+			source:          domain.DeadLetterUserCreate,
+			id:              1,
+			disabled:        true,
+			expectedErrCode: mverr.DeadLettersDisabledErrorCode,
+			expectedStatus:  domain.QueueDeadLettered,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			queue := &fakeUserQueue{done: map[int]*domain.QueuedUser{
+				7: {ID: 7, Status: domain.QueueDeadLettered, Attempts: 3},
+			}}
+			deadLetters := &fakeDeadLetters{
+				letters:   map[int]*domain.DeadLetter{1: {ID: 1, Source: tc.source, SourceID: 7, Attempts: 3}},
+				deleteErr: tc.deleteErr,
+			}
+
+			userSvc, err := NewUserSvc(&fakeUserRepo{}, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			if !tc.noQueue {
+				userSvc.EnableWriteBehind(queue)
+			}
+			if !tc.disabled {
+				userSvc.EnableDeadLetters(deadLetters)
+			}
+
+			err2 := userSvc.ReplayDeadLetter(context.Background(), tc.id)
+			if tc.expectedErrCode == 0 && err2 != nil {
+				t.Fatalf("error %s was not expected", err2)
+			}
+			if tc.expectedErrCode != 0 && (err2 == nil || err2.ErrCode != tc.expectedErrCode) {
+				t.Fatalf("expected error code %d, got %v", tc.expectedErrCode, err2)
+			}
+			if status := queue.done[7].Status; status != tc.expectedStatus {
+				t.Errorf("expected queued user status %s, got %s", tc.expectedStatus, status)
+			}
+			if _, ok := deadLetters.letters[1]; ok == tc.expectedDeleted {
+				t.Errorf("expected dead letter deleted %t, got %t", tc.expectedDeleted, !ok)
+			}
+		})
+	}
+}
+
+func TestGetDeadLetters(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	tcs := []struct {
+		testName        string
+		disabled        bool
+		expectedErrCode mverr.ErrCode
+		expectedCount   int
+	}{
+		{
+			testName:      "testGetDeadLetters",
+			expectedCount: 1,
+		},
+		{
+			testName:        "testGetDeadLettersDisabled",
+			disabled:        true,
+			expectedErrCode: mverr.DeadLettersDisabledErrorCode,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			userSvc, err := NewUserSvc(&fakeUserRepo{}, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			if !tc.disabled {
+				userSvc.EnableDeadLetters(&fakeDeadLetters{letters: map[int]*domain.DeadLetter{
+					1: {ID: 1, Source: domain.DeadLetterUserCreate, SourceID: 7, Attempts: 3},
+				}})
+			}
+
+			dls, err2 := userSvc.GetDeadLetters(context.Background())
+			if tc.expectedErrCode != 0 {
+				if err2 == nil || err2.ErrCode != tc.expectedErrCode {
+					t.Fatalf("expected error code %d, got %v", tc.expectedErrCode, err2)
+				}
+				return
+			}
+			if err2 != nil {
+				t.Fatalf("error %s was not expected", err2)
+			}
+			if len(dls.DeadLetters) != tc.expectedCount {
+				t.Errorf("expected %d dead letters, got %d", tc.expectedCount, len(dls.DeadLetters))
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	"github.com/youngkin/mockvideo/internal/eventbus"
+	"github.com/youngkin/mockvideo/internal/eventschema"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// UserEvent is the payload of the user lifecycle topics. The event doesn't include the user, it
+// should be retrieved if needed.
+type UserEvent struct {
+	UserID int `json:"userid"`
+	// Seq is the sequence number of the corresponding change in the ChangeLog, 0 if there isn't
+	// one, i.e., for UserCreated since pending users aren't returned by GetUsers
+	Seq int64     `json:"seq,omitempty"`
+	At  time.Time `json:"at"`
+}
+
+// The user lifecycle topics UserSvc publishes to, see UserSvc.SetEventBus
+var (
+	// UserCreated is published when a pending user is created
+	UserCreated = eventbus.NewTopic("user.created", UserEvent{})
+	// UserActivated is published when a pending user is activated
+	UserActivated = eventbus.NewTopic("user.activated", UserEvent{})
+	// UserUpdated is published when a user is updated, including having its role changed
+	UserUpdated = eventbus.NewTopic("user.updated", UserEvent{})
+	// UserDeleted is published when a user is deleted, it may not have existed
+	UserDeleted = eventbus.NewTopic("user.deleted", UserEvent{})
+)
+
+// UserEventVersion is the version of the UserEvent schema, it must be incremented by a change to
+// UserEvent that eventschema.Breaking reports as breaking
+const UserEventVersion = 1
+
+// RegisterEventSchemas registers the schemas of the user lifecycle topics' payloads in 'r'
+func RegisterEventSchemas(r *eventschema.Registry) error {
+	for _, t := range []eventbus.Topic{UserCreated, UserActivated, UserUpdated, UserDeleted} {
+		if err := r.Register(t, UserEventVersion); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queuedEvent is an event published once the UnitOfWork it was raised in commits
+type queuedEvent struct {
+	topic eventbus.Topic
+	event UserEvent
+}
+
+// publish publishes 'e' to 't' if an event bus has been set. Within a UnitOfWork the event is
+// queued until the UnitOfWork commits, it's discarded if it's rolled back.
+func (us *UserSvc) publish(t eventbus.Topic, e UserEvent) {
+	if us.events == nil {
+		return
+	}
+	if us.uowEvents != nil {
+		*us.uowEvents = append(*us.uowEvents, queuedEvent{topic: t, event: e})
+		return
+	}
+	if err := us.events.Publish(t, e); err != nil {
+		us.logger.WithFields(logging.Fields{
+			logging.UserID:      e.UserID,
+			logging.ErrorDetail: err,
+		}).Error("unable to publish user event")
+	}
+}
+
+// recordChange records that user 'userID' was changed by 'op' in the ChangeLog and publishes
+// the change to 't'
+func (us *UserSvc) recordChange(op domain.ChangeOp, t eventbus.Topic, userID int) {
+	c := us.changes.Record(op, userID)
+	us.publish(t, UserEvent{UserID: userID, Seq: c.Seq, At: c.At})
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/internal/db/memory"
+	"github.com/youngkin/mockvideo/internal/domain"
+	"github.com/youngkin/mockvideo/internal/eventbus"
+	"github.com/youngkin/mockvideo/internal/eventschema"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+var update = flag.Bool("update", false, "update the event schema golden files")
+
+func TestUserEvents(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	repo := memory.NewUserTable()
+	userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting UserSvc", err)
+	}
+	mailer := &fakeMailer{}
+	if err = userSvc.ConfigureActivation(mailer, DefaultActivationTTL); err != nil {
+		t.Fatalf("error %s was not expected when configuring activation", err)
+	}
+	// The events published must match their schemas
+	schemas := eventschema.NewRegistry()
+	if err = RegisterEventSchemas(schemas); err != nil {
+		t.Fatalf("error %s was not expected registering the event schemas", err)
+	}
+	bus := eventbus.New()
+	defer bus.Close()
+	if err = bus.SetValidator(schemas); err != nil {
+		t.Fatalf("error %s was not expected setting the event bus validator", err)
+	}
+	if err = userSvc.SetEventBus(bus); err != nil {
+		t.Fatalf("error %s was not expected setting the event bus", err)
+	}
+	sub, err := bus.Subscribe("test", 10, eventbus.DropNewest, UserCreated, UserActivated, UserUpdated, UserDeleted)
+	if err != nil {
+		t.Fatalf("error %s was not expected subscribing", err)
+	}
+
+	ctx := context.Background()
+	user := domain.User{AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary, Password: "pw"}
+	id, mvErr := userSvc.CreateUser(ctx, user)
+	if mvErr != nil {
+		t.Fatalf("error %s was not expected creating a user", mvErr)
+	}
+	if mvErr = userSvc.ActivateUser(ctx, id, mailer.token); mvErr != nil {
+		t.Fatalf("error %s was not expected activating user %d", mvErr, id)
+	}
+	user.ID = id
+	user.Name = "micky dolenz"
+	if mvErr = userSvc.UpdateUser(ctx, user); mvErr != nil {
+		t.Fatalf("error %s was not expected updating user %d", mvErr, id)
+	}
+	if mvErr = userSvc.DeleteUser(ctx, id); mvErr != nil {
+		t.Fatalf("error %s was not expected deleting user %d", mvErr, id)
+	}
+	// Deleting a user that doesn't exist isn't published
+	if mvErr = userSvc.DeleteUser(ctx, id); mvErr != nil {
+		t.Fatalf("error %s was not expected deleting deleted user %d", mvErr, id)
+	}
+	// A failed update isn't published
+	if mvErr = userSvc.UpdateUser(ctx, user); mvErr == nil {
+		t.Fatalf("expected an error updating deleted user %d", id)
+	}
+
+	expected := []struct {
+		topic string
+		seq   int64
+	}{
+		{topic: UserCreated.Name(), seq: 0},
+		{topic: UserActivated.Name(), seq: 1},
+		{topic: UserUpdated.Name(), seq: 2},
+		{topic: UserDeleted.Name(), seq: 3},
+	}
+	if len(sub.C()) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(sub.C()))
+	}
+	for _, exp := range expected {
+		e := <-sub.C()
+		ue := e.Payload.(UserEvent)
+		if e.Topic.Name() != exp.topic || ue.UserID != id || ue.Seq != exp.seq || ue.At.IsZero() {
+			t.Errorf("expected %s for user %d with Seq %d, got %s %+v", exp.topic, id, exp.seq, e.Topic.Name(), ue)
+		}
+	}
+}
+
+// TestEventSchemaCompatibility protects the consumers of the user lifecycle events from breaking
+// changes to UserEvent. Each topic's current schema is compared against the one consumers were built
+// against, testdata/<topic>.v<version>.json. A breaking change requires incrementing UserEventVersion.
+// Additions, and new versions, aren't breaking but the golden files must be updated to include them:
+//
+//	go test ./cmd/accountd/internal/services -run TestEventSchemaCompatibility -update
+func TestEventSchemaCompatibility(t *testing.T) {
+	schemas := eventschema.NewRegistry()
+	if err := RegisterEventSchemas(schemas); err != nil {
+		t.Fatalf("error %s was not expected registering the event schemas", err)
+	}
+
+	for _, s := range schemas.Schemas() {
+		t.Run(s.Topic, func(t *testing.T) {
+			current, err := json.MarshalIndent(s.JSON, "", "  ")
+			if err != nil {
+				t.Fatalf("error %s was not expected marshaling the %s schema", err, s.Topic)
+			}
+			current = append(current, '\n')
+			gf := filepath.Join("testdata", fmt.Sprintf("%s.v%d.json", s.Topic, s.Version))
+			if *update {
+				if err = ioutil.WriteFile(gf, current, 0644); err != nil {
+					t.Fatalf("failed to update golden file: %s", err)
+				}
+				return
+			}
+			gfc, err := ioutil.ReadFile(gf)
+			if err != nil {
+				t.Fatalf("failed reading golden file, run the test with -update if this is a new version: %s", err)
+			}
+			var golden eventschema.JSONSchema
+			if err = json.Unmarshal(gfc, &golden); err != nil {
+				t.Fatalf("error %s was not expected unmarshaling %s", err, gf)
+			}
+
+			for _, change := range eventschema.Breaking(&golden, s.JSON) {
+				t.Errorf("breaking change to version %d: %s", s.Version, change)
+			}
+			if string(gfc) != string(current) {
+				t.Errorf("the %s schema doesn't match %s, run the test with -update to update it", s.Topic, gf)
+			}
+		})
+	}
+}
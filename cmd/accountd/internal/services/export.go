@@ -0,0 +1,256 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/blob"
+	"github.com/youngkin/mockvideo/internal/clock"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// ExportFileName is the name of the JSON file containing the domain.AccountExport within an export's zip archive
+const ExportFileName = "account.json"
+
+// ExportSvcInterface defines the operations to be supported by any types that provide
+// the implementations of account data export usecases
+type ExportSvcInterface interface {
+	// StartExport begins generating an export of account 'accountID' and returns the pending job
+	StartExport(ctx context.Context, accountID int) (*domain.ExportJob, *mverr.MVError)
+	// GetExport returns the current state of the export job 'jobID' of account 'accountID'
+	GetExport(ctx context.Context, accountID, jobID int) (*domain.ExportJob, *mverr.MVError)
+	// OpenExport returns the zip archive of the completed export job 'jobID' of account
+	// 'accountID'. The caller must close the returned ReadCloser.
+	OpenExport(ctx context.Context, accountID, jobID int) (io.ReadCloser, *mverr.MVError)
+}
+
+// ExportSvc generates account data exports in the background and stores them in a blob.Store.
+// Export jobs are only tracked in memory, so they're lost when accountd restarts and aren't
+// visible to other accountd instances.
+type ExportSvc struct {
+	authorizer
+	userSvc UserSvcInterface
+	store   blob.Store
+	logger  logging.Logger
+
+	mu     sync.Mutex
+	clock  clock.Clock
+	jobs   map[int]*domain.ExportJob
+	nextID int
+	// running tracks in-progress exports so tests can wait for them
+	running sync.WaitGroup
+}
+
+// NewExportSvc returns a new instance that handles account data export usecases. Exports are
+// written to 'store'. 'userSvc', 'store', and 'logger' must be non-nil.
+func NewExportSvc(userSvc UserSvcInterface, store blob.Store, logger logging.Logger) (*ExportSvc, error) {
+	if userSvc == nil {
+		return nil, errors.New("non-nil UserSvcInterface required")
+	}
+	if store == nil {
+		return nil, errors.New("non-nil blob.Store required")
+	}
+	if logger == nil {
+		return nil, errors.New("non-nil Logger required")
+	}
+	return &ExportSvc{
+		userSvc: userSvc,
+		store:   store,
+		logger:  logger,
+		clock:   clock.System,
+		jobs:    make(map[int]*domain.ExportJob),
+		nextID:  1,
+	}, nil
+}
+
+// SetClock replaces the Clock, clock.System by default, used to timestamp exports. 'c' must be non-nil.
+func (es *ExportSvc) SetClock(c clock.Clock) error {
+	if c == nil {
+		return errors.New("non-nil Clock required")
+	}
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	es.clock = c
+	return nil
+}
+
+// StartExport authorizes the request and starts generating the export in the background. Only
+// a primary user of the account can export it since the export contains the PII of all its users.
+func (es *ExportSvc) StartExport(ctx context.Context, accountID int) (*domain.ExportJob, *mverr.MVError) {
+	if err := es.authorize(ctx, READ, accountID); err != nil {
+		return nil, err
+	}
+
+	es.mu.Lock()
+	job := &domain.ExportJob{
+		ID:        es.nextID,
+		AccountID: accountID,
+		Status:    domain.ExportPending,
+		Created:   es.clock.Now(),
+	}
+	job.BlobName = fmt.Sprintf("account-%d-export-%d.zip", accountID, job.ID)
+	es.jobs[job.ID] = job
+	es.nextID++
+	pending := *job
+	es.mu.Unlock()
+
+	es.running.Add(1)
+	go func() {
+		defer es.running.Done()
+		// The export outlives the request that started it
+		es.export(context.Background(), pending)
+	}()
+
+	return &pending, nil
+}
+
+// GetExport returns a copy of the export job if the caller is authorized to export the account
+func (es *ExportSvc) GetExport(ctx context.Context, accountID, jobID int) (*domain.ExportJob, *mverr.MVError) {
+	if err := es.authorize(ctx, READ, accountID); err != nil {
+		return nil, err
+	}
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	job, ok := es.jobs[jobID]
+	if !ok || job.AccountID != accountID {
+		return nil, &mverr.MVError{
+			ErrCode:   mverr.DBNoExportErrorCode,
+			ErrMsg:    mverr.DBNoExportErrorMsg,
+			ErrDetail: fmt.Sprintf("no export %d for account %d", jobID, accountID),
+		}
+	}
+	found := *job
+	return &found, nil
+}
+
+// OpenExport returns the export's zip archive. ExportNotReadyErrorCode is returned if the export
+// is still pending, ExportErrorCode if it failed.
+func (es *ExportSvc) OpenExport(ctx context.Context, accountID, jobID int) (io.ReadCloser, *mverr.MVError) {
+	job, mvErr := es.GetExport(ctx, accountID, jobID)
+	if mvErr != nil {
+		return nil, mvErr
+	}
+
+	switch job.Status {
+	case domain.ExportPending:
+		return nil, &mverr.MVError{
+			ErrCode:   mverr.ExportNotReadyErrorCode,
+			ErrMsg:    mverr.ExportNotReadyErrorMsg,
+			ErrDetail: fmt.Sprintf("export %d for account %d is pending", jobID, accountID),
+		}
+	case domain.ExportFailed:
+		return nil, &mverr.MVError{
+			ErrCode:   mverr.ExportErrorCode,
+			ErrMsg:    mverr.ExportErrorMsg,
+			ErrDetail: fmt.Sprintf("export %d for account %d failed: %s", jobID, accountID, job.ErrMsg),
+		}
+	}
+
+	rc, err := es.store.Get(ctx, job.BlobName)
+	if err != nil {
+		return nil, &mverr.MVError{
+			ErrCode:    mverr.ExportErrorCode,
+			ErrMsg:     mverr.ExportErrorMsg,
+			ErrDetail:  fmt.Sprintf("unable to read export %d for account %d", jobID, accountID),
+			WrappedErr: err,
+		}
+	}
+	return rc, nil
+}
+
+// export generates the export described by 'job', stores it, and records the outcome
+func (es *ExportSvc) export(ctx context.Context, job domain.ExportJob) {
+	err := es.writeExport(ctx, job)
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	stored := es.jobs[job.ID]
+	if err != nil {
+		es.logger.WithFields(logging.Fields{
+			logging.ErrorCode:   err.ErrCode,
+			logging.ErrorDetail: err.Error(),
+			logging.AccountID:   job.AccountID,
+		}).Error(mverr.ExportErrorMsg)
+		stored.Status = domain.ExportFailed
+		stored.ErrMsg = err.ErrMsg
+		return
+	}
+
+	es.logger.WithFields(logging.Fields{
+		logging.Audit:     true,
+		logging.AccountID: job.AccountID,
+	}).Info("account export created")
+	stored.Status = domain.ExportComplete
+}
+
+// writeExport writes the zip archive of the account's export to the blob.Store
+func (es *ExportSvc) writeExport(ctx context.Context, job domain.ExportJob) *mverr.MVError {
+	users, mvErr := accountUsers(ctx, es.userSvc, job.AccountID)
+	if mvErr != nil {
+		return mvErr
+	}
+	if len(users) == 0 {
+		return &mverr.MVError{
+			ErrCode:   mverr.DBNoAccountErrorCode,
+			ErrMsg:    mverr.DBNoAccountErrorMsg,
+			ErrDetail: fmt.Sprintf("account %d has no users", job.AccountID),
+		}
+	}
+
+	export := domain.AccountExport{AccountID: job.AccountID, ExportedAt: es.now()}
+	for _, u := range users {
+		exported := *u
+		exported.Password = ""
+		export.Users = append(export.Users, &exported)
+	}
+
+	var archive bytes.Buffer
+	err := writeZip(&archive, export)
+	if err == nil {
+		_, err = es.store.Put(ctx, job.BlobName, &archive)
+	}
+	if err != nil {
+		return &mverr.MVError{
+			ErrCode:    mverr.ExportErrorCode,
+			ErrMsg:     mverr.ExportErrorMsg,
+			ErrDetail:  fmt.Sprintf("unable to write export %d for account %d", job.ID, job.AccountID),
+			WrappedErr: err,
+		}
+	}
+	return nil
+}
+
+func (es *ExportSvc) now() time.Time {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	return es.clock.Now()
+}
+
+// writeZip writes a zip archive containing 'export' as ExportFileName to 'w'
+func writeZip(w io.Writer, export domain.AccountExport) error {
+	zw := zip.NewWriter(w)
+	f, err := zw.Create(ExportFileName)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(export); err != nil {
+		return err
+	}
+	return zw.Close()
+}
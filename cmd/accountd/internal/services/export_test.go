@@ -0,0 +1,255 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/clock"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// memStore is a blob.Store that keeps blobs in memory
+type memStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+	err   error
+}
+
+func (ms *memStore) Put(ctx context.Context, name string, r io.Reader) (string, error) {
+	if ms.err != nil {
+		return "", ms.err
+	}
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.blobs[name] = content
+	return "mem://" + name, nil
+}
+
+func (ms *memStore) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	content, ok := ms.blobs[name]
+	if !ok {
+		return nil, errors.New("blob not found")
+	}
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+func TestExport(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	users := &domain.Users{Users: []*domain.User{
+		{AccountID: 1, ID: 2, Name: "mickey dolenz", EMail: "mickey@monkees.com", Role: domain.Restricted, Password: "secret"},
+		{AccountID: 2, ID: 3, Name: "davy jones", Role: domain.Primary, Password: "secret"},
+		{AccountID: 1, ID: 1, Name: "porgy tirebiter", EMail: "porgy@firesign.com", Role: domain.Primary, Password: "secret"},
+	}}
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tcs := []struct {
+		testName         string
+		accountID        int
+		caller           *auth.Caller
+		storeErr         error
+		expectedStartErr mverr.ErrCode
+		expectedStatus   domain.ExportStatus
+		expectedUserIDs  []int
+		expectedOpenErr  mverr.ErrCode
+	}{
+		{
+			testName:        "testExportComplete",
+			accountID:       1,
+			caller:          &auth.Caller{UserID: 1, AccountID: 1, Role: domain.Primary},
+			expectedStatus:  domain.ExportComplete,
+			expectedUserIDs: []int{1, 2},
+		},
+		{
+			testName:        "testExportNoCaller",
+			accountID:       2,
+			expectedStatus:  domain.ExportComplete,
+			expectedUserIDs: []int{3},
+		},
+		{
+			testName:         "testExportNonPrimaryCaller",
+			accountID:        1,
+			caller:           &auth.Caller{UserID: 2, AccountID: 1, Role: domain.Restricted},
+			expectedStartErr: mverr.UserUnauthorizedErrorCode,
+		},
+		{
+			testName:         "testExportOtherAccount",
+			accountID:        2,
+			caller:           &auth.Caller{UserID: 1, AccountID: 1, Role: domain.Primary},
+			expectedStartErr: mverr.UserUnauthorizedErrorCode,
+		},
+		{
+			testName:        "testExportNoAccount",
+			accountID:       9,
+			expectedStatus:  domain.ExportFailed,
+			expectedOpenErr: mverr.ExportErrorCode,
+		},
+		{
+			testName:        "testExportStoreFailure",
+			accountID:       1,
+			storeErr:        errors.New("disk full"),
+			expectedStatus:  domain.ExportFailed,
+			expectedOpenErr: mverr.ExportErrorCode,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			store := &memStore{blobs: make(map[string][]byte), err: tc.storeErr}
+			es, err := NewExportSvc(summaryUserSvc{users: users}, store, logger)
+			if err != nil {
+				t.Fatalf("error %s was not expected creating ExportSvc", err)
+			}
+			es.SetClock(clock.NewFrozen(now))
+
+			ctx := context.Background()
+			if tc.caller != nil {
+				ctx = auth.NewContext(ctx, *tc.caller)
+			}
+
+			job, mvErr := es.StartExport(ctx, tc.accountID)
+			if tc.expectedStartErr != 0 {
+				if mvErr == nil || mvErr.ErrCode != tc.expectedStartErr {
+					t.Fatalf("expected error code %d, got %v", tc.expectedStartErr, mvErr)
+				}
+				return
+			}
+			if mvErr != nil {
+				t.Fatalf("error %s was not expected starting export", mvErr)
+			}
+			if job.Status != domain.ExportPending || job.AccountID != tc.accountID {
+				t.Errorf("expected pending job for account %d, got %+v", tc.accountID, job)
+			}
+
+			es.running.Wait()
+
+			job, mvErr = es.GetExport(ctx, tc.accountID, job.ID)
+			if mvErr != nil {
+				t.Fatalf("error %s was not expected getting export", mvErr)
+			}
+			if job.Status != tc.expectedStatus {
+				t.Fatalf("expected status %s, got %s", tc.expectedStatus, job.Status)
+			}
+
+			rc, mvErr := es.OpenExport(ctx, tc.accountID, job.ID)
+			if tc.expectedOpenErr != 0 {
+				if mvErr == nil || mvErr.ErrCode != tc.expectedOpenErr {
+					t.Fatalf("expected error code %d, got %v", tc.expectedOpenErr, mvErr)
+				}
+				return
+			}
+			if mvErr != nil {
+				t.Fatalf("error %s was not expected opening export", mvErr)
+			}
+			defer rc.Close()
+
+			export := readExport(t, rc)
+			if export.AccountID != tc.accountID || !export.ExportedAt.Equal(now) {
+				t.Errorf("expected export of account %d at %s, got account %d at %s", tc.accountID, now, export.AccountID, export.ExportedAt)
+			}
+			userIDs := []int{}
+			for _, u := range export.Users {
+				userIDs = append(userIDs, u.ID)
+				if u.Password != "" {
+					t.Errorf("expected password of user %d to be excluded", u.ID)
+				}
+			}
+			if !reflect.DeepEqual(userIDs, tc.expectedUserIDs) {
+				t.Errorf("expected user IDs %v, got %v", tc.expectedUserIDs, userIDs)
+			}
+			// The users returned by the UserSvc must not be modified
+			for _, u := range users.Users {
+				if u.Password == "" {
+					t.Errorf("expected password of source user %d to be unchanged", u.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestGetExportNotFound(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	users := &domain.Users{Users: []*domain.User{{AccountID: 1, ID: 1, Role: domain.Primary}}}
+	es, err := NewExportSvc(summaryUserSvc{users: users}, &memStore{blobs: make(map[string][]byte)}, logger)
+	if err != nil {
+		t.Fatalf("error %s was not expected creating ExportSvc", err)
+	}
+	job, mvErr := es.StartExport(context.Background(), 1)
+	if mvErr != nil {
+		t.Fatalf("error %s was not expected starting export", mvErr)
+	}
+	es.running.Wait()
+
+	tcs := []struct {
+		testName  string
+		accountID int
+		jobID     int
+	}{
+		{testName: "testUnknownJob", accountID: 1, jobID: job.ID + 1},
+		{testName: "testJobOfOtherAccount", accountID: 2, jobID: job.ID},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			if _, mvErr := es.GetExport(context.Background(), tc.accountID, tc.jobID); mvErr == nil || mvErr.ErrCode != mverr.DBNoExportErrorCode {
+				t.Errorf("expected error code %d, got %v", mverr.DBNoExportErrorCode, mvErr)
+			}
+			if _, mvErr := es.OpenExport(context.Background(), tc.accountID, tc.jobID); mvErr == nil || mvErr.ErrCode != mverr.DBNoExportErrorCode {
+				t.Errorf("expected error code %d, got %v", mverr.DBNoExportErrorCode, mvErr)
+			}
+		})
+	}
+}
+
+// readExport returns the domain.AccountExport in the zip archive read from 'r'
+func readExport(t *testing.T, r io.Reader) domain.AccountExport {
+	t.Helper()
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("error %s was not expected reading export", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("error %s was not expected opening zip archive", err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != ExportFileName {
+		t.Fatalf("expected only %s in the zip archive, got %d files", ExportFileName, len(zr.File))
+	}
+	f, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatalf("error %s was not expected opening %s", err, ExportFileName)
+	}
+	defer f.Close()
+
+	var export domain.AccountExport
+	if err = json.NewDecoder(f).Decode(&export); err != nil {
+		t.Fatalf("error %s was not expected decoding %s", err, ExportFileName)
+	}
+	return export
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/youngkin/mockvideo/internal/httpclient"
+)
+
+// BillingdInvoiceSvc is an InvoiceSvc that gets an account's invoices from the billingd service
+type BillingdInvoiceSvc struct {
+	baseURL string
+	client  *httpclient.Client
+}
+
+// NewBillingdInvoiceSvc returns an InvoiceSvc for the billingd service at 'baseURL', e.g.,
+// 'http://billingd.kube'. 'client' must be non-nil.
+func NewBillingdInvoiceSvc(baseURL string, client *httpclient.Client) (*BillingdInvoiceSvc, error) {
+	if len(baseURL) == 0 {
+		return nil, errors.New("non-empty baseURL required")
+	}
+	if client == nil {
+		return nil, errors.New("non-nil *httpclient.Client required")
+	}
+	return &BillingdInvoiceSvc{baseURL: strings.TrimSuffix(baseURL, "/"), client: client}, nil
+}
+
+// OutstandingTotal returns the total of the account's unpaid invoices using
+// 'GET /accounts/{id}/invoices/outstanding'
+func (s *BillingdInvoiceSvc) OutstandingTotal(ctx context.Context, accountID int) (float64, error) {
+	url := fmt.Sprintf("%s/accounts/%d/invoices/outstanding", s.baseURL, accountID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("GET %s: unexpected HTTP status %d", url, resp.StatusCode)
+	}
+
+	invoices := struct {
+		Total float64 `json:"total"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&invoices); err != nil {
+		return 0, fmt.Errorf("GET %s: %s", url, err)
+	}
+	return invoices.Total, nil
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/httpclient"
+)
+
+func TestBillingdOutstandingTotal(t *testing.T) {
+	tcs := []struct {
+		testName      string
+		status        int
+		body          string
+		expectedTotal float64
+		shouldError   bool
+	}{
+		{
+			testName:      "testTotal",
+			status:        http.StatusOK,
+			body:          `{"total":24.99}`,
+			expectedTotal: 24.99,
+		},
+		{
+			testName:    "testNotFound",
+			status:      http.StatusNotFound,
+			shouldError: true,
+		},
+		{
+			testName:    "testMalformedBody",
+			status:      http.StatusOK,
+			body:        `{"total":"lots"}`,
+			shouldError: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/accounts/1/invoices/outstanding" {
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+
+			breaker, _ := httpclient.NewBreaker(5, time.Minute)
+			client, _ := httpclient.New("billingd", time.Second, 0, breaker)
+			invoiceSvc, err := NewBillingdInvoiceSvc(srv.URL+"/", client)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting BillingdInvoiceSvc", err)
+			}
+
+			total, err := invoiceSvc.OutstandingTotal(context.Background(), 1)
+			if tc.shouldError {
+				if err == nil {
+					t.Errorf("expected an error, got total %f", total)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error %s was not expected", err)
+			}
+			if total != tc.expectedTotal {
+				t.Errorf("expected total %f, got %f", tc.expectedTotal, total)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// DefaultSessionTTL is how long the session token issued to a user that logs in is valid for
+const DefaultSessionTTL = time.Hour
+
+// EnableLogin allows users to log in via Login. Their session tokens are issued with 'keys', so
+// they're accepted wherever JWTs signed with 'keys' are, and are valid for 'ttl'. 'keys' must be
+// non-nil and 'ttl' must be greater than 0.
+func (us *UserSvc) EnableLogin(keys *auth.JWTKeys, ttl time.Duration) error {
+	if keys == nil {
+		return errors.New("non-nil *auth.JWTKeys required")
+	}
+	if ttl <= 0 {
+		return errors.New("ttl must be greater than 0")
+	}
+	decoy, err := auth.HashPassword("", us.passwordCost)
+	if err != nil {
+		return err
+	}
+	us.sessions = keys
+	us.sessionTTL = ttl
+	us.decoyHash = decoy
+	return nil
+}
+
+// Login authenticates the user with the email address and password in 'creds', records their
+// login, see RecordLogin, and issues them a session token. Only Active users can log in. Every
+// failure to authenticate returns the same InvalidCredentialsErrorCode error, and takes about as
+// long, so callers can't discover which email addresses belong to users. Like RecordLogin it isn't
+// authorized, the caller is the user logging in.
+func (us *UserSvc) Login(ctx context.Context, creds domain.Credentials) (*domain.Session, *mverr.MVError) {
+	if us.sessions == nil {
+		err := &mverr.MVError{
+			ErrCode:   mverr.LoginDisabledErrorCode,
+			ErrMsg:    mverr.LoginDisabledErrorMsg,
+			ErrDetail: "Login called without JWT signing keys",
+		}
+		us.logUserError(err)
+		return nil, err
+	}
+
+	us.readPool.Acquire()
+	u, hash, err := us.users(ctx).GetCredentials(creds.EMail)
+	us.readPool.Release()
+	if err != nil {
+		us.logUserError(err)
+		return nil, err
+	}
+
+	// The password is checked even if there's no user, so the failure takes as long as a wrong password
+	var reason string
+	switch {
+	case u == nil:
+		auth.CheckPassword(us.decoyHash, creds.Password)
+		reason = "no user has the email address"
+	case !auth.CheckPassword(hash, creds.Password):
+		reason = "wrong password"
+	case u.Status != domain.Active:
+		reason = "the user isn't active"
+	}
+	if reason != "" {
+		err = &mverr.MVError{
+			ErrCode:   mverr.InvalidCredentialsErrorCode,
+			ErrMsg:    mverr.InvalidCredentialsErrorMsg,
+			ErrDetail: "login failed: " + reason,
+		}
+		fields := logging.Fields{
+			logging.Audit:       true,
+			logging.ErrorCode:   err.ErrCode,
+			logging.ErrorDetail: err.ErrDetail,
+		}
+		if u != nil {
+			fields[logging.UserID] = u.ID
+		}
+		us.logger.WithFields(fields).Warn(err.ErrMsg)
+		return nil, err
+	}
+
+	token, expiresAt, issueErr := us.sessions.Issue(auth.Caller{UserID: u.ID, AccountID: u.AccountID, Role: u.Role}, us.sessionTTL)
+	if issueErr != nil {
+		err = &mverr.MVError{
+			ErrCode:    mverr.UnknownErrorCode,
+			ErrMsg:     mverr.UnknownErrorMsg,
+			ErrDetail:  "unable to issue a session token",
+			WrappedErr: issueErr,
+		}
+		us.logUserError(err)
+		return nil, err
+	}
+
+	// The user is logged in even if the login can't be recorded, it only affects GetInactiveUsers
+	us.writePool.Acquire()
+	if err = us.users(ctx).RecordLogin(u.ID); err != nil {
+		us.logUserError(err)
+	}
+	us.writePool.Release()
+
+	us.logger.WithFields(logging.Fields{
+		logging.Audit:  true,
+		logging.UserID: u.ID,
+	}).Info("user logged in")
+	return &domain.Session{UserID: u.ID, Token: token, ExpiresAt: expiresAt}, nil
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/db/memory"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+func TestLogin(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	hash, err := auth.HashPassword("heyheywerethemonkees", auth.MinPasswordCost)
+	if err != nil {
+		t.Fatalf("error %s was not expected hashing the password", err)
+	}
+	repo := memory.NewUserTable()
+	active, mvErr := repo.CreateUser(domain.User{AccountID: 2, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Unrestricted, Password: hash})
+	if mvErr != nil {
+		t.Fatalf("error %s was not expected creating the active user", mvErr)
+	}
+	_, mvErr = repo.CreateUser(domain.User{AccountID: 2, Name: "davy jones", EMail: "davyj@gmail.com", Role: domain.Restricted, Password: hash,
+		Status: domain.Pending, ActivationToken: "token", ActivationExpiry: time.Now().Add(time.Hour)})
+	if mvErr != nil {
+		t.Fatalf("error %s was not expected creating the pending user", mvErr)
+	}
+
+	keys, err := auth.ParseJWTKeys(strings.NewReader("2020-10 fedcba9876543210fedcba9876543210"))
+	if err != nil {
+		t.Fatalf("error %s was not expected parsing the JWT keys", err)
+	}
+
+	tcs := []struct {
+		testName        string
+		creds           domain.Credentials
+		loginDisabled   bool
+		expectedErrCode mverr.ErrCode
+	}{
+		{testName: "testLoginSuccess", creds: domain.Credentials{EMail: "mickeyd@gmail.com", Password: "heyheywerethemonkees"}},
+		{testName: "testLoginWrongPassword", creds: domain.Credentials{EMail: "mickeyd@gmail.com", Password: "daydreambeliever"}, expectedErrCode: mverr.InvalidCredentialsErrorCode},
+		{testName: "testLoginUnknownEmail", creds: domain.Credentials{EMail: "ptork@gmail.com", Password: "heyheywerethemonkees"}, expectedErrCode: mverr.InvalidCredentialsErrorCode},
+		{testName: "testLoginPendingUser", creds: domain.Credentials{EMail: "davyj@gmail.com", Password: "heyheywerethemonkees"}, expectedErrCode: mverr.InvalidCredentialsErrorCode},
+		{testName: "testLoginDisabled", creds: domain.Credentials{EMail: "mickeyd@gmail.com", Password: "heyheywerethemonkees"}, loginDisabled: true, expectedErrCode: mverr.LoginDisabledErrorCode},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			if err = userSvc.SetPasswordCost(auth.MinPasswordCost); err != nil {
+				t.Fatalf("error %s was not expected setting the password cost", err)
+			}
+			if !tc.loginDisabled {
+				if err = userSvc.EnableLogin(keys, DefaultSessionTTL); err != nil {
+					t.Fatalf("error %s was not expected enabling logins", err)
+				}
+			}
+
+			start := time.Now()
+			session, mvErr := userSvc.Login(context.Background(), tc.creds)
+			if tc.expectedErrCode != mverr.NoErrorCode {
+				if mvErr == nil || mvErr.ErrCode != tc.expectedErrCode {
+					t.Fatalf("expected error code %d, got %v", tc.expectedErrCode, mvErr)
+				}
+				return
+			}
+			if mvErr != nil {
+				t.Fatalf("error %s was not expected logging in", mvErr)
+			}
+
+			caller, err := keys.Caller(session.Token)
+			if err != nil {
+				t.Fatalf("error %s was not expected verifying the session token", err)
+			}
+			expected := auth.Caller{UserID: active, AccountID: 2, Role: domain.Unrestricted}
+			if session.UserID != active || caller.UserID != expected.UserID || caller.AccountID != expected.AccountID || caller.Role != expected.Role {
+				t.Errorf("expected a session for %+v, got %+v for %+v", expected, session, caller)
+			}
+			if session.ExpiresAt.Before(start.Add(DefaultSessionTTL).Truncate(time.Second)) {
+				t.Errorf("expected the session to expire in %s, got %s", DefaultSessionTTL, session.ExpiresAt)
+			}
+			if u, _ := repo.GetUser(active); u == nil || u.LastLogin == nil {
+				t.Errorf("expected the user's login to be recorded, got %+v", u)
+			}
+		})
+	}
+}
+
+func TestEnableLogin(t *testing.T) {
+	userSvc, err := NewUserSvc(memory.NewUserTable(), logging.Default(), 10, 10, 10)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting UserSvc", err)
+	}
+	keys, err := auth.ParseJWTKeys(strings.NewReader("2020-10 fedcba9876543210fedcba9876543210"))
+	if err != nil {
+		t.Fatalf("error %s was not expected parsing the JWT keys", err)
+	}
+	if err = userSvc.EnableLogin(nil, DefaultSessionTTL); err == nil {
+		t.Errorf("expected an error enabling logins without JWT keys")
+	}
+	if err = userSvc.EnableLogin(keys, 0); err == nil {
+		t.Errorf("expected an error enabling logins without a session ttl")
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	"github.com/youngkin/mockvideo/internal/locale"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// Mailer sends email to users
+type Mailer interface {
+	// SendActivation sends 'user' the token needed to activate their account. The message
+	// should be rendered in the language preferred by the caller in 'ctx', see locale.Match.
+	SendActivation(ctx context.Context, user domain.User, token string) error
+}
+
+// LogMailer is a Mailer that logs messages instead of sending them. It's useful for
+// development and testing where there is no mail server.
+type LogMailer struct {
+	logger logging.Logger
+}
+
+// NewLogMailer returns a Mailer that logs messages to 'logger'
+func NewLogMailer(logger logging.Logger) (*LogMailer, error) {
+	if logger == nil {
+		return nil, errors.New("non-nil Logger required")
+	}
+	return &LogMailer{logger: logger}, nil
+}
+
+// SendActivation logs the activation request that would have been emailed to 'user'
+func (m *LogMailer) SendActivation(ctx context.Context, user domain.User, token string) error {
+	m.logger.WithFields(logging.Fields{
+		logging.Locale:    locale.Preferred(ctx),
+		logging.UserID:    user.ID,
+		logging.UserEMail: user.EMail,
+	}).Info(fmt.Sprintf("activation email: POST /users/%d/activate?token=%s", user.ID, token))
+	return nil
+}
@@ -0,0 +1,343 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/httpclient"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"github.com/youngkin/mockvideo/internal/metrics"
+)
+
+// DefaultSearchIndex is the name of the index users are mirrored to, unless configured otherwise
+const DefaultSearchIndex = "users"
+
+// searchRebuildPageSize is the number of users indexed by each bulk request when the index is rebuilt
+const searchRebuildPageSize = 500
+
+// SearchIndexUpdates counts the user changes mirrored to the search index. The 'result' label
+// should be one of 'ok|error'.
+var SearchIndexUpdates = metrics.NewCounterVec(prometheus.CounterOpts{
+	Subsystem: "service",
+	Name:      "search_index_updates_total",
+	Help:      "number of user changes mirrored to the search index",
+}, []string{"result"})
+
+// UserSearcher finds the users whose name or email address matches 'query', see UserSvc.SearchUsers
+type UserSearcher interface {
+	SearchUsers(ctx context.Context, query string, limit int) (*domain.Users, error)
+}
+
+// ElasticsearchUserIndex is a UserSearcher backed by an Elasticsearch, or OpenSearch, index. It
+// mirrors the users returned by UserSvc.GetUsers into the index by following the changes recorded
+// in a ChangeLog. The index is rebuilt when the ElasticsearchUserIndex starts, and whenever changes
+// were discarded by the ChangeLog before they were mirrored, so the index needn't be durable. A
+// change that can't be mirrored is logged and counted, the user is stale in the index until the
+// next change to it. Searches fail until the index has been built.
+type ElasticsearchUserIndex struct {
+	baseURL string
+	index   string
+	client  *httpclient.Client
+	userSvc UserSvcInterface
+	changes *ChangeLog
+	logger  logging.Logger
+	// built is set to 1 once the index has been built for the first time
+	built int32
+	// ctx is canceled by Stop, ending any request to the index or ChangeLog poll in progress
+	ctx    context.Context
+	cancel context.CancelFunc
+	doneC  chan struct{}
+}
+
+// userDoc is the document indexed for a user. IndexedAt, in Unix nanoseconds, identifies the documents
+// a rebuild didn't replace, including those left by a previous accountd process.
+type userDoc struct {
+	*domain.User
+	IndexedAt int64 `json:"indexedat"`
+}
+
+// NewElasticsearchUserIndex returns an ElasticsearchUserIndex for the index named 'index' in the cluster
+// at 'baseURL', e.g., 'http://elasticsearch.kube:9200'. Users are read via 'userSvc' as their changes
+// are recorded in 'changes'. 'client', 'userSvc', 'changes', and 'logger' must be non-nil. Start() must
+// be called to build the index.
+func NewElasticsearchUserIndex(baseURL, index string, client *httpclient.Client, userSvc UserSvcInterface, changes *ChangeLog, logger logging.Logger) (*ElasticsearchUserIndex, error) {
+	if len(baseURL) == 0 {
+		return nil, errors.New("non-empty baseURL required")
+	}
+	if len(index) == 0 {
+		return nil, errors.New("non-empty index required")
+	}
+	if client == nil {
+		return nil, errors.New("non-nil *httpclient.Client required")
+	}
+	if userSvc == nil {
+		return nil, errors.New("non-nil UserSvcInterface required")
+	}
+	if changes == nil {
+		return nil, errors.New("non-nil ChangeLog required")
+	}
+	if logger == nil {
+		return nil, errors.New("non-nil Logger required")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ElasticsearchUserIndex{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		index:   index,
+		client:  client,
+		userSvc: userSvc,
+		changes: changes,
+		logger:  logger,
+		ctx:     ctx,
+		cancel:  cancel,
+		doneC:   make(chan struct{}),
+	}, nil
+}
+
+// Start builds the index and then mirrors user changes into it in a separate goroutine
+func (ix *ElasticsearchUserIndex) Start() {
+	go func() {
+		defer close(ix.doneC)
+		ix.run()
+	}()
+}
+
+// Stop stops mirroring user changes, abandoning a change or rebuild in progress
+func (ix *ElasticsearchUserIndex) Stop() {
+	ix.cancel()
+	<-ix.doneC
+}
+
+// run mirrors user changes into the index until it's stopped
+func (ix *ElasticsearchUserIndex) run() {
+	seq, built := ix.rebuild()
+	for ix.ctx.Err() == nil {
+		if !built {
+			ix.pause()
+			seq, built = ix.rebuild()
+			continue
+		}
+
+		changes, err := ix.changes.Since(ix.ctx, seq)
+		if err != nil {
+			ix.logger.Warnf("user changes after %d were discarded before being mirrored to search index %s, rebuilding it", seq, ix.index)
+			seq, built = ix.rebuild()
+			continue
+		}
+		for _, c := range changes.Changes {
+			ix.apply(c)
+		}
+		seq = changes.Next
+
+		// Polls return immediately once the ChangeLog is closed, avoid spinning until stopped
+		if len(changes.Changes) == 0 {
+			ix.pause()
+		}
+	}
+}
+
+// pause waits for idlePollInterval or until the index is stopped
+func (ix *ElasticsearchUserIndex) pause() {
+	select {
+	case <-ix.ctx.Done():
+	case <-time.After(idlePollInterval):
+	}
+}
+
+// rebuild indexes all of the users and then removes the users that weren't indexed, i.e., that have
+// been deleted, from the index. It returns the sequence number of the last change reflected in the
+// index and false if the rebuild failed.
+func (ix *ElasticsearchUserIndex) rebuild() (int64, bool) {
+	// Changes recorded while the index is rebuilt are mirrored once it's complete
+	seq := ix.changes.Latest()
+	start := time.Now().UnixNano()
+
+	afterID := 0
+	for {
+		users, err := ix.userSvc.GetUsersPage(ix.ctx, afterID, searchRebuildPageSize)
+		if err != nil {
+			// Logging done in the service layer
+			return 0, false
+		}
+		if len(users.Users) == 0 {
+			break
+		}
+		if err := ix.bulkIndex(users.Users, start); err != nil {
+			ix.logError("unable to rebuild search index", err)
+			return 0, false
+		}
+		afterID = users.Users[len(users.Users)-1].ID
+	}
+
+	stale, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{"range": map[string]interface{}{"indexedat": map[string]int64{"lt": start}}},
+	})
+	if err != nil {
+		ix.logError("unable to rebuild search index", err)
+		return 0, false
+	}
+	if err := ix.do(ix.ctx, http.MethodPost, "/"+ix.index+"/_delete_by_query?refresh=true&ignore_unavailable=true", "application/json", stale, nil); err != nil {
+		ix.logError("unable to rebuild search index", err)
+		return 0, false
+	}
+
+	atomic.StoreInt32(&ix.built, 1)
+	ix.logger.Infof("search index %s rebuilt as of user change %d", ix.index, seq)
+	return seq, true
+}
+
+// bulkIndex indexes 'users', as of 'indexedAt', with a single request
+func (ix *ElasticsearchUserIndex) bulkIndex(users []*domain.User, indexedAt int64) error {
+	var body bytes.Buffer
+	for _, u := range users {
+		doc, err := json.Marshal(userDoc{User: u, IndexedAt: indexedAt})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&body, "{\"index\":{\"_id\":\"%d\"}}\n%s\n", u.ID, doc)
+	}
+
+	result := struct {
+		Errors bool `json:"errors"`
+	}{}
+	if err := ix.do(ix.ctx, http.MethodPost, "/"+ix.index+"/_bulk", "application/x-ndjson", body.Bytes(), &result); err != nil {
+		return err
+	}
+	if result.Errors {
+		return fmt.Errorf("bulk indexing %d users into %s failed for one or more users", len(users), ix.index)
+	}
+	return nil
+}
+
+// apply mirrors change 'c' into the index
+func (ix *ElasticsearchUserIndex) apply(c *domain.UserChange) {
+	err := ix.mirror(c)
+	if err != nil {
+		SearchIndexUpdates.WithLabelValues("error").Inc()
+		ix.logger.WithFields(logging.Fields{
+			logging.UserID:      c.UserID,
+			logging.ErrorDetail: err.Error(),
+		}).Errorf("unable to mirror user %s to search index", c.Op)
+		return
+	}
+	SearchIndexUpdates.WithLabelValues("ok").Inc()
+}
+
+// mirror indexes the user changed by 'c', or removes it from the index if it was deleted
+func (ix *ElasticsearchUserIndex) mirror(c *domain.UserChange) error {
+	path := fmt.Sprintf("/%s/_doc/%d", ix.index, c.UserID)
+	if c.Op == domain.ChangeDelete {
+		return ix.do(ix.ctx, http.MethodDelete, path, "", nil, nil)
+	}
+
+	u, err := ix.userSvc.GetUser(ix.ctx, c.UserID)
+	if err != nil {
+		if err.ErrCode == mverr.DBNoUserErrorCode {
+			// The user has since been deleted, a later change mirrors the deletion
+			return nil
+		}
+		return err
+	}
+	doc, err2 := json.Marshal(userDoc{User: u, IndexedAt: time.Now().UnixNano()})
+	if err2 != nil {
+		return err2
+	}
+	return ix.do(ix.ctx, http.MethodPut, path, "application/json", doc, nil)
+}
+
+// SearchUsers returns, in order of relevance, up to 'limit' users whose name or email address
+// contains words starting with the words in 'query', the last of which may be incomplete
+func (ix *ElasticsearchUserIndex) SearchUsers(ctx context.Context, query string, limit int) (*domain.Users, error) {
+	if atomic.LoadInt32(&ix.built) == 0 {
+		return nil, fmt.Errorf("search index %s hasn't been built yet", ix.index)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"size": limit,
+		"query": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":  query,
+				"type":   "phrase_prefix",
+				"fields": []string{"name", "email"},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := struct {
+		Hits struct {
+			Hits []struct {
+				Source domain.User `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}{}
+	if err := ix.do(ctx, http.MethodPost, "/"+ix.index+"/_search", "application/json", body, &result); err != nil {
+		return nil, err
+	}
+
+	users := &domain.Users{Users: []*domain.User{}}
+	for i := range result.Hits.Hits {
+		users.Users = append(users.Users, &result.Hits.Hits[i].Source)
+	}
+	return users, nil
+}
+
+// do sends 'body', if any, of type 'contentType' to 'path' and decodes the JSON response into
+// 'result' if it's non-nil. Deleting a document that doesn't exist isn't an error.
+func (ix *ElasticsearchUserIndex) do(ctx context.Context, method, path, contentType string, body []byte, result interface{}) error {
+	url := ix.baseURL + path
+	var req *http.Request
+	var err error
+	if body == nil {
+		req, err = http.NewRequestWithContext(ctx, method, url, nil)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	}
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := ix.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if method == http.MethodDelete && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%s %s: unexpected HTTP status %d", method, url, resp.StatusCode)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("%s %s: %s", method, url, err)
+	}
+	return nil
+}
+
+func (ix *ElasticsearchUserIndex) logError(msg string, err error) {
+	ix.logger.WithFields(logging.Fields{
+		logging.ErrorDetail: err.Error(),
+	}).Error(msg)
+}
@@ -0,0 +1,238 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/internal/db/memory"
+	"github.com/youngkin/mockvideo/internal/domain"
+	"github.com/youngkin/mockvideo/internal/httpclient"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// fakeSearchCluster emulates the parts of the Elasticsearch API used by ElasticsearchUserIndex for
+// the index 'users'. Searches return all of the documents.
+type fakeSearchCluster struct {
+	mu   sync.Mutex
+	docs map[string]map[string]interface{}
+}
+
+func (c *fakeSearchCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/users/_bulk":
+		d := json.NewDecoder(r.Body)
+		for d.More() {
+			action := struct {
+				Index struct {
+					ID string `json:"_id"`
+				} `json:"index"`
+			}{}
+			doc := map[string]interface{}{}
+			if d.Decode(&action) != nil || d.Decode(&doc) != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			c.docs[action.Index.ID] = doc
+		}
+		w.Write([]byte(`{"errors":false}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/users/_delete_by_query":
+		w.Write([]byte(`{"deleted":0}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/users/_search":
+		ids := []string{}
+		for id := range c.docs {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		hits := []map[string]interface{}{}
+		for _, id := range ids {
+			hits = append(hits, map[string]interface{}{"_id": id, "_source": c.docs[id]})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"hits": map[string]interface{}{"hits": hits}})
+	case strings.HasPrefix(r.URL.Path, "/users/_doc/"):
+		id := strings.TrimPrefix(r.URL.Path, "/users/_doc/")
+		if r.Method == http.MethodDelete {
+			if _, ok := c.docs[id]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(c.docs, id)
+			return
+		}
+		doc := map[string]interface{}{}
+		if json.NewDecoder(r.Body).Decode(&doc) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		c.docs[id] = doc
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// doc returns the document indexed for user 'id', if any
+func (c *fakeSearchCluster) doc(id string) (map[string]interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, ok := c.docs[id]
+	return doc, ok
+}
+
+func TestElasticsearchUserIndex(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	repo := memory.NewUserTable()
+	for _, name := range []string{"mickeyd", "davyj"} {
+		if _, err := repo.CreateUser(domain.User{AccountID: 1, Name: name, EMail: name + "@gmail.com", Role: domain.Restricted, Password: "pw"}); err != nil {
+			t.Fatalf("error %s was not expected creating a user", err)
+		}
+	}
+	userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting UserSvc", err)
+	}
+	changes, _ := NewChangeLog(10, 50*time.Millisecond)
+	userSvc.SetChangeLog(changes)
+
+	cluster := &fakeSearchCluster{docs: map[string]map[string]interface{}{}}
+	srv := httptest.NewServer(cluster)
+	defer srv.Close()
+
+	breaker, _ := httpclient.NewBreaker(5, time.Minute)
+	client, _ := httpclient.New("search", time.Second, 0, breaker)
+	index, err := NewElasticsearchUserIndex(srv.URL, DefaultSearchIndex, client, userSvc, changes, logger)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting ElasticsearchUserIndex", err)
+	}
+
+	// Searches fail, so the UserSvc falls back to the DB, until the index has been built
+	if _, err = index.SearchUsers(context.Background(), "mickey", 10); err == nil {
+		t.Errorf("expected an error searching the index before it's built")
+	}
+
+	index.Start()
+	defer index.Stop()
+
+	waitFor(t, "the index to be built", func() bool {
+		_, err := index.SearchUsers(context.Background(), "mickey", 10)
+		return err == nil
+	})
+	users, err := index.SearchUsers(context.Background(), "mickey", 10)
+	if err != nil || len(users.Users) != 2 || users.Users[0].Name != "mickeyd" {
+		t.Fatalf("expected both users from the index, got %+v, error %v", users, err)
+	}
+	if doc, _ := cluster.doc("1"); doc["password"] != nil || doc["indexedat"] == nil {
+		t.Errorf("expected a document without a password, got %+v", doc)
+	}
+
+	u, _ := repo.GetUser(1)
+	u.Name = "micky"
+	u.Password = "pw"
+	if mvErr := userSvc.UpdateUser(context.Background(), *u); mvErr != nil {
+		t.Fatalf("error %s was not expected updating user 1", mvErr)
+	}
+	if mvErr := userSvc.DeleteUser(context.Background(), 2); mvErr != nil {
+		t.Fatalf("error %s was not expected deleting user 2", mvErr)
+	}
+
+	waitFor(t, "the changes to be mirrored", func() bool {
+		doc, _ := cluster.doc("1")
+		_, found := cluster.doc("2")
+		return doc["name"] == "micky" && !found
+	})
+}
+
+// stubSearcher is a UserSearcher returning 'users', or failing with 'err' if it's set
+type stubSearcher struct {
+	users *domain.Users
+	err   error
+}
+
+func (s stubSearcher) SearchUsers(ctx context.Context, query string, limit int) (*domain.Users, error) {
+	return s.users, s.err
+}
+
+func TestSearchUsers(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	indexed := &domain.Users{Users: []*domain.User{{ID: 42, Name: "mickey dolenz"}}}
+
+	tcs := []struct {
+		testName    string
+		searcher    UserSearcher
+		expectedIDs []int
+	}{
+		{
+			testName:    "testNoSearcher",
+			expectedIDs: []int{1},
+		},
+		{
+			testName:    "testSearcher",
+			searcher:    stubSearcher{users: indexed},
+			expectedIDs: []int{42},
+		},
+		{
+			testName:    "testSearcherFails",
+			searcher:    stubSearcher{err: errors.New("circuit breaker open")},
+			expectedIDs: []int{1},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			repo := memory.NewUserTable()
+			repo.CreateUser(domain.User{AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary, Password: "pw"})
+			repo.CreateUser(domain.User{AccountID: 1, Name: "davy jones", EMail: "davyj@gmail.com", Role: domain.Restricted, Password: "pw"})
+			userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			if tc.searcher != nil {
+				if err = userSvc.SetSearcher(tc.searcher); err != nil {
+					t.Fatalf("error %s was not expected setting the UserSearcher", err)
+				}
+			}
+
+			users, mvErr := userSvc.SearchUsers(context.Background(), "mickey", 10)
+			if mvErr != nil {
+				t.Fatalf("error %s was not expected", mvErr)
+			}
+			ids := []int{}
+			for _, u := range users.Users {
+				ids = append(ids, u.ID)
+			}
+			if len(ids) != len(tc.expectedIDs) || ids[0] != tc.expectedIDs[0] {
+				t.Errorf("expected users %v, got %v", tc.expectedIDs, ids)
+			}
+		})
+	}
+}
+
+// waitFor fails the test if 'cond' isn't true within a few seconds. 'what' describes 'cond'.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/clock"
+	"github.com/youngkin/mockvideo/internal/httpclient"
+)
+
+// Overall statuses reported by a StatusBoard
+const (
+	// StatusBoardOK means every service is healthy
+	StatusBoardOK = "ok"
+	// StatusBoardDegraded means some, but not all, services are unhealthy
+	StatusBoardDegraded = "degraded"
+	// StatusBoardDown means every service is unhealthy
+	StatusBoardDown = "down"
+)
+
+// HealthTarget is a service whose health is checked by requesting its health endpoint
+type HealthTarget struct {
+	// Name identifies the service, e.g., 'customerd'
+	Name string
+	// URL is the service's health endpoint, e.g., 'http://customerd.kube/customerdhealth'
+	URL string
+}
+
+// ParseHealthTargets returns the HealthTargets in 'services', a comma separated list of 'name=url'
+// entries, e.g., 'accountd=http://localhost:5000/accountdhealth'. Names must be unique.
+func ParseHealthTargets(services string) ([]HealthTarget, error) {
+	targets := []HealthTarget{}
+	names := make(map[string]bool)
+	for _, entry := range strings.Split(services, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid health target %q, expected 'name=url'", entry)
+		}
+		u, err := url.Parse(parts[1])
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid health target %q, expected an absolute http or https URL", entry)
+		}
+		if names[parts[0]] {
+			return nil, fmt.Errorf("invalid health target %q, there's already a target named %s", entry, parts[0])
+		}
+		names[parts[0]] = true
+		targets = append(targets, HealthTarget{Name: parts[0], URL: parts[1]})
+	}
+	return targets, nil
+}
+
+// ServiceHealth is the result of checking a single service's health
+type ServiceHealth struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	// HTTPStatus is the status of the health endpoint's response, it's omitted if there wasn't one
+	HTTPStatus int    `json:"httpstatus,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Duration   string `json:"duration"`
+}
+
+// StatusBoard is the consolidated health of the services checked by a StatusBoardSvc
+type StatusBoard struct {
+	Status   string          `json:"status"`
+	Checked  time.Time       `json:"checked"`
+	Services []ServiceHealth `json:"services"`
+}
+
+// StatusBoardSvcInterface defines the operations supported by StatusBoardSvc
+type StatusBoardSvcInterface interface {
+	Check(ctx context.Context) StatusBoard
+}
+
+// statusBoardTarget is a HealthTarget and the client used to check it
+type statusBoardTarget struct {
+	HealthTarget
+	client *httpclient.Client
+}
+
+// StatusBoardSvc checks the health of several services concurrently. A service that's slow or
+// unavailable is reported as unhealthy without affecting the others.
+type StatusBoardSvc struct {
+	targets []statusBoardTarget
+	clock   clock.Clock
+}
+
+// NewStatusBoardSvc returns a StatusBoardSvc that checks 'targets'. 'clients' are used to check the
+// target with the same index, each target needs its own client since a client's circuit breaker
+// reflects the health of a single service. The clients' timeouts bound the time a check takes.
+func NewStatusBoardSvc(targets []HealthTarget, clients []*httpclient.Client) (*StatusBoardSvc, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("at least one HealthTarget required")
+	}
+	if len(clients) != len(targets) {
+		return nil, errors.New("a *httpclient.Client is required for each HealthTarget")
+	}
+	s := &StatusBoardSvc{clock: clock.System}
+	for i, t := range targets {
+		if clients[i] == nil {
+			return nil, errors.New("non-nil *httpclient.Client required")
+		}
+		s.targets = append(s.targets, statusBoardTarget{HealthTarget: t, client: clients[i]})
+	}
+	return s, nil
+}
+
+// SetClock replaces the Clock, clock.System by default, used to timestamp StatusBoards. 'c' must be non-nil.
+func (s *StatusBoardSvc) SetClock(c clock.Clock) error {
+	if c == nil {
+		return errors.New("non-nil Clock required")
+	}
+	s.clock = c
+	return nil
+}
+
+// Check checks the health of every target concurrently and returns the consolidated results, in
+// the same order as the targets. A target is healthy if its health endpoint responds with a 2xx status.
+func (s *StatusBoardSvc) Check(ctx context.Context) StatusBoard {
+	results := make([]ServiceHealth, len(s.targets))
+	var wg sync.WaitGroup
+	for i, t := range s.targets {
+		wg.Add(1)
+		go func(i int, t statusBoardTarget) {
+			defer wg.Done()
+			results[i] = t.check(ctx)
+		}(i, t)
+	}
+	wg.Wait()
+
+	healthy := 0
+	for _, r := range results {
+		if r.Healthy {
+			healthy++
+		}
+	}
+	board := StatusBoard{Status: StatusBoardDegraded, Checked: s.clock.Now(), Services: results}
+	switch healthy {
+	case len(results):
+		board.Status = StatusBoardOK
+	case 0:
+		board.Status = StatusBoardDown
+	}
+	return board
+}
+
+// check requests the target's health endpoint
+func (t statusBoardTarget) check(ctx context.Context) ServiceHealth {
+	start := time.Now()
+	h := ServiceHealth{Name: t.Name}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL, nil)
+	if err != nil {
+		h.Error = err.Error()
+		h.Duration = time.Since(start).String()
+		return h
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		h.Error = err.Error()
+		h.Duration = time.Since(start).String()
+		return h
+	}
+	// Drain the body so the connection can be reused
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	h.HTTPStatus = resp.StatusCode
+	h.Healthy = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !h.Healthy {
+		h.Error = fmt.Sprintf("unexpected HTTP status %d", resp.StatusCode)
+	}
+	h.Duration = time.Since(start).String()
+	return h
+}
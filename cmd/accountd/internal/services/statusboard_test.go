@@ -0,0 +1,142 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/clock"
+	"github.com/youngkin/mockvideo/internal/httpclient"
+)
+
+func TestParseHealthTargets(t *testing.T) {
+	tcs := []struct {
+		testName  string
+		services  string
+		expected  []HealthTarget
+		expectErr bool
+	}{
+		{
+			testName: "testTargets",
+			services: "accountd=http://localhost:5000/accountdhealth, customerd=https://customerd.kube/customerdhealth",
+			expected: []HealthTarget{
+				{Name: "accountd", URL: "http://localhost:5000/accountdhealth"},
+				{Name: "customerd", URL: "https://customerd.kube/customerdhealth"},
+			},
+		},
+		{testName: "testNoTargets", services: "", expected: []HealthTarget{}},
+		{testName: "testMissingName", services: "=http://localhost:5000/accountdhealth", expectErr: true},
+		{testName: "testMissingURL", services: "accountd", expectErr: true},
+		{testName: "testRelativeURL", services: "accountd=/accountdhealth", expectErr: true},
+		{testName: "testDuplicateName", services: "accountd=http://a/health,accountd=http://b/health", expectErr: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			targets, err := ParseHealthTargets(tc.services)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error %t, got %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if len(targets) != len(tc.expected) {
+				t.Fatalf("expected %+v, got %+v", tc.expected, targets)
+			}
+			for i := range targets {
+				if targets[i] != tc.expected[i] {
+					t.Errorf("expected %+v, got %+v", tc.expected[i], targets[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStatusBoardCheck(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("I'm Healthy!"))
+	}))
+	defer healthy.Close()
+	unhealthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unhealthy.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+
+	tcs := []struct {
+		testName        string
+		targets         []HealthTarget
+		expectedStatus  string
+		expectedHealthy []bool
+		expectedHTTP    []int
+	}{
+		{
+			testName:        "testOK",
+			targets:         []HealthTarget{{Name: "accountd", URL: healthy.URL}, {Name: "customerd", URL: healthy.URL}},
+			expectedStatus:  StatusBoardOK,
+			expectedHealthy: []bool{true, true},
+			expectedHTTP:    []int{http.StatusOK, http.StatusOK},
+		},
+		{
+			testName:        "testDegraded",
+			targets:         []HealthTarget{{Name: "accountd", URL: healthy.URL}, {Name: "customerd", URL: unhealthy.URL}, {Name: "productd", URL: slow.URL}},
+			expectedStatus:  StatusBoardDegraded,
+			expectedHealthy: []bool{true, false, false},
+			expectedHTTP:    []int{http.StatusOK, http.StatusServiceUnavailable, 0},
+		},
+		{
+			testName:        "testDown",
+			targets:         []HealthTarget{{Name: "customerd", URL: unhealthy.URL}},
+			expectedStatus:  StatusBoardDown,
+			expectedHealthy: []bool{false},
+			expectedHTTP:    []int{http.StatusServiceUnavailable},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			clients := []*httpclient.Client{}
+			for _, target := range tc.targets {
+				breaker, _ := httpclient.NewBreaker(5, time.Minute)
+				client, _ := httpclient.New(target.Name, 100*time.Millisecond, 0, breaker)
+				clients = append(clients, client)
+			}
+			board, err := NewStatusBoardSvc(tc.targets, clients)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting StatusBoardSvc", err)
+			}
+			now := time.Date(2020, 7, 4, 9, 30, 0, 0, time.UTC)
+			if err = board.SetClock(clock.NewFrozen(now)); err != nil {
+				t.Fatalf("error %s was not expected setting the clock", err)
+			}
+
+			sb := board.Check(context.Background())
+			if sb.Status != tc.expectedStatus || !sb.Checked.Equal(now) {
+				t.Errorf("expected status %s checked at %s, got %s at %s", tc.expectedStatus, now, sb.Status, sb.Checked)
+			}
+			if len(sb.Services) != len(tc.targets) {
+				t.Fatalf("expected %d services, got %+v", len(tc.targets), sb.Services)
+			}
+			for i, s := range sb.Services {
+				if s.Name != tc.targets[i].Name || s.Healthy != tc.expectedHealthy[i] || s.HTTPStatus != tc.expectedHTTP[i] {
+					t.Errorf("expected %s healthy %t with status %d, got %+v", tc.targets[i].Name, tc.expectedHealthy[i], tc.expectedHTTP[i], s)
+				}
+				if !s.Healthy && s.Error == "" {
+					t.Errorf("expected an error for unhealthy %s", s.Name)
+				}
+			}
+		})
+	}
+}
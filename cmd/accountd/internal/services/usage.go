@@ -0,0 +1,170 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/internal/clock"
+	"github.com/youngkin/mockvideo/internal/domain"
+	"github.com/youngkin/mockvideo/internal/metrics"
+)
+
+const (
+	// DefaultUsageWindow is the default length of the window that a UsageTracker's window counts cover
+	DefaultUsageWindow = time.Hour
+	// DefaultUsageMaxAccounts is the default number of accounts tracked by a UsageTracker
+	DefaultUsageMaxAccounts = 10000
+)
+
+// UsageTrackedAccounts is the number of accounts whose usage is being tracked. Usage isn't labeled
+// by account since the number of accounts is unbounded.
+var UsageTrackedAccounts = metrics.NewGauge(prometheus.GaugeOpts{
+	Subsystem: "service",
+	Name:      "usage_tracked_accounts",
+	Help:      "number of accounts whose API usage is being tracked",
+})
+
+// UsageEvictions counts the accounts whose usage was discarded to make room for another account
+var UsageEvictions = metrics.NewCounter(prometheus.CounterOpts{
+	Subsystem: "service",
+	Name:      "usage_evictions_total",
+	Help:      "number of accounts whose API usage was discarded because the maximum number of accounts were tracked",
+})
+
+// UsageReporter reports an account's API usage, e.g., to enforce quotas
+type UsageReporter interface {
+	Usage(accountID int) domain.AccountUsage
+}
+
+// UsageTracker counts the API requests made on behalf of each account, and how many of them
+// failed. Counts are only kept in memory, so they're lost when accountd restarts and only cover
+// the requests served by this instance. At most maxAccounts accounts are tracked, when another
+// account makes a request the least recently active account is discarded.
+type UsageTracker struct {
+	window      time.Duration
+	maxAccounts int
+
+	mu       sync.Mutex
+	clock    clock.Clock
+	accounts map[int]*accountUsage
+}
+
+// accountUsage is the usage of a single account
+type accountUsage struct {
+	windowStart time.Time
+	lastSeen    time.Time
+	window      domain.UsageCounts
+	total       domain.UsageCounts
+}
+
+// NewUsageTracker returns a UsageTracker whose window counts are reset every 'window' and that
+// tracks up to 'maxAccounts' accounts. Both must be greater than 0.
+func NewUsageTracker(window time.Duration, maxAccounts int) (*UsageTracker, error) {
+	if window <= 0 {
+		return nil, errors.New("window must be greater than 0")
+	}
+	if maxAccounts < 1 {
+		return nil, errors.New("maxAccounts must be greater than 0")
+	}
+	UsageTrackedAccounts.Set(0)
+	return &UsageTracker{
+		window:      window,
+		maxAccounts: maxAccounts,
+		clock:       clock.System,
+		accounts:    make(map[int]*accountUsage),
+	}, nil
+}
+
+// SetClock replaces the Clock, clock.System by default, used to start windows. 'c' must be non-nil.
+func (ut *UsageTracker) SetClock(c clock.Clock) error {
+	if c == nil {
+		return errors.New("non-nil Clock required")
+	}
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	ut.clock = c
+	return nil
+}
+
+// Record records a request made on behalf of account 'accountID', 'failed' is true if the request failed
+func (ut *UsageTracker) Record(accountID int, failed bool) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	now := ut.clock.Now()
+	au, ok := ut.accounts[accountID]
+	if !ok {
+		if len(ut.accounts) == ut.maxAccounts {
+			ut.evict()
+		}
+		au = &accountUsage{windowStart: now}
+		ut.accounts[accountID] = au
+		UsageTrackedAccounts.Set(float64(len(ut.accounts)))
+	}
+	ut.roll(au, now)
+	au.lastSeen = now
+
+	au.window.Requests++
+	au.total.Requests++
+	if failed {
+		au.window.Errors++
+		au.total.Errors++
+	}
+}
+
+// Usage returns the usage of account 'accountID'. The counts are 0 if the account hasn't made any
+// requests, or its usage was discarded.
+func (ut *UsageTracker) Usage(accountID int) domain.AccountUsage {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	now := ut.clock.Now()
+	au, ok := ut.accounts[accountID]
+	if !ok {
+		return domain.AccountUsage{AccountID: accountID, WindowStart: now}
+	}
+	ut.roll(au, now)
+
+	return domain.AccountUsage{
+		AccountID:   accountID,
+		WindowStart: au.windowStart,
+		Window:      withErrorRate(au.window),
+		Total:       withErrorRate(au.total),
+	}
+}
+
+// roll starts a new window for 'au' if its current window has ended by 'now'
+func (ut *UsageTracker) roll(au *accountUsage, now time.Time) {
+	if now.Sub(au.windowStart) < ut.window {
+		return
+	}
+	au.windowStart = now
+	au.window = domain.UsageCounts{}
+}
+
+// evict discards the usage of the least recently active account
+func (ut *UsageTracker) evict() {
+	evictID := 0
+	var oldest time.Time
+	for id, au := range ut.accounts {
+		if oldest.IsZero() || au.lastSeen.Before(oldest) {
+			evictID, oldest = id, au.lastSeen
+		}
+	}
+	delete(ut.accounts, evictID)
+	UsageEvictions.Inc()
+}
+
+// withErrorRate returns 'c' with its ErrorRate calculated
+func withErrorRate(c domain.UsageCounts) domain.UsageCounts {
+	if c.Requests > 0 {
+		c.ErrorRate = float64(c.Errors) / float64(c.Requests)
+	}
+	return c
+}
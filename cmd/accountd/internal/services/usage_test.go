@@ -0,0 +1,200 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/clock"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// usageRqst is a request recorded by a UsageTracker after advancing its clock by 'after'
+type usageRqst struct {
+	accountID int
+	failed    bool
+	after     time.Duration
+}
+
+func TestUsageTracker(t *testing.T) {
+	start := time.Date(2020, 7, 4, 9, 0, 0, 0, time.UTC)
+
+	tcs := []struct {
+		testName      string
+		maxAccounts   int
+		rqsts         []usageRqst
+		accountID     int
+		expectedStart time.Time
+		expectedWin   domain.UsageCounts
+		expectedTotal domain.UsageCounts
+	}{
+		{
+			testName:      "testUsageCounts",
+			maxAccounts:   10,
+			rqsts:         []usageRqst{{accountID: 1}, {accountID: 1, failed: true}, {accountID: 2}, {accountID: 1}, {accountID: 1}},
+			accountID:     1,
+			expectedStart: start,
+			expectedWin:   domain.UsageCounts{Requests: 4, Errors: 1, ErrorRate: 0.25},
+			expectedTotal: domain.UsageCounts{Requests: 4, Errors: 1, ErrorRate: 0.25},
+		},
+		{
+			testName:      "testUsageNoRequests",
+			maxAccounts:   10,
+			rqsts:         []usageRqst{{accountID: 2}},
+			accountID:     1,
+			expectedStart: start,
+		},
+		{
+			testName:      "testUsageWindowRolls",
+			maxAccounts:   10,
+			rqsts:         []usageRqst{{accountID: 1, failed: true}, {accountID: 1, after: 61 * time.Minute}},
+			accountID:     1,
+			expectedStart: start.Add(61 * time.Minute),
+			expectedWin:   domain.UsageCounts{Requests: 1},
+			expectedTotal: domain.UsageCounts{Requests: 2, Errors: 1, ErrorRate: 0.5},
+		},
+		{
+			testName:      "testUsageLeastRecentlyActiveEvicted",
+			maxAccounts:   2,
+			rqsts:         []usageRqst{{accountID: 1}, {accountID: 2, after: time.Second}, {accountID: 1, after: time.Second}, {accountID: 3, after: time.Second}},
+			accountID:     2,
+			expectedStart: start.Add(3 * time.Second),
+		},
+		{
+			testName:      "testUsageActiveAccountKept",
+			maxAccounts:   2,
+			rqsts:         []usageRqst{{accountID: 1}, {accountID: 2, after: time.Second}, {accountID: 1, after: time.Second}, {accountID: 3, after: time.Second}},
+			accountID:     1,
+			expectedStart: start,
+			expectedWin:   domain.UsageCounts{Requests: 2},
+			expectedTotal: domain.UsageCounts{Requests: 2},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			ut, err := NewUsageTracker(time.Hour, tc.maxAccounts)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting a UsageTracker", err)
+			}
+			c := clock.NewFrozen(start)
+			if err = ut.SetClock(c); err != nil {
+				t.Fatalf("error %s was not expected setting the clock", err)
+			}
+
+			for _, rqst := range tc.rqsts {
+				c.Advance(rqst.after)
+				ut.Record(rqst.accountID, rqst.failed)
+			}
+
+			expected := domain.AccountUsage{AccountID: tc.accountID, WindowStart: tc.expectedStart, Window: tc.expectedWin, Total: tc.expectedTotal}
+			if actual := ut.Usage(tc.accountID); actual != expected {
+				t.Errorf("expected usage %+v, got %+v", expected, actual)
+			}
+		})
+	}
+}
+
+func TestNewUsageTracker(t *testing.T) {
+	tcs := []struct {
+		testName    string
+		window      time.Duration
+		maxAccounts int
+		expectErr   bool
+	}{
+		{testName: "testValid", window: time.Minute, maxAccounts: 1},
+		{testName: "testZeroWindow", window: 0, maxAccounts: 1, expectErr: true},
+		{testName: "testZeroMaxAccounts", window: time.Minute, maxAccounts: 0, expectErr: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			_, err := NewUsageTracker(tc.window, tc.maxAccounts)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("expected error %t, got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestGetUsage(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	tcs := []struct {
+		testName        string
+		accountID       int
+		caller          *auth.Caller
+		usageDisabled   bool
+		expectedErrCode mverr.ErrCode
+	}{
+		{
+			testName:  "testUsageByAccountUser",
+			accountID: 1,
+			caller:    &auth.Caller{UserID: 2, AccountID: 1, Role: domain.Restricted},
+		},
+		{
+			testName:  "testUsageNoCaller",
+			accountID: 1,
+		},
+		{
+			testName:        "testUsageOtherAccountForbidden",
+			accountID:       1,
+			caller:          &auth.Caller{UserID: 3, AccountID: 2, Role: domain.Primary},
+			expectedErrCode: mverr.UserUnauthorizedErrorCode,
+		},
+		{
+			testName:        "testUsageDisabled",
+			accountID:       1,
+			usageDisabled:   true,
+			expectedErrCode: mverr.UsageDisabledErrorCode,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			accountSvc, err := NewAccountSvc(summaryUserSvc{}, nil, logger)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting AccountSvc", err)
+			}
+			if !tc.usageDisabled {
+				ut, err := NewUsageTracker(time.Hour, 10)
+				if err != nil {
+					t.Fatalf("error %s was not expected when getting a UsageTracker", err)
+				}
+				ut.Record(1, true)
+				if err = accountSvc.SetUsageReporter(ut); err != nil {
+					t.Fatalf("error %s was not expected setting the UsageReporter", err)
+				}
+			}
+
+			ctx := context.Background()
+			if tc.caller != nil {
+				ctx = auth.NewContext(ctx, *tc.caller)
+			}
+			usage, err2 := accountSvc.GetUsage(ctx, tc.accountID)
+
+			if tc.expectedErrCode != mverr.NoErrorCode {
+				if err2 == nil || err2.ErrCode != tc.expectedErrCode {
+					t.Fatalf("expected error code %d, got %v", tc.expectedErrCode, err2)
+				}
+				return
+			}
+			if err2 != nil {
+				t.Fatalf("error %s was not expected", err2)
+			}
+			expected := domain.UsageCounts{Requests: 1, Errors: 1, ErrorRate: 1}
+			if usage.AccountID != tc.accountID || usage.Total != expected {
+				t.Errorf("expected account %d with total usage %+v, got %+v", tc.accountID, expected, usage)
+			}
+		})
+	}
+}
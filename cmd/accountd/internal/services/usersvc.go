@@ -5,56 +5,360 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/clock"
 	"github.com/youngkin/mockvideo/internal/domain"
 	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/eventbus"
+	"github.com/youngkin/mockvideo/internal/httpclient"
 	"github.com/youngkin/mockvideo/internal/logging"
 )
 
 // UserSvcInterface defines the operations to be supported by any types that provide
-// the implementations of user related usecases
+// the implementations of user related usecases. 'ctx' carries the identity of the
+// caller, if any, used to authorize the operation (see package 'auth'). Failures are
+// always reported as a *mverr.MVError, never as an error, so the HTTP and gRPC endpoints
+// map them to responses the same way, by ErrCode.
 // TODO: This exactly matches the UserRepository interface. This smells.
 type UserSvcInterface interface {
-	GetUsers() (*domain.Users, *mverr.MVError)
-	GetUser(id int) (*domain.User, *mverr.MVError)
-	CreateUser(user domain.User) (id int, err *mverr.MVError)
-	CreateUsers(users domain.Users) (bulkResponse *BulkResponse, err *mverr.MVError)
-	UpdateUser(user domain.User) *mverr.MVError
-	UpdateUsers(users domain.Users) (bulkResponse *BulkResponse, err *mverr.MVError)
-	DeleteUser(id int) *mverr.MVError
+	GetUsers(ctx context.Context, qt domain.QueryTimeout) (*domain.Users, *mverr.MVError)
+	GetUsersPage(ctx context.Context, afterID, limit int) (*domain.Users, *mverr.MVError)
+	SearchUsers(ctx context.Context, query string, limit int) (*domain.Users, *mverr.MVError)
+	GetInactiveUsers(ctx context.Context, since time.Time) (*domain.Users, *mverr.MVError)
+	GetUsersVersion(ctx context.Context) (*domain.UsersVersion, *mverr.MVError)
+	GetChanges(ctx context.Context, since *int64) (*domain.UserChanges, *mverr.MVError)
+	GetUser(ctx context.Context, id int) (*domain.User, *mverr.MVError)
+	CreateUser(ctx context.Context, user domain.User) (id int, err *mverr.MVError)
+	CreateUsers(ctx context.Context, users domain.Users) (bulkResponse *BulkResponse, err *mverr.MVError)
+	UpdateUser(ctx context.Context, user domain.User) *mverr.MVError
+	UpdateUsers(ctx context.Context, users domain.Users) (bulkResponse *BulkResponse, err *mverr.MVError)
+	UpsertUser(ctx context.Context, user domain.User) (id int, outcome domain.UpsertOutcome, err *mverr.MVError)
+	UpsertUsers(ctx context.Context, users domain.Users) (bulkResponse *BulkResponse, err *mverr.MVError)
+	ValidateUsers(ctx context.Context, users domain.Users, rqstType RqstType) (bulkResponse *BulkResponse, err *mverr.MVError)
+	DeleteUser(ctx context.Context, id int) *mverr.MVError
+	DeleteUserWithSuccessor(ctx context.Context, id, successorID int) *mverr.MVError
+	DeleteUsers(ctx context.Context, ids []int) (bulkResponse *BulkResponse, err *mverr.MVError)
+	EnqueueUser(ctx context.Context, user domain.User) (queueID int, err *mverr.MVError)
+	GetQueuedUser(ctx context.Context, queueID int) (*domain.QueuedUser, *mverr.MVError)
+	ApplyQueuedUser(ctx context.Context, qu *domain.QueuedUser) (userID int, err *mverr.MVError)
+	ActivateUser(ctx context.Context, id int, token string) *mverr.MVError
+	RecordLogin(ctx context.Context, id int) *mverr.MVError
+	UpdateRoles(ctx context.Context, accountID int, roles map[int]domain.Role) *mverr.MVError
+	Signup(ctx context.Context, signup domain.Signup) (accountID, userID int, err *mverr.MVError)
+	Login(ctx context.Context, creds domain.Credentials) (*domain.Session, *mverr.MVError)
 }
 
 // UserSvc provides the capability needed to interact with application
 // usecases related to users
 type UserSvc struct {
+	authorizer
 	repo       domain.UserRepository
-	logger     *log.Entry
+	logger     logging.Logger
 	maxBulkOps int
+	// readPool and writePool isolate read and write workloads from each other
+	readPool  *Bulkhead
+	writePool *Bulkhead
+	// bulkValidation decides whether a bulk request with invalid users is processed, see preflight
+	bulkValidation BulkValidation
+	// queue is only set when write-behind mode is enabled
+	queue domain.UserQueueRepository
+	// accounts is only set when signups are enabled
+	accounts domain.AccountRepository
+	// deadLetters is only set when dead letters are enabled
+	deadLetters domain.DeadLetterRepository
+	// mailer sends new users their activation token, they must activate their account within activationTTL
+	mailer        Mailer
+	activationTTL time.Duration
+	// clock provides the time new users' activation period starts at
+	clock clock.Clock
+	// passwordCost is the bcrypt cost users' passwords are hashed with before they're stored
+	passwordCost int
+	// sessions is only set when logins are enabled, it issues the session tokens, valid for sessionTTL,
+	// of users that log in. decoyHash is checked when there's no user to check a password against.
+	sessions   *auth.JWTKeys
+	sessionTTL time.Duration
+	decoyHash  string
+	// uow, if set, is used to apply multi-step operations atomically
+	uow domain.UnitOfWork
+	// changes records changes to the users returned by GetUsers, i.e., active users
+	changes *ChangeLog
+	// searcher is only set when a search index is configured, otherwise the DB is searched
+	searcher UserSearcher
+	// impersonations is only set when impersonation is enabled, a deleted user's tokens are revoked
+	impersonations *auth.Impersonations
+	// events, if set, is the bus user lifecycle events are published to
+	events *eventbus.Bus
+	// uowEvents is only set in the copy of the UserSvc used within a UnitOfWork, see inUnitOfWork
+	uowEvents *[]queuedEvent
 }
 
 // NewUserSvc returns a new instance that handles application usecases related to users.
-// 'ur' and 'logger' must be non-nil. 'maxBulkOps' must be greater than 0.
-func NewUserSvc(ur domain.UserRepository, logger *log.Entry, maxBulkOps int) (*UserSvc, error) {
+// 'ur' and 'logger' must be non-nil. 'maxBulkOps' must be greater than 0. 'maxReads' and
+// 'maxWrites' limit the number of concurrent read and write requests respectively. Both
+// must be greater than 0.
+func NewUserSvc(ur domain.UserRepository, logger logging.Logger, maxBulkOps, maxReads, maxWrites int) (*UserSvc, error) {
 	if ur == nil {
 		return nil, errors.New("non-nil *domain.UserRepository required")
 	}
 	if logger == nil {
-		return nil, errors.New("non-nil *log.Entry required")
+		return nil, errors.New("non-nil Logger required")
 	}
 	if maxBulkOps < 1 {
 		return nil, errors.New("maxBulkOps must be greater than 0")
 	}
-	return &UserSvc{repo: ur, logger: logger, maxBulkOps: maxBulkOps}, nil
+	if maxReads < 1 {
+		return nil, errors.New("maxReads must be greater than 0")
+	}
+	if maxWrites < 1 {
+		return nil, errors.New("maxWrites must be greater than 0")
+	}
+	mailer, err := NewLogMailer(logger)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := NewChangeLog(DefaultChangeLogSize, DefaultChangesWait)
+	if err != nil {
+		return nil, err
+	}
+	return &UserSvc{
+		repo:          ur,
+		logger:        logger,
+		maxBulkOps:    maxBulkOps,
+		readPool:      NewBulkhead(ReadPool, maxReads),
+		writePool:     NewBulkhead(WritePool, maxWrites),
+		mailer:        mailer,
+		activationTTL: DefaultActivationTTL,
+		clock:         clock.System,
+		passwordCost:  auth.DefaultPasswordCost,
+		changes:       changes,
+	}, nil
 }
 
-// GetUsers retrieves all Users from the database
-func (us *UserSvc) GetUsers() (*domain.Users, *mverr.MVError) {
-	users, err := us.repo.GetUsers()
+// SetClock replaces the Clock, clock.System by default, used to determine when a new user's
+// activation period expires. 'c' must be non-nil.
+func (us *UserSvc) SetClock(c clock.Clock) error {
+	if c == nil {
+		return errors.New("non-nil Clock required")
+	}
+	us.clock = c
+	return nil
+}
 
+// SetPasswordCost replaces the bcrypt cost, auth.DefaultPasswordCost by default, users' passwords are
+// hashed with. 'cost' must be between auth.MinPasswordCost and auth.MaxPasswordCost. Passwords that are
+// already stored keep the cost they were hashed with.
+func (us *UserSvc) SetPasswordCost(cost int) error {
+	if cost < auth.MinPasswordCost || cost > auth.MaxPasswordCost {
+		return fmt.Errorf("cost must be between %d and %d", auth.MinPasswordCost, auth.MaxPasswordCost)
+	}
+	us.passwordCost = cost
+	return nil
+}
+
+// SetBulkValidation sets what happens to a bulk request when some of its users are invalid,
+// ContinueOnError by default
+func (us *UserSvc) SetBulkValidation(v BulkValidation) error {
+	if _, ok := BulkValidationName[v]; !ok {
+		return fmt.Errorf("unknown BulkValidation %d", v)
+	}
+	us.bulkValidation = v
+	return nil
+}
+
+// ConfigureActivation replaces the default Mailer (a LogMailer) and activation period
+// (DefaultActivationTTL) used for new users. 'mailer' must be non-nil and 'ttl' must be
+// greater than 0.
+func (us *UserSvc) ConfigureActivation(mailer Mailer, ttl time.Duration) error {
+	if mailer == nil {
+		return errors.New("non-nil Mailer required")
+	}
+	if ttl <= 0 {
+		return errors.New("ttl must be greater than 0")
+	}
+	us.mailer = mailer
+	us.activationTTL = ttl
+	return nil
+}
+
+// SetChangeLog replaces the ChangeLog, one retaining DefaultChangeLogSize changes by default,
+// that user changes are recorded in. 'cl' must be non-nil.
+func (us *UserSvc) SetChangeLog(cl *ChangeLog) error {
+	if cl == nil {
+		return errors.New("non-nil ChangeLog required")
+	}
+	us.changes = cl
+	return nil
+}
+
+// ShareBulkheads replaces the read and write bulkheads with those of 'other' so the requests to
+// both UserSvcs, e.g., a canary's and the primary's, count against the same limits. 'other' must
+// be non-nil.
+func (us *UserSvc) ShareBulkheads(other *UserSvc) error {
+	if other == nil {
+		return errors.New("non-nil UserSvc required")
+	}
+	us.readPool = other.readPool
+	us.writePool = other.writePool
+	return nil
+}
+
+// SetSearcher sets the UserSearcher used by SearchUsers instead of the DB. 'searcher' must be non-nil.
+func (us *UserSvc) SetSearcher(searcher UserSearcher) error {
+	if searcher == nil {
+		return errors.New("non-nil UserSearcher required")
+	}
+	us.searcher = searcher
+	return nil
+}
+
+// SetImpersonations sets the Impersonations whose tokens for a user are revoked when the user is
+// deleted. 'im' must be non-nil.
+func (us *UserSvc) SetImpersonations(im *auth.Impersonations) error {
+	if im == nil {
+		return errors.New("non-nil *auth.Impersonations required")
+	}
+	us.impersonations = im
+	return nil
+}
+
+// SetEventBus sets the bus user lifecycle events, e.g., UserCreated, are published to. 'bus' must be non-nil.
+func (us *UserSvc) SetEventBus(bus *eventbus.Bus) error {
+	if bus == nil {
+		return errors.New("non-nil *eventbus.Bus required")
+	}
+	us.events = bus
+	return nil
+}
+
+// SetUnitOfWork sets the UnitOfWork used to apply multi-step operations, e.g., ApplyQueuedUser,
+// atomically. 'uow' must be non-nil. Without a UnitOfWork each step is applied on its own.
+func (us *UserSvc) SetUnitOfWork(uow domain.UnitOfWork) error {
+	if uow == nil {
+		return errors.New("non-nil domain.UnitOfWork required")
+	}
+	us.uow = uow
+	return nil
+}
+
+// inUnitOfWork calls 'fn' with a copy of the UserSvc whose repositories are bound to a single
+// UnitOfWork. If there isn't a UnitOfWork 'fn' is called with the UserSvc itself. Events raised
+// by 'fn' are only published if the UnitOfWork commits.
+func (us *UserSvc) inUnitOfWork(fn func(svc *UserSvc) *mverr.MVError) *mverr.MVError {
+	if us.uow == nil {
+		return fn(us)
+	}
+	events := []queuedEvent{}
+	err := us.uow.Do(func(repos domain.Repositories) *mverr.MVError {
+		events = events[:0]
+		svc := *us
+		svc.uowEvents = &events
+		svc.repo = repos.Users
+		if repos.UserQueue != nil {
+			svc.queue = repos.UserQueue
+		}
+		if repos.Accounts != nil {
+			svc.accounts = repos.Accounts
+		}
+		return fn(&svc)
+	})
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		us.publish(e.topic, e.event)
+	}
+	return nil
+}
+
+// users returns the UserRepository bound to 'ctx', so its operations are abandoned once the request
+// is, e.g., when a gRPC client's deadline passes. Repositories that don't support binding, see
+// domain.ContextualUserRepository, are returned as is.
+func (us *UserSvc) users(ctx context.Context) domain.UserRepository {
+	if cr, ok := us.repo.(domain.ContextualUserRepository); ok {
+		return cr.WithContext(ctx)
+	}
+	return us.repo
+}
+
+// GetUsers retrieves all Users from the database. The query is bounded by 'qt', each endpoint
+// has its own QueryTimeout. If the query times out the Users are truncated if 'qt.Partial',
+// otherwise a QueryTimeoutErrorCode error is returned.
+func (us *UserSvc) GetUsers(ctx context.Context, qt domain.QueryTimeout) (*domain.Users, *mverr.MVError) {
+	us.readPool.Acquire()
+	defer us.readPool.Release()
+
+	var users *domain.Users
+	var err *mverr.MVError
+	if qt.Timeout > 0 {
+		users, err = us.users(ctx).GetUsersWithin(qt)
+	} else {
+		users, err = us.users(ctx).GetUsers()
+	}
+
+	if err != nil {
+		us.logUserError(err)
+		return nil, err
+	}
+	if users.Truncated {
+		us.logger.Warnf("GetUsers query timed out after %s, returning the %d users read", qt.Timeout, len(users.Users))
+	}
+
+	return users, nil
+}
+
+// GetUsersPage retrieves, ordered by ID, up to 'limit' of the Users with an ID greater than 'afterID'
+// from the database
+func (us *UserSvc) GetUsersPage(ctx context.Context, afterID, limit int) (*domain.Users, *mverr.MVError) {
+	us.readPool.Acquire()
+	defer us.readPool.Release()
+
+	users, err := us.users(ctx).GetUsersPage(afterID, limit)
+	if err != nil {
+		us.logUserError(err)
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// SearchUsers retrieves up to 'limit' of the Users whose name or email address matches 'query'. The
+// search index is used if there is one, the DB is searched if there isn't or the search index fails.
+// Users found in the DB are ordered by ID, those found in the search index by relevance.
+func (us *UserSvc) SearchUsers(ctx context.Context, query string, limit int) (*domain.Users, *mverr.MVError) {
+	us.readPool.Acquire()
+	defer us.readPool.Release()
+
+	if us.searcher != nil {
+		users, err := us.searcher.SearchUsers(ctx, query, limit)
+		if err == nil {
+			return users, nil
+		}
+		us.logger.Warnf("search index unavailable, searching the DB instead: %s", err)
+	}
+
+	users, err := us.users(ctx).SearchUsers(query, limit)
+	if err != nil {
+		us.logUserError(err)
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetInactiveUsers retrieves, ordered by ID, the Users that haven't logged in since 'since' from
+// the database. Users that have never logged in are included if they were created before 'since'.
+func (us *UserSvc) GetInactiveUsers(ctx context.Context, since time.Time) (*domain.Users, *mverr.MVError) {
+	us.readPool.Acquire()
+	defer us.readPool.Release()
+
+	users, err := us.users(ctx).GetInactiveUsers(since)
 	if err != nil {
 		us.logUserError(err)
 		return nil, err
@@ -63,9 +367,43 @@ func (us *UserSvc) GetUsers() (*domain.Users, *mverr.MVError) {
 	return users, nil
 }
 
+// GetUsersVersion retrieves the version of the users returned by GetUsers from the database
+func (us *UserSvc) GetUsersVersion(ctx context.Context) (*domain.UsersVersion, *mverr.MVError) {
+	us.readPool.Acquire()
+	defer us.readPool.Release()
+
+	v, err := us.users(ctx).UsersVersion()
+	if err != nil {
+		us.logUserError(err)
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// GetChanges returns the changes to the users after sequence number 'since', waiting for a
+// change if there aren't any, see ChangeLog.Since. If 'since' is nil no changes are returned,
+// only the sequence number of the latest change to poll from. Users are only included in the
+// changes once they're activated, expired pending users aren't included.
+func (us *UserSvc) GetChanges(ctx context.Context, since *int64) (*domain.UserChanges, *mverr.MVError) {
+	if since == nil {
+		return &domain.UserChanges{Changes: []*domain.UserChange{}, Next: us.changes.Latest()}, nil
+	}
+
+	changes, err := us.changes.Since(ctx, *since)
+	if err != nil {
+		us.logUserError(err)
+		return nil, err
+	}
+	return changes, nil
+}
+
 // GetUser retrieves a user from the database
-func (us *UserSvc) GetUser(id int) (*domain.User, *mverr.MVError) {
-	u, err := us.repo.GetUser(id)
+func (us *UserSvc) GetUser(ctx context.Context, id int) (*domain.User, *mverr.MVError) {
+	us.readPool.Acquire()
+	defer us.readPool.Release()
+
+	u, err := us.users(ctx).GetUser(id)
 
 	if err != nil {
 		us.logUserError(err)
@@ -84,24 +422,78 @@ func (us *UserSvc) GetUser(id int) (*domain.User, *mverr.MVError) {
 	return u, nil
 }
 
-// CreateUser inserts a new User into the database
-func (us *UserSvc) CreateUser(u domain.User) (id int, err *mverr.MVError) {
-	id, err = us.repo.CreateUser(u)
+// CreateUser inserts a new, pending, User into the database and sends the user the
+// token needed to activate their account. Only a primary user of the new user's account
+// is authorized to create the user. The user's password is stored as a hash, see hashPassword.
+func (us *UserSvc) CreateUser(ctx context.Context, u domain.User) (id int, err *mverr.MVError) {
+	return us.createUser(ctx, u, false)
+}
+
+// createUser implements CreateUser. 'u.Password' is stored as is if 'passwordHashed', e.g., it was
+// hashed when the user was queued, see ApplyQueuedUser.
+func (us *UserSvc) createUser(ctx context.Context, u domain.User, passwordHashed bool) (id int, err *mverr.MVError) {
+	if err = us.authorize(ctx, CREATE, u.AccountID); err != nil {
+		us.logUserError(err)
+		return 0, err
+	}
+	if !passwordHashed {
+		if u.Password, err = us.hashPassword(u.Password); err != nil {
+			us.logUserError(err)
+			return 0, err
+		}
+	}
+
+	token, err2 := newActivationToken()
+	if err2 != nil {
+		err = &mverr.MVError{
+			ErrCode:    mverr.UnknownErrorCode,
+			ErrMsg:     mverr.UnknownErrorMsg,
+			ErrDetail:  "unable to generate activation token",
+			WrappedErr: err2,
+		}
+		us.logUserError(err)
+		return 0, err
+	}
+	u.Status = domain.Pending
+	u.ActivationToken = token
+	u.ActivationExpiry = us.clock.Now().Add(us.activationTTL)
+	u.LastModifiedBy = modifiedBy(ctx)
+
+	us.writePool.Acquire()
+	id, err = us.users(ctx).CreateUser(u)
+	us.writePool.Release()
 	if err != nil {
 		us.logUserError(err)
 		return 0, err
 	}
 
-	return id, err
+	u.ID = id
+	us.publish(UserCreated, UserEvent{UserID: id, At: us.clock.Now()})
+	if err2 := us.mailer.SendActivation(ctx, u, token); err2 != nil {
+		// The user was created, but won't be able to activate their account. It will
+		// be deleted when the activation token expires.
+		us.logUserError(&mverr.MVError{
+			ErrCode:    mverr.UnknownErrorCode,
+			ErrMsg:     mverr.UnknownErrorMsg,
+			ErrDetail:  fmt.Sprintf("unable to send activation email to user %d", id),
+			WrappedErr: err2,
+		})
+	}
+
+	return id, nil
 }
 
-// CreateUsers inserts a group new Users into the database
-func (us *UserSvc) CreateUsers(users domain.Users) (bulkResponse *BulkResponse, err *mverr.MVError) {
-	responses := us.handleRqstMultipleUsers(time.Now(), users, CREATE)
+// CreateUsers inserts a group new Users into the database. The users are validated before any are
+// created, see preflight. Users in the group that share an email address, ignoring case, are all
+// rejected with a BulkDuplicateEmailErrorCode error. Otherwise which of them was created would
+// depend on the order the concurrent creations reached the database. The results are in the same
+// order as 'users'.
+func (us *UserSvc) CreateUsers(ctx context.Context, users domain.Users) (bulkResponse *BulkResponse, err *mverr.MVError) {
+	responses := us.handleBulkRqst(ctx, users, CREATE)
 
 	for _, result := range responses.Results {
-		if result.ErrReason != mverr.NoErrorCode {
-			us.logger.WithFields(log.Fields{
+		if result.Failed() {
+			us.logger.WithFields(logging.Fields{
 				logging.ErrorCode:   result.ErrReason,
 				logging.Status:      result.Status,
 				logging.ErrorDetail: fmt.Sprintf("error creating user: Name: %s, email: %s", result.User.Name, result.User.EMail),
@@ -125,24 +517,135 @@ func (us *UserSvc) CreateUsers(users domain.Users) (bulkResponse *BulkResponse,
 	return responses, nil
 }
 
-// UpdateUser updates an existing user in the database
-func (us *UserSvc) UpdateUser(user domain.User) *mverr.MVError {
-	err := us.repo.UpdateUser(user)
+// partitionDuplicateEmails separates the users whose email address, ignoring case, isn't shared
+// with any other user in 'users' from those whose email address is
+func partitionDuplicateEmails(users domain.Users) (unique domain.Users, duplicates []*domain.User) {
+	counts := make(map[string]int, len(users.Users))
+	for _, u := range users.Users {
+		counts[strings.ToLower(u.EMail)]++
+	}
+	for _, u := range users.Users {
+		if counts[strings.ToLower(u.EMail)] > 1 {
+			duplicates = append(duplicates, u)
+			continue
+		}
+		unique.Users = append(unique.Users, u)
+	}
+	return unique, duplicates
+}
+
+// handleBulkRqst validates 'users', see preflight, and then creates ('rqstType' CREATE), updates
+// (UPDATE), or upserts (UPSERT) the valid users. The results are in the same order as 'users'. If
+// FailFast is set and any of the users are invalid nothing is written, the results are only the
+// invalid users' and the overall status is StatusBadRequest.
+func (us *UserSvc) handleBulkRqst(ctx context.Context, users domain.Users, rqstType RqstType) *BulkResponse {
+	valid, items, invalid := us.preflight(users, rqstType)
+	if len(invalid) > 0 && us.bulkValidation == FailFast {
+		return &BulkResponse{OverallStatus: StatusBadRequest, Results: invalid}
+	}
+
+	responses := us.handleRqstMultipleUsers(ctx, time.Now(), valid, items, rqstType)
+	if len(invalid) > 0 {
+		responses.Results = append(responses.Results, invalid...)
+		responses.OverallStatus = StatusConflict
+	}
+	sort.Slice(responses.Results, func(i, j int) bool {
+		return responses.Results[i].Item < responses.Results[j].Item
+	})
+	return responses
+}
+
+// preflight checks each of 'users' for errors that don't require the database, i.e., that the user
+// is valid, see domain.User.ValidateUser, and for creates and upserts that its email address isn't
+// shared with another user in 'users'. It returns the valid users along with their indexes in
+// 'users', 'items', and a result for each invalid user. An invalid user's ErrMsg is prefixed with its
+// index, e.g., "item 3: ...".
+func (us *UserSvc) preflight(users domain.Users, rqstType RqstType) (valid domain.Users, items []int, invalid []Response) {
+	shared := make(map[*domain.User]bool)
+	if rqstType == CREATE || rqstType == UPSERT {
+		_, duplicates := partitionDuplicateEmails(users)
+		for _, u := range duplicates {
+			shared[u] = true
+		}
+	}
+
+	for i, u := range users.Users {
+		r := Response{Item: i, Status: StatusBadRequest, User: withoutPassword(*u)}
+		if err := u.ValidateUser(); err != nil {
+			r.ErrMsg = fmt.Sprintf("item %d: %s", i, err)
+			r.ErrReason = mverr.UserValidationErrorCode
+			invalid = append(invalid, r)
+			continue
+		}
+		if shared[u] {
+			r.ErrMsg = fmt.Sprintf("item %d: %s", i, mverr.BulkDuplicateEmailErrorMsg)
+			r.ErrReason = mverr.BulkDuplicateEmailErrorCode
+			invalid = append(invalid, r)
+			continue
+		}
+		valid.Users = append(valid.Users, u)
+		items = append(items, i)
+	}
+	return valid, items, invalid
+}
+
+// UpdateUser updates an existing user in the database. Only a primary user of the user's
+// account, or the user themselves, is authorized to update the user. Users can't change
+// their own role or account. An update never creates a user, a DBNoUserErrorCode error is
+// returned if the user doesn't exist. The user's new password is stored as a hash, see hashPassword.
+func (us *UserSvc) UpdateUser(ctx context.Context, user domain.User) *mverr.MVError {
+	us.writePool.Acquire()
+	defer us.writePool.Release()
+
+	identified, err := us.identified(ctx, UPDATE)
+	if err != nil {
+		us.logUserError(err)
+		return err
+	}
+	if identified {
+		existing, err := us.users(ctx).GetUser(user.ID)
+		if err != nil {
+			us.logUserError(err)
+			return err
+		}
+		if err = us.authorizeUpdate(ctx, existing, user); err != nil {
+			us.logUserError(err)
+			return err
+		}
+		if existing == nil {
+			err = &mverr.MVError{
+				ErrCode:   mverr.DBNoUserErrorCode,
+				ErrMsg:    mverr.DBNoUserErrorMsg,
+				ErrDetail: fmt.Sprintf("error, attempting to update non-existent user, user.ID %d", user.ID),
+			}
+			us.logUserError(err)
+			return err
+		}
+	}
+	if user.Password, err = us.hashPassword(user.Password); err != nil {
+		us.logUserError(err)
+		return err
+	}
+
+	user.LastModifiedBy = modifiedBy(ctx)
+	err = us.users(ctx).UpdateUser(user)
 	if err != nil {
 		us.logUserError(err)
 		return err
 	}
 
+	us.recordChange(domain.ChangeUpdate, UserUpdated, user.ID)
 	return nil
 }
 
-// UpdateUsers updates a group existing Users in the database
-func (us *UserSvc) UpdateUsers(users domain.Users) (bulkResponse *BulkResponse, err *mverr.MVError) {
-	responses := us.handleRqstMultipleUsers(time.Now(), users, UPDATE)
+// UpdateUsers updates a group existing Users in the database. The users are validated before any
+// are updated, see preflight. The results are in the same order as 'users'.
+func (us *UserSvc) UpdateUsers(ctx context.Context, users domain.Users) (bulkResponse *BulkResponse, err *mverr.MVError) {
+	responses := us.handleBulkRqst(ctx, users, UPDATE)
 
 	for _, result := range responses.Results {
-		if result.ErrReason != mverr.NoErrorCode {
-			us.logger.WithFields(log.Fields{
+		if result.Failed() {
+			us.logger.WithFields(logging.Fields{
 				logging.ErrorCode:   result.ErrReason,
 				logging.Status:      result.Status,
 				logging.ErrorDetail: fmt.Sprintf("error updating user: Name: %s, email: %s", result.User.Name, result.User.EMail),
@@ -166,19 +669,636 @@ func (us *UserSvc) UpdateUsers(users domain.Users) (bulkResponse *BulkResponse,
 	return responses, nil
 }
 
-// DeleteUser deletes an existing user from the database
-func (us *UserSvc) DeleteUser(id int) *mverr.MVError {
-	err := us.repo.DeleteUser(id)
+// UpsertUser creates 'u', as CreateUser does, or updates the name, role, and password of the existing
+// user in the same account with the same email address. The returned outcome reports which, or that
+// the existing user already matched 'u'. Only a primary user of the user's account is authorized to
+// upsert the user. An existing user is never moved to another account. The password is stored as a
+// hash, see upsertPassword.
+func (us *UserSvc) UpsertUser(ctx context.Context, u domain.User) (id int, outcome domain.UpsertOutcome, err *mverr.MVError) {
+	if err = us.authorize(ctx, UPSERT, u.AccountID); err != nil {
+		us.logUserError(err)
+		return 0, "", err
+	}
+	if u.Password, err = us.upsertPassword(ctx, u); err != nil {
+		us.logUserError(err)
+		return 0, "", err
+	}
+
+	token, err2 := newActivationToken()
+	if err2 != nil {
+		err = &mverr.MVError{
+			ErrCode:    mverr.UnknownErrorCode,
+			ErrMsg:     mverr.UnknownErrorMsg,
+			ErrDetail:  "unable to generate activation token",
+			WrappedErr: err2,
+		}
+		us.logUserError(err)
+		return 0, "", err
+	}
+	u.Status = domain.Pending
+	u.ActivationToken = token
+	u.ActivationExpiry = us.clock.Now().Add(us.activationTTL)
+	u.LastModifiedBy = modifiedBy(ctx)
+
+	us.writePool.Acquire()
+	id, outcome, err = us.users(ctx).UpsertUser(u)
+	us.writePool.Release()
+	if err != nil {
+		us.logUserError(err)
+		return 0, "", err
+	}
+
+	u.ID = id
+	switch outcome {
+	case domain.UpsertCreated:
+		us.publish(UserCreated, UserEvent{UserID: id, At: us.clock.Now()})
+		if err2 := us.mailer.SendActivation(ctx, u, token); err2 != nil {
+			us.logUserError(&mverr.MVError{
+				ErrCode:    mverr.UnknownErrorCode,
+				ErrMsg:     mverr.UnknownErrorMsg,
+				ErrDetail:  fmt.Sprintf("unable to send activation email to user %d", id),
+				WrappedErr: err2,
+			})
+		}
+	case domain.UpsertUpdated:
+		us.recordChange(domain.ChangeUpdate, UserUpdated, id)
+	}
+
+	return id, outcome, nil
+}
+
+// UpsertUsers upserts a group of Users, see UpsertUser, e.g., to import users that may already exist.
+// Each result reports the user's outcome and the BulkResponse's Summary counts them. As with
+// CreateUsers, the users are validated first and users in the group that share an email address,
+// ignoring case, are all rejected with a BulkDuplicateEmailErrorCode error.
+func (us *UserSvc) UpsertUsers(ctx context.Context, users domain.Users) (bulkResponse *BulkResponse, err *mverr.MVError) {
+	responses := us.handleBulkRqst(ctx, users, UPSERT)
+	responses.Summary = summarize(responses.Results)
+
+	for _, result := range responses.Results {
+		if result.Failed() {
+			us.logger.WithFields(logging.Fields{
+				logging.ErrorCode:   result.ErrReason,
+				logging.Status:      result.Status,
+				logging.ErrorDetail: fmt.Sprintf("error upserting user: Name: %s, email: %s", result.User.Name, result.User.EMail),
+			}).Errorf(result.ErrMsg)
+		}
+	}
+
+	if responses.OverallStatus != StatusOK {
+		err = &mverr.MVError{
+			ErrCode: mverr.BulkRequestErrorCode,
+			ErrMsg:  mverr.BulkRequestErrorMsg,
+			WrappedErr: fmt.Errorf("part or all of a bulk upsert request failed, overall request status %s",
+				StatusTypeName[responses.OverallStatus]),
+		}
+		us.logUserError(err)
+		return responses, err
+	}
+
+	us.logger.Debugf("UpsertUsers, BulkResponse: %+v", responses)
+
+	return responses, nil
+}
+
+// ValidateUsers validates a bulk create ('rqstType' CREATE) or update (UPDATE) request without
+// writing anything, i.e., it's a dry run of CreateUsers or UpdateUsers. Each user is authorized
+// and validated, and checked for email addresses that are shared with another user in 'users',
+// for creates, or already in use, as well as, for updates, that the user exists. Users that
+// would be created or updated have a StatusOK result. The results are in the same order as
+// 'users' and the BulkResponse is marked as a DryRun.
+func (us *UserSvc) ValidateUsers(ctx context.Context, users domain.Users, rqstType RqstType) (bulkResponse *BulkResponse, err *mverr.MVError) {
+	if rqstType != CREATE && rqstType != UPDATE {
+		err = &mverr.MVError{
+			ErrCode:   mverr.BulkRequestErrorCode,
+			ErrMsg:    mverr.BulkRequestErrorMsg,
+			ErrDetail: fmt.Sprintf("Bulk RequestType %s can't be validated", RqstTypeName[rqstType]),
+		}
+		us.logUserError(err)
+		return &BulkResponse{OverallStatus: StatusBadRequest, DryRun: true}, err
+	}
+
+	shared := make(map[*domain.User]bool)
+	if rqstType == CREATE {
+		_, duplicates := partitionDuplicateEmails(users)
+		for _, u := range duplicates {
+			shared[u] = true
+		}
+	}
+
+	responses := &BulkResponse{OverallStatus: StatusOK, DryRun: true}
+	for i, u := range users.Users {
+		r := Response{Item: i, Status: StatusOK, User: withoutPassword(*u)}
+		var vErr *mverr.MVError
+		if shared[u] {
+			vErr = &mverr.MVError{ErrCode: mverr.BulkDuplicateEmailErrorCode, ErrMsg: mverr.BulkDuplicateEmailErrorMsg}
+		} else {
+			us.readPool.Acquire()
+			vErr = us.validateUser(ctx, *u, rqstType)
+			us.readPool.Release()
+		}
+		if vErr != nil {
+			r.Status = errToStatus(vErr)
+			r.ErrMsg = vErr.ErrMsg
+			r.ErrReason = vErr.ErrCode
+			responses.OverallStatus = StatusConflict
+		}
+		responses.Results = append(responses.Results, r)
+	}
+
+	us.logger.Debugf("ValidateUsers, BulkResponse: %+v", responses)
+
+	return responses, nil
+}
+
+// validateUser returns the error, if any, that creating ('rqstType' CREATE) or updating (UPDATE)
+// 'u' would fail with, short of errors writing to the database
+func (us *UserSvc) validateUser(ctx context.Context, u domain.User, rqstType RqstType) *mverr.MVError {
+	exceptID := 0
+	if rqstType == CREATE {
+		if err := us.authorize(ctx, CREATE, u.AccountID); err != nil {
+			return err
+		}
+	} else {
+		existing, err := us.users(ctx).GetUser(u.ID)
+		if err != nil {
+			return err
+		}
+		if err = us.authorizeUpdate(ctx, existing, u); err != nil {
+			return err
+		}
+		if existing == nil {
+			return &mverr.MVError{
+				ErrCode:   mverr.DBNoUserErrorCode,
+				ErrMsg:    mverr.DBNoUserErrorMsg,
+				ErrDetail: fmt.Sprintf("error, attempting to update non-existent user, user.ID %d", u.ID),
+			}
+		}
+		exceptID = u.ID
+	}
+
+	if err := u.ValidateUser(); err != nil {
+		return &mverr.MVError{
+			ErrCode:    mverr.UserValidationErrorCode,
+			ErrMsg:     mverr.UserValidationErrorMsg,
+			ErrDetail:  err.Error(),
+			WrappedErr: err,
+		}
+	}
+
+	inUse, err := us.users(ctx).EmailInUse(u.EMail, exceptID)
+	if err != nil {
+		return err
+	}
+	if inUse && rqstType == CREATE {
+		return &mverr.MVError{
+			ErrCode:   mverr.DBInsertDuplicateUserErrorCode,
+			ErrMsg:    mverr.DBInsertDuplicateUserErrorMsg,
+			ErrDetail: fmt.Sprintf("duplicate email address: User name: %s, User email: %s", u.Name, u.EMail),
+		}
+	}
+	if inUse {
+		return &mverr.MVError{
+			ErrCode:   mverr.DBUpSertErrorCode,
+			ErrMsg:    mverr.DBUpSertErrorMsg,
+			ErrDetail: fmt.Sprintf("error updating user %d, email %s in use by another user", u.ID, u.EMail),
+		}
+	}
+	return nil
+}
+
+// DeleteUser deletes an existing user from the database. Only a primary user of the user's
+// account is authorized to delete the user. DeleteUser is idempotent, deleting a user that
+// doesn't exist, e.g., when a DELETE is retried, succeeds. A user with dependent records, see
+// domain.UserDependency, isn't deleted, a DeleteBlockedErrorCode error wrapping a
+// *domain.DependentsError is returned instead. The dependencies are checked and the user is
+// deleted in a single UnitOfWork, if one has been set, so a dependent record can't be added in
+// between. The impersonation tokens for a deleted user are revoked. The deletion is only recorded
+// and published, see ChangeLog and UserDeleted, if the user existed.
+func (us *UserSvc) DeleteUser(ctx context.Context, id int) *mverr.MVError {
+	us.writePool.Acquire()
+	defer us.writePool.Release()
+
+	err := us.authorizeDelete(ctx, id)
+	if err != nil {
+		us.logUserError(err)
+		return err
+	}
+
+	var deleted bool
+	err = us.inUnitOfWork(func(svc *UserSvc) *mverr.MVError {
+		deps, err := svc.users(ctx).GetUserDependencies(id)
+		if err != nil {
+			svc.logUserError(err)
+			return err
+		}
+		if len(deps) > 0 {
+			err = &mverr.MVError{
+				ErrCode:    mverr.DeleteBlockedErrorCode,
+				ErrMsg:     mverr.DeleteBlockedErrorMsg,
+				ErrDetail:  fmt.Sprintf("user %d has dependent records %+v", id, deps),
+				WrappedErr: &domain.DependentsError{Dependencies: deps},
+			}
+			svc.logUserError(err)
+			return err
+		}
+
+		if deleted, err = svc.users(ctx).DeleteUser(id); err != nil {
+			svc.logUserError(err)
+			return err
+		}
+		return nil
+	})
+	if err != nil || !deleted {
+		// DeleteUser is idempotent, there's nothing to record if the user didn't exist
+		return err
+	}
+
+	us.recordChange(domain.ChangeDelete, UserDeleted, id)
+	us.revokeImpersonations(id)
+	return nil
+}
+
+// DeleteUserWithSuccessor deletes the primary user identified by 'id' and makes the user identified
+// by 'successorID', another user of its account, the account's primary user. Both happen in a single
+// transaction so the account is never left without a primary user, see
+// domain.UserRepository.DeleteUserWithSuccessor. It's how a primary user whose account has other
+// users, which DeleteUser rejects, is deleted. Authorization and idempotency are as for DeleteUser.
+func (us *UserSvc) DeleteUserWithSuccessor(ctx context.Context, id, successorID int) *mverr.MVError {
+	us.writePool.Acquire()
+	defer us.writePool.Release()
+
+	err := us.authorizeDelete(ctx, id)
 	if err != nil {
 		us.logUserError(err)
 		return err
 	}
 
+	deleted, err := us.users(ctx).DeleteUserWithSuccessor(id, successorID)
+	if err != nil {
+		us.logUserError(err)
+		return err
+	}
+	if !deleted {
+		return nil
+	}
+
+	us.recordChange(domain.ChangeUpdate, UserUpdated, successorID)
+	us.recordChange(domain.ChangeDelete, UserDeleted, id)
+	us.revokeImpersonations(id)
 	return nil
 }
 
+// DeleteUsers deletes the group of users identified by 'ids', each as DeleteUser does, so deleting a
+// user that doesn't exist succeeds. The users are deleted concurrently, an account's primary user is
+// only deleted if the account has no other users when it's processed, so it should be deleted by a
+// later request. The results are in the same order as 'ids', each result's user only has its ID.
+func (us *UserSvc) DeleteUsers(ctx context.Context, ids []int) (bulkResponse *BulkResponse, err *mverr.MVError) {
+	users := domain.Users{}
+	items := make([]int, 0, len(ids))
+	for i, id := range ids {
+		users.Users = append(users.Users, &domain.User{ID: id})
+		items = append(items, i)
+	}
+
+	responses := us.handleRqstMultipleUsers(ctx, time.Now(), users, items, DELETE)
+	sort.Slice(responses.Results, func(i, j int) bool {
+		return responses.Results[i].Item < responses.Results[j].Item
+	})
+
+	for _, result := range responses.Results {
+		if result.Failed() {
+			us.logger.WithFields(logging.Fields{
+				logging.ErrorCode:   result.ErrReason,
+				logging.Status:      result.Status,
+				logging.ErrorDetail: fmt.Sprintf("error deleting user: ID: %d", result.User.ID),
+			}).Errorf(result.ErrMsg)
+		}
+	}
+
+	if responses.OverallStatus != StatusOK {
+		err = &mverr.MVError{
+			ErrCode: mverr.BulkRequestErrorCode,
+			ErrMsg:  mverr.BulkRequestErrorMsg,
+			WrappedErr: fmt.Errorf("part or all of a bulk delete request failed, overall request status %s",
+				StatusTypeName[responses.OverallStatus]),
+		}
+		us.logUserError(err)
+		return responses, err
+	}
+
+	us.logger.Debugf("DeleteUsers, BulkResponse: %+v", responses)
+
+	return responses, nil
+}
+
+// authorizeDelete returns an error if the caller in 'ctx' isn't authorized to delete the user
+// identified by 'id'. Deleting a non-existent user is a no-op so any caller is authorized to do it.
+func (us *UserSvc) authorizeDelete(ctx context.Context, id int) *mverr.MVError {
+	if ok, err := us.identified(ctx, DELETE); !ok {
+		return err
+	}
+	existing, err := us.users(ctx).GetUser(id)
+	if err != nil || existing == nil {
+		return err
+	}
+	return us.authorize(ctx, DELETE, existing.AccountID)
+}
+
+// revokeImpersonations revokes the impersonation tokens for the deleted user identified by 'id'
+func (us *UserSvc) revokeImpersonations(id int) {
+	if us.impersonations == nil {
+		return
+	}
+	if n := us.impersonations.RevokeUser(id); n > 0 {
+		us.logger.WithFields(logging.Fields{
+			logging.UserID: id,
+		}).Infof("revoked %d impersonation tokens for deleted user", n)
+	}
+}
+
+// ActivateUser activates the pending user identified by 'id' if 'token' matches the token
+// sent to the user when they were created. Activating is the user's first login, see RecordLogin.
+func (us *UserSvc) ActivateUser(ctx context.Context, id int, token string) *mverr.MVError {
+	us.writePool.Acquire()
+	defer us.writePool.Release()
+
+	err := us.users(ctx).ActivateUser(id, token)
+	if err != nil {
+		us.logUserError(err)
+		return err
+	}
+
+	// Pending users aren't returned by GetUsers, the user only now becomes one of them
+	us.recordChange(domain.ChangeCreate, UserActivated, id)
+	// The user is activated even if the login can't be recorded, it only affects GetInactiveUsers
+	if err = us.users(ctx).RecordLogin(id); err != nil {
+		us.logUserError(err)
+	}
+	return nil
+}
+
+// RecordLogin records that the user identified by 'id' has just logged in, i.e., authenticated
+// themselves, setting the user's LastLogin. It's called by the authentication flow, not on behalf
+// of a caller, so it isn't authorized.
+func (us *UserSvc) RecordLogin(ctx context.Context, id int) *mverr.MVError {
+	us.writePool.Acquire()
+	defer us.writePool.Release()
+
+	if err := us.users(ctx).RecordLogin(id); err != nil {
+		us.logUserError(err)
+		return err
+	}
+	return nil
+}
+
+// UpdateRoles atomically changes the roles of the users in account 'accountID', e.g., when
+// the account's primary user changes. 'roles' maps user IDs to their new roles. The account
+// must be left with exactly one primary user. Only a primary user of the account is
+// authorized to change roles.
+func (us *UserSvc) UpdateRoles(ctx context.Context, accountID int, roles map[int]domain.Role) *mverr.MVError {
+	us.writePool.Acquire()
+	defer us.writePool.Release()
+
+	err := us.authorize(ctx, UPDATE, accountID)
+	if err != nil {
+		us.logUserError(err)
+		return err
+	}
+
+	err = us.users(ctx).UpdateRoles(accountID, roles)
+	if err != nil {
+		us.logUserError(err)
+		return err
+	}
+
+	ids := make([]int, 0, len(roles))
+	for id := range roles {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		us.recordChange(domain.ChangeUpdate, UserUpdated, id)
+	}
+	return nil
+}
+
+// ExpirePendingUsers deletes pending users that weren't activated before their
+// activation token expired. It returns the number of users deleted.
+func (us *UserSvc) ExpirePendingUsers() (int, *mverr.MVError) {
+	us.writePool.Acquire()
+	defer us.writePool.Release()
+
+	n, err := us.repo.DeleteExpiredUsers()
+	if err != nil {
+		us.logUserError(err)
+		return 0, err
+	}
+
+	PendingUsersExpired.Add(float64(n))
+	return n, nil
+}
+
+// EnableWriteBehind allows user creations to be queued via EnqueueUser. A WriteBehindWorker
+// is needed to apply the queued user creations.
+func (us *UserSvc) EnableWriteBehind(queue domain.UserQueueRepository) {
+	us.queue = queue
+}
+
+// EnqueueUser queues a new User to be inserted into the database later. The returned
+// queueID is a provisional ID that can be used to check the status of the creation.
+// The caller is authorized, and the user's password is hashed, when the user is queued,
+// as with CreateUser.
+func (us *UserSvc) EnqueueUser(ctx context.Context, u domain.User) (queueID int, err *mverr.MVError) {
+	if us.queue == nil {
+		err = &mverr.MVError{
+			ErrCode:   mverr.WriteBehindDisabledErrorCode,
+			ErrMsg:    mverr.WriteBehindDisabledErrorMsg,
+			ErrDetail: "EnqueueUser called without a user queue",
+		}
+		us.logUserError(err)
+		return 0, err
+	}
+
+	if err = us.authorize(ctx, CREATE, u.AccountID); err != nil {
+		us.logUserError(err)
+		return 0, err
+	}
+	if u.Password, err = us.hashPassword(u.Password); err != nil {
+		us.logUserError(err)
+		return 0, err
+	}
+
+	us.writePool.Acquire()
+	defer us.writePool.Release()
+
+	queueID, err = us.queue.EnqueueUser(u)
+	if err != nil {
+		us.logUserError(err)
+		return 0, err
+	}
+
+	return queueID, nil
+}
+
+// GetQueuedUser retrieves the status of a queued user creation
+func (us *UserSvc) GetQueuedUser(ctx context.Context, queueID int) (*domain.QueuedUser, *mverr.MVError) {
+	if us.queue == nil {
+		err := &mverr.MVError{
+			ErrCode:   mverr.WriteBehindDisabledErrorCode,
+			ErrMsg:    mverr.WriteBehindDisabledErrorMsg,
+			ErrDetail: "GetQueuedUser called without a user queue",
+		}
+		us.logUserError(err)
+		return nil, err
+	}
+
+	us.readPool.Acquire()
+	defer us.readPool.Release()
+
+	qu, err := us.queue.GetQueuedUser(queueID)
+	if err != nil {
+		us.logUserError(err)
+		return nil, err
+	}
+
+	if qu == nil {
+		err := mverr.MVError{
+			ErrCode:   mverr.DBNoQueuedUserErrorCode,
+			ErrDetail: fmt.Sprintf("Queued user %d not found", queueID),
+			ErrMsg:    mverr.DBNoQueuedUserErrorMsg,
+		}
+		us.logUserError(&err)
+		return nil, &err
+	}
+	return qu, nil
+}
+
+// hashPassword returns the bcrypt hash of 'password', see auth.HashPassword, so that users' passwords
+// are never stored in plain text. An empty password is returned unchanged.
+func (us *UserSvc) hashPassword(password string) (string, *mverr.MVError) {
+	if password == "" {
+		return password, nil
+	}
+	hash, err := auth.HashPassword(password, us.passwordCost)
+	if err != nil {
+		return "", &mverr.MVError{
+			ErrCode:    mverr.UserValidationErrorCode,
+			ErrMsg:     mverr.UserValidationErrorMsg,
+			ErrDetail:  "unable to hash the user's password",
+			WrappedErr: err,
+		}
+	}
+	return hash, nil
+}
+
+// upsertPassword returns the password 'u' is upserted with. That's the stored hash of the existing user
+// in u's account with u's email address if 'u.Password' is the password it was computed from, so that
+// an otherwise unchanged user is skipped. Hashes are salted, hashing the password again would always
+// update the user. Otherwise it's the hash of 'u.Password', see hashPassword.
+func (us *UserSvc) upsertPassword(ctx context.Context, u domain.User) (string, *mverr.MVError) {
+	if u.Password == "" {
+		return "", nil
+	}
+
+	us.readPool.Acquire()
+	existing, hash, err := us.users(ctx).GetCredentials(u.EMail)
+	us.readPool.Release()
+	if err != nil {
+		return "", err
+	}
+	if existing != nil && existing.AccountID == u.AccountID && auth.CheckPassword(hash, u.Password) {
+		return hash, nil
+	}
+	return us.hashPassword(u.Password)
+}
+
+// ApplyQueuedUser creates the user in 'qu', a user creation claimed from the write-behind queue,
+// and marks the queued creation complete. Both are done in a single UnitOfWork, if one has been
+// set, so a created user is never left with an incomplete queued creation. The caller is
+// responsible for marking the queued creation failed if an error is returned. The caller
+// was authorized when the user was queued. The activation email is sent before the UnitOfWork
+// commits, if it's rolled back the token can't be used.
+func (us *UserSvc) ApplyQueuedUser(ctx context.Context, qu *domain.QueuedUser) (userID int, err *mverr.MVError) {
+	if us.queue == nil {
+		err = &mverr.MVError{
+			ErrCode:   mverr.WriteBehindDisabledErrorCode,
+			ErrMsg:    mverr.WriteBehindDisabledErrorMsg,
+			ErrDetail: "ApplyQueuedUser called without a user queue",
+		}
+		us.logUserError(err)
+		return 0, err
+	}
+
+	// The caller was authorized when the user was queued
+	ctx = withinService(ctx)
+	err = us.inUnitOfWork(func(svc *UserSvc) *mverr.MVError {
+		id, err := svc.createUser(ctx, qu.User, qu.PasswordHashed)
+		if err != nil {
+			return err
+		}
+		if err = svc.queue.CompleteUser(qu.ID, id); err != nil {
+			svc.logUserError(err)
+			return err
+		}
+		userID = id
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// EnableSignup allows new accounts, and their primary users, to be created via Signup
+func (us *UserSvc) EnableSignup(accounts domain.AccountRepository) {
+	us.accounts = accounts
+}
+
+// Signup creates a new account and its primary user, a pending user as with CreateUser, and
+// returns their IDs. Both are created in a single UnitOfWork, if one has been
+// set, so an account is never left without its primary user. 'signup.User' is always created
+// as the account's Primary user. As with ApplyQueuedUser the activation email is sent before the
+// UnitOfWork commits, if it's rolled back the token can't be used.
+func (us *UserSvc) Signup(ctx context.Context, signup domain.Signup) (accountID, userID int, err *mverr.MVError) {
+	if us.accounts == nil {
+		err = &mverr.MVError{
+			ErrCode:   mverr.SignupDisabledErrorCode,
+			ErrMsg:    mverr.SignupDisabledErrorMsg,
+			ErrDetail: "Signup called without an account repository",
+		}
+		us.logUserError(err)
+		return 0, 0, err
+	}
+
+	user := signup.User
+	user.Role = domain.Primary
+	// Signups are anonymous, the new account's primary user is created on the service's behalf
+	ctx = withinService(ctx)
+	err = us.inUnitOfWork(func(svc *UserSvc) *mverr.MVError {
+		svc.writePool.Acquire()
+		id, err := svc.accounts.CreateAccount(signup.Account)
+		svc.writePool.Release()
+		if err != nil {
+			svc.logUserError(err)
+			return err
+		}
+
+		user.AccountID = id
+		if userID, err = svc.CreateUser(ctx, user); err != nil {
+			return err
+		}
+		accountID = id
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return accountID, userID, nil
+}
+
 func (us *UserSvc) logUserError(e *mverr.MVError) {
-	us.logger.WithFields(log.Fields{
+	us.logger.WithFields(logging.Fields{
 		logging.ErrorCode:    e.ErrCode,
 		logging.ErrorDetail:  e.ErrDetail,
 		logging.WrappedError: e.WrappedErr,
@@ -186,14 +1306,19 @@ func (us *UserSvc) logUserError(e *mverr.MVError) {
 
 }
 
-func (us *UserSvc) handleRqstMultipleUsers(start time.Time, users domain.Users, rqstType RqstType) *BulkResponse {
+func (us *UserSvc) handleRqstMultipleUsers(ctx context.Context, start time.Time, users domain.Users, items []int, rqstType RqstType) *BulkResponse {
 	us.logger.Debugf("handleRqstMultipleUsers for %s", RqstTypeName[rqstType])
 	bp := NewBulkProcessor(us.maxBulkOps, us.logger)
 	defer bp.Stop()
 
-	br := NewBulkRequest(users, rqstType, us)
+	BulkBatchSize.WithLabelValues(RqstTypeName[rqstType]).Observe(float64(len(users.Users)))
+	ctx, span := httpclient.StartSpan(ctx, "bulk batch")
+	br := NewBulkRequest(ctx, users, rqstType, us)
 	rqstCompleteC := make(chan Response)
 	numUsers := len(users.Users)
+	for i := range br.Requests {
+		br.Requests[i].item = items[i]
+	}
 
 	for i := 0; i < numUsers; i++ {
 		go us.handleConcurrentRqst(br.Requests[i], bp.RequestC, rqstCompleteC)
@@ -215,6 +1340,8 @@ func (us *UserSvc) handleRqstMultipleUsers(start time.Time, users domain.Users,
 	}
 
 	responses.OverallStatus = overallStatus
+	span.End()
+	logSpan(us.logger, span, StatusTypeName[overallStatus])
 	return &responses
 }
 
@@ -0,0 +1,980 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/internal/auth"
+	userdb "github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/db/memory"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// TestRetrySafety verifies that DELETE is idempotent and that an update never creates a user,
+// with and without an authenticated caller
+func TestRetrySafety(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	primary := auth.Caller{UserID: 1, AccountID: 1, Role: domain.Primary}
+
+	tcs := []struct {
+		testName string
+		caller   *auth.Caller
+	}{
+		{
+			testName: "testRetrySafetyNoCaller",
+		},
+		{
+			testName: "testRetrySafetyPrimary",
+			caller:   &primary,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			repo := memory.NewUserTable()
+			for _, u := range []domain.User{
+				{AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary, Password: "pw"},
+				{AccountID: 1, Name: "davy jones", EMail: "davyj@gmail.com", Role: domain.Restricted, Password: "pw"},
+			} {
+				if _, err := repo.CreateUser(u); err != nil {
+					t.Fatalf("error %s was not expected creating user %s", err, u.Name)
+				}
+			}
+			userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+
+			ctx := context.Background()
+			if tc.caller != nil {
+				ctx = auth.NewContext(ctx, *tc.caller)
+			}
+
+			for attempt := 1; attempt <= 2; attempt++ {
+				if err := userSvc.DeleteUser(ctx, 2); err != nil {
+					t.Errorf("error %s was not expected deleting user 2, attempt %d", err, attempt)
+				}
+			}
+			if u, _ := repo.GetUser(2); u != nil {
+				t.Errorf("expected user 2 to be deleted, got %+v", u)
+			}
+
+			missing := domain.User{ID: 2, AccountID: 1, Name: "davy jones", EMail: "davyj@gmail.com", Role: domain.Restricted, Password: "pw"}
+			err2 := userSvc.UpdateUser(ctx, missing)
+			if err2 == nil || err2.ErrCode != mverr.DBNoUserErrorCode {
+				t.Errorf("expected error code %d updating a deleted user, got %v", mverr.DBNoUserErrorCode, err2)
+			}
+			if u, _ := repo.GetUser(2); u != nil {
+				t.Errorf("expected an update to not create user 2, got %+v", u)
+			}
+		})
+	}
+}
+
+func TestCreateUsersDuplicateEmails(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	tcs := []struct {
+		testName string
+		emails   []string
+		// expected maps each email address to the status of its creation
+		expected       map[string]Status
+		expectedStatus Status
+	}{
+		{
+			testName:       "testNoDuplicates",
+			emails:         []string{"mickeyd@gmail.com", "davyj@gmail.com"},
+			expected:       map[string]Status{"mickeyd@gmail.com": StatusCreated, "davyj@gmail.com": StatusCreated},
+			expectedStatus: StatusCreated,
+		},
+		{
+			testName:       "testDuplicatesRejected",
+			emails:         []string{"mickeyd@gmail.com", "davyj@gmail.com", "mickeyd@gmail.com"},
+			expected:       map[string]Status{"mickeyd@gmail.com": StatusBadRequest, "davyj@gmail.com": StatusCreated},
+			expectedStatus: StatusConflict,
+		},
+		{
+			testName:       "testDuplicatesIgnoreCase",
+			emails:         []string{"MickeyD@gmail.com", "mickeyd@gmail.com"},
+			expected:       map[string]Status{"MickeyD@gmail.com": StatusBadRequest, "mickeyd@gmail.com": StatusBadRequest},
+			expectedStatus: StatusConflict,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			repo := memory.NewUserTable()
+			userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+
+			users := domain.Users{}
+			for _, email := range tc.emails {
+				users.Users = append(users.Users, &domain.User{AccountID: 1, Name: email, EMail: email, Role: domain.Restricted, Password: "pw"})
+			}
+			resp, _ := userSvc.CreateUsers(context.Background(), users)
+
+			if resp.OverallStatus != tc.expectedStatus {
+				t.Errorf("expected overall status %s, got %s", StatusTypeName[tc.expectedStatus], StatusTypeName[resp.OverallStatus])
+			}
+			if len(resp.Results) != len(tc.emails) {
+				t.Fatalf("expected %d results, got %d", len(tc.emails), len(resp.Results))
+			}
+			created := 0
+			for _, result := range resp.Results {
+				expected := tc.expected[result.User.EMail]
+				if result.Status != expected {
+					t.Errorf("expected status %s for %s, got %s", StatusTypeName[expected], result.User.EMail, StatusTypeName[result.Status])
+				}
+				if expected == StatusBadRequest && result.ErrReason != mverr.BulkDuplicateEmailErrorCode {
+					t.Errorf("expected error code %d for %s, got %d", mverr.BulkDuplicateEmailErrorCode, result.User.EMail, result.ErrReason)
+				}
+				if result.Status == StatusCreated {
+					created++
+				}
+			}
+			// Rejected users mustn't reach the repository. The created users are pending, so
+			// they're not returned by GetUsers.
+			stored := 0
+			for id := 1; id <= len(tc.emails); id++ {
+				if u, _ := repo.GetUser(id); u != nil {
+					stored++
+				}
+			}
+			if stored != created {
+				t.Errorf("expected %d users to be created, got %d", created, stored)
+			}
+		})
+	}
+}
+
+func TestUpsertUsers(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	hashed, err := auth.HashPassword("pw", auth.MinPasswordCost)
+	if err != nil {
+		t.Fatalf("error %s was not expected hashing a password", err)
+	}
+	repo := memory.NewUserTable()
+	for _, u := range []domain.User{
+		{AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary, Password: hashed},
+		{AccountID: 1, Name: "davy jones", EMail: "davyj@gmail.com", Role: domain.Restricted, Password: hashed},
+		{AccountID: 2, Name: "peter tork", EMail: "petert@gmail.com", Role: domain.Primary, Password: hashed},
+		{AccountID: 1, Name: "michael nesmith", EMail: "michaeln@gmail.com", Role: domain.Restricted, Password: hashed},
+	} {
+		if _, err := repo.CreateUser(u); err != nil {
+			t.Fatalf("error %s was not expected creating user %s", err, u.Name)
+		}
+	}
+	userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting UserSvc", err)
+	}
+	if err = userSvc.SetPasswordCost(auth.MinPasswordCost); err != nil {
+		t.Fatalf("error %s was not expected setting the password cost", err)
+	}
+
+	users := domain.Users{Users: []*domain.User{
+		// unchanged
+		{AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary, Password: "pw"},
+		// new password
+		{AccountID: 1, Name: "michael nesmith", EMail: "michaeln@gmail.com", Role: domain.Restricted, Password: "newpw"},
+		// new role
+		{AccountID: 1, Name: "davy jones", EMail: "davyj@gmail.com", Role: domain.Unrestricted, Password: "pw"},
+		// new user
+		{AccountID: 1, Name: "mike nesmith", EMail: "miken@gmail.com", Role: domain.Restricted, Password: "pw"},
+		// in use in another account
+		{AccountID: 1, Name: "peter tork", EMail: "petert@gmail.com", Role: domain.Restricted, Password: "pw"},
+		// duplicates in the request
+		{AccountID: 1, Name: "micky dolenz", EMail: "mickyd@gmail.com", Role: domain.Restricted, Password: "pw"},
+		{AccountID: 1, Name: "micky dolenz", EMail: "MickyD@gmail.com", Role: domain.Restricted, Password: "pw"},
+	}}
+	resp, mvErr := userSvc.UpsertUsers(context.Background(), users)
+	if mvErr == nil || mvErr.ErrCode != mverr.BulkRequestErrorCode {
+		t.Errorf("expected error code %d, got %v", mverr.BulkRequestErrorCode, mvErr)
+	}
+	if resp.OverallStatus != StatusConflict {
+		t.Errorf("expected overall status %s, got %s", StatusTypeName[StatusConflict], StatusTypeName[resp.OverallStatus])
+	}
+	expectedSummary := UpsertSummary{Created: 1, Updated: 2, Skipped: 1, Failed: 3}
+	if resp.Summary == nil || *resp.Summary != expectedSummary {
+		t.Errorf("expected summary %+v, got %+v", expectedSummary, resp.Summary)
+	}
+
+	expected := map[string]mverr.ErrCode{
+		"petert@gmail.com": mverr.DBInsertDuplicateUserErrorCode,
+		"mickyd@gmail.com": mverr.BulkDuplicateEmailErrorCode,
+		"MickyD@gmail.com": mverr.BulkDuplicateEmailErrorCode,
+	}
+	for _, result := range resp.Results {
+		if result.ErrReason != expected[result.User.EMail] {
+			t.Errorf("expected error code %d for %s, got %d", expected[result.User.EMail], result.User.EMail, result.ErrReason)
+		}
+	}
+	if u, _ := repo.GetUser(2); u.Role != domain.Unrestricted {
+		t.Errorf("expected user 2 to be unrestricted, got %+v", u)
+	}
+	if u, _ := repo.GetUser(3); u.AccountID != 2 || u.Role != domain.Primary {
+		t.Errorf("expected user 3 to be unchanged, got %+v", u)
+	}
+	if u, _ := repo.GetUser(5); u == nil || u.EMail != "miken@gmail.com" {
+		t.Errorf("expected user 5 to be created, got %+v", u)
+	}
+	if _, hash, _ := repo.GetCredentials("mickeyd@gmail.com"); hash != hashed {
+		t.Errorf("expected mickeyd@gmail.com's password hash to be unchanged, got %s", hash)
+	}
+	if _, hash, _ := repo.GetCredentials("michaeln@gmail.com"); !auth.CheckPassword(hash, "newpw") {
+		t.Errorf("expected michaeln@gmail.com's password to be updated, got hash %s", hash)
+	}
+}
+
+func TestValidateUsers(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	restricted := auth.Caller{UserID: 2, AccountID: 1, Role: domain.Restricted}
+	newUser := func(id int, name, email string) *domain.User {
+		return &domain.User{ID: id, AccountID: 1, Name: name, EMail: email, Role: domain.Restricted, Password: "pw"}
+	}
+
+	tcs := []struct {
+		testName string
+		rqstType RqstType
+		caller   *auth.Caller
+		users    []*domain.User
+		// expected are the error codes of the results, in the same order as 'users'
+		expected       []mverr.ErrCode
+		expectedStatus Status
+	}{
+		{
+			testName:       "testCreateValid",
+			rqstType:       CREATE,
+			users:          []*domain.User{newUser(0, "peter tork", "petert@gmail.com"), newUser(0, "mike nesmith", "miken@gmail.com")},
+			expected:       []mverr.ErrCode{mverr.NoErrorCode, mverr.NoErrorCode},
+			expectedStatus: StatusOK,
+		},
+		{
+			testName: "testCreateInvalid",
+			rqstType: CREATE,
+			users: []*domain.User{
+				newUser(0, "peter tork", "petert@gmail.com"),
+				newUser(0, "", "miken@gmail.com"),
+				newUser(0, "mickey dolenz", "MickeyD@gmail.com"),
+				newUser(0, "davy jones", "davyj@gmail.com"),
+				newUser(0, "davy jones", "davyj@gmail.com"),
+			},
+			expected: []mverr.ErrCode{mverr.NoErrorCode, mverr.UserValidationErrorCode, mverr.DBInsertDuplicateUserErrorCode,
+				mverr.BulkDuplicateEmailErrorCode, mverr.BulkDuplicateEmailErrorCode},
+			expectedStatus: StatusConflict,
+		},
+		{
+			testName:       "testCreateUnauthorized",
+			rqstType:       CREATE,
+			caller:         &restricted,
+			users:          []*domain.User{newUser(0, "peter tork", "petert@gmail.com")},
+			expected:       []mverr.ErrCode{mverr.UserUnauthorizedErrorCode},
+			expectedStatus: StatusConflict,
+		},
+		{
+			testName:       "testUpdateValid",
+			rqstType:       UPDATE,
+			users:          []*domain.User{newUser(2, "davy jones", "davyj@gmail.com")},
+			expected:       []mverr.ErrCode{mverr.NoErrorCode},
+			expectedStatus: StatusOK,
+		},
+		{
+			testName:       "testUpdateInvalid",
+			rqstType:       UPDATE,
+			users:          []*domain.User{newUser(99, "peter tork", "petert@gmail.com"), newUser(2, "davy jones", "MickeyD@gmail.com")},
+			expected:       []mverr.ErrCode{mverr.DBNoUserErrorCode, mverr.DBUpSertErrorCode},
+			expectedStatus: StatusConflict,
+		},
+		{
+			testName:       "testDeleteNotSupported",
+			rqstType:       DELETE,
+			users:          []*domain.User{newUser(2, "davy jones", "davyj@gmail.com")},
+			expectedStatus: StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			repo := memory.NewUserTable()
+			for _, u := range []domain.User{
+				{AccountID: 1, Name: "mickey dolenz", EMail: "MickeyD@gmail.com", Role: domain.Primary, Password: "pw"},
+				{AccountID: 1, Name: "davy jones", EMail: "davyjones@gmail.com", Role: domain.Restricted, Password: "pw"},
+			} {
+				if _, err := repo.CreateUser(u); err != nil {
+					t.Fatalf("error %s was not expected creating user %s", err, u.Name)
+				}
+			}
+			userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+
+			ctx := context.Background()
+			if tc.caller != nil {
+				ctx = auth.NewContext(ctx, *tc.caller)
+			}
+			resp, _ := userSvc.ValidateUsers(ctx, domain.Users{Users: tc.users}, tc.rqstType)
+
+			if !resp.DryRun {
+				t.Errorf("expected the response to be marked as a dry run")
+			}
+			if resp.OverallStatus != tc.expectedStatus {
+				t.Errorf("expected overall status %s, got %s", StatusTypeName[tc.expectedStatus], StatusTypeName[resp.OverallStatus])
+			}
+			if len(resp.Results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %d", len(tc.expected), len(resp.Results))
+			}
+			for i, result := range resp.Results {
+				if result.ErrReason != tc.expected[i] {
+					t.Errorf("expected error code %d for user %d, got %d: %s", tc.expected[i], i, result.ErrReason, result.ErrMsg)
+				}
+				if tc.expected[i] == mverr.NoErrorCode && result.Status != StatusOK {
+					t.Errorf("expected status %s for user %d, got %s", StatusTypeName[StatusOK], i, StatusTypeName[result.Status])
+				}
+			}
+
+			// Nothing is written
+			if u, _ := repo.GetUser(3); u != nil {
+				t.Errorf("expected no users to be created, got %+v", u)
+			}
+			if u, _ := repo.GetUser(2); u.EMail != "davyjones@gmail.com" {
+				t.Errorf("expected user 2 to be unchanged, got %+v", u)
+			}
+		})
+	}
+}
+
+func TestBulkPreflight(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	users := func() domain.Users {
+		return domain.Users{Users: []*domain.User{
+			{AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Restricted, Password: "pw"},
+			// no email address
+			{AccountID: 1, Name: "davy jones", Role: domain.Restricted, Password: "pw"},
+			{AccountID: 1, Name: "peter tork", EMail: "petert@gmail.com", Role: domain.Restricted, Password: "pw"},
+			// invalid role
+			{AccountID: 1, Name: "mike nesmith", EMail: "miken@gmail.com", Role: 9, Password: "pw"},
+		}}
+	}
+
+	tcs := []struct {
+		testName       string
+		validation     BulkValidation
+		rqstType       RqstType
+		expected       []mverr.ErrCode
+		expectedStatus Status
+		expectedUsers  int
+	}{
+		{
+			testName:       "testCreateContinueOnError",
+			validation:     ContinueOnError,
+			rqstType:       CREATE,
+			expected:       []mverr.ErrCode{mverr.NoErrorCode, mverr.UserValidationErrorCode, mverr.NoErrorCode, mverr.UserValidationErrorCode},
+			expectedStatus: StatusConflict,
+			expectedUsers:  2,
+		},
+		{
+			testName:       "testCreateFailFast",
+			validation:     FailFast,
+			rqstType:       CREATE,
+			expected:       []mverr.ErrCode{mverr.UserValidationErrorCode, mverr.UserValidationErrorCode},
+			expectedStatus: StatusBadRequest,
+			expectedUsers:  0,
+		},
+		{
+			testName:       "testUpsertFailFast",
+			validation:     FailFast,
+			rqstType:       UPSERT,
+			expected:       []mverr.ErrCode{mverr.UserValidationErrorCode, mverr.UserValidationErrorCode},
+			expectedStatus: StatusBadRequest,
+			expectedUsers:  0,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			repo := memory.NewUserTable()
+			userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			if err = userSvc.SetBulkValidation(tc.validation); err != nil {
+				t.Fatalf("error %s was not expected setting the bulk validation", err)
+			}
+
+			var resp *BulkResponse
+			var mvErr *mverr.MVError
+			if tc.rqstType == CREATE {
+				resp, mvErr = userSvc.CreateUsers(context.Background(), users())
+			} else {
+				resp, mvErr = userSvc.UpsertUsers(context.Background(), users())
+			}
+			if mvErr == nil {
+				t.Errorf("expected an error, got none")
+			}
+			if resp.OverallStatus != tc.expectedStatus {
+				t.Errorf("expected overall status %s, got %s", StatusTypeName[tc.expectedStatus], StatusTypeName[resp.OverallStatus])
+			}
+			if len(resp.Results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %d", len(tc.expected), len(resp.Results))
+			}
+			for i, result := range resp.Results {
+				if result.ErrReason != tc.expected[i] {
+					t.Errorf("expected error code %d for result %d, got %d: %s", tc.expected[i], i, result.ErrReason, result.ErrMsg)
+				}
+				if i > 0 && result.Item <= resp.Results[i-1].Item {
+					t.Errorf("expected the results in the order of the request, got item %d after item %d", result.Item, resp.Results[i-1].Item)
+				}
+				if result.Failed() && !strings.HasPrefix(result.ErrMsg, fmt.Sprintf("item %d: ", result.Item)) {
+					t.Errorf("expected the error message to be prefixed with item %d, got %q", result.Item, result.ErrMsg)
+				}
+			}
+
+			// The created users are pending, so they're not returned by GetUsers
+			stored := 0
+			for id := 1; id <= len(users().Users); id++ {
+				if u, _ := repo.GetUser(id); u != nil {
+					stored++
+				}
+			}
+			if stored != tc.expectedUsers {
+				t.Errorf("expected %d users to be written, got %d", tc.expectedUsers, stored)
+			}
+		})
+	}
+
+	userSvc, err := NewUserSvc(memory.NewUserTable(), logger, 10, 10, 10)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting UserSvc", err)
+	}
+	if err = userSvc.SetBulkValidation(BulkValidation(9)); err == nil {
+		t.Errorf("expected an error setting an unknown bulk validation")
+	}
+}
+
+func TestSignup(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	account := domain.Account{
+		AccountHolderName: "mickey dolenz",
+		ServiceAddress:    "1 Main St",
+		BillingAddress:    "1 Main St",
+		EMail:             "mickeyd@gmail.com",
+		Phone:             "5555550100",
+	}
+	user := domain.User{Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Restricted, Password: "pw"}
+
+	tcs := []struct {
+		testName        string
+		disabled        bool
+		signup          domain.Signup
+		expectedErrCode mverr.ErrCode
+	}{
+		{
+			testName: "testSignupSuccess",
+			signup:   domain.Signup{Account: account, User: user},
+		},
+		{
+			testName:        "testSignupInvalidAccount",
+			signup:          domain.Signup{Account: domain.Account{EMail: account.EMail}, User: user},
+			expectedErrCode: mverr.AccountValidationErrorCode,
+		},
+		{
+			// The account is rolled back when its user can't be created
+			testName:        "testSignupInvalidUser",
+			signup:          domain.Signup{Account: account, User: domain.User{EMail: user.EMail}},
+			expectedErrCode: mverr.UserValidationErrorCode,
+		},
+		{
+			testName:        "testSignupDisabled",
+			disabled:        true,
+			signup:          domain.Signup{Account: account, User: user},
+			expectedErrCode: mverr.SignupDisabledErrorCode,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			repo := memory.NewUserTable()
+			accounts := memory.NewAccountTable()
+			uow, err := memory.NewUnitOfWork(repo)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting a UnitOfWork", err)
+			}
+			if err = uow.SetAccountTable(accounts); err != nil {
+				t.Fatalf("error %s was not expected when setting the AccountTable", err)
+			}
+			userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			mailer := &fakeMailer{}
+			if err = userSvc.ConfigureActivation(mailer, DefaultActivationTTL); err != nil {
+				t.Fatalf("error %s was not expected when configuring activation", err)
+			}
+			if err = userSvc.SetUnitOfWork(uow); err != nil {
+				t.Fatalf("error %s was not expected when setting the UnitOfWork", err)
+			}
+			if !tc.disabled {
+				userSvc.EnableSignup(accounts)
+			}
+
+			accountID, userID, mvErr := userSvc.Signup(context.Background(), tc.signup)
+			if tc.expectedErrCode != mverr.NoErrorCode {
+				if mvErr == nil || mvErr.ErrCode != tc.expectedErrCode {
+					t.Fatalf("expected error code %d, got %v", tc.expectedErrCode, mvErr)
+				}
+				if a := accounts.GetAccount(1); a != nil {
+					t.Errorf("expected no account to be created, got %+v", a)
+				}
+				return
+			}
+			if mvErr != nil {
+				t.Fatalf("error %s was not expected", mvErr)
+			}
+
+			if a := accounts.GetAccount(accountID); a == nil || a.EMail != account.EMail {
+				t.Errorf("expected account %d for %s, got %+v", accountID, account.EMail, a)
+			}
+			u, _ := repo.GetUser(userID)
+			if u == nil {
+				t.Fatalf("expected user %d to be created", userID)
+			}
+			if u.AccountID != accountID || u.Role != domain.Primary || u.Status != domain.Pending {
+				t.Errorf("expected a pending primary user in account %d, got %+v", accountID, u)
+			}
+			if mailer.user.ID != userID || mailer.token == "" {
+				t.Errorf("expected an activation email for user %d, got %+v", userID, mailer.user)
+			}
+		})
+	}
+}
+
+func TestDeleteUserDependencies(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	repo := memory.NewUserTable()
+	for _, u := range []domain.User{
+		{AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary, Password: "pw"},
+		{AccountID: 1, Name: "davy jones", EMail: "davyj@gmail.com", Role: domain.Restricted, Password: "pw"},
+	} {
+		if _, err := repo.CreateUser(u); err != nil {
+			t.Fatalf("error %s was not expected creating user %s", err, u.Name)
+		}
+	}
+	userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting UserSvc", err)
+	}
+	im, err := auth.NewImpersonations("secret", auth.DefaultImpersonationTTL)
+	if err != nil {
+		t.Fatalf("error %s was not expected creating Impersonations", err)
+	}
+	if err = userSvc.SetImpersonations(im); err != nil {
+		t.Fatalf("error %s was not expected setting Impersonations", err)
+	}
+	grant, _ := im.Grant("secret", "jsmith", "ticket 1234", auth.Caller{UserID: 2, AccountID: 1, Role: domain.Restricted})
+	ctx := context.Background()
+
+	// The account's primary user can't be deleted while the account has other users
+	mvErr := userSvc.DeleteUser(ctx, 1)
+	var depErr *domain.DependentsError
+	if mvErr == nil || mvErr.ErrCode != mverr.DeleteBlockedErrorCode || !errors.As(mvErr.WrappedErr, &depErr) {
+		t.Fatalf("expected error code %d wrapping a DependentsError, got %v", mverr.DeleteBlockedErrorCode, mvErr)
+	}
+	if len(depErr.Dependencies) != 1 || depErr.Dependencies[0].Kind != domain.DependentAccountUsers || depErr.Dependencies[0].Count != 1 {
+		t.Errorf("expected 1 dependent account user, got %+v", depErr.Dependencies)
+	}
+	if u, _ := repo.GetUser(1); u == nil {
+		t.Errorf("expected user 1 to not be deleted")
+	}
+
+	// Deleting a user revokes its impersonation tokens, and then the primary user can be deleted
+	if mvErr = userSvc.DeleteUser(ctx, 2); mvErr != nil {
+		t.Fatalf("error %s was not expected deleting user 2", mvErr)
+	}
+	if _, ok := im.Caller(grant.Token); ok {
+		t.Errorf("expected the impersonation token for user 2 to be revoked")
+	}
+	if mvErr = userSvc.DeleteUser(ctx, 1); mvErr != nil {
+		t.Errorf("error %s was not expected deleting user 1", mvErr)
+	}
+}
+
+// TestDeleteUserUnitOfWork verifies that a user's dependencies are checked, and the user deleted,
+// in a single MySQL transaction and that a dependency stops the delete
+func TestDeleteUserUnitOfWork(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	tcs := []struct {
+		testName        string
+		accountUsers    int
+		accountLinks    int
+		expectedErrCode mverr.ErrCode
+	}{
+		{
+			testName: "testDeleteUserNoDependencies",
+		},
+		{
+			testName:        "testDeleteUserAccountUsers",
+			accountUsers:    1,
+			expectedErrCode: mverr.DeleteBlockedErrorCode,
+		},
+		{
+			testName:        "testDeleteUserAccountLinks",
+			accountLinks:    2,
+			expectedErrCode: mverr.DeleteBlockedErrorCode,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+			}
+			defer dbase.Close()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT COUNT(.+) FROM user u JOIN user o (.+) FOR UPDATE").WithArgs(1, domain.Primary).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tc.accountUsers))
+			mock.ExpectQuery("SELECT COUNT(.+) FROM accountUser (.+) FOR UPDATE").WithArgs(1).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tc.accountLinks))
+			if tc.expectedErrCode == 0 {
+				mock.ExpectExec("DELETE FROM user WHERE id = (.+)").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			users, err := userdb.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting a Table", err)
+			}
+			uow, err := userdb.NewUnitOfWork(dbase, users, nil)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting a UnitOfWork", err)
+			}
+			userSvc, err := NewUserSvc(users, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			if err = userSvc.SetUnitOfWork(uow); err != nil {
+				t.Fatalf("error %s was not expected when setting UnitOfWork", err)
+			}
+
+			mvErr := userSvc.DeleteUser(context.Background(), 1)
+			if tc.expectedErrCode == 0 && mvErr != nil {
+				t.Errorf("error %s was not expected", mvErr)
+			}
+			if tc.expectedErrCode != 0 && (mvErr == nil || mvErr.ErrCode != tc.expectedErrCode) {
+				t.Errorf("expected error code %d, got %v", tc.expectedErrCode, mvErr)
+			}
+			// The DELETE must not be executed if the user has dependencies
+			if err = mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestDeleteUsers(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	repo := memory.NewUserTable()
+	for _, u := range []domain.User{
+		{AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary, Password: "pw"},
+		{AccountID: 1, Name: "davy jones", EMail: "davyj@gmail.com", Role: domain.Restricted, Password: "pw"},
+		{AccountID: 2, Name: "peter tork", EMail: "petert@gmail.com", Role: domain.Primary, Password: "pw"},
+	} {
+		if _, err := repo.CreateUser(u); err != nil {
+			t.Fatalf("error %s was not expected creating user %s", err, u.Name)
+		}
+	}
+	userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting UserSvc", err)
+	}
+
+	// User 1 is the primary user of an account with another user, and user 4 doesn't exist
+	resp, mvErr := userSvc.DeleteUsers(context.Background(), []int{3, 1, 4})
+	if mvErr == nil || mvErr.ErrCode != mverr.BulkRequestErrorCode {
+		t.Errorf("expected error code %d, got %v", mverr.BulkRequestErrorCode, mvErr)
+	}
+	if resp.OverallStatus != StatusConflict {
+		t.Errorf("expected overall status %s, got %s", StatusTypeName[StatusConflict], StatusTypeName[resp.OverallStatus])
+	}
+	expected := []Response{
+		{Item: 0, Status: StatusOK, User: domain.User{ID: 3}},
+		{Item: 1, Status: StatusConflict, ErrMsg: mverr.DeleteBlockedErrorMsg, ErrReason: mverr.DeleteBlockedErrorCode, User: domain.User{ID: 1}},
+		{Item: 2, Status: StatusOK, User: domain.User{ID: 4}},
+	}
+	if !reflect.DeepEqual(resp.Results, expected) {
+		t.Errorf("expected results %+v, got %+v", expected, resp.Results)
+	}
+	if u, _ := repo.GetUser(3); u != nil {
+		t.Errorf("expected user 3 to be deleted, got %+v", u)
+	}
+	if u, _ := repo.GetUser(1); u == nil {
+		t.Errorf("expected user 1 to not be deleted")
+	}
+
+	resp, mvErr = userSvc.DeleteUsers(context.Background(), []int{2})
+	if mvErr != nil || resp.OverallStatus != StatusOK {
+		t.Errorf("expected overall status %s, got %s, error %v", StatusTypeName[StatusOK], StatusTypeName[resp.OverallStatus], mvErr)
+	}
+}
+
+func TestLastModifiedBy(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	tcs := []struct {
+		testName           string
+		caller             *auth.Caller
+		expectedModifiedBy string
+	}{
+		{
+			testName: "testLastModifiedByNoCaller",
+		},
+		{
+			testName:           "testLastModifiedByUser",
+			caller:             &auth.Caller{UserID: 1, AccountID: 1, Role: domain.Primary},
+			expectedModifiedBy: "user:1",
+		},
+		{
+			testName:           "testLastModifiedByAPIKey",
+			caller:             &auth.Caller{UserID: 1, AccountID: 1, Role: domain.Primary, APIKey: "billing"},
+			expectedModifiedBy: "apikey:billing",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			repo := memory.NewUserTable()
+			userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			ctx := context.Background()
+			if tc.caller != nil {
+				ctx = auth.NewContext(ctx, *tc.caller)
+			}
+
+			// A LastModifiedBy in the request is ignored
+			u := domain.User{AccountID: 1, Name: "davy jones", EMail: "davyj@gmail.com", Role: domain.Restricted, Password: "pw", LastModifiedBy: "admin:spoofed"}
+			id, mvErr := userSvc.CreateUser(ctx, u)
+			if mvErr != nil {
+				t.Fatalf("error %s was not expected creating the user", mvErr)
+			}
+			if created, _ := repo.GetUser(id); created.LastModifiedBy != tc.expectedModifiedBy {
+				t.Errorf("expected the created user's LastModifiedBy to be %q, got %q", tc.expectedModifiedBy, created.LastModifiedBy)
+			}
+
+			u.ID = id
+			u.Name = "davy"
+			if mvErr = userSvc.UpdateUser(ctx, u); mvErr != nil {
+				t.Fatalf("error %s was not expected updating the user", mvErr)
+			}
+			if updated, _ := repo.GetUser(id); updated.LastModifiedBy != tc.expectedModifiedBy {
+				t.Errorf("expected the updated user's LastModifiedBy to be %q, got %q", tc.expectedModifiedBy, updated.LastModifiedBy)
+			}
+		})
+	}
+}
+
+func TestActivateUserRecordsLogin(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	repo := memory.NewUserTable()
+	userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting UserSvc", err)
+	}
+	mailer := &fakeMailer{}
+	if err = userSvc.ConfigureActivation(mailer, DefaultActivationTTL); err != nil {
+		t.Fatalf("error %s was not expected when configuring activation", err)
+	}
+
+	ctx := context.Background()
+	id, mvErr := userSvc.CreateUser(ctx, domain.User{AccountID: 1, Name: "davy jones", EMail: "davyj@gmail.com", Role: domain.Primary, Password: "pw"})
+	if mvErr != nil {
+		t.Fatalf("error %s was not expected creating the user", mvErr)
+	}
+	if u, _ := repo.GetUser(id); u.LastLogin != nil {
+		t.Errorf("expected a pending user to have never logged in, got LastLogin %s", u.LastLogin)
+	}
+	if mvErr = userSvc.ActivateUser(ctx, id, mailer.token); mvErr != nil {
+		t.Fatalf("error %s was not expected activating the user", mvErr)
+	}
+	if u, _ := repo.GetUser(id); u.LastLogin == nil {
+		t.Errorf("expected activating the user to record its login")
+	}
+}
+
+// contextRepository is a domain.ContextualUserRepository whose operations, once bound to a context,
+// fail if the context is done, as a DB request would
+type contextRepository struct {
+	domain.UserRepository
+	ctx context.Context
+}
+
+// WithContext returns a copy of the contextRepository bound to 'ctx'
+func (r *contextRepository) WithContext(ctx context.Context) domain.UserRepository {
+	return &contextRepository{UserRepository: r.UserRepository, ctx: ctx}
+}
+
+// GetUser fails if the contextRepository's context is done
+func (r *contextRepository) GetUser(id int) (*domain.User, *mverr.MVError) {
+	if r.ctx != nil && r.ctx.Err() != nil {
+		return nil, &mverr.MVError{ErrCode: mverr.QueryTimeoutErrorCode, ErrMsg: mverr.QueryTimeoutErrorMsg, WrappedErr: r.ctx.Err()}
+	}
+	return r.UserRepository.GetUser(id)
+}
+
+func TestUserSvcBindsRequestContext(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	repo := memory.NewUserTable()
+	id, mvErr := repo.CreateUser(domain.User{AccountID: 1, Name: "davy jones", EMail: "davyj@gmail.com", Role: domain.Primary, Password: "pw"})
+	if mvErr != nil {
+		t.Fatalf("error %s was not expected creating the user", mvErr)
+	}
+	userSvc, err := NewUserSvc(&contextRepository{UserRepository: repo}, logger, 10, 10, 10)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting UserSvc", err)
+	}
+
+	if _, mvErr = userSvc.GetUser(context.Background(), id); mvErr != nil {
+		t.Fatalf("error %s was not expected getting the user", mvErr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	if _, mvErr = userSvc.GetUser(ctx, id); mvErr == nil || mvErr.ErrCode != mverr.QueryTimeoutErrorCode {
+		t.Errorf("expected error code %d once the request's deadline passed, got %v", mverr.QueryTimeoutErrorCode, mvErr)
+	}
+}
+
+// passwordRepo is a domain.UserRepository that records the users it's asked to store
+type passwordRepo struct {
+	domain.UserRepository
+	stored []domain.User
+}
+
+func (r *passwordRepo) CreateUser(user domain.User) (int, *mverr.MVError) {
+	r.stored = append(r.stored, user)
+	return len(r.stored), nil
+}
+
+func (r *passwordRepo) UpdateUser(user domain.User) *mverr.MVError {
+	r.stored = append(r.stored, user)
+	return nil
+}
+
+func (r *passwordRepo) GetCredentials(email string) (*domain.User, string, *mverr.MVError) {
+	return nil, "", nil
+}
+
+func (r *passwordRepo) UpsertUser(user domain.User) (int, domain.UpsertOutcome, *mverr.MVError) {
+	r.stored = append(r.stored, user)
+	return len(r.stored), domain.UpsertCreated, nil
+}
+
+func TestPasswordHashing(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	repo := &passwordRepo{}
+	queue := &fakeUserQueue{done: make(map[int]*domain.QueuedUser)}
+	userSvc, err := NewUserSvc(repo, logger, 10, 10, 10)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting UserSvc", err)
+	}
+	if err = userSvc.SetPasswordCost(auth.MaxPasswordCost + 1); err == nil {
+		t.Errorf("expected an error setting a cost greater than %d", auth.MaxPasswordCost)
+	}
+	if err = userSvc.SetPasswordCost(auth.MinPasswordCost); err != nil {
+		t.Fatalf("error %s was not expected setting the password cost", err)
+	}
+	userSvc.EnableWriteBehind(queue)
+
+	ctx := context.Background()
+	u := domain.User{ID: 1, AccountID: 1, Name: "davy jones", EMail: "davyj@gmail.com", Role: domain.Primary, Password: "pw"}
+	if _, mvErr := userSvc.CreateUser(ctx, u); mvErr != nil {
+		t.Fatalf("error %s was not expected creating the user", mvErr)
+	}
+	if mvErr := userSvc.UpdateUser(ctx, u); mvErr != nil {
+		t.Fatalf("error %s was not expected updating the user", mvErr)
+	}
+	if _, _, mvErr := userSvc.UpsertUser(ctx, u); mvErr != nil {
+		t.Fatalf("error %s was not expected upserting the user", mvErr)
+	}
+	for i, stored := range repo.stored {
+		if stored.Password == u.Password || !auth.CheckPassword(stored.Password, u.Password) {
+			t.Errorf("expected stored user %d to have the password's hash, got %q", i, stored.Password)
+		}
+	}
+	if repo.stored[0].Password == repo.stored[1].Password {
+		t.Errorf("expected each hash to be salted, got %q twice", repo.stored[0].Password)
+	}
+
+	// A password that happens to look like a hash is hashed too
+	lookalike := u
+	lookalike.Password = repo.stored[0].Password
+	if _, mvErr := userSvc.CreateUser(ctx, lookalike); mvErr != nil {
+		t.Fatalf("error %s was not expected creating the user", mvErr)
+	}
+	if stored := repo.stored[len(repo.stored)-1]; !auth.CheckPassword(stored.Password, lookalike.Password) {
+		t.Errorf("expected the stored user to have the hash of %q, got %q", lookalike.Password, stored.Password)
+	}
+
+	// A queued user's password is hashed when it's queued, and isn't hashed again when it's applied
+	if _, mvErr := userSvc.EnqueueUser(ctx, u); mvErr != nil {
+		t.Fatalf("error %s was not expected queueing the user", mvErr)
+	}
+	qu, mvErr := queue.ClaimNextUser()
+	if mvErr != nil {
+		t.Fatalf("error %s was not expected claiming the queued user", mvErr)
+	}
+	if !auth.CheckPassword(qu.User.Password, u.Password) {
+		t.Fatalf("expected the queued user to have the password's hash, got %q", qu.User.Password)
+	}
+	if _, mvErr = userSvc.ApplyQueuedUser(ctx, qu); mvErr != nil {
+		t.Fatalf("error %s was not expected applying the queued user", mvErr)
+	}
+	if applied := repo.stored[len(repo.stored)-1]; applied.Password != qu.User.Password {
+		t.Errorf("expected the queued user's hash %q to be stored, got %q", qu.User.Password, applied.Password)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"github.com/youngkin/mockvideo/internal/metrics"
+)
+
+// idlePollInterval is how long the WriteBehindWorker waits before checking an empty queue again
+const idlePollInterval = time.Second
+
+const (
+	// DefaultWriteBehindMaxAttempts is the number of attempts made to apply a queued user creation
+	// before it's dead-lettered, unless configured otherwise
+	DefaultWriteBehindMaxAttempts = 3
+	// DefaultWriteBehindRetryBackoff is how long the WriteBehindWorker waits after the first failed
+	// attempt before processing the queue again, unless configured otherwise. It doubles with each
+	// subsequent failed attempt.
+	DefaultWriteBehindRetryBackoff = 5 * time.Second
+)
+
+// WriteBehindProcessed counts the queued user creations applied by the WriteBehindWorker.
+// The 'result' label should be one of 'complete|failed|retried|deadlettered'.
+var WriteBehindProcessed = metrics.NewCounterVec(prometheus.CounterOpts{
+	Subsystem: "service",
+	Name:      "write_behind_processed_total",
+	Help:      "number of queued user creations processed",
+}, []string{"result"})
+
+// WriteBehindWorker applies queued user creations at a controlled rate. This smooths out
+// spikes in user creation requests so they don't overwhelm the database.
+type WriteBehindWorker struct {
+	queue    domain.UserQueueRepository
+	userSvc  UserSvcInterface
+	logger   logging.Logger
+	interval time.Duration
+	// Queued user creations that fail for reasons other than their content, e.g., the DB being
+	// unavailable, are attempted up to maxAttempts times before they're dead-lettered
+	maxAttempts  int
+	retryBackoff time.Duration
+	stopC        chan struct{}
+	doneC        chan struct{}
+}
+
+// NewWriteBehindWorker returns a WriteBehindWorker that takes user creations from 'queue' and
+// applies them via 'userSvc' at a rate of no more than 'ratePerSec' per second. 'ratePerSec'
+// must be greater than 0. Start() must be called to begin processing.
+func NewWriteBehindWorker(queue domain.UserQueueRepository, userSvc UserSvcInterface, logger logging.Logger, ratePerSec int) (*WriteBehindWorker, error) {
+	if queue == nil {
+		return nil, errors.New("non-nil domain.UserQueueRepository required")
+	}
+	if userSvc == nil {
+		return nil, errors.New("non-nil UserSvcInterface required")
+	}
+	if logger == nil {
+		return nil, errors.New("non-nil Logger required")
+	}
+	if ratePerSec < 1 {
+		return nil, errors.New("ratePerSec must be greater than 0")
+	}
+	return &WriteBehindWorker{
+		queue:        queue,
+		userSvc:      userSvc,
+		logger:       logger,
+		interval:     time.Second / time.Duration(ratePerSec),
+		maxAttempts:  DefaultWriteBehindMaxAttempts,
+		retryBackoff: DefaultWriteBehindRetryBackoff,
+		stopC:        make(chan struct{}),
+		doneC:        make(chan struct{}),
+	}, nil
+}
+
+// SetRetryPolicy replaces the default number of attempts made to apply a queued user creation,
+// DefaultWriteBehindMaxAttempts, and the backoff after the first failed attempt,
+// DefaultWriteBehindRetryBackoff. 'maxAttempts' must be greater than 0 and 'backoff' must not be
+// negative. It must be called before Start().
+func (w *WriteBehindWorker) SetRetryPolicy(maxAttempts int, backoff time.Duration) error {
+	if maxAttempts < 1 {
+		return errors.New("maxAttempts must be greater than 0")
+	}
+	if backoff < 0 {
+		return errors.New("backoff must not be negative")
+	}
+	w.maxAttempts = maxAttempts
+	w.retryBackoff = backoff
+	return nil
+}
+
+// Start begins processing queued user creations in a separate goroutine
+func (w *WriteBehindWorker) Start() {
+	go func() {
+		defer close(w.doneC)
+		for {
+			wait := w.processNext()
+
+			select {
+			case <-w.stopC:
+				return
+			case <-time.After(wait):
+			}
+		}
+	}()
+}
+
+// Stop stops processing queued user creations. It waits for an in-progress user creation
+// to complete before returning.
+func (w *WriteBehindWorker) Stop() {
+	close(w.stopC)
+	<-w.doneC
+}
+
+// processNext applies the next queued user creation, if any. It returns how long to wait before
+// processing the queue again.
+func (w *WriteBehindWorker) processNext() time.Duration {
+	qu, err := w.queue.ClaimNextUser()
+	if err != nil {
+		w.logError(err)
+		return idlePollInterval
+	}
+	if qu == nil {
+		return idlePollInterval
+	}
+
+	// The user creation and its completion are applied together, see UserSvc.ApplyQueuedUser
+	_, err = w.userSvc.ApplyQueuedUser(context.Background(), qu)
+	if err != nil {
+		return w.fail(qu, err)
+	}
+
+	WriteBehindProcessed.WithLabelValues(string(domain.QueueComplete)).Inc()
+	return w.interval
+}
+
+// fail records a failed attempt to apply 'qu'. User creations that can never succeed are failed,
+// others are retried until they've been attempted maxAttempts times and are then dead-lettered.
+// It returns how long to wait before processing the queue again.
+func (w *WriteBehindWorker) fail(qu *domain.QueuedUser, err *mverr.MVError) time.Duration {
+	attempts := qu.Attempts + 1
+	switch {
+	case isPermanentFailure(err.ErrCode):
+		WriteBehindProcessed.WithLabelValues(string(domain.QueueFailed)).Inc()
+		if err2 := w.queue.FailUser(qu.ID, err.ErrMsg); err2 != nil {
+			w.logError(err2)
+		}
+		return w.interval
+	case attempts < w.maxAttempts:
+		WriteBehindProcessed.WithLabelValues("retried").Inc()
+		if err2 := w.queue.RetryUser(qu.ID, err.ErrMsg); err2 != nil {
+			w.logError(err2)
+		}
+		// The retried user creation is the oldest pending one so it's claimed again next, backing
+		// off gives the cause of the failure, e.g., an unavailable DB, time to clear
+		return w.retryBackoff << uint(attempts-1)
+	default:
+		WriteBehindProcessed.WithLabelValues(string(domain.QueueDeadLettered)).Inc()
+		if err2 := w.queue.DeadLetterUser(qu.ID, err.ErrMsg); err2 != nil {
+			w.logError(err2)
+			return w.interval
+		}
+		DeadLetterDepth.Inc()
+		w.logger.WithFields(logging.Fields{
+			logging.Attempts:  attempts,
+			logging.ErrorCode: err.ErrCode,
+			logging.QueueID:   qu.ID,
+		}).Warn("queued user creation dead-lettered")
+		return w.interval
+	}
+}
+
+// isPermanentFailure returns true if an error with 'code' is caused by the content of a user
+// creation, i.e., retrying the user creation would fail the same way
+func isPermanentFailure(code mverr.ErrCode) bool {
+	switch code {
+	case mverr.DBInsertDuplicateUserErrorCode,
+		mverr.InvalidInsertErrorCode,
+		mverr.UserUnauthorizedErrorCode,
+		mverr.UserValidationErrorCode:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *WriteBehindWorker) logError(e *mverr.MVError) {
+	w.logger.WithFields(logging.Fields{
+		logging.ErrorCode:    e.ErrCode,
+		logging.ErrorDetail:  e.ErrDetail,
+		logging.WrappedError: e.WrappedErr,
+	}).Error(e.ErrMsg)
+}
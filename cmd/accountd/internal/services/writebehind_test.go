@@ -0,0 +1,328 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/eventbus"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// fakeUserRepo is a domain.UserRepository that only supports CreateUser. CreateUser fails with
+// 'createErr', if it's set, the first 'createFailures' times it's called or every time if
+// 'createFailures' is 0.
+type fakeUserRepo struct {
+	domain.UserRepository
+	createErr      *mverr.MVError
+	createFailures int
+	createCalls    int
+	created        domain.User
+}
+
+func (r *fakeUserRepo) CreateUser(user domain.User) (int, *mverr.MVError) {
+	r.createCalls++
+	if r.createErr != nil && (r.createFailures == 0 || r.createCalls <= r.createFailures) {
+		return 0, r.createErr
+	}
+	r.created = user
+	return 42, nil
+}
+
+// fakeUserQueue is an in-memory domain.UserQueueRepository
+type fakeUserQueue struct {
+	mu          sync.Mutex
+	pending     []*domain.QueuedUser
+	done        map[int]*domain.QueuedUser
+	completeErr *mverr.MVError
+}
+
+func (q *fakeUserQueue) EnqueueUser(user domain.User) (int, *mverr.MVError) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	id := len(q.pending) + len(q.done) + 1
+	q.pending = append(q.pending, &domain.QueuedUser{ID: id, Status: domain.QueuePending, User: user})
+	return id, nil
+}
+
+func (q *fakeUserQueue) ClaimNextUser() (*domain.QueuedUser, *mverr.MVError) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) == 0 {
+		return nil, nil
+	}
+	qu := q.pending[0]
+	q.pending = q.pending[1:]
+	qu.Status = domain.QueueProcessing
+	qu.PasswordHashed = true
+	q.done[qu.ID] = qu
+	return qu, nil
+}
+
+func (q *fakeUserQueue) CompleteUser(id int, userID int) *mverr.MVError {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.completeErr != nil {
+		return q.completeErr
+	}
+	q.done[id].Status = domain.QueueComplete
+	q.done[id].UserID = userID
+	return nil
+}
+
+func (q *fakeUserQueue) FailUser(id int, errMsg string) *mverr.MVError {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.done[id].Status = domain.QueueFailed
+	q.done[id].ErrMsg = errMsg
+	return nil
+}
+
+func (q *fakeUserQueue) RetryUser(id int, errMsg string) *mverr.MVError {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	qu := q.done[id]
+	qu.Status = domain.QueuePending
+	qu.Attempts++
+	qu.ErrMsg = errMsg
+	q.pending = append([]*domain.QueuedUser{qu}, q.pending...)
+	return nil
+}
+
+func (q *fakeUserQueue) DeadLetterUser(id int, errMsg string) *mverr.MVError {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.done[id].Status = domain.QueueDeadLettered
+	q.done[id].Attempts++
+	q.done[id].ErrMsg = errMsg
+	return nil
+}
+
+func (q *fakeUserQueue) ReplayUser(id int) (bool, *mverr.MVError) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	qu, ok := q.done[id]
+	if !ok || qu.Status != domain.QueueDeadLettered {
+		return false, nil
+	}
+	qu.Status = domain.QueuePending
+	qu.Attempts = 0
+	q.pending = append(q.pending, qu)
+	return true, nil
+}
+
+func (q *fakeUserQueue) GetQueuedUser(id int) (*domain.QueuedUser, *mverr.MVError) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	qu, ok := q.done[id]
+	if !ok {
+		return nil, nil
+	}
+	result := *qu
+	return &result, nil
+}
+
+func TestWriteBehindWorker(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	unavailableErr := &mverr.MVError{
+		ErrCode: mverr.DBUnavailableErrorCode,
+		ErrMsg:  mverr.DBUnavailableErrorMsg,
+	}
+
+	tcs := []struct {
+		testName         string
+		createErr        *mverr.MVError
+		createFailures   int
+		expectedStatus   domain.QueueStatus
+		expectedUserID   int
+		expectedAttempts int
+	}{
+		{
+			testName:       "testWriteBehindComplete",
+			expectedStatus: domain.QueueComplete,
+			expectedUserID: 42,
+		},
+		{
+			testName: "testWriteBehindFailed",
+			createErr: &mverr.MVError{
+				ErrCode: mverr.DBInsertDuplicateUserErrorCode,
+				ErrMsg:  mverr.DBInsertDuplicateUserErrorMsg,
+			},
+			expectedStatus: domain.QueueFailed,
+		},
+		{
+			testName:         "testWriteBehindRetried",
+			createErr:        unavailableErr,
+			createFailures:   2,
+			expectedStatus:   domain.QueueComplete,
+			expectedUserID:   42,
+			expectedAttempts: 2,
+		},
+		{
+			testName:         "testWriteBehindDeadLettered",
+			createErr:        unavailableErr,
+			expectedStatus:   domain.QueueDeadLettered,
+			expectedAttempts: 3,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			queue := &fakeUserQueue{done: make(map[int]*domain.QueuedUser)}
+			userSvc, err := NewUserSvc(&fakeUserRepo{createErr: tc.createErr, createFailures: tc.createFailures}, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			userSvc.EnableWriteBehind(queue)
+
+			queueID, err2 := userSvc.EnqueueUser(context.Background(), domain.User{AccountID: 1, Name: "porgy tirebiter"})
+			if err2 != nil {
+				t.Fatalf("error %s was not expected when queueing user", err2)
+			}
+
+			w, err := NewWriteBehindWorker(queue, userSvc, logger, 100)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting WriteBehindWorker", err)
+			}
+			if err = w.SetRetryPolicy(3, time.Millisecond); err != nil {
+				t.Fatalf("error %s was not expected when setting the retry policy", err)
+			}
+			w.Start()
+
+			var qu *domain.QueuedUser
+			deadline := time.Now().Add(time.Second)
+			for time.Now().Before(deadline) {
+				qu, err2 = userSvc.GetQueuedUser(context.Background(), queueID)
+				if err2 == nil && qu.Status != domain.QueueProcessing && qu.Status != domain.QueuePending {
+					break
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			w.Stop()
+
+			if qu == nil {
+				t.Fatalf("queued user %d was never processed", queueID)
+			}
+			if qu.Status != tc.expectedStatus {
+				t.Errorf("expected status %s, got %s", tc.expectedStatus, qu.Status)
+			}
+			if qu.UserID != tc.expectedUserID {
+				t.Errorf("expected user ID %d, got %d", tc.expectedUserID, qu.UserID)
+			}
+			if qu.Attempts != tc.expectedAttempts {
+				t.Errorf("expected %d failed attempts, got %d", tc.expectedAttempts, qu.Attempts)
+			}
+		})
+	}
+}
+
+// fakeUnitOfWork is a domain.UnitOfWork whose Repositories are 'repos'. It records whether
+// the last unit of work was committed.
+type fakeUnitOfWork struct {
+	repos     domain.Repositories
+	committed bool
+}
+
+func (uow *fakeUnitOfWork) Do(fn func(repos domain.Repositories) *mverr.MVError) *mverr.MVError {
+	err := fn(uow.repos)
+	uow.committed = err == nil
+	return err
+}
+
+func TestApplyQueuedUser(t *testing.T) {
+	logger := logging.Default()
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+
+	dbErr := &mverr.MVError{
+		ErrCode: mverr.DBUpSertErrorCode,
+		ErrMsg:  mverr.DBUpSertErrorMsg,
+	}
+
+	tcs := []struct {
+		testName          string
+		createErr         *mverr.MVError
+		completeErr       *mverr.MVError
+		expectedErr       *mverr.MVError
+		expectedUserID    int
+		expectedCommitted bool
+	}{
+		{
+			testName:          "testApplyQueuedUserCommitted",
+			expectedUserID:    42,
+			expectedCommitted: true,
+		},
+		{
+			testName:    "testApplyQueuedUserCreateFailed",
+			createErr:   dbErr,
+			expectedErr: dbErr,
+		},
+		{
+			testName:    "testApplyQueuedUserCompleteFailed",
+			completeErr: dbErr,
+			expectedErr: dbErr,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			queue := &fakeUserQueue{done: make(map[int]*domain.QueuedUser)}
+			userSvc, err := NewUserSvc(&fakeUserRepo{}, logger, 10, 10, 10)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting UserSvc", err)
+			}
+			userSvc.EnableWriteBehind(queue)
+
+			// The UnitOfWork's repositories, not the UserSvc's, must be used
+			txRepo := &fakeUserRepo{createErr: tc.createErr}
+			txQueue := &fakeUserQueue{done: make(map[int]*domain.QueuedUser), completeErr: tc.completeErr}
+			uow := &fakeUnitOfWork{repos: domain.Repositories{Users: txRepo, UserQueue: txQueue}}
+			if err = userSvc.SetUnitOfWork(uow); err != nil {
+				t.Fatalf("error %s was not expected when setting UnitOfWork", err)
+			}
+			// UserCreated is only published once the UnitOfWork commits
+			bus := eventbus.New()
+			defer bus.Close()
+			userSvc.SetEventBus(bus)
+			events, _ := bus.Subscribe("test", 1, eventbus.DropNewest, UserCreated)
+
+			queueID, err2 := txQueue.EnqueueUser(domain.User{AccountID: 1, Name: "porgy tirebiter"})
+			if err2 != nil {
+				t.Fatalf("error %s was not expected when queueing user", err2)
+			}
+			qu, _ := txQueue.ClaimNextUser()
+
+			userID, err2 := userSvc.ApplyQueuedUser(context.Background(), qu)
+			if err2 != tc.expectedErr {
+				t.Errorf("expected error %v, got %v", tc.expectedErr, err2)
+			}
+			if userID != tc.expectedUserID {
+				t.Errorf("expected user ID %d, got %d", tc.expectedUserID, userID)
+			}
+			if uow.committed != tc.expectedCommitted {
+				t.Errorf("expected committed %t, got %t", tc.expectedCommitted, uow.committed)
+			}
+			if published := len(events.C()) == 1; published != tc.expectedCommitted {
+				t.Errorf("expected UserCreated published %t, got %t", tc.expectedCommitted, published)
+			}
+			if tc.createErr == nil && txRepo.created.Name != qu.User.Name {
+				t.Errorf("expected user %s to be created in the unit of work, got %+v", qu.User.Name, txRepo.created)
+			}
+			if len(queue.done) != 0 {
+				t.Errorf("expected the UserSvc's queue to be unused, got %+v", queue.done)
+			}
+			if tc.expectedCommitted && txQueue.done[queueID].Status != domain.QueueComplete {
+				t.Errorf("expected status %s, got %s", domain.QueueComplete, txQueue.done[queueID].Status)
+			}
+		})
+	}
+}
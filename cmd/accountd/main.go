@@ -6,34 +6,42 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
 	"flag"
-	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
-	"os/signal"
 	"strconv"
 	"strings"
-	"syscall"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/juju/errors"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-	grpcuser "github.com/youngkin/mockvideo/cmd/accountd/grpc/users"
 	handlers "github.com/youngkin/mockvideo/cmd/accountd/http"
+	"github.com/youngkin/mockvideo/cmd/accountd/http/accounts"
+	"github.com/youngkin/mockvideo/cmd/accountd/http/admin"
+	"github.com/youngkin/mockvideo/cmd/accountd/http/respond"
 	"github.com/youngkin/mockvideo/cmd/accountd/http/users"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/app"
 	"github.com/youngkin/mockvideo/cmd/accountd/internal/config"
+	"github.com/youngkin/mockvideo/cmd/accountd/internal/protocol"
 	"github.com/youngkin/mockvideo/cmd/accountd/internal/services"
+	"github.com/youngkin/mockvideo/internal/accesslog"
+	"github.com/youngkin/mockvideo/internal/bodytimeout"
+	"github.com/youngkin/mockvideo/internal/canary"
 	"github.com/youngkin/mockvideo/internal/db"
-	userdb "github.com/youngkin/mockvideo/internal/db"
 	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/eventbus"
+	"github.com/youngkin/mockvideo/internal/httpclient"
+	"github.com/youngkin/mockvideo/internal/lifecycle"
+	"github.com/youngkin/mockvideo/internal/listener"
 	"github.com/youngkin/mockvideo/internal/logging"
+	"github.com/youngkin/mockvideo/internal/metrics"
+	"github.com/youngkin/mockvideo/internal/secrets"
 	"google.golang.org/grpc"
-
-	log "github.com/sirupsen/logrus"
 )
 
 /*
@@ -48,6 +56,9 @@ pertaining to:
 	i.	Uses 'interpolateParams=true' to avoid multiple round-trips when using placeholders (i.e., '?') in a
 		`db.Query()` or `db.Exec()` call
 	ii.	Uses 'parseTime=true' to allow unmarshaling DATE DATETIME directly into Golang time.Time variables.
+	iii.	Uses 'loc=UTC' so DATETIME values are written and read as UTC regardless of the host's time zone.
+	iv.	Both of the above are configurable, as are TLS, the character set, timeouts, and connecting
+		using a Unix domain socket, see 'db.BuildDSN'.
 */
 
 // TODO:
@@ -57,15 +68,41 @@ pertaining to:
 //	4.	TODO: Config parms (configMap?), monitor for changes restarting if necessary
 //	5.	TODO: ONGOING: Prometheus, instrument database calls
 
-func init() {
-	// PROMETHEUS NOTE:
-	// As metrics get defined, e.g., such as 'users.UserRqstDur', they must be
-	// added here. 'prometheus.MustRegister()' can only be called once at
-	// program initialization. Metrics should be defined in the packages that
-	// use them.
-	prometheus.MustRegister(users.UserRqstDur, db.DBRqstDur)
-	// Add Go module build info.
-	prometheus.MustRegister(prometheus.NewBuildInfoCollector())
+// registerMetrics registers accountd's metrics under the namespace and subsystem configured by
+// 'metricsNamespace' (metrics.DefaultNamespace by default) and 'metricsSubsystem' (none by default).
+// If 'metricsEnabled' is false nothing is registered and the metrics are disabled, updating them
+// does no work, see metrics.Disable. The names of the registered metrics are returned. A metric that's
+// already registered is kept rather than failing startup, see metrics.Register.
+//
+// PROMETHEUS NOTE:
+// As metrics get defined, e.g., such as 'users.UserRqstDur', they must be
+// added here. Metrics should be defined in the packages that use them, without
+// a Namespace.
+func registerMetrics(configs map[string]string) ([]string, error) {
+	if enabled, err := strconv.ParseBool(configs["metricsEnabled"]); err == nil && !enabled {
+		metrics.Disable()
+		return nil, nil
+	}
+	// Add Go module build info. Unlike accountd's metrics its name doesn't depend on the configuration.
+	if err := prometheus.Register(prometheus.NewBuildInfoCollector()); err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+			return nil, err
+		}
+	}
+	namespace, ok := configs["metricsNamespace"]
+	if !ok {
+		namespace = metrics.DefaultNamespace
+	}
+	subsystem := configs["metricsSubsystem"]
+	cs := []prometheus.Collector{users.UserRqstDur, accounts.AccountRqstDur, admin.AdminRqstDur, db.DBRqstDur, db.DBRowsReturned, db.DBResultBytes, db.ReadOnlyMode,
+		services.BulkheadInUse, services.BulkheadCapacity, services.BulkheadWaitDur, services.BulkBatchSize, services.BulkItemWaitDur, services.BulkItemDur,
+		services.WriteBehindProcessed, services.DeadLetterDepth, services.PendingUsersExpired, services.SearchIndexUpdates,
+		services.UsageTrackedAccounts, services.UsageEvictions, eventbus.EventsPublished, eventbus.EventsDropped, eventbus.Subscribers,
+		eventbus.SlowConsumersDisconnected, httpclient.DownstreamRqstDur, httpclient.BreakerOpen, respond.ClientRqsts, respond.DeprecatedRqsts, respond.JSONMarshalingFailures, handlers.UnknownResourceRqsts, accesslog.Rqsts, canary.RqstDur}
+	if err := metrics.Register(prometheus.DefaultRegisterer, namespace, subsystem, cs...); err != nil {
+		return nil, err
+	}
+	return metrics.Names(namespace, subsystem, cs...)
 }
 
 func main() {
@@ -75,188 +112,307 @@ func main() {
 	secretsDir := flag.String("secretsDir",
 		"/opt/mockvideo/accountd/secrets",
 		"specifies the location of the accountd secrets")
-	protocolType := flag.String("protocol", "http", "specifies whether the service will use http or grpc. Options are 'http' or 'grpc'.")
+	secretsKeyFile := flag.String("secretsKeyFile", "",
+		"if set, the file containing the age identities that decrypt encrypted secrets, e.g., one mounted by a KMS integration. "+
+			"Otherwise the identities are read from the "+secrets.KeyEnv+" environment variable.")
+	protocols := protocol.Set{protocol.HTTP}
+	flag.Var(&protocols, "protocol", "specifies the APIs the service serves, "+protocol.Usage+". "+
+		"When both are served gRPC is served on 'grpcPort' rather than 'port'.")
+	addrFileName := flag.String("addrFile", "",
+		"if set, the address accountd is listening on is written to this file once it's accepting connections. "+
+			"Useful with 'port=0', e.g., for tests and sidecars.")
+	env := flag.String("env", os.Getenv("ACCOUNTD_ENV"),
+		"specifies the environment, e.g., 'dev' or 'prod'. Its overlay, the configFile with an '.env' suffix, "+
+			"is merged over the configFile. Defaults to the ACCOUNTD_ENV environment variable.")
 	flag.Parse()
 
-	logger := logging.GetLogger().WithField(logging.Application, logging.User)
+	logger := logging.Default().WithFields(logging.Fields{logging.Application: logging.User})
 
 	//
 	// Get configuration
 	//
-	configFile, err := os.Open(*configFileName)
+	configFileNames, err := config.ProfileFileNames(*configFileName, *env)
 	if err != nil {
-		logger.WithFields(log.Fields{
+		logger.WithFields(logging.Fields{
 			logging.ConfigFileName: *configFileName,
+			logging.Environment:    *env,
 			logging.ErrorCode:      mverr.UnableToOpenConfigErrorCode,
 			logging.ErrorDetail:    err.Error(),
-		}).Fatal(mverr.UnableToOpenConfigMsg)
+		}).Error(mverr.UnableToOpenConfigMsg)
 		os.Exit(1)
 	}
 
-	configs, err := config.LoadConfig(configFile)
-	if err != nil {
-		logger.WithFields(log.Fields{
+	// The environment's overlay, if any, is merged over the base configuration
+	configs := map[string]string{}
+	for _, fileName := range configFileNames {
+		configFile, err := os.Open(fileName)
+		if err != nil {
+			logger.WithFields(logging.Fields{
+				logging.ConfigFileName: fileName,
+				logging.ErrorCode:      mverr.UnableToOpenConfigErrorCode,
+				logging.ErrorDetail:    err.Error(),
+			}).Error(mverr.UnableToOpenConfigMsg)
+			os.Exit(1)
+		}
+
+		overlay, err := config.LoadConfig(configFile)
+		configFile.Close()
+		if err != nil {
+			logger.WithFields(logging.Fields{
+				logging.ConfigFileName: fileName,
+				logging.ErrorCode:      mverr.UnableToLoadConfigErrorCode,
+				logging.ErrorDetail:    err.Error(),
+			}).Error(mverr.UnableToLoadConfigMsg)
+			os.Exit(1)
+		}
+		configs = config.Merge(configs, overlay)
+	}
+
+	// All of the configuration's problems are reported at once rather than as each item is used
+	if err = app.ConfigSchema.Validate(configs); err != nil {
+		logger.WithFields(logging.Fields{
 			logging.ConfigFileName: *configFileName,
+			logging.Environment:    *env,
 			logging.ErrorCode:      mverr.UnableToLoadConfigErrorCode,
 			logging.ErrorDetail:    err.Error(),
-		}).Fatal(mverr.UnableToLoadConfigMsg)
+		}).Error(mverr.UnableToLoadConfigMsg)
 		os.Exit(1)
 	}
 
-	secrets, err := config.LoadSecrets(*secretsDir)
+	secretsIdentities, err := secrets.LoadIdentities(os.Getenv(secrets.KeyEnv), *secretsKeyFile)
 	if err != nil {
-		logger.WithFields(log.Fields{
+		logger.WithFields(logging.Fields{
+			logging.ConfigFileName: *secretsKeyFile,
+			logging.ErrorCode:      mverr.UnableToLoadSecretsErrorCode,
+			logging.ErrorDetail:    err.Error(),
+		}).Error(mverr.UnableToLoadSecretsMsg)
+		os.Exit(1)
+	}
+	secrets, err := config.LoadSecrets(*secretsDir, secretsIdentities)
+	if err != nil {
+		logger.WithFields(logging.Fields{
 			logging.ConfigFileName: *secretsDir,
 			logging.ErrorCode:      mverr.UnableToLoadSecretsErrorCode,
 			logging.ErrorDetail:    err.Error(),
-		}).Fatal(mverr.UnableToLoadSecretsMsg)
+		}).Error(mverr.UnableToLoadSecretsMsg)
 		os.Exit(1)
 	}
 
+	level := logging.InfoLevel
 	loglevel, ok := configs["logLevel"]
 	if !ok {
-		logger.Warnf("Log level unavailable, defaulting to %s", log.GetLevel().String())
+		logger.Warnf("Log level unavailable, defaulting to %s", level)
 	} else {
-		level, err := strconv.Atoi(loglevel)
-		if err != nil {
-			logger.Warnf("Log level <%s> invalid, defaulting to %s", loglevel, log.GetLevel().String())
+		l, err := strconv.Atoi(loglevel)
+		if err != nil || l < int(logging.PanicLevel) || l > int(logging.TraceLevel) {
+			logger.Warnf("Log level <%s> invalid, defaulting to %s", loglevel, level)
 		} else {
-			log.SetLevel(log.Level(level))
+			level = logging.Level(l)
 		}
 	}
 
-	logger.WithFields(log.Fields{
+	// The logging backend is selected by the 'logger' configuration. Backends other than logrus
+	// must be enabled using the corresponding build tag, e.g., 'go build -tags zap'.
+	backend, ok := configs["logger"]
+	if !ok {
+		backend = logging.Logrus
+	}
+	l, err := logging.New(backend, level)
+	if err != nil {
+		logger.Warnf("%s, defaulting to %s", err, logging.Logrus)
+		l, _ = logging.New(logging.Logrus, level)
+	}
+	logger = l.WithFields(logging.Fields{logging.Application: logging.User})
+
+	logger.WithFields(logging.Fields{
 		logging.ConfigFileName: *configFileName,
+		logging.Environment:    *env,
 		logging.SecretsDirName: *secretsDir,
 	}).Info("accountd service starting")
 
-	//
-	// Setup DB connection
-	//
-	connStr, err := getDBConnectionStr(configs, secrets)
+	metricNames, err := registerMetrics(configs)
 	if err != nil {
-		logger.WithFields(log.Fields{
-			logging.ErrorCode:   mverr.UnableToGetDBConnStrErrorCode,
+		logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.UnableToGetConfigErrorCode,
 			logging.ErrorDetail: err.Error(),
-		}).Fatal(mverr.UnableToGetDBConnStrMsg)
+		}).Error(mverr.UnableToGetConfigMsg)
 		os.Exit(1)
 	}
+	logger.Debugf("registered %d metrics: %s", len(metricNames), strings.Join(metricNames, ", "))
 
-	db, err := sql.Open("mysql", connStr)
-	if err != nil {
-		logger.WithFields(log.Fields{
-			logging.ErrorCode:   mverr.UnableToOpenDBConnErrorCode,
-			logging.ErrorDetail: err.Error(),
-			logging.DBHost:      configs["dbHost"],
-			logging.DBPort:      configs["dbPort"],
-			logging.DBName:      configs["dbName"],
-		}).Fatal("OPEN: " + mverr.UnableToOpenDBConnMsg)
-		os.Exit(1)
+	//
+	// Setup DB connection, there isn't one in demo mode
+	//
+	cfg := app.NewConfig(configs, secrets, logger)
+	if !cfg.TracingEnabled {
+		httpclient.SetTracer(httpclient.NoopTracer{})
+	}
+	var db *sql.DB
+	if cfg.DemoMode {
+		logger.Info("demo mode enabled, serving synthetic users without a DB, changes are rejected")
+	} else {
+		db = openDB(configs, secrets, logger)
 	}
-	defer db.Close()
 
-	err = db.Ping()
+	//
+	// Setup Repositories, UseCases, and endpoint handlers
+	//
+	cfg, protections, mvErr := app.Harden(cfg, secrets["dbpassword"], protocols)
+	if mvErr != nil {
+		logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mvErr.ErrCode,
+			logging.ErrorDetail: mvErr.ErrDetail,
+		}).Error(mvErr.ErrMsg)
+		os.Exit(1)
+	}
+	for _, p := range protections {
+		logger.Infof("production mode: %s", p)
+	}
+	tlsCfg, err := cfg.TLSConfig()
 	if err != nil {
-		logger.WithFields(log.Fields{
-			logging.ErrorCode:   mverr.UnableToOpenDBConnErrorCode,
+		logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.UnableToLoadSecretsErrorCode,
 			logging.ErrorDetail: err.Error(),
-			logging.DBHost:      configs["dbHost"],
-			logging.DBPort:      configs["dbPort"],
-			logging.DBName:      configs["dbName"],
-		}).Fatal("PING: " + mverr.UnableToOpenDBConnMsg)
+		}).Error(mverr.UnableToLoadSecretsMsg)
 		os.Exit(1)
 	}
 
-	maxBulkOps := 10
-	maxBulkOpsStr, ok := configs["maxConcurrentBulkOperations"]
-	if !ok {
-		logger.Info("max bulk operations configuration unavailable (configs[maxConcurrentBulkOperations]), defaulting to 10")
-	} else {
-		maxBulkOps, err = strconv.Atoi(maxBulkOpsStr)
-		if err != nil {
-			logger.Warnf("maxConcurrentBulkOperations <%s> invalid, defaulting to %d", maxBulkOpsStr, maxBulkOps)
-		}
+	a, mvErr := app.New(cfg, db, logger, app.Overrides{})
+	if mvErr != nil {
+		logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mvErr.ErrCode,
+			logging.ErrorDetail: mvErr.ErrDetail,
+		}).Error(mvErr.ErrMsg)
+		os.Exit(1)
+	}
+	if a.Impersonations != nil {
+		logger.Infof("impersonation enabled, tokens are valid for %s", cfg.ImpersonationTTL)
+	}
+	if a.WriteBehindWorker != nil {
+		logger.Infof("write-behind mode enabled, applying at most %d queued user creations per second", cfg.WriteBehindRate)
+	}
+	if a.UserIndex != nil {
+		logger.Infof("user searches enabled, mirroring users to search index %s at %s", cfg.SearchIndex, cfg.SearchURL)
 	}
 
 	//
-	// Setup Repositories and UseCases
+	// Components are registered with the lifecycle manager as they're started, dependencies first,
+	// so they're stopped in dependency order on shutdown: the server, once it has drained, then the
+	// background workers, and finally the DB connection pool.
 	//
-	userTable, err := userdb.NewTable(db)
+	lc, err := lifecycle.NewManager(cfg.ShutdownTimeout, logger)
 	if err != nil {
-		logger.WithFields(log.Fields{
-			logging.ErrorCode:   mverr.UnableToCreateRepositoryErrorCode,
-			logging.ErrorDetail: "unable to create a userdb.Table instance",
-		}).Fatal(mverr.UnableToCreateRepositoryMsg)
+		logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.UnableToGetConfigErrorCode,
+			logging.ErrorDetail: err.Error(),
+		}).Error(mverr.UnableToGetConfigMsg)
 		os.Exit(1)
 	}
-	userSvc, err := services.NewUserSvc(userTable, logger, maxBulkOps)
-	if err != nil {
-		logger.WithFields(log.Fields{
-			logging.ErrorCode:   mverr.UnableToCreateUserSvcErrorCode,
-			logging.ErrorDetail: "unable to create a services.UserUseCase instance",
-		}).Fatal(mverr.UnableToCreateUserSvcMsg)
-		os.Exit(1)
+	if db != nil {
+		lc.Register("DB connection pool", func(ctx context.Context) error { return db.Close() })
 	}
+	a.Start(lc)
 
 	//
 	// Setup endpoints and start service
 	//
-	port, ok := configs["port"]
-	if !ok {
-		logger.Info("port configuration unavailable (configs[port]), defaulting to 5000")
-		port = "5000"
+	port, err := listenPort(configs, "port", logger)
+	if err != nil {
+		logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.UnableToGetConfigErrorCode,
+			logging.ErrorDetail: err.Error(),
+		}).Error(mverr.UnableToGetConfigMsg)
+		os.Exit(1)
+	}
+	// Listeners in addition to 'port', e.g., a Unix domain socket for a sidecar proxy
+	extraListeners, err := listener.ListenAll(configs["listen"], socketMode(configs, logger))
+	if err != nil {
+		logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.UnableToGetConfigErrorCode,
+			logging.ErrorDetail: err.Error(),
+		}).Error(mverr.UnableToGetConfigMsg)
+		os.Exit(1)
+	}
+	for _, ln := range extraListeners {
+		logger.WithFields(logging.Fields{
+			logging.Address: ln.Addr().Network() + "://" + ln.Addr().String(),
+		}).Info("accountd listening on additional address")
 	}
-	port = ":" + port
 
-	switch *protocolType {
-	case "http":
-		s, err := startHTTPServer(userSvc, logger, maxBulkOps, port)
+	if protocols.Has(protocol.HTTP) {
+		// Responses to 'GET /users/changes' can take up to ChangesWait to be written
+		s, addr, err := startHTTPServer(a.HTTPHandler, logger, port, httpWriteTimeout+cfg.ChangesWait, tlsCfg, extraListeners)
 		if err != nil {
-			logger.WithFields(log.Fields{
+			logger.WithFields(logging.Fields{
 				logging.ErrorCode:   mverr.UnableToCreateHTTPHandlerErrorCode,
 				logging.ErrorDetail: err.Error(),
-			}).Fatal(mverr.UnableToCreateHTTPHandlerMsg)
+				logging.Port:        port,
+			}).Error(mverr.UnableToCreateHTTPHandlerMsg)
 			os.Exit(1)
 		}
-		logger.WithFields(log.Fields{
+		publishAddr(addr, *addrFileName, logger)
+		logger.WithFields(logging.Fields{
 			logging.ConfigFileName: *configFileName,
 			logging.SecretsDirName: *secretsDir,
 			logging.Port:           port,
-			logging.LogLevel:       log.GetLevel().String(),
+			logging.Address:        addr.String(),
+			logging.LogLevel:       level.String(),
 			logging.DBHost:         configs["dbHost"],
 			logging.DBPort:         configs["dbPort"],
 			logging.DBName:         configs["dbName"],
+			logging.TLS:            tlsCfg != nil,
 		}).Info("accountd HTTP service running")
+		lc.Register("HTTP server", s.Shutdown)
+		// Stopped before the HTTP server, ending long-polls so they don't delay its shutdown
+		lc.Register("change log", lifecycle.Func(a.ChangeLog.Close))
+	}
 
-		handleTermSignalHTTP(s, logger, 10)
-
-	case "grpc":
-		s, err := startGRPCServer(userSvc, logger, maxBulkOps, port)
+	if protocols.Has(protocol.GRPC) {
+		// When both are served the HTTP server has 'port', the extra listeners, and the address file
+		grpcPort, grpcListeners, grpcAddrFileName := port, extraListeners, *addrFileName
+		if protocols.Has(protocol.HTTP) {
+			grpcPort, err = listenPort(configs, "grpcPort", logger)
+			if err != nil {
+				logger.WithFields(logging.Fields{
+					logging.ErrorCode:   mverr.UnableToGetConfigErrorCode,
+					logging.ErrorDetail: err.Error(),
+				}).Error(mverr.UnableToGetConfigMsg)
+				os.Exit(1)
+			}
+			grpcListeners, grpcAddrFileName = nil, ""
+		}
+		addr, err := startGRPCServer(a.GRPCServer, logger, grpcPort, grpcListeners)
 		if err != nil {
-			logger.WithFields(log.Fields{
+			logger.WithFields(logging.Fields{
 				logging.ErrorCode:   mverr.UnableToCreateRPCServerErrorCode,
 				logging.ErrorDetail: err.Error(),
-			}).Fatal(mverr.UnableToCreateRPCServerErrorMsg)
+				logging.Port:        grpcPort,
+			}).Error(mverr.UnableToCreateRPCServerErrorMsg)
 			os.Exit(1)
 		}
-		logger.WithFields(log.Fields{
+		publishAddr(addr, grpcAddrFileName, logger)
+		logger.WithFields(logging.Fields{
 			logging.ConfigFileName: *configFileName,
 			logging.SecretsDirName: *secretsDir,
-			logging.Port:           port,
-			logging.LogLevel:       log.GetLevel().String(),
+			logging.Port:           grpcPort,
+			logging.Address:        addr.String(),
+			logging.LogLevel:       level.String(),
 			logging.DBHost:         configs["dbHost"],
 			logging.DBPort:         configs["dbPort"],
 			logging.DBName:         configs["dbName"],
+			logging.TLS:            tlsCfg != nil,
 		}).Info("accountd gRPC service running")
+		lc.Register("gRPC server", gracefulStopFunc(a.GRPCServer))
+	}
 
-		handleTermSignalGRPC(s, logger)
+	// Registered after the servers so that they report they're no longer ready, and load balancers
+	// have DrainDelay to stop routing new requests to them, before they're stopped
+	lc.Register("drain", a.Drain.Stop)
 
-	default:
-		logger.WithFields(log.Fields{
-			logging.ErrorCode:   mverr.InvalidProtocolTypeErrorCode,
-			logging.ErrorDetail: fmt.Sprintf("invalid protocolType, %s, provided", *protocolType),
-		}).Fatal(mverr.InvalidProtocolTypeErrorMsg)
-		os.Exit(1)
+	if err := lc.Wait(context.Background()); err != nil {
+		logger.Warnf("accountd shut down with error: %s", err)
+	} else {
+		logger.Info("accountd stopped")
 	}
 }
 
@@ -264,141 +420,236 @@ func main() {
 // Helper funcs
 //
 
-// handleTermSignalHTTP provides a mechanism to catch SIGTERMs and gracefully
-// shutdown the HTTP service endpoint.
-func handleTermSignalHTTP(s *http.Server, logger *log.Entry, timeout int) {
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
-
-	<-sigs
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
-	defer cancel()
-
-	logger.Infof("Server shutting down with timeout: %d", timeout)
-
-	if err := s.Shutdown(ctx); err != nil {
-		logger.Warnf("Server shutting down with error: %s", err)
-	} else {
-		logger.Info("Server stopped")
+// gracefulStopFunc returns a lifecycle.StopFunc that gracefully stops 's', waiting for in-progress
+// RPCs to complete. If they don't complete before the shutdown timeout 's' is stopped immediately.
+func gracefulStopFunc(s *grpc.Server) lifecycle.StopFunc {
+	return func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			s.Stop()
+			return ctx.Err()
+		}
 	}
-
 }
 
-// handleTermSignalGRPC provides a mechanism to catch SIGTERMs and gracefully
-// shutdown the gRPC service endpoint.
-func handleTermSignalGRPC(s *grpc.Server, logger *log.Entry) {
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
-
-	<-sigs
+// openDB opens the DB connection pool described by 'configs' and 'secrets' and verifies the DB is
+// reachable. accountd exits if it isn't.
+func openDB(configs, secrets map[string]string, logger logging.Logger) *sql.DB {
+	connStr, err := getDBConnectionStr(configs, secrets)
+	if err != nil {
+		logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.UnableToGetDBConnStrErrorCode,
+			logging.ErrorDetail: err.Error(),
+		}).Error(mverr.UnableToGetDBConnStrMsg)
+		os.Exit(1)
+	}
 
-	logger.Info("Server shutting down")
+	db, err := sql.Open("mysql", connStr)
+	if err != nil {
+		logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.UnableToOpenDBConnErrorCode,
+			logging.ErrorDetail: err.Error(),
+			logging.DBHost:      configs["dbHost"],
+			logging.DBPort:      configs["dbPort"],
+			logging.DBName:      configs["dbName"],
+		}).Error("OPEN: " + mverr.UnableToOpenDBConnMsg)
+		os.Exit(1)
+	}
 
-	s.GracefulStop()
-	logger.Info("Server stopped")
+	err = db.Ping()
+	if err != nil {
+		logger.WithFields(logging.Fields{
+			logging.ErrorCode:   mverr.UnableToOpenDBConnErrorCode,
+			logging.ErrorDetail: err.Error(),
+			logging.DBHost:      configs["dbHost"],
+			logging.DBPort:      configs["dbPort"],
+			logging.DBName:      configs["dbName"],
+		}).Error("PING: " + mverr.UnableToOpenDBConnMsg)
+		os.Exit(1)
+	}
+	return db
 }
 
+// getDBConnectionStr returns the MySQL DSN for the DB credentials in 'secrets' and the 'db*' items
+// in 'configs', see db.BuildDSN. The 'dbCACert' secret, if present, is the CA that verifies the DB
+// server's certificate when 'dbTLS' is 'true'.
 func getDBConnectionStr(configs, secrets map[string]string) (string, error) {
-	// E.g., "username:userpassword@tcp(10.0.0.100:3306)/mockvideo?interpolateParams=true"
-	var sb strings.Builder
-
+	// E.g., "username:userpassword@tcp(10.0.0.100:3306)/mockvideo?interpolateParams=true&parseTime=true"
 	dbuser, ok := secrets["dbuser"]
 	if !ok {
 		return "", errors.NotAssignedf("DB user name, identified by 'dbuser', not found in secrets")
 	}
-	sb.WriteString(dbuser)
-	sb.WriteString(":")
 	dbpassword, ok := secrets["dbpassword"]
 	if !ok {
 		return "", errors.NotAssignedf("DB user password, identified by 'dbpassword', not found in secrets")
 	}
-	sb.WriteString(dbpassword)
-	sb.WriteString("@tcp(")
 
-	dbHost, ok := configs["dbHost"]
-	if !ok {
-		return "", errors.NotAssignedf("DB hostname/address, identified by 'dbHost', not found in configuration")
+	opts := db.DSNOptions{
+		User:     dbuser,
+		Password: dbpassword,
+		Host:     configs["dbHost"],
+		Socket:   configs["dbSocket"],
+		Name:     configs["dbName"],
+		TLS:      dbConfig(configs, "dbTLS"),
+		TLSCA:    secrets["dbCACert"],
+		Charset:  configs["dbCharset"],
 	}
-	sb.WriteString(dbHost)
-	sb.WriteString(":")
-
-	dbPort, ok := configs["dbPort"]
-	if !ok {
-		return "", errors.NotAssignedf("DB port, identified by 'dbPort', not found in configuration")
+	var err error
+	if dbPort, ok := configs["dbPort"]; ok {
+		if opts.Port, err = strconv.Atoi(dbPort); err != nil {
+			return "", errors.NotValidf("DB port, identified by 'dbPort', <%s>", dbPort)
+		}
+	}
+	for key, timeout := range map[string]*time.Duration{
+		"dbTimeoutMillis":      &opts.Timeout,
+		"dbReadTimeoutMillis":  &opts.ReadTimeout,
+		"dbWriteTimeoutMillis": &opts.WriteTimeout,
+	} {
+		millis, err := strconv.Atoi(dbConfig(configs, key))
+		if err != nil {
+			return "", errors.NotValidf("DB timeout, identified by '%s', <%s>", key, configs[key])
+		}
+		*timeout = time.Duration(millis) * time.Millisecond
+	}
+	if opts.ParseTime, err = strconv.ParseBool(dbConfig(configs, "dbParseTime")); err != nil {
+		return "", errors.NotValidf("'dbParseTime' <%s>", configs["dbParseTime"])
+	}
+	if opts.InterpolateParams, err = strconv.ParseBool(dbConfig(configs, "dbInterpolateParams")); err != nil {
+		return "", errors.NotValidf("'dbInterpolateParams' <%s>", configs["dbInterpolateParams"])
 	}
-	sb.WriteString(dbPort)
 
-	sb.WriteString(")/")
+	return db.BuildDSN(opts)
+}
 
-	dbName, ok := configs["dbName"]
+// dbConfig returns the configuration item identified by 'key', or its default if it isn't configured
+func dbConfig(configs map[string]string, key string) string {
+	if val, ok := configs[key]; ok {
+		return val
+	}
+	k, _ := app.ConfigSchema.Key(key)
+	return k.Default
+}
+
+// listenPort returns the address, e.g., ':5000', of the port identified by the 'key' configuration,
+// e.g., 'port'. Port 0 lets the OS choose an available port, see publishAddr for how to discover it.
+func listenPort(configs map[string]string, key string, logger logging.Logger) (string, error) {
+	port, ok := configs[key]
 	if !ok {
-		return "", errors.NotAssignedf("DB Name, identified by 'dbName', not found in configuration")
+		k, _ := app.ConfigSchema.Key(key)
+		logger.Infof("%s configuration unavailable (configs[%s]), defaulting to %s", key, key, k.Default)
+		port = k.Default
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 0 || p > 65535 {
+		return "", errors.Errorf("invalid %s <%s>, must be between 0 and 65535", key, port)
 	}
-	sb.WriteString(dbName)
+	return ":" + port, nil
+}
 
-	sb.WriteString("?interpolateParams=true")
+// publishAddr makes the address accountd is listening on, 'addr', available to other processes
+// by writing it to 'addrFileName'. Nothing is written if 'addrFileName' is empty. The file is
+// written atomically so readers never see a partial address.
+func publishAddr(addr net.Addr, addrFileName string, logger logging.Logger) {
+	if addrFileName == "" {
+		return
+	}
 
-	return sb.String(), nil
+	tmp := addrFileName + ".tmp"
+	err := ioutil.WriteFile(tmp, []byte(addr.String()), 0644)
+	if err == nil {
+		err = os.Rename(tmp, addrFileName)
+	}
+	if err != nil {
+		logger.WithFields(logging.Fields{
+			logging.Address:     addr.String(),
+			logging.ErrorDetail: err.Error(),
+		}).Warnf("unable to write listen address to %s", addrFileName)
+	}
 }
 
-func startHTTPServer(userSvc *services.UserSvc, logger *log.Entry, maxBulkOps int, port string) (*http.Server, error) {
-	usersHandler, err := users.NewUserHandler(userSvc, logger, maxBulkOps)
-	if err != nil {
-		return nil, err
+// socketMode returns the file mode of Unix domain sockets from the 'listenSocketMode' configuration,
+// an octal value like '0660'
+func socketMode(configs map[string]string, logger logging.Logger) os.FileMode {
+	modeStr, ok := configs["listenSocketMode"]
+	if !ok {
+		return listener.DefaultSocketMode
 	}
+	mode, err := strconv.ParseUint(modeStr, 8, 32)
+	if err != nil || os.FileMode(mode)&^os.ModePerm != 0 {
+		logger.Warnf("listenSocketMode <%s> invalid, defaulting to %#o", modeStr, listener.DefaultSocketMode)
+		return listener.DefaultSocketMode
+	}
+	return os.FileMode(mode)
+}
 
-	healthHandler := http.HandlerFunc(handlers.HealthFunc)
+// httpWriteTimeout is the time allowed to write an HTTP response, excluding any long-poll wait
+const httpWriteTimeout = 5 * time.Second
 
-	mux := http.NewServeMux()
-	mux.Handle("/users", usersHandler)  // Desired to prevent redirects. Can remove if redirects for '/users/' are OK
-	mux.Handle("/users/", usersHandler) // Required to properly route requests to '/users/{id}. Don't understand why the above route isn't sufficient
-	mux.Handle("/accountdhealth", healthHandler)
-	mux.Handle("/metrics", promhttp.Handler())
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		logger.WithFields(log.Fields{
-			logging.ErrorCode: mverr.MalformedURLErrorCode,
-			logging.Path:      r.URL.Path,
-		}).Error(mverr.MalformedURLMsg)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(mverr.MalformedURLMsg))
-	})
+// startHTTPServer starts an HTTP server for 'handler' listening on 'port' and on the 'extra'
+// listeners. Responses must be written within 'writeTimeout'. The address the server is
+// listening on for 'port' is returned, it differs from 'port' when 'port' is ':0'. All of the
+// listeners serve TLS if 'tlsCfg' isn't nil.
+func startHTTPServer(handler http.Handler, logger logging.Logger, port string, writeTimeout time.Duration, tlsCfg *tls.Config, extra []net.Listener) (*http.Server, net.Addr, error) {
+	ln, err := net.Listen("tcp", port)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	s := &http.Server{
-		Addr:              port,
-		Handler:           mux,
+		Addr:              ln.Addr().String(),
+		Handler:           handler,
 		ReadHeaderTimeout: 5 * time.Second,
-		WriteTimeout:      5 * time.Second,
+		WriteTimeout:      writeTimeout,
+		TLSConfig:         tlsCfg,
+		// Lets bodytimeout.Middleware unblock reads of request bodies that take too long
+		ConnContext: bodytimeout.ConnContext,
 	}
 
-	go func() {
-		if err := s.ListenAndServe(); err != http.ErrServerClosed {
-			// TODO: improve logging (e.g., 'WithFields...')
-			logger.Fatal(err)
-		}
-	}()
+	// Shutdown closes all of the listeners
+	for _, l := range append([]net.Listener{ln}, extra...) {
+		go func(l net.Listener) {
+			serve := s.Serve
+			if tlsCfg != nil {
+				// The certificate and key are already in s.TLSConfig
+				serve = func(l net.Listener) error { return s.ServeTLS(l, "", "") }
+			}
+			if err := serve(l); err != http.ErrServerClosed {
+				// TODO: improve logging (e.g., 'WithFields...')
+				logger.Error(err)
+				os.Exit(1)
+			}
+		}(l)
+	}
 
-	return s, nil
+	return s, ln.Addr(), nil
 }
 
-func startGRPCServer(userSvc *services.UserSvc, logger *log.Entry, maxBulkOps int, port string) (*grpc.Server, error) {
-	usersServer, err := grpcuser.NewUserServer(userSvc, logger)
+// startGRPCServer starts serving 's' on 'port' and on the 'extra' listeners. The address the server
+// is listening on for 'port' is returned, it differs from 'port' when 'port' is ':0'.
+func startGRPCServer(s *grpc.Server, logger logging.Logger, port string, extra []net.Listener) (net.Addr, error) {
 	conn, err := net.Listen("tcp", port)
 	if err != nil {
 		return nil, err
 	}
 
-	s := grpc.NewServer()
-	grpcuser.RegisterUserServerServer(s, usersServer)
-
-	go func() {
-		defer conn.Close()
+	for _, l := range append([]net.Listener{conn}, extra...) {
+		go func(l net.Listener) {
+			defer l.Close()
 
-		if err := s.Serve(conn); err != nil {
-			// TODO: improve logging (e.g., 'WithFields...')
-			logger.Fatal(err)
-		}
-	}()
+			if err := s.Serve(l); err != nil {
+				// TODO: improve logging (e.g., 'WithFields...')
+				logger.Error(err)
+				os.Exit(1)
+			}
+		}(l)
+	}
 
-	return s, nil
+	return conn.Addr(), nil
 }
@@ -17,8 +17,61 @@ CREATE TABLE user (
     # role: 1 - admin, 2 - unrestricted, 3 - restricted
     role INT,
     password VARCHAR(255),
+    #
+    # status: pending - awaiting activation, active - activated
+    status VARCHAR(16) NOT NULL DEFAULT 'active',
+    activationToken VARCHAR(64),
+    activationExpiry DATETIME,
+    #
+    # createdAt and updatedAt are set by the application, the defaults cover rows inserted directly (e.g., test data)
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updatedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    #
+    # lastLogin is NULL until the user first logs in, lastModifiedBy identifies the caller that last created
+    # or updated the user, e.g., 'user:42' or 'apikey:billing'
+    lastLogin DATETIME,
+    lastModifiedBy VARCHAR(255),
     PRIMARY KEY (id),
-    UNIQUE KEY (email)
+    UNIQUE KEY (email),
+    INDEX (status, activationExpiry)
+);
+
+# userCreateQueue holds user creations accepted in write-behind mode that
+# have not yet been applied to the user table.
+DROP TABLE IF EXISTS userCreateQueue;
+CREATE TABLE userCreateQueue (
+    id INT AUTO_INCREMENT,
+    accountID INT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    role INT,
+    password VARCHAR(255),
+    #
+    # status: pending, processing, complete, failed, deadlettered
+    status VARCHAR(16) NOT NULL,
+    # attempts is the number of failed attempts to create the user
+    attempts INT NOT NULL DEFAULT 0,
+    # userID is the id of the created user once status is complete
+    userID INT,
+    errMsg VARCHAR(255),
+    PRIMARY KEY (id),
+    INDEX (status, id)
+);
+
+# deadLetter records asynchronous work that failed too many times to be retried
+# automatically. It can be replayed via 'POST /admin/deadletters/{id}/replay'.
+DROP TABLE IF EXISTS deadLetter;
+CREATE TABLE deadLetter (
+    id INT AUTO_INCREMENT,
+    #
+    # source: usercreate - sourceID is the id of a userCreateQueue row
+    source VARCHAR(32) NOT NULL,
+    sourceID INT NOT NULL,
+    attempts INT NOT NULL,
+    errMsg VARCHAR(255),
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY (source, sourceID)
 );
 
 # account is the high level information about a customer
@@ -35,6 +88,32 @@ CREATE TABLE account (
     UNIQUE KEY (email)
 );
 
+# billingContact is who's billed for an account, an account has at most one
+DROP TABLE IF EXISTS billingContact;
+CREATE TABLE billingContact (
+    accountID INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    phone VARCHAR(10),
+    addressID INT NOT NULL,
+    PRIMARY KEY (accountID)
+);
+
+# address is a postal address, e.g., of an account's billing contact
+DROP TABLE IF EXISTS address;
+CREATE TABLE address (
+    id INT AUTO_INCREMENT,
+    street VARCHAR(255) NOT NULL,
+    street2 VARCHAR(255),
+    city VARCHAR(255) NOT NULL,
+    region VARCHAR(255),
+    # postalCode is validated against the country's format, if it has one
+    postalCode VARCHAR(16),
+    # country is an ISO 3166-1 alpha-2 country code, e.g., US
+    country CHAR(2) NOT NULL,
+    PRIMARY KEY (id)
+);
+
 # bundle represents a group of one or more products. 
 DROP TABLE IF EXISTS bundle;
 CREATE TABLE bundle (
@@ -6,12 +6,13 @@ VALUES ("mickey dolenz", "mickey", "123 Laurel Canyon Drive", "123 Laurel Canyon
 INSERT INTO account (accountHolderName, nickName, serviceAddress, billingAddress, email, phone) 
 VALUES ("cass elliot", "mama cass", "1023 Laurel Canyon Drive", "1023 Laurel Canyon Drive", "mama@gmail.com", "7132224512");
 
-INSERT INTO user (accountID, name, email, role, password) VALUES (1, "mickey dolenz", "mickeyd@gmail.com", 1, "alksdf98423)*(&#");
-INSERT INTO user (accountID, name, email, role, password) VALUES (1, "peter tork", "petertd@gmail.com", 3, "alksdf98423)*(&#");
-INSERT INTO user (accountID, name, email, role, password) VALUES (1, "davy jones", "djonesI@gmail.com", 3, "alksdf98423)*(&#");
-INSERT INTO user (accountID, name, email, role, password) VALUES (1, "michael nesmith", "joanne@gmail.com", 2, "alksdf98423)*(&#");
+-- The users' passwords are bcrypt hashes of "alksdf98423)*(&#"
+INSERT INTO user (accountID, name, email, role, password) VALUES (1, "mickey dolenz", "mickeyd@gmail.com", 1, "$2a$10$vHIWCjrtpPywj6w8t2K1vO1e5j5fzVINgrn6OsBwjxTZFk6FXEvei");
+INSERT INTO user (accountID, name, email, role, password) VALUES (1, "peter tork", "petertd@gmail.com", 3, "$2a$10$vHIWCjrtpPywj6w8t2K1vO1e5j5fzVINgrn6OsBwjxTZFk6FXEvei");
+INSERT INTO user (accountID, name, email, role, password) VALUES (1, "davy jones", "djonesI@gmail.com", 3, "$2a$10$vHIWCjrtpPywj6w8t2K1vO1e5j5fzVINgrn6OsBwjxTZFk6FXEvei");
+INSERT INTO user (accountID, name, email, role, password) VALUES (1, "michael nesmith", "joanne@gmail.com", 2, "$2a$10$vHIWCjrtpPywj6w8t2K1vO1e5j5fzVINgrn6OsBwjxTZFk6FXEvei");
 
-INSERT INTO user (accountID, name, email, role, password) VALUES (2, "mama cass", "mama@gmail.com", 1, "alksdf98423)*(&#");
+INSERT INTO user (accountID, name, email, role, password) VALUES (2, "mama cass", "mama@gmail.com", 1, "$2a$10$vHIWCjrtpPywj6w8t2K1vO1e5j5fzVINgrn6OsBwjxTZFk6FXEvei");
 
 INSERT INTO accountUser (accountID, userID) VALUES (1, 1);
 INSERT INTO accountUser (accountID, userID) VALUES (1, 2);
@@ -0,0 +1,167 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package accesslog
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"github.com/youngkin/mockvideo/internal/metrics"
+)
+
+// DefaultRules are the Filter rules used when none are configured. They exclude the health,
+// readiness, and metrics endpoints.
+var DefaultRules = []string{"/accountdhealth", "/readyz", "/metrics"}
+
+// Rqsts counts the HTTP requests handled by Middleware, by method and status. Requests excluded
+// by the Filter aren't counted.
+var Rqsts = metrics.NewCounterVec(prometheus.CounterOpts{
+	Subsystem: "http",
+	Name:      "requests_total",
+	Help:      "number of HTTP requests by method and status",
+}, []string{"rqstMethod", "rqstStatus"})
+
+// otherMethod is the method label of requests with a nonstandard method
+const otherMethod = "OTHER"
+
+// Filter decides which requests are logged and counted, see the package documentation
+type Filter struct {
+	// excluded contains the paths whose requests are neither logged nor counted
+	excluded map[string]bool
+	// sampled maps paths to the samplers deciding which of their requests are logged
+	sampled map[string]*sampler
+}
+
+// sampler selects 1 in 'every' requests
+type sampler struct {
+	every uint64
+	count uint64
+}
+
+// NewFilter returns a Filter made up of 'rules', each either 'path' or 'path=N' where N is a positive
+// integer, see the package documentation. Paths must start with '/'.
+func NewFilter(rules []string) (*Filter, error) {
+	f := &Filter{excluded: make(map[string]bool), sampled: make(map[string]*sampler)}
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		parts := strings.SplitN(rule, "=", 2)
+		path := parts[0]
+		if !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("invalid access log rule %q, expected 'path' or 'path=N' where path starts with '/'", rule)
+		}
+		if f.excluded[path] || f.sampled[path] != nil {
+			return nil, fmt.Errorf("invalid access log rule %q, there's already a rule for %s", rule, path)
+		}
+		if len(parts) == 1 {
+			f.excluded[path] = true
+			continue
+		}
+		every, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil || every == 0 {
+			return nil, fmt.Errorf("invalid access log rule %q, expected 'path=N' where N is a positive integer", rule)
+		}
+		f.sampled[path] = &sampler{every: every}
+	}
+	return f, nil
+}
+
+// counted returns true if requests for 'path' are counted
+func (f *Filter) counted(path string) bool {
+	return !f.excluded[path]
+}
+
+// logged returns true if this request for 'path' is logged
+func (f *Filter) logged(path string) bool {
+	if f.excluded[path] {
+		return false
+	}
+	s, ok := f.sampled[path]
+	if !ok {
+		return true
+	}
+	return s.selected()
+}
+
+// selected returns true if this is 1 of the 1 in 'every' requests
+func (s *sampler) selected() bool {
+	return (atomic.AddUint64(&s.count, 1)-1)%s.every == 0
+}
+
+// Middleware logs, at info level, and counts in Rqsts the requests handled by the next handler
+// as permitted by 'f'
+func Middleware(f *Filter, logger logging.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := r.URL.Path
+			if !f.counted(path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			Rqsts.WithLabelValues(methodLabel(r.Method), strconv.Itoa(rec.Status())).Inc()
+			if !f.logged(path) {
+				return
+			}
+			logging.Log(logger, logging.InfoLevel, "HTTP request handled", func(fields logging.Fields) {
+				fields[logging.Method] = r.Method
+				fields[logging.Path] = path
+				fields[logging.HTTPStatus] = rec.Status()
+				fields[logging.Duration] = time.Since(start).String()
+				fields[logging.RemoteAddr] = r.RemoteAddr
+			})
+		})
+	}
+}
+
+// statusRecorder is an http.ResponseWriter that records the HTTP status of the response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records 'status' and writes it to the underlying http.ResponseWriter
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Flush sends any buffered data to the client if the underlying http.ResponseWriter supports it
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Status returns the HTTP status of the response. It's 200 (OK) if WriteHeader wasn't called.
+func (rec *statusRecorder) Status() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
+
+// methodLabel returns the method label of 'method'. Nonstandard methods share a single label so a
+// client can't create arbitrarily many label values.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodConnect, http.MethodDelete, http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodPatch, http.MethodPost, http.MethodPut, http.MethodTrace:
+		return method
+	default:
+		return otherMethod
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package accesslog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+func TestNewFilter(t *testing.T) {
+	tcs := []struct {
+		testName  string
+		rules     []string
+		expectErr bool
+	}{
+		{testName: "testDefaultRules", rules: DefaultRules},
+		{testName: "testNoRules"},
+		{testName: "testSampled", rules: []string{"/metrics", " /readyz=10 "}},
+		{testName: "testRelativePath", rules: []string{"readyz"}, expectErr: true},
+		{testName: "testEmptyRule", rules: []string{""}, expectErr: true},
+		{testName: "testZeroRate", rules: []string{"/readyz=0"}, expectErr: true},
+		{testName: "testNegativeRate", rules: []string{"/readyz=-1"}, expectErr: true},
+		{testName: "testNonNumericRate", rules: []string{"/readyz=often"}, expectErr: true},
+		{testName: "testDuplicatePath", rules: []string{"/readyz", "/readyz=10"}, expectErr: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			_, err := NewFilter(tc.rules)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("expected error %t, got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	tcs := []struct {
+		testName       string
+		rules          []string
+		path           string
+		rqsts          int
+		expectedLogged int
+	}{
+		{testName: "testNoRule", rules: DefaultRules, path: "/users", rqsts: 3, expectedLogged: 3},
+		{testName: "testExcluded", rules: DefaultRules, path: "/metrics", rqsts: 3},
+		{testName: "testExactMatch", rules: []string{"/users"}, path: "/users/1", rqsts: 3, expectedLogged: 3},
+		{testName: "testSampled", rules: []string{"/readyz=2"}, path: "/readyz", rqsts: 5, expectedLogged: 3},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			f, err := NewFilter(tc.rules)
+			if err != nil {
+				t.Fatalf("error %s was not expected creating a Filter", err)
+			}
+			testLogger, hook := test.NewNullLogger()
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			})
+			h := Middleware(f, logging.NewLogrusLogger(log.NewEntry(testLogger)))(next)
+
+			for i := 0; i < tc.rqsts; i++ {
+				rr := httptest.NewRecorder()
+				h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+				if rr.Code != http.StatusTeapot {
+					t.Fatalf("expected StatusCode = %d, got %d", http.StatusTeapot, rr.Code)
+				}
+			}
+
+			entries := hook.AllEntries()
+			if len(entries) != tc.expectedLogged {
+				t.Fatalf("expected %d log entries, got %d", tc.expectedLogged, len(entries))
+			}
+			for _, e := range entries {
+				if e.Data[logging.Path] != tc.path || e.Data[logging.HTTPStatus] != http.StatusTeapot {
+					t.Errorf("expected a log entry for %s with status %d, got %+v", tc.path, http.StatusTeapot, e.Data)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package accesslog logs each HTTP request once it's been handled, along with its status and duration,
+and counts it in the Rqsts metric. Frequent, uninteresting requests such as health probes and
+Prometheus scrapes would drown out the others, so a Filter decides which requests are logged and
+counted. A Filter is made up of rules, one per path:
+
+		/metrics		requests for '/metrics' are neither logged nor counted
+		/readyz=100		1 in 100 requests for '/readyz' is logged, all of them are counted
+
+Paths are matched exactly. Requests for paths without a rule are always logged and counted. The
+DefaultRules exclude the health, readiness, and metrics endpoints.
+
+UnaryServerInterceptor logs gRPC requests. Its RPCSampler logs 1 in N successful RPCs, but failed RPCs
+are always logged, as are slow RPCs, those taking at least the RPCSampler's threshold. Slow RPCs are
+logged at warn level with a "slow_rpc" field so they can be found in ELK.
+*/
+package accesslog
@@ -0,0 +1,74 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package accesslog
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultSlowRPC is the duration after which an RPC is logged as slow when one isn't configured
+const DefaultSlowRPC = time.Second
+
+// RPCSampler decides which RPCs UnaryServerInterceptor logs, see the package documentation
+type RPCSampler struct {
+	// successes selects the successful RPCs that are logged
+	successes sampler
+	// slow is the duration after which an RPC is slow, slow RPCs aren't identified if it's 0
+	slow time.Duration
+}
+
+// NewRPCSampler returns an RPCSampler that logs 1 in 'every' successful RPCs, and all failed
+// RPCs and RPCs that take at least 'slow'. Slow RPCs aren't identified if 'slow' is 0.
+func NewRPCSampler(every int, slow time.Duration) (*RPCSampler, error) {
+	if every < 1 {
+		return nil, errors.New("the sample rate must be a positive integer")
+	}
+	if slow < 0 {
+		return nil, errors.New("the slow RPC duration can't be negative")
+	}
+	return &RPCSampler{successes: sampler{every: uint64(every)}, slow: slow}, nil
+}
+
+// isSlow returns true if an RPC that took 'd' is slow
+func (s *RPCSampler) isSlow(d time.Duration) bool {
+	return s.slow > 0 && d >= s.slow
+}
+
+// UnaryServerInterceptor is the gRPC counterpart of Middleware. It logs the RPCs selected by 's'
+// once they're handled, along with their gRPC status code and duration. Slow RPCs are logged at warn
+// level with the logging.SlowRPC field set, others at info level.
+func UnaryServerInterceptor(s *RPCSampler, logger logging.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		d := time.Since(start)
+
+		code := status.Code(err)
+		slow := s.isSlow(d)
+		level := logging.InfoLevel
+		switch {
+		case slow:
+			level = logging.WarnLevel
+		case code == codes.OK && !s.successes.selected():
+			return resp, err
+		}
+		logging.Log(logger, level, "gRPC request handled", func(fields logging.Fields) {
+			fields[logging.RPCFunc] = info.FullMethod
+			fields[logging.GRPCCode] = code.String()
+			fields[logging.Duration] = d.String()
+			if slow {
+				fields[logging.SlowRPC] = true
+			}
+		})
+		return resp, err
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package accesslog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewRPCSampler(t *testing.T) {
+	tcs := []struct {
+		testName  string
+		every     int
+		slow      time.Duration
+		expectErr bool
+	}{
+		{testName: "testDefaults", every: 1, slow: DefaultSlowRPC},
+		{testName: "testNoSlowRPCs", every: 10},
+		{testName: "testZeroRate", every: 0, expectErr: true},
+		{testName: "testNegativeSlowRPC", every: 1, slow: -time.Second, expectErr: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			_, err := NewRPCSampler(tc.every, tc.slow)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("expected error %t, got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tcs := []struct {
+		testName       string
+		every          int
+		slow           time.Duration
+		rpcDuration    time.Duration
+		err            error
+		rpcs           int
+		expectedLogged int
+		expectedLevel  log.Level
+		expectedCode   codes.Code
+	}{
+		{testName: "testAllLogged", every: 1, rpcs: 3, expectedLogged: 3, expectedLevel: log.InfoLevel, expectedCode: codes.OK},
+		{testName: "testSampled", every: 2, rpcs: 5, expectedLogged: 3, expectedLevel: log.InfoLevel, expectedCode: codes.OK},
+		{
+			testName:       "testFailuresAlwaysLogged",
+			every:          100,
+			err:            status.Error(codes.NotFound, "no such user"),
+			rpcs:           3,
+			expectedLogged: 3,
+			expectedLevel:  log.InfoLevel,
+			expectedCode:   codes.NotFound,
+		},
+		{
+			testName:       "testSlowAlwaysLogged",
+			every:          100,
+			slow:           time.Millisecond,
+			rpcDuration:    5 * time.Millisecond,
+			rpcs:           3,
+			expectedLogged: 3,
+			expectedLevel:  log.WarnLevel,
+			expectedCode:   codes.OK,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			s, err := NewRPCSampler(tc.every, tc.slow)
+			if err != nil {
+				t.Fatalf("error %s was not expected creating an RPCSampler", err)
+			}
+			testLogger, hook := test.NewNullLogger()
+			interceptor := UnaryServerInterceptor(s, logging.NewLogrusLogger(log.NewEntry(testLogger)))
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				time.Sleep(tc.rpcDuration)
+				return "resp", tc.err
+			}
+			info := &grpc.UnaryServerInfo{FullMethod: "/users.UserServer/GetUser"}
+
+			for i := 0; i < tc.rpcs; i++ {
+				resp, err := interceptor(context.Background(), "rqst", info, handler)
+				if resp != "resp" || err != tc.err {
+					t.Fatalf("expected the handler's response and error, got %v, %v", resp, err)
+				}
+			}
+
+			entries := hook.AllEntries()
+			if len(entries) != tc.expectedLogged {
+				t.Fatalf("expected %d log entries, got %d", tc.expectedLogged, len(entries))
+			}
+			for _, e := range entries {
+				if e.Level != tc.expectedLevel || e.Data[logging.RPCFunc] != info.FullMethod || e.Data[logging.GRPCCode] != tc.expectedCode.String() {
+					t.Errorf("expected a %s log entry for %s with code %s, got %s %+v", tc.expectedLevel, info.FullMethod, tc.expectedCode, e.Level, e.Data)
+				}
+				if _, slow := e.Data[logging.SlowRPC]; slow != (tc.slow > 0) {
+					t.Errorf("expected %s set %t, got %+v", logging.SlowRPC, tc.slow > 0, e.Data)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+)
+
+// minAPIKeyLen is the minimum length of an API key, shorter keys are too easily guessed
+const minAPIKeyLen = 32
+
+// roles maps the role names used in API key definitions to Roles
+var roles = map[string]domain.Role{
+	"primary":      domain.Primary,
+	"unrestricted": domain.Unrestricted,
+	"restricted":   domain.Restricted,
+}
+
+// apiKey is an API key and the caller it authenticates
+type apiKey struct {
+	key    string
+	caller Caller
+}
+
+// APIKeys authenticates services, e.g., billingd, that call accountd with an API key. Each key is
+// granted a set of Scopes, limiting the operations its caller can perform. APIKeys is safe for
+// concurrent use.
+type APIKeys struct {
+	keys []apiKey
+}
+
+// ParseAPIKeys reads API key definitions from 'r', one per line:
+//
+//	# name    key                                 accountID  role          scopes
+//	billing   5b0c0e2d8f3e4a6b9c1d7e2f3a4b5c6d    1          unrestricted  users:read
+//
+// The name identifies the key's caller in audit logs. The account ID and role are those the caller
+// acts as, see Caller. The scopes are a comma separated list, see ParseScopes. Names and keys must
+// be unique and keys must be at least 32 characters. Blank lines and lines starting with '#' are ignored.
+func ParseAPIKeys(r io.Reader) (*APIKeys, error) {
+	ak := &APIKeys{}
+	names := make(map[string]bool)
+	keys := make(map[string]bool)
+	lineReader := bufio.NewScanner(r)
+	for lineNum := 1; lineReader.Scan(); lineNum++ {
+		line := strings.TrimSpace(lineReader.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("line %d: expected 'name key accountID role scopes'", lineNum)
+		}
+		name, key := fields[0], fields[1]
+		if names[name] {
+			return nil, fmt.Errorf("line %d: duplicate name %q", lineNum, name)
+		}
+		if len(key) < minAPIKeyLen {
+			return nil, fmt.Errorf("line %d: the key must be at least %d characters", lineNum, minAPIKeyLen)
+		}
+		if keys[key] {
+			return nil, fmt.Errorf("line %d: duplicate key", lineNum)
+		}
+		accountID, err := strconv.Atoi(fields[2])
+		if err != nil || accountID < 1 {
+			return nil, fmt.Errorf("line %d: invalid accountID %q", lineNum, fields[2])
+		}
+		role, ok := roles[strings.ToLower(fields[3])]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown role %q, must be one of 'primary', 'unrestricted', or 'restricted'", lineNum, fields[3])
+		}
+		scopes, err := ParseScopes(fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNum, err)
+		}
+
+		names[name] = true
+		keys[key] = true
+		ak.keys = append(ak.keys, apiKey{
+			key:    key,
+			caller: Caller{AccountID: accountID, Role: role, APIKey: name, Scopes: scopes},
+		})
+	}
+	if err := lineReader.Err(); err != nil {
+		return nil, err
+	}
+	return ak, nil
+}
+
+// Caller returns the caller, with its Scopes, authenticated by 'key'. The returned bool is false if
+// 'key' is unknown. Every key is compared, in constant time, so the time taken doesn't reveal which
+// keys exist.
+func (ak *APIKeys) Caller(key string) (Caller, bool) {
+	var found Caller
+	ok := false
+	for _, k := range ak.keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k.key)) == 1 {
+			found, ok = k.caller, true
+		}
+	}
+	return found, ok
+}
+
+// Len returns the number of API keys
+func (ak *APIKeys) Len() int {
+	return len(ak.keys)
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	billingKey = "0123456789abcdef0123456789abcdef"
+	crmKey     = "fedcba9876543210fedcba9876543210"
+	testKeys   = `
+# name    key                                 accountID  role          scopes
+billing   ` + billingKey + `    1          unrestricted  users:read
+crm       ` + crmKey + `    2          Primary       users:read,users:write
+`
+)
+
+func TestParseAPIKeys(t *testing.T) {
+	tcs := []struct {
+		testName    string
+		keys        string
+		expectedLen int
+		expectErr   bool
+	}{
+		{testName: "testValid", keys: testKeys, expectedLen: 2},
+		{testName: "testEmpty", keys: "# no keys\n", expectedLen: 0},
+		{testName: "testMissingField", keys: "billing " + billingKey + " 1 unrestricted", expectErr: true},
+		{testName: "testShortKey", keys: "billing abc 1 unrestricted users:read", expectErr: true},
+		{testName: "testInvalidAccountID", keys: "billing " + billingKey + " zero unrestricted users:read", expectErr: true},
+		{testName: "testUnknownRole", keys: "billing " + billingKey + " 1 superuser users:read", expectErr: true},
+		{testName: "testUnknownScope", keys: "billing " + billingKey + " 1 unrestricted users:delete", expectErr: true},
+		{testName: "testDuplicateName", keys: "billing " + billingKey + " 1 primary admin\nbilling " + crmKey + " 1 primary admin", expectErr: true},
+		{testName: "testDuplicateKey", keys: "billing " + billingKey + " 1 primary admin\ncrm " + billingKey + " 1 primary admin", expectErr: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			keys, err := ParseAPIKeys(strings.NewReader(tc.keys))
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error %t, got %v", tc.expectErr, err)
+			}
+			if err == nil && keys.Len() != tc.expectedLen {
+				t.Errorf("expected %d keys, got %d", tc.expectedLen, keys.Len())
+			}
+		})
+	}
+}
+
+func TestAPIKeysCaller(t *testing.T) {
+	keys, err := ParseAPIKeys(strings.NewReader(testKeys))
+	if err != nil {
+		t.Fatalf("error %s was not expected parsing the keys", err)
+	}
+
+	caller, ok := keys.Caller(crmKey)
+	expected := Caller{AccountID: 2, Role: domain.Primary, APIKey: "crm", Scopes: []Scope{ScopeUsersRead, ScopeUsersWrite}}
+	if !ok || !reflect.DeepEqual(caller, expected) {
+		t.Errorf("expected caller %+v, got %+v, %t", expected, caller, ok)
+	}
+	if _, ok = keys.Caller("notakey"); ok {
+		t.Errorf("expected an unknown key not to identify a caller")
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tcs := []struct {
+		testName string
+		scopes   []Scope
+		scope    Scope
+		expected bool
+	}{
+		{testName: "testUnscoped", scopes: nil, scope: ScopeAdmin, expected: true},
+		{testName: "testGranted", scopes: []Scope{ScopeUsersRead}, scope: ScopeUsersRead, expected: true},
+		{testName: "testNotGranted", scopes: []Scope{ScopeUsersRead}, scope: ScopeUsersWrite, expected: false},
+		{testName: "testAdmin", scopes: []Scope{ScopeAdmin}, scope: ScopeUsersWrite, expected: true},
+		{testName: "testNoScopes", scopes: []Scope{}, scope: ScopeUsersRead, expected: false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			if actual := (Caller{Scopes: tc.scopes}).HasScope(tc.scope); actual != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	tcs := []struct {
+		testName string
+		caller   Caller
+		expected string
+	}{
+		{testName: "testUser", caller: Caller{UserID: 3, AccountID: 1}, expected: "user:3"},
+		{testName: "testImpersonated", caller: Caller{UserID: 3, AccountID: 1, Impersonator: "alice"}, expected: "admin:alice"},
+		{testName: "testAPIKey", caller: Caller{AccountID: 1, APIKey: "billing"}, expected: "apikey:billing"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			if actual := tc.caller.Identity(); actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	keys, err := ParseAPIKeys(strings.NewReader(testKeys))
+	if err != nil {
+		t.Fatalf("error %s was not expected parsing the keys", err)
+	}
+
+	tcs := []struct {
+		testName       string
+		method         string
+		authorization  string
+		expectedCode   codes.Code
+		expectedCaller string
+	}{
+		{testName: "testNoKey", expectedCode: codes.Unauthenticated},
+		{testName: "testNoKeyHealthCheck", method: "/grpc.health.v1.Health/Check", expectedCode: codes.OK},
+		{testName: "testNoKeyLogin", method: "/accountd.UserServer/Login", expectedCode: codes.OK},
+		{testName: "testKey", authorization: "Bearer " + billingKey, expectedCode: codes.OK, expectedCaller: "billing"},
+		{testName: "testUnknownKey", authorization: "Bearer notakey", expectedCode: codes.Unauthenticated},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.authorization != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tc.authorization))
+			}
+			var caller string
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				c, _ := FromContext(ctx)
+				caller = c.APIKey
+				return nil, nil
+			}
+
+			method := "/accountd.UserServer/GetUser"
+			if tc.method != "" {
+				method = tc.method
+			}
+			interceptor := UnaryServerInterceptor(keys, logging.Default())
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+			if code := status.Code(err); code != tc.expectedCode {
+				t.Errorf("expected code %s, got %s", tc.expectedCode, code)
+			}
+			if caller != tc.expectedCaller {
+				t.Errorf("expected caller %q, got %q", tc.expectedCaller, caller)
+			}
+		})
+	}
+}
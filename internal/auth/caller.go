@@ -0,0 +1,77 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+)
+
+// Caller identifies the authenticated user making a request
+type Caller struct {
+	UserID    int
+	AccountID int
+	Role      domain.Role
+	// Impersonator identifies the administrator acting as the user, it's empty unless the
+	// request is being made using an impersonation token (see Impersonations)
+	Impersonator string
+	// APIKey names the API key the request was made with, e.g., 'billing', it's empty unless the
+	// request is being made using an API key (see APIKeys)
+	APIKey string
+	// Scopes are the operations the caller is allowed to perform. A nil Scopes, e.g., for an
+	// impersonated user, doesn't restrict the caller, see HasScope.
+	Scopes []Scope
+}
+
+// Impersonated returns true if the caller is an administrator acting as the user
+func (c Caller) Impersonated() bool {
+	return c.Impersonator != ""
+}
+
+// Identity identifies the caller in records of its changes, e.g., domain.User.LastModifiedBy. It's
+// 'apikey:{name}' for an API key, 'admin:{name}' for an administrator impersonating the user, and
+// 'user:{id}' otherwise.
+func (c Caller) Identity() string {
+	switch {
+	case c.APIKey != "":
+		return "apikey:" + c.APIKey
+	case c.Impersonated():
+		return "admin:" + c.Impersonator
+	default:
+		return "user:" + strconv.Itoa(c.UserID)
+	}
+}
+
+// HasScope returns true if the caller is allowed to perform operations requiring 'scope'. The
+// ScopeAdmin scope allows every operation.
+func (c Caller) HasScope(scope Scope) bool {
+	if c.Scopes == nil {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// callerKey is the context key for the Caller. It's unexported to prevent collisions
+// with keys defined in other packages.
+type callerKey struct{}
+
+// NewContext returns a copy of 'ctx' that carries 'caller'
+func NewContext(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// FromContext returns the Caller carried by 'ctx'. The returned bool is false if
+// 'ctx' doesn't carry a Caller, e.g., for requests originating within the service.
+func FromContext(ctx context.Context) (Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(Caller)
+	return caller, ok
+}
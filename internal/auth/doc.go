@@ -0,0 +1,25 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package auth provides the means to carry the identity of an authenticated caller through a request's
+context.Context. Protocol layers (e.g., HTTP handlers, gRPC servers) add the Caller to the context
+after authenticating a request. The services layer uses the Caller to make authorization decisions.
+
+Impersonations allows support staff to act as a user. An administrator exchanges the admin token for
+an impersonation token scoped to a single user and valid for a limited time (see MaxImpersonationTTL).
+Requests made with the impersonation token carry the user's Caller with Caller.Impersonator identifying
+the administrator, so they're authorized as the user but can be audited as the administrator.
+
+APIKeys authenticates other services, e.g., billingd, that call accountd with an
+'Authorization: Bearer {key}' header or metadata. Each key is granted Scopes (users:read, users:write,
+admin) limiting the operations its caller can perform, see Caller.HasScope. Callers without Scopes,
+e.g., those acting through an impersonation token, are unrestricted.
+
+JWTKeys authenticates users that call accountd with a JSON Web Token (JWT) signed, using HS256, by one of
+the configured keys. The token's 'kid' header identifies the key, so keys can be rotated. Its 'sub',
+'accountid', and 'role' claims identify the Caller, and an optional 'scope' claim limits its Scopes.
+JWTUnaryServerInterceptor requires gRPC callers to be authenticated once JWTs are configured.
+*/
+package auth
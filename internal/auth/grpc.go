@@ -0,0 +1,110 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor returns a gRPC interceptor that authenticates RPCs made with an API key, i.e.,
+// those with 'authorization: Bearer {key}' metadata. The key's caller is added to the RPC's context.
+// RPCs with an unknown key, or without a key, other than health checks and logins, fail with an
+// Unauthenticated status. RPCs whose caller has already been identified, e.g., by JWTUnaryServerInterceptor,
+// are passed on unchanged.
+func UnaryServerInterceptor(keys *APIKeys, logger logging.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		key := bearerMetadata(ctx)
+		if _, identified := FromContext(ctx); identified || key == "" && isPublic(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		if key == "" {
+			logger.WithFields(logging.Fields{
+				logging.Audit:     true,
+				logging.ErrorCode: mverr.MissingTokenErrorCode,
+				logging.RPCFunc:   info.FullMethod,
+			}).Warn(mverr.MissingTokenErrorMsg)
+			return nil, status.Error(mverr.GRPCCode(mverr.MissingTokenErrorCode), mverr.MissingTokenErrorMsg)
+		}
+		caller, ok := keys.Caller(key)
+		if !ok {
+			logger.WithFields(logging.Fields{
+				logging.Audit:     true,
+				logging.ErrorCode: mverr.InvalidAPIKeyErrorCode,
+				logging.RPCFunc:   info.FullMethod,
+			}).Warn(mverr.InvalidAPIKeyErrorMsg)
+			return nil, status.Error(mverr.GRPCCode(mverr.InvalidAPIKeyErrorCode), mverr.InvalidAPIKeyErrorMsg)
+		}
+		return handler(NewContext(ctx, caller), req)
+	}
+}
+
+// JWTUnaryServerInterceptor returns a gRPC interceptor that requires RPCs to be made with a JWT signed
+// by one of 'keys', i.e., with 'authorization: Bearer {jwt}' metadata. The JWT's caller is added to the
+// RPC's context. If 'others' is true, i.e., there are other kinds of bearer token, RPCs with a token that
+// isn't a JWT are passed on unchanged so they can be authenticated by, e.g., UnaryServerInterceptor.
+// Other RPCs fail with an Unauthenticated status. Health checks and logins don't require a token.
+func JWTUnaryServerInterceptor(keys *JWTKeys, others bool, logger logging.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		token := bearerMetadata(ctx)
+		if token == "" && isPublic(info.FullMethod) || token != "" && !IsJWT(token) && others {
+			return handler(ctx, req)
+		}
+
+		var caller Caller
+		err := errors.New("no bearer token")
+		if IsJWT(token) {
+			caller, err = keys.Caller(token)
+		}
+		if err != nil {
+			logger.WithFields(logging.Fields{
+				logging.Audit:       true,
+				logging.ErrorCode:   mverr.InvalidJWTErrorCode,
+				logging.ErrorDetail: err.Error(),
+				logging.RPCFunc:     info.FullMethod,
+			}).Warn(mverr.InvalidJWTErrorMsg)
+			return nil, status.Error(mverr.GRPCCode(mverr.InvalidJWTErrorCode), mverr.InvalidJWTErrorMsg)
+		}
+		return handler(NewContext(ctx, caller), req)
+	}
+}
+
+// publicMethods are the full names of the RPCs that don't require a token, the health checks, of the
+// standard gRPC health service and UserServer's Health RPC, and UserServer's Login RPC, whose callers
+// don't have a token yet
+var publicMethods = map[string]bool{
+	"/grpc.health.v1.Health/Check": true,
+	"/grpc.health.v1.Health/Watch": true,
+	"/accountd.UserServer/Health":  true,
+	"/accountd.UserServer/Login":   true,
+}
+
+// isPublic returns true if 'fullMethod' doesn't require a token, see publicMethods
+func isPublic(fullMethod string) bool {
+	return publicMethods[fullMethod]
+}
+
+// bearerMetadata returns the token from the 'authorization: Bearer {token}' metadata of an RPC, or
+// an empty string if there isn't one
+func bearerMetadata(ctx context.Context) string {
+	const prefix = "Bearer "
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, authz := range md.Get("authorization") {
+		if strings.HasPrefix(authz, prefix) {
+			return strings.TrimSpace(strings.TrimPrefix(authz, prefix))
+		}
+	}
+	return ""
+}
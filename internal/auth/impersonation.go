@@ -0,0 +1,164 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/clock"
+)
+
+// DefaultImpersonationTTL is how long an impersonation token is valid unless configured otherwise
+const DefaultImpersonationTTL = 15 * time.Minute
+
+// MaxImpersonationTTL is the hard limit on how long an impersonation token can be valid
+const MaxImpersonationTTL = time.Hour
+
+// impersonationTokenLen is the number of random bytes in an impersonation token
+const impersonationTokenLen = 32
+
+// ErrInvalidAdminToken is returned by Impersonations.Grant when the admin token doesn't match
+var ErrInvalidAdminToken = errors.New("invalid admin token")
+
+// Grant describes an impersonation token issued to an administrator
+type Grant struct {
+	Token   string    `json:"token"`
+	Admin   string    `json:"admin"`
+	Reason  string    `json:"reason"`
+	UserID  int       `json:"userid"`
+	Expires time.Time `json:"expires"`
+	// caller is the user being impersonated
+	caller Caller
+}
+
+// Impersonations issues impersonation tokens to administrators and maps them back to the
+// impersonated user. An administrator presents the admin token, a shared secret, to exchange
+// it for a token scoped to a single user. The token is only valid for the configured TTL.
+// Impersonations is safe for concurrent use.
+type Impersonations struct {
+	adminToken string
+	ttl        time.Duration
+	clock      clock.Clock
+
+	mu     sync.Mutex
+	grants map[string]Grant
+}
+
+// NewImpersonations returns an Impersonations that issues tokens, valid for 'ttl', in exchange
+// for 'adminToken'. 'adminToken' must not be empty and 'ttl' must be greater than 0 and no
+// more than MaxImpersonationTTL.
+func NewImpersonations(adminToken string, ttl time.Duration) (*Impersonations, error) {
+	if adminToken == "" {
+		return nil, errors.New("non-empty adminToken required")
+	}
+	if ttl <= 0 || ttl > MaxImpersonationTTL {
+		return nil, errors.New("ttl must be greater than 0 and no more than MaxImpersonationTTL")
+	}
+	return &Impersonations{
+		adminToken: adminToken,
+		ttl:        ttl,
+		clock:      clock.System,
+		grants:     make(map[string]Grant),
+	}, nil
+}
+
+// SetClock replaces the Clock, clock.System by default, used to determine when tokens expire.
+// 'c' must be non-nil.
+func (im *Impersonations) SetClock(c clock.Clock) error {
+	if c == nil {
+		return errors.New("non-nil Clock required")
+	}
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	im.clock = c
+	return nil
+}
+
+// IsAdminToken returns true if 'token' matches the admin token provided to NewImpersonations
+func (im *Impersonations) IsAdminToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(im.adminToken)) == 1
+}
+
+// Grant issues a token allowing 'admin' to act as 'target'. 'adminToken' must match the admin
+// token provided to NewImpersonations, otherwise ErrInvalidAdminToken is returned. 'admin'
+// and 'reason' are recorded so impersonated requests can be audited, neither can be empty.
+func (im *Impersonations) Grant(adminToken, admin, reason string, target Caller) (Grant, error) {
+	if !im.IsAdminToken(adminToken) {
+		return Grant{}, ErrInvalidAdminToken
+	}
+	if admin == "" || reason == "" {
+		return Grant{}, errors.New("admin and reason are required")
+	}
+
+	b := make([]byte, impersonationTokenLen)
+	if _, err := rand.Read(b); err != nil {
+		return Grant{}, err
+	}
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	now := im.clock.Now()
+	im.purge(now)
+
+	target.Impersonator = admin
+	g := Grant{
+		Token:   hex.EncodeToString(b),
+		Admin:   admin,
+		Reason:  reason,
+		UserID:  target.UserID,
+		Expires: now.Add(im.ttl),
+		caller:  target,
+	}
+	im.grants[g.Token] = g
+	return g, nil
+}
+
+// Caller returns the impersonated user, with Caller.Impersonator set to the administrator,
+// for 'token'. The returned bool is false if 'token' is unknown or has expired.
+func (im *Impersonations) Caller(token string) (Caller, bool) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	g, ok := im.grants[token]
+	if !ok {
+		return Caller{}, false
+	}
+	if !im.clock.Now().Before(g.Expires) {
+		delete(im.grants, token)
+		return Caller{}, false
+	}
+	return g.caller, true
+}
+
+// RevokeUser revokes the tokens allowing administrators to act as the user identified by 'userID',
+// e.g., once the user has been deleted, returning the number revoked
+func (im *Impersonations) RevokeUser(userID int) int {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	n := 0
+	for token, g := range im.grants {
+		if g.UserID == userID {
+			delete(im.grants, token)
+			n++
+		}
+	}
+	return n
+}
+
+// purge removes expired grants. The caller must hold 'im.mu'.
+func (im *Impersonations) purge(now time.Time) {
+	for token, g := range im.grants {
+		if !now.Before(g.Expires) {
+			delete(im.grants, token)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/clock"
+	"github.com/youngkin/mockvideo/internal/domain"
+)
+
+func TestNewImpersonations(t *testing.T) {
+	tcs := []struct {
+		testName   string
+		adminToken string
+		ttl        time.Duration
+		shouldPass bool
+	}{
+		{testName: "testNewImpersonationsSuccess", adminToken: "secret", ttl: DefaultImpersonationTTL, shouldPass: true},
+		{testName: "testNewImpersonationsMaxTTL", adminToken: "secret", ttl: MaxImpersonationTTL, shouldPass: true},
+		{testName: "testNewImpersonationsTTLTooLong", adminToken: "secret", ttl: MaxImpersonationTTL + time.Second},
+		{testName: "testNewImpersonationsZeroTTL", adminToken: "secret"},
+		{testName: "testNewImpersonationsNoAdminToken", ttl: DefaultImpersonationTTL},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			_, err := NewImpersonations(tc.adminToken, tc.ttl)
+			if tc.shouldPass && err != nil {
+				t.Errorf("error %s was not expected", err)
+			}
+			if !tc.shouldPass && err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestGrant(t *testing.T) {
+	target := Caller{UserID: 5, AccountID: 2, Role: domain.Primary}
+
+	tcs := []struct {
+		testName   string
+		adminToken string
+		admin      string
+		reason     string
+		shouldPass bool
+	}{
+		{testName: "testGrantSuccess", adminToken: "secret", admin: "jsmith", reason: "ticket 1234", shouldPass: true},
+		{testName: "testGrantBadAdminToken", adminToken: "guess", admin: "jsmith", reason: "ticket 1234"},
+		{testName: "testGrantNoAdmin", adminToken: "secret", reason: "ticket 1234"},
+		{testName: "testGrantNoReason", adminToken: "secret", admin: "jsmith"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			now := time.Date(2020, time.July, 4, 9, 30, 0, 0, time.UTC)
+			clk := clock.NewFrozen(now)
+			im, err := NewImpersonations("secret", 15*time.Minute)
+			if err != nil {
+				t.Fatalf("error %s was not expected creating Impersonations", err)
+			}
+			im.SetClock(clk)
+
+			g, err := im.Grant(tc.adminToken, tc.admin, tc.reason, target)
+			if !tc.shouldPass {
+				if err == nil {
+					t.Fatalf("expected an error, got grant %+v", g)
+				}
+				if _, ok := im.Caller(g.Token); ok {
+					t.Errorf("expected no caller for a failed grant")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error %s was not expected", err)
+			}
+			if g.UserID != target.UserID || g.Admin != tc.admin || !g.Expires.Equal(now.Add(15*time.Minute)) {
+				t.Errorf("unexpected grant %+v", g)
+			}
+
+			caller, ok := im.Caller(g.Token)
+			expected := target
+			expected.Impersonator = tc.admin
+			if !ok || !reflect.DeepEqual(caller, expected) || !caller.Impersonated() {
+				t.Errorf("expected caller %+v, got %+v, %t", expected, caller, ok)
+			}
+
+			clk.Advance(15 * time.Minute)
+			if caller, ok = im.Caller(g.Token); ok {
+				t.Errorf("expected the token to have expired, got caller %+v", caller)
+			}
+		})
+	}
+}
+
+func TestRevokeUser(t *testing.T) {
+	im, err := NewImpersonations("secret", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("error %s was not expected creating Impersonations", err)
+	}
+	revoked, _ := im.Grant("secret", "jsmith", "ticket 1234", Caller{UserID: 5, AccountID: 2})
+	revoked2, _ := im.Grant("secret", "jdoe", "ticket 1235", Caller{UserID: 5, AccountID: 2})
+	kept, _ := im.Grant("secret", "jsmith", "ticket 1236", Caller{UserID: 6, AccountID: 2})
+
+	if n := im.RevokeUser(5); n != 2 {
+		t.Errorf("expected 2 tokens to be revoked, got %d", n)
+	}
+	for _, g := range []Grant{revoked, revoked2} {
+		if _, ok := im.Caller(g.Token); ok {
+			t.Errorf("expected token for user %d to be revoked", g.UserID)
+		}
+	}
+	if _, ok := im.Caller(kept.Token); !ok {
+		t.Errorf("expected token for user %d to be kept", kept.UserID)
+	}
+	if n := im.RevokeUser(5); n != 0 {
+		t.Errorf("expected no tokens to be revoked again, got %d", n)
+	}
+}
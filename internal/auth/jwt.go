@@ -0,0 +1,267 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/clock"
+	"github.com/youngkin/mockvideo/internal/domain"
+)
+
+// minJWTKeyLen is the minimum length of a JWT signing key, HS256 keys shorter than the hash are weak
+const minJWTKeyLen = 32
+
+// jwtAlg is the only JWT signing algorithm accepted, HMAC using SHA-256
+const jwtAlg = "HS256"
+
+// jwtHeader is the JOSE header of a JWT
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	KID string `json:"kid"`
+}
+
+// JWTClaims are the claims of a JWT identifying a user, see JWTKeys
+type JWTClaims struct {
+	// Subject is the user's ID
+	Subject   string `json:"sub"`
+	AccountID int    `json:"accountid"`
+	// Role is 'primary', 'unrestricted', or 'restricted'
+	Role string `json:"role"`
+	// Scope optionally limits the operations the user can perform, it's a space separated list, e.g.,
+	// 'users:read users:write'
+	Scope string `json:"scope,omitempty"`
+	// ExpiresAt and NotBefore are in seconds since the Unix epoch, NotBefore is optional
+	ExpiresAt int64 `json:"exp"`
+	NotBefore int64 `json:"nbf,omitempty"`
+}
+
+// JWTKeys authenticates users that call accountd with a JSON Web Token (JWT) issued, e.g., by a
+// login service. Tokens must be signed with HS256 using one of the keys, identified by the token's
+// 'kid' header, so keys can be rotated by adding the new key before tokens are signed with it.
+// JWTKeys is safe for concurrent use.
+type JWTKeys struct {
+	keys map[string][]byte
+	// current is the kid of the key tokens are issued with, see Issue
+	current string
+
+	mu    sync.Mutex
+	clock clock.Clock
+}
+
+// ParseJWTKeys reads JWT signing key definitions from 'r', one per line:
+//
+//	# kid      key
+//	2020-10    3f9a1c7e5b2d4086a1c3e5f7092b4d6f
+//
+// The key ID (kid) must match the 'kid' header of the tokens signed with the key. Key IDs must be unique
+// and keys must be at least 32 characters. Blank lines and lines starting with '#' are ignored. At least
+// one key is required. Tokens issued by accountd, see Issue, are signed with the last key.
+func ParseJWTKeys(r io.Reader) (*JWTKeys, error) {
+	jk := &JWTKeys{keys: make(map[string][]byte), clock: clock.System}
+	lineReader := bufio.NewScanner(r)
+	for lineNum := 1; lineReader.Scan(); lineNum++ {
+		line := strings.TrimSpace(lineReader.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected 'kid key'", lineNum)
+		}
+		kid, key := fields[0], fields[1]
+		if _, ok := jk.keys[kid]; ok {
+			return nil, fmt.Errorf("line %d: duplicate kid %q", lineNum, kid)
+		}
+		if len(key) < minJWTKeyLen {
+			return nil, fmt.Errorf("line %d: the key must be at least %d characters", lineNum, minJWTKeyLen)
+		}
+		jk.keys[kid] = []byte(key)
+		jk.current = kid
+	}
+	if err := lineReader.Err(); err != nil {
+		return nil, err
+	}
+	if len(jk.keys) == 0 {
+		return nil, errors.New("at least one JWT signing key required")
+	}
+	return jk, nil
+}
+
+// SetClock replaces the Clock, clock.System by default, used to determine when tokens expire.
+// 'c' must be non-nil.
+func (jk *JWTKeys) SetClock(c clock.Clock) error {
+	if c == nil {
+		return errors.New("non-nil Clock required")
+	}
+	jk.mu.Lock()
+	defer jk.mu.Unlock()
+	jk.clock = c
+	return nil
+}
+
+// now returns the current time according to the JWTKeys' Clock
+func (jk *JWTKeys) now() time.Time {
+	jk.mu.Lock()
+	defer jk.mu.Unlock()
+	return jk.clock.Now()
+}
+
+// Len returns the number of signing keys
+func (jk *JWTKeys) Len() int {
+	return len(jk.keys)
+}
+
+// IsJWT returns true if 'token' has the form of a JWT, i.e., 'header.claims.signature'. API keys and
+// impersonation tokens never do, so it distinguishes JWTs from the other kinds of bearer token.
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Sign returns a JWT asserting 'claims', signed with the key identified by 'kid'. It's the
+// counterpart of Caller, e.g., for issuing tokens to tests and local tools.
+func (jk *JWTKeys) Sign(kid string, claims JWTClaims) (string, error) {
+	key, ok := jk.keys[kid]
+	if !ok {
+		return "", fmt.Errorf("unknown kid %q", kid)
+	}
+	header, err := json.Marshal(jwtHeader{Alg: jwtAlg, Typ: "JWT", KID: kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(jwtSignature(key, signingInput)), nil
+}
+
+// Issue returns a JWT identifying the user 'caller', e.g., as a session token when the user logs in,
+// and the time it expires. It's valid for 'ttl' and is signed with the current key, the last one read by
+// ParseJWTKeys. 'caller.Scopes' are included in the token's 'scope' claim.
+func (jk *JWTKeys) Issue(caller Caller, ttl time.Duration) (token string, expiresAt time.Time, err error) {
+	if ttl <= 0 {
+		return "", time.Time{}, errors.New("the token's ttl must be greater than 0")
+	}
+	role, err := roleName(caller.Role)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	scopes := make([]string, 0, len(caller.Scopes))
+	for _, s := range caller.Scopes {
+		scopes = append(scopes, string(s))
+	}
+	now := jk.now()
+	expiresAt = now.Add(ttl).Truncate(time.Second)
+	token, err = jk.Sign(jk.current, JWTClaims{
+		Subject:   strconv.Itoa(caller.UserID),
+		AccountID: caller.AccountID,
+		Role:      role,
+		Scope:     strings.Join(scopes, " "),
+		ExpiresAt: expiresAt.Unix(),
+		NotBefore: now.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// Caller returns the user identified by 'token'. An error, describing why, is returned if 'token'
+// isn't a JWT signed with one of the keys, has expired or isn't valid yet, or its claims don't
+// identify a user. The Caller's Scopes are nil, i.e., unrestricted, unless the token has a 'scope' claim.
+func (jk *JWTKeys) Caller(token string) (Caller, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Caller{}, errors.New("malformed token, expected 'header.claims.signature'")
+	}
+	var header jwtHeader
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return Caller{}, fmt.Errorf("malformed header: %w", err)
+	}
+	if header.Alg != jwtAlg {
+		return Caller{}, fmt.Errorf("unsupported algorithm %q, must be %s", header.Alg, jwtAlg)
+	}
+	key, ok := jk.keys[header.KID]
+	if !ok {
+		return Caller{}, fmt.Errorf("unknown kid %q", header.KID)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, jwtSignature(key, parts[0]+"."+parts[1])) {
+		return Caller{}, errors.New("invalid signature")
+	}
+
+	var claims JWTClaims
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return Caller{}, fmt.Errorf("malformed claims: %w", err)
+	}
+	now := jk.now()
+	if claims.ExpiresAt == 0 {
+		return Caller{}, errors.New("the 'exp' claim is required")
+	}
+	if expires := time.Unix(claims.ExpiresAt, 0); !now.Before(expires) {
+		return Caller{}, fmt.Errorf("expired at %s", expires.UTC().Format(time.RFC3339))
+	}
+	if notBefore := time.Unix(claims.NotBefore, 0); claims.NotBefore != 0 && now.Before(notBefore) {
+		return Caller{}, fmt.Errorf("not valid until %s", notBefore.UTC().Format(time.RFC3339))
+	}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil || userID < 1 {
+		return Caller{}, fmt.Errorf("invalid 'sub' claim %q, must be a user ID", claims.Subject)
+	}
+	if claims.AccountID < 1 {
+		return Caller{}, fmt.Errorf("invalid 'accountid' claim %d", claims.AccountID)
+	}
+	role, ok := roles[strings.ToLower(claims.Role)]
+	if !ok {
+		return Caller{}, fmt.Errorf("unknown 'role' claim %q, must be one of 'primary', 'unrestricted', or 'restricted'", claims.Role)
+	}
+	var scopes []Scope
+	if claims.Scope != "" {
+		if scopes, err = ParseScopes(strings.Join(strings.Fields(claims.Scope), ",")); err != nil {
+			return Caller{}, fmt.Errorf("invalid 'scope' claim: %w", err)
+		}
+	}
+	return Caller{UserID: userID, AccountID: claims.AccountID, Role: role, Scopes: scopes}, nil
+}
+
+// roleName returns the name of 'role' used in the 'role' claim
+func roleName(role domain.Role) (string, error) {
+	for name, r := range roles {
+		if r == role {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown role %d", role)
+}
+
+// jwtSignature returns the HS256 signature of 'signingInput', the encoded header and claims, using 'key'
+func jwtSignature(key []byte, signingInput string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signingInput))
+	return mac.Sum(nil)
+}
+
+// decodeJWTSegment decodes 'segment', base64url encoded JSON, into 'v'
+func decodeJWTSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
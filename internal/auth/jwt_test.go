@@ -0,0 +1,243 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/clock"
+	"github.com/youngkin/mockvideo/internal/domain"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testJWTKeys = `
+# kid      key
+2020-09    0123456789abcdef0123456789abcdef
+2020-10    fedcba9876543210fedcba9876543210
+`
+
+// jwtNow is the time according to the Clock of the JWTKeys returned by newJWTKeys
+var jwtNow = time.Date(2020, time.October, 1, 12, 0, 0, 0, time.UTC)
+
+func newJWTKeys(t *testing.T) *JWTKeys {
+	keys, err := ParseJWTKeys(strings.NewReader(testJWTKeys))
+	if err != nil {
+		t.Fatalf("error %s was not expected parsing the keys", err)
+	}
+	if err = keys.SetClock(clock.NewFrozen(jwtNow)); err != nil {
+		t.Fatalf("error %s was not expected setting the clock", err)
+	}
+	return keys
+}
+
+func validClaims() JWTClaims {
+	return JWTClaims{Subject: "7", AccountID: 3, Role: "primary", ExpiresAt: jwtNow.Add(time.Hour).Unix()}
+}
+
+func TestParseJWTKeys(t *testing.T) {
+	tcs := []struct {
+		testName    string
+		keys        string
+		expectedLen int
+		expectErr   bool
+	}{
+		{testName: "testValid", keys: testJWTKeys, expectedLen: 2},
+		{testName: "testNoKeys", keys: "# no keys\n", expectErr: true},
+		{testName: "testMissingField", keys: "2020-10", expectErr: true},
+		{testName: "testShortKey", keys: "2020-10 abc", expectErr: true},
+		{testName: "testDuplicateKID", keys: "2020-10 0123456789abcdef0123456789abcdef\n2020-10 fedcba9876543210fedcba9876543210", expectErr: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			keys, err := ParseJWTKeys(strings.NewReader(tc.keys))
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error %t, got %v", tc.expectErr, err)
+			}
+			if err == nil && keys.Len() != tc.expectedLen {
+				t.Errorf("expected %d keys, got %d", tc.expectedLen, keys.Len())
+			}
+		})
+	}
+}
+
+func TestJWTKeysCaller(t *testing.T) {
+	keys := newJWTKeys(t)
+	other, err := ParseJWTKeys(strings.NewReader("2020-10 00000000000000000000000000000000\n2019-01 0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("error %s was not expected parsing the keys", err)
+	}
+	sign := func(k *JWTKeys, kid string, claims JWTClaims) string {
+		token, err := k.Sign(kid, claims)
+		if err != nil {
+			t.Fatalf("error %s was not expected signing a token", err)
+		}
+		return token
+	}
+	withClaims := func(change func(c *JWTClaims)) string {
+		c := validClaims()
+		change(&c)
+		return sign(keys, "2020-10", c)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"2020-10"}`)) + "." +
+		strings.Split(sign(keys, "2020-10", validClaims()), ".")[1] + "."
+
+	tcs := []struct {
+		testName       string
+		token          string
+		expectedCaller Caller
+		expectErr      bool
+	}{
+		{
+			testName:       "testValid",
+			token:          sign(keys, "2020-10", validClaims()),
+			expectedCaller: Caller{UserID: 7, AccountID: 3, Role: domain.Primary},
+		},
+		{
+			testName:       "testPreviousKey",
+			token:          sign(keys, "2020-09", validClaims()),
+			expectedCaller: Caller{UserID: 7, AccountID: 3, Role: domain.Primary},
+		},
+		{
+			testName:       "testScope",
+			token:          withClaims(func(c *JWTClaims) { c.Scope = "users:read  users:write" }),
+			expectedCaller: Caller{UserID: 7, AccountID: 3, Role: domain.Primary, Scopes: []Scope{ScopeUsersRead, ScopeUsersWrite}},
+		},
+		{testName: "testMalformed", token: "notajwt", expectErr: true},
+		{testName: "testWrongKey", token: sign(other, "2020-10", validClaims()), expectErr: true},
+		{testName: "testUnknownKID", token: sign(other, "2019-01", validClaims()), expectErr: true},
+		{testName: "testAlgNone", token: unsigned, expectErr: true},
+		{testName: "testExpired", token: withClaims(func(c *JWTClaims) { c.ExpiresAt = jwtNow.Unix() }), expectErr: true},
+		{testName: "testNoExpiry", token: withClaims(func(c *JWTClaims) { c.ExpiresAt = 0 }), expectErr: true},
+		{testName: "testNotYetValid", token: withClaims(func(c *JWTClaims) { c.NotBefore = jwtNow.Add(time.Minute).Unix() }), expectErr: true},
+		{testName: "testInvalidSubject", token: withClaims(func(c *JWTClaims) { c.Subject = "jsmith" }), expectErr: true},
+		{testName: "testInvalidAccountID", token: withClaims(func(c *JWTClaims) { c.AccountID = 0 }), expectErr: true},
+		{testName: "testUnknownRole", token: withClaims(func(c *JWTClaims) { c.Role = "superuser" }), expectErr: true},
+		{testName: "testUnknownScope", token: withClaims(func(c *JWTClaims) { c.Scope = "users:delete" }), expectErr: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			caller, err := keys.Caller(tc.token)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error %t, got %v", tc.expectErr, err)
+			}
+			if !reflect.DeepEqual(caller, tc.expectedCaller) {
+				t.Errorf("expected caller %+v, got %+v", tc.expectedCaller, caller)
+			}
+		})
+	}
+}
+
+func TestJWTKeysIssue(t *testing.T) {
+	keys := newJWTKeys(t)
+	caller := Caller{UserID: 7, AccountID: 3, Role: domain.Restricted, Scopes: []Scope{ScopeUsersRead}}
+
+	token, expiresAt, err := keys.Issue(caller, time.Hour)
+	if err != nil {
+		t.Fatalf("error %s was not expected issuing a token", err)
+	}
+	if !expiresAt.Equal(jwtNow.Add(time.Hour)) {
+		t.Errorf("expected the token to expire at %s, got %s", jwtNow.Add(time.Hour), expiresAt)
+	}
+	actual, err := keys.Caller(token)
+	if err != nil {
+		t.Fatalf("error %s was not expected verifying an issued token", err)
+	}
+	if !reflect.DeepEqual(actual, caller) {
+		t.Errorf("expected caller %+v, got %+v", caller, actual)
+	}
+
+	// Tokens are issued with the last key
+	current, err := ParseJWTKeys(strings.NewReader("2020-10 fedcba9876543210fedcba9876543210"))
+	if err != nil {
+		t.Fatalf("error %s was not expected parsing the keys", err)
+	}
+	if err = current.SetClock(clock.NewFrozen(jwtNow)); err != nil {
+		t.Fatalf("error %s was not expected setting the clock", err)
+	}
+	if _, err = current.Caller(token); err != nil {
+		t.Errorf("expected the token to be signed with the last key, got error %s", err)
+	}
+
+	if err = keys.SetClock(clock.NewFrozen(expiresAt)); err != nil {
+		t.Fatalf("error %s was not expected setting the clock", err)
+	}
+	if _, err = keys.Caller(token); err == nil {
+		t.Errorf("expected the token to have expired")
+	}
+
+	if _, _, err = keys.Issue(caller, 0); err == nil {
+		t.Errorf("expected an error issuing a token without a ttl")
+	}
+	if _, _, err = keys.Issue(Caller{UserID: 7, AccountID: 3, Role: domain.Role(42)}, time.Hour); err == nil {
+		t.Errorf("expected an error issuing a token for an unknown role")
+	}
+}
+
+func TestJWTUnaryServerInterceptor(t *testing.T) {
+	keys := newJWTKeys(t)
+	token, err := keys.Sign("2020-10", validClaims())
+	if err != nil {
+		t.Fatalf("error %s was not expected signing a token", err)
+	}
+
+	tcs := []struct {
+		testName       string
+		method         string
+		authorization  string
+		others         bool
+		expectedCode   codes.Code
+		expectedUserID int
+	}{
+		{testName: "testJWT", authorization: "Bearer " + token, expectedCode: codes.OK, expectedUserID: 7},
+		{testName: "testNoToken", expectedCode: codes.Unauthenticated},
+		{testName: "testInvalidJWT", authorization: "Bearer " + token + "x", expectedCode: codes.Unauthenticated},
+		{testName: "testOtherToken", authorization: "Bearer " + billingKey, expectedCode: codes.Unauthenticated},
+		{testName: "testOtherTokenAllowed", authorization: "Bearer " + billingKey, others: true, expectedCode: codes.OK},
+		{testName: "testHealthCheck", method: "/grpc.health.v1.Health/Check", expectedCode: codes.OK},
+		{testName: "testLogin", method: "/accountd.UserServer/Login", expectedCode: codes.OK},
+		{testName: "testUserServerHealth", method: "/accountd.UserServer/Health", expectedCode: codes.OK},
+		{testName: "testOtherServiceLogin", method: "/accountd.OtherServer/Login", expectedCode: codes.Unauthenticated},
+		{testName: "testOtherServiceHealth", method: "/other.Health/Health", expectedCode: codes.Unauthenticated},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.authorization != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tc.authorization))
+			}
+			method := "/accountd.UserServer/GetUser"
+			if tc.method != "" {
+				method = tc.method
+			}
+			var userID int
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				c, _ := FromContext(ctx)
+				userID = c.UserID
+				return nil, nil
+			}
+
+			interceptor := JWTUnaryServerInterceptor(keys, tc.others, logging.Default())
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+			if code := status.Code(err); code != tc.expectedCode {
+				t.Errorf("expected code %s, got %s", tc.expectedCode, code)
+			}
+			if userID != tc.expectedUserID {
+				t.Errorf("expected user %d, got %d", tc.expectedUserID, userID)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"fmt"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// DefaultPasswordCost is the bcrypt cost passwords are hashed with unless configured otherwise
+	DefaultPasswordCost = bcrypt.DefaultCost
+	// MinPasswordCost and MaxPasswordCost are the bounds of the bcrypt cost. Each increment doubles
+	// the time taken to hash, and to check, a password.
+	MinPasswordCost = bcrypt.MinCost
+	MaxPasswordCost = bcrypt.MaxCost
+)
+
+// HashPassword returns the bcrypt hash of 'password' using 'cost', which must be between MinPasswordCost
+// and MaxPasswordCost. Each hash is salted, hashing the same password twice results in different hashes.
+// Passwords longer than domain.MaxPasswordLen bytes can't be hashed.
+func HashPassword(password string, cost int) (string, error) {
+	if cost < MinPasswordCost || cost > MaxPasswordCost {
+		return "", fmt.Errorf("the cost must be between %d and %d, got %d", MinPasswordCost, MaxPasswordCost, cost)
+	}
+	if len(password) > domain.MaxPasswordLen {
+		return "", fmt.Errorf("the password must have at most %d bytes", domain.MaxPasswordLen)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsPasswordHash returns true if 's' is a bcrypt hash, e.g., one returned by HashPassword
+func IsPasswordHash(s string) bool {
+	_, err := bcrypt.Cost([]byte(s))
+	return err == nil
+}
+
+// CheckPassword returns true if 'password' is the password 'hash', returned by HashPassword, was
+// computed from. It takes as long as hashing the password did. Passwords longer than domain.MaxPasswordLen
+// bytes never match, bcrypt would otherwise ignore the excess bytes.
+func CheckPassword(hash, password string) bool {
+	if len(password) > domain.MaxPasswordLen {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+)
+
+func TestHashPassword(t *testing.T) {
+	tcs := []struct {
+		testName  string
+		password  string
+		cost      int
+		expectErr bool
+	}{
+		{testName: "testHash", password: "alksdf98423)*(&#", cost: MinPasswordCost},
+		{testName: "testMaxLen", password: strings.Repeat("x", domain.MaxPasswordLen), cost: MinPasswordCost},
+		{testName: "testTooLong", password: strings.Repeat("x", domain.MaxPasswordLen+1), cost: MinPasswordCost, expectErr: true},
+		{testName: "testCostTooLow", password: "pw", cost: MinPasswordCost - 1, expectErr: true},
+		{testName: "testCostTooHigh", password: "pw", cost: MaxPasswordCost + 1, expectErr: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			hash, err := HashPassword(tc.password, tc.cost)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error %t, got %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if hash == tc.password || !IsPasswordHash(hash) {
+				t.Errorf("expected a bcrypt hash, got %q", hash)
+			}
+			if !CheckPassword(hash, tc.password) {
+				t.Errorf("expected the hash to match the password")
+			}
+			if CheckPassword(hash, tc.password+"x") {
+				t.Errorf("expected the hash not to match another password")
+			}
+		})
+	}
+}
+
+func TestIsPasswordHash(t *testing.T) {
+	hash, err := HashPassword("pw", MinPasswordCost)
+	if err != nil {
+		t.Fatalf("error %s was not expected hashing a password", err)
+	}
+	for s, expected := range map[string]bool{hash: true, "pw": false, "": false, "$2a$04$tooshort": false} {
+		if actual := IsPasswordHash(s); actual != expected {
+			t.Errorf("expected IsPasswordHash(%q) to be %t, got %t", s, expected, actual)
+		}
+	}
+}
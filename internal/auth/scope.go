@@ -0,0 +1,49 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scope is an operation a caller is allowed to perform
+type Scope string
+
+const (
+	// ScopeUsersRead allows reading users and accounts
+	ScopeUsersRead Scope = "users:read"
+	// ScopeUsersWrite allows creating, updating, and deleting users and accounts
+	ScopeUsersWrite Scope = "users:write"
+	// ScopeAdmin allows every operation, including those of the admin endpoints
+	ScopeAdmin Scope = "admin"
+)
+
+// scopes are the valid Scopes
+var scopes = map[Scope]bool{
+	ScopeUsersRead:  true,
+	ScopeUsersWrite: true,
+	ScopeAdmin:      true,
+}
+
+// ParseScopes returns the Scopes in 's', a comma separated list, e.g., 'users:read,users:write'.
+// At least one Scope is required.
+func ParseScopes(s string) ([]Scope, error) {
+	parsed := []Scope{}
+	for _, name := range strings.Split(s, ",") {
+		scope := Scope(strings.TrimSpace(name))
+		if scope == "" {
+			continue
+		}
+		if !scopes[scope] {
+			return nil, fmt.Errorf("unknown scope %q, must be one of 'users:read', 'users:write', or 'admin'", scope)
+		}
+		parsed = append(parsed, scope)
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("at least one scope required, got %q", s)
+	}
+	return parsed, nil
+}
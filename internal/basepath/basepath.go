@@ -0,0 +1,113 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package basepath
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+)
+
+type locationKey struct{}
+
+// location is what's needed, in addition to a resource's path, to build its HREF
+type location struct {
+	// origin is the scheme and host clients use to reach the service, it's empty for relative HREFs
+	origin string
+	// prefix is the path prefix removed by a proxy before forwarding the request, see X-Forwarded-Prefix
+	prefix string
+	// base is the service's base path
+	base string
+}
+
+func locationFromContext(ctx context.Context) location {
+	loc, _ := ctx.Value(locationKey{}).(location)
+	return loc
+}
+
+// Clean validates 'base' and returns it in canonical form, with a leading '/' and no trailing '/',
+// e.g., 'accountd/' becomes '/accountd'. An empty base path, or '/', is returned as "", meaning
+// resources are served at their bare paths.
+func Clean(base string) (string, error) {
+	base = strings.Trim(strings.TrimSpace(base), "/")
+	if base == "" {
+		return "", nil
+	}
+	for _, segment := range strings.Split(base, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", fmt.Errorf("invalid base path %q, segments must be non-empty and can't be '.' or '..'", base)
+		}
+		if strings.ContainsAny(segment, "?#%") {
+			return "", fmt.Errorf("invalid base path %q, it can't contain '?', '#', or '%%'", base)
+		}
+	}
+	return "/" + base, nil
+}
+
+// NewContext returns a copy of 'ctx' carrying the base path 'base'
+func NewContext(ctx context.Context, base string) context.Context {
+	loc := locationFromContext(ctx)
+	loc.base = base
+	return context.WithValue(ctx, locationKey{}, loc)
+}
+
+// FromContext returns the base path carried by 'ctx', or "" if there is none
+func FromContext(ctx context.Context) string {
+	return locationFromContext(ctx).base
+}
+
+// HREF returns the HREF of the resource at 'path', a bare path such as '/users/1' optionally
+// followed by a query. It's prefixed by the base path in 'ctx' and, if they're in 'ctx', the path
+// prefix removed by a proxy and the origin of the request, see LocationMiddleware. For example,
+// '/users/1' becomes 'https://mockvideo.kube/accountd/users/1'.
+func HREF(ctx context.Context, path string) string {
+	loc := locationFromContext(ctx)
+	return loc.origin + loc.prefix + loc.base + path
+}
+
+// Middleware returns middleware that serves requests whose path is 'base', a base path returned by
+// Clean, or is under it. The base path is removed from the request's URL and added to its context,
+// see HREF. Other requests are passed to 'notFound'. Requests are passed through unchanged if 'base'
+// is empty.
+func Middleware(base string, notFound http.Handler) (func(http.Handler) http.Handler, error) {
+	if base != "" && (!strings.HasPrefix(base, "/") || strings.HasSuffix(base, "/")) {
+		return nil, fmt.Errorf("base path %q must start, and mustn't end, with '/', see Clean", base)
+	}
+	if notFound == nil {
+		return nil, errors.New("non-nil notFound http.Handler required")
+	}
+	return func(next http.Handler) http.Handler {
+		if base == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := strings.TrimPrefix(r.URL.Path, base)
+			if len(path) == len(r.URL.Path) || (path != "" && !strings.HasPrefix(path, "/")) {
+				// Not under 'base', e.g., '/accountdx' when 'base' is '/accountd'
+				notFound.ServeHTTP(w, r)
+				return
+			}
+			if path == "" {
+				path = "/"
+			}
+			r2 := r.Clone(NewContext(r.Context(), base))
+			r2.URL.Path = path
+			r2.URL.RawPath = ""
+			next.ServeHTTP(w, r2)
+		})
+	}, nil
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor that adds 'base' to the context of each RPC so
+// that the HREFs of the resources it returns, which are HTTP resources, include the base path
+func UnaryServerInterceptor(base string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(NewContext(ctx, base), req)
+	}
+}
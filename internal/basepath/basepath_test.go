@@ -0,0 +1,112 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package basepath
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClean(t *testing.T) {
+	tcs := []struct {
+		testName  string
+		base      string
+		expected  string
+		expectErr bool
+	}{
+		{testName: "testEmpty", base: "", expected: ""},
+		{testName: "testRoot", base: "/", expected: ""},
+		{testName: "testCanonical", base: "/accountd", expected: "/accountd"},
+		{testName: "testSlashesAdded", base: "accountd/", expected: "/accountd"},
+		{testName: "testMultipleSegments", base: "/mockvideo/accountd", expected: "/mockvideo/accountd"},
+		{testName: "testEmptySegment", base: "/mockvideo//accountd", expectErr: true},
+		{testName: "testDotDot", base: "/mockvideo/..", expectErr: true},
+		{testName: "testQuery", base: "/accountd?x=1", expectErr: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			actual, err := Clean(tc.base)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error %t, got %v", tc.expectErr, err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		base               string
+		path               string
+		expectedHTTPStatus int
+		expectedPath       string
+		expectedHREF       string
+	}{
+		{
+			testName:           "testNoBasePath",
+			path:               "/users/1",
+			expectedHTTPStatus: http.StatusOK,
+			expectedPath:       "/users/1",
+			expectedHREF:       "/users/1",
+		},
+		{
+			testName:           "testUnderBasePath",
+			base:               "/accountd",
+			path:               "/accountd/users/1",
+			expectedHTTPStatus: http.StatusOK,
+			expectedPath:       "/users/1",
+			expectedHREF:       "/accountd/users/1",
+		},
+		{
+			testName:           "testBasePathOnly",
+			base:               "/accountd",
+			path:               "/accountd",
+			expectedHTTPStatus: http.StatusOK,
+			expectedPath:       "/",
+			expectedHREF:       "/accountd/users/1",
+		},
+		{
+			testName:           "testBarePath",
+			base:               "/accountd",
+			path:               "/users/1",
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+		{
+			testName:           "testSharedPrefix",
+			base:               "/accountd",
+			path:               "/accountdhealth",
+			expectedHTTPStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			var path, href string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				href = HREF(r.Context(), "/users/1")
+			})
+			m, err := Middleware(tc.base, http.NotFoundHandler())
+			if err != nil {
+				t.Fatalf("error '%s' was not expected getting the middleware", err)
+			}
+
+			rr := httptest.NewRecorder()
+			m(next).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Fatalf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, rr.Code)
+			}
+			if path != tc.expectedPath || href != tc.expectedHREF {
+				t.Errorf("expected path %s and HREF %s, got %s and %s", tc.expectedPath, tc.expectedHREF, path, href)
+			}
+		})
+	}
+}
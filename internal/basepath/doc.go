@@ -0,0 +1,16 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package basepath serves a service's resources under a deployment specific base path, e.g.,
+// '/accountd', so that the paths used without an ingress that rewrites them match those used with
+// one. Middleware removes the base path from each request's URL before it's routed, so handlers and
+// path based rules, e.g., access log rules, see the bare path, e.g., '/users/1'. Handlers build the
+// paths they return to the client, in HREF fields and Location headers, with HREF so that the paths
+// include the base path.
+//
+// LocationMiddleware makes the HREFs absolute, e.g., 'https://mockvideo.kube/accountd/users/1',
+// and, when the service is behind a proxy that rewrites paths, includes the path prefix removed by
+// the proxy. The proxy's X-Forwarded-Proto, X-Forwarded-Host, and X-Forwarded-Prefix headers are
+// only used when they're trusted.
+package basepath
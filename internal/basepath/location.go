@@ -0,0 +1,82 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package basepath
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Headers set by proxies, e.g., an ingress, describing the request received by the proxy
+const (
+	// ForwardedProtoHeader is the scheme, 'http' or 'https', used by the client
+	ForwardedProtoHeader = "X-Forwarded-Proto"
+	// ForwardedHostHeader is the host, and optionally port, the client sent the request to
+	ForwardedHostHeader = "X-Forwarded-Host"
+	// ForwardedPrefixHeader is the path prefix the proxy removed before forwarding the request
+	ForwardedPrefixHeader = "X-Forwarded-Prefix"
+)
+
+// Origin returns the scheme and host clients use to reach the service, e.g., 'https://mockvideo.kube',
+// based on 'r'. If 'trustForwarded' is true valid X-Forwarded-Proto and X-Forwarded-Host headers
+// take precedence over the request's own scheme and Host header. They should only be trusted when
+// every request is received via a proxy that sets them, otherwise a client can choose the HREFs it's sent.
+func Origin(r *http.Request, trustForwarded bool) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+	if trustForwarded {
+		if proto := strings.ToLower(firstValue(r.Header.Get(ForwardedProtoHeader))); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if fwdHost := firstValue(r.Header.Get(ForwardedHostHeader)); isValidHost(fwdHost) {
+			host = fwdHost
+		}
+	}
+	if !isValidHost(host) {
+		return ""
+	}
+	return scheme + "://" + host
+}
+
+// LocationMiddleware returns middleware that adds what's needed to build HREFs, other than the base
+// path, to each request's context. If 'absolute' is true HREFs include the request's origin, see
+// Origin. If 'trustForwarded' is true they include a valid X-Forwarded-Prefix, e.g., the '/accountd'
+// removed by an ingress that routes '/accountd/users' to the service's '/users'. Requests are
+// passed through unchanged if both are false.
+func LocationMiddleware(absolute, trustForwarded bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !absolute && !trustForwarded {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			loc := locationFromContext(r.Context())
+			if absolute {
+				loc.origin = Origin(r, trustForwarded)
+			}
+			if trustForwarded {
+				if prefix, err := Clean(firstValue(r.Header.Get(ForwardedPrefixHeader))); err == nil {
+					loc.prefix = prefix
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), locationKey{}, loc)))
+		})
+	}
+}
+
+// firstValue returns the first of the comma separated values of a header. Proxies append to the
+// value, so the first is the one set by the proxy nearest to the client.
+func firstValue(v string) string {
+	return strings.TrimSpace(strings.SplitN(v, ",", 2)[0])
+}
+
+// isValidHost returns true if 'host' is a non-empty host, optionally with a port, that can't
+// change the meaning of the URL it's included in
+func isValidHost(host string) bool {
+	return host != "" && !strings.ContainsAny(host, "/\\?#@ \t")
+}
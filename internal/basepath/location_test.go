@@ -0,0 +1,92 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package basepath
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLocationMiddleware(t *testing.T) {
+	tcs := []struct {
+		testName       string
+		absolute       bool
+		trustForwarded bool
+		tls            bool
+		headers        map[string]string
+		expectedHREF   string
+	}{
+		{
+			testName:     "testRelative",
+			headers:      map[string]string{ForwardedHostHeader: "mockvideo.kube", ForwardedPrefixHeader: "/mv"},
+			expectedHREF: "/accountd/users/1",
+		},
+		{
+			testName:     "testAbsolute",
+			absolute:     true,
+			expectedHREF: "http://accountd:5000/accountd/users/1",
+		},
+		{
+			testName:     "testAbsoluteTLS",
+			absolute:     true,
+			tls:          true,
+			expectedHREF: "https://accountd:5000/accountd/users/1",
+		},
+		{
+			testName:     "testForwardedNotTrusted",
+			absolute:     true,
+			headers:      map[string]string{ForwardedProtoHeader: "https", ForwardedHostHeader: "mockvideo.kube", ForwardedPrefixHeader: "/mv"},
+			expectedHREF: "http://accountd:5000/accountd/users/1",
+		},
+		{
+			testName:       "testForwarded",
+			absolute:       true,
+			trustForwarded: true,
+			headers:        map[string]string{ForwardedProtoHeader: "https", ForwardedHostHeader: "mockvideo.kube, proxy.internal", ForwardedPrefixHeader: "/mv"},
+			expectedHREF:   "https://mockvideo.kube/mv/accountd/users/1",
+		},
+		{
+			testName:       "testForwardedPrefixOnly",
+			trustForwarded: true,
+			headers:        map[string]string{ForwardedHostHeader: "mockvideo.kube", ForwardedPrefixHeader: "/mv"},
+			expectedHREF:   "/mv/accountd/users/1",
+		},
+		{
+			testName:       "testInvalidForwardedIgnored",
+			absolute:       true,
+			trustForwarded: true,
+			headers:        map[string]string{ForwardedProtoHeader: "gopher", ForwardedHostHeader: "evil.com/x?", ForwardedPrefixHeader: "/../mv"},
+			expectedHREF:   "http://accountd:5000/accountd/users/1",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			var href string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				href = HREF(r.Context(), "/users/1")
+			})
+			base, err := Middleware("/accountd", http.NotFoundHandler())
+			if err != nil {
+				t.Fatalf("error '%s' was not expected getting the middleware", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "http://accountd:5000/accountd/users/1", nil)
+			if tc.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			LocationMiddleware(tc.absolute, tc.trustForwarded)(base(next)).ServeHTTP(httptest.NewRecorder(), req)
+
+			if href != tc.expectedHREF {
+				t.Errorf("expected HREF %s, got %s", tc.expectedHREF, href)
+			}
+		})
+	}
+}
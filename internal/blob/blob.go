@@ -0,0 +1,101 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Store stores blobs
+type Store interface {
+	// Put stores the contents of 'r' as the blob 'name' and returns the blob's location. An
+	// existing blob with the same name is replaced.
+	Put(ctx context.Context, name string, r io.Reader) (string, error)
+	// Get returns the contents of the blob 'name'. The caller must close the returned ReadCloser.
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// FileStore is a Store that stores each blob as a file in a directory. A blob's location is a
+// 'file' URL, e.g., 'file:///var/mockvideo/blobs/heap.pprof'.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a FileStore that stores blobs in 'dir'. 'dir' must be an existing directory.
+func NewFileStore(dir string) (*FileStore, error) {
+	if dir == "" {
+		return nil, errors.New("non-empty dir required")
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(absDir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", absDir)
+	}
+	return &FileStore{dir: absDir}, nil
+}
+
+// Put stores the contents of 'r' in the file 'name' in the FileStore's directory. 'name' can't
+// contain a path separator. The blob is written to a temporary file that's renamed once it's
+// complete, so a partially written blob is never visible at the returned location.
+func (fs *FileStore) Put(ctx context.Context, name string, r io.Reader) (string, error) {
+	if err := validName(name); err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	tmp, err := ioutil.TempFile(fs.dir, "."+name+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name()) // Fails harmlessly once the file has been renamed
+
+	if _, err = io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err = tmp.Close(); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(fs.dir, name)
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return "file://" + filepath.ToSlash(path), nil
+}
+
+// Get opens the file 'name' in the FileStore's directory
+func (fs *FileStore) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := validName(name); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return os.Open(filepath.Join(fs.dir, name))
+}
+
+// validName returns an error if 'name' isn't a valid blob name, i.e., a single path element
+func validName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\\`) {
+		return fmt.Errorf("invalid blob name %q", name)
+	}
+	return nil
+}
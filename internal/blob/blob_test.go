@@ -0,0 +1,172 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package blob
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blob")
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a temp dir", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "file")
+	if err = ioutil.WriteFile(file, []byte("x"), 0600); err != nil {
+		t.Fatalf("error %s was not expected creating a file", err)
+	}
+
+	tcs := []struct {
+		testName   string
+		dir        string
+		shouldPass bool
+	}{
+		{testName: "testDir", dir: dir, shouldPass: true},
+		{testName: "testEmptyDir", dir: "", shouldPass: false},
+		{testName: "testMissingDir", dir: filepath.Join(dir, "missing"), shouldPass: false},
+		{testName: "testFile", dir: file, shouldPass: false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			_, err := NewFileStore(tc.dir)
+			if tc.shouldPass && err != nil {
+				t.Errorf("error %s was not expected", err)
+			}
+			if !tc.shouldPass && err == nil {
+				t.Errorf("expected an error, got none")
+			}
+		})
+	}
+}
+
+func TestFileStorePut(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blob")
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a temp dir", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fs, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a FileStore", err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tcs := []struct {
+		testName   string
+		ctx        context.Context
+		name       string
+		content    string
+		shouldPass bool
+	}{
+		{testName: "testPut", ctx: context.Background(), name: "heap.pprof", content: "profile", shouldPass: true},
+		{testName: "testReplace", ctx: context.Background(), name: "heap.pprof", content: "newer profile", shouldPass: true},
+		{testName: "testPathSeparator", ctx: context.Background(), name: "../heap.pprof", content: "profile", shouldPass: false},
+		{testName: "testEmptyName", ctx: context.Background(), name: "", content: "profile", shouldPass: false},
+		{testName: "testCanceled", ctx: canceled, name: "canceled.pprof", content: "profile", shouldPass: false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			location, err := fs.Put(tc.ctx, tc.name, strings.NewReader(tc.content))
+			if !tc.shouldPass {
+				if err == nil {
+					t.Errorf("expected an error, got location %s", location)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error %s was not expected", err)
+			}
+
+			expected := "file://" + filepath.ToSlash(filepath.Join(dir, tc.name))
+			if location != expected {
+				t.Errorf("expected location %s, got %s", expected, location)
+			}
+			content, err := ioutil.ReadFile(filepath.Join(dir, tc.name))
+			if err != nil {
+				t.Fatalf("error %s was not expected reading the blob", err)
+			}
+			if string(content) != tc.content {
+				t.Errorf("expected content %q, got %q", tc.content, content)
+			}
+		})
+	}
+
+	// Only the stored blob, no temporary files, should remain
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("error %s was not expected reading the FileStore's directory", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected 1 file, got %d", len(files))
+	}
+}
+
+func TestFileStoreGet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blob")
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a temp dir", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fs, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a FileStore", err)
+	}
+	if _, err = fs.Put(context.Background(), "export.zip", strings.NewReader("archive")); err != nil {
+		t.Fatalf("error %s was not expected putting a blob", err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tcs := []struct {
+		testName   string
+		ctx        context.Context
+		name       string
+		shouldPass bool
+	}{
+		{testName: "testGet", ctx: context.Background(), name: "export.zip", shouldPass: true},
+		{testName: "testMissing", ctx: context.Background(), name: "missing.zip", shouldPass: false},
+		{testName: "testPathSeparator", ctx: context.Background(), name: "../export.zip", shouldPass: false},
+		{testName: "testCanceled", ctx: canceled, name: "export.zip", shouldPass: false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			rc, err := fs.Get(tc.ctx, tc.name)
+			if !tc.shouldPass {
+				if err == nil {
+					rc.Close()
+					t.Errorf("expected an error getting %s", tc.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error %s was not expected", err)
+			}
+			defer rc.Close()
+
+			content, err := ioutil.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("error %s was not expected reading the blob", err)
+			}
+			if string(content) != "archive" {
+				t.Errorf("expected content %q, got %q", "archive", content)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package blob stores opaque binary objects, e.g., diagnostic artifacts like heap profiles, that are
+too large or too short-lived to belong in the database.
+
+Store is the abstraction used by the rest of mockvideo. FileStore is a Store backed by a directory,
+typically a volume mounted into the service's container. A Store backed by an object store (e.g.,
+S3 or GCS) can be added without changing Store's users.
+*/
+package blob
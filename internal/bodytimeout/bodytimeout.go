@@ -0,0 +1,131 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package bodytimeout
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ErrTimeout is returned by reads of a request body that wasn't received in the time allowed
+var ErrTimeout = errors.New("request body wasn't received in time")
+
+// IsTimeout returns true if 'err' indicates that a request body wasn't received in time, either
+// because of Middleware's limit or the request context's deadline
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrTimeout) || errors.Is(err, context.DeadlineExceeded)
+}
+
+type connKey struct{}
+
+// ConnContext adds 'c' to 'ctx'. It's used as an http.Server's ConnContext so that Middleware can
+// unblock a read that's waiting for a client that has run out of time.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, c)
+}
+
+// Middleware allows each request's body to be read for up to 'timeout' after the request is
+// passed to the next handler. Reads also stop if the request's context is done. A 'timeout'
+// of 0 doesn't limit the time allowed.
+func Middleware(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			b := &body{
+				ReadCloser: r.Body,
+				ctx:        r.Context(),
+				deadline:   time.Now().Add(timeout),
+				results:    make(chan result, 1),
+			}
+			// HTTP/2 streams share their connection, their reads are unblocked when the handler returns
+			if c, ok := r.Context().Value(connKey{}).(net.Conn); ok && r.ProtoMajor == 1 {
+				b.conn = c
+			}
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.Body = b
+			next.ServeHTTP(w, r2)
+		})
+	}
+}
+
+// result is the result of a read of the underlying body
+type result struct {
+	n   int
+	err error
+}
+
+// body is a request body whose reads fail once its deadline has passed or its context is done
+type body struct {
+	io.ReadCloser
+	ctx      context.Context
+	deadline time.Time
+	conn     net.Conn
+	// buf receives the data read from the underlying body. It's only reused once a read has completed,
+	// an abandoned read may still write to it.
+	buf     []byte
+	results chan result
+	// err is returned by every read once a read has been abandoned
+	err error
+}
+
+// Read reads up to len(p) bytes from the underlying body
+func (b *body) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := b.ctx.Err(); err != nil {
+		return 0, b.abandon(err)
+	}
+	wait := time.Until(b.deadline)
+	if wait <= 0 {
+		return 0, b.abandon(ErrTimeout)
+	}
+
+	if cap(b.buf) < len(p) {
+		b.buf = make([]byte, len(p))
+	}
+	buf := b.buf[:len(p)]
+	go func() {
+		n, err := b.ReadCloser.Read(buf)
+		b.results <- result{n: n, err: err}
+	}()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case res := <-b.results:
+		copy(p, buf[:res.n])
+		return res.n, res.err
+	case <-timer.C:
+		return 0, b.abandon(ErrTimeout)
+	case <-b.ctx.Done():
+		return 0, b.abandon(b.ctx.Err())
+	}
+}
+
+// abandon fails this, and every subsequent, read with 'err' and unblocks any read that's waiting
+// for the client
+func (b *body) abandon(err error) error {
+	b.err = err
+	if b.conn != nil {
+		b.conn.SetReadDeadline(time.Now())
+	}
+	return err
+}
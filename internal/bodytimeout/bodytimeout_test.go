@@ -0,0 +1,137 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package bodytimeout
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decodeHandler decodes a JSON request body, responding with a 408 HTTP status if it isn't
+// received in time
+var decodeHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	var v map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		if IsTimeout(err) {
+			w.WriteHeader(http.StatusRequestTimeout)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+})
+
+// slowWrite sends a request with 'body' to 'addr', writing 'chunkSize' bytes of the body every
+// 'interval', and returns the response's HTTP status
+func slowWrite(t *testing.T, addr, body string, chunkSize int, interval time.Duration) int {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("error %s was not expected connecting to the server", err)
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "POST / HTTP/1.1\r\nHost: %s\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n", addr, len(body))
+	// The response may arrive before the body has been written
+	go func() {
+		for i := 0; i < len(body); i += chunkSize {
+			end := i + chunkSize
+			if end > len(body) {
+				end = len(body)
+			}
+			if _, err := conn.Write([]byte(body[i:end])); err != nil {
+				return
+			}
+			time.Sleep(interval)
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("error %s was not expected reading the response", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestMiddleware(t *testing.T) {
+	body := `{"name": "mickey dolenz", "email": "mickeyd@gmail.com"}`
+
+	tcs := []struct {
+		testName       string
+		timeout        time.Duration
+		chunkSize      int
+		interval       time.Duration
+		expectedStatus int
+	}{
+		{
+			testName:       "testReceivedInTime",
+			timeout:        time.Second,
+			chunkSize:      len(body),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			testName:       "testSlowWriterInTime",
+			timeout:        time.Second,
+			chunkSize:      20,
+			interval:       10 * time.Millisecond,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			testName:       "testSlowWriterTimesOut",
+			timeout:        100 * time.Millisecond,
+			chunkSize:      5,
+			interval:       50 * time.Millisecond,
+			expectedStatus: http.StatusRequestTimeout,
+		},
+		{
+			testName:       "testNoTimeout",
+			chunkSize:      20,
+			interval:       10 * time.Millisecond,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			s := httptest.NewUnstartedServer(Middleware(tc.timeout)(decodeHandler))
+			s.Config.ConnContext = ConnContext
+			s.Start()
+			defer s.Close()
+
+			status := slowWrite(t, s.Listener.Addr().String(), body, tc.chunkSize, tc.interval)
+			if status != tc.expectedStatus {
+				t.Errorf("expected HTTP status %d, got %d", tc.expectedStatus, status)
+			}
+		})
+	}
+}
+
+func TestMiddlewareCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`)).WithContext(ctx)
+	var readErr error
+	Middleware(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = r.Body.Read(make([]byte, 2))
+	})).ServeHTTP(rr, r)
+
+	if readErr != context.Canceled {
+		t.Errorf("expected error %s, got %v", context.Canceled, readErr)
+	}
+	if IsTimeout(readErr) {
+		t.Errorf("expected a cancelled read to not be a timeout")
+	}
+}
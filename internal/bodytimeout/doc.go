@@ -0,0 +1,16 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package bodytimeout limits the time allowed to receive a request's body. The HTTP server's
+// ReadHeaderTimeout only covers the request's headers, so without a limit a client that trickles
+// its body, a few bytes at a time, ties up a handler, and anything it holds, e.g., a bulkhead slot,
+// indefinitely. Middleware wraps each request's body so that reads fail with ErrTimeout once the
+// time allowed has passed, or with the context's error if the request is cancelled, e.g., using
+// 'POST /admin/requests/{id}/cancel'. Handlers report ErrTimeout to the client as a 408 (Request
+// Timeout) HTTP status.
+//
+// A read that's still waiting for the client when it fails is abandoned. For HTTP/1 connections
+// it's unblocked by setting the connection's read deadline, which requires the server to record
+// its connections using ConnContext. The connection is closed once the response is written.
+package bodytimeout
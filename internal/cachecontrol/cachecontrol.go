@@ -0,0 +1,165 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cachecontrol
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRules are the Policy rules used when none are configured. The health and readiness checks,
+// and an account's usage counts, can be cached briefly. Everything else, including every response
+// containing users, isn't cached.
+var DefaultRules = []string{"/accountdhealth=5", "/readyz=5", "/accounts/*/usage=private:30"}
+
+// noStore is the Cache-Control header of responses that mustn't be cached
+const noStore = "no-store"
+
+// expired is the Expires header of responses that mustn't be cached, for HTTP/1.0 caches
+var expired = time.Unix(0, 0).UTC().Format(http.TimeFormat)
+
+// Policy decides the Cache-Control and Expires headers of each response, see the package documentation
+type Policy struct {
+	rules []rule
+}
+
+// rule applies to the paths matching 'segments', where '*' matches any single segment
+type rule struct {
+	segments []string
+	// maxAge is 0 if responses mustn't be cached
+	maxAge  int
+	private bool
+}
+
+// NewPolicy returns a Policy made up of 'rules', each either 'path', 'path=N', or 'path=private:N'
+// where N is a positive integer, see the package documentation. Paths must start with '/'.
+func NewPolicy(rules []string) (*Policy, error) {
+	p := &Policy{}
+	seen := make(map[string]bool)
+	for _, r := range rules {
+		r = strings.TrimSpace(r)
+		parts := strings.SplitN(r, "=", 2)
+		path := parts[0]
+		if !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("invalid cache control rule %q, expected 'path', 'path=N', or 'path=private:N' where path starts with '/'", r)
+		}
+		if seen[path] {
+			return nil, fmt.Errorf("invalid cache control rule %q, there's already a rule for %s", r, path)
+		}
+		seen[path] = true
+
+		cr := rule{segments: strings.Split(path, "/")}
+		if len(parts) == 2 {
+			age := parts[1]
+			if strings.HasPrefix(age, "private:") {
+				cr.private = true
+				age = strings.TrimPrefix(age, "private:")
+			}
+			maxAge, err := strconv.ParseUint(age, 10, 31)
+			if err != nil || maxAge == 0 {
+				return nil, fmt.Errorf("invalid cache control rule %q, expected 'path=N' or 'path=private:N' where N is a positive integer", r)
+			}
+			cr.maxAge = int(maxAge)
+		}
+		p.rules = append(p.rules, cr)
+	}
+	return p, nil
+}
+
+// match returns the rule for 'path', or nil if there isn't one
+func (p *Policy) match(path string) *rule {
+	segments := strings.Split(path, "/")
+	for i := range p.rules {
+		if p.rules[i].matches(segments) {
+			return &p.rules[i]
+		}
+	}
+	return nil
+}
+
+// matches returns true if 'segments' matches the rule's segments
+func (r *rule) matches(segments []string) bool {
+	if len(segments) != len(r.segments) {
+		return false
+	}
+	for i, s := range r.segments {
+		if s != "*" && s != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// setHeaders sets the Cache-Control and Expires headers in 'h' for a response with 'status' to a request
+// with 'method' for 'path', unless the handler has already set Cache-Control
+func (p *Policy) setHeaders(h http.Header, method, path string, status int) {
+	if h.Get("Cache-Control") != "" {
+		return
+	}
+	r := p.match(path)
+	if r == nil || r.maxAge == 0 || status != http.StatusOK || (method != http.MethodGet && method != http.MethodHead) {
+		h.Set("Cache-Control", noStore)
+		h.Set("Expires", expired)
+		return
+	}
+
+	cc := "public, max-age=" + strconv.Itoa(r.maxAge)
+	if r.private {
+		cc = "private, max-age=" + strconv.Itoa(r.maxAge)
+	}
+	h.Set("Cache-Control", cc)
+	h.Set("Expires", time.Now().Add(time.Duration(r.maxAge)*time.Second).UTC().Format(http.TimeFormat))
+}
+
+// Middleware sets the Cache-Control and Expires headers of the responses of the next handler as
+// decided by 'p'
+func Middleware(p *Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&headerWriter{ResponseWriter: w, policy: p, r: r}, r)
+		})
+	}
+}
+
+// headerWriter is an http.ResponseWriter that sets the caching headers just before the response's
+// headers are written, once its status is known
+type headerWriter struct {
+	http.ResponseWriter
+	policy  *Policy
+	r       *http.Request
+	written bool
+}
+
+// WriteHeader sets the caching headers for 'status' and writes it to the underlying http.ResponseWriter
+func (hw *headerWriter) WriteHeader(status int) {
+	if !hw.written {
+		hw.written = true
+		hw.policy.setHeaders(hw.Header(), hw.r.Method, hw.r.URL.Path, status)
+	}
+	hw.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes 'b' to the underlying http.ResponseWriter, setting the caching headers for a 200 (OK)
+// status if WriteHeader wasn't called
+func (hw *headerWriter) Write(b []byte) (int, error) {
+	if !hw.written {
+		hw.WriteHeader(http.StatusOK)
+	}
+	return hw.ResponseWriter.Write(b)
+}
+
+// Flush sends any buffered data to the client if the underlying http.ResponseWriter supports it,
+// setting the caching headers for a 200 (OK) status if WriteHeader wasn't called
+func (hw *headerWriter) Flush() {
+	if !hw.written {
+		hw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := hw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
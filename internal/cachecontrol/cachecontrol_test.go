@@ -0,0 +1,93 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cachecontrol
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewPolicy(t *testing.T) {
+	tcs := []struct {
+		testName  string
+		rules     []string
+		expectErr bool
+	}{
+		{testName: "testDefaultRules", rules: DefaultRules},
+		{testName: "testNoRules"},
+		{testName: "testNoStore", rules: []string{"/metrics", " /readyz=10 "}},
+		{testName: "testRelativePath", rules: []string{"readyz=5"}, expectErr: true},
+		{testName: "testEmptyRule", rules: []string{""}, expectErr: true},
+		{testName: "testZeroMaxAge", rules: []string{"/readyz=0"}, expectErr: true},
+		{testName: "testNegativeMaxAge", rules: []string{"/readyz=-1"}, expectErr: true},
+		{testName: "testNonNumericMaxAge", rules: []string{"/readyz=long"}, expectErr: true},
+		{testName: "testInvalidPrivate", rules: []string{"/readyz=private"}, expectErr: true},
+		{testName: "testDuplicatePath", rules: []string{"/readyz", "/readyz=10"}, expectErr: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			_, err := NewPolicy(tc.rules)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("expected error %t, got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	tcs := []struct {
+		testName        string
+		method          string
+		path            string
+		status          int
+		handlerCC       string
+		expectedCC      string
+		expectedExpired bool
+	}{
+		{testName: "testNoRule", method: http.MethodGet, path: "/users/1", status: http.StatusOK, expectedCC: "no-store", expectedExpired: true},
+		{testName: "testPublic", method: http.MethodGet, path: "/readyz", status: http.StatusOK, expectedCC: "public, max-age=5"},
+		{testName: "testHead", method: http.MethodHead, path: "/accountdhealth", status: http.StatusOK, expectedCC: "public, max-age=5"},
+		{testName: "testPrivateWildcard", method: http.MethodGet, path: "/accounts/42/usage", status: http.StatusOK, expectedCC: "private, max-age=30"},
+		{testName: "testWildcardSegmentsOnly", method: http.MethodGet, path: "/accounts/42/users/usage", status: http.StatusOK, expectedCC: "no-store", expectedExpired: true},
+		{testName: "testNotOK", method: http.MethodGet, path: "/readyz", status: http.StatusServiceUnavailable, expectedCC: "no-store", expectedExpired: true},
+		{testName: "testNotGet", method: http.MethodPost, path: "/readyz", status: http.StatusOK, expectedCC: "no-store", expectedExpired: true},
+		{testName: "testHandlerCacheControl", method: http.MethodGet, path: "/readyz", status: http.StatusOK, handlerCC: "no-cache", expectedCC: "no-cache"},
+		{testName: "testImplicitStatus", method: http.MethodGet, path: "/readyz", expectedCC: "public, max-age=5"},
+	}
+
+	p, err := NewPolicy(DefaultRules)
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a Policy", err)
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.handlerCC != "" {
+					w.Header().Set("Cache-Control", tc.handlerCC)
+				}
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+				w.Write([]byte("OK"))
+			})
+			rr := httptest.NewRecorder()
+			Middleware(p)(next).ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, nil))
+
+			if cc := rr.Header().Get("Cache-Control"); cc != tc.expectedCC {
+				t.Errorf("expected Cache-Control %q, got %q", tc.expectedCC, cc)
+			}
+			expires := rr.Header().Get("Expires")
+			if tc.expectedExpired && expires != expired {
+				t.Errorf("expected Expires %q, got %q", expired, expires)
+			}
+			if !tc.expectedExpired && tc.handlerCC == "" && (expires == "" || expires == expired) {
+				t.Errorf("expected a future Expires, got %q", expires)
+			}
+		})
+	}
+}
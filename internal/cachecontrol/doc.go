@@ -0,0 +1,19 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package cachecontrol sets the Cache-Control and Expires headers of each HTTP response so that proxies
+// and browsers cache responses predictably. Most responses contain users, i.e., personal information,
+// so by default nothing is cached. A Policy is made up of rules, one per path, allowing some responses
+// to be cached for a short time:
+//
+//	/metrics			responses for '/metrics' aren't cached, as for paths without a rule
+//	/readyz=5			responses for '/readyz' can be cached by any cache for 5 seconds
+//	/accounts/*/usage=private:30	responses for '/accounts/{id}/usage' can be cached for 30 seconds,
+//					but only by the caller's own cache, e.g., a browser
+//
+// A '*' segment matches any single segment, otherwise paths are matched exactly. Only 200 (OK) responses
+// to GET and HEAD requests are cached, other responses are sent with 'Cache-Control: no-store'. A
+// Cache-Control header set by the handler itself is left as it is. The DefaultRules allow the health
+// and readiness checks, and an account's usage counts, to be cached briefly.
+package cachecontrol
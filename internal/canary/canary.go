@@ -0,0 +1,116 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package canary
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/internal/metrics"
+)
+
+// Header is the request header that selects the handler, "true" for the canary and "false" for the
+// primary. Canary responses have the header set to "true".
+const Header = "X-Canary"
+
+// Variants, the VariantLabel values of RqstDur
+const (
+	Primary = "primary"
+	Canary  = "canary"
+)
+
+// VariantLabel is the label of RqstDur identifying the handler, Primary or Canary, that handled the request
+const VariantLabel = "variant"
+
+// RqstDur captures the duration of the requests routed by Middleware by handler and status, so the
+// canary's latency and error rate can be compared with the primary's
+var RqstDur = metrics.NewHistogramVec(prometheus.HistogramOpts{
+	Subsystem: "http",
+	Name:      "canary_request_duration_seconds",
+	Help:      "canary and primary request duration distribution in seconds by variant and status",
+	Buckets:   prometheus.DefBuckets,
+}, []string{VariantLabel, "rqstStatus"})
+
+// Middleware routes 'percent' percent of the requests, and those whose Header is "true", to 'canary'
+// rather than the next handler. Requests whose Header is "false" are never routed to 'canary'. The
+// requests are recorded in RqstDur. The next handler is returned as is if 'canary' is nil.
+func Middleware(percent int, canary http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if canary == nil {
+			return next
+		}
+		s := &sampler{percent: uint64(percent)}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			variant, h := Primary, next
+			if toCanary(r, s) {
+				variant, h = Canary, canary
+				w.Header().Set(Header, "true")
+			}
+
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+			h.ServeHTTP(rec, r)
+			RqstDur.WithLabelValues(variant, strconv.Itoa(rec.Status())).Observe(float64(time.Since(start)) / float64(time.Second))
+		})
+	}
+}
+
+// toCanary returns true if 'r' is routed to the canary, as selected by its Header if it has a valid
+// one, otherwise by 's'
+func toCanary(r *http.Request, s *sampler) bool {
+	if h := r.Header.Get(Header); h != "" {
+		if canary, err := strconv.ParseBool(h); err == nil {
+			return canary
+		}
+	}
+	return s.sample()
+}
+
+// sampler selects 'percent' percent of the requests, spread evenly, e.g., every 4th request for 25
+type sampler struct {
+	percent uint64
+	count   uint64
+}
+
+// sample returns true if this request is selected
+func (s *sampler) sample() bool {
+	if s.percent == 0 {
+		return false
+	}
+	n := atomic.AddUint64(&s.count, 1)
+	return n*s.percent%100 < s.percent
+}
+
+// statusRecorder is an http.ResponseWriter that records the HTTP status of the response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records 'status' and writes it to the underlying http.ResponseWriter
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Flush sends any buffered data to the client if the underlying http.ResponseWriter supports it
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Status returns the HTTP status of the response. It's 200 (OK) if WriteHeader wasn't called.
+func (rec *statusRecorder) Status() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package canary
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// variantHandler responds with the 'X-Variant' header set to 'variant' and 'status'
+func variantHandler(variant string, status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Variant", variant)
+		w.WriteHeader(status)
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	tcs := []struct {
+		testName       string
+		percent        int
+		noCanary       bool
+		header         string
+		rqsts          int
+		expectedCanary int
+	}{
+		{testName: "testNoCanaryTraffic", percent: 0, rqsts: 10, expectedCanary: 0},
+		{testName: "testSomeCanaryTraffic", percent: 25, rqsts: 20, expectedCanary: 5},
+		{testName: "testAllCanaryTraffic", percent: 100, rqsts: 10, expectedCanary: 10},
+		{testName: "testHeaderSelectsCanary", percent: 0, header: "true", rqsts: 10, expectedCanary: 10},
+		{testName: "testHeaderSelectsPrimary", percent: 100, header: "false", rqsts: 10, expectedCanary: 0},
+		{testName: "testInvalidHeaderIgnored", percent: 100, header: "maybe", rqsts: 10, expectedCanary: 10},
+		{testName: "testNilCanary", percent: 100, noCanary: true, header: "true", rqsts: 10, expectedCanary: 0},
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(RqstDur)
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			RqstDur.Reset()
+			canary := variantHandler(Canary, http.StatusInternalServerError)
+			if tc.noCanary {
+				canary = nil
+			}
+			h := Middleware(tc.percent, canary)(variantHandler(Primary, http.StatusOK))
+
+			canaryRqsts := 0
+			for i := 0; i < tc.rqsts; i++ {
+				r := httptest.NewRequest(http.MethodGet, "/users", nil)
+				if tc.header != "" {
+					r.Header.Set(Header, tc.header)
+				}
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+
+				isCanary := w.Header().Get("X-Variant") == Canary
+				if isCanary {
+					canaryRqsts++
+				}
+				if isCanary != (w.Header().Get(Header) == "true") {
+					t.Errorf("expected the %s header to be set only on canary responses, got %q", Header, w.Header().Get(Header))
+				}
+			}
+			if canaryRqsts != tc.expectedCanary {
+				t.Errorf("expected %d of %d requests to be routed to the canary, got %d", tc.expectedCanary, tc.rqsts, canaryRqsts)
+			}
+
+			// Each variant's requests are recorded with the variant's status, unless there's no canary
+			expected := map[string]uint64{}
+			if tc.expectedCanary > 0 {
+				expected["canary/500"] = uint64(tc.expectedCanary)
+			}
+			if tc.expectedCanary < tc.rqsts && !tc.noCanary {
+				expected["primary/200"] = uint64(tc.rqsts - tc.expectedCanary)
+			}
+			if observed := observed(t, reg); !reflect.DeepEqual(observed, expected) {
+				t.Errorf("expected the requests %v to be recorded, got %v", expected, observed)
+			}
+		})
+	}
+}
+
+// observed returns the number of requests recorded in RqstDur, registered with 'reg', keyed by variant/status
+func observed(t *testing.T, reg *prometheus.Registry) map[string]uint64 {
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("error %s was not expected gathering the metrics", err)
+	}
+	counts := map[string]uint64{}
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			counts[labels[VariantLabel]+"/"+labels["rqstStatus"]] = m.GetHistogram().GetSampleCount()
+		}
+	}
+	return counts
+}
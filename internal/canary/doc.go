@@ -0,0 +1,17 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package canary supports rolling out an alternate implementation of a handler, e.g., one backed by a new
+repository, safely. Middleware routes a configured percentage of the requests to the alternate, canary,
+handler and the rest to the primary handler. Clients can choose the handler with the X-Canary header:
+
+	X-Canary: true		the request is handled by the canary
+	X-Canary: false		the request is handled by the primary
+
+Responses from the canary have an "X-Canary: true" header. Both handlers' requests are recorded in the
+RqstDur metric, labeled by variant and status, so the canary's latency and error rate can be compared
+with the primary's before more traffic is routed to it.
+*/
+package canary
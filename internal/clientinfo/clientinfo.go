@@ -0,0 +1,130 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package clientinfo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// UserAgentHeader is the HTTP header identifying the client
+	UserAgentHeader = "User-Agent"
+	// VersionHeader is the HTTP header containing the client's version. It overrides the version
+	// in UserAgentHeader.
+	VersionHeader = "X-Client-Version"
+)
+
+const (
+	// Unknown is the label of requests that don't identify their client
+	Unknown = "unknown"
+	// Other is the label of requests from clients that aren't allowed, it's also the version
+	// in the label of requests from allowed clients whose version isn't allowed
+	Other = "other"
+)
+
+// DefaultAllowlist is the Allowlist used when none is configured. Go clients, e.g., accountctl and
+// package client, use Go's default User-Agent.
+var DefaultAllowlist = []string{"curl", "go-http-client"}
+
+type labelKey struct{}
+
+// NewContext returns a copy of 'ctx' carrying the client label 'label'
+func NewContext(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, labelKey{}, label)
+}
+
+// FromContext returns the client label carried by 'ctx', or Unknown if there isn't one
+func FromContext(ctx context.Context) string {
+	if label, ok := ctx.Value(labelKey{}).(string); ok {
+		return label
+	}
+	return Unknown
+}
+
+// Allowlist maps clients, and their versions, to labels, see Label
+type Allowlist struct {
+	// allowed contains the allowed 'name's and 'name/version's
+	allowed map[string]bool
+}
+
+// NewAllowlist returns an Allowlist allowing 'entries'. Each entry is either a client 'name', which
+// allows the client but not its versions, or 'name/version', which allows the client and that
+// version. Entries are case insensitive and can only contain letters, digits, '.', '_', and '-'.
+func NewAllowlist(entries []string) (*Allowlist, error) {
+	a := &Allowlist{allowed: make(map[string]bool, len(entries))}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		parts := strings.Split(entry, "/")
+		if len(parts) > 2 {
+			return nil, fmt.Errorf("invalid client allowlist entry %q, expected 'name' or 'name/version'", entry)
+		}
+		for _, p := range parts {
+			if !isToken(p) {
+				return nil, fmt.Errorf("invalid client allowlist entry %q, expected 'name' or 'name/version'", entry)
+			}
+		}
+		a.allowed[parts[0]] = true
+		a.allowed[entry] = true
+	}
+	return a, nil
+}
+
+// Label returns the label of the client that made 'r', see the package documentation
+func (a *Allowlist) Label(r *http.Request) string {
+	name, version := client(r)
+	if name == "" {
+		return Unknown
+	}
+	if !a.allowed[name] {
+		return Other
+	}
+	if version == "" || !a.allowed[name+"/"+version] {
+		return name + "/" + Other
+	}
+	return name + "/" + version
+}
+
+// Middleware adds the label of the client that made the request to the request's context
+func Middleware(a *Allowlist) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), a.Label(r))))
+		})
+	}
+}
+
+// client returns the lower case name and version of the client that made 'r'. The name is empty
+// if 'r' doesn't identify its client.
+func client(r *http.Request) (name, version string) {
+	// The first product token, e.g., 'curl/7.64.1', identifies the client
+	fields := strings.Fields(r.Header.Get(UserAgentHeader))
+	if len(fields) > 0 {
+		parts := strings.SplitN(strings.ToLower(fields[0]), "/", 2)
+		name = parts[0]
+		if len(parts) == 2 {
+			version = parts[1]
+		}
+	}
+	if v := strings.TrimSpace(r.Header.Get(VersionHeader)); v != "" {
+		version = strings.ToLower(v)
+	}
+	return name, version
+}
+
+// isToken returns true if 's' is a non-empty string of letters, digits, '.', '_', and '-'
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '.' && c != '_' && c != '-' {
+			return false
+		}
+	}
+	return true
+}
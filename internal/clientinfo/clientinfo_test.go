@@ -0,0 +1,101 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package clientinfo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLabel(t *testing.T) {
+	a, err := NewAllowlist([]string{"curl", "AccountCtl/1.2.0", "accountctl/1.3.0"})
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting an Allowlist", err)
+	}
+
+	tcs := []struct {
+		testName  string
+		userAgent string
+		version   string
+		expected  string
+	}{
+		{testName: "testNoHeaders", expected: Unknown},
+		{testName: "testAllowedVersion", userAgent: "accountctl/1.2.0 (linux)", expected: "accountctl/1.2.0"},
+		{testName: "testCaseInsensitive", userAgent: "ACCOUNTCTL/1.3.0", expected: "accountctl/1.3.0"},
+		{testName: "testVersionNotAllowed", userAgent: "accountctl/0.9.0", expected: "accountctl/other"},
+		{testName: "testNameOnlyEntry", userAgent: "curl/7.64.1", expected: "curl/other"},
+		{testName: "testNoVersion", userAgent: "curl", expected: "curl/other"},
+		{testName: "testClientNotAllowed", userAgent: "Mozilla/5.0 (X11; Linux x86_64)", expected: Other},
+		{testName: "testVersionHeaderOverrides", userAgent: "accountctl/0.9.0", version: "1.2.0", expected: "accountctl/1.2.0"},
+		{testName: "testVersionHeaderAlone", version: "1.2.0", expected: Unknown},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if tc.userAgent != "" {
+				r.Header.Set(UserAgentHeader, tc.userAgent)
+			}
+			if tc.version != "" {
+				r.Header.Set(VersionHeader, tc.version)
+			}
+			if label := a.Label(r); label != tc.expected {
+				t.Errorf("expected label %q, got %q", tc.expected, label)
+			}
+		})
+	}
+}
+
+func TestNewAllowlist(t *testing.T) {
+	tcs := []struct {
+		testName   string
+		entries    []string
+		shouldPass bool
+	}{
+		{testName: "testValid", entries: []string{"curl", "accountctl/1.2.0-rc.1", "my_client"}, shouldPass: true},
+		{testName: "testEmpty", entries: []string{}, shouldPass: true},
+		{testName: "testEmptyEntry", entries: []string{""}},
+		{testName: "testEmptyVersion", entries: []string{"curl/"}},
+		{testName: "testTooManyParts", entries: []string{"curl/7/64"}},
+		{testName: "testInvalidCharacters", entries: []string{"curl 7"}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			_, err := NewAllowlist(tc.entries)
+			if tc.shouldPass && err != nil {
+				t.Errorf("error %s was not expected", err)
+			}
+			if !tc.shouldPass && err == nil {
+				t.Errorf("expected an error for entries %v", tc.entries)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	a, err := NewAllowlist(DefaultAllowlist)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting an Allowlist", err)
+	}
+
+	if label := FromContext(context.Background()); label != Unknown {
+		t.Errorf("expected %q without a label, got %q", Unknown, label)
+	}
+
+	var label string
+	h := Middleware(a)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		label = FromContext(r.Context())
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/users", nil)
+	r.Header.Set(UserAgentHeader, "Go-http-client/1.1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if label != "go-http-client/other" {
+		t.Errorf("expected label %q, got %q", "go-http-client/other", label)
+	}
+}
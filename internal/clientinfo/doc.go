@@ -0,0 +1,21 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package clientinfo identifies the client, and its version, that made a request so the platform team
+can see which clients still use deprecated endpoints. The client is taken from the product token
+of the HTTP 'User-Agent' header, e.g., 'accountctl/1.2.0 (linux)' is client 'accountctl' version
+'1.2.0'. A custom 'X-Client-Version' header, if present, overrides the version.
+
+Client names and versions are chosen by callers, so they're mapped through an Allowlist to a label
+with a bounded number of values that can be used in metrics:
+
+		name/version	if 'name/version' is allowed, e.g., 'accountctl/1.2.0'
+		name/other		if 'name' is allowed but its version isn't
+		other			if 'name' isn't allowed
+		unknown			if the request doesn't identify its client
+
+Middleware adds the label to the request's context, see FromContext.
+*/
+package clientinfo
@@ -0,0 +1,57 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time
+type Clock interface {
+	// Now returns the current time in UTC
+	Now() time.Time
+}
+
+// System is the Clock backed by the system time
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now().UTC()
+}
+
+// Frozen is a Clock whose time only changes when it's Set or Advanced. It's safe for concurrent use.
+type Frozen struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFrozen returns a Frozen clock set to 't'
+func NewFrozen(t time.Time) *Frozen {
+	return &Frozen{now: t.UTC()}
+}
+
+// Now returns the clock's current time
+func (f *Frozen) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Set changes the clock's current time to 't'
+func (f *Frozen) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = t.UTC()
+}
+
+// Advance moves the clock's current time forward by 'd'
+func (f *Frozen) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
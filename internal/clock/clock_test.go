@@ -0,0 +1,64 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSystem(t *testing.T) {
+	before := time.Now()
+	now := System.Now()
+	after := time.Now()
+
+	if now.Location() != time.UTC {
+		t.Errorf("expected location UTC, got %s", now.Location())
+	}
+	if now.Before(before) || now.After(after) {
+		t.Errorf("expected a time between %s and %s, got %s", before, after, now)
+	}
+}
+
+func TestFrozen(t *testing.T) {
+	mdt := time.FixedZone("MDT", -6*60*60)
+	start := time.Date(2020, time.July, 4, 3, 30, 0, 0, mdt)
+
+	tcs := []struct {
+		testName string
+		change   func(f *Frozen)
+		expected time.Time
+	}{
+		{
+			testName: "testFrozenUnchanged",
+			change:   func(f *Frozen) {},
+			expected: time.Date(2020, time.July, 4, 9, 30, 0, 0, time.UTC),
+		},
+		{
+			testName: "testFrozenAdvance",
+			change:   func(f *Frozen) { f.Advance(48 * time.Hour) },
+			expected: time.Date(2020, time.July, 6, 9, 30, 0, 0, time.UTC),
+		},
+		{
+			testName: "testFrozenSet",
+			change:   func(f *Frozen) { f.Set(time.Date(2021, time.January, 1, 0, 0, 0, 0, mdt)) },
+			expected: time.Date(2021, time.January, 1, 6, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			f := NewFrozen(start)
+			tc.change(f)
+			now := f.Now()
+			if !now.Equal(tc.expected) || now.Location() != time.UTC {
+				t.Errorf("expected %s, got %s", tc.expected, now)
+			}
+			if !f.Now().Equal(now) {
+				t.Errorf("expected the time to be unchanged between calls")
+			}
+		})
+	}
+}
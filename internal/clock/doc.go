@@ -0,0 +1,18 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package clock provides the current time to services and repositories. Code that records or compares
+against the current time (e.g., user timestamps, activation expiry) gets it from a Clock rather than
+calling time.Now() directly. Production code uses System. Tests use a Frozen clock so the times they
+observe are predictable and can be moved forward, e.g., past an expiry, without sleeping.
+
+All times returned by a Clock are in UTC. Together with the RFC 3339 encoding used by encoding/json
+for time.Time this results in API responses containing times like "2020-07-04T09:30:00Z" regardless
+of the time zone of the host or the database.
+
+Clock isn't used to measure elapsed time (e.g., request durations for metrics), time.Now() and
+time.Since() remain appropriate for that.
+*/
+package clock
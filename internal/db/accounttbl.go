@@ -0,0 +1,258 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+const accountTbl = "accountTbl"
+
+var (
+	insertAccountStmt        = "INSERT INTO account (accountHolderName, nickName, serviceAddress, billingAddress, email, phone) VALUES (?, ?, ?, ?, ?, ?)"
+	insertAddressStmt        = "INSERT INTO address (street, street2, city, region, postalCode, country) VALUES (?, ?, ?, ?, ?, ?)"
+	insertBillingContactStmt = "INSERT INTO billingContact (accountID, name, email, phone, addressID) VALUES (?, ?, ?, ?, ?)"
+	getAccountQuery          = "SELECT a.id, a.accountHolderName, a.nickName, a.serviceAddress, a.billingAddress, a.email, a.phone, " +
+		"bc.name, bc.email, bc.phone, ad.street, ad.street2, ad.city, ad.region, ad.postalCode, ad.country " +
+		"FROM account a LEFT JOIN billingContact bc ON bc.accountID = a.id LEFT JOIN address ad ON ad.id = bc.addressID " +
+		"WHERE a.id = ?"
+)
+
+// AccountTable supports access to the 'account' table
+type AccountTable struct {
+	db *sql.DB
+	// tx is only set when the AccountTable is part of a UnitOfWork, see WithTx
+	tx *sql.Tx
+	// addresses validates the addresses of the accounts' billing contacts, see SetAddressValidator
+	addresses domain.AddressValidator
+}
+
+// NewAccountTable creates a new AccountTable instance with the provided sql.DB instance
+func NewAccountTable(db *sql.DB) (*AccountTable, error) {
+	if db == nil {
+		return nil, errors.New("non-nil sql.DB connection required")
+	}
+	return &AccountTable{db: db}, nil
+}
+
+// SetAddressValidator replaces the AddressValidator used to validate the address of an account's
+// BillingContact, by default a PostalValidator using domain.DefaultPostalFormats
+func (at *AccountTable) SetAddressValidator(v domain.AddressValidator) error {
+	if v == nil {
+		return errors.New("non-nil AddressValidator required")
+	}
+	at.addresses = v
+	return nil
+}
+
+// WithTx returns a copy of the AccountTable whose operations are performed within 'tx'. The
+// caller is responsible for committing or rolling back 'tx', see UnitOfWork.
+func (at *AccountTable) WithTx(tx *sql.Tx) *AccountTable {
+	t := *at
+	t.tx = tx
+	return &t
+}
+
+// conn returns the transaction the AccountTable is bound to, if any, otherwise the sql.DB
+func (at *AccountTable) conn() querier {
+	if at.tx != nil {
+		return at.tx
+	}
+	return at.db
+}
+
+// CreateAccount validates the provided account data, inserts it into the db, and returns the
+// newly created account ID. The account's BillingContact, if any, is inserted into the
+// 'billingContact' table and its address into the 'address' table in the same transaction as
+// the account.
+func (at *AccountTable) CreateAccount(a domain.Account) (int, *mverr.MVError) {
+	start := time.Now()
+
+	var err error
+	if at.addresses != nil {
+		err = a.ValidateAccountWith(at.addresses)
+	} else {
+		err = a.ValidateAccount()
+	}
+	if err != nil {
+		DBRqstDur.WithLabelValues(accountTbl, create, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return 0, &mverr.MVError{
+			ErrCode:    mverr.AccountValidationErrorCode,
+			ErrMsg:     mverr.AccountValidationErrorMsg,
+			ErrDetail:  err.Error(),
+			WrappedErr: err}
+	}
+
+	if a.BillingContact != nil {
+		return at.createAccountWithContact(start, a)
+	}
+
+	r, err := at.conn().Exec(insertAccountStmt, a.AccountHolderName, a.NickName, a.ServiceAddress, a.BillingAddress, a.EMail, a.Phone)
+	if err != nil {
+		DBRqstDur.WithLabelValues(accountTbl, create, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		if errDetail, ok := err.(*mysql.MySQLError); ok && errDetail.Number == mverr.MySQLDupInsertErrorCode {
+			return 0, &mverr.MVError{
+				ErrCode:    mverr.DBInsertDuplicateAccountErrorCode,
+				ErrMsg:     mverr.DBInsertDuplicateAccountErrorMsg,
+				ErrDetail:  fmt.Sprintf("error inserting duplicate account into the database, possible duplicate email address: Account email: %s", a.EMail),
+				WrappedErr: err}
+		}
+		return 0, &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error inserting account for %s into DB", a.EMail),
+			WrappedErr: err}
+	}
+	id, err := r.LastInsertId()
+	if err != nil {
+		DBRqstDur.WithLabelValues(accountTbl, create, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return 0, &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  "unable to obtain inserted account's assigned ID",
+			WrappedErr: err}
+	}
+
+	DBRqstDur.WithLabelValues(accountTbl, create, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return int(id), nil
+}
+
+// createAccountWithContact inserts 'a' and its BillingContact in a single transaction, joining the
+// AccountTable's transaction if it's part of a UnitOfWork
+func (at *AccountTable) createAccountWithContact(start time.Time, a domain.Account) (int, *mverr.MVError) {
+	fail := func(mvErr *mverr.MVError) (int, *mverr.MVError) {
+		DBRqstDur.WithLabelValues(accountTbl, create, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return 0, mvErr
+	}
+
+	tx, err := beginTxn(context.Background(), at.db, at.tx)
+	if err != nil {
+		return fail(&mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error beginning transaction to insert account for %s", a.EMail),
+			WrappedErr: err})
+	}
+	r, err := tx.Exec(insertAccountStmt, a.AccountHolderName, a.NickName, a.ServiceAddress, a.BillingAddress, a.EMail, a.Phone)
+	if err != nil {
+		tx.Rollback()
+		if errDetail, ok := err.(*mysql.MySQLError); ok && errDetail.Number == mverr.MySQLDupInsertErrorCode {
+			return fail(&mverr.MVError{
+				ErrCode:    mverr.DBInsertDuplicateAccountErrorCode,
+				ErrMsg:     mverr.DBInsertDuplicateAccountErrorMsg,
+				ErrDetail:  fmt.Sprintf("error inserting duplicate account into the database, possible duplicate email address: Account email: %s", a.EMail),
+				WrappedErr: err})
+		}
+		return fail(&mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error inserting account for %s into DB", a.EMail),
+			WrappedErr: err})
+	}
+	id, err := r.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return fail(&mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  "unable to obtain inserted account's assigned ID",
+			WrappedErr: err})
+	}
+
+	bc := a.BillingContact
+	r, err = tx.Exec(insertAddressStmt, bc.Address.Street, nullString(bc.Address.Street2), bc.Address.City,
+		nullString(bc.Address.Region), nullString(bc.Address.PostalCode), bc.Address.Country)
+	if err != nil {
+		tx.Rollback()
+		return fail(&mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error inserting billing address for account %d into DB", id),
+			WrappedErr: err})
+	}
+	addressID, err := r.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return fail(&mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  "unable to obtain inserted billing address's assigned ID",
+			WrappedErr: err})
+	}
+	_, err = tx.Exec(insertBillingContactStmt, id, bc.Name, bc.EMail, nullString(bc.Phone), addressID)
+	if err != nil {
+		tx.Rollback()
+		return fail(&mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error inserting billing contact for account %d into DB", id),
+			WrappedErr: err})
+	}
+	if err = tx.Commit(); err != nil {
+		return fail(&mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error committing account for %s", a.EMail),
+			WrappedErr: err})
+	}
+
+	DBRqstDur.WithLabelValues(accountTbl, create, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return int(id), nil
+}
+
+// GetAccount returns the account identified by 'id', including its BillingContact, or a nil
+// account if there isn't one
+func (at *AccountTable) GetAccount(id int) (*domain.Account, *mverr.MVError) {
+	start := time.Now()
+
+	var (
+		a                                            domain.Account
+		nickName                                     sql.NullString
+		bcName, bcEMail, bcPhone                     sql.NullString
+		street, street2, city, region, postal, cntry sql.NullString
+	)
+	err := at.conn().QueryRow(getAccountQuery, id).Scan(&a.ID, &a.AccountHolderName, &nickName, &a.ServiceAddress,
+		&a.BillingAddress, &a.EMail, &a.Phone, &bcName, &bcEMail, &bcPhone, &street, &street2, &city, &region, &postal, &cntry)
+	if err == sql.ErrNoRows {
+		DBRqstDur.WithLabelValues(accountTbl, readOne, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+		return nil, nil
+	}
+	if err != nil {
+		DBRqstDur.WithLabelValues(accountTbl, readOne, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return nil, &mverr.MVError{
+			ErrCode:    mverr.DBRowScanErrorCode,
+			ErrMsg:     mverr.DBRowScanErrorMsg,
+			ErrDetail:  fmt.Sprintf("error scanning account row %d", id),
+			WrappedErr: err}
+	}
+
+	a.NickName = nickName.String
+	if bcName.Valid {
+		a.BillingContact = &domain.BillingContact{
+			Name:  bcName.String,
+			EMail: bcEMail.String,
+			Phone: bcPhone.String,
+			Address: domain.Address{
+				Street:     street.String,
+				Street2:    street2.String,
+				City:       city.String,
+				Region:     region.String,
+				PostalCode: postal.String,
+				Country:    cntry.String,
+			},
+		}
+	}
+
+	DBRqstDur.WithLabelValues(accountTbl, readOne, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return &a, nil
+}
@@ -0,0 +1,257 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/httpclient"
+)
+
+// breakerService is the httpclient.BreakerOpen 'service' label value of the DB circuit breaker
+const breakerService = "db"
+
+// BreakerRepository is a domain.UserRepository that protects another UserRepository, normally
+// a Table, with a circuit breaker. While the breaker is open requests fail immediately with a
+// DBUnavailableErrorCode error whose RetryAfter is the time until the breaker allows a request.
+// Only DB failures count towards opening the breaker, errors caused by the request, e.g., a
+// user that doesn't exist, don't.
+type BreakerRepository struct {
+	repo    domain.UserRepository
+	breaker *httpclient.Breaker
+	// ctx is only set when the BreakerRepository is bound to a request, see WithContext
+	ctx context.Context
+}
+
+// NewBreakerRepository returns a BreakerRepository protecting 'repo' with 'breaker'. Both must be
+// non-nil and 'breaker' should only be used by this BreakerRepository.
+func NewBreakerRepository(repo domain.UserRepository, breaker *httpclient.Breaker) (*BreakerRepository, error) {
+	if repo == nil {
+		return nil, errors.New("non-nil domain.UserRepository required")
+	}
+	if breaker == nil {
+		return nil, errors.New("non-nil *httpclient.Breaker required")
+	}
+	httpclient.BreakerOpen.WithLabelValues(breakerService).Set(0)
+	return &BreakerRepository{repo: repo, breaker: breaker}, nil
+}
+
+// WithContext returns a copy of the BreakerRepository whose protected UserRepository is bound to
+// 'ctx', if it supports it. Failures caused by 'ctx' being done, e.g., a gRPC client's deadline
+// passing, are returned as QueryTimeoutErrorCode errors and don't count towards opening the breaker.
+func (br *BreakerRepository) WithContext(ctx context.Context) domain.UserRepository {
+	b := *br
+	b.repo = bindContext(ctx, br.repo)
+	b.ctx = ctx
+	return &b
+}
+
+// do calls 'fn' if the breaker allows it and records its outcome
+func (br *BreakerRepository) do(fn func() *mverr.MVError) *mverr.MVError {
+	if !br.breaker.Allow() {
+		retryAfter := br.breaker.RetryAfter()
+		return &mverr.MVError{
+			ErrCode:    mverr.DBUnavailableErrorCode,
+			ErrMsg:     mverr.DBUnavailableErrorMsg,
+			ErrDetail:  fmt.Sprintf("DB circuit breaker open, retry in %s", retryAfter),
+			RetryAfter: retryAfter,
+		}
+	}
+
+	err := abandoned(br.ctx, fn())
+	br.breaker.Record(err == nil || !isDBFailure(err.ErrCode))
+
+	open := 0.0
+	if br.breaker.State() != httpclient.Closed {
+		open = 1
+	}
+	httpclient.BreakerOpen.WithLabelValues(breakerService).Set(open)
+	return err
+}
+
+// isDBFailure returns true if 'code' indicates the DB request failed, as opposed to the request
+// being rejected, e.g., because of a constraint violation
+func isDBFailure(code mverr.ErrCode) bool {
+	switch code {
+	case mverr.DBDeleteErrorCode,
+		mverr.DBQueryErrorCode,
+		mverr.DBRowScanErrorCode,
+		mverr.DBUpSertErrorCode,
+		mverr.UnknownErrorCode,
+		mverr.UserRqstErrorCode:
+		return true
+	default:
+		return false
+	}
+}
+
+// GetUsers calls GetUsers on the protected UserRepository
+func (br *BreakerRepository) GetUsers() (us *domain.Users, err *mverr.MVError) {
+	err = br.do(func() *mverr.MVError {
+		us, err = br.repo.GetUsers()
+		return err
+	})
+	return us, err
+}
+
+// GetUsersWithin calls GetUsersWithin on the protected UserRepository. A query that times out
+// isn't a DB failure, it doesn't count towards opening the breaker.
+func (br *BreakerRepository) GetUsersWithin(qt domain.QueryTimeout) (us *domain.Users, err *mverr.MVError) {
+	err = br.do(func() *mverr.MVError {
+		us, err = br.repo.GetUsersWithin(qt)
+		return err
+	})
+	return us, err
+}
+
+// GetUsersPage calls GetUsersPage on the protected UserRepository
+func (br *BreakerRepository) GetUsersPage(afterID, limit int) (us *domain.Users, err *mverr.MVError) {
+	err = br.do(func() *mverr.MVError {
+		us, err = br.repo.GetUsersPage(afterID, limit)
+		return err
+	})
+	return us, err
+}
+
+// SearchUsers calls SearchUsers on the protected UserRepository
+func (br *BreakerRepository) SearchUsers(query string, limit int) (us *domain.Users, err *mverr.MVError) {
+	err = br.do(func() *mverr.MVError {
+		us, err = br.repo.SearchUsers(query, limit)
+		return err
+	})
+	return us, err
+}
+
+// UsersVersion calls UsersVersion on the protected UserRepository
+func (br *BreakerRepository) UsersVersion() (v *domain.UsersVersion, err *mverr.MVError) {
+	err = br.do(func() *mverr.MVError {
+		v, err = br.repo.UsersVersion()
+		return err
+	})
+	return v, err
+}
+
+// EmailInUse calls EmailInUse on the protected UserRepository
+func (br *BreakerRepository) EmailInUse(email string, exceptID int) (inUse bool, err *mverr.MVError) {
+	err = br.do(func() *mverr.MVError {
+		inUse, err = br.repo.EmailInUse(email, exceptID)
+		return err
+	})
+	return inUse, err
+}
+
+// GetCredentials calls GetCredentials on the protected UserRepository
+func (br *BreakerRepository) GetCredentials(email string) (u *domain.User, hash string, err *mverr.MVError) {
+	err = br.do(func() *mverr.MVError {
+		u, hash, err = br.repo.GetCredentials(email)
+		return err
+	})
+	return u, hash, err
+}
+
+// GetUser calls GetUser on the protected UserRepository
+func (br *BreakerRepository) GetUser(id int) (u *domain.User, err *mverr.MVError) {
+	err = br.do(func() *mverr.MVError {
+		u, err = br.repo.GetUser(id)
+		return err
+	})
+	return u, err
+}
+
+// CreateUser calls CreateUser on the protected UserRepository
+func (br *BreakerRepository) CreateUser(user domain.User) (id int, err *mverr.MVError) {
+	err = br.do(func() *mverr.MVError {
+		id, err = br.repo.CreateUser(user)
+		return err
+	})
+	return id, err
+}
+
+// UpsertUser calls UpsertUser on the protected UserRepository
+func (br *BreakerRepository) UpsertUser(user domain.User) (id int, outcome domain.UpsertOutcome, err *mverr.MVError) {
+	err = br.do(func() *mverr.MVError {
+		id, outcome, err = br.repo.UpsertUser(user)
+		return err
+	})
+	return id, outcome, err
+}
+
+// UpdateUser calls UpdateUser on the protected UserRepository
+func (br *BreakerRepository) UpdateUser(user domain.User) *mverr.MVError {
+	return br.do(func() *mverr.MVError {
+		return br.repo.UpdateUser(user)
+	})
+}
+
+// GetUserDependencies calls GetUserDependencies on the protected UserRepository
+func (br *BreakerRepository) GetUserDependencies(id int) (deps []domain.UserDependency, err *mverr.MVError) {
+	err = br.do(func() *mverr.MVError {
+		deps, err = br.repo.GetUserDependencies(id)
+		return err
+	})
+	return deps, err
+}
+
+// DeleteUser calls DeleteUser on the protected UserRepository
+func (br *BreakerRepository) DeleteUser(id int) (deleted bool, err *mverr.MVError) {
+	err = br.do(func() *mverr.MVError {
+		deleted, err = br.repo.DeleteUser(id)
+		return err
+	})
+	return deleted, err
+}
+
+// DeleteUserWithSuccessor calls DeleteUserWithSuccessor on the protected UserRepository
+func (br *BreakerRepository) DeleteUserWithSuccessor(id, successorID int) (deleted bool, err *mverr.MVError) {
+	err = br.do(func() *mverr.MVError {
+		deleted, err = br.repo.DeleteUserWithSuccessor(id, successorID)
+		return err
+	})
+	return deleted, err
+}
+
+// ActivateUser calls ActivateUser on the protected UserRepository
+func (br *BreakerRepository) ActivateUser(id int, token string) *mverr.MVError {
+	return br.do(func() *mverr.MVError {
+		return br.repo.ActivateUser(id, token)
+	})
+}
+
+// DeleteExpiredUsers calls DeleteExpiredUsers on the protected UserRepository
+func (br *BreakerRepository) DeleteExpiredUsers() (n int, err *mverr.MVError) {
+	err = br.do(func() *mverr.MVError {
+		n, err = br.repo.DeleteExpiredUsers()
+		return err
+	})
+	return n, err
+}
+
+// UpdateRoles calls UpdateRoles on the protected UserRepository
+func (br *BreakerRepository) UpdateRoles(accountID int, roles map[int]domain.Role) *mverr.MVError {
+	return br.do(func() *mverr.MVError {
+		return br.repo.UpdateRoles(accountID, roles)
+	})
+}
+
+// RecordLogin calls RecordLogin on the protected UserRepository
+func (br *BreakerRepository) RecordLogin(id int) *mverr.MVError {
+	return br.do(func() *mverr.MVError {
+		return br.repo.RecordLogin(id)
+	})
+}
+
+// GetInactiveUsers calls GetInactiveUsers on the protected UserRepository
+func (br *BreakerRepository) GetInactiveUsers(since time.Time) (us *domain.Users, err *mverr.MVError) {
+	err = br.do(func() *mverr.MVError {
+		us, err = br.repo.GetInactiveUsers(since)
+		return err
+	})
+	return us, err
+}
@@ -0,0 +1,487 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package conformance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+// Backend is the set of repositories under test
+type Backend struct {
+	Users domain.UserRepository
+	// Accounts and UnitOfWork are optional, the tests that need them are skipped if they're nil. The
+	// UnitOfWork's Repositories must be bound to Users and, if it's set, Accounts.
+	Accounts   domain.AccountRepository
+	UnitOfWork domain.UnitOfWork
+}
+
+// Factory returns a Backend whose repositories are empty. It's called once for each test, any
+// resources it acquires, e.g., a DB connection, can be released with t.Cleanup.
+type Factory func(t *testing.T) Backend
+
+// tests are the conformance tests, each is run as a subtest with a new Backend
+var tests = []struct {
+	name string
+	test func(t *testing.T, b Backend)
+}{
+	{name: "testCreateAndGetUser", test: testCreateAndGetUser},
+	{name: "testGetUserNotFound", test: testGetUserNotFound},
+	{name: "testInvalidUser", test: testInvalidUser},
+	{name: "testDuplicateEmail", test: testDuplicateEmail},
+	{name: "testEmailInUse", test: testEmailInUse},
+	{name: "testGetCredentials", test: testGetCredentials},
+	{name: "testUpdateUser", test: testUpdateUser},
+	{name: "testUpdateUserNotFound", test: testUpdateUserNotFound},
+	{name: "testUpdateUserDuplicateEmail", test: testUpdateUserDuplicateEmail},
+	{name: "testUpsertUser", test: testUpsertUser},
+	{name: "testDeleteUser", test: testDeleteUser},
+	{name: "testDeleteUserWithSuccessor", test: testDeleteUserWithSuccessor},
+	{name: "testPendingUsers", test: testPendingUsers},
+	{name: "testPagination", test: testPagination},
+	{name: "testSearchUsers", test: testSearchUsers},
+	{name: "testRecordLogin", test: testRecordLogin},
+	{name: "testUpdateRoles", test: testUpdateRoles},
+	{name: "testDuplicateAccount", test: testDuplicateAccount},
+	{name: "testUnitOfWorkCommitted", test: testUnitOfWorkCommitted},
+	{name: "testUnitOfWorkRolledBack", test: testUnitOfWorkRolledBack},
+}
+
+// TestRepositories runs the conformance tests against the Backends returned by 'newBackend'
+func TestRepositories(t *testing.T, newBackend Factory) {
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, newBackend(t))
+		})
+	}
+}
+
+func testCreateAndGetUser(t *testing.T, b Backend) {
+	id := createUser(t, b.Users, newUser(1, "mickeyd", domain.Primary))
+
+	u, err := b.Users.GetUser(id)
+	if err != nil || u == nil {
+		t.Fatalf("expected user %d, got %+v, error %v", id, u, err)
+	}
+	if u.ID != id || u.AccountID != 1 || u.Name != "mickeyd" || u.EMail != "mickeyd@gmail.com" || u.Role != domain.Primary {
+		t.Errorf("expected user %d to be mickeyd, got %+v", id, u)
+	}
+	if u.Status != domain.Active {
+		t.Errorf("expected a user created without a status to be %s, got %s", domain.Active, u.Status)
+	}
+	if u.Password != "" {
+		t.Errorf("expected the user's password not to be returned, got %q", u.Password)
+	}
+	if u.CreatedAt.IsZero() || !u.UpdatedAt.Equal(u.CreatedAt) || u.LastLogin != nil {
+		t.Errorf("expected a new user's CreatedAt and UpdatedAt to be the same and no LastLogin, got %+v", u)
+	}
+}
+
+func testGetUserNotFound(t *testing.T, b Backend) {
+	u, err := b.Users.GetUser(404)
+	if err != nil || u != nil {
+		t.Errorf("expected no user and no error, got %+v, error %v", u, err)
+	}
+}
+
+func testInvalidUser(t *testing.T, b Backend) {
+	u := newUser(1, "mickeyd", domain.Primary)
+	u.EMail = ""
+	_, err := b.Users.CreateUser(u)
+	expectErrCode(t, "CreateUser", err, mverr.UserValidationErrorCode)
+}
+
+func testDuplicateEmail(t *testing.T, b Backend) {
+	createUser(t, b.Users, newUser(1, "mickeyd", domain.Primary))
+
+	// The email address is a duplicate even if it's in another account
+	_, err := b.Users.CreateUser(newUser(2, "mickeyd", domain.Primary))
+	expectErrCode(t, "CreateUser", err, mverr.DBInsertDuplicateUserErrorCode)
+}
+
+func testEmailInUse(t *testing.T, b Backend) {
+	id := createUser(t, b.Users, newUser(1, "mickeyd", domain.Primary))
+
+	tcs := []struct {
+		email    string
+		exceptID int
+		expected bool
+	}{
+		{email: "mickeyd@gmail.com", expected: true},
+		{email: "mickeyd@gmail.com", exceptID: id, expected: false},
+		{email: "davy@gmail.com", expected: false},
+	}
+	for _, tc := range tcs {
+		inUse, err := b.Users.EmailInUse(tc.email, tc.exceptID)
+		if err != nil || inUse != tc.expected {
+			t.Errorf("expected EmailInUse(%s, %d) to be %t, got %t, error %v", tc.email, tc.exceptID, tc.expected, inUse, err)
+		}
+	}
+}
+
+func testGetCredentials(t *testing.T, b Backend) {
+	id := createUser(t, b.Users, newUser(1, "mickeyd", domain.Primary))
+
+	u, hash, err := b.Users.GetCredentials("mickeyd@gmail.com")
+	if err != nil || u == nil || u.ID != id {
+		t.Fatalf("expected user %d, got %+v, error %v", id, u, err)
+	}
+	if hash != "pw" {
+		t.Errorf("expected the stored password %q, got %q", "pw", hash)
+	}
+	if u.Password != "" {
+		t.Errorf("expected the user's password not to be returned in the user, got %q", u.Password)
+	}
+
+	u, hash, err = b.Users.GetCredentials("davy@gmail.com")
+	if err != nil || u != nil || hash != "" {
+		t.Errorf("expected no user and no error, got %+v, %q, error %v", u, hash, err)
+	}
+}
+
+func testUpdateUser(t *testing.T, b Backend) {
+	id := createUser(t, b.Users, newUser(1, "mickeyd", domain.Primary))
+
+	u := newUser(1, "mickeyd", domain.Primary)
+	u.ID = id
+	u.Name = "mickey dolenz"
+	if err := b.Users.UpdateUser(u); err != nil {
+		t.Fatalf("error %s was not expected updating user %d", err, id)
+	}
+	updated, err := b.Users.GetUser(id)
+	if err != nil || updated == nil || updated.Name != "mickey dolenz" {
+		t.Errorf("expected user %d's name to be updated, got %+v, error %v", id, updated, err)
+	}
+}
+
+func testUpdateUserNotFound(t *testing.T, b Backend) {
+	u := newUser(1, "mickeyd", domain.Primary)
+	u.ID = 404
+	expectErrCode(t, "UpdateUser", b.Users.UpdateUser(u), mverr.DBNoUserErrorCode)
+
+	// UpdateUser must never create a user
+	if got, err := b.Users.GetUser(404); err != nil || got != nil {
+		t.Errorf("expected no user 404, got %+v, error %v", got, err)
+	}
+}
+
+func testUpdateUserDuplicateEmail(t *testing.T, b Backend) {
+	createUser(t, b.Users, newUser(1, "mickeyd", domain.Primary))
+	id := createUser(t, b.Users, newUser(1, "davy", domain.Restricted))
+
+	u := newUser(1, "mickeyd", domain.Restricted)
+	u.ID = id
+	expectErrCode(t, "UpdateUser", b.Users.UpdateUser(u), mverr.DBUpSertErrorCode)
+}
+
+func testUpsertUser(t *testing.T, b Backend) {
+	u := newUser(1, "mickeyd", domain.Primary)
+	id, outcome, err := b.Users.UpsertUser(u)
+	if err != nil || outcome != domain.UpsertCreated {
+		t.Fatalf("expected the user to be %s, got %s, error %v", domain.UpsertCreated, outcome, err)
+	}
+
+	sameID, outcome, err := b.Users.UpsertUser(u)
+	if err != nil || outcome != domain.UpsertSkipped || sameID != id {
+		t.Errorf("expected user %d to be %s, got user %d %s, error %v", id, domain.UpsertSkipped, sameID, outcome, err)
+	}
+
+	u.Name = "mickey dolenz"
+	sameID, outcome, err = b.Users.UpsertUser(u)
+	if err != nil || outcome != domain.UpsertUpdated || sameID != id {
+		t.Errorf("expected user %d to be %s, got user %d %s, error %v", id, domain.UpsertUpdated, sameID, outcome, err)
+	}
+
+	// An existing user is never moved to another account
+	u.AccountID = 2
+	_, _, err = b.Users.UpsertUser(u)
+	expectErrCode(t, "UpsertUser", err, mverr.DBInsertDuplicateUserErrorCode)
+	if got, _ := b.Users.GetUser(id); got == nil || got.AccountID != 1 || got.Name != "mickey dolenz" {
+		t.Errorf("expected user %d to be unchanged in account 1, got %+v", id, got)
+	}
+}
+
+func testDeleteUser(t *testing.T, b Backend) {
+	id := createUser(t, b.Users, newUser(1, "mickeyd", domain.Primary))
+
+	// Deleting is idempotent, only the first deletion deletes the user
+	for i := 0; i < 2; i++ {
+		deleted, err := b.Users.DeleteUser(id)
+		if err != nil {
+			t.Fatalf("error %s was not expected deleting user %d", err, id)
+		}
+		if deleted != (i == 0) {
+			t.Errorf("expected deletion %d of user %d to report deleted %t, got %t", i+1, id, i == 0, deleted)
+		}
+	}
+	if got, err := b.Users.GetUser(id); err != nil || got != nil {
+		t.Errorf("expected user %d to be deleted, got %+v, error %v", id, got, err)
+	}
+}
+
+func testDeleteUserWithSuccessor(t *testing.T, b Backend) {
+	primary := createUser(t, b.Users, newUser(1, "mickeyd", domain.Primary))
+	successor := createUser(t, b.Users, newUser(1, "davy", domain.Restricted))
+	other := createUser(t, b.Users, newUser(2, "peter", domain.Primary))
+
+	for _, ids := range [][2]int{{successor, primary}, {primary, other}, {primary, primary}} {
+		_, err := b.Users.DeleteUserWithSuccessor(ids[0], ids[1])
+		expectErrCode(t, "DeleteUserWithSuccessor", err, mverr.InvalidSuccessorErrorCode)
+	}
+
+	if deleted, err := b.Users.DeleteUserWithSuccessor(primary, successor); err != nil || !deleted {
+		t.Fatalf("expected user %d to be deleted, got deleted %t, error %v", primary, deleted, err)
+	}
+	if got, _ := b.Users.GetUser(primary); got != nil {
+		t.Errorf("expected user %d to be deleted, got %+v", primary, got)
+	}
+	if got, _ := b.Users.GetUser(successor); got == nil || got.Role != domain.Primary {
+		t.Errorf("expected user %d to be the primary user, got %+v", successor, got)
+	}
+
+	// Like DeleteUser it's idempotent
+	if deleted, err := b.Users.DeleteUserWithSuccessor(primary, successor); err != nil || deleted {
+		t.Errorf("expected deleted user %d not to be deleted again, got deleted %t, error %v", primary, deleted, err)
+	}
+}
+
+func testPendingUsers(t *testing.T, b Backend) {
+	active := createUser(t, b.Users, newUser(1, "mickeyd", domain.Primary))
+	u := newUser(1, "davy", domain.Restricted)
+	u.Status = domain.Pending
+	u.ActivationToken = "token"
+	u.ActivationExpiry = time.Now().Add(time.Hour)
+	pending := createUser(t, b.Users, u)
+
+	if got, err := b.Users.GetUser(pending); err != nil || got == nil || got.Status != domain.Pending {
+		t.Errorf("expected pending user %d, got %+v, error %v", pending, got, err)
+	}
+	users, err := b.Users.GetUsers()
+	if err != nil {
+		t.Fatalf("error %s was not expected getting the users", err)
+	}
+	expectIDs(t, "GetUsers", users, active)
+	v, err := b.Users.UsersVersion()
+	if err != nil || v.Count != 1 {
+		t.Errorf("expected a version counting 1 user, got %+v, error %v", v, err)
+	}
+
+	expectErrCode(t, "ActivateUser", b.Users.ActivateUser(pending, "wrong"), mverr.InvalidActivationErrorCode)
+	if err := b.Users.ActivateUser(pending, "token"); err != nil {
+		t.Fatalf("error %s was not expected activating user %d", err, pending)
+	}
+	users, _ = b.Users.GetUsers()
+	expectIDs(t, "GetUsers", users, active, pending)
+}
+
+func testPagination(t *testing.T, b Backend) {
+	var ids []int
+	for _, name := range []string{"mickeyd", "davy", "peter", "mike", "micky"} {
+		ids = append(ids, createUser(t, b.Users, newUser(1, name, domain.Restricted)))
+	}
+
+	pages := [][]int{ids[0:2], ids[2:4], ids[4:5], nil}
+	afterID := 0
+	for i, expected := range pages {
+		page, err := b.Users.GetUsersPage(afterID, 2)
+		if err != nil {
+			t.Fatalf("error %s was not expected getting page %d", err, i)
+		}
+		expectIDs(t, "GetUsersPage", page, expected...)
+		if len(page.Users) > 0 {
+			afterID = page.Users[len(page.Users)-1].ID
+		}
+	}
+}
+
+func testSearchUsers(t *testing.T, b Backend) {
+	mickeyd := createUser(t, b.Users, newUser(1, "mickeyd", domain.Primary))
+	createUser(t, b.Users, newUser(1, "davy", domain.Restricted))
+	micky := createUser(t, b.Users, newUser(1, "micky", domain.Restricted))
+
+	users, err := b.Users.SearchUsers("MICK", 10)
+	if err != nil {
+		t.Fatalf("error %s was not expected searching the users", err)
+	}
+	expectIDs(t, "SearchUsers", users, mickeyd, micky)
+
+	users, err = b.Users.SearchUsers("mick", 1)
+	if err != nil {
+		t.Fatalf("error %s was not expected searching the users", err)
+	}
+	expectIDs(t, "SearchUsers", users, mickeyd)
+}
+
+func testRecordLogin(t *testing.T, b Backend) {
+	id := createUser(t, b.Users, newUser(1, "mickeyd", domain.Primary))
+	before, _ := b.Users.GetUser(id)
+
+	if err := b.Users.RecordLogin(id); err != nil {
+		t.Fatalf("error %s was not expected recording user %d's login", err, id)
+	}
+	u, err := b.Users.GetUser(id)
+	if err != nil || u == nil || u.LastLogin == nil {
+		t.Fatalf("expected user %d to have a LastLogin, got %+v, error %v", id, u, err)
+	}
+	if !u.UpdatedAt.Equal(before.UpdatedAt) {
+		t.Errorf("expected user %d's UpdatedAt to be unchanged, got %s, was %s", id, u.UpdatedAt, before.UpdatedAt)
+	}
+
+	if err := b.Users.RecordLogin(404); err != nil {
+		t.Errorf("error %s was not expected recording a non-existent user's login", err)
+	}
+}
+
+func testUpdateRoles(t *testing.T, b Backend) {
+	primary := createUser(t, b.Users, newUser(1, "mickeyd", domain.Primary))
+	restricted := createUser(t, b.Users, newUser(1, "davy", domain.Restricted))
+	other := createUser(t, b.Users, newUser(2, "peter", domain.Primary))
+
+	// Two primary users, or a user in another account, are rejected and no roles are changed
+	expectErrCode(t, "UpdateRoles", b.Users.UpdateRoles(1, map[int]domain.Role{restricted: domain.Primary}), mverr.InvalidRoleAssignmentErrorCode)
+	expectErrCode(t, "UpdateRoles", b.Users.UpdateRoles(1, map[int]domain.Role{restricted: domain.Unrestricted, other: domain.Restricted}), mverr.DBNoUserErrorCode)
+	if u, _ := b.Users.GetUser(restricted); u == nil || u.Role != domain.Restricted {
+		t.Errorf("expected user %d's role to be unchanged, got %+v", restricted, u)
+	}
+
+	err := b.Users.UpdateRoles(1, map[int]domain.Role{primary: domain.Unrestricted, restricted: domain.Primary})
+	if err != nil {
+		t.Fatalf("error %s was not expected updating the roles", err)
+	}
+	if u, _ := b.Users.GetUser(restricted); u == nil || u.Role != domain.Primary {
+		t.Errorf("expected user %d to be the primary user, got %+v", restricted, u)
+	}
+}
+
+func testDuplicateAccount(t *testing.T, b Backend) {
+	if b.Accounts == nil {
+		t.Skip("the backend doesn't have an AccountRepository")
+	}
+	if _, err := b.Accounts.CreateAccount(newAccount("mickeyd")); err != nil {
+		t.Fatalf("error %s was not expected creating an account", err)
+	}
+	_, err := b.Accounts.CreateAccount(newAccount("mickeyd"))
+	expectErrCode(t, "CreateAccount", err, mverr.DBInsertDuplicateAccountErrorCode)
+}
+
+func testUnitOfWorkCommitted(t *testing.T, b Backend) {
+	if b.UnitOfWork == nil {
+		t.Skip("the backend doesn't have a UnitOfWork")
+	}
+	var ids []int
+	err := b.UnitOfWork.Do(func(repos domain.Repositories) *mverr.MVError {
+		accountID := 1
+		if repos.Accounts != nil {
+			var err *mverr.MVError
+			if accountID, err = repos.Accounts.CreateAccount(newAccount("mickeyd")); err != nil {
+				return err
+			}
+		}
+		for _, name := range []string{"mickeyd", "davy"} {
+			id, err := repos.Users.CreateUser(newUser(accountID, name, domain.Restricted))
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error %s was not expected from the UnitOfWork", err)
+	}
+
+	users, _ := b.Users.GetUsers()
+	expectIDs(t, "GetUsers", users, ids...)
+}
+
+func testUnitOfWorkRolledBack(t *testing.T, b Backend) {
+	if b.UnitOfWork == nil {
+		t.Skip("the backend doesn't have a UnitOfWork")
+	}
+	existing := createUser(t, b.Users, newUser(1, "peter", domain.Primary))
+
+	err := b.UnitOfWork.Do(func(repos domain.Repositories) *mverr.MVError {
+		if repos.Accounts != nil {
+			if _, err := repos.Accounts.CreateAccount(newAccount("mickeyd")); err != nil {
+				return err
+			}
+		}
+		if _, err := repos.Users.CreateUser(newUser(1, "mickeyd", domain.Restricted)); err != nil {
+			return err
+		}
+		if _, err := repos.Users.DeleteUser(existing); err != nil {
+			return err
+		}
+		// The duplicate fails the UnitOfWork, none of its changes are kept
+		_, err := repos.Users.CreateUser(newUser(1, "mickeyd", domain.Restricted))
+		return err
+	})
+	expectErrCode(t, "UnitOfWork.Do", err, mverr.DBInsertDuplicateUserErrorCode)
+
+	users, _ := b.Users.GetUsers()
+	expectIDs(t, "GetUsers", users, existing)
+	if b.Accounts != nil {
+		// The account's email address is available if its creation was rolled back
+		if _, err := b.Accounts.CreateAccount(newAccount("mickeyd")); err != nil {
+			t.Errorf("expected the account to be rolled back, got error %s creating it again", err)
+		}
+	}
+}
+
+// newUser returns a valid user named 'name' whose email address is derived from its name
+func newUser(accountID int, name string, role domain.Role) domain.User {
+	return domain.User{AccountID: accountID, Name: name, EMail: name + "@gmail.com", Role: role, Password: "pw"}
+}
+
+// newAccount returns a valid account whose email address is derived from 'name'
+func newAccount(name string) domain.Account {
+	return domain.Account{
+		AccountHolderName: name,
+		ServiceAddress:    "1 Main St",
+		BillingAddress:    "1 Main St",
+		EMail:             name + "@gmail.com",
+		Phone:             "5555550100",
+	}
+}
+
+// createUser creates 'u', failing the test if it can't be created, and returns its ID
+func createUser(t *testing.T, users domain.UserRepository, u domain.User) int {
+	t.Helper()
+	id, err := users.CreateUser(u)
+	if err != nil {
+		t.Fatalf("error %s was not expected creating user %s", err, u.Name)
+	}
+	return id
+}
+
+// expectErrCode fails the test if 'err', returned by 'op', doesn't have the error code 'expected'
+func expectErrCode(t *testing.T, op string, err *mverr.MVError, expected mverr.ErrCode) {
+	t.Helper()
+	if err == nil || err.ErrCode != expected {
+		t.Errorf("expected %s to fail with error code %d, got %v", op, expected, err)
+	}
+}
+
+// expectIDs fails the test if 'users' aren't the users identified by 'ids', in the same order
+func expectIDs(t *testing.T, op string, users *domain.Users, ids ...int) {
+	t.Helper()
+	got := []int{}
+	for _, u := range users.Users {
+		got = append(got, u.ID)
+	}
+	if len(got) != len(ids) {
+		t.Errorf("expected %s to return users %v, got %v", op, ids, got)
+		return
+	}
+	for i := range ids {
+		if got[i] != ids[i] {
+			t.Errorf("expected %s to return users %v, got %v", op, ids, got)
+			return
+		}
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package conformance is a test suite verifying that a repository implementation, e.g., the MySQL
+repositories in package 'db' or the in-memory ones in package 'memory', has the semantics documented
+by the domain repository interfaces. Every backend is run through the same tests so they all behave
+the same way, e.g., when a user has a duplicate email address, doesn't exist, or is created in a
+UnitOfWork that's rolled back.
+
+A backend's tests call TestRepositories with a Factory returning new, empty repositories:
+
+	func TestConformance(t *testing.T) {
+		conformance.TestRepositories(t, func(t *testing.T) conformance.Backend {
+			users := memory.NewUserTable()
+			uow, err := memory.NewUnitOfWork(users)
+			if err != nil {
+				t.Fatalf("error %s was not expected creating a UnitOfWork", err)
+			}
+			return conformance.Backend{Users: users, UnitOfWork: uow}
+		})
+	}
+
+Each test is a subtest, e.g., 'TestConformance/testDuplicateEmail', so a single one can be run with
+'go test -run'. The tests that need an AccountRepository or a UnitOfWork are skipped if the Backend
+doesn't have one.
+*/
+package conformance
@@ -0,0 +1,35 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+// bindContext returns 'repo' bound to 'ctx' if it's a domain.ContextualUserRepository, otherwise 'repo'
+func bindContext(ctx context.Context, repo domain.UserRepository) domain.UserRepository {
+	if cr, ok := repo.(domain.ContextualUserRepository); ok {
+		return cr.WithContext(ctx)
+	}
+	return repo
+}
+
+// abandoned returns 'err' as a QueryTimeoutErrorCode error if it's a DB failure caused by 'ctx' being
+// done, i.e., the request the operation was performed for was abandoned. Such errors don't indicate
+// that the DB is failing. Any other error is returned unchanged.
+func abandoned(ctx context.Context, err *mverr.MVError) *mverr.MVError {
+	if err == nil || ctx == nil || ctx.Err() == nil || !isDBFailure(err.ErrCode) {
+		return err
+	}
+	return &mverr.MVError{
+		ErrCode:    mverr.QueryTimeoutErrorCode,
+		ErrMsg:     mverr.QueryTimeoutErrorMsg,
+		ErrDetail:  fmt.Sprintf("DB request abandoned, %s", ctx.Err()),
+		WrappedErr: err}
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+const deadLetterTbl = "deadLetterTbl"
+
+var (
+	getAllDeadLettersQuery = "SELECT id, source, sourceID, attempts, errMsg, createdAt FROM deadLetter ORDER BY id"
+	getDeadLetterQuery     = "SELECT id, source, sourceID, attempts, errMsg, createdAt FROM deadLetter WHERE id = ?"
+	deleteDeadLetterStmt   = "DELETE FROM deadLetter WHERE id = ?"
+)
+
+// DeadLetterTable supports access to the 'deadLetter' table. Rows are added by the tables of the
+// asynchronous work that failed, e.g., QueueTable.DeadLetterUser.
+type DeadLetterTable struct {
+	db *sql.DB
+}
+
+// NewDeadLetterTable creates a new DeadLetterTable instance with the provided sql.DB instance
+func NewDeadLetterTable(db *sql.DB) (*DeadLetterTable, error) {
+	if db == nil {
+		return nil, errors.New("non-nil sql.DB connection required")
+	}
+	return &DeadLetterTable{db: db}, nil
+}
+
+// GetDeadLetters returns all the dead letters, oldest first
+func (dt *DeadLetterTable) GetDeadLetters() (*domain.DeadLetters, *mverr.MVError) {
+	start := time.Now()
+
+	results, err := dt.db.Query(getAllDeadLettersQuery)
+	if err != nil {
+		DBRqstDur.WithLabelValues(deadLetterTbl, readAll, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return nil, &mverr.MVError{
+			ErrCode:    mverr.DBQueryErrorCode,
+			ErrMsg:     mverr.DBQueryErrorMsg,
+			ErrDetail:  "error querying dead letters",
+			WrappedErr: err}
+	}
+	defer results.Close()
+
+	dls := domain.DeadLetters{}
+	for results.Next() {
+		dl, err := scanDeadLetter(results)
+		if err != nil {
+			DBRqstDur.WithLabelValues(deadLetterTbl, readAll, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+			return nil, &mverr.MVError{
+				ErrCode:    mverr.DBRowScanErrorCode,
+				ErrMsg:     mverr.DBRowScanErrorMsg,
+				ErrDetail:  "error scanning dead letters query result set",
+				WrappedErr: err}
+		}
+		dls.DeadLetters = append(dls.DeadLetters, dl)
+	}
+
+	DBRqstDur.WithLabelValues(deadLetterTbl, readAll, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	bytes := 0
+	for _, dl := range dls.DeadLetters {
+		bytes += 3*intColumnBytes + dateTimeColumnBytes + len(dl.Source) + len(dl.ErrMsg)
+	}
+	observeResultSet(deadLetterTbl, readAll, len(dls.DeadLetters), bytes)
+	return &dls, nil
+}
+
+// GetDeadLetter will return the dead letter identified by 'id' or a nil DeadLetter if there
+// wasn't a match
+func (dt *DeadLetterTable) GetDeadLetter(id int) (*domain.DeadLetter, *mverr.MVError) {
+	start := time.Now()
+
+	dl, err := scanDeadLetter(dt.db.QueryRow(getDeadLetterQuery, id))
+	if err == sql.ErrNoRows {
+		DBRqstDur.WithLabelValues(deadLetterTbl, readOne, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+		return nil, nil
+	}
+	if err != nil {
+		DBRqstDur.WithLabelValues(deadLetterTbl, readOne, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return nil, &mverr.MVError{
+			ErrCode:    mverr.DBRowScanErrorCode,
+			ErrMsg:     mverr.DBRowScanErrorMsg,
+			ErrDetail:  fmt.Sprintf("error scanning dead letter row %d", id),
+			WrappedErr: err}
+	}
+
+	DBRqstDur.WithLabelValues(deadLetterTbl, readOne, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return dl, nil
+}
+
+// DeleteDeadLetter deletes the dead letter identified by 'id'. It's not an error if it doesn't exist.
+func (dt *DeadLetterTable) DeleteDeadLetter(id int) *mverr.MVError {
+	start := time.Now()
+
+	_, err := dt.db.Exec(deleteDeadLetterStmt, id)
+	if err != nil {
+		DBRqstDur.WithLabelValues(deadLetterTbl, delete, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return &mverr.MVError{
+			ErrCode:    mverr.DBDeleteErrorCode,
+			ErrMsg:     mverr.DBDeleteErrorMsg,
+			ErrDetail:  fmt.Sprintf("error deleting dead letter id %d", id),
+			WrappedErr: err}
+	}
+
+	DBRqstDur.WithLabelValues(deadLetterTbl, delete, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return nil
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDeadLetter(row scanner) (*domain.DeadLetter, error) {
+	var errMsg sql.NullString
+	dl := &domain.DeadLetter{}
+	err := row.Scan(&dl.ID,
+		&dl.Source,
+		&dl.SourceID,
+		&dl.Attempts,
+		&errMsg,
+		&dl.Created)
+	if err != nil {
+		return nil, err
+	}
+	dl.ErrMsg = errMsg.String
+	return dl, nil
+}
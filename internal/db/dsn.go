@@ -0,0 +1,149 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TLS modes of a DSNOptions
+const (
+	// TLSDisabled connects without TLS
+	TLSDisabled = "false"
+	// TLSVerify requires TLS and verifies the server's certificate and host name
+	TLSVerify = "true"
+	// TLSSkipVerify requires TLS but doesn't verify the server's certificate
+	TLSSkipVerify = "skip-verify"
+)
+
+// TLSModes are the valid values of DSNOptions.TLS
+var TLSModes = []string{TLSDisabled, TLSVerify, TLSSkipVerify}
+
+// customCATLSConfig is the name the TLS configuration verifying the server's certificate against
+// DSNOptions.TLSCA is registered under with the MySQL driver
+const customCATLSConfig = "mockvideo-custom-ca"
+
+// DSNOptions describe a connection to the MySQL DB
+type DSNOptions struct {
+	User     string
+	Password string
+	// Host and Port locate the DB using TCP. Socket, the path of a Unix domain socket, locates it
+	// instead when the DB is on the same host. Exactly one of them must be set.
+	Host   string
+	Port   int
+	Socket string
+	// Name is the name of the database
+	Name string
+	// TLS is one of TLSModes, TLSDisabled if it's empty. TLSCA is a PEM encoded CA certificate
+	// that the server's certificate is verified against instead of the host's root CAs. It's only
+	// valid with TLSVerify. TLS isn't supported with Socket.
+	TLS   string
+	TLSCA string
+	// Charset is the connection's character set, e.g., 'utf8mb4'. The server's default is used if
+	// it's empty.
+	Charset string
+	// Timeout limits the time taken to connect, ReadTimeout and WriteTimeout limit the time taken
+	// by each read and write on the connection. Zero means no limit.
+	Timeout      time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// ParseTime returns DATE and DATETIME values as time.Time rather than []byte
+	ParseTime bool
+	// InterpolateParams replaces placeholders in queries with their values in the client, saving a
+	// round-trip to prepare a statement.
+	InterpolateParams bool
+}
+
+// BuildDSN validates 'opts' and returns the corresponding MySQL data source name, for use with
+// sql.Open("mysql", ...). Time values are always read and written as UTC. When 'opts' includes a
+// TLSCA a TLS configuration using it is registered with the MySQL driver, replacing any previously
+// registered by BuildDSN.
+func BuildDSN(opts DSNOptions) (string, error) {
+	if err := opts.validate(); err != nil {
+		return "", err
+	}
+
+	cfg := mysql.NewConfig()
+	cfg.User = opts.User
+	cfg.Passwd = opts.Password
+	if opts.Socket != "" {
+		cfg.Net = "unix"
+		cfg.Addr = opts.Socket
+	} else {
+		cfg.Net = "tcp"
+		cfg.Addr = net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
+	}
+	cfg.DBName = opts.Name
+	cfg.Timeout = opts.Timeout
+	cfg.ReadTimeout = opts.ReadTimeout
+	cfg.WriteTimeout = opts.WriteTimeout
+	cfg.ParseTime = opts.ParseTime
+	cfg.InterpolateParams = opts.InterpolateParams
+	if opts.Charset != "" {
+		cfg.Params = map[string]string{"charset": opts.Charset}
+	}
+
+	switch {
+	case opts.TLSCA != "":
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM([]byte(opts.TLSCA)) {
+			return "", errors.New("the DB TLS CA doesn't contain a PEM encoded certificate")
+		}
+		if err := mysql.RegisterTLSConfig(customCATLSConfig, &tls.Config{RootCAs: roots}); err != nil {
+			return "", err
+		}
+		cfg.TLSConfig = customCATLSConfig
+	case opts.TLS != "" && opts.TLS != TLSDisabled:
+		cfg.TLSConfig = opts.TLS
+	}
+
+	return cfg.FormatDSN(), nil
+}
+
+// validate returns an error describing the first invalid option in 'opts', if any
+func (opts DSNOptions) validate() error {
+	if opts.User == "" {
+		return errors.New("a DB user is required")
+	}
+	if opts.Name == "" {
+		return errors.New("a DB name is required")
+	}
+
+	switch {
+	case opts.Socket != "" && (opts.Host != "" || opts.Port != 0):
+		return errors.New("either a DB host and port or a DB socket is required, not both")
+	case opts.Socket == "" && opts.Host == "":
+		return errors.New("a DB host or socket is required")
+	case opts.Socket == "" && (opts.Port < 1 || opts.Port > 65535):
+		return fmt.Errorf("DB port %d is invalid, it must be between 1 and 65535", opts.Port)
+	}
+
+	switch opts.TLS {
+	case "", TLSDisabled, TLSVerify, TLSSkipVerify:
+	default:
+		return fmt.Errorf("DB TLS mode %q is invalid, it must be one of %q", opts.TLS, TLSModes)
+	}
+	tlsEnabled := opts.TLS != "" && opts.TLS != TLSDisabled
+	if tlsEnabled && opts.Socket != "" {
+		return errors.New("DB TLS isn't supported with a DB socket")
+	}
+	if opts.TLSCA != "" && opts.TLS != TLSVerify {
+		return fmt.Errorf("a DB TLS CA requires DB TLS mode %q", TLSVerify)
+	}
+
+	if opts.Timeout < 0 || opts.ReadTimeout < 0 || opts.WriteTimeout < 0 {
+		return errors.New("DB timeouts can't be negative")
+	}
+
+	return nil
+}
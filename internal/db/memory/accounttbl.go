@@ -0,0 +1,100 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+// AccountTable is an in-memory implementation of domain.AccountRepository. It's safe for concurrent use.
+type AccountTable struct {
+	mu       sync.Mutex
+	accounts map[int]domain.Account
+	nextID   int
+	// addresses validates the addresses of the accounts' billing contacts, see SetAddressValidator
+	addresses domain.AddressValidator
+}
+
+// NewAccountTable returns an empty AccountTable
+func NewAccountTable() *AccountTable {
+	return &AccountTable{accounts: make(map[int]domain.Account), nextID: 1}
+}
+
+// SetAddressValidator replaces the AddressValidator used to validate the address of an account's
+// BillingContact, by default a PostalValidator using domain.DefaultPostalFormats
+func (at *AccountTable) SetAddressValidator(v domain.AddressValidator) error {
+	if v == nil {
+		return errors.New("non-nil AddressValidator required")
+	}
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.addresses = v
+	return nil
+}
+
+// CreateAccount validates 'a', stores it, and returns its ID
+func (at *AccountTable) CreateAccount(a domain.Account) (int, *mverr.MVError) {
+	at.mu.Lock()
+	v := at.addresses
+	at.mu.Unlock()
+
+	var err error
+	if v != nil {
+		err = a.ValidateAccountWith(v)
+	} else {
+		err = a.ValidateAccount()
+	}
+	if err != nil {
+		return 0, &mverr.MVError{
+			ErrCode:    mverr.AccountValidationErrorCode,
+			ErrMsg:     mverr.AccountValidationErrorMsg,
+			ErrDetail:  err.Error(),
+			WrappedErr: err}
+	}
+
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	for _, existing := range at.accounts {
+		if existing.EMail == a.EMail {
+			return 0, &mverr.MVError{
+				ErrCode:   mverr.DBInsertDuplicateAccountErrorCode,
+				ErrMsg:    mverr.DBInsertDuplicateAccountErrorMsg,
+				ErrDetail: fmt.Sprintf("duplicate email address: Account email: %s", a.EMail)}
+		}
+	}
+
+	a.ID = at.nextID
+	a.HREF = ""
+	if a.BillingContact != nil {
+		bc := *a.BillingContact
+		a.BillingContact = &bc
+	}
+	at.nextID++
+	at.accounts[a.ID] = a
+
+	return a.ID, nil
+}
+
+// GetAccount returns the account identified by 'id', or nil if there isn't one
+func (at *AccountTable) GetAccount(id int) *domain.Account {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	a, ok := at.accounts[id]
+	if !ok {
+		return nil
+	}
+	if a.BillingContact != nil {
+		bc := *a.BillingContact
+		a.BillingContact = &bc
+	}
+	return &a
+}
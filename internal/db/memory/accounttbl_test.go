@@ -0,0 +1,151 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package memory
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+func newAccount(name string) domain.Account {
+	return domain.Account{
+		AccountHolderName: name,
+		ServiceAddress:    "1 Main St",
+		BillingAddress:    "1 Main St",
+		EMail:             name + "@gmail.com",
+		Phone:             "5555550100",
+	}
+}
+
+func TestCreateAccount(t *testing.T) {
+	at := NewAccountTable()
+
+	id, err := at.CreateAccount(newAccount("mickeyd"))
+	if err != nil {
+		t.Fatalf("error %s was not expected creating an account", err)
+	}
+	if a := at.GetAccount(id); a == nil || a.AccountHolderName != "mickeyd" {
+		t.Errorf("expected account %d for mickeyd, got %+v", id, a)
+	}
+
+	_, err = at.CreateAccount(newAccount("mickeyd"))
+	if err == nil || err.ErrCode != mverr.DBInsertDuplicateAccountErrorCode {
+		t.Errorf("expected error code %d for a duplicate email, got %v", mverr.DBInsertDuplicateAccountErrorCode, err)
+	}
+
+	_, err = at.CreateAccount(domain.Account{EMail: "davy@gmail.com"})
+	if err == nil || err.ErrCode != mverr.AccountValidationErrorCode {
+		t.Errorf("expected error code %d for an invalid account, got %v", mverr.AccountValidationErrorCode, err)
+	}
+
+	if a := at.GetAccount(id + 1); a != nil {
+		t.Errorf("expected no account %d, got %+v", id+1, a)
+	}
+}
+
+// countryValidator only accepts addresses in 'country', it replaces the default AddressValidator
+type countryValidator string
+
+func (c countryValidator) ValidateAddress(a domain.Address) error {
+	if a.Country != string(c) {
+		return errors.New("unsupported country " + a.Country)
+	}
+	return nil
+}
+
+func TestBillingContact(t *testing.T) {
+	address := domain.Address{Street: "1 Main St", City: "Springfield", Region: "OR", PostalCode: "97477", Country: "US"}
+	tcs := []struct {
+		testName   string
+		contact    *domain.BillingContact
+		validator  domain.AddressValidator
+		shouldPass bool
+	}{
+		{testName: "testNoContact", shouldPass: true},
+		{
+			testName:   "testUSContact",
+			contact:    &domain.BillingContact{Name: "davy", EMail: "davy@gmail.com", Address: address},
+			shouldPass: true,
+		},
+		{
+			testName: "testGBContact",
+			contact: &domain.BillingContact{Name: "davy", EMail: "davy@gmail.com",
+				Address: domain.Address{Street: "10 Downing St", City: "London", PostalCode: "sw1a 2aa", Country: "GB"}},
+			shouldPass: true,
+		},
+		{
+			testName: "testNoPostalFormat",
+			contact: &domain.BillingContact{Name: "davy", EMail: "davy@gmail.com",
+				Address: domain.Address{Street: "1 Harbour Rd", City: "Dublin", Country: "IE"}},
+			shouldPass: true,
+		},
+		{
+			testName:   "testMissingName",
+			contact:    &domain.BillingContact{EMail: "davy@gmail.com", Address: address},
+			shouldPass: false,
+		},
+		{
+			testName: "testUnknownCountry",
+			contact: &domain.BillingContact{Name: "davy", EMail: "davy@gmail.com",
+				Address: domain.Address{Street: "1 Main St", City: "Springfield", PostalCode: "97477", Country: "USA"}},
+			shouldPass: false,
+		},
+		{
+			testName: "testInvalidPostalCode",
+			contact: &domain.BillingContact{Name: "davy", EMail: "davy@gmail.com",
+				Address: domain.Address{Street: "1 Main St", City: "Springfield", PostalCode: "9747", Country: "US"}},
+			shouldPass: false,
+		},
+		{
+			testName:   "testPluggableValidatorRejects",
+			contact:    &domain.BillingContact{Name: "davy", EMail: "davy@gmail.com", Address: address},
+			validator:  countryValidator("CA"),
+			shouldPass: false,
+		},
+		{
+			testName: "testPluggableValidatorAccepts",
+			contact: &domain.BillingContact{Name: "davy", EMail: "davy@gmail.com",
+				Address: domain.Address{Country: "CA"}},
+			validator:  countryValidator("CA"),
+			shouldPass: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			at := NewAccountTable()
+			if tc.validator != nil {
+				if err := at.SetAddressValidator(tc.validator); err != nil {
+					t.Fatalf("error %s was not expected setting the address validator", err)
+				}
+			}
+			a := newAccount("davy")
+			a.BillingContact = tc.contact
+
+			id, err := at.CreateAccount(a)
+			if !tc.shouldPass {
+				if err == nil || err.ErrCode != mverr.AccountValidationErrorCode {
+					t.Fatalf("expected error code %d, got %v", mverr.AccountValidationErrorCode, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error %s was not expected creating an account", err)
+			}
+			got := at.GetAccount(id)
+			if got == nil || !reflect.DeepEqual(got.BillingContact, tc.contact) {
+				t.Errorf("expected billing contact %+v, got %+v", tc.contact, got)
+			}
+		})
+	}
+
+	if err := NewAccountTable().SetAddressValidator(nil); err == nil {
+		t.Errorf("expected an error setting a nil address validator")
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package memory
+
+import (
+	"testing"
+
+	"github.com/youngkin/mockvideo/internal/db/conformance"
+)
+
+func TestConformance(t *testing.T) {
+	conformance.TestRepositories(t, func(t *testing.T) conformance.Backend {
+		users := NewUserTable()
+		accounts := NewAccountTable()
+		uow, err := NewUnitOfWork(users)
+		if err != nil {
+			t.Fatalf("error %s was not expected creating a UnitOfWork", err)
+		}
+		if err = uow.SetAccountTable(accounts); err != nil {
+			t.Fatalf("error %s was not expected setting the account table", err)
+		}
+		return conformance.Backend{Users: users, Accounts: accounts, UnitOfWork: uow}
+	})
+}
@@ -0,0 +1,10 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package memory provides in-memory implementations of the domain repositories. They behave like their
+database backed counterparts in package 'db' but don't require a database. This makes them useful for
+tests and for mock servers (see package 'mockserver').
+*/
+package memory
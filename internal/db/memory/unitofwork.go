@@ -0,0 +1,86 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package memory
+
+import (
+	"errors"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+// UnitOfWork is an in-memory implementation of domain.UnitOfWork over a UserTable and,
+// optionally, an AccountTable. Changes are undone by restoring snapshots of the tables taken
+// when Do is called. Unlike a database transaction it isn't isolated, changes made concurrently
+// by callers outside the UnitOfWork are lost if the UnitOfWork is rolled back. The Repositories
+// it provides don't include a UserQueue.
+type UnitOfWork struct {
+	users *UserTable
+	// accounts is only set if the UnitOfWork includes an AccountTable, see SetAccountTable
+	accounts *AccountTable
+}
+
+// NewUnitOfWork returns a UnitOfWork over 'users', which must be non-nil
+func NewUnitOfWork(users *UserTable) (*UnitOfWork, error) {
+	if users == nil {
+		return nil, errors.New("non-nil UserTable required")
+	}
+	return &UnitOfWork{users: users}, nil
+}
+
+// SetAccountTable adds 'accounts', which must be non-nil, to the UnitOfWork
+func (uow *UnitOfWork) SetAccountTable(accounts *AccountTable) error {
+	if accounts == nil {
+		return errors.New("non-nil AccountTable required")
+	}
+	uow.accounts = accounts
+	return nil
+}
+
+// Do implements domain.UnitOfWork
+func (uow *UnitOfWork) Do(fn func(repos domain.Repositories) *mverr.MVError) *mverr.MVError {
+	ut := uow.users
+
+	ut.mu.Lock()
+	users := make(map[int]domain.User, len(ut.users))
+	for id, u := range ut.users {
+		users[id] = u
+	}
+	nextID := ut.nextID
+	ut.mu.Unlock()
+
+	repos := domain.Repositories{Users: ut}
+	var restoreAccounts func()
+	if at := uow.accounts; at != nil {
+		repos.Accounts = at
+
+		at.mu.Lock()
+		accounts := make(map[int]domain.Account, len(at.accounts))
+		for id, a := range at.accounts {
+			accounts[id] = a
+		}
+		nextAccountID := at.nextID
+		at.mu.Unlock()
+
+		restoreAccounts = func() {
+			at.mu.Lock()
+			at.accounts = accounts
+			at.nextID = nextAccountID
+			at.mu.Unlock()
+		}
+	}
+
+	if err := fn(repos); err != nil {
+		ut.mu.Lock()
+		ut.users = users
+		ut.nextID = nextID
+		ut.mu.Unlock()
+		if restoreAccounts != nil {
+			restoreAccounts()
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package memory
+
+import (
+	"testing"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+func TestUnitOfWork(t *testing.T) {
+	tcs := []struct {
+		testName      string
+		fail          bool
+		expectedUsers int
+	}{
+		{
+			testName:      "testUnitOfWorkCommit",
+			expectedUsers: 3,
+		},
+		{
+			testName:      "testUnitOfWorkRollback",
+			fail:          true,
+			expectedUsers: 1,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			ut := NewUserTable()
+			primaryID, err := ut.CreateUser(newUser(1, "mickeyd", domain.Primary))
+			if err != nil {
+				t.Fatalf("error %s was not expected creating a user", err)
+			}
+			uow, err2 := NewUnitOfWork(ut)
+			if err2 != nil {
+				t.Fatalf("error %s was not expected creating a UnitOfWork", err2)
+			}
+
+			err = uow.Do(func(repos domain.Repositories) *mverr.MVError {
+				for _, name := range []string{"peter", "davy"} {
+					if _, err := repos.Users.CreateUser(newUser(1, name, domain.Unrestricted)); err != nil {
+						return err
+					}
+				}
+				if _, err := repos.Users.DeleteUser(primaryID); err != nil {
+					return err
+				}
+				if _, err := repos.Users.CreateUser(newUser(1, "mickeyd", domain.Primary)); err != nil {
+					return err
+				}
+				if tc.fail {
+					return &mverr.MVError{ErrCode: mverr.UnknownErrorCode, ErrMsg: mverr.UnknownErrorMsg}
+				}
+				return nil
+			})
+			if tc.fail != (err != nil) {
+				t.Fatalf("expected failure %t, got error %v", tc.fail, err)
+			}
+
+			us, err := ut.GetUsers()
+			if err != nil {
+				t.Fatalf("error %s was not expected getting users", err)
+			}
+			if len(us.Users) != tc.expectedUsers {
+				t.Errorf("expected %d users, got %d", tc.expectedUsers, len(us.Users))
+			}
+			if u, _ := ut.GetUser(primaryID); tc.fail && u == nil {
+				t.Errorf("expected user %d to be restored", primaryID)
+			}
+
+			// IDs used within a rolled back UnitOfWork are reused
+			id, err := ut.CreateUser(newUser(1, "micky", domain.Unrestricted))
+			if err != nil {
+				t.Fatalf("error %s was not expected creating a user", err)
+			}
+			if tc.fail && id != primaryID+1 {
+				t.Errorf("expected ID %d, got %d", primaryID+1, id)
+			}
+		})
+	}
+}
+
+func TestUnitOfWorkAccounts(t *testing.T) {
+	tcs := []struct {
+		testName string
+		fail     bool
+	}{
+		{
+			testName: "testUnitOfWorkCommit",
+		},
+		{
+			testName: "testUnitOfWorkRollback",
+			fail:     true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			ut := NewUserTable()
+			at := NewAccountTable()
+			uow, err := NewUnitOfWork(ut)
+			if err != nil {
+				t.Fatalf("error %s was not expected creating a UnitOfWork", err)
+			}
+			if err = uow.SetAccountTable(at); err != nil {
+				t.Fatalf("error %s was not expected setting the AccountTable", err)
+			}
+
+			mvErr := uow.Do(func(repos domain.Repositories) *mverr.MVError {
+				accountID, err := repos.Accounts.CreateAccount(newAccount("mickeyd"))
+				if err != nil {
+					return err
+				}
+				if _, err = repos.Users.CreateUser(newUser(accountID, "mickeyd", domain.Primary)); err != nil {
+					return err
+				}
+				if tc.fail {
+					return &mverr.MVError{ErrCode: mverr.UnknownErrorCode, ErrMsg: mverr.UnknownErrorMsg}
+				}
+				return nil
+			})
+			if tc.fail != (mvErr != nil) {
+				t.Fatalf("expected failure %t, got error %v", tc.fail, mvErr)
+			}
+
+			// Both the account and its user are rolled back
+			if a := at.GetAccount(1); (a != nil) == tc.fail {
+				t.Errorf("expected account 1 to exist %t, got %+v", !tc.fail, a)
+			}
+			if u, _ := ut.GetUser(1); (u != nil) == tc.fail {
+				t.Errorf("expected user 1 to exist %t, got %+v", !tc.fail, u)
+			}
+		})
+	}
+}
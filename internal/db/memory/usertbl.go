@@ -0,0 +1,619 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/clock"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/idgen"
+)
+
+// UserTable is an in-memory implementation of domain.UserRepository. It's safe for concurrent use.
+type UserTable struct {
+	mu     sync.Mutex
+	users  map[int]domain.User
+	nextID int
+	clock  clock.Clock
+	// idGen assigns the IDs of new users if it's set, see SetIDGenerator
+	idGen idgen.Generator
+	// frozen is set once the users can no longer be changed, see Freeze
+	frozen bool
+}
+
+// NewUserTable returns an empty UserTable that uses clock.System, see SetClock
+func NewUserTable() *UserTable {
+	return &UserTable{users: make(map[int]domain.User), nextID: 1, clock: clock.System}
+}
+
+// SetClock replaces the Clock used for user timestamps and activation expiry. 'c' must be non-nil.
+func (ut *UserTable) SetClock(c clock.Clock) error {
+	if c == nil {
+		return errors.New("non-nil Clock required")
+	}
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	ut.clock = c
+	return nil
+}
+
+// SetIDGenerator makes 'g' assign the IDs of new users, keyed by email address, rather than the
+// table. 'g' must be non-nil.
+func (ut *UserTable) SetIDGenerator(g idgen.Generator) error {
+	if g == nil {
+		return errors.New("non-nil Generator required")
+	}
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	ut.idGen = g
+	return nil
+}
+
+// Freeze prevents the users from being changed, e.g., to serve a fixed dataset in demo mode. Every
+// write fails with a DemoModeErrorCode error once the UserTable is frozen.
+func (ut *UserTable) Freeze() {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	ut.frozen = true
+}
+
+// frozenError returns the error for 'op', a write attempted once the UserTable is frozen, or nil if
+// it isn't frozen. The caller must hold 'ut.mu'.
+func (ut *UserTable) frozenError(op string) *mverr.MVError {
+	if !ut.frozen {
+		return nil
+	}
+	return &mverr.MVError{
+		ErrCode:   mverr.DemoModeErrorCode,
+		ErrMsg:    mverr.DemoModeErrorMsg,
+		ErrDetail: fmt.Sprintf("%s rejected, the users are frozen", op)}
+}
+
+// GetUsers returns all active users ordered by ID. Pending users, i.e., those that haven't been
+// activated, aren't included.
+func (ut *UserTable) GetUsers() (*domain.Users, *mverr.MVError) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	us := domain.Users{}
+	for _, id := range ut.sortedIDs() {
+		u := ut.users[id]
+		if u.Status != domain.Active {
+			continue
+		}
+		us.Users = append(us.Users, public(u))
+	}
+	return &us, nil
+}
+
+// GetUsersWithin returns the users returned by GetUsers. Reading them from memory doesn't take long
+// enough for 'qt' to matter so it's ignored and the users are never truncated.
+func (ut *UserTable) GetUsersWithin(qt domain.QueryTimeout) (*domain.Users, *mverr.MVError) {
+	return ut.GetUsers()
+}
+
+// GetUsersPage returns, ordered by ID, up to 'limit' of the users returned by GetUsers whose
+// ID is greater than 'afterID'
+func (ut *UserTable) GetUsersPage(afterID, limit int) (*domain.Users, *mverr.MVError) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	us := domain.Users{}
+	for _, id := range ut.sortedIDs() {
+		if len(us.Users) == limit {
+			break
+		}
+		u := ut.users[id]
+		if id <= afterID || u.Status != domain.Active {
+			continue
+		}
+		us.Users = append(us.Users, public(u))
+	}
+	return &us, nil
+}
+
+// SearchUsers returns, ordered by ID, up to 'limit' of the users returned by GetUsers whose name or
+// email address contains 'query', ignoring case
+func (ut *UserTable) SearchUsers(query string, limit int) (*domain.Users, *mverr.MVError) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	query = strings.ToLower(query)
+	us := domain.Users{}
+	for _, id := range ut.sortedIDs() {
+		if len(us.Users) == limit {
+			break
+		}
+		u := ut.users[id]
+		if u.Status != domain.Active {
+			continue
+		}
+		if strings.Contains(strings.ToLower(u.Name), query) || strings.Contains(strings.ToLower(u.EMail), query) {
+			us.Users = append(us.Users, public(u))
+		}
+	}
+	return &us, nil
+}
+
+// GetInactiveUsers returns, ordered by ID, the active users that haven't logged in since 'since'.
+// Users that have never logged in are included if they were created before 'since'.
+func (ut *UserTable) GetInactiveUsers(since time.Time) (*domain.Users, *mverr.MVError) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	us := domain.Users{}
+	for _, id := range ut.sortedIDs() {
+		u := ut.users[id]
+		if u.Status != domain.Active {
+			continue
+		}
+		lastActive := u.CreatedAt
+		if u.LastLogin != nil {
+			lastActive = *u.LastLogin
+		}
+		if lastActive.Before(since) {
+			us.Users = append(us.Users, public(u))
+		}
+	}
+	return &us, nil
+}
+
+// UsersVersion returns the latest update time and the number of the users returned by GetUsers
+func (ut *UserTable) UsersVersion() (*domain.UsersVersion, *mverr.MVError) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	v := &domain.UsersVersion{}
+	for _, u := range ut.users {
+		if u.Status != domain.Active {
+			continue
+		}
+		v.Count++
+		if u.UpdatedAt.After(v.LastModified) {
+			v.LastModified = u.UpdatedAt
+		}
+	}
+	return v, nil
+}
+
+// GetUser returns the user identified by 'id' or a nil user if there isn't a matching user
+func (ut *UserTable) GetUser(id int) (*domain.User, *mverr.MVError) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	u, found := ut.users[id]
+	if !found {
+		return nil, nil
+	}
+	return public(u), nil
+}
+
+// EmailInUse returns true if a user, of any status, other than the one identified by 'exceptID'
+// has the email address 'email'
+func (ut *UserTable) EmailInUse(email string, exceptID int) (bool, *mverr.MVError) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	return ut.emailInUse(email, exceptID), nil
+}
+
+// GetCredentials returns the user, of any status, with the email address 'email' and the user's
+// password hash, or a nil user if there wasn't a matching user
+func (ut *UserTable) GetCredentials(email string) (*domain.User, string, *mverr.MVError) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	for _, u := range ut.users {
+		if u.EMail == email {
+			return public(u), u.Password, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// CreateUser stores 'u' and returns its newly assigned ID. A user without a Status is created as
+// an Active user. Like the 'user' table, email addresses must be unique. The user's CreatedAt and
+// UpdatedAt are set to the current time.
+func (ut *UserTable) CreateUser(u domain.User) (int, *mverr.MVError) {
+	err := u.ValidateUser()
+	if err != nil {
+		return 0, &mverr.MVError{
+			ErrCode:    mverr.UserValidationErrorCode,
+			ErrMsg:     mverr.UserValidationErrorMsg,
+			ErrDetail:  err.Error(),
+			WrappedErr: err}
+	}
+
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	if mvErr := ut.frozenError("CreateUser"); mvErr != nil {
+		return 0, mvErr
+	}
+
+	if ut.emailInUse(u.EMail, 0) {
+		return 0, &mverr.MVError{
+			ErrCode:   mverr.DBInsertDuplicateUserErrorCode,
+			ErrMsg:    mverr.DBInsertDuplicateUserErrorMsg,
+			ErrDetail: fmt.Sprintf("duplicate email address: User name: %s, User email: %s", u.Name, u.EMail)}
+	}
+
+	if u.Status == "" {
+		u.Status = domain.Active
+	}
+	id, mvErr := ut.newID(u)
+	if mvErr != nil {
+		return 0, mvErr
+	}
+	u.ID = id
+	u.HREF = ""
+	u.CreatedAt = ut.timestamp()
+	u.UpdatedAt = u.CreatedAt
+	u.LastLogin = nil
+	ut.users[u.ID] = u
+
+	return u.ID, nil
+}
+
+// UpsertUser creates 'u', as CreateUser does, or updates the name, role, and password of the existing user
+// with the same email address. Like the 'user' table, the existing user's UpdatedAt is only changed if one
+// of these is, otherwise UpsertSkipped is returned. A user in another account isn't updated, a
+// DBInsertDuplicateUserErrorCode error is returned instead.
+func (ut *UserTable) UpsertUser(u domain.User) (int, domain.UpsertOutcome, *mverr.MVError) {
+	err := u.ValidateUser()
+	if err != nil {
+		return 0, "", &mverr.MVError{
+			ErrCode:    mverr.UserValidationErrorCode,
+			ErrMsg:     mverr.UserValidationErrorMsg,
+			ErrDetail:  err.Error(),
+			WrappedErr: err}
+	}
+
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	if mvErr := ut.frozenError("UpsertUser"); mvErr != nil {
+		return 0, "", mvErr
+	}
+
+	for id, existing := range ut.users {
+		if existing.EMail != u.EMail {
+			continue
+		}
+		if existing.AccountID != u.AccountID {
+			return 0, "", &mverr.MVError{
+				ErrCode:   mverr.DBInsertDuplicateUserErrorCode,
+				ErrMsg:    mverr.DBInsertDuplicateUserErrorMsg,
+				ErrDetail: fmt.Sprintf("error upserting user, email %s is in use in another account", u.EMail)}
+		}
+		if existing.Name == u.Name && existing.Role == u.Role && existing.Password == u.Password {
+			return id, domain.UpsertSkipped, nil
+		}
+		existing.Name = u.Name
+		existing.Role = u.Role
+		existing.Password = u.Password
+		existing.UpdatedAt = ut.timestamp()
+		existing.LastModifiedBy = u.LastModifiedBy
+		ut.users[id] = existing
+		return id, domain.UpsertUpdated, nil
+	}
+
+	if u.Status == "" {
+		u.Status = domain.Active
+	}
+	id, mvErr := ut.newID(u)
+	if mvErr != nil {
+		return 0, "", mvErr
+	}
+	u.ID = id
+	u.HREF = ""
+	u.CreatedAt = ut.timestamp()
+	u.UpdatedAt = u.CreatedAt
+	u.LastLogin = nil
+	ut.users[u.ID] = u
+
+	return u.ID, domain.UpsertCreated, nil
+}
+
+// newID returns the ID of the new user 'u', ut.mu must be locked
+func (ut *UserTable) newID(u domain.User) (int, *mverr.MVError) {
+	if ut.idGen == nil {
+		id := ut.nextID
+		ut.nextID++
+		return id, nil
+	}
+	id, err := ut.idGen.NextID(u.EMail)
+	if err != nil {
+		return 0, &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("unable to assign an ID to user %s", u.EMail),
+			WrappedErr: err}
+	}
+	if _, ok := ut.users[id]; ok {
+		return 0, &mverr.MVError{
+			ErrCode:   mverr.DBUpSertErrorCode,
+			ErrMsg:    mverr.DBUpSertErrorMsg,
+			ErrDetail: fmt.Sprintf("ID %d assigned to user %s is already in use", id, u.EMail)}
+	}
+	return id, nil
+}
+
+// UpdateUser replaces the user identified by 'u.ID' with 'u'. The user's status, activation
+// token, CreatedAt, and LastLogin are unchanged, UpdatedAt is set to the current time.
+func (ut *UserTable) UpdateUser(u domain.User) *mverr.MVError {
+	err := u.ValidateUser()
+	if err != nil {
+		return &mverr.MVError{
+			ErrCode:    mverr.UserValidationErrorCode,
+			ErrMsg:     mverr.UserValidationErrorMsg,
+			ErrDetail:  err.Error(),
+			WrappedErr: err}
+	}
+
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	if mvErr := ut.frozenError("UpdateUser"); mvErr != nil {
+		return mvErr
+	}
+
+	existing, found := ut.users[u.ID]
+	if !found {
+		return &mverr.MVError{
+			ErrCode:   mverr.DBNoUserErrorCode,
+			ErrMsg:    mverr.DBNoUserErrorMsg,
+			ErrDetail: fmt.Sprintf("error, attempting to update non-existent user, user.ID %d", u.ID)}
+	}
+	if ut.emailInUse(u.EMail, u.ID) {
+		return &mverr.MVError{
+			ErrCode:   mverr.DBUpSertErrorCode,
+			ErrMsg:    mverr.DBUpSertErrorMsg,
+			ErrDetail: fmt.Sprintf("error updating user %d, email %s in use by another user", u.ID, u.EMail)}
+	}
+
+	u.HREF = ""
+	u.Status = existing.Status
+	u.ActivationToken = existing.ActivationToken
+	u.ActivationExpiry = existing.ActivationExpiry
+	u.CreatedAt = existing.CreatedAt
+	u.UpdatedAt = ut.timestamp()
+	u.LastLogin = existing.LastLogin
+	ut.users[u.ID] = u
+
+	return nil
+}
+
+// GetUserDependencies returns the records that prevent the user identified by 'id' from being deleted,
+// i.e., the other users of the account the user is the Primary user of. The UserTable doesn't have
+// accountUser records so there are never domain.DependentAccountLinks.
+func (ut *UserTable) GetUserDependencies(id int) ([]domain.UserDependency, *mverr.MVError) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	deps := []domain.UserDependency{}
+	u, found := ut.users[id]
+	if !found || u.Role != domain.Primary {
+		return deps, nil
+	}
+	count := 0
+	for _, o := range ut.users {
+		if o.AccountID == u.AccountID && o.ID != id {
+			count++
+		}
+	}
+	if count > 0 {
+		deps = append(deps, domain.UserDependency{Kind: domain.DependentAccountUsers, Count: count})
+	}
+	return deps, nil
+}
+
+// RecordLogin sets the LastLogin of the user identified by 'id' to the current time, its UpdatedAt
+// is unchanged. Recording the login of a non-existent user isn't an error.
+func (ut *UserTable) RecordLogin(id int) *mverr.MVError {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	if mvErr := ut.frozenError("RecordLogin"); mvErr != nil {
+		return mvErr
+	}
+
+	u, found := ut.users[id]
+	if !found {
+		return nil
+	}
+	now := ut.timestamp()
+	u.LastLogin = &now
+	ut.users[id] = u
+	return nil
+}
+
+// DeleteUser deletes the user identified by 'id', returning whether it existed. Deleting a
+// non-existent user isn't an error.
+func (ut *UserTable) DeleteUser(id int) (bool, *mverr.MVError) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	if mvErr := ut.frozenError("DeleteUser"); mvErr != nil {
+		return false, mvErr
+	}
+
+	_, found := ut.users[id]
+	delete(ut.users, id)
+	return found, nil
+}
+
+// DeleteUserWithSuccessor deletes the Primary user identified by 'id' and makes the user identified
+// by 'successorID' its account's Primary user, returning whether the user existed. Deleting a
+// non-existent user isn't an error.
+func (ut *UserTable) DeleteUserWithSuccessor(id, successorID int) (bool, *mverr.MVError) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	if mvErr := ut.frozenError("DeleteUserWithSuccessor"); mvErr != nil {
+		return false, mvErr
+	}
+
+	u, found := ut.users[id]
+	if !found {
+		return false, nil
+	}
+	if u.Role != domain.Primary {
+		return false, &mverr.MVError{
+			ErrCode:   mverr.InvalidSuccessorErrorCode,
+			ErrMsg:    mverr.InvalidSuccessorErrorMsg,
+			ErrDetail: fmt.Sprintf("user %d isn't the primary user of account %d, it doesn't need a successor", id, u.AccountID)}
+	}
+	successor, found := ut.users[successorID]
+	if !found || successorID == id || successor.AccountID != u.AccountID {
+		return false, &mverr.MVError{
+			ErrCode:   mverr.InvalidSuccessorErrorCode,
+			ErrMsg:    mverr.InvalidSuccessorErrorMsg,
+			ErrDetail: fmt.Sprintf("successor %d is not another user of account %d", successorID, u.AccountID)}
+	}
+
+	successor.Role = domain.Primary
+	successor.UpdatedAt = ut.timestamp()
+	ut.users[successorID] = successor
+	delete(ut.users, id)
+	return true, nil
+}
+
+// ActivateUser changes a Pending user to Active if 'token' matches the user's unexpired activation token
+func (ut *UserTable) ActivateUser(id int, token string) *mverr.MVError {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	if mvErr := ut.frozenError("ActivateUser"); mvErr != nil {
+		return mvErr
+	}
+
+	u, found := ut.users[id]
+	if !found || u.Status != domain.Pending || u.ActivationToken != token || !u.ActivationExpiry.After(ut.clock.Now()) {
+		return &mverr.MVError{
+			ErrCode:   mverr.InvalidActivationErrorCode,
+			ErrMsg:    mverr.InvalidActivationErrorMsg,
+			ErrDetail: fmt.Sprintf("no pending user with id %d and a matching unexpired activation token", id)}
+	}
+
+	u.Status = domain.Active
+	u.ActivationToken = ""
+	u.UpdatedAt = ut.timestamp()
+	ut.users[id] = u
+	return nil
+}
+
+// DeleteExpiredUsers deletes Pending users whose activation token has expired, returning the number deleted
+func (ut *UserTable) DeleteExpiredUsers() (int, *mverr.MVError) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	now := ut.clock.Now()
+	expired := []int{}
+	for id, u := range ut.users {
+		if u.Status == domain.Pending && u.ActivationExpiry.Before(now) {
+			expired = append(expired, id)
+		}
+	}
+	// A frozen UserTable without expired users isn't changed, the expiry check isn't an error
+	if len(expired) > 0 {
+		if mvErr := ut.frozenError("DeleteExpiredUsers"); mvErr != nil {
+			return 0, mvErr
+		}
+	}
+	for _, id := range expired {
+		delete(ut.users, id)
+	}
+	return len(expired), nil
+}
+
+// UpdateRoles changes the roles of the users in account 'accountID'. 'roles' maps user IDs to their
+// new roles. Either all roles are changed or none are. The change is rejected if any of the users
+// aren't in the account or if the account wouldn't have exactly one primary user afterwards.
+func (ut *UserTable) UpdateRoles(accountID int, roles map[int]domain.Role) *mverr.MVError {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	if mvErr := ut.frozenError("UpdateRoles"); mvErr != nil {
+		return mvErr
+	}
+
+	for id, role := range roles {
+		if role != domain.Primary && role != domain.Unrestricted && role != domain.Restricted {
+			return &mverr.MVError{
+				ErrCode:   mverr.UserValidationErrorCode,
+				ErrMsg:    mverr.UserValidationErrorMsg,
+				ErrDetail: fmt.Sprintf("invalid role %d for user %d", role, id)}
+		}
+		if u, found := ut.users[id]; !found || u.AccountID != accountID {
+			return &mverr.MVError{
+				ErrCode:   mverr.DBNoUserErrorCode,
+				ErrMsg:    mverr.DBNoUserErrorMsg,
+				ErrDetail: fmt.Sprintf("user %d is not in account %d", id, accountID)}
+		}
+	}
+
+	primaries := 0
+	for id, u := range ut.users {
+		if u.AccountID != accountID {
+			continue
+		}
+		role, found := roles[id]
+		if !found {
+			role = u.Role
+		}
+		if role == domain.Primary {
+			primaries++
+		}
+	}
+	if primaries != 1 {
+		return &mverr.MVError{
+			ErrCode:   mverr.InvalidRoleAssignmentErrorCode,
+			ErrMsg:    mverr.InvalidRoleAssignmentErrorMsg,
+			ErrDetail: fmt.Sprintf("role update would leave account %d with %d primary users", accountID, primaries)}
+	}
+
+	now := ut.timestamp()
+	for id, role := range roles {
+		u := ut.users[id]
+		u.Role = role
+		u.UpdatedAt = now
+		ut.users[id] = u
+	}
+	return nil
+}
+
+// sortedIDs returns the IDs of all users in ascending order. The caller must hold 'ut.mu'.
+func (ut *UserTable) sortedIDs() []int {
+	ids := make([]int, 0, len(ut.users))
+	for id := range ut.users {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// emailInUse returns true if a user other than 'exceptID' has 'email'. The caller must hold 'ut.mu'.
+func (ut *UserTable) emailInUse(email string, exceptID int) bool {
+	for id, u := range ut.users {
+		if id != exceptID && u.EMail == email {
+			return true
+		}
+	}
+	return false
+}
+
+// timestamp returns the current time with the same precision as the 'user' table's timestamps.
+// The caller must hold 'ut.mu'.
+func (ut *UserTable) timestamp() time.Time {
+	return ut.clock.Now().Truncate(time.Second)
+}
+
+// public returns a copy of 'u' without the fields the 'user' table queries don't return
+func public(u domain.User) *domain.User {
+	u.Password = ""
+	u.ActivationToken = ""
+	u.ActivationExpiry = time.Time{}
+	return &u
+}
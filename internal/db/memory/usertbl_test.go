@@ -0,0 +1,623 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package memory
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/clock"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/idgen"
+)
+
+func newUser(accountID int, name string, role domain.Role) domain.User {
+	return domain.User{AccountID: accountID, Name: name, EMail: name + "@gmail.com", Role: role, Password: "pw"}
+}
+
+func TestCRUD(t *testing.T) {
+	ut := NewUserTable()
+
+	id, err := ut.CreateUser(newUser(1, "mickeyd", domain.Primary))
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a user", err)
+	}
+
+	_, err = ut.CreateUser(newUser(1, "mickeyd", domain.Unrestricted))
+	if err == nil || err.ErrCode != mverr.DBInsertDuplicateUserErrorCode {
+		t.Errorf("expected error code %d creating a duplicate user, got %v", mverr.DBInsertDuplicateUserErrorCode, err)
+	}
+
+	u, err := ut.GetUser(id)
+	if err != nil || u == nil {
+		t.Fatalf("expected user %d, got %v, error %v", id, u, err)
+	}
+	if u.Status != domain.Active || u.Password != "" {
+		t.Errorf("expected an active user without a password, got %+v", u)
+	}
+
+	updated := newUser(1, "micky", domain.Primary)
+	updated.ID = id
+	if err = ut.UpdateUser(updated); err != nil {
+		t.Fatalf("error %s was not expected updating a user", err)
+	}
+	if u, _ = ut.GetUser(id); u.Name != "micky" {
+		t.Errorf("expected the user's name to be updated, got %+v", u)
+	}
+
+	updated.ID = 99
+	if err = ut.UpdateUser(updated); err == nil || err.ErrCode != mverr.DBNoUserErrorCode {
+		t.Errorf("expected error code %d updating a non-existent user, got %v", mverr.DBNoUserErrorCode, err)
+	}
+
+	deleted, err := ut.DeleteUser(id)
+	if err != nil {
+		t.Fatalf("error %s was not expected deleting a user", err)
+	}
+	if !deleted {
+		t.Errorf("expected user %d to be reported as deleted", id)
+	}
+	if u, _ = ut.GetUser(id); u != nil {
+		t.Errorf("expected user %d to be deleted, got %+v", id, u)
+	}
+	if deleted, err = ut.DeleteUser(id); err != nil || deleted {
+		t.Errorf("expected deleting deleted user %d to succeed without deleting it, got deleted %t, error %v", id, deleted, err)
+	}
+}
+
+func TestUpsertUser(t *testing.T) {
+	ut := NewUserTable()
+	existingID, err := ut.CreateUser(newUser(1, "mickeyd", domain.Primary))
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a user", err)
+	}
+
+	tcs := []struct {
+		testName        string
+		user            domain.User
+		expectedID      int
+		expectedOutcome domain.UpsertOutcome
+		expectedName    string
+		expectedErrCode mverr.ErrCode
+	}{
+		{
+			testName:        "testUpsertCreated",
+			user:            newUser(1, "davyj", domain.Unrestricted),
+			expectedID:      existingID + 1,
+			expectedOutcome: domain.UpsertCreated,
+			expectedName:    "davyj",
+		},
+		{
+			testName:        "testUpsertSkipped",
+			user:            newUser(1, "mickeyd", domain.Primary),
+			expectedID:      existingID,
+			expectedOutcome: domain.UpsertSkipped,
+			expectedName:    "mickeyd",
+		},
+		{
+			testName:        "testUpsertUpdated",
+			user:            domain.User{AccountID: 1, Name: "micky", EMail: "mickeyd@gmail.com", Role: domain.Primary, Password: "pw"},
+			expectedID:      existingID,
+			expectedOutcome: domain.UpsertUpdated,
+			expectedName:    "micky",
+		},
+		{
+			testName:        "testUpsertOtherAccount",
+			user:            newUser(2, "mickeyd", domain.Primary),
+			expectedErrCode: mverr.DBInsertDuplicateUserErrorCode,
+		},
+		{
+			testName:        "testUpsertInvalid",
+			user:            domain.User{AccountID: 1, EMail: "petert@gmail.com", Password: "pw"},
+			expectedErrCode: mverr.UserValidationErrorCode,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			id, outcome, err := ut.UpsertUser(tc.user)
+			if tc.expectedErrCode != mverr.NoErrorCode {
+				if err == nil || err.ErrCode != tc.expectedErrCode {
+					t.Errorf("expected error code %d, got %v", tc.expectedErrCode, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error %s was not expected upserting a user", err)
+			}
+			if id != tc.expectedID || outcome != tc.expectedOutcome {
+				t.Errorf("expected ID %d and outcome %s, got %d and %s", tc.expectedID, tc.expectedOutcome, id, outcome)
+			}
+			if u, _ := ut.GetUser(id); u == nil || u.Name != tc.expectedName {
+				t.Errorf("expected user %d to be named %s, got %+v", id, tc.expectedName, u)
+			}
+		})
+	}
+}
+
+func TestIDGenerator(t *testing.T) {
+	ut := NewUserTable()
+	if err := ut.SetIDGenerator(idgen.Keyed{"mickeyd@gmail.com": 10, "davyj@gmail.com": 10}); err != nil {
+		t.Fatalf("error %s was not expected setting the ID generator", err)
+	}
+
+	id, err := ut.CreateUser(newUser(1, "mickeyd", domain.Primary))
+	if err != nil || id != 10 {
+		t.Fatalf("expected user ID 10, got %d, error %v", id, err)
+	}
+	if u, _ := ut.GetUser(10); u == nil || u.Name != "mickeyd" {
+		t.Errorf("expected user 10 to be mickeyd, got %+v", u)
+	}
+	if _, err = ut.CreateUser(newUser(1, "davyj", domain.Restricted)); err == nil || err.ErrCode != mverr.DBUpSertErrorCode {
+		t.Errorf("expected error code %d creating a user whose ID is in use, got %v", mverr.DBUpSertErrorCode, err)
+	}
+	if _, err = ut.CreateUser(newUser(1, "petert", domain.Restricted)); err == nil || err.ErrCode != mverr.DBUpSertErrorCode {
+		t.Errorf("expected error code %d creating a user without an ID, got %v", mverr.DBUpSertErrorCode, err)
+	}
+}
+
+func TestUsersVersion(t *testing.T) {
+	ut := NewUserTable()
+	clk := clock.NewFrozen(time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC))
+	ut.SetClock(clk)
+
+	v, _ := ut.UsersVersion()
+	if *v != (domain.UsersVersion{}) {
+		t.Errorf("expected the zero version without users, got %+v", v)
+	}
+
+	first, _ := ut.CreateUser(newUser(1, "mickeyd", domain.Primary))
+	clk.Advance(time.Minute)
+	ut.CreateUser(newUser(1, "davyj", domain.Restricted))
+	pending := newUser(1, "peter", domain.Restricted)
+	pending.Status = domain.Pending
+	clk.Advance(time.Minute)
+	ut.CreateUser(pending)
+
+	// The version must match the users returned by GetUsers, i.e., exclude pending users
+	users, _ := ut.GetUsers()
+	v, _ = ut.UsersVersion()
+	if *v != users.Version() || v.Count != 2 || !v.LastModified.Equal(clk.Now().Add(-time.Minute)) {
+		t.Errorf("expected version %+v of 2 active users, got %+v", users.Version(), v)
+	}
+
+	ut.DeleteUser(first)
+	if v, _ = ut.UsersVersion(); v.Count != 1 {
+		t.Errorf("expected the deletion to be reflected in the version, got %+v", v)
+	}
+}
+
+func TestGetUsersPage(t *testing.T) {
+	ut := NewUserTable()
+	for _, name := range []string{"mickeyd", "davyj", "peter", "mikey"} {
+		ut.CreateUser(newUser(1, name, domain.Restricted))
+	}
+	pending := newUser(1, "porgy", domain.Restricted)
+	pending.Status = domain.Pending
+	ut.CreateUser(pending)
+
+	tcs := []struct {
+		testName    string
+		afterID     int
+		limit       int
+		expectedIDs []int
+	}{
+		{testName: "testFirstPage", afterID: 0, limit: 2, expectedIDs: []int{1, 2}},
+		{testName: "testNextPage", afterID: 2, limit: 2, expectedIDs: []int{3, 4}},
+		{testName: "testPendingExcluded", afterID: 3, limit: 2, expectedIDs: []int{4}},
+		{testName: "testPastEnd", afterID: 5, limit: 2, expectedIDs: []int{}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			users, err := ut.GetUsersPage(tc.afterID, tc.limit)
+			if err != nil {
+				t.Fatalf("error %s was not expected", err)
+			}
+			ids := []int{}
+			for _, u := range users.Users {
+				ids = append(ids, u.ID)
+			}
+			if !reflect.DeepEqual(ids, tc.expectedIDs) {
+				t.Errorf("expected users %v, got %v", tc.expectedIDs, ids)
+			}
+		})
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	ut := NewUserTable()
+	for _, name := range []string{"mickeyd", "davyj", "MickeyN", "peter"} {
+		ut.CreateUser(newUser(1, name, domain.Restricted))
+	}
+	pending := newUser(1, "mickeyp", domain.Restricted)
+	pending.Status = domain.Pending
+	ut.CreateUser(pending)
+
+	tcs := []struct {
+		testName    string
+		query       string
+		limit       int
+		expectedIDs []int
+	}{
+		{testName: "testNameIgnoringCase", query: "MICKEY", limit: 10, expectedIDs: []int{1, 3}},
+		{testName: "testEmail", query: "j@gmail", limit: 10, expectedIDs: []int{2}},
+		{testName: "testLimit", query: "gmail.com", limit: 2, expectedIDs: []int{1, 2}},
+		{testName: "testNoMatch", query: "porgy", limit: 10, expectedIDs: []int{}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			users, err := ut.SearchUsers(tc.query, tc.limit)
+			if err != nil {
+				t.Fatalf("error %s was not expected", err)
+			}
+			ids := []int{}
+			for _, u := range users.Users {
+				ids = append(ids, u.ID)
+			}
+			if !reflect.DeepEqual(ids, tc.expectedIDs) {
+				t.Errorf("expected users %v, got %v", tc.expectedIDs, ids)
+			}
+		})
+	}
+}
+
+func TestActivation(t *testing.T) {
+	ut := NewUserTable()
+
+	pending := newUser(1, "davyj", domain.Primary)
+	pending.Status = domain.Pending
+	pending.ActivationToken = "goodtoken"
+	pending.ActivationExpiry = time.Now().Add(time.Hour)
+	id, _ := ut.CreateUser(pending)
+
+	expired := newUser(1, "peter", domain.Unrestricted)
+	expired.Status = domain.Pending
+	expired.ActivationToken = "oldtoken"
+	expired.ActivationExpiry = time.Now().Add(-time.Hour)
+	ut.CreateUser(expired)
+
+	users, _ := ut.GetUsers()
+	if len(users.Users) != 0 {
+		t.Errorf("expected pending users to be excluded, got %d users", len(users.Users))
+	}
+
+	if err := ut.ActivateUser(id, "badtoken"); err == nil || err.ErrCode != mverr.InvalidActivationErrorCode {
+		t.Errorf("expected error code %d, got %v", mverr.InvalidActivationErrorCode, err)
+	}
+	if err := ut.ActivateUser(id, "goodtoken"); err != nil {
+		t.Errorf("error %s was not expected activating user", err)
+	}
+
+	n, _ := ut.DeleteExpiredUsers()
+	if n != 1 {
+		t.Errorf("expected 1 expired user to be deleted, got %d", n)
+	}
+
+	users, _ = ut.GetUsers()
+	if len(users.Users) != 1 || users.Users[0].ID != id {
+		t.Errorf("expected only user %d, got %+v", id, users.Users)
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	created := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFrozen(created.Add(500 * time.Millisecond))
+	ut := NewUserTable()
+	if err := ut.SetClock(clk); err != nil {
+		t.Fatalf("error %s was not expected setting the clock", err)
+	}
+
+	pending := newUser(1, "davyj", domain.Primary)
+	pending.Status = domain.Pending
+	pending.ActivationToken = "goodtoken"
+	pending.ActivationExpiry = created.Add(time.Hour)
+	id, _ := ut.CreateUser(pending)
+
+	u, _ := ut.GetUser(id)
+	if !u.CreatedAt.Equal(created) || !u.UpdatedAt.Equal(created) {
+		t.Errorf("expected CreatedAt and UpdatedAt %s, got %s and %s", created, u.CreatedAt, u.UpdatedAt)
+	}
+
+	clk.Advance(30 * time.Minute)
+	updated := newUser(1, "davy", domain.Primary)
+	updated.ID = id
+	ut.UpdateUser(updated)
+	u, _ = ut.GetUser(id)
+	if !u.CreatedAt.Equal(created) || !u.UpdatedAt.Equal(created.Add(30*time.Minute)) {
+		t.Errorf("expected CreatedAt %s and UpdatedAt %s, got %s and %s", created, created.Add(30*time.Minute), u.CreatedAt, u.UpdatedAt)
+	}
+
+	clk.Advance(time.Hour)
+	if err := ut.ActivateUser(id, "goodtoken"); err == nil || err.ErrCode != mverr.InvalidActivationErrorCode {
+		t.Errorf("expected error code %d activating an expired user, got %v", mverr.InvalidActivationErrorCode, err)
+	}
+	if n, _ := ut.DeleteExpiredUsers(); n != 1 {
+		t.Errorf("expected 1 expired user to be deleted, got %d", n)
+	}
+
+	if err := ut.SetClock(nil); err == nil {
+		t.Errorf("expected an error setting a nil clock")
+	}
+}
+
+func TestRecordLogin(t *testing.T) {
+	created := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFrozen(created)
+	ut := NewUserTable()
+	if err := ut.SetClock(clk); err != nil {
+		t.Fatalf("error %s was not expected setting the clock", err)
+	}
+
+	loggedIn, _ := ut.CreateUser(newUser(1, "davyj", domain.Primary))
+	neverLoggedIn, _ := ut.CreateUser(newUser(1, "jdavy", domain.Unrestricted))
+	clk.Advance(24 * time.Hour)
+	recent := newUser(1, "ydavj", domain.Unrestricted)
+	recent.LastModifiedBy = "user:1"
+	recentID, _ := ut.CreateUser(recent)
+
+	clk.Advance(time.Hour)
+	if err := ut.RecordLogin(loggedIn); err != nil {
+		t.Fatalf("error %s was not expected recording a login", err)
+	}
+	if err := ut.RecordLogin(99); err != nil {
+		t.Errorf("error %s was not expected recording the login of a non-existent user", err)
+	}
+	u, _ := ut.GetUser(loggedIn)
+	lastLogin := created.Add(25 * time.Hour)
+	if u.LastLogin == nil || !u.LastLogin.Equal(lastLogin) {
+		t.Errorf("expected LastLogin %s, got %v", lastLogin, u.LastLogin)
+	}
+	if !u.UpdatedAt.Equal(created) {
+		t.Errorf("expected UpdatedAt to be unchanged by the login, got %s", u.UpdatedAt)
+	}
+
+	clk.Advance(time.Hour)
+	updated := newUser(1, "davy", domain.Primary)
+	updated.ID = loggedIn
+	updated.LastModifiedBy = "apikey:batch"
+	ut.UpdateUser(updated)
+	u, _ = ut.GetUser(loggedIn)
+	if u.LastLogin == nil || !u.LastLogin.Equal(lastLogin) || u.LastModifiedBy != "apikey:batch" {
+		t.Errorf("expected LastLogin %s and LastModifiedBy apikey:batch after an update, got %v and %s", lastLogin, u.LastLogin, u.LastModifiedBy)
+	}
+	u, _ = ut.GetUser(recentID)
+	if u.LastLogin != nil || u.LastModifiedBy != "user:1" {
+		t.Errorf("expected no LastLogin and LastModifiedBy user:1, got %v and %s", u.LastLogin, u.LastModifiedBy)
+	}
+
+	tcs := []struct {
+		testName    string
+		since       time.Time
+		expectedIDs []int
+	}{
+		{testName: "testNoneInactive", since: created, expectedIDs: nil},
+		{testName: "testNeverLoggedIn", since: created.Add(time.Hour), expectedIDs: []int{neverLoggedIn}},
+		{testName: "testAllInactive", since: created.Add(48 * time.Hour), expectedIDs: []int{loggedIn, neverLoggedIn, recentID}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			us, err := ut.GetInactiveUsers(tc.since)
+			if err != nil {
+				t.Fatalf("error %s was not expected getting inactive users", err)
+			}
+			var ids []int
+			for _, u := range us.Users {
+				ids = append(ids, u.ID)
+			}
+			if !reflect.DeepEqual(ids, tc.expectedIDs) {
+				t.Errorf("expected users %v, got %v", tc.expectedIDs, ids)
+			}
+		})
+	}
+}
+
+func TestUpdateRoles(t *testing.T) {
+	tests := []struct {
+		testName        string
+		roles           map[int]domain.Role
+		expectedErrCode mverr.ErrCode
+	}{
+		{
+			testName:        "testUpdateRolesChangePrimary",
+			roles:           map[int]domain.Role{1: domain.Unrestricted, 2: domain.Primary},
+			expectedErrCode: mverr.NoErrorCode,
+		},
+		{
+			testName:        "testUpdateRolesNoPrimary",
+			roles:           map[int]domain.Role{1: domain.Restricted},
+			expectedErrCode: mverr.InvalidRoleAssignmentErrorCode,
+		},
+		{
+			testName:        "testUpdateRolesOtherAccount",
+			roles:           map[int]domain.Role{1: domain.Unrestricted, 3: domain.Primary},
+			expectedErrCode: mverr.DBNoUserErrorCode,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			ut := NewUserTable()
+			ut.CreateUser(newUser(1, "mickeyd", domain.Primary))
+			ut.CreateUser(newUser(1, "davyj", domain.Unrestricted))
+			ut.CreateUser(newUser(2, "peter", domain.Primary))
+
+			err := ut.UpdateRoles(1, tc.roles)
+			if tc.expectedErrCode == mverr.NoErrorCode {
+				if err != nil {
+					t.Fatalf("error %s was not expected", err)
+				}
+				for id, role := range tc.roles {
+					if u, _ := ut.GetUser(id); u.Role != role {
+						t.Errorf("expected user %d to have role %d, got %d", id, role, u.Role)
+					}
+				}
+				return
+			}
+
+			if err == nil || err.ErrCode != tc.expectedErrCode {
+				t.Fatalf("expected error code %d, got %v", tc.expectedErrCode, err)
+			}
+			if u, _ := ut.GetUser(1); u.Role != domain.Primary {
+				t.Errorf("expected roles to be unchanged, user 1 has role %d", u.Role)
+			}
+		})
+	}
+}
+
+func TestGetUserDependencies(t *testing.T) {
+	ut := NewUserTable()
+	ut.CreateUser(newUser(1, "mickeyd", domain.Primary))
+	ut.CreateUser(newUser(1, "davyj", domain.Restricted))
+	pending := newUser(1, "peter", domain.Restricted)
+	pending.Status = domain.Pending
+	ut.CreateUser(pending)
+	ut.CreateUser(newUser(2, "mamacass", domain.Primary))
+
+	tcs := []struct {
+		testName      string
+		id            int
+		expectedCount int
+	}{
+		{testName: "testPrimaryWithOtherUsers", id: 1, expectedCount: 2},
+		{testName: "testNotPrimary", id: 2},
+		{testName: "testOnlyUser", id: 4},
+		{testName: "testNoUser", id: 100},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			deps, err := ut.GetUserDependencies(tc.id)
+			if err != nil {
+				t.Fatalf("error %s was not expected", err)
+			}
+			if tc.expectedCount == 0 {
+				if len(deps) != 0 {
+					t.Errorf("expected no dependencies, got %+v", deps)
+				}
+				return
+			}
+			if len(deps) != 1 || deps[0].Kind != domain.DependentAccountUsers || deps[0].Count != tc.expectedCount {
+				t.Errorf("expected %d dependent account users, got %+v", tc.expectedCount, deps)
+			}
+		})
+	}
+}
+
+func TestDeleteUserWithSuccessor(t *testing.T) {
+	tcs := []struct {
+		testName        string
+		id              int
+		successorID     int
+		expectedErrCode mverr.ErrCode
+	}{
+		{testName: "testSuccessor", id: 1, successorID: 2},
+		{testName: "testNoUser", id: 100, successorID: 2},
+		{testName: "testNotPrimary", id: 2, successorID: 3, expectedErrCode: mverr.InvalidSuccessorErrorCode},
+		{testName: "testSuccessorInOtherAccount", id: 1, successorID: 4, expectedErrCode: mverr.InvalidSuccessorErrorCode},
+		{testName: "testSuccessorIsUser", id: 1, successorID: 1, expectedErrCode: mverr.InvalidSuccessorErrorCode},
+		{testName: "testNoSuccessor", id: 1, successorID: 100, expectedErrCode: mverr.InvalidSuccessorErrorCode},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			ut := NewUserTable()
+			ut.CreateUser(newUser(1, "mickeyd", domain.Primary))
+			ut.CreateUser(newUser(1, "davyj", domain.Restricted))
+			ut.CreateUser(newUser(1, "peter", domain.Restricted))
+			ut.CreateUser(newUser(2, "mamacass", domain.Primary))
+
+			deleted, err := ut.DeleteUserWithSuccessor(tc.id, tc.successorID)
+			if tc.expectedErrCode != 0 {
+				if err == nil || err.ErrCode != tc.expectedErrCode {
+					t.Fatalf("expected error code %d, got %v", tc.expectedErrCode, err)
+				}
+				if u, _ := ut.GetUser(tc.id); u == nil {
+					t.Errorf("expected user %d not to be deleted", tc.id)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error %s was not expected", err)
+			}
+			if u, _ := ut.GetUser(tc.id); u != nil {
+				t.Errorf("expected user %d to be deleted", tc.id)
+			}
+			if deleted != (tc.id != 100) {
+				t.Errorf("expected deleted to be %t, got %t", tc.id != 100, deleted)
+			}
+			if tc.id == 100 {
+				if u, _ := ut.GetUser(tc.successorID); u.Role != domain.Restricted {
+					t.Errorf("expected successor's role to be unchanged, got %d", u.Role)
+				}
+				return
+			}
+			if u, _ := ut.GetUser(tc.successorID); u == nil || u.Role != domain.Primary {
+				t.Errorf("expected successor %d to be the primary user, got %+v", tc.successorID, u)
+			}
+		})
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	ut := NewUserTable()
+	primary, err := ut.CreateUser(newUser(1, "mickeyd", domain.Primary))
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a user", err)
+	}
+	restricted, err := ut.CreateUser(newUser(1, "davyj", domain.Restricted))
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a user", err)
+	}
+	ut.Freeze()
+
+	updated := newUser(1, "mickeyd", domain.Primary)
+	updated.ID = primary
+	updated.Name = "micky dolenz"
+	writes := map[string]func() *mverr.MVError{
+		"CreateUser": func() *mverr.MVError {
+			_, err := ut.CreateUser(newUser(1, "peterc", domain.Restricted))
+			return err
+		},
+		"UpsertUser": func() *mverr.MVError {
+			_, _, err := ut.UpsertUser(newUser(1, "peterc", domain.Restricted))
+			return err
+		},
+		"UpdateUser":  func() *mverr.MVError { return ut.UpdateUser(updated) },
+		"RecordLogin": func() *mverr.MVError { return ut.RecordLogin(primary) },
+		"DeleteUser": func() *mverr.MVError {
+			_, err := ut.DeleteUser(restricted)
+			return err
+		},
+		"DeleteUserWithSuccessor": func() *mverr.MVError {
+			_, err := ut.DeleteUserWithSuccessor(primary, restricted)
+			return err
+		},
+		"ActivateUser": func() *mverr.MVError { return ut.ActivateUser(restricted, "token") },
+		"UpdateRoles": func() *mverr.MVError {
+			return ut.UpdateRoles(1, map[int]domain.Role{primary: domain.Unrestricted, restricted: domain.Primary})
+		},
+	}
+	for name, write := range writes {
+		if err := write(); err == nil || err.ErrCode != mverr.DemoModeErrorCode {
+			t.Errorf("expected error code %d from %s once frozen, got %v", mverr.DemoModeErrorCode, name, err)
+		}
+	}
+	// Without expired users the expiry check doesn't change anything
+	if n, err := ut.DeleteExpiredUsers(); n != 0 || err != nil {
+		t.Errorf("expected no users deleted and no error, got %d, error %v", n, err)
+	}
+
+	users, err := ut.GetUsers()
+	if err != nil {
+		t.Fatalf("error %s was not expected getting the users", err)
+	}
+	if len(users.Users) != 2 || users.Users[0].Name != "mickeyd" || users.Users[0].LastLogin != nil {
+		t.Errorf("expected the 2 users to be unchanged, got %+v", users.Users)
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/httpclient"
+	"github.com/youngkin/mockvideo/internal/metrics"
+)
+
+// ReadOnlyMode is 1 while a ReadOnlyRepository is in read-only mode, 0 otherwise
+var ReadOnlyMode = metrics.NewGauge(prometheus.GaugeOpts{
+	Subsystem: "database",
+	Name:      "read_only_mode",
+	Help:      "1 while writes are rejected because writes to the database are failing persistently, 0 otherwise",
+})
+
+// ReadOnlyRepository is a domain.UserRepository that degrades to read-only mode when writes to
+// another UserRepository, normally a Table, fail persistently. It enters read-only mode when the
+// breaker's failure threshold of consecutive writes fail, or immediately when the DB reports that
+// it's read-only. In read-only mode reads are unaffected and writes fail immediately with a
+// ReadOnlyModeErrorCode error whose RetryAfter is the time until the next trial write. The mode is
+// exited as soon as a trial write succeeds.
+type ReadOnlyRepository struct {
+	repo    domain.UserRepository
+	breaker *httpclient.Breaker
+	// ctx is only set when the ReadOnlyRepository is bound to a request, see WithContext
+	ctx context.Context
+}
+
+// NewReadOnlyRepository returns a ReadOnlyRepository protecting writes to 'repo' with 'breaker'.
+// Both must be non-nil and 'breaker' should only be used by this ReadOnlyRepository.
+func NewReadOnlyRepository(repo domain.UserRepository, breaker *httpclient.Breaker) (*ReadOnlyRepository, error) {
+	if repo == nil {
+		return nil, errors.New("non-nil domain.UserRepository required")
+	}
+	if breaker == nil {
+		return nil, errors.New("non-nil *httpclient.Breaker required")
+	}
+	ReadOnlyMode.Set(0)
+	return &ReadOnlyRepository{repo: repo, breaker: breaker}, nil
+}
+
+// ReadOnly returns true while in read-only mode, including while a trial write is in flight
+func (ro *ReadOnlyRepository) ReadOnly() bool {
+	return ro.breaker.State() != httpclient.Closed
+}
+
+// WithContext returns a copy of the ReadOnlyRepository whose protected UserRepository is bound to
+// 'ctx', if it supports it. Failed writes caused by 'ctx' being done, e.g., a gRPC client's deadline
+// passing, are returned as QueryTimeoutErrorCode errors and don't count towards read-only mode.
+func (ro *ReadOnlyRepository) WithContext(ctx context.Context) domain.UserRepository {
+	r := *ro
+	r.repo = bindContext(ctx, ro.repo)
+	r.ctx = ctx
+	return &r
+}
+
+// write calls 'fn', a write, unless in read-only mode and records its outcome
+func (ro *ReadOnlyRepository) write(fn func() *mverr.MVError) *mverr.MVError {
+	if !ro.breaker.Allow() {
+		retryAfter := ro.breaker.RetryAfter()
+		return &mverr.MVError{
+			ErrCode:    mverr.ReadOnlyModeErrorCode,
+			ErrMsg:     mverr.ReadOnlyModeErrorMsg,
+			ErrDetail:  fmt.Sprintf("writes are failing persistently, next write attempt in %s", retryAfter),
+			RetryAfter: retryAfter,
+		}
+	}
+
+	err := fn()
+	if err != nil && isReadOnlyFailure(err) {
+		ro.breaker.Trip()
+	} else {
+		err = abandoned(ro.ctx, err)
+		ro.breaker.Record(err == nil || !isDBFailure(err.ErrCode))
+	}
+
+	mode := 0.0
+	if ro.ReadOnly() {
+		mode = 1
+	}
+	ReadOnlyMode.Set(mode)
+	return err
+}
+
+// isReadOnlyFailure returns true if 'err' was caused by the DB rejecting a write because it's
+// read-only, e.g., a replica or a primary started with '--read-only'
+func isReadOnlyFailure(err *mverr.MVError) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err.WrappedErr, &mysqlErr) {
+		return false
+	}
+	return mysqlErr.Number == mverr.MySQLOptionPreventsStatementErrorCode ||
+		mysqlErr.Number == mverr.MySQLReadOnlyModeErrorCode
+}
+
+// GetUsers calls GetUsers on the protected UserRepository
+func (ro *ReadOnlyRepository) GetUsers() (*domain.Users, *mverr.MVError) {
+	return ro.repo.GetUsers()
+}
+
+// GetUsersWithin calls GetUsersWithin on the protected UserRepository
+func (ro *ReadOnlyRepository) GetUsersWithin(qt domain.QueryTimeout) (*domain.Users, *mverr.MVError) {
+	return ro.repo.GetUsersWithin(qt)
+}
+
+// GetUsersPage calls GetUsersPage on the protected UserRepository
+func (ro *ReadOnlyRepository) GetUsersPage(afterID, limit int) (*domain.Users, *mverr.MVError) {
+	return ro.repo.GetUsersPage(afterID, limit)
+}
+
+// SearchUsers calls SearchUsers on the protected UserRepository
+func (ro *ReadOnlyRepository) SearchUsers(query string, limit int) (*domain.Users, *mverr.MVError) {
+	return ro.repo.SearchUsers(query, limit)
+}
+
+// UsersVersion calls UsersVersion on the protected UserRepository
+func (ro *ReadOnlyRepository) UsersVersion() (*domain.UsersVersion, *mverr.MVError) {
+	return ro.repo.UsersVersion()
+}
+
+// GetUser calls GetUser on the protected UserRepository
+func (ro *ReadOnlyRepository) GetUser(id int) (*domain.User, *mverr.MVError) {
+	return ro.repo.GetUser(id)
+}
+
+// EmailInUse calls EmailInUse on the protected UserRepository
+func (ro *ReadOnlyRepository) EmailInUse(email string, exceptID int) (bool, *mverr.MVError) {
+	return ro.repo.EmailInUse(email, exceptID)
+}
+
+// GetCredentials calls GetCredentials on the protected UserRepository
+func (ro *ReadOnlyRepository) GetCredentials(email string) (*domain.User, string, *mverr.MVError) {
+	return ro.repo.GetCredentials(email)
+}
+
+// GetUserDependencies calls GetUserDependencies on the protected UserRepository
+func (ro *ReadOnlyRepository) GetUserDependencies(id int) ([]domain.UserDependency, *mverr.MVError) {
+	return ro.repo.GetUserDependencies(id)
+}
+
+// CreateUser calls CreateUser on the protected UserRepository unless in read-only mode
+func (ro *ReadOnlyRepository) CreateUser(user domain.User) (id int, err *mverr.MVError) {
+	err = ro.write(func() *mverr.MVError {
+		id, err = ro.repo.CreateUser(user)
+		return err
+	})
+	return id, err
+}
+
+// UpsertUser calls UpsertUser on the protected UserRepository unless in read-only mode
+func (ro *ReadOnlyRepository) UpsertUser(user domain.User) (id int, outcome domain.UpsertOutcome, err *mverr.MVError) {
+	err = ro.write(func() *mverr.MVError {
+		id, outcome, err = ro.repo.UpsertUser(user)
+		return err
+	})
+	return id, outcome, err
+}
+
+// UpdateUser calls UpdateUser on the protected UserRepository unless in read-only mode
+func (ro *ReadOnlyRepository) UpdateUser(user domain.User) *mverr.MVError {
+	return ro.write(func() *mverr.MVError {
+		return ro.repo.UpdateUser(user)
+	})
+}
+
+// DeleteUser calls DeleteUser on the protected UserRepository unless in read-only mode
+func (ro *ReadOnlyRepository) DeleteUser(id int) (deleted bool, err *mverr.MVError) {
+	err = ro.write(func() *mverr.MVError {
+		deleted, err = ro.repo.DeleteUser(id)
+		return err
+	})
+	return deleted, err
+}
+
+// DeleteUserWithSuccessor calls DeleteUserWithSuccessor on the protected UserRepository unless in
+// read-only mode
+func (ro *ReadOnlyRepository) DeleteUserWithSuccessor(id, successorID int) (deleted bool, err *mverr.MVError) {
+	err = ro.write(func() *mverr.MVError {
+		deleted, err = ro.repo.DeleteUserWithSuccessor(id, successorID)
+		return err
+	})
+	return deleted, err
+}
+
+// ActivateUser calls ActivateUser on the protected UserRepository unless in read-only mode
+func (ro *ReadOnlyRepository) ActivateUser(id int, token string) *mverr.MVError {
+	return ro.write(func() *mverr.MVError {
+		return ro.repo.ActivateUser(id, token)
+	})
+}
+
+// DeleteExpiredUsers calls DeleteExpiredUsers on the protected UserRepository unless in read-only mode
+func (ro *ReadOnlyRepository) DeleteExpiredUsers() (n int, err *mverr.MVError) {
+	err = ro.write(func() *mverr.MVError {
+		n, err = ro.repo.DeleteExpiredUsers()
+		return err
+	})
+	return n, err
+}
+
+// UpdateRoles calls UpdateRoles on the protected UserRepository unless in read-only mode
+func (ro *ReadOnlyRepository) UpdateRoles(accountID int, roles map[int]domain.Role) *mverr.MVError {
+	return ro.write(func() *mverr.MVError {
+		return ro.repo.UpdateRoles(accountID, roles)
+	})
+}
+
+// RecordLogin calls RecordLogin on the protected UserRepository unless in read-only mode
+func (ro *ReadOnlyRepository) RecordLogin(id int) *mverr.MVError {
+	return ro.write(func() *mverr.MVError {
+		return ro.repo.RecordLogin(id)
+	})
+}
+
+// GetInactiveUsers calls GetInactiveUsers on the protected UserRepository
+func (ro *ReadOnlyRepository) GetInactiveUsers(since time.Time) (*domain.Users, *mverr.MVError) {
+	return ro.repo.GetInactiveUsers(since)
+}
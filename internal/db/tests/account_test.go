@@ -0,0 +1,318 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tests
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+var testAccount = domain.Account{
+	AccountHolderName: "Porgy Tirebiter",
+	ServiceAddress:    "1 Main St, Springfield",
+	BillingAddress:    "1 Main St, Springfield",
+	EMail:             "porgytirebiter@email.com",
+	Phone:             "5555550100",
+}
+
+var testBillingContact = domain.BillingContact{
+	Name:  "Porgy Tirebiter",
+	EMail: "billing@email.com",
+	Address: domain.Address{
+		Street:     "1 Main St",
+		City:       "Springfield",
+		Region:     "OR",
+		PostalCode: "97477",
+		Country:    "US",
+	},
+}
+
+// accountWithContact returns testAccount with testBillingContact as its BillingContact
+func accountWithContact() domain.Account {
+	a := testAccount
+	bc := testBillingContact
+	a.BillingContact = &bc
+	return a
+}
+
+func TestCreateAccount(t *testing.T) {
+	tcs := []struct {
+		testName        string
+		account         domain.Account
+		setupFunc       func(mock sqlmock.Sqlmock)
+		expectedID      int
+		expectedErrCode mverr.ErrCode
+	}{
+		{
+			testName: "testCreateAccountSuccess",
+			account:  testAccount,
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO account").
+					WithArgs(testAccount.AccountHolderName, testAccount.NickName, testAccount.ServiceAddress,
+						testAccount.BillingAddress, testAccount.EMail, testAccount.Phone).
+					WillReturnResult(sqlmock.NewResult(3, 1))
+			},
+			expectedID: 3,
+		},
+		{
+			testName: "testCreateAccountWithContact",
+			account:  accountWithContact(),
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO account").WillReturnResult(sqlmock.NewResult(3, 1))
+				mock.ExpectExec("INSERT INTO address").
+					WithArgs("1 Main St", nil, "Springfield", "OR", "97477", "US").
+					WillReturnResult(sqlmock.NewResult(7, 1))
+				mock.ExpectExec("INSERT INTO billingContact").
+					WithArgs(3, "Porgy Tirebiter", "billing@email.com", nil, 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expectedID: 3,
+		},
+		{
+			testName: "testCreateAccountContactRolledBack",
+			account:  accountWithContact(),
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO account").WillReturnResult(sqlmock.NewResult(3, 1))
+				mock.ExpectExec("INSERT INTO address").WillReturnResult(sqlmock.NewResult(7, 1))
+				mock.ExpectExec("INSERT INTO billingContact").WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			expectedErrCode: mverr.DBUpSertErrorCode,
+		},
+		{
+			testName: "testCreateAccountInvalidPostalCode",
+			account: func() domain.Account {
+				a := accountWithContact()
+				a.BillingContact.Address.PostalCode = "K1A 0B1"
+				return a
+			}(),
+			setupFunc:       func(mock sqlmock.Sqlmock) {},
+			expectedErrCode: mverr.AccountValidationErrorCode,
+		},
+		{
+			testName:        "testCreateAccountInvalid",
+			account:         domain.Account{EMail: testAccount.EMail, Phone: "55555501000"},
+			setupFunc:       func(mock sqlmock.Sqlmock) {},
+			expectedErrCode: mverr.AccountValidationErrorCode,
+		},
+		{
+			testName: "testCreateAccountDuplicate",
+			account:  testAccount,
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO account").
+					WillReturnError(&mysql.MySQLError{Number: mverr.MySQLDupInsertErrorCode, Message: "Duplicate entry"})
+			},
+			expectedErrCode: mverr.DBInsertDuplicateAccountErrorCode,
+		},
+		{
+			testName: "testCreateAccountDBError",
+			account:  testAccount,
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO account").WillReturnError(sql.ErrConnDone)
+			},
+			expectedErrCode: mverr.DBUpSertErrorCode,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+			}
+			defer dbase.Close()
+			tc.setupFunc(mock)
+
+			accounts, err := db.NewAccountTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating account table instance: %s", err)
+			}
+
+			id, mvErr := accounts.CreateAccount(tc.account)
+			if tc.expectedErrCode != mverr.NoErrorCode {
+				if mvErr == nil || mvErr.ErrCode != tc.expectedErrCode {
+					t.Fatalf("expected error code %d, got %v", tc.expectedErrCode, mvErr)
+				}
+			} else {
+				validateExpectedErrors(t, mvErr, true)
+				if id != tc.expectedID {
+					t.Errorf("expected account ID %d, got %d", tc.expectedID, id)
+				}
+			}
+
+			DBCallTeardownHelper(t, mock)
+		})
+	}
+}
+
+// TestUnitOfWorkAccounts verifies that an account and its first user are created in a single transaction
+func TestUnitOfWorkAccounts(t *testing.T) {
+	tcs := []struct {
+		testName   string
+		shouldPass bool
+		setupFunc  func(mock sqlmock.Sqlmock)
+	}{
+		{
+			testName:   "testAccountAndUserCommitted",
+			shouldPass: true,
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO account").WillReturnResult(sqlmock.NewResult(3, 1))
+				mock.ExpectExec("INSERT INTO user").WillReturnResult(sqlmock.NewResult(42, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			testName:   "testAccountRolledBack",
+			shouldPass: false,
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO account").WillReturnResult(sqlmock.NewResult(3, 1))
+				mock.ExpectExec("INSERT INTO user").WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+			}
+			defer dbase.Close()
+			tc.setupFunc(mock)
+
+			users, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			accounts, err := db.NewAccountTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating account table instance: %s", err)
+			}
+			uow, err := db.NewUnitOfWork(dbase, users, nil)
+			if err != nil {
+				t.Fatalf("error creating unit of work instance: %s", err)
+			}
+			if err = uow.SetAccountTable(accounts); err != nil {
+				t.Fatalf("error %s was not expected when setting the account table", err)
+			}
+
+			mvErr := uow.Do(func(repos domain.Repositories) *mverr.MVError {
+				accountID, err := repos.Accounts.CreateAccount(testAccount)
+				if err != nil {
+					return err
+				}
+				_, err = repos.Users.CreateUser(domain.User{
+					AccountID: accountID,
+					Name:      "porgy tirebiter",
+					EMail:     testAccount.EMail,
+					Role:      domain.Primary,
+					Password:  "myawesomepassword",
+				})
+				return err
+			})
+			validateExpectedErrors(t, mvErr, tc.shouldPass)
+
+			DBCallTeardownHelper(t, mock)
+		})
+	}
+}
+
+func TestGetAccount(t *testing.T) {
+	columns := []string{"id", "accountHolderName", "nickName", "serviceAddress", "billingAddress", "email", "phone",
+		"name", "email", "phone", "street", "street2", "city", "region", "postalCode", "country"}
+	tcs := []struct {
+		testName        string
+		setupFunc       func(mock sqlmock.Sqlmock)
+		expected        *domain.Account
+		expectedErrCode mverr.ErrCode
+	}{
+		{
+			testName: "testGetAccountWithContact",
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT (.+) FROM account a LEFT JOIN billingContact").WithArgs(3).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(3, testAccount.AccountHolderName, nil,
+						testAccount.ServiceAddress, testAccount.BillingAddress, testAccount.EMail, testAccount.Phone,
+						"Porgy Tirebiter", "billing@email.com", nil, "1 Main St", nil, "Springfield", "OR", "97477", "US"))
+			},
+			expected: func() *domain.Account {
+				a := accountWithContact()
+				a.ID = 3
+				return &a
+			}(),
+		},
+		{
+			testName: "testGetAccountWithoutContact",
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT (.+) FROM account a LEFT JOIN billingContact").WithArgs(3).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(3, testAccount.AccountHolderName, nil,
+						testAccount.ServiceAddress, testAccount.BillingAddress, testAccount.EMail, testAccount.Phone,
+						nil, nil, nil, nil, nil, nil, nil, nil, nil))
+			},
+			expected: func() *domain.Account {
+				a := testAccount
+				a.ID = 3
+				return &a
+			}(),
+		},
+		{
+			testName: "testGetAccountNotFound",
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT (.+) FROM account a LEFT JOIN billingContact").WithArgs(3).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+		},
+		{
+			testName: "testGetAccountDBError",
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT (.+) FROM account a LEFT JOIN billingContact").WithArgs(3).
+					WillReturnError(sql.ErrConnDone)
+			},
+			expectedErrCode: mverr.DBRowScanErrorCode,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+			}
+			defer dbase.Close()
+			tc.setupFunc(mock)
+
+			accounts, err := db.NewAccountTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating account table instance: %s", err)
+			}
+
+			a, mvErr := accounts.GetAccount(3)
+			if tc.expectedErrCode != mverr.NoErrorCode {
+				if mvErr == nil || mvErr.ErrCode != tc.expectedErrCode {
+					t.Fatalf("expected error code %d, got %v", tc.expectedErrCode, mvErr)
+				}
+			} else {
+				validateExpectedErrors(t, mvErr, true)
+				if !reflect.DeepEqual(a, tc.expected) {
+					t.Errorf("expected account %+v, got %+v", tc.expected, a)
+				}
+			}
+
+			DBCallTeardownHelper(t, mock)
+		})
+	}
+}
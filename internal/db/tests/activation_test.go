@@ -0,0 +1,100 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tests
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/youngkin/mockvideo/internal/clock"
+	"github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+func TestActivateUser(t *testing.T) {
+	tests := []struct {
+		testName        string
+		shouldPass      bool
+		token           string
+		expectedErrCode mverr.ErrCode
+		setupFunc       func(*testing.T) (*sql.DB, sqlmock.Sqlmock)
+		teardownFunc    func(*testing.T, sqlmock.Sqlmock)
+	}{
+		{
+			testName:     "testActivateUserSuccess",
+			shouldPass:   true,
+			token:        "goodtoken",
+			setupFunc:    DBActivateUserSetupHelper,
+			teardownFunc: DBCallTeardownHelper,
+		},
+		{
+			testName:        "testActivateUserNoMatch",
+			shouldPass:      false,
+			token:           "badtoken",
+			expectedErrCode: mverr.InvalidActivationErrorCode,
+			setupFunc:       DBActivateUserNoMatchSetupHelper,
+			teardownFunc:    DBCallTeardownHelper,
+		},
+		{
+			testName:        "testActivateUserDBError",
+			shouldPass:      false,
+			token:           "goodtoken",
+			expectedErrCode: mverr.DBUpSertErrorCode,
+			setupFunc:       DBActivateUserErrorSetupHelper,
+			teardownFunc:    DBCallTeardownHelper,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock := tc.setupFunc(t)
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			defer dbase.Close()
+
+			err2 := ut.ActivateUser(1, tc.token)
+			validateExpectedErrors(t, err2, tc.shouldPass)
+			if err2 != nil && err2.ErrCode != tc.expectedErrCode {
+				t.Errorf("expected error code %d, got %d", tc.expectedErrCode, err2.ErrCode)
+			}
+
+			tc.teardownFunc(t, mock)
+		})
+	}
+}
+
+func TestDeleteExpiredUsers(t *testing.T) {
+	dbase, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+	defer dbase.Close()
+
+	now := time.Date(2020, time.July, 4, 9, 30, 15, 0, time.UTC)
+	mock.ExpectExec("DELETE FROM user WHERE status").
+		WithArgs(string(domain.Pending), now).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	ut, err := db.NewTable(dbase)
+	if err != nil {
+		t.Fatalf("error creating user table instance: %s", err)
+	}
+	if err = ut.SetClock(clock.NewFrozen(now)); err != nil {
+		t.Fatalf("error setting the user table's clock: %s", err)
+	}
+
+	n, err2 := ut.DeleteExpiredUsers()
+	validateExpectedErrors(t, err2, true)
+	if n != 3 {
+		t.Errorf("expected 3 expired users to be deleted, got %d", n)
+	}
+
+	DBCallTeardownHelper(t, mock)
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tests
+
+/*
+These benchmarks measure the overhead of the db.Table methods, e.g., row scanning, error
+handling, and metrics, using a mock database. Setting up the mock database isn't measured.
+*/
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+// batchSize is the number of calls a mock database is set up to expect
+const batchSize = 10
+
+// runBenchmark calls 'op' b.N times. sqlmock checks every expectation on each call, so rather
+// than setting up b.N expectations on one mock database, which would make each call slower than
+// the last, a new mock database with 'batchSize' expectations is set up by 'expect' as needed.
+func runBenchmark(b *testing.B, expect func(sqlmock.Sqlmock), op func(*db.Table) *mverr.MVError) {
+	var dbase *sql.DB
+	var ut *db.Table
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%batchSize == 0 {
+			b.StopTimer()
+			if dbase != nil {
+				dbase.Close()
+			}
+			var mock sqlmock.Sqlmock
+			var err error
+			dbase, mock, err = sqlmock.New()
+			if err != nil {
+				b.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+			}
+			for j := 0; j < batchSize; j++ {
+				expect(mock)
+			}
+			ut, err = db.NewTable(dbase)
+			if err != nil {
+				b.Fatalf("error creating user table instance: %s", err)
+			}
+			b.StartTimer()
+		}
+
+		if err := op(ut); err != nil {
+			b.Fatalf("error '%s' was not expected", err)
+		}
+	}
+	b.StopTimer()
+	dbase.Close()
+}
+
+func userRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby"}).
+		AddRow(1, 1, "porgy tirebiter", "porgytirebiter@email.com", domain.Primary, domain.Active, createdAt, updatedAt, nil, nil).
+		AddRow(1, 2, "mickey dolenz", "mdolenz@themonkeys.com", domain.Restricted, domain.Active, createdAt, updatedAt, nil, nil)
+}
+
+func BenchmarkGetUsers(b *testing.B) {
+	runBenchmark(b, func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user").WillReturnRows(userRows())
+	}, func(ut *db.Table) *mverr.MVError {
+		_, err := ut.GetUsers()
+		return err
+	})
+}
+
+func BenchmarkGetUser(b *testing.B) {
+	runBenchmark(b, func(mock sqlmock.Sqlmock) {
+		rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby"}).
+			AddRow(1, 1, "porgy tirebiter", "porgytirebiter@email.com", domain.Primary, domain.Active, createdAt, updatedAt, nil, nil)
+		mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user").WithArgs(1).WillReturnRows(rows)
+	}, func(ut *db.Table) *mverr.MVError {
+		_, err := ut.GetUser(1)
+		return err
+	})
+}
+
+func BenchmarkCreateUser(b *testing.B) {
+	u := domain.User{AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary, Password: "pw"}
+	runBenchmark(b, func(mock sqlmock.Sqlmock) {
+		mock.ExpectExec("INSERT INTO user").WillReturnResult(sqlmock.NewResult(1, 1))
+	}, func(ut *db.Table) *mverr.MVError {
+		_, err := ut.CreateUser(u)
+		return err
+	})
+}
+
+func BenchmarkUpdateUser(b *testing.B) {
+	u := domain.User{AccountID: 1, ID: 2, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Unrestricted, Password: "pw"}
+	runBenchmark(b, func(mock sqlmock.Sqlmock) {
+		rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby"}).
+			AddRow(1, 2, "mickey dolenz", "mickeyd@gmail.com", domain.Unrestricted, domain.Active, createdAt, updatedAt, nil, nil)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user WHERE id = ?").WithArgs(u.ID).WillReturnRows(rows)
+		mock.ExpectExec("UPDATE user SET (.+) WHERE (.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}, func(ut *db.Table) *mverr.MVError {
+		return ut.UpdateUser(u)
+	})
+}
+
+func BenchmarkDeleteUser(b *testing.B) {
+	runBenchmark(b, func(mock sqlmock.Sqlmock) {
+		mock.ExpectExec("DELETE FROM user").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	}, func(ut *db.Table) *mverr.MVError {
+		_, err := ut.DeleteUser(1)
+		return err
+	})
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/httpclient"
+)
+
+func TestBreakerRepository(t *testing.T) {
+	tcs := []struct {
+		testName string
+		// expect sets up the DB requests expected for the first two calls
+		expect func(mock sqlmock.Sqlmock)
+		// call is made three times, the first two fail
+		call func(br *db.BreakerRepository) *mverr.MVError
+		// expectedErrCode is the error code of the third call
+		expectedErrCode mverr.ErrCode
+	}{
+		{
+			testName: "testOpensOnDBFailures",
+			expect: func(mock sqlmock.Sqlmock) {
+				for i := 0; i < 2; i++ {
+					mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user WHERE id = \\?").
+						WillReturnError(fmt.Errorf("some error"))
+				}
+			},
+			call: func(br *db.BreakerRepository) *mverr.MVError {
+				_, err := br.GetUser(1)
+				return err
+			},
+			expectedErrCode: mverr.DBUnavailableErrorCode,
+		},
+		{
+			testName: "testIgnoresRequestErrors",
+			expect:   func(mock sqlmock.Sqlmock) {},
+			call: func(br *db.BreakerRepository) *mverr.MVError {
+				// An invalid user is rejected without a DB request
+				_, err := br.CreateUser(domain.User{})
+				return err
+			},
+			expectedErrCode: mverr.UserValidationErrorCode,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+			}
+			defer dbase.Close()
+			tc.expect(mock)
+
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			breaker, err := httpclient.NewBreaker(2, time.Minute)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting a Breaker", err)
+			}
+			br, err := db.NewBreakerRepository(ut, breaker)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting a BreakerRepository", err)
+			}
+
+			for i := 0; i < 2; i++ {
+				if mvErr := tc.call(br); mvErr == nil {
+					t.Fatalf("expected an error from call %d", i)
+				}
+			}
+
+			mvErr := tc.call(br)
+			if mvErr == nil || mvErr.ErrCode != tc.expectedErrCode {
+				t.Fatalf("expected error code %d, got %v", tc.expectedErrCode, mvErr)
+			}
+			if tc.expectedErrCode == mverr.DBUnavailableErrorCode && (mvErr.RetryAfter <= 0 || mvErr.RetryAfter > time.Minute) {
+				t.Errorf("expected a RetryAfter of up to %s, got %s", time.Minute, mvErr.RetryAfter)
+			}
+			// An open breaker doesn't make a DB request
+			DBCallTeardownHelper(t, mock)
+		})
+	}
+}
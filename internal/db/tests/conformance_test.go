@@ -0,0 +1,60 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tests
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/db/conformance"
+)
+
+// conformanceDSNEnv is the environment variable holding the DSN of the MySQL DB TestConformance is
+// run against. The DB must have the tables created by 'infrastructure/sql/create.sql', their rows
+// are deleted before each test.
+const conformanceDSNEnv = "MOCKVIDEO_CONFORMANCE_DSN"
+
+// TestConformance runs the repository conformance tests against a real MySQL DB, they can't be
+// verified with sqlmock. It's skipped unless MOCKVIDEO_CONFORMANCE_DSN is set, e.g.:
+//
+//	MOCKVIDEO_CONFORMANCE_DSN='admin:admin@tcp(localhost:3306)/mockvideo?parseTime=true' go test -run TestConformance ./internal/db/tests
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv(conformanceDSNEnv)
+	if dsn == "" {
+		t.Skipf("skipping the MySQL conformance tests, %s isn't set", conformanceDSNEnv)
+	}
+
+	conformance.TestRepositories(t, func(t *testing.T) conformance.Backend {
+		dbase, err := sql.Open("mysql", dsn)
+		if err != nil {
+			t.Fatalf("error %s was not expected opening the DB", err)
+		}
+		t.Cleanup(func() { dbase.Close() })
+		for _, tbl := range []string{"user", "account", "billingContact", "address"} {
+			if _, err = dbase.Exec("DELETE FROM " + tbl); err != nil {
+				t.Fatalf("error %s was not expected emptying the %s table", err, tbl)
+			}
+		}
+
+		users, err := db.NewTable(dbase)
+		if err != nil {
+			t.Fatalf("error creating user table instance: %s", err)
+		}
+		accounts, err := db.NewAccountTable(dbase)
+		if err != nil {
+			t.Fatalf("error creating account table instance: %s", err)
+		}
+		uow, err := db.NewUnitOfWork(dbase, users, nil)
+		if err != nil {
+			t.Fatalf("error creating unit of work instance: %s", err)
+		}
+		if err = uow.SetAccountTable(accounts); err != nil {
+			t.Fatalf("error %s was not expected when setting the account table", err)
+		}
+		return conformance.Backend{Users: users, Accounts: accounts, UnitOfWork: uow}
+	})
+}
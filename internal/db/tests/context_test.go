@@ -0,0 +1,96 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/httpclient"
+)
+
+// getUserQuery is the query made by GetUser
+const getUserQuery = "SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user WHERE id = \\?"
+
+func TestTableWithContext(t *testing.T) {
+	dbase, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+	defer dbase.Close()
+
+	rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby"}).
+		AddRow(1, 1, "porgy tirebiter", "porgytirebiter@email.com", domain.Primary, domain.Active, createdAt, updatedAt, nil, nil)
+	mock.ExpectQuery(getUserQuery).WithArgs(1).WillDelayFor(time.Second).WillReturnRows(rows)
+
+	ut, err := db.NewTable(dbase)
+	if err != nil {
+		t.Fatalf("error creating user table instance: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, mvErr := ut.WithContext(ctx).GetUser(1); mvErr == nil {
+		t.Fatalf("expected an error once the context's deadline passed")
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("expected the query to be abandoned when the context's deadline passed, it took %s", elapsed)
+	}
+
+	// A transaction isn't begun once the deadline has passed
+	if mvErr := ut.WithContext(ctx).UpdateUser(domain.User{ID: 1, AccountID: 1, Name: "porgy tirebiter", EMail: "porgytirebiter@email.com", Password: "xxxxx"}); mvErr == nil {
+		t.Errorf("expected an error updating a user after the context's deadline passed")
+	}
+
+	DBCallTeardownHelper(t, mock)
+}
+
+func TestBreakerRepositoryWithContext(t *testing.T) {
+	dbase, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+	defer dbase.Close()
+
+	rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby"}).
+		AddRow(1, 1, "porgy tirebiter", "porgytirebiter@email.com", domain.Primary, domain.Active, createdAt, updatedAt, nil, nil)
+	mock.ExpectQuery(getUserQuery).WithArgs(1).WillDelayFor(time.Second).WillReturnRows(rows)
+	mock.ExpectQuery(getUserQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby"}).
+		AddRow(1, 1, "porgy tirebiter", "porgytirebiter@email.com", domain.Primary, domain.Active, createdAt, updatedAt, nil, nil))
+
+	ut, err := db.NewTable(dbase)
+	if err != nil {
+		t.Fatalf("error creating user table instance: %s", err)
+	}
+	// A single DB failure would open the breaker
+	breaker, err := httpclient.NewBreaker(1, time.Minute)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting a Breaker", err)
+	}
+	br, err := db.NewBreakerRepository(ut, breaker)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting a BreakerRepository", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, mvErr := br.WithContext(ctx).GetUser(1)
+	if mvErr == nil || mvErr.ErrCode != mverr.QueryTimeoutErrorCode {
+		t.Fatalf("expected error code %d once the context's deadline passed, got %v", mverr.QueryTimeoutErrorCode, mvErr)
+	}
+
+	// An abandoned request isn't a DB failure, the breaker is still closed
+	if _, mvErr = br.GetUser(1); mvErr != nil {
+		t.Fatalf("expected the breaker to allow the request, got %s", mvErr)
+	}
+
+	DBCallTeardownHelper(t, mock)
+}
@@ -0,0 +1,265 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tests
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+func TestDeadLetterUser(t *testing.T) {
+	tcs := []struct {
+		testName        string
+		setupFunc       func(mock sqlmock.Sqlmock)
+		expectedErrCode mverr.ErrCode
+	}{
+		{
+			testName: "testDeadLetterUserSuccess",
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE userCreateQueue SET status = \\?, attempts = attempts \\+ 1").
+					WithArgs(string(domain.QueueDeadLettered), mverr.DBUnavailableErrorMsg, 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO deadLetter").
+					WithArgs(string(domain.DeadLetterUserCreate), 7).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			testName: "testDeadLetterUserInsertError",
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE userCreateQueue SET status = \\?, attempts = attempts \\+ 1").
+					WithArgs(string(domain.QueueDeadLettered), mverr.DBUnavailableErrorMsg, 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO deadLetter").
+					WithArgs(string(domain.DeadLetterUserCreate), 7).
+					WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			expectedErrCode: mverr.DBUpSertErrorCode,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+			}
+			defer dbase.Close()
+			tc.setupFunc(mock)
+
+			qt, err := db.NewQueueTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating queue table instance: %s", err)
+			}
+
+			err2 := qt.DeadLetterUser(7, mverr.DBUnavailableErrorMsg)
+			if tc.expectedErrCode == 0 && err2 != nil {
+				t.Errorf("error %s was not expected", err2)
+			}
+			if tc.expectedErrCode != 0 && (err2 == nil || err2.ErrCode != tc.expectedErrCode) {
+				t.Errorf("expected error code %d, got %v", tc.expectedErrCode, err2)
+			}
+			DBCallTeardownHelper(t, mock)
+		})
+	}
+}
+
+func TestReplayUser(t *testing.T) {
+	tcs := []struct {
+		testName         string
+		rowsAffected     int64
+		expectedReplayed bool
+	}{
+		{
+			testName:         "testReplayUserReplayed",
+			rowsAffected:     1,
+			expectedReplayed: true,
+		},
+		{
+			testName:         "testReplayUserNotDeadLettered",
+			rowsAffected:     0,
+			expectedReplayed: false,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+			}
+			defer dbase.Close()
+			mock.ExpectExec("UPDATE userCreateQueue SET status = \\?, attempts = 0").
+				WithArgs(string(domain.QueuePending), 7, string(domain.QueueDeadLettered)).
+				WillReturnResult(sqlmock.NewResult(0, tc.rowsAffected))
+
+			qt, err := db.NewQueueTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating queue table instance: %s", err)
+			}
+
+			replayed, err2 := qt.ReplayUser(7)
+			if err2 != nil {
+				t.Errorf("error %s was not expected", err2)
+			}
+			if replayed != tc.expectedReplayed {
+				t.Errorf("expected replayed %t, got %t", tc.expectedReplayed, replayed)
+			}
+			DBCallTeardownHelper(t, mock)
+		})
+	}
+}
+
+func TestGetDeadLetters(t *testing.T) {
+	created := time.Date(2020, 7, 4, 9, 30, 0, 0, time.UTC)
+
+	tcs := []struct {
+		testName        string
+		setupFunc       func(mock sqlmock.Sqlmock)
+		expected        []domain.DeadLetter
+		expectedErrCode mverr.ErrCode
+	}{
+		{
+			testName: "testGetDeadLettersSuccess",
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id", "source", "sourceid", "attempts", "errmsg", "createdat"}).
+					AddRow(1, string(domain.DeadLetterUserCreate), 7, 3, mverr.DBUnavailableErrorMsg, created).
+					AddRow(2, string(domain.DeadLetterUserCreate), 9, 3, nil, created)
+				mock.ExpectQuery("SELECT id, source, sourceID, attempts, errMsg, createdAt FROM deadLetter ORDER BY id").
+					WillReturnRows(rows)
+			},
+			expected: []domain.DeadLetter{
+				{ID: 1, Source: domain.DeadLetterUserCreate, SourceID: 7, Attempts: 3, ErrMsg: mverr.DBUnavailableErrorMsg, Created: created},
+				{ID: 2, Source: domain.DeadLetterUserCreate, SourceID: 9, Attempts: 3, Created: created},
+			},
+		},
+		{
+			testName: "testGetDeadLettersQueryError",
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT id, source, sourceID, attempts, errMsg, createdAt FROM deadLetter ORDER BY id").
+					WillReturnError(sql.ErrConnDone)
+			},
+			expectedErrCode: mverr.DBQueryErrorCode,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+			}
+			defer dbase.Close()
+			tc.setupFunc(mock)
+
+			dt, err := db.NewDeadLetterTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating dead letter table instance: %s", err)
+			}
+
+			dls, err2 := dt.GetDeadLetters()
+			if tc.expectedErrCode != 0 {
+				if err2 == nil || err2.ErrCode != tc.expectedErrCode {
+					t.Errorf("expected error code %d, got %v", tc.expectedErrCode, err2)
+				}
+				DBCallTeardownHelper(t, mock)
+				return
+			}
+			if err2 != nil {
+				t.Fatalf("error %s was not expected", err2)
+			}
+			if len(dls.DeadLetters) != len(tc.expected) {
+				t.Fatalf("expected %d dead letters, got %d", len(tc.expected), len(dls.DeadLetters))
+			}
+			for i, dl := range dls.DeadLetters {
+				if *dl != tc.expected[i] {
+					t.Errorf("expected %+v, got %+v", tc.expected[i], *dl)
+				}
+			}
+			DBCallTeardownHelper(t, mock)
+		})
+	}
+}
+
+func TestGetDeadLetter(t *testing.T) {
+	created := time.Date(2020, 7, 4, 9, 30, 0, 0, time.UTC)
+
+	tcs := []struct {
+		testName  string
+		setupFunc func(mock sqlmock.Sqlmock)
+		expected  *domain.DeadLetter
+	}{
+		{
+			testName: "testGetDeadLetterSuccess",
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id", "source", "sourceid", "attempts", "errmsg", "createdat"}).
+					AddRow(1, string(domain.DeadLetterUserCreate), 7, 3, mverr.DBUnavailableErrorMsg, created)
+				mock.ExpectQuery("SELECT id, source, sourceID, attempts, errMsg, createdAt FROM deadLetter WHERE id = \\?").
+					WithArgs(1).WillReturnRows(rows)
+			},
+			expected: &domain.DeadLetter{ID: 1, Source: domain.DeadLetterUserCreate, SourceID: 7, Attempts: 3, ErrMsg: mverr.DBUnavailableErrorMsg, Created: created},
+		},
+		{
+			testName: "testGetDeadLetterNoRow",
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT id, source, sourceID, attempts, errMsg, createdAt FROM deadLetter WHERE id = \\?").
+					WithArgs(1).WillReturnError(sql.ErrNoRows)
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+			}
+			defer dbase.Close()
+			tc.setupFunc(mock)
+
+			dt, err := db.NewDeadLetterTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating dead letter table instance: %s", err)
+			}
+
+			actual, err2 := dt.GetDeadLetter(1)
+			if err2 != nil {
+				t.Fatalf("error %s was not expected", err2)
+			}
+			if (actual == nil) != (tc.expected == nil) || (actual != nil && *actual != *tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, actual)
+			}
+			DBCallTeardownHelper(t, mock)
+		})
+	}
+}
+
+func TestDeleteDeadLetter(t *testing.T) {
+	dbase, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+	defer dbase.Close()
+	mock.ExpectExec("DELETE FROM deadLetter WHERE id = \\?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	dt, err := db.NewDeadLetterTable(dbase)
+	if err != nil {
+		t.Fatalf("error creating dead letter table instance: %s", err)
+	}
+	if err2 := dt.DeleteDeadLetter(1); err2 != nil {
+		t.Errorf("error %s was not expected", err2)
+	}
+	DBCallTeardownHelper(t, mock)
+}
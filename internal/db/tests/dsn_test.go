@@ -0,0 +1,208 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tests
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/db"
+)
+
+func TestBuildDSN(t *testing.T) {
+	ca, err := ioutil.ReadFile("testdata/ca.crt")
+	if err != nil {
+		t.Fatalf("error %s was not expected reading the CA certificate", err)
+	}
+
+	tcp := db.DSNOptions{User: "someuser", Password: "somepassword", Host: "mysql", Port: 3306, Name: "mockvideo"}
+	socket := db.DSNOptions{User: "someuser", Password: "somepassword", Socket: "/var/run/mysqld/mysqld.sock", Name: "mockvideo"}
+	with := func(opts db.DSNOptions, modify func(*db.DSNOptions)) db.DSNOptions {
+		modify(&opts)
+		return opts
+	}
+
+	tcs := []struct {
+		testName    string
+		opts        db.DSNOptions
+		expectedDSN string
+		expectFail  bool
+	}{
+		{
+			testName:    "testTCP",
+			opts:        tcp,
+			expectedDSN: "someuser:somepassword@tcp(mysql:3306)/mockvideo",
+		},
+		{
+			testName:    "testIPv6Host",
+			opts:        with(tcp, func(o *db.DSNOptions) { o.Host = "::1" }),
+			expectedDSN: "someuser:somepassword@tcp([::1]:3306)/mockvideo",
+		},
+		{
+			testName:    "testSocket",
+			opts:        socket,
+			expectedDSN: "someuser:somepassword@unix(/var/run/mysqld/mysqld.sock)/mockvideo",
+		},
+		{
+			testName:    "testNoPassword",
+			opts:        with(tcp, func(o *db.DSNOptions) { o.Password = "" }),
+			expectedDSN: "someuser@tcp(mysql:3306)/mockvideo",
+		},
+		{
+			testName:    "testParseTime",
+			opts:        with(tcp, func(o *db.DSNOptions) { o.ParseTime = true }),
+			expectedDSN: "someuser:somepassword@tcp(mysql:3306)/mockvideo?parseTime=true",
+		},
+		{
+			testName:    "testInterpolateParams",
+			opts:        with(tcp, func(o *db.DSNOptions) { o.InterpolateParams = true }),
+			expectedDSN: "someuser:somepassword@tcp(mysql:3306)/mockvideo?interpolateParams=true",
+		},
+		{
+			testName:    "testCharset",
+			opts:        with(tcp, func(o *db.DSNOptions) { o.Charset = "utf8mb4" }),
+			expectedDSN: "someuser:somepassword@tcp(mysql:3306)/mockvideo?charset=utf8mb4",
+		},
+		{
+			testName: "testTimeouts",
+			opts: with(tcp, func(o *db.DSNOptions) {
+				o.Timeout = 5 * time.Second
+				o.ReadTimeout = 30 * time.Second
+				o.WriteTimeout = 1500 * time.Millisecond
+			}),
+			expectedDSN: "someuser:somepassword@tcp(mysql:3306)/mockvideo?readTimeout=30s&timeout=5s&writeTimeout=1.5s",
+		},
+		{
+			testName:    "testTLSDisabled",
+			opts:        with(tcp, func(o *db.DSNOptions) { o.TLS = db.TLSDisabled }),
+			expectedDSN: "someuser:somepassword@tcp(mysql:3306)/mockvideo",
+		},
+		{
+			testName:    "testTLSVerify",
+			opts:        with(tcp, func(o *db.DSNOptions) { o.TLS = db.TLSVerify }),
+			expectedDSN: "someuser:somepassword@tcp(mysql:3306)/mockvideo?tls=true",
+		},
+		{
+			testName:    "testTLSSkipVerify",
+			opts:        with(tcp, func(o *db.DSNOptions) { o.TLS = db.TLSSkipVerify }),
+			expectedDSN: "someuser:somepassword@tcp(mysql:3306)/mockvideo?tls=skip-verify",
+		},
+		{
+			testName: "testTLSCustomCA",
+			opts: with(tcp, func(o *db.DSNOptions) {
+				o.TLS = db.TLSVerify
+				o.TLSCA = string(ca)
+			}),
+			expectedDSN: "someuser:somepassword@tcp(mysql:3306)/mockvideo?tls=mockvideo-custom-ca",
+		},
+		{
+			testName: "testAllOptions",
+			opts: with(tcp, func(o *db.DSNOptions) {
+				o.TLS = db.TLSSkipVerify
+				o.Charset = "utf8mb4"
+				o.Timeout = time.Second
+				o.ReadTimeout = time.Second
+				o.WriteTimeout = time.Second
+				o.ParseTime = true
+				o.InterpolateParams = true
+			}),
+			expectedDSN: "someuser:somepassword@tcp(mysql:3306)/mockvideo?interpolateParams=true&parseTime=true&readTimeout=1s&timeout=1s&tls=skip-verify&writeTimeout=1s&charset=utf8mb4",
+		},
+		{
+			testName: "testSocketAllOptions",
+			opts: with(socket, func(o *db.DSNOptions) {
+				o.Charset = "utf8mb4"
+				o.Timeout = time.Second
+				o.ParseTime = true
+				o.InterpolateParams = true
+			}),
+			expectedDSN: "someuser:somepassword@unix(/var/run/mysqld/mysqld.sock)/mockvideo?interpolateParams=true&parseTime=true&timeout=1s&charset=utf8mb4",
+		},
+		{
+			testName:   "testNoUser",
+			opts:       with(tcp, func(o *db.DSNOptions) { o.User = "" }),
+			expectFail: true,
+		},
+		{
+			testName:   "testNoName",
+			opts:       with(tcp, func(o *db.DSNOptions) { o.Name = "" }),
+			expectFail: true,
+		},
+		{
+			testName:   "testNoHostOrSocket",
+			opts:       with(tcp, func(o *db.DSNOptions) { o.Host = "" }),
+			expectFail: true,
+		},
+		{
+			testName:   "testNoPort",
+			opts:       with(tcp, func(o *db.DSNOptions) { o.Port = 0 }),
+			expectFail: true,
+		},
+		{
+			testName:   "testPortOutOfRange",
+			opts:       with(tcp, func(o *db.DSNOptions) { o.Port = 65536 }),
+			expectFail: true,
+		},
+		{
+			testName:   "testHostAndSocket",
+			opts:       with(socket, func(o *db.DSNOptions) { o.Host = "mysql" }),
+			expectFail: true,
+		},
+		{
+			testName:   "testPortAndSocket",
+			opts:       with(socket, func(o *db.DSNOptions) { o.Port = 3306 }),
+			expectFail: true,
+		},
+		{
+			testName:   "testInvalidTLSMode",
+			opts:       with(tcp, func(o *db.DSNOptions) { o.TLS = "preferred" }),
+			expectFail: true,
+		},
+		{
+			testName:   "testTLSWithSocket",
+			opts:       with(socket, func(o *db.DSNOptions) { o.TLS = db.TLSVerify }),
+			expectFail: true,
+		},
+		{
+			testName: "testCAWithSkipVerify",
+			opts: with(tcp, func(o *db.DSNOptions) {
+				o.TLS = db.TLSSkipVerify
+				o.TLSCA = string(ca)
+			}),
+			expectFail: true,
+		},
+		{
+			testName:   "testCAWithoutTLS",
+			opts:       with(tcp, func(o *db.DSNOptions) { o.TLSCA = string(ca) }),
+			expectFail: true,
+		},
+		{
+			testName: "testInvalidCA",
+			opts: with(tcp, func(o *db.DSNOptions) {
+				o.TLS = db.TLSVerify
+				o.TLSCA = "not a certificate"
+			}),
+			expectFail: true,
+		},
+		{
+			testName:   "testNegativeTimeout",
+			opts:       with(tcp, func(o *db.DSNOptions) { o.ReadTimeout = -time.Second }),
+			expectFail: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dsn, err := db.BuildDSN(tc.opts)
+			if (err != nil) != tc.expectFail {
+				t.Fatalf("expected failure %t, got %v", tc.expectFail, err)
+			}
+			if dsn != tc.expectedDSN {
+				t.Errorf("expected DSN %q, got %q", tc.expectedDSN, dsn)
+			}
+		})
+	}
+}
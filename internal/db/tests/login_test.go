@@ -0,0 +1,160 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tests
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/youngkin/mockvideo/internal/clock"
+	"github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/domain"
+)
+
+func TestRecordLogin(t *testing.T) {
+	now := time.Date(2020, time.July, 4, 9, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		testName   string
+		shouldPass bool
+		execErr    error
+	}{
+		{testName: "testRecordLoginSuccess", shouldPass: true},
+		{testName: "testRecordLoginDBError", shouldPass: false, execErr: sql.ErrConnDone},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+			}
+			defer dbase.Close()
+
+			// updatedAt isn't changed by a login
+			exec := mock.ExpectExec("UPDATE user SET lastLogin = \\? WHERE id = \\?").WithArgs(now, 1)
+			if tc.execErr != nil {
+				exec.WillReturnError(tc.execErr)
+			} else {
+				exec.WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			if err = ut.SetClock(clock.NewFrozen(now)); err != nil {
+				t.Fatalf("error setting the user table's clock: %s", err)
+			}
+
+			validateExpectedErrors(t, ut.RecordLogin(1), tc.shouldPass)
+			DBCallTeardownHelper(t, mock)
+		})
+	}
+}
+
+func TestGetInactiveUsers(t *testing.T) {
+	dbase, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+	defer dbase.Close()
+
+	since := time.Date(2020, time.April, 1, 0, 0, 0, 0, time.UTC)
+	lastLogin := time.Date(2020, time.March, 15, 8, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby"}).
+		AddRow(1, 1, "porgy tirebiter", "porgytirebiter@email.com", domain.Primary, domain.Active, createdAt, updatedAt, lastLogin, "user:1").
+		AddRow(1, 2, "mickey dolenz", "mdolenz@themonkeys.com", domain.Restricted, domain.Active, createdAt, updatedAt, nil, nil)
+	mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user WHERE status = \\? AND COALESCE\\(lastLogin, createdAt\\) < \\? ORDER BY id").
+		WithArgs(domain.Active, since).
+		WillReturnRows(rows)
+
+	ut, err := db.NewTable(dbase)
+	if err != nil {
+		t.Fatalf("error creating user table instance: %s", err)
+	}
+	users, err2 := ut.GetInactiveUsers(since)
+	if err2 != nil {
+		t.Fatalf("error %s was not expected", err2)
+	}
+	if len(users.Users) != 2 {
+		t.Fatalf("expected 2 users, got %+v", users.Users)
+	}
+	if u := users.Users[0]; u.LastLogin == nil || !u.LastLogin.Equal(lastLogin) || u.LastModifiedBy != "user:1" {
+		t.Errorf("expected LastLogin %s and LastModifiedBy user:1, got %v and %s", lastLogin, u.LastLogin, u.LastModifiedBy)
+	}
+	if u := users.Users[1]; u.LastLogin != nil || u.LastModifiedBy != "" {
+		t.Errorf("expected NULL LastLogin and LastModifiedBy to be unset, got %v and %s", u.LastLogin, u.LastModifiedBy)
+	}
+
+	DBCallTeardownHelper(t, mock)
+}
+
+func TestGetCredentials(t *testing.T) {
+	const hash = "$2a$10$vHIWCjrtpPywj6w8t2K1vO1e5j5fzVINgrn6OsBwjxTZFk6FXEvei"
+	columns := []string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby", "password"}
+
+	tests := []struct {
+		testName     string
+		rows         *sqlmock.Rows
+		queryErr     error
+		shouldPass   bool
+		expectedUser bool
+		expectedHash string
+	}{
+		{
+			testName:     "testGetCredentialsSuccess",
+			rows:         sqlmock.NewRows(columns).AddRow(1, 2, "mickey dolenz", "mickeyd@gmail.com", domain.Primary, domain.Active, createdAt, updatedAt, nil, nil, hash),
+			shouldPass:   true,
+			expectedUser: true,
+			expectedHash: hash,
+		},
+		{
+			testName:     "testGetCredentialsNoPassword",
+			rows:         sqlmock.NewRows(columns).AddRow(1, 2, "mickey dolenz", "mickeyd@gmail.com", domain.Primary, domain.Active, createdAt, updatedAt, nil, nil, nil),
+			shouldPass:   true,
+			expectedUser: true,
+		},
+		{testName: "testGetCredentialsNoUser", rows: sqlmock.NewRows(columns), shouldPass: true},
+		{testName: "testGetCredentialsDBError", queryErr: sql.ErrConnDone, shouldPass: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+			}
+			defer dbase.Close()
+
+			query := mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy, password FROM user WHERE email = \\?").
+				WithArgs("mickeyd@gmail.com")
+			if tc.queryErr != nil {
+				query.WillReturnError(tc.queryErr)
+			} else {
+				query.WillReturnRows(tc.rows)
+			}
+
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			u, h, mvErr := ut.GetCredentials("mickeyd@gmail.com")
+			validateExpectedErrors(t, mvErr, tc.shouldPass)
+			if (u != nil) != tc.expectedUser {
+				t.Errorf("expected a user %t, got %+v", tc.expectedUser, u)
+			}
+			if u != nil && (u.ID != 2 || u.Password != "") {
+				t.Errorf("expected user 2 without a password, got %+v", u)
+			}
+			if h != tc.expectedHash {
+				t.Errorf("expected hash %q, got %q", tc.expectedHash, h)
+			}
+			DBCallTeardownHelper(t, mock)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tests
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/domain"
+)
+
+func TestResultSetMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(db.DBRowsReturned, db.DBResultBytes)
+
+	tcs := []struct {
+		testName       string
+		shouldPass     bool
+		expectObserved bool
+		setupFunc      func(*testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.Users)
+	}{
+		{
+			testName:       "testObservedOnSuccess",
+			shouldPass:     true,
+			expectObserved: true,
+			setupFunc:      DBCallSetupHelper,
+		},
+		{
+			testName:  "testNotObservedOnQueryFailure",
+			setupFunc: DBCallQueryErrorSetupHelper,
+		},
+		{
+			testName:  "testNotObservedOnScanFailure",
+			setupFunc: DBCallRowScanErrorSetupHelper,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, expected := tc.setupFunc(t)
+			defer dbase.Close()
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+
+			rowsCount, rowsSum := histogram(t, reg, "database_db_rows_returned")
+			bytesCount, bytesSum := histogram(t, reg, "database_db_result_bytes")
+			_, err2 := ut.GetUsers()
+			validateExpectedErrors(t, err2, tc.shouldPass)
+			DBCallTeardownHelper(t, mock)
+			newRowsCount, newRowsSum := histogram(t, reg, "database_db_rows_returned")
+			newBytesCount, newBytesSum := histogram(t, reg, "database_db_result_bytes")
+
+			if !tc.expectObserved {
+				if newRowsCount != rowsCount || newBytesCount != bytesCount {
+					t.Errorf("expected no observations, got %d rows and %d bytes observations", newRowsCount-rowsCount, newBytesCount-bytesCount)
+				}
+				return
+			}
+			if newRowsCount != rowsCount+1 || newBytesCount != bytesCount+1 {
+				t.Fatalf("expected 1 observation, got %d rows and %d bytes observations", newRowsCount-rowsCount, newBytesCount-bytesCount)
+			}
+			if int(newRowsSum-rowsSum) != len(expected.Users) {
+				t.Errorf("expected %d rows, got %v", len(expected.Users), newRowsSum-rowsSum)
+			}
+			minBytes := 0
+			for _, u := range expected.Users {
+				minBytes += len(u.Name) + len(u.EMail)
+			}
+			if int(newBytesSum-bytesSum) <= minBytes {
+				t.Errorf("expected more than %d bytes, got %v", minBytes, newBytesSum-bytesSum)
+			}
+		})
+	}
+}
+
+// histogram returns the sample count and sum of the 'userTbl' 'readAll' observations of the
+// histogram named 'name' in 'reg'
+func histogram(t *testing.T, reg *prometheus.Registry, name string) (uint64, float64) {
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("error %s was not expected gathering metrics", err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["target"] == "userTbl" && labels["operation"] == "readAll" {
+				return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+			}
+		}
+	}
+	return 0, 0
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/youngkin/mockvideo/internal/db"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/httpclient"
+)
+
+func TestReadOnlyRepository(t *testing.T) {
+	readOnlyErr := &mysql.MySQLError{Number: mverr.MySQLOptionPreventsStatementErrorCode, Message: "The MySQL server is running with the --read-only option"}
+
+	tcs := []struct {
+		testName string
+		// writeErrs are the errors returned by successive DB writes, the first len(writeErrs)
+		// writes are made
+		writeErrs        []error
+		expectedReadOnly bool
+	}{
+		{
+			testName:         "testEntersOnWriteFailures",
+			writeErrs:        []error{fmt.Errorf("some error"), fmt.Errorf("some error")},
+			expectedReadOnly: true,
+		},
+		{
+			testName:         "testEntersOnReadOnlyDB",
+			writeErrs:        []error{readOnlyErr},
+			expectedReadOnly: true,
+		},
+		{
+			testName:         "testStaysReadWriteBelowThreshold",
+			writeErrs:        []error{fmt.Errorf("some error"), nil, fmt.Errorf("some error")},
+			expectedReadOnly: false,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+			}
+			defer dbase.Close()
+			for _, writeErr := range tc.writeErrs {
+				exec := mock.ExpectExec("DELETE FROM user WHERE id = \\?").WithArgs(1)
+				if writeErr != nil {
+					exec.WillReturnError(writeErr)
+				} else {
+					exec.WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+			}
+			// Reads are served in read-only mode
+			mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user WHERE id = \\?").
+				WithArgs(1).
+				WillReturnRows(sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby"}))
+
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			breaker, err := httpclient.NewBreaker(2, time.Minute)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting a Breaker", err)
+			}
+			ro, err := db.NewReadOnlyRepository(ut, breaker)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting a ReadOnlyRepository", err)
+			}
+
+			for range tc.writeErrs {
+				ro.DeleteUser(1)
+			}
+			if ro.ReadOnly() != tc.expectedReadOnly {
+				t.Fatalf("expected ReadOnly() = %t, got %t", tc.expectedReadOnly, ro.ReadOnly())
+			}
+
+			if tc.expectedReadOnly {
+				_, mvErr := ro.DeleteUser(1)
+				if mvErr == nil || mvErr.ErrCode != mverr.ReadOnlyModeErrorCode {
+					t.Fatalf("expected error code %d, got %v", mverr.ReadOnlyModeErrorCode, mvErr)
+				}
+				if mvErr.RetryAfter <= 0 || mvErr.RetryAfter > time.Minute {
+					t.Errorf("expected a RetryAfter of up to %s, got %s", time.Minute, mvErr.RetryAfter)
+				}
+			}
+			if _, mvErr := ro.GetUser(1); mvErr != nil {
+				t.Errorf("error %s was not expected reading a user", mvErr)
+			}
+			// A write rejected in read-only mode doesn't make a DB request
+			DBCallTeardownHelper(t, mock)
+		})
+	}
+}
+
+func TestReadOnlyRepositoryRecovers(t *testing.T) {
+	dbase, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+	defer dbase.Close()
+	mock.ExpectExec("DELETE FROM user WHERE id = \\?").WithArgs(1).
+		WillReturnError(&mysql.MySQLError{Number: mverr.MySQLReadOnlyModeErrorCode, Message: "read only"})
+	mock.ExpectExec("DELETE FROM user WHERE id = \\?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ut, err := db.NewTable(dbase)
+	if err != nil {
+		t.Fatalf("error creating user table instance: %s", err)
+	}
+	breaker, err := httpclient.NewBreaker(2, time.Millisecond)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting a Breaker", err)
+	}
+	ro, err := db.NewReadOnlyRepository(ut, breaker)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting a ReadOnlyRepository", err)
+	}
+
+	ro.DeleteUser(1)
+	if !ro.ReadOnly() {
+		t.Fatalf("expected read-only mode after the DB rejected a write")
+	}
+	// The trial write made once the breaker's cooldown has passed succeeds
+	time.Sleep(5 * time.Millisecond)
+	if _, mvErr := ro.DeleteUser(1); mvErr != nil {
+		t.Fatalf("error %s was not expected from the trial write", mvErr)
+	}
+	if ro.ReadOnly() {
+		t.Errorf("expected read-only mode to be exited after a successful write")
+	}
+	DBCallTeardownHelper(t, mock)
+}
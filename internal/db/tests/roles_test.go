@@ -0,0 +1,93 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tests
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+func TestUpdateRoles(t *testing.T) {
+	tests := []struct {
+		testName        string
+		shouldPass      bool
+		roles           map[int]domain.Role
+		expectedErrCode mverr.ErrCode
+		setupFunc       func(*testing.T) (*sql.DB, sqlmock.Sqlmock)
+		teardownFunc    func(*testing.T, sqlmock.Sqlmock)
+	}{
+		{
+			testName:     "testUpdateRolesChangePrimary",
+			shouldPass:   true,
+			roles:        map[int]domain.Role{1: domain.Unrestricted, 2: domain.Primary},
+			setupFunc:    DBUpdateRolesSetupHelper,
+			teardownFunc: DBCallTeardownHelper,
+		},
+		{
+			testName:        "testUpdateRolesNoPrimary",
+			shouldPass:      false,
+			roles:           map[int]domain.Role{1: domain.Unrestricted},
+			expectedErrCode: mverr.InvalidRoleAssignmentErrorCode,
+			setupFunc:       DBUpdateRolesRejectedSetupHelper,
+			teardownFunc:    DBCallTeardownHelper,
+		},
+		{
+			testName:        "testUpdateRolesTwoPrimaries",
+			shouldPass:      false,
+			roles:           map[int]domain.Role{3: domain.Primary},
+			expectedErrCode: mverr.InvalidRoleAssignmentErrorCode,
+			setupFunc:       DBUpdateRolesRejectedSetupHelper,
+			teardownFunc:    DBCallTeardownHelper,
+		},
+		{
+			testName:        "testUpdateRolesUserNotInAccount",
+			shouldPass:      false,
+			roles:           map[int]domain.Role{1: domain.Unrestricted, 4: domain.Primary},
+			expectedErrCode: mverr.DBNoUserErrorCode,
+			setupFunc:       DBUpdateRolesRejectedSetupHelper,
+			teardownFunc:    DBCallTeardownHelper,
+		},
+		{
+			testName:        "testUpdateRolesInvalidRole",
+			shouldPass:      false,
+			roles:           map[int]domain.Role{2: domain.Role(7)},
+			expectedErrCode: mverr.UserValidationErrorCode,
+			setupFunc:       DBUpdateRolesNoCallSetupHelper,
+			teardownFunc:    DBCallTeardownHelper,
+		},
+		{
+			testName:        "testUpdateRolesDBError",
+			shouldPass:      false,
+			roles:           map[int]domain.Role{1: domain.Unrestricted, 2: domain.Primary},
+			expectedErrCode: mverr.DBUpSertErrorCode,
+			setupFunc:       DBUpdateRolesErrorSetupHelper,
+			teardownFunc:    DBCallTeardownHelper,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock := tc.setupFunc(t)
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			defer dbase.Close()
+
+			err2 := ut.UpdateRoles(1, tc.roles)
+			validateExpectedErrors(t, err2, tc.shouldPass)
+			if err2 != nil && err2.ErrCode != tc.expectedErrCode {
+				t.Errorf("expected error code %d, got %d", tc.expectedErrCode, err2.ErrCode)
+			}
+
+			tc.teardownFunc(t, mock)
+		})
+	}
+}
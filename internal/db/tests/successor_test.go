@@ -0,0 +1,73 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tests
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/youngkin/mockvideo/internal/db"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+func TestDeleteUserWithSuccessor(t *testing.T) {
+	tests := []struct {
+		testName        string
+		shouldPass      bool
+		expectDeleted   bool
+		expectedErrCode mverr.ErrCode
+		setupFunc       func(*testing.T) (*sql.DB, sqlmock.Sqlmock)
+	}{
+		{
+			testName:      "testDeleteWithSuccessor",
+			shouldPass:    true,
+			expectDeleted: true,
+			setupFunc:     DBDeleteWithSuccessorSetupHelper,
+		},
+		{
+			testName:   "testDeleteWithSuccessorNoUser",
+			shouldPass: true,
+			setupFunc:  DBDeleteWithSuccessorNoUserSetupHelper,
+		},
+		{
+			testName:        "testDeleteWithSuccessorNotPrimary",
+			expectedErrCode: mverr.InvalidSuccessorErrorCode,
+			setupFunc:       DBDeleteWithSuccessorNotPrimarySetupHelper,
+		},
+		{
+			testName:        "testDeleteWithSuccessorOtherAccount",
+			expectedErrCode: mverr.InvalidSuccessorErrorCode,
+			setupFunc:       DBDeleteWithSuccessorOtherAccountSetupHelper,
+		},
+		{
+			testName:        "testDeleteWithSuccessorDBError",
+			expectedErrCode: mverr.DBDeleteErrorCode,
+			setupFunc:       DBDeleteWithSuccessorErrorSetupHelper,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock := tc.setupFunc(t)
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			defer dbase.Close()
+
+			deleted, err2 := ut.DeleteUserWithSuccessor(1, 2)
+			validateExpectedErrors(t, err2, tc.shouldPass)
+			if deleted != tc.expectDeleted {
+				t.Errorf("expected deleted to be %t, got %t", tc.expectDeleted, deleted)
+			}
+			if err2 != nil && err2.ErrCode != tc.expectedErrCode {
+				t.Errorf("expected error code %d, got %d", tc.expectedErrCode, err2.ErrCode)
+			}
+
+			DBCallTeardownHelper(t, mock)
+		})
+	}
+}
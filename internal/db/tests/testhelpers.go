@@ -6,14 +6,33 @@ package tests
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/youngkin/mockvideo/internal/auth"
 	"github.com/youngkin/mockvideo/internal/domain"
 	mverr "github.com/youngkin/mockvideo/internal/errors"
 )
 
+// createdAt and updatedAt are the timestamps of the users in the mock database
+var (
+	createdAt = time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+	updatedAt = time.Date(2020, time.July, 4, 9, 30, 0, 0, time.UTC)
+)
+
+// passwordArg is a sqlmock.Argument matching a password, or its hash when it's been hashed by the
+// services layer, see auth.HashPassword
+type passwordArg string
+
+// Match returns true if 'v' is the password or its hash
+func (p passwordArg) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	return ok && (s == string(p) || auth.IsPasswordHash(s) && auth.CheckPassword(s, string(p)))
+}
+
 // DBCallSetupHelper encapsulates common code needed to setup mock DB access to user data
 func DBCallSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.Users) {
 	db, mock, err := sqlmock.New()
@@ -21,11 +40,11 @@ func DBCallSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.Users) {
 		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
 	}
 
-	rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role"}).
-		AddRow(0, 1, "porgy tirebiter", "porgytirebiter@email.com", domain.Primary).
-		AddRow(0, 2, "mickey dolenz", "mdolenz@themonkeys.com", domain.Restricted)
+	rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby"}).
+		AddRow(0, 1, "porgy tirebiter", "porgytirebiter@email.com", domain.Primary, domain.Active, createdAt, updatedAt, nil, nil).
+		AddRow(0, 2, "mickey dolenz", "mdolenz@themonkeys.com", domain.Restricted, domain.Active, createdAt, updatedAt, nil, nil)
 
-	mock.ExpectQuery("SELECT accountID, id, name, email, role FROM user").
+	mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user").
 		WillReturnRows(rows)
 
 	expected := domain.Users{
@@ -36,6 +55,9 @@ func DBCallSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.Users) {
 				Name:      "porgy tirebiter",
 				EMail:     "porgytirebiter@email.com",
 				Role:      domain.Primary,
+				Status:    domain.Active,
+				CreatedAt: createdAt,
+				UpdatedAt: updatedAt,
 			},
 			{
 				AccountID: 0,
@@ -43,6 +65,9 @@ func DBCallSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.Users) {
 				Name:      "mickey dolenz",
 				EMail:     "mdolenz@themonkeys.com",
 				Role:      domain.Restricted,
+				Status:    domain.Active,
+				CreatedAt: createdAt,
+				UpdatedAt: updatedAt,
 			},
 		},
 	}
@@ -57,12 +82,34 @@ func DBDeleteSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sqlmock)
 		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
 	}
 
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM user u JOIN user o").WithArgs(u.ID, domain.Primary).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM accountUser").WithArgs(u.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	mock.ExpectExec("DELETE FROM user WHERE id = ?").WithArgs(u.ID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	return db, mock
 }
 
+// DBDeleteNonExistingRowSetupHelper encapsulates the common code needed to setup a mock delete of a
+// User that doesn't exist
+func DBDeleteNonExistingRowSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM user u JOIN user o").WithArgs(u.ID, domain.Primary).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM accountUser").WithArgs(u.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("DELETE FROM user WHERE id = ?").WithArgs(u.ID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	return db, mock
+}
+
 // DBDeleteErrorSetupHelper encapsulates the common code needed to mock a user delete error
 func DBDeleteErrorSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sqlmock) {
 	db, mock, err := sqlmock.New()
@@ -70,11 +117,46 @@ func DBDeleteErrorSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sql
 		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
 	}
 
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM user u JOIN user o").WithArgs(u.ID, domain.Primary).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM accountUser").WithArgs(u.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 	mock.ExpectExec("DELETE FROM user WHERE id = ?").WithArgs(u.ID).WillReturnError(sql.ErrConnDone)
 
 	return db, mock
 }
 
+// DBDeleteBlockedSetupHelper encapsulates the common code needed to mock a user delete that is
+// rejected because the user is the primary user of an account with other users
+func DBDeleteBlockedSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM user u JOIN user o").WithArgs(u.ID, domain.Primary).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM accountUser").WithArgs(u.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	return db, mock
+}
+
+// DBDeleteUnauthorizedSetupHelper encapsulates the common code needed to mock a user delete
+// that is rejected after the user is looked up for authorization
+func DBDeleteUnauthorizedSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby"}).
+		AddRow(u.AccountID, u.ID, u.Name, u.EMail, u.Role, domain.Active, createdAt, updatedAt, nil, nil)
+	mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user WHERE id = ?").WithArgs(u.ID).WillReturnRows(rows)
+
+	return db, mock
+}
+
 // DBInsertSetupHelper encapsulates the common code needed to setup a mock User insert
 func DBInsertSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sqlmock) {
 	db, mock, err := sqlmock.New()
@@ -83,12 +165,28 @@ func DBInsertSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sqlmock)
 	}
 
 	// TODO: Swap these statements
-	mock.ExpectExec("INSERT INTO user").WithArgs(u.AccountID, u.Name, u.EMail, u.Role, u.Password).
+	mock.ExpectExec("INSERT INTO user").WithArgs(u.AccountID, u.Name, u.EMail, u.Role, passwordArg(u.Password),
+		sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	return db, mock
 }
 
+// DBInsertWithIDSetupHelper encapsulates the common code needed to mock a user insert whose ID, 42, is
+// assigned by an idgen.Generator
+func DBInsertWithIDSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectExec("INSERT INTO user \\(id,").WithArgs(42, u.AccountID, u.Name, u.EMail, u.Role, passwordArg(u.Password),
+		sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	return db, mock
+}
+
 // DBInsertErrorSetupHelper encapsulates the common code needed to mock a user insert error
 func DBInsertErrorSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sqlmock) {
 	db, mock, err := sqlmock.New()
@@ -96,7 +194,8 @@ func DBInsertErrorSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sql
 		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
 	}
 
-	mock.ExpectExec("INSERT INTO user").WithArgs(u.AccountID, u.Name, u.EMail, u.Role, u.Password).
+	mock.ExpectExec("INSERT INTO user").WithArgs(u.AccountID, u.Name, u.EMail, u.Role, passwordArg(u.Password),
+		sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnError(fmt.Errorf("some error"))
 
 	return db, mock
@@ -120,7 +219,7 @@ func DBUpdateNonExistingRowSetupHelper(t *testing.T, u domain.User) (*sql.DB, sq
 	}
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("SELECT accountID, id, name, email, role FROM user WHERE id = ?").WithArgs(u.ID).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user WHERE id = (.+) FOR UPDATE").WithArgs(u.ID).WillReturnError(sql.ErrNoRows)
 
 	return db, mock
 }
@@ -133,7 +232,7 @@ func DBUpdateErrorSelectSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmo
 	}
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("SELECT accountID, id, name, email, role FROM user WHERE id = ?").WithArgs(u.ID).
+	mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user WHERE id = (.+) FOR UPDATE").WithArgs(u.ID).
 		WillReturnError(sql.ErrConnDone)
 
 	return db, mock
@@ -146,12 +245,12 @@ func DBUpdateSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sqlmock)
 		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
 	}
 
-	rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role"}).
-		AddRow(1, 2, "mickey dolenz", "mickeyd@gmail.com", domain.Unrestricted)
+	rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby"}).
+		AddRow(1, 2, "mickey dolenz", "mickeyd@gmail.com", domain.Unrestricted, domain.Active, createdAt, updatedAt, nil, nil)
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("SELECT accountID, id, name, email, role FROM user WHERE id = ?").WithArgs(u.ID).WillReturnRows(rows)
-	mock.ExpectExec("UPDATE user SET (.+) WHERE (.+)").WithArgs(u.ID, u.AccountID, u.Name, u.EMail, u.Role, u.Password, u.ID).
+	mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user WHERE id = (.+) FOR UPDATE").WithArgs(u.ID).WillReturnRows(rows)
+	mock.ExpectExec("UPDATE user SET (.+) WHERE (.+)").WithArgs(u.ID, u.AccountID, u.Name, u.EMail, u.Role, passwordArg(u.Password), sqlmock.AnyArg(), sqlmock.AnyArg(), u.ID).
 		WillReturnResult(sqlmock.NewResult(0, 1)) // no insert ID, 1 row affected
 	mock.ExpectCommit()
 	return db, mock
@@ -164,13 +263,13 @@ func DBUpdateErrorSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sql
 		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
 	}
 
-	rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role"}).
-		AddRow(1, 100, "Mickey Mouse", "MickeyMoused@disney.com", domain.Unrestricted)
+	rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby"}).
+		AddRow(1, 100, "Mickey Mouse", "MickeyMoused@disney.com", domain.Unrestricted, domain.Active, createdAt, updatedAt, nil, nil)
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("SELECT accountID, id, name, email, role FROM user WHERE id = ?").WithArgs(u.ID).
+	mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user WHERE id = (.+) FOR UPDATE").WithArgs(u.ID).
 		WillReturnRows(rows)
-	mock.ExpectExec("UPDATE user SET (.+) WHERE (.+)").WithArgs(u.ID, u.AccountID, u.Name, u.EMail, u.Role, u.Password, u.ID).WillReturnError(sql.ErrConnDone)
+	mock.ExpectExec("UPDATE user SET (.+) WHERE (.+)").WithArgs(u.ID, u.AccountID, u.Name, u.EMail, u.Role, passwordArg(u.Password), sqlmock.AnyArg(), sqlmock.AnyArg(), u.ID).WillReturnError(sql.ErrConnDone)
 	mock.ExpectRollback()
 	return db, mock
 }
@@ -182,12 +281,30 @@ func DBCallQueryErrorSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domai
 		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
 	}
 
-	mock.ExpectQuery("SELECT accountID, id, name, email, role FROM user").
+	mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user").
 		WillReturnError(fmt.Errorf("some error"))
 
 	return db, mock, nil
 }
 
+// DBCallSlowQuerySetupHelper encapsulates common code needed to mock a users query that takes a
+// second to return its result set
+func DBCallSlowQuerySetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.Users) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby"}).
+		AddRow(0, 1, "porgy tirebiter", "porgytirebiter@email.com", domain.Primary, domain.Active, createdAt, updatedAt, nil, nil)
+
+	mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user").
+		WillDelayFor(time.Second).
+		WillReturnRows(rows)
+
+	return db, mock, &domain.Users{}
+}
+
 // DBCallRowScanErrorSetupHelper encapsulates common coded needed to mock DB query failures
 func DBCallRowScanErrorSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.Users) {
 	db, mock, err := sqlmock.New()
@@ -198,9 +315,98 @@ func DBCallRowScanErrorSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *dom
 	rows := sqlmock.NewRows([]string{"badRow"}).
 		AddRow(-1)
 
-	mock.ExpectQuery("SELECT accountID, id, name, email, role FROM user").
+	mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user").
+		WillReturnRows(rows)
+
+	return db, mock, nil
+}
+
+// DBUsersPageSetupHelper encapsulates common code needed to setup mock DB access to the page of
+// users after user 1
+func DBUsersPageSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.Users) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby"}).
+		AddRow(0, 2, "mickey dolenz", "mdolenz@themonkeys.com", domain.Restricted, domain.Active, createdAt, updatedAt, nil, nil)
+	mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user WHERE status = \\? AND id > \\? ORDER BY id LIMIT \\?").
+		WithArgs(domain.Active, 1, 2).
+		WillReturnRows(rows)
+
+	expected := domain.Users{
+		Users: []*domain.User{
+			{
+				AccountID: 0,
+				ID:        2,
+				Name:      "mickey dolenz",
+				EMail:     "mdolenz@themonkeys.com",
+				Role:      domain.Restricted,
+				Status:    domain.Active,
+				CreatedAt: createdAt,
+				UpdatedAt: updatedAt,
+			},
+		},
+	}
+
+	return db, mock, &expected
+}
+
+// DBUsersPageErrorSetupHelper encapsulates common code needed to mock users page query failures
+func DBUsersPageErrorSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.Users) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user WHERE status = \\? AND id > \\?").
+		WithArgs(domain.Active, 1, 2).
+		WillReturnError(fmt.Errorf("some error"))
+
+	return db, mock, nil
+}
+
+// DBUsersVersionSetupHelper encapsulates common code needed to setup mock DB access to the version of
+// the users returned by DBCallSetupHelper
+func DBUsersVersionSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.UsersVersion) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	rows := sqlmock.NewRows([]string{"max(updatedat)", "count(*)"}).AddRow(updatedAt, 2)
+	mock.ExpectQuery("SELECT MAX\\(updatedAt\\), COUNT\\(\\*\\) FROM user").WithArgs(domain.Active).
+		WillReturnRows(rows)
+
+	return db, mock, &domain.UsersVersion{LastModified: updatedAt, Count: 2}
+}
+
+// DBUsersVersionEmptySetupHelper encapsulates common code needed to setup mock DB access to the version
+// of an empty set of users
+func DBUsersVersionEmptySetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.UsersVersion) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	rows := sqlmock.NewRows([]string{"max(updatedat)", "count(*)"}).AddRow(nil, 0)
+	mock.ExpectQuery("SELECT MAX\\(updatedAt\\), COUNT\\(\\*\\) FROM user").WithArgs(domain.Active).
 		WillReturnRows(rows)
 
+	return db, mock, &domain.UsersVersion{}
+}
+
+// DBUsersVersionErrorSetupHelper encapsulates common code needed to mock users version query failures
+func DBUsersVersionErrorSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.UsersVersion) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectQuery("SELECT MAX\\(updatedAt\\), COUNT\\(\\*\\) FROM user").WithArgs(domain.Active).
+		WillReturnError(fmt.Errorf("some error"))
+
 	return db, mock, nil
 }
 
@@ -219,10 +425,10 @@ func GetUserSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.User) {
 		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
 	}
 
-	rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role"}).
-		AddRow(5, 1, "porgy tirebiter", "porgytirebiter@email.com", domain.Primary)
+	rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby"}).
+		AddRow(5, 1, "porgy tirebiter", "porgytirebiter@email.com", domain.Primary, domain.Active, createdAt, updatedAt, nil, nil)
 
-	mock.ExpectQuery("SELECT accountID, id, name, email, role FROM user").
+	mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user").
 		WithArgs(1).WillReturnRows(rows)
 
 	expected := domain.User{
@@ -231,6 +437,9 @@ func GetUserSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.User) {
 		Name:      "porgy tirebiter",
 		EMail:     "porgytirebiter@email.com",
 		Role:      domain.Primary,
+		Status:    domain.Active,
+		CreatedAt: createdAt,
+		UpdatedAt: updatedAt,
 	}
 
 	return db, mock, &expected
@@ -243,7 +452,7 @@ func DBUserErrNoRowsSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain
 		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
 	}
 
-	mock.ExpectQuery("SELECT accountID, id, name, email, role FROM user").
+	mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user").
 		WithArgs(1).WillReturnError(sql.ErrNoRows)
 
 	return db, mock, nil
@@ -256,7 +465,7 @@ func DBUserOtherErrSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.
 		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
 	}
 
-	mock.ExpectQuery("SELECT accountID, id, name, email, role FROM user").
+	mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user").
 		WithArgs(1).WillReturnError(sql.ErrConnDone)
 
 	return db, mock, nil
@@ -280,3 +489,443 @@ func validateExpectedErrors(t *testing.T, err *mverr.MVError, shouldPass bool) {
 		t.Fatalf("expected error didn't occur")
 	}
 }
+
+// DBEnqueueSetupHelper encapsulates the common code needed to setup a mock queued (write-behind) User insert
+func DBEnqueueSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectExec("INSERT INTO userCreateQueue").
+		WithArgs(u.AccountID, u.Name, u.EMail, u.Role, passwordArg(u.Password), string(domain.QueuePending)).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+
+	return db, mock
+}
+
+// DBEnqueueErrorSetupHelper encapsulates the common code needed to mock a queued user insert error
+func DBEnqueueErrorSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectExec("INSERT INTO userCreateQueue").
+		WithArgs(u.AccountID, u.Name, u.EMail, u.Role, passwordArg(u.Password), string(domain.QueuePending)).
+		WillReturnError(fmt.Errorf("some error"))
+
+	return db, mock
+}
+
+// DBClaimQueuedUserSetupHelper encapsulates the common code needed to mock claiming a pending queued user
+func DBClaimQueuedUserSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.QueuedUser) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "accountid", "name", "email", "role", "password", "attempts"}).
+		AddRow(7, 1, "porgy tirebiter", "porgytirebiter@email.com", domain.Primary, "vanilla", 1)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, accountID, name, email, role, password, attempts FROM userCreateQueue").
+		WithArgs(string(domain.QueuePending)).WillReturnRows(rows)
+	mock.ExpectExec("UPDATE userCreateQueue SET status").
+		WithArgs(string(domain.QueueProcessing), 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	expected := domain.QueuedUser{
+		ID:       7,
+		Status:   domain.QueueProcessing,
+		Attempts: 1,
+		User: domain.User{
+			AccountID: 1,
+			Name:      "porgy tirebiter",
+			EMail:     "porgytirebiter@email.com",
+			Role:      domain.Primary,
+			Password:  "vanilla",
+		},
+		PasswordHashed: true,
+	}
+
+	return db, mock, &expected
+}
+
+// DBClaimEmptyQueueSetupHelper encapsulates the common code needed to mock claiming from an empty queue
+func DBClaimEmptyQueueSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.QueuedUser) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, accountID, name, email, role, password, attempts FROM userCreateQueue").
+		WithArgs(string(domain.QueuePending)).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	return db, mock, nil
+}
+
+// DBClaimQueuedUserErrorSetupHelper encapsulates the common code needed to mock an error claiming a queued user
+func DBClaimQueuedUserErrorSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.QueuedUser) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "accountid", "name", "email", "role", "password", "attempts"}).
+		AddRow(7, 1, "porgy tirebiter", "porgytirebiter@email.com", domain.Primary, "vanilla", 0)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, accountID, name, email, role, password, attempts FROM userCreateQueue").
+		WithArgs(string(domain.QueuePending)).WillReturnRows(rows)
+	mock.ExpectExec("UPDATE userCreateQueue SET status").
+		WithArgs(string(domain.QueueProcessing), 7).WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	return db, mock, nil
+}
+
+// DBGetQueuedUserSetupHelper encapsulates the common code needed to mock retrieving a completed queued user
+func DBGetQueuedUserSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.QueuedUser) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "accountid", "name", "email", "role", "status", "attempts", "userid", "errmsg"}).
+		AddRow(7, 1, "porgy tirebiter", "porgytirebiter@email.com", domain.Primary, string(domain.QueueComplete), 0, 42, nil)
+
+	mock.ExpectQuery("SELECT id, accountID, name, email, role, status, attempts, userID, errMsg FROM userCreateQueue").
+		WithArgs(7).WillReturnRows(rows)
+
+	expected := domain.QueuedUser{
+		ID:     7,
+		Status: domain.QueueComplete,
+		UserID: 42,
+		User: domain.User{
+			AccountID: 1,
+			Name:      "porgy tirebiter",
+			EMail:     "porgytirebiter@email.com",
+			Role:      domain.Primary,
+		},
+	}
+
+	return db, mock, &expected
+}
+
+// DBGetQueuedUserErrNoRowsSetupHelper encapsulates the common code needed to mock a queued user that isn't found
+func DBGetQueuedUserErrNoRowsSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.QueuedUser) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectQuery("SELECT id, accountID, name, email, role, status, attempts, userID, errMsg FROM userCreateQueue").
+		WithArgs(7).WillReturnError(sql.ErrNoRows)
+
+	return db, mock, nil
+}
+
+// DBQueueNoCallSetupHelper encapsulates the common code needed to mock an error upstream from a queue DB call
+func DBQueueNoCallSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.QueuedUser) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	return db, mock, nil
+}
+
+// DBActivateUserSetupHelper encapsulates the common code needed to mock activating user 1 with the token 'goodtoken'
+func DBActivateUserSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectExec("UPDATE user SET status").
+		WithArgs(string(domain.Active), sqlmock.AnyArg(), 1, string(domain.Pending), "goodtoken", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	return db, mock
+}
+
+// DBActivateUserRecordLoginSetupHelper is DBActivateUserSetupHelper followed by recording the activated
+// user's login, as services.UserSvc.ActivateUser does
+func DBActivateUserRecordLoginSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock := DBActivateUserSetupHelper(t)
+	mock.ExpectExec("UPDATE user SET lastLogin").
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	return db, mock
+}
+
+// DBActivateUserNoMatchSetupHelper encapsulates the common code needed to mock an activation that doesn't
+// match a pending user, e.g., because the token is wrong or expired
+func DBActivateUserNoMatchSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectExec("UPDATE user SET status").
+		WithArgs(string(domain.Active), sqlmock.AnyArg(), 1, string(domain.Pending), "badtoken", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	return db, mock
+}
+
+// DBActivateUserErrorSetupHelper encapsulates the common code needed to mock a DB error during activation
+func DBActivateUserErrorSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectExec("UPDATE user SET status").
+		WithArgs(string(domain.Active), sqlmock.AnyArg(), 1, string(domain.Pending), "goodtoken", sqlmock.AnyArg()).
+		WillReturnError(sql.ErrConnDone)
+
+	return db, mock
+}
+
+// DBActivateUserNoCallSetupHelper encapsulates the common code needed to mock an activation request that
+// fails before the DB is called
+func DBActivateUserNoCallSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	return db, mock
+}
+
+// accountRolesRows returns the users in account 1 as they would be returned when locking the account's
+// users for a role update. User 1 is the primary user, users 2 and 3 are unrestricted and restricted.
+func accountRolesRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "role"}).
+		AddRow(1, domain.Primary).
+		AddRow(2, domain.Unrestricted).
+		AddRow(3, domain.Restricted)
+}
+
+// DBUpdateRolesSetupHelper encapsulates the common code needed to mock making user 2 the primary
+// user of account 1 in place of user 1
+func DBUpdateRolesSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, role FROM user WHERE accountID = (.+) FOR UPDATE").WithArgs(1).WillReturnRows(accountRolesRows())
+	mock.ExpectExec("UPDATE user SET role = (.+) WHERE id = (.+)").WithArgs(domain.Unrestricted, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE user SET role = (.+) WHERE id = (.+)").WithArgs(domain.Primary, sqlmock.AnyArg(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	return db, mock
+}
+
+// DBUpdateRolesRejectedSetupHelper encapsulates the common code needed to mock a role update that is
+// rejected, and rolled back, after the account's users are read
+func DBUpdateRolesRejectedSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, role FROM user WHERE accountID = (.+) FOR UPDATE").WithArgs(1).WillReturnRows(accountRolesRows())
+	mock.ExpectRollback()
+
+	return db, mock
+}
+
+// DBUpdateRolesErrorSetupHelper encapsulates the common code needed to mock a DB error, and the resulting
+// rollback, while updating roles
+func DBUpdateRolesErrorSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, role FROM user WHERE accountID = (.+) FOR UPDATE").WithArgs(1).WillReturnRows(accountRolesRows())
+	mock.ExpectExec("UPDATE user SET role = (.+) WHERE id = (.+)").WithArgs(domain.Unrestricted, sqlmock.AnyArg(), 1).
+		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	return db, mock
+}
+
+// DBUpdateRolesNoCallSetupHelper encapsulates the common code needed to mock a role update that
+// fails before the DB is called
+func DBUpdateRolesNoCallSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	return db, mock
+}
+
+// dbUpsertSetupHelper mocks a user upsert. 'rows' is the result of locking the user's email and 'result'
+// is the result of the upsert.
+func dbUpsertSetupHelper(t *testing.T, u domain.User, rows *sqlmock.Rows, result driver.Result) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, accountID FROM user WHERE email = (.+) FOR UPDATE").WithArgs(u.EMail).WillReturnRows(rows)
+	mock.ExpectExec("INSERT INTO user (.+) ON DUPLICATE KEY UPDATE").WithArgs(u.AccountID, u.Name, u.EMail, u.Role, passwordArg(u.Password),
+		sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(result)
+	mock.ExpectCommit()
+
+	return db, mock
+}
+
+// DBUpsertCreateSetupHelper mocks an upsert that creates a new user
+func DBUpsertCreateSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sqlmock) {
+	return dbUpsertSetupHelper(t, u, sqlmock.NewRows([]string{"id", "accountID"}), sqlmock.NewResult(1, 1))
+}
+
+// DBUpsertUpdateSetupHelper mocks an upsert that updates user 1 in the same account. MySQL reports 2
+// rows affected when ON DUPLICATE KEY UPDATE changes a row.
+func DBUpsertUpdateSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sqlmock) {
+	rows := sqlmock.NewRows([]string{"id", "accountID"}).AddRow(1, u.AccountID)
+	return dbUpsertSetupHelper(t, u, rows, sqlmock.NewResult(0, 2))
+}
+
+// DBUpsertSkipSetupHelper mocks an upsert of a user that's identical to user 1, nothing is changed
+func DBUpsertSkipSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sqlmock) {
+	rows := sqlmock.NewRows([]string{"id", "accountID"}).AddRow(1, u.AccountID)
+	return dbUpsertSetupHelper(t, u, rows, sqlmock.NewResult(0, 0))
+}
+
+// DBUpsertOtherAccountSetupHelper mocks an upsert of a user whose email is in use in another account
+func DBUpsertOtherAccountSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, accountID FROM user WHERE email = (.+) FOR UPDATE").WithArgs(u.EMail).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "accountID"}).AddRow(7, u.AccountID+1))
+	mock.ExpectRollback()
+
+	return db, mock
+}
+
+// DBUpsertErrorSetupHelper mocks an upsert that fails
+func DBUpsertErrorSetupHelper(t *testing.T, u domain.User) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, accountID FROM user WHERE email = (.+) FOR UPDATE").WithArgs(u.EMail).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "accountID"}))
+	mock.ExpectExec("INSERT INTO user (.+) ON DUPLICATE KEY UPDATE").WillReturnError(fmt.Errorf("some error"))
+	mock.ExpectRollback()
+
+	return db, mock
+}
+
+// successorRoleRows returns the result of locking a user's row to read its account and role
+func successorRoleRows(accountID int, role domain.Role) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"accountID", "role"}).AddRow(accountID, role)
+}
+
+// DBDeleteWithSuccessorSetupHelper mocks deleting user 1, the primary user of account 1, making
+// user 2 the account's primary user
+func DBDeleteWithSuccessorSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT accountID, role FROM user WHERE id = (.+) FOR UPDATE").WithArgs(1).WillReturnRows(successorRoleRows(1, domain.Primary))
+	mock.ExpectQuery("SELECT accountID, role FROM user WHERE id = (.+) FOR UPDATE").WithArgs(2).WillReturnRows(successorRoleRows(1, domain.Restricted))
+	mock.ExpectExec("UPDATE user SET role = (.+) WHERE id = (.+)").WithArgs(domain.Primary, sqlmock.AnyArg(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM user WHERE id = (.+)").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	return db, mock
+}
+
+// DBDeleteWithSuccessorNoUserSetupHelper mocks deleting user 1 with a successor when user 1 doesn't exist
+func DBDeleteWithSuccessorNoUserSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT accountID, role FROM user WHERE id = (.+) FOR UPDATE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"accountID", "role"}))
+	mock.ExpectRollback()
+
+	return db, mock
+}
+
+// DBDeleteWithSuccessorNotPrimarySetupHelper mocks deleting user 1 with a successor when user 1 isn't
+// a primary user
+func DBDeleteWithSuccessorNotPrimarySetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT accountID, role FROM user WHERE id = (.+) FOR UPDATE").WithArgs(1).WillReturnRows(successorRoleRows(1, domain.Restricted))
+	mock.ExpectRollback()
+
+	return db, mock
+}
+
+// DBDeleteWithSuccessorOtherAccountSetupHelper mocks deleting user 1, the primary user of account 1,
+// naming user 2, a user of account 2, as its successor
+func DBDeleteWithSuccessorOtherAccountSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT accountID, role FROM user WHERE id = (.+) FOR UPDATE").WithArgs(1).WillReturnRows(successorRoleRows(1, domain.Primary))
+	mock.ExpectQuery("SELECT accountID, role FROM user WHERE id = (.+) FOR UPDATE").WithArgs(2).WillReturnRows(successorRoleRows(2, domain.Restricted))
+	mock.ExpectRollback()
+
+	return db, mock
+}
+
+// DBDeleteWithSuccessorErrorSetupHelper mocks a DB error, and the resulting rollback, while deleting
+// user 1 with user 2 as its successor
+func DBDeleteWithSuccessorErrorSetupHelper(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT accountID, role FROM user WHERE id = (.+) FOR UPDATE").WithArgs(1).WillReturnRows(successorRoleRows(1, domain.Primary))
+	mock.ExpectQuery("SELECT accountID, role FROM user WHERE id = (.+) FOR UPDATE").WithArgs(2).WillReturnRows(successorRoleRows(1, domain.Restricted))
+	mock.ExpectExec("UPDATE user SET role = (.+) WHERE id = (.+)").WithArgs(domain.Primary, sqlmock.AnyArg(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM user WHERE id = (.+)").WithArgs(1).WillReturnError(fmt.Errorf("some error"))
+	mock.ExpectRollback()
+
+	return db, mock
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tests
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+func TestUnitOfWork(t *testing.T) {
+	user := domain.User{
+		AccountID: 1,
+		Name:      "Mickey Dolenz",
+		EMail:     "mickeyd@themonkeys.com",
+		Role:      domain.Unrestricted,
+		Password:  "myawesomepassword",
+	}
+	queueID := 7
+
+	tests := []struct {
+		testName   string
+		shouldPass bool
+		setupFunc  func(sqlmock.Sqlmock)
+	}{
+		{
+			testName:   "testUnitOfWorkCommit",
+			shouldPass: true,
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO user").WillReturnResult(sqlmock.NewResult(42, 1))
+				mock.ExpectExec("UPDATE userCreateQueue SET status").WithArgs(domain.QueueComplete, 42, queueID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			testName:   "testUnitOfWorkRollback",
+			shouldPass: false,
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO user").WillReturnResult(sqlmock.NewResult(42, 1))
+				mock.ExpectExec("UPDATE userCreateQueue SET status").WithArgs(domain.QueueComplete, 42, queueID).
+					WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+		},
+		{
+			testName:   "testUnitOfWorkBeginError",
+			shouldPass: false,
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(sql.ErrConnDone)
+			},
+		},
+		{
+			testName:   "testUnitOfWorkCommitError",
+			shouldPass: false,
+			setupFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO user").WillReturnResult(sqlmock.NewResult(42, 1))
+				mock.ExpectExec("UPDATE userCreateQueue SET status").WithArgs(domain.QueueComplete, 42, queueID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit().WillReturnError(sql.ErrConnDone)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+			}
+			defer dbase.Close()
+			tc.setupFunc(mock)
+
+			users, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			queue, err := db.NewQueueTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating queue table instance: %s", err)
+			}
+			uow, err := db.NewUnitOfWork(dbase, users, queue)
+			if err != nil {
+				t.Fatalf("error creating unit of work instance: %s", err)
+			}
+
+			err2 := uow.Do(func(repos domain.Repositories) *mverr.MVError {
+				id, err := repos.Users.CreateUser(user)
+				if err != nil {
+					return err
+				}
+				return repos.UserQueue.CompleteUser(queueID, id)
+			})
+			validateExpectedErrors(t, err2, tc.shouldPass)
+
+			DBCallTeardownHelper(t, mock)
+		})
+	}
+}
+
+// TestUnitOfWorkJoinsTransaction verifies that a repository operation that needs its own
+// transaction joins the UnitOfWork's transaction instead of beginning and committing another one
+func TestUnitOfWorkJoinsTransaction(t *testing.T) {
+	u := domain.User{
+		AccountID: 1,
+		ID:        2,
+		Name:      "mickey dolenz",
+		EMail:     "mickeyd@gmail.com",
+		Role:      domain.Unrestricted,
+		Password:  "myawesomepassword",
+	}
+
+	dbase, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+	defer dbase.Close()
+
+	rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby"}).
+		AddRow(u.AccountID, u.ID, u.Name, u.EMail, u.Role, domain.Active, createdAt, updatedAt, nil, nil)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user WHERE id = (.+) FOR UPDATE").WithArgs(u.ID).WillReturnRows(rows)
+	mock.ExpectExec("UPDATE user SET (.+) WHERE (.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM user WHERE id = ?").WithArgs(3).WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	users, err := db.NewTable(dbase)
+	if err != nil {
+		t.Fatalf("error creating user table instance: %s", err)
+	}
+	uow, err := db.NewUnitOfWork(dbase, users, nil)
+	if err != nil {
+		t.Fatalf("error creating unit of work instance: %s", err)
+	}
+
+	err2 := uow.Do(func(repos domain.Repositories) *mverr.MVError {
+		if repos.UserQueue != nil {
+			t.Errorf("expected nil UserQueue when the unit of work has no queue")
+		}
+		if err := repos.Users.UpdateUser(u); err != nil {
+			return err
+		}
+		_, err := repos.Users.DeleteUser(3)
+		return err
+	})
+	validateExpectedErrors(t, err2, false)
+
+	DBCallTeardownHelper(t, mock)
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tests
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+func TestUpsertUser(t *testing.T) {
+	user := domain.User{
+		AccountID: 1,
+		Name:      "mama cass",
+		EMail:     "mama@gmail.com",
+		Role:      domain.Unrestricted,
+		Password:  "myawsomepassword",
+	}
+
+	tests := []struct {
+		testName        string
+		user            domain.User
+		shouldPass      bool
+		expectedID      int
+		expectedOutcome domain.UpsertOutcome
+		expectedErrCode mverr.ErrCode
+		setupFunc       func(*testing.T, domain.User) (*sql.DB, sqlmock.Sqlmock)
+		teardownFunc    func(*testing.T, sqlmock.Sqlmock)
+	}{
+		{
+			testName:        "testUpsertUserCreated",
+			user:            user,
+			shouldPass:      true,
+			expectedID:      1,
+			expectedOutcome: domain.UpsertCreated,
+			setupFunc:       DBUpsertCreateSetupHelper,
+			teardownFunc:    DBCallTeardownHelper,
+		},
+		{
+			testName:        "testUpsertUserUpdated",
+			user:            user,
+			shouldPass:      true,
+			expectedID:      1,
+			expectedOutcome: domain.UpsertUpdated,
+			setupFunc:       DBUpsertUpdateSetupHelper,
+			teardownFunc:    DBCallTeardownHelper,
+		},
+		{
+			testName:        "testUpsertUserSkipped",
+			user:            user,
+			shouldPass:      true,
+			expectedID:      1,
+			expectedOutcome: domain.UpsertSkipped,
+			setupFunc:       DBUpsertSkipSetupHelper,
+			teardownFunc:    DBCallTeardownHelper,
+		},
+		{
+			testName:        "testUpsertUserOtherAccount",
+			user:            user,
+			shouldPass:      false,
+			expectedErrCode: mverr.DBInsertDuplicateUserErrorCode,
+			setupFunc:       DBUpsertOtherAccountSetupHelper,
+			teardownFunc:    DBCallTeardownHelper,
+		},
+		{
+			testName:        "testUpsertUserInvalid",
+			user:            domain.User{AccountID: 1, Name: "mama cass"},
+			shouldPass:      false,
+			expectedErrCode: mverr.UserValidationErrorCode,
+			setupFunc:       DBNoCallSetupHelper,
+			teardownFunc:    DBCallTeardownHelper,
+		},
+		{
+			testName:        "testUpsertUserDBError",
+			user:            user,
+			shouldPass:      false,
+			expectedErrCode: mverr.DBUpSertErrorCode,
+			setupFunc:       DBUpsertErrorSetupHelper,
+			teardownFunc:    DBCallTeardownHelper,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock := tc.setupFunc(t, tc.user)
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			defer dbase.Close()
+
+			id, outcome, err2 := ut.UpsertUser(tc.user)
+			validateExpectedErrors(t, err2, tc.shouldPass)
+			if err2 != nil && err2.ErrCode != tc.expectedErrCode {
+				t.Errorf("expected error code %d, got %d", tc.expectedErrCode, err2.ErrCode)
+			}
+			if id != tc.expectedID || outcome != tc.expectedOutcome {
+				t.Errorf("expected ID %d and outcome %q, got %d and %q", tc.expectedID, tc.expectedOutcome, id, outcome)
+			}
+
+			tc.teardownFunc(t, mock)
+		})
+	}
+}
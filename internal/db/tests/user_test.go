@@ -13,10 +13,13 @@ import (
 	"database/sql"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/youngkin/mockvideo/internal/db"
 	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/idgen"
 )
 
 func TestGetAllUsers(t *testing.T) {
@@ -94,6 +97,241 @@ func TestGetAllUsers(t *testing.T) {
 	}
 }
 
+func TestGetUsersPage(t *testing.T) {
+	tests := []struct {
+		testName     string
+		shouldPass   bool
+		setupFunc    func(*testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.Users)
+		teardownFunc func(*testing.T, sqlmock.Sqlmock)
+	}{
+		{
+			testName:     "testGetUsersPageSuccess",
+			shouldPass:   true,
+			setupFunc:    DBUsersPageSetupHelper,
+			teardownFunc: DBCallTeardownHelper,
+		},
+		{
+			testName:     "testGetUsersPageQueryFailure",
+			shouldPass:   false,
+			setupFunc:    DBUsersPageErrorSetupHelper,
+			teardownFunc: DBCallTeardownHelper,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, expected := tc.setupFunc(t)
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			defer dbase.Close()
+
+			actual, err2 := ut.GetUsersPage(1, 2)
+
+			validateExpectedErrors(t, err2, tc.shouldPass)
+			if tc.shouldPass {
+				if len(expected.Users) != len(actual.Users) {
+					t.Fatalf("expected %d users, got %d", len(expected.Users), len(actual.Users))
+				}
+				for i, user := range expected.Users {
+					if *user != *actual.Users[i] {
+						t.Errorf("expected %+v, got %+v", user, actual.Users[i])
+					}
+				}
+			}
+			tc.teardownFunc(t, mock)
+		})
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	dbase, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+	}
+	defer dbase.Close()
+
+	// LIKE wildcards in the query are matched literally
+	rows := sqlmock.NewRows([]string{"accountid", "id", "name", "email", "role", "status", "createdat", "updatedat", "lastlogin", "lastmodifiedby"}).
+		AddRow(0, 2, "mickey_dolenz", "mdolenz@themonkeys.com", domain.Restricted, domain.Active, createdAt, updatedAt, nil, nil)
+	mock.ExpectQuery("SELECT accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy FROM user WHERE status = \\? AND \\(name LIKE \\? OR email LIKE \\?\\) ORDER BY id LIMIT \\?").
+		WithArgs(domain.Active, `%y\_d%`, `%y\_d%`, 10).
+		WillReturnRows(rows)
+
+	ut, err := db.NewTable(dbase)
+	if err != nil {
+		t.Fatalf("error creating user table instance: %s", err)
+	}
+	users, err2 := ut.SearchUsers("y_d", 10)
+	if err2 != nil {
+		t.Fatalf("error %s was not expected", err2)
+	}
+	if len(users.Users) != 1 || users.Users[0].ID != 2 {
+		t.Errorf("expected user 2, got %+v", users.Users)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetUsersWithin(t *testing.T) {
+	tests := []struct {
+		testName          string
+		qt                domain.QueryTimeout
+		setupFunc         func(*testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.Users)
+		expectedErrCode   mverr.ErrCode
+		expectedTruncated bool
+	}{
+		{
+			testName:  "testGetUsersWithinTimeout",
+			qt:        domain.QueryTimeout{Timeout: time.Minute},
+			setupFunc: DBCallSetupHelper,
+		},
+		{
+			testName:  "testGetUsersNoTimeout",
+			setupFunc: DBCallSetupHelper,
+		},
+		{
+			testName:        "testGetUsersTimedOut",
+			qt:              domain.QueryTimeout{Timeout: 10 * time.Millisecond},
+			setupFunc:       DBCallSlowQuerySetupHelper,
+			expectedErrCode: mverr.QueryTimeoutErrorCode,
+		},
+		{
+			testName:          "testGetUsersTimedOutPartial",
+			qt:                domain.QueryTimeout{Timeout: 10 * time.Millisecond, Partial: true},
+			setupFunc:         DBCallSlowQuerySetupHelper,
+			expectedTruncated: true,
+		},
+		{
+			testName:        "testGetUsersQueryFailure",
+			qt:              domain.QueryTimeout{Timeout: time.Minute, Partial: true},
+			setupFunc:       DBCallQueryErrorSetupHelper,
+			expectedErrCode: mverr.UserRqstErrorCode,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, expected := tc.setupFunc(t)
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			defer dbase.Close()
+
+			actual, err2 := ut.GetUsersWithin(tc.qt)
+			if tc.expectedErrCode != mverr.NoErrorCode {
+				if err2 == nil || err2.ErrCode != tc.expectedErrCode {
+					t.Fatalf("expected error code %d, got %v", tc.expectedErrCode, err2)
+				}
+				return
+			}
+			if err2 != nil {
+				t.Fatalf("error '%s' was not expected", err2)
+			}
+			if actual.Truncated != tc.expectedTruncated {
+				t.Errorf("expected truncated %t, got %t", tc.expectedTruncated, actual.Truncated)
+			}
+			if len(expected.Users) != len(actual.Users) {
+				t.Errorf("expected %d users, got %d", len(expected.Users), len(actual.Users))
+			}
+			DBCallTeardownHelper(t, mock)
+		})
+	}
+}
+
+func TestUsersVersion(t *testing.T) {
+	tests := []struct {
+		testName     string
+		shouldPass   bool
+		setupFunc    func(*testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.UsersVersion)
+		teardownFunc func(*testing.T, sqlmock.Sqlmock)
+	}{
+		{
+			testName:     "testUsersVersionSuccess",
+			shouldPass:   true,
+			setupFunc:    DBUsersVersionSetupHelper,
+			teardownFunc: DBCallTeardownHelper,
+		},
+		{
+			testName:     "testUsersVersionNoUsers",
+			shouldPass:   true,
+			setupFunc:    DBUsersVersionEmptySetupHelper,
+			teardownFunc: DBCallTeardownHelper,
+		},
+		{
+			testName:     "testUsersVersionQueryFailure",
+			shouldPass:   false,
+			setupFunc:    DBUsersVersionErrorSetupHelper,
+			teardownFunc: DBCallTeardownHelper,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, expected := tc.setupFunc(t)
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+			defer dbase.Close()
+
+			actual, err2 := ut.UsersVersion()
+
+			validateExpectedErrors(t, err2, tc.shouldPass)
+			if tc.shouldPass && *expected != *actual {
+				t.Errorf("expected %+v, got %+v", expected, actual)
+			}
+			tc.teardownFunc(t, mock)
+		})
+	}
+}
+
+func TestEmailInUse(t *testing.T) {
+	tests := []struct {
+		testName   string
+		count      int
+		queryErr   error
+		shouldPass bool
+		expected   bool
+	}{
+		{testName: "testEmailInUse", count: 1, shouldPass: true, expected: true},
+		{testName: "testEmailNotInUse", count: 0, shouldPass: true, expected: false},
+		{testName: "testEmailInUseQueryFailure", queryErr: fmt.Errorf("some error"), shouldPass: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a mock database connection", err)
+			}
+			defer dbase.Close()
+			query := mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM user WHERE email = \\? AND id != \\?").
+				WithArgs("porgytirebiter@email.com", 1)
+			if tc.queryErr != nil {
+				query.WillReturnError(tc.queryErr)
+			} else {
+				query.WillReturnRows(sqlmock.NewRows([]string{"count(*)"}).AddRow(tc.count))
+			}
+			ut, err := db.NewTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating user table instance: %s", err)
+			}
+
+			actual, err2 := ut.EmailInUse("porgytirebiter@email.com", 1)
+
+			validateExpectedErrors(t, err2, tc.shouldPass)
+			if actual != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, actual)
+			}
+			DBCallTeardownHelper(t, mock)
+		})
+	}
+}
+
 func TestGetUser(t *testing.T) {
 	tests := []struct {
 		testName     string
@@ -154,6 +392,7 @@ func TestInsertUser(t *testing.T) {
 	tests := []struct {
 		testName       string
 		user           domain.User
+		idGen          idgen.Generator
 		expectedUserID int
 		shouldPass     bool
 		setupFunc      func(*testing.T, domain.User) (*sql.DB, sqlmock.Sqlmock)
@@ -187,6 +426,36 @@ func TestInsertUser(t *testing.T) {
 			setupFunc:      DBInsertErrorSetupHelper,
 			teardownFunc:   DBCallTeardownHelper,
 		},
+		{
+			testName: "testInsertUserGeneratedID",
+			user: domain.User{
+				AccountID: 1,
+				Name:      "mama cass",
+				EMail:     "mama@gmail.com",
+				Role:      0,
+				Password:  "myawsomepassword",
+			},
+			idGen:          idgen.Keyed{"mama@gmail.com": 42},
+			expectedUserID: 42,
+			shouldPass:     true,
+			setupFunc:      DBInsertWithIDSetupHelper,
+			teardownFunc:   DBCallTeardownHelper,
+		},
+		{
+			testName: "testInsertUserNoGeneratedID",
+			user: domain.User{
+				AccountID: 1,
+				Name:      "mama cass",
+				EMail:     "mama@gmail.com",
+				Role:      0,
+				Password:  "myawsomepassword",
+			},
+			idGen:          idgen.Keyed{},
+			expectedUserID: 0,
+			shouldPass:     false,
+			setupFunc:      DBNoCallSetupHelper,
+			teardownFunc:   DBCallTeardownHelper,
+		},
 	}
 
 	for _, tc := range tests {
@@ -197,6 +466,11 @@ func TestInsertUser(t *testing.T) {
 				t.Fatalf("error creating user table instance: %s", err)
 			}
 			defer dbase.Close()
+			if tc.idGen != nil {
+				if err = ut.SetIDGenerator(tc.idGen); err != nil {
+					t.Fatalf("error setting the ID generator: %s", err)
+				}
+			}
 
 			uID, err2 := ut.CreateUser(tc.user)
 
@@ -0,0 +1,180 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package tests
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/youngkin/mockvideo/internal/db"
+	"github.com/youngkin/mockvideo/internal/domain"
+)
+
+func TestEnqueueUser(t *testing.T) {
+	tests := []struct {
+		testName     string
+		shouldPass   bool
+		expectedID   int
+		user         domain.User
+		setupFunc    func(*testing.T, domain.User) (*sql.DB, sqlmock.Sqlmock)
+		teardownFunc func(*testing.T, sqlmock.Sqlmock)
+	}{
+		{
+			testName:   "testEnqueueUserSuccess",
+			shouldPass: true,
+			expectedID: 7,
+			user: domain.User{
+				AccountID: 1,
+				Name:      "Mickey Dolenz",
+				EMail:     "mickeyd@themonkeys.com",
+				Role:      domain.Unrestricted,
+				Password:  "myawesomepassword",
+			},
+			setupFunc:    DBEnqueueSetupHelper,
+			teardownFunc: DBCallTeardownHelper,
+		},
+		{
+			testName:   "testEnqueueUserDBError",
+			shouldPass: false,
+			user: domain.User{
+				AccountID: 1,
+				Name:      "Mickey Dolenz",
+				EMail:     "mickeyd@themonkeys.com",
+				Role:      domain.Unrestricted,
+				Password:  "myawesomepassword",
+			},
+			setupFunc:    DBEnqueueErrorSetupHelper,
+			teardownFunc: DBCallTeardownHelper,
+		},
+		{
+			testName:   "testEnqueueUserInvalidUser",
+			shouldPass: false,
+			user: domain.User{
+				AccountID: 1,
+				Name:      "Mickey Dolenz",
+				Role:      domain.Unrestricted,
+				Password:  "myawesomepassword",
+			},
+			setupFunc:    DBNoCallSetupHelper,
+			teardownFunc: DBCallTeardownHelper,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock := tc.setupFunc(t, tc.user)
+			qt, err := db.NewQueueTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating queue table instance: %s", err)
+			}
+			defer dbase.Close()
+
+			id, err2 := qt.EnqueueUser(tc.user)
+			validateExpectedErrors(t, err2, tc.shouldPass)
+			if id != tc.expectedID {
+				t.Errorf("expected queued user ID %d, got %d", tc.expectedID, id)
+			}
+
+			tc.teardownFunc(t, mock)
+		})
+	}
+}
+
+func TestClaimNextUser(t *testing.T) {
+	tests := []struct {
+		testName     string
+		shouldPass   bool
+		setupFunc    func(*testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.QueuedUser)
+		teardownFunc func(*testing.T, sqlmock.Sqlmock)
+	}{
+		{
+			testName:     "testClaimNextUserSuccess",
+			shouldPass:   true,
+			setupFunc:    DBClaimQueuedUserSetupHelper,
+			teardownFunc: DBCallTeardownHelper,
+		},
+		{
+			testName:     "testClaimNextUserEmptyQueue",
+			shouldPass:   true, // true because we get a nil 'QueuedUser' if the queue is empty
+			setupFunc:    DBClaimEmptyQueueSetupHelper,
+			teardownFunc: DBCallTeardownHelper,
+		},
+		{
+			testName:     "testClaimNextUserUpdateError",
+			shouldPass:   false,
+			setupFunc:    DBClaimQueuedUserErrorSetupHelper,
+			teardownFunc: DBCallTeardownHelper,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, expected := tc.setupFunc(t)
+			qt, err := db.NewQueueTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating queue table instance: %s", err)
+			}
+			defer dbase.Close()
+
+			actual, err2 := qt.ClaimNextUser()
+			validateExpectedErrors(t, err2, tc.shouldPass)
+
+			if expected == nil && actual != nil {
+				t.Errorf("expected nil QueuedUser, got %+v", actual)
+			}
+			if expected != nil && (actual == nil || *expected != *actual) {
+				t.Errorf("expected %+v, got %+v", expected, actual)
+			}
+
+			tc.teardownFunc(t, mock)
+		})
+	}
+}
+
+func TestGetQueuedUser(t *testing.T) {
+	tests := []struct {
+		testName     string
+		shouldPass   bool
+		setupFunc    func(*testing.T) (*sql.DB, sqlmock.Sqlmock, *domain.QueuedUser)
+		teardownFunc func(*testing.T, sqlmock.Sqlmock)
+	}{
+		{
+			testName:     "testGetQueuedUserSuccess",
+			shouldPass:   true,
+			setupFunc:    DBGetQueuedUserSetupHelper,
+			teardownFunc: DBCallTeardownHelper,
+		},
+		{
+			testName:     "testGetQueuedUserNoRow",
+			shouldPass:   true, // true because we get a nil 'QueuedUser' if not found
+			setupFunc:    DBGetQueuedUserErrNoRowsSetupHelper,
+			teardownFunc: DBCallTeardownHelper,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			dbase, mock, expected := tc.setupFunc(t)
+			qt, err := db.NewQueueTable(dbase)
+			if err != nil {
+				t.Fatalf("error creating queue table instance: %s", err)
+			}
+			defer dbase.Close()
+
+			actual, err2 := qt.GetQueuedUser(7)
+			validateExpectedErrors(t, err2, tc.shouldPass)
+
+			if expected == nil && actual != nil {
+				t.Errorf("expected nil QueuedUser, got %+v", actual)
+			}
+			if expected != nil && (actual == nil || *expected != *actual) {
+				t.Errorf("expected %+v, got %+v", expected, actual)
+			}
+
+			tc.teardownFunc(t, mock)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+// querier is implemented by both sql.DB and sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// txn is the transaction used by a single repository operation that needs one, e.g.,
+// Table.UpdateRoles. If the repository is part of a UnitOfWork the operation joins the
+// UnitOfWork's transaction and txn's Commit and Rollback do nothing, the UnitOfWork
+// decides whether the transaction is committed.
+type txn struct {
+	*sql.Tx
+	owned bool
+}
+
+// beginTxn returns a txn that joins 'tx' if it's non-nil, otherwise it begins a new transaction
+// that's rolled back if 'ctx' is done before it's committed
+func beginTxn(ctx context.Context, db *sql.DB, tx *sql.Tx) (txn, error) {
+	if tx != nil {
+		return txn{Tx: tx}, nil
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return txn{}, err
+	}
+	return txn{Tx: tx, owned: true}, nil
+}
+
+// Commit commits the transaction if txn began it
+func (t txn) Commit() error {
+	if !t.owned {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+// Rollback rolls back the transaction if txn began it
+func (t txn) Rollback() error {
+	if !t.owned {
+		return nil
+	}
+	return t.Tx.Rollback()
+}
+
+// UnitOfWork is the MySQL implementation of domain.UnitOfWork
+type UnitOfWork struct {
+	db    *sql.DB
+	users *Table
+	queue *QueueTable
+	// accounts is only set if the UnitOfWork includes the account table, see SetAccountTable
+	accounts *AccountTable
+}
+
+// NewUnitOfWork returns a UnitOfWork whose transactions include 'users' and, if it's
+// non-nil, 'queue'. 'db' and 'users' must be non-nil.
+func NewUnitOfWork(db *sql.DB, users *Table, queue *QueueTable) (*UnitOfWork, error) {
+	if db == nil {
+		return nil, errors.New("non-nil sql.DB connection required")
+	}
+	if users == nil {
+		return nil, errors.New("non-nil Table required")
+	}
+	return &UnitOfWork{db: db, users: users, queue: queue}, nil
+}
+
+// SetAccountTable adds 'accounts', which must be non-nil, to the UnitOfWork's transactions
+func (uow *UnitOfWork) SetAccountTable(accounts *AccountTable) error {
+	if accounts == nil {
+		return errors.New("non-nil AccountTable required")
+	}
+	uow.accounts = accounts
+	return nil
+}
+
+// Do implements domain.UnitOfWork
+func (uow *UnitOfWork) Do(fn func(repos domain.Repositories) *mverr.MVError) *mverr.MVError {
+	tx, err := uow.db.Begin()
+	if err != nil {
+		return &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  "error beginning unit of work transaction",
+			WrappedErr: err}
+	}
+
+	repos := domain.Repositories{Users: uow.users.WithTx(tx)}
+	if uow.queue != nil {
+		repos.UserQueue = uow.queue.WithTx(tx)
+	}
+	if uow.accounts != nil {
+		repos.Accounts = uow.accounts.WithTx(tx)
+	}
+
+	if mvErr := fn(repos); mvErr != nil {
+		tx.Rollback()
+		return mvErr
+	}
+
+	if err = tx.Commit(); err != nil {
+		return &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  "error committing unit of work transaction",
+			WrappedErr: err}
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+const userQueueTbl = "userQueueTbl"
+
+var (
+	enqueueUserStmt     = "INSERT INTO userCreateQueue (accountID, name, email, role, password, status) VALUES (?, ?, ?, ?, ?, ?)"
+	nextPendingQuery    = "SELECT id, accountID, name, email, role, password, attempts FROM userCreateQueue WHERE status = ? ORDER BY id LIMIT 1 FOR UPDATE"
+	claimQueuedUserStmt = "UPDATE userCreateQueue SET status = ? WHERE id = ?"
+	completeQueuedStmt  = "UPDATE userCreateQueue SET status = ?, userID = ?, password = '' WHERE id = ?"
+	failQueuedStmt      = "UPDATE userCreateQueue SET status = ?, errMsg = ?, password = '' WHERE id = ?"
+	// The password is retained by failed attempts so the user creation can be retried or replayed
+	failedAttemptStmt    = "UPDATE userCreateQueue SET status = ?, attempts = attempts + 1, errMsg = ? WHERE id = ?"
+	insertDeadLetterStmt = "INSERT INTO deadLetter (source, sourceID, attempts, errMsg) SELECT ?, id, attempts, errMsg FROM userCreateQueue WHERE id = ?"
+	replayQueuedStmt     = "UPDATE userCreateQueue SET status = ?, attempts = 0 WHERE id = ? AND status = ?"
+	getQueuedUserQuery   = "SELECT id, accountID, name, email, role, status, attempts, userID, errMsg FROM userCreateQueue WHERE id = ?"
+)
+
+// QueueTable supports access to the 'userCreateQueue' table. It is the durable queue
+// used when user creation is done in write-behind mode.
+// TODO: Rows left in the 'processing' state by a crashed worker aren't reclaimed.
+type QueueTable struct {
+	db *sql.DB
+	// tx is only set when the QueueTable is part of a UnitOfWork, see WithTx
+	tx *sql.Tx
+}
+
+// NewQueueTable creates a new QueueTable instance with the provided sql.DB instance
+func NewQueueTable(db *sql.DB) (*QueueTable, error) {
+	if db == nil {
+		return nil, errors.New("non-nil sql.DB connection required")
+	}
+	return &QueueTable{db: db}, nil
+}
+
+// WithTx returns a copy of the QueueTable whose operations are performed within 'tx'. The
+// caller is responsible for committing or rolling back 'tx', see UnitOfWork.
+func (qt *QueueTable) WithTx(tx *sql.Tx) *QueueTable {
+	t := *qt
+	t.tx = tx
+	return &t
+}
+
+// conn returns the transaction the QueueTable is bound to, if any, otherwise the sql.DB
+func (qt *QueueTable) conn() querier {
+	if qt.tx != nil {
+		return qt.tx
+	}
+	return qt.db
+}
+
+// EnqueueUser validates the provided user data, adds it to the queue, and returns
+// the provisional ID of the queued user creation. The user's password must already be hashed.
+func (qt *QueueTable) EnqueueUser(u domain.User) (int, *mverr.MVError) {
+	start := time.Now()
+
+	err := u.ValidateUser()
+	if err != nil {
+		DBRqstDur.WithLabelValues(userQueueTbl, create, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return 0, &mverr.MVError{
+			ErrCode:    mverr.UserValidationErrorCode,
+			ErrMsg:     mverr.UserValidationErrorMsg,
+			ErrDetail:  err.Error(),
+			WrappedErr: err}
+	}
+
+	r, err := qt.conn().Exec(enqueueUserStmt, u.AccountID, u.Name, u.EMail, u.Role, u.Password, domain.QueuePending)
+	if err != nil {
+		DBRqstDur.WithLabelValues(userQueueTbl, create, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return 0, &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error queueing user creation: User name: %s, User email: %s", u.Name, u.EMail),
+			WrappedErr: err}
+	}
+	id, err := r.LastInsertId()
+	if err != nil {
+		DBRqstDur.WithLabelValues(userQueueTbl, create, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return 0, &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  "unable to obtain queued user's provisional ID",
+			WrappedErr: err}
+	}
+
+	DBRqstDur.WithLabelValues(userQueueTbl, create, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return int(id), nil
+}
+
+// ClaimNextUser marks the oldest pending user creation as 'processing' and returns it. The
+// select and update are done in a single transaction so that multiple service instances
+// can't claim the same user creation. A nil QueuedUser is returned if the queue is empty. Queued
+// passwords are hashes, see EnqueueUser.
+func (qt *QueueTable) ClaimNextUser() (*domain.QueuedUser, *mverr.MVError) {
+	start := time.Now()
+
+	tx, err := beginTxn(context.Background(), qt.db, qt.tx)
+	if err != nil {
+		DBRqstDur.WithLabelValues(userQueueTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return nil, &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  "error beginning transaction to claim queued user",
+			WrappedErr: err}
+	}
+
+	qu := &domain.QueuedUser{Status: domain.QueueProcessing, PasswordHashed: true}
+	row := tx.QueryRow(nextPendingQuery, domain.QueuePending)
+	err = row.Scan(&qu.ID,
+		&qu.User.AccountID,
+		&qu.User.Name,
+		&qu.User.EMail,
+		&qu.User.Role,
+		&qu.User.Password,
+		&qu.Attempts)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		DBRqstDur.WithLabelValues(userQueueTbl, update, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+		return nil, nil
+	}
+	if err != nil {
+		tx.Rollback()
+		DBRqstDur.WithLabelValues(userQueueTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return nil, &mverr.MVError{
+			ErrCode:    mverr.DBRowScanErrorCode,
+			ErrMsg:     mverr.DBRowScanErrorMsg,
+			ErrDetail:  "error scanning pending queued user row",
+			WrappedErr: err}
+	}
+
+	_, err = tx.Exec(claimQueuedUserStmt, domain.QueueProcessing, qu.ID)
+	if err != nil {
+		tx.Rollback()
+		DBRqstDur.WithLabelValues(userQueueTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return nil, &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error claiming queued user %d", qu.ID),
+			WrappedErr: err}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		DBRqstDur.WithLabelValues(userQueueTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return nil, &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error committing claim of queued user %d", qu.ID),
+			WrappedErr: err}
+	}
+
+	DBRqstDur.WithLabelValues(userQueueTbl, update, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return qu, nil
+}
+
+// CompleteUser records that the queued user creation identified by 'id' resulted in the
+// creation of the user identified by 'userID'. The queued password is cleared.
+func (qt *QueueTable) CompleteUser(id int, userID int) *mverr.MVError {
+	return qt.finish(id, completeQueuedStmt, domain.QueueComplete, userID, id)
+}
+
+// FailUser records that the queued user creation identified by 'id' could not be applied.
+// The queued password is cleared.
+func (qt *QueueTable) FailUser(id int, errMsg string) *mverr.MVError {
+	return qt.finish(id, failQueuedStmt, domain.QueueFailed, errMsg, id)
+}
+
+// RetryUser records a failed attempt to apply the queued user creation identified by 'id' and
+// returns it to the pending state so it's claimed again. The queued password is retained.
+func (qt *QueueTable) RetryUser(id int, errMsg string) *mverr.MVError {
+	return qt.finish(id, failedAttemptStmt, domain.QueuePending, errMsg, id)
+}
+
+// DeadLetterUser records the final failed attempt to apply the queued user creation identified by
+// 'id' and adds it to the 'deadLetter' table. Both are done in a single transaction. The queued
+// password is retained so the user creation can be replayed, see ReplayUser.
+func (qt *QueueTable) DeadLetterUser(id int, errMsg string) *mverr.MVError {
+	start := time.Now()
+
+	tx, err := beginTxn(context.Background(), qt.db, qt.tx)
+	if err != nil {
+		DBRqstDur.WithLabelValues(userQueueTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error beginning transaction to dead-letter queued user %d", id),
+			WrappedErr: err}
+	}
+
+	_, err = tx.Exec(failedAttemptStmt, domain.QueueDeadLettered, errMsg, id)
+	if err == nil {
+		_, err = tx.Exec(insertDeadLetterStmt, domain.DeadLetterUserCreate, id)
+	}
+	if err != nil {
+		tx.Rollback()
+		DBRqstDur.WithLabelValues(userQueueTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error dead-lettering queued user %d", id),
+			WrappedErr: err}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		DBRqstDur.WithLabelValues(userQueueTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error committing dead-lettering of queued user %d", id),
+			WrappedErr: err}
+	}
+
+	DBRqstDur.WithLabelValues(userQueueTbl, update, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return nil
+}
+
+// ReplayUser returns the dead-lettered user creation identified by 'id' to the pending state with
+// no failed attempts. 'replayed' is false if the user creation isn't dead-lettered. The dead letter
+// itself isn't removed, see DeadLetterTable.DeleteDeadLetter.
+func (qt *QueueTable) ReplayUser(id int) (bool, *mverr.MVError) {
+	start := time.Now()
+
+	r, err := qt.conn().Exec(replayQueuedStmt, domain.QueuePending, id, domain.QueueDeadLettered)
+	if err != nil {
+		DBRqstDur.WithLabelValues(userQueueTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return false, &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error replaying queued user %d", id),
+			WrappedErr: err}
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		DBRqstDur.WithLabelValues(userQueueTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return false, &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("unable to determine if queued user %d was replayed", id),
+			WrappedErr: err}
+	}
+
+	DBRqstDur.WithLabelValues(userQueueTbl, update, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return n > 0, nil
+}
+
+func (qt *QueueTable) finish(id int, stmt string, args ...interface{}) *mverr.MVError {
+	start := time.Now()
+
+	_, err := qt.conn().Exec(stmt, args...)
+	if err != nil {
+		DBRqstDur.WithLabelValues(userQueueTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error updating status of queued user %d", id),
+			WrappedErr: err}
+	}
+
+	DBRqstDur.WithLabelValues(userQueueTbl, update, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return nil
+}
+
+// GetQueuedUser will return the queued user creation identified by 'id' or a nil QueuedUser
+// if there wasn't a match.
+func (qt *QueueTable) GetQueuedUser(id int) (*domain.QueuedUser, *mverr.MVError) {
+	start := time.Now()
+
+	var userID sql.NullInt64
+	var errMsg sql.NullString
+	qu := &domain.QueuedUser{}
+	row := qt.conn().QueryRow(getQueuedUserQuery, id)
+	err := row.Scan(&qu.ID,
+		&qu.User.AccountID,
+		&qu.User.Name,
+		&qu.User.EMail,
+		&qu.User.Role,
+		&qu.Status,
+		&qu.Attempts,
+		&userID,
+		&errMsg)
+	if err == sql.ErrNoRows {
+		DBRqstDur.WithLabelValues(userQueueTbl, readOne, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+		return nil, nil
+	}
+	if err != nil {
+		DBRqstDur.WithLabelValues(userQueueTbl, readOne, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return nil, &mverr.MVError{
+			ErrCode:    mverr.DBRowScanErrorCode,
+			ErrMsg:     mverr.DBRowScanErrorMsg,
+			ErrDetail:  fmt.Sprintf("error scanning queued user row %d", id),
+			WrappedErr: err}
+	}
+	qu.UserID = int(userID.Int64)
+	qu.ErrMsg = errMsg.String
+
+	DBRqstDur.WithLabelValues(userQueueTbl, readOne, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return qu, nil
+}
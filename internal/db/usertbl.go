@@ -5,25 +5,34 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/internal/clock"
 	"github.com/youngkin/mockvideo/internal/domain"
 	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/idgen"
+	"github.com/youngkin/mockvideo/internal/metrics"
 )
 
 // DBRqstDur is used to capture the length and status of database requests
 // The labels for this metric should be used as follows:
-//	1.	'operation' should be one of 'create|update|readAll|readOne|delete'
-//	2.	'result' should be one of 'ok|error'
-//	3.	'target' refers to the target table name. It should be one of 'user' for now.
-//		This must be updated when new tables are added.
-var DBRqstDur = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-	Namespace: "mockvideo",
+//  1. 'operation' should be one of 'create|update|upsert|readAll|readPage|readOne|readVersion|readEmail|search|delete'
+//  2. 'result' should be one of 'ok|error|timeout'
+//  3. 'target' refers to the target table name. It should be one of 'userTbl|userQueueTbl|accountTbl|deadLetterTbl' for now.
+//     This must be updated when new tables are added.
+//
+// Unlike the request duration metrics (see httpclient.ObserveSince) DBRqstDur observations don't have
+// trace ID exemplars. Table's methods don't have a context.Context, so the trace isn't available. They
+// can be added once a context is passed through to the repositories.
+var DBRqstDur = metrics.NewHistogramVec(prometheus.HistogramOpts{
 	Subsystem: "database",
 	Name:      "db_request_duration_seconds",
 	Help:      "database request duration distribution in seconds",
@@ -31,66 +40,276 @@ var DBRqstDur = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 	Buckets: prometheus.LinearBuckets(0.001, .004, 50),
 }, []string{"target", "operation", "result"})
 
+// DBRowsReturned and DBResultBytes are DBRqstDur's companions for queries that return a result set.
+// They capture the number of rows returned by a query and an estimate of their size in bytes, see
+// observeResultSet, so capacity planning can correlate query latency with result size. Their
+// 'target' and 'operation' labels are the same as DBRqstDur's. Only queries that succeed, or time out
+// returning partial results, are observed.
+var DBRowsReturned = metrics.NewHistogramVec(prometheus.HistogramOpts{
+	Subsystem: "database",
+	Name:      "db_rows_returned",
+	Help:      "distribution of the number of rows returned by database queries",
+	Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+}, []string{"target", "operation"})
+
+// DBResultBytes is described with DBRowsReturned
+var DBResultBytes = metrics.NewHistogramVec(prometheus.HistogramOpts{
+	Subsystem: "database",
+	Name:      "db_result_bytes",
+	Help:      "distribution of the estimated size in bytes of the result sets returned by database queries",
+	Buckets:   prometheus.ExponentialBuckets(256, 4, 8),
+}, []string{"target", "operation"})
+
+// Estimated sizes, in bytes, of fixed size columns. They're the sizes of the columns' MySQL storage
+// types, INT and DATETIME, variable size columns are estimated by their length.
+const (
+	intColumnBytes      = 4
+	dateTimeColumnBytes = 5
+)
+
+// observeResultSet observes DBRowsReturned and DBResultBytes for a query on 'target' that returned
+// 'rows' rows totalling an estimated 'bytes' bytes
+func observeResultSet(target, operation string, rows, bytes int) {
+	DBRowsReturned.WithLabelValues(target, operation).Observe(float64(rows))
+	DBResultBytes.WithLabelValues(target, operation).Observe(float64(bytes))
+}
+
+// usersBytes returns the estimated size of the user table rows 'users' were scanned from
+func usersBytes(users []*domain.User) int {
+	bytes := 0
+	for _, u := range users {
+		bytes += 3*intColumnBytes + 2*dateTimeColumnBytes + len(u.Name) + len(u.EMail) + len(u.Status) + len(u.LastModifiedBy)
+		if u.LastLogin != nil {
+			bytes += dateTimeColumnBytes
+		}
+	}
+	return bytes
+}
+
 // Metrics labels
 const (
 	create  = "create"
 	update  = "update"
 	readAll = "readAll"
 	readOne = "readOne"
-	delete  = "delete"
-	ok      = "ok"
-	dbErr   = "error"
-	userTbl = "userTbl"
+	// readPage is the operation label of GetUsersPage queries
+	readPage = "readPage"
+	// readVersion is the operation label of UsersVersion queries
+	readVersion = "readVersion"
+	// readEmail is the operation label of EmailInUse queries
+	readEmail = "readEmail"
+	// readCredentials is the operation label of GetCredentials queries
+	readCredentials = "readCredentials"
+	// readDependencies is the operation label of GetUserDependencies queries
+	readDependencies = "readDependencies"
+	// readInactive is the operation label of GetInactiveUsers queries
+	readInactive = "readInactive"
+	// recordLogin is the operation label of RecordLogin statements
+	recordLogin = "recordLogin"
+	// upsert is the operation label of UpsertUser statements
+	upsert = "upsert"
+	// search is the operation label of SearchUsers queries
+	search = "search"
+	delete = "delete"
+	ok     = "ok"
+	dbErr  = "error"
+	// timedOut is the result label of queries that exceeded their domain.QueryTimeout
+	timedOut = "timeout"
+	userTbl  = "userTbl"
 )
 
+// userColumns are the columns of the user table scanned by scanUser, in order
+const userColumns = "accountID, id, name, email, role, status, createdAt, updatedAt, lastLogin, lastModifiedBy"
+
 var (
-	getAllUsersQuery = "SELECT accountID, id, name, email, role FROM user"
-	getUserQuery     = "SELECT accountID, id, name, email, role FROM user WHERE id = ?"
-	// TODO: Implement these and remove the current insertUserStmt
-	// getUserPasswordQuery = "SELECT password WHERE id = ?"
-	insertUserStmt = "INSERT INTO user (accountID, name, email, role, password) VALUES (?, ?, ?, ?, ?)"
-	updateUserStmt = "UPDATE user SET id = ?, accountID = ?, name = ?, email = ?, role = ?, password = ? WHERE id = ?"
-	deleteUserStmt = "DELETE FROM user WHERE id = ?"
+	getAllUsersQuery     = "SELECT " + userColumns + " FROM user WHERE status = ?"
+	getUsersPageQuery    = "SELECT " + userColumns + " FROM user WHERE status = ? AND id > ? ORDER BY id LIMIT ?"
+	searchUsersQuery     = "SELECT " + userColumns + " FROM user WHERE status = ? AND (name LIKE ? OR email LIKE ?) ORDER BY id LIMIT ?"
+	getUsersVersionQuery = "SELECT MAX(updatedAt), COUNT(*) FROM user WHERE status = ?"
+	getUserQuery         = "SELECT " + userColumns + " FROM user WHERE id = ?"
+	lockUserQuery        = "SELECT " + userColumns + " FROM user WHERE id = ? FOR UPDATE"
+	// getInactiveUsersQuery selects the users that haven't logged in since a time, users that have
+	// never logged in are inactive if they were created before it
+	getInactiveUsersQuery = "SELECT " + userColumns + " FROM user WHERE status = ? AND COALESCE(lastLogin, createdAt) < ? ORDER BY id"
+	emailInUseQuery       = "SELECT COUNT(*) FROM user WHERE email = ? AND id != ?"
+	// accountUsersQuery counts the other users of the account the user is the primary user of
+	// accountUsersQuery and accountLinksQuery count a user's dependencies, see GetUserDependencies. They
+	// lock the rows they read so, in a transaction, the dependencies can't change until it ends.
+	accountUsersQuery = "SELECT COUNT(*) FROM user u JOIN user o ON o.accountID = u.accountID AND o.id != u.id WHERE u.id = ? AND u.role = ? FOR UPDATE"
+	accountLinksQuery = "SELECT COUNT(*) FROM accountUser a JOIN user u ON u.id = a.userID WHERE a.userID = ? FOR UPDATE"
+	// getCredentialsQuery selects a user, and their password hash, by email address, see credentialsRow
+	getCredentialsQuery    = "SELECT " + userColumns + ", password FROM user WHERE email = ?"
+	insertUserStmt         = "INSERT INTO user (accountID, name, email, role, password, status, activationToken, activationExpiry, createdAt, updatedAt, lastModifiedBy) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	insertUserWithIDStmt   = "INSERT INTO user (id, accountID, name, email, role, password, status, activationToken, activationExpiry, createdAt, updatedAt, lastModifiedBy) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	updateUserStmt         = "UPDATE user SET id = ?, accountID = ?, name = ?, email = ?, role = ?, password = ?, updatedAt = ?, lastModifiedBy = ? WHERE id = ?"
+	deleteUserStmt         = "DELETE FROM user WHERE id = ?"
+	activateUserStmt       = "UPDATE user SET status = ?, activationToken = NULL, updatedAt = ? WHERE id = ? AND status = ? AND activationToken = ? AND activationExpiry > ?"
+	deleteExpiredUsersStmt = "DELETE FROM user WHERE status = ? AND activationExpiry < ?"
+	getAccountRolesQuery   = "SELECT id, role FROM user WHERE accountID = ? FOR UPDATE"
+	updateRoleStmt         = "UPDATE user SET role = ?, updatedAt = ? WHERE id = ?"
+	lockUserRoleQuery      = "SELECT accountID, role FROM user WHERE id = ? FOR UPDATE"
+	// recordLoginStmt doesn't change updatedAt, logging in doesn't modify the user
+	recordLoginStmt = "UPDATE user SET lastLogin = ? WHERE id = ?"
+	// lockEmailQuery locks the user with an email address, or the gap it would be inserted in, until an upsert commits
+	lockEmailQuery = "SELECT id, accountID FROM user WHERE email = ? FOR UPDATE"
+	// upsertUserClause updates an existing user's updatedAt and lastModifiedBy only if its name, role, or password
+	// change. Assignments are made in order, so they're compared before the other columns are assigned. MySQL
+	// reports 0 rows affected when nothing changed, 1 when the user was inserted, and 2 when it was updated.
+	upsertUserClause = " ON DUPLICATE KEY UPDATE updatedAt = IF(name = VALUES(name) AND role = VALUES(role) AND password = VALUES(password), updatedAt, VALUES(updatedAt)), " +
+		"lastModifiedBy = IF(name = VALUES(name) AND role = VALUES(role) AND password = VALUES(password), lastModifiedBy, VALUES(lastModifiedBy)), " +
+		"name = VALUES(name), role = VALUES(role), password = VALUES(password)"
+	upsertUserStmt       = insertUserStmt + upsertUserClause
+	upsertUserWithIDStmt = insertUserWithIDStmt + upsertUserClause
 )
 
 // Table supports CRUD access to the 'user' table
 type Table struct {
 	db *sql.DB
+	// tx is only set when the Table is part of a UnitOfWork, see WithTx
+	tx *sql.Tx
+	// ctx is only set when the Table is bound to a request, see WithContext
+	ctx context.Context
+	// clock provides the time used for user timestamps and activation expiry
+	clock clock.Clock
+	// idGen assigns the IDs of new users if it's set, otherwise the 'user' table's AUTO_INCREMENT does
+	idGen idgen.Generator
 }
 
-// NewTable creates a new UserTbl instance with the provided sql.DB instance
+// NewTable creates a new UserTbl instance with the provided sql.DB instance. The table
+// uses clock.System, see SetClock.
 func NewTable(db *sql.DB) (*Table, error) {
 	if db == nil {
 		return nil, errors.New("non-nil sql.DB connection required")
 	}
-	return &Table{db: db}, nil
+	return &Table{db: db, clock: clock.System}, nil
+}
+
+// SetClock replaces the Clock used for user timestamps and activation expiry. 'c' must be non-nil.
+func (ut *Table) SetClock(c clock.Clock) error {
+	if c == nil {
+		return errors.New("non-nil Clock required")
+	}
+	ut.clock = c
+	return nil
 }
 
-// GetUsers will return all users known to the application
+// SetIDGenerator makes 'g' assign the IDs of new users, keyed by email address, rather than the
+// 'user' table's AUTO_INCREMENT. 'g' must be non-nil.
+func (ut *Table) SetIDGenerator(g idgen.Generator) error {
+	if g == nil {
+		return errors.New("non-nil Generator required")
+	}
+	ut.idGen = g
+	return nil
+}
+
+// WithTx returns a copy of the Table whose operations are performed within 'tx'. The
+// caller is responsible for committing or rolling back 'tx', see UnitOfWork.
+func (ut *Table) WithTx(tx *sql.Tx) *Table {
+	t := *ut
+	t.tx = tx
+	return &t
+}
+
+// WithContext returns a copy of the Table whose operations are abandoned once 'ctx' is done, e.g.,
+// when the deadline of the request it's bound to passes. A transaction begun by an abandoned
+// operation is rolled back.
+func (ut *Table) WithContext(ctx context.Context) domain.UserRepository {
+	t := *ut
+	t.ctx = ctx
+	return &t
+}
+
+// context returns the context the Table is bound to, if any, otherwise context.Background
+func (ut *Table) context() context.Context {
+	if ut.ctx != nil {
+		return ut.ctx
+	}
+	return context.Background()
+}
+
+// conn returns the transaction the Table is bound to, if any, otherwise the sql.DB
+func (ut *Table) conn() querier {
+	if ut.tx != nil {
+		return ut.tx
+	}
+	return ut.db
+}
+
+// timestamp returns the current time as stored in the 'createdAt' and 'updatedAt' columns. DATETIME
+// columns don't have fractional seconds so it's truncated to avoid MySQL rounding it.
+func (ut *Table) timestamp() time.Time {
+	return ut.clock.Now().Truncate(time.Second)
+}
+
+// GetUsers will return all active users known to the application. Pending users, i.e.,
+// those that haven't been activated, aren't included.
 func (ut *Table) GetUsers() (*domain.Users, *mverr.MVError) {
+	return ut.queryUsers(ut.context(), false, readAll, getAllUsersQuery, domain.Active)
+}
+
+// GetUsersWithin returns the users returned by GetUsers, abandoning the query once 'qt.Timeout'
+// has elapsed. The users scanned before then are returned, with Users.Truncated set, if
+// 'qt.Partial', otherwise a QueryTimeoutErrorCode error is returned.
+func (ut *Table) GetUsersWithin(qt domain.QueryTimeout) (*domain.Users, *mverr.MVError) {
+	if qt.Timeout <= 0 {
+		return ut.GetUsers()
+	}
+	ctx, cancel := context.WithTimeout(ut.context(), qt.Timeout)
+	defer cancel()
+	return ut.queryUsers(ctx, qt.Partial, readAll, getAllUsersQuery, domain.Active)
+}
+
+// GetUsersPage returns, ordered by ID, up to 'limit' of the users returned by GetUsers whose
+// ID is greater than 'afterID'. Unlike paging with an offset, users created or deleted while
+// the users are paged through don't cause other users to be skipped or returned twice.
+func (ut *Table) GetUsersPage(afterID, limit int) (*domain.Users, *mverr.MVError) {
+	return ut.queryUsers(ut.context(), false, readPage, getUsersPageQuery, domain.Active, afterID, limit)
+}
+
+// SearchUsers returns, ordered by ID, up to 'limit' of the users returned by GetUsers whose name or
+// email address contains 'query'. The comparison ignores case, per the columns' collation.
+func (ut *Table) SearchUsers(query string, limit int) (*domain.Users, *mverr.MVError) {
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+	return ut.queryUsers(ut.context(), false, search, searchUsersQuery, domain.Active, pattern, pattern, limit)
+}
+
+// GetInactiveUsers returns, ordered by ID, the active users that haven't logged in since 'since'.
+// Users that have never logged in are included if they were created before 'since'.
+func (ut *Table) GetInactiveUsers(since time.Time) (*domain.Users, *mverr.MVError) {
+	return ut.queryUsers(ut.context(), false, readInactive, getInactiveUsersQuery, domain.Active, since)
+}
+
+// likeEscaper escapes the LIKE wildcards, and the escape character itself, so they're matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// queryUsers returns the users selected by 'query'. 'operation' is the DBRqstDur operation label.
+// If 'ctx' expires before all the users have been scanned the users scanned so far are returned,
+// marked as truncated, if 'partial', otherwise a QueryTimeoutErrorCode error is returned.
+func (ut *Table) queryUsers(ctx context.Context, partial bool, operation, query string, args ...interface{}) (*domain.Users, *mverr.MVError) {
 	start := time.Now()
 
-	results, err := ut.db.Query(getAllUsersQuery)
+	us := domain.Users{}
+	results, err := ut.conn().QueryContext(ctx, query, args...)
 	if err != nil {
-		DBRqstDur.WithLabelValues(userTbl, readAll, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		if ctx.Err() != nil {
+			return ut.queryTimedOut(&us, partial, operation, start, err)
+		}
+		DBRqstDur.WithLabelValues(userTbl, operation, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
 		return nil, &mverr.MVError{
 			ErrCode:    mverr.UserRqstErrorCode,
 			ErrMsg:     mverr.UserRqstErrorMsg,
 			ErrDetail:  "error querying users",
 			WrappedErr: err}
 	}
+	defer results.Close()
 
-	us := domain.Users{}
 	for results.Next() {
 		var u domain.User
 
-		err = results.Scan(&u.AccountID,
-			&u.ID,
-			&u.Name,
-			&u.EMail,
-			&u.Role)
+		err = scanUser(results, &u)
 		if err != nil {
-			DBRqstDur.WithLabelValues(userTbl, readAll, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+			DBRqstDur.WithLabelValues(userTbl, operation, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
 			return nil, &mverr.MVError{
 				ErrCode:    mverr.UserRqstErrorCode,
 				ErrMsg:     mverr.UserRqstErrorMsg,
@@ -100,24 +319,106 @@ func (ut *Table) GetUsers() (*domain.Users, *mverr.MVError) {
 
 		us.Users = append(us.Users, &u)
 	}
+	if err = results.Err(); err != nil {
+		if ctx.Err() != nil {
+			return ut.queryTimedOut(&us, partial, operation, start, err)
+		}
+		DBRqstDur.WithLabelValues(userTbl, operation, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return nil, &mverr.MVError{
+			ErrCode:    mverr.UserRqstErrorCode,
+			ErrMsg:     mverr.UserRqstErrorMsg,
+			ErrDetail:  "error reading users query result set",
+			WrappedErr: err}
+	}
 
-	DBRqstDur.WithLabelValues(userTbl, readAll, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	DBRqstDur.WithLabelValues(userTbl, operation, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	observeResultSet(userTbl, operation, len(us.Users), usersBytes(us.Users))
 
 	return &us, nil
 }
 
+// scanUser scans the userColumns of 'row' into 'u'. The lastLogin and lastModifiedBy columns are
+// NULL for users that have never logged in or were last modified before they were recorded.
+func scanUser(row scanner, u *domain.User) error {
+	var lastLogin sql.NullTime
+	var lastModifiedBy sql.NullString
+	err := row.Scan(&u.AccountID,
+		&u.ID,
+		&u.Name,
+		&u.EMail,
+		&u.Role,
+		&u.Status,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+		&lastLogin,
+		&lastModifiedBy)
+	if err != nil {
+		return err
+	}
+	if lastLogin.Valid {
+		u.LastLogin = &lastLogin.Time
+	}
+	u.LastModifiedBy = lastModifiedBy.String
+	return nil
+}
+
+// nullString returns 's', or nil, i.e., NULL, if it's empty
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// queryTimedOut returns the result of a users query that timed out with 'err', 'us' are the users
+// scanned before it did. They're returned, marked as truncated, if 'partial'.
+func (ut *Table) queryTimedOut(us *domain.Users, partial bool, operation string, start time.Time, err error) (*domain.Users, *mverr.MVError) {
+	DBRqstDur.WithLabelValues(userTbl, operation, timedOut).Observe(float64(time.Since(start)) / float64(time.Second))
+	if partial {
+		observeResultSet(userTbl, operation, len(us.Users), usersBytes(us.Users))
+		us.Truncated = true
+		return us, nil
+	}
+	return nil, &mverr.MVError{
+		ErrCode:    mverr.QueryTimeoutErrorCode,
+		ErrMsg:     mverr.QueryTimeoutErrorMsg,
+		ErrDetail:  fmt.Sprintf("users query timed out after %d users were read", len(us.Users)),
+		WrappedErr: err}
+}
+
+// UsersVersion returns the latest update time and the number of the users returned by GetUsers.
+// It's much cheaper than GetUsers so it can be used to determine if the users have changed.
+func (ut *Table) UsersVersion() (*domain.UsersVersion, *mverr.MVError) {
+	start := time.Now()
+
+	var lastModified sql.NullTime
+	v := &domain.UsersVersion{}
+	err := ut.conn().QueryRowContext(ut.context(), getUsersVersionQuery, domain.Active).Scan(&lastModified, &v.Count)
+	if err != nil {
+		DBRqstDur.WithLabelValues(userTbl, readVersion, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return nil, &mverr.MVError{
+			ErrCode:    mverr.UserRqstErrorCode,
+			ErrMsg:     mverr.UserRqstErrorMsg,
+			ErrDetail:  "error querying users version",
+			WrappedErr: err}
+	}
+	// MAX() is NULL if there aren't any users
+	if lastModified.Valid {
+		v.LastModified = lastModified.Time
+	}
+
+	DBRqstDur.WithLabelValues(userTbl, readVersion, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return v, nil
+}
+
 // GetUser will return the user identified by 'id' or a nil user if there
 // wasn't a matching user.
 func (ut *Table) GetUser(id int) (*domain.User, *mverr.MVError) {
 	start := time.Now()
 
-	row := ut.db.QueryRow(getUserQuery, id)
+	row := ut.conn().QueryRowContext(ut.context(), getUserQuery, id)
 	user := &domain.User{}
-	err := row.Scan(&user.AccountID,
-		&user.ID,
-		&user.Name,
-		&user.EMail,
-		&user.Role)
+	err := scanUser(row, user)
 	if err != nil && err != sql.ErrNoRows {
 		DBRqstDur.WithLabelValues(userTbl, readOne, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
 		return nil, &mverr.MVError{
@@ -135,7 +436,101 @@ func (ut *Table) GetUser(id int) (*domain.User, *mverr.MVError) {
 	return user, nil
 }
 
+// EmailInUse returns true if a user, of any status, other than the one identified by 'exceptID'
+// has the email address 'email'
+func (ut *Table) EmailInUse(email string, exceptID int) (bool, *mverr.MVError) {
+	start := time.Now()
+
+	var count int
+	err := ut.conn().QueryRowContext(ut.context(), emailInUseQuery, email, exceptID).Scan(&count)
+	if err != nil {
+		DBRqstDur.WithLabelValues(userTbl, readEmail, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return false, &mverr.MVError{
+			ErrCode:    mverr.UserRqstErrorCode,
+			ErrMsg:     mverr.UserRqstErrorMsg,
+			ErrDetail:  "error querying users by email address",
+			WrappedErr: err}
+	}
+
+	DBRqstDur.WithLabelValues(userTbl, readEmail, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return count > 0, nil
+}
+
+// GetCredentials returns the user, of any status, with the email address 'email' and the user's
+// password hash, or a nil user if there wasn't a matching user
+func (ut *Table) GetCredentials(email string) (*domain.User, string, *mverr.MVError) {
+	start := time.Now()
+
+	var hash sql.NullString
+	row := ut.conn().QueryRowContext(ut.context(), getCredentialsQuery, email)
+	user := &domain.User{}
+	err := scanUser(credentialsRow{row: row, hash: &hash}, user)
+	if err != nil && err != sql.ErrNoRows {
+		DBRqstDur.WithLabelValues(userTbl, readCredentials, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return nil, "", &mverr.MVError{
+			ErrCode:    mverr.UserRqstErrorCode,
+			ErrMsg:     mverr.UserRqstErrorMsg,
+			ErrDetail:  "error scanning user credentials row",
+			WrappedErr: err}
+	}
+	if err == sql.ErrNoRows {
+		DBRqstDur.WithLabelValues(userTbl, readCredentials, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+		return nil, "", nil
+	}
+
+	DBRqstDur.WithLabelValues(userTbl, readCredentials, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return user, hash.String, nil
+}
+
+// credentialsRow is a row selected by getCredentialsQuery, the password column following the
+// userColumns scanned by scanUser is scanned into 'hash'
+type credentialsRow struct {
+	row  scanner
+	hash *sql.NullString
+}
+
+func (cr credentialsRow) Scan(dest ...interface{}) error {
+	return cr.row.Scan(append(dest, cr.hash)...)
+}
+
+// GetUserDependencies returns the records that prevent the user identified by 'id' from being deleted,
+// i.e., the other users of the account the user is the primary user of and the accountUser records
+// associating the user with an account. The userCreateQueue's userID only records the user a completed
+// queued creation created, it doesn't prevent the user from being deleted.
+func (ut *Table) GetUserDependencies(id int) ([]domain.UserDependency, *mverr.MVError) {
+	start := time.Now()
+
+	deps := []domain.UserDependency{}
+	for _, q := range []struct {
+		kind  string
+		query string
+		args  []interface{}
+	}{
+		{kind: domain.DependentAccountUsers, query: accountUsersQuery, args: []interface{}{id, domain.Primary}},
+		{kind: domain.DependentAccountLinks, query: accountLinksQuery, args: []interface{}{id}},
+	} {
+		var count int
+		err := ut.conn().QueryRowContext(ut.context(), q.query, q.args...).Scan(&count)
+		if err != nil {
+			DBRqstDur.WithLabelValues(userTbl, readDependencies, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+			return nil, &mverr.MVError{
+				ErrCode:    mverr.UserRqstErrorCode,
+				ErrMsg:     mverr.UserRqstErrorMsg,
+				ErrDetail:  fmt.Sprintf("error querying the %s dependencies of user %d", q.kind, id),
+				WrappedErr: err}
+		}
+		if count > 0 {
+			deps = append(deps, domain.UserDependency{Kind: q.kind, Count: count})
+		}
+	}
+
+	DBRqstDur.WithLabelValues(userTbl, readDependencies, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return deps, nil
+}
+
 // CreateUser takes the provided user data, inserts it into the db, and returns the newly created user ID.
+// A user without a Status is created as an Active user. The user's CreatedAt and UpdatedAt are set to
+// the current time, any values in 'u' are ignored.
 func (ut *Table) CreateUser(u domain.User) (int, *mverr.MVError) {
 	start := time.Now()
 
@@ -149,26 +544,51 @@ func (ut *Table) CreateUser(u domain.User) (int, *mverr.MVError) {
 			WrappedErr: err}
 	}
 
-	r, err := ut.db.Exec(insertUserStmt, u.AccountID, u.Name, u.EMail, u.Role, u.Password)
-	if err != nil {
-		errDetail, ok := err.(*mysql.MySQLError)
-		if ok {
-			if errDetail.Number == mverr.MySQLDupInsertErrorCode {
-				DBRqstDur.WithLabelValues(userTbl, create, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
-				return 0, &mverr.MVError{
-					ErrCode:    mverr.DBInsertDuplicateUserErrorCode,
-					ErrMsg:     mverr.DBInsertDuplicateUserErrorMsg,
-					ErrDetail:  fmt.Sprintf("error inserting duplicate user into the database, possible duplicate email address: User name: %s, User email: %s", u.Name, u.EMail),
-					WrappedErr: err}
-			}
-		} else {
+	status := u.Status
+	if status == "" {
+		status = domain.Active
+	}
+	var expiry interface{}
+	if !u.ActivationExpiry.IsZero() {
+		expiry = u.ActivationExpiry
+	}
+
+	now := ut.timestamp()
+	var r sql.Result
+	genID := 0
+	if ut.idGen == nil {
+		r, err = ut.conn().ExecContext(ut.context(), insertUserStmt, u.AccountID, u.Name, u.EMail, u.Role, u.Password, status, u.ActivationToken, expiry, now, now, nullString(u.LastModifiedBy))
+	} else {
+		genID, err = ut.idGen.NextID(u.EMail)
+		if err != nil {
 			DBRqstDur.WithLabelValues(userTbl, create, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
 			return 0, &mverr.MVError{
 				ErrCode:    mverr.DBUpSertErrorCode,
 				ErrMsg:     mverr.DBUpSertErrorMsg,
-				ErrDetail:  fmt.Sprintf("error inserting user %+v into DB", u),
+				ErrDetail:  fmt.Sprintf("unable to assign an ID to user %s", u.EMail),
 				WrappedErr: err}
 		}
+		r, err = ut.conn().ExecContext(ut.context(), insertUserWithIDStmt, genID, u.AccountID, u.Name, u.EMail, u.Role, u.Password, status, u.ActivationToken, expiry, now, now, nullString(u.LastModifiedBy))
+	}
+	if err != nil {
+		DBRqstDur.WithLabelValues(userTbl, create, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		errDetail, ok := err.(*mysql.MySQLError)
+		if ok && errDetail.Number == mverr.MySQLDupInsertErrorCode {
+			return 0, &mverr.MVError{
+				ErrCode:    mverr.DBInsertDuplicateUserErrorCode,
+				ErrMsg:     mverr.DBInsertDuplicateUserErrorMsg,
+				ErrDetail:  fmt.Sprintf("error inserting duplicate user into the database, possible duplicate email address: User name: %s, User email: %s", u.Name, u.EMail),
+				WrappedErr: err}
+		}
+		return 0, &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error inserting user %+v into DB", u),
+			WrappedErr: err}
+	}
+	if ut.idGen != nil {
+		DBRqstDur.WithLabelValues(userTbl, create, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+		return genID, nil
 	}
 	id, err := r.LastInsertId()
 	if err != nil {
@@ -186,7 +606,128 @@ func (ut *Table) CreateUser(u domain.User) (int, *mverr.MVError) {
 	return int(id), nil
 }
 
-// UpdateUser takes the provided user data, inserts it into the db, and returns the newly created user ID
+// UpsertUser creates 'u', as CreateUser does, or updates the name, role, and password of the existing user
+// with the same email address using a single INSERT ... ON DUPLICATE KEY UPDATE. The existing user's row,
+// or the gap a new user would be inserted into, is locked until the upsert commits so that a user in
+// another account is never updated, a DBInsertDuplicateUserErrorCode error is returned instead.
+func (ut *Table) UpsertUser(u domain.User) (int, domain.UpsertOutcome, *mverr.MVError) {
+	start := time.Now()
+	fail := func(mvErr *mverr.MVError) (int, domain.UpsertOutcome, *mverr.MVError) {
+		DBRqstDur.WithLabelValues(userTbl, upsert, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return 0, "", mvErr
+	}
+
+	err := u.ValidateUser()
+	if err != nil {
+		return fail(&mverr.MVError{
+			ErrCode:    mverr.UserValidationErrorCode,
+			ErrMsg:     mverr.UserValidationErrorMsg,
+			ErrDetail:  err.Error(),
+			WrappedErr: err})
+	}
+
+	tx, err := beginTxn(ut.context(), ut.db, ut.tx)
+	if err != nil {
+		return fail(&mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error beginning transaction to upsert user %s", u.EMail),
+			WrappedErr: err})
+	}
+	var existingID, existingAccountID int
+	err = tx.QueryRowContext(ut.context(), lockEmailQuery, u.EMail).Scan(&existingID, &existingAccountID)
+	if err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		return fail(&mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error finding user %s to upsert", u.EMail),
+			WrappedErr: err})
+	}
+	if existingID != 0 && existingAccountID != u.AccountID {
+		tx.Rollback()
+		return fail(&mverr.MVError{
+			ErrCode:   mverr.DBInsertDuplicateUserErrorCode,
+			ErrMsg:    mverr.DBInsertDuplicateUserErrorMsg,
+			ErrDetail: fmt.Sprintf("error upserting user, email %s is in use in another account", u.EMail)})
+	}
+
+	status := u.Status
+	if status == "" {
+		status = domain.Active
+	}
+	var expiry interface{}
+	if !u.ActivationExpiry.IsZero() {
+		expiry = u.ActivationExpiry
+	}
+	now := ut.timestamp()
+	var r sql.Result
+	genID := 0
+	if ut.idGen == nil || existingID != 0 {
+		r, err = tx.ExecContext(ut.context(), upsertUserStmt, u.AccountID, u.Name, u.EMail, u.Role, u.Password, status, u.ActivationToken, expiry, now, now, nullString(u.LastModifiedBy))
+	} else {
+		genID, err = ut.idGen.NextID(u.EMail)
+		if err != nil {
+			tx.Rollback()
+			return fail(&mverr.MVError{
+				ErrCode:    mverr.DBUpSertErrorCode,
+				ErrMsg:     mverr.DBUpSertErrorMsg,
+				ErrDetail:  fmt.Sprintf("unable to assign an ID to user %s", u.EMail),
+				WrappedErr: err})
+		}
+		r, err = tx.ExecContext(ut.context(), upsertUserWithIDStmt, genID, u.AccountID, u.Name, u.EMail, u.Role, u.Password, status, u.ActivationToken, expiry, now, now, nullString(u.LastModifiedBy))
+	}
+	if err != nil {
+		tx.Rollback()
+		return fail(&mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error upserting user %s", u.EMail),
+			WrappedErr: err})
+	}
+	affected, err := r.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return fail(&mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("unable to determine if user %s was created or updated", u.EMail),
+			WrappedErr: err})
+	}
+
+	id, outcome := existingID, domain.UpsertUpdated
+	switch {
+	case existingID != 0 && affected == 0:
+		outcome = domain.UpsertSkipped
+	case existingID == 0 && genID != 0:
+		id, outcome = genID, domain.UpsertCreated
+	case existingID == 0:
+		lastID, err := r.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return fail(&mverr.MVError{
+				ErrCode:    mverr.DBUpSertErrorCode,
+				ErrMsg:     mverr.DBUpSertErrorMsg,
+				ErrDetail:  "unable to obtain upserted user's assigned ID",
+				WrappedErr: err})
+		}
+		id, outcome = int(lastID), domain.UpsertCreated
+	}
+	if err = tx.Commit(); err != nil {
+		return fail(&mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error committing upsert of user %s", u.EMail),
+			WrappedErr: err})
+	}
+
+	DBRqstDur.WithLabelValues(userTbl, upsert, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return id, outcome, nil
+}
+
+// UpdateUser takes the provided user data and updates the matching user in the db. The user's UpdatedAt
+// is set to the current time, u.CreatedAt and u.UpdatedAt are ignored. A user is never created, a
+// DBNoUserErrorCode error is returned if there isn't a matching user.
 func (ut *Table) UpdateUser(u domain.User) *mverr.MVError {
 	start := time.Now()
 
@@ -200,9 +741,9 @@ func (ut *Table) UpdateUser(u domain.User) *mverr.MVError {
 			WrappedErr: err}
 	}
 
-	// This entire db.Begin/tx.Rollback/Commit seem awkward to me. But it's here because
-	// MySQL silently performs an insert if there is no row to update.
-	tx, err := ut.db.Begin()
+	// The user's row is locked until the update commits so the user can't be deleted, e.g., by a
+	// concurrent DELETE, between confirming it exists and updating it.
+	tx, err := beginTxn(ut.context(), ut.db, ut.tx)
 	if err != nil {
 		DBRqstDur.WithLabelValues(userTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
 		return &mverr.MVError{
@@ -211,13 +752,9 @@ func (ut *Table) UpdateUser(u domain.User) *mverr.MVError {
 			ErrDetail:  fmt.Sprintf("error beginning transaction for user %+v", u),
 			WrappedErr: err}
 	}
-	r := ut.db.QueryRow(getUserQuery, u.ID)
+	r := tx.QueryRowContext(ut.context(), lockUserQuery, u.ID)
 	userRow := domain.User{}
-	err = r.Scan(&userRow.AccountID,
-		&userRow.ID,
-		&userRow.Name,
-		&userRow.EMail,
-		&userRow.Role)
+	err = scanUser(r, &userRow)
 
 	if err != nil && err == sql.ErrNoRows {
 		tx.Rollback()
@@ -238,7 +775,7 @@ func (ut *Table) UpdateUser(u domain.User) *mverr.MVError {
 			WrappedErr: err}
 	}
 
-	_, err = ut.db.Exec(updateUserStmt, u.ID, u.AccountID, u.Name, u.EMail, u.Role, u.Password, u.ID)
+	_, err = tx.ExecContext(ut.context(), updateUserStmt, u.ID, u.AccountID, u.Name, u.EMail, u.Role, u.Password, ut.timestamp(), nullString(u.LastModifiedBy), u.ID)
 	if err != nil {
 		tx.Rollback()
 		DBRqstDur.WithLabelValues(userTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
@@ -254,20 +791,325 @@ func (ut *Table) UpdateUser(u domain.User) *mverr.MVError {
 	return nil
 }
 
-// DeleteUser deletes the user identified by u.id from the database
-func (ut *Table) DeleteUser(id int) *mverr.MVError {
+// RecordLogin sets the lastLogin of the user identified by 'id' to the current time, its updatedAt
+// is unchanged. Recording the login of a user that doesn't exist isn't an error.
+func (ut *Table) RecordLogin(id int) *mverr.MVError {
 	start := time.Now()
 
-	_, err := ut.db.Exec(deleteUserStmt, id)
+	_, err := ut.conn().ExecContext(ut.context(), recordLoginStmt, ut.timestamp(), id)
 	if err != nil {
-		DBRqstDur.WithLabelValues(userTbl, delete, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		DBRqstDur.WithLabelValues(userTbl, recordLogin, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
 		return &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error recording the login of user %d", id),
+			WrappedErr: err}
+	}
+
+	DBRqstDur.WithLabelValues(userTbl, recordLogin, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return nil
+}
+
+// DeleteUser deletes the user identified by 'id' from the database. It returns whether the user
+// existed. Deleting a user that doesn't exist isn't an error, so a DELETE can be safely retried.
+func (ut *Table) DeleteUser(id int) (bool, *mverr.MVError) {
+	start := time.Now()
+
+	r, err := ut.conn().ExecContext(ut.context(), deleteUserStmt, id)
+	if err != nil {
+		DBRqstDur.WithLabelValues(userTbl, delete, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return false, &mverr.MVError{
 			ErrCode:    mverr.DBDeleteErrorCode,
 			ErrMsg:     mverr.DBDeleteErrorMsg,
 			ErrDetail:  fmt.Sprintf("error deleting  user id %d", id),
 			WrappedErr: err}
 	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		DBRqstDur.WithLabelValues(userTbl, delete, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return false, &mverr.MVError{
+			ErrCode:    mverr.DBDeleteErrorCode,
+			ErrMsg:     mverr.DBDeleteErrorMsg,
+			ErrDetail:  fmt.Sprintf("unable to determine whether user id %d was deleted", id),
+			WrappedErr: err}
+	}
 
 	DBRqstDur.WithLabelValues(userTbl, delete, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return n > 0, nil
+}
+
+// DeleteUserWithSuccessor deletes the primary user identified by 'id' and makes the user identified
+// by 'successorID' its account's primary user in a single transaction. Either both happen or
+// neither does. It returns whether the user existed, deleting a user that doesn't exist isn't an
+// error. The change is rejected if the user isn't a primary user or if the successor isn't another
+// user of the same account.
+func (ut *Table) DeleteUserWithSuccessor(id, successorID int) (bool, *mverr.MVError) {
+	start := time.Now()
+
+	tx, err := beginTxn(ut.context(), ut.db, ut.tx)
+	if err != nil {
+		DBRqstDur.WithLabelValues(userTbl, delete, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return false, &mverr.MVError{
+			ErrCode:    mverr.DBDeleteErrorCode,
+			ErrMsg:     mverr.DBDeleteErrorMsg,
+			ErrDetail:  fmt.Sprintf("error beginning transaction for deletion of user %d", id),
+			WrappedErr: err}
+	}
+
+	var accountID int
+	var role domain.Role
+	err = tx.QueryRowContext(ut.context(), lockUserRoleQuery, id).Scan(&accountID, &role)
+	if err == sql.ErrNoRows {
+		// DELETE is idempotent, the user may already have been deleted
+		tx.Rollback()
+		DBRqstDur.WithLabelValues(userTbl, delete, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+		return false, nil
+	}
+	if err != nil {
+		tx.Rollback()
+		DBRqstDur.WithLabelValues(userTbl, delete, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return false, &mverr.MVError{
+			ErrCode:    mverr.DBQueryErrorCode,
+			ErrMsg:     mverr.UserRqstErrorMsg,
+			ErrDetail:  fmt.Sprintf("error getting user %d", id),
+			WrappedErr: err}
+	}
+	if role != domain.Primary {
+		tx.Rollback()
+		DBRqstDur.WithLabelValues(userTbl, delete, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return false, &mverr.MVError{
+			ErrCode:   mverr.InvalidSuccessorErrorCode,
+			ErrMsg:    mverr.InvalidSuccessorErrorMsg,
+			ErrDetail: fmt.Sprintf("user %d isn't the primary user of account %d, it doesn't need a successor", id, accountID)}
+	}
+
+	var successorAccountID int
+	err = tx.QueryRowContext(ut.context(), lockUserRoleQuery, successorID).Scan(&successorAccountID, &role)
+	if err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		DBRqstDur.WithLabelValues(userTbl, delete, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return false, &mverr.MVError{
+			ErrCode:    mverr.DBQueryErrorCode,
+			ErrMsg:     mverr.UserRqstErrorMsg,
+			ErrDetail:  fmt.Sprintf("error getting successor %d", successorID),
+			WrappedErr: err}
+	}
+	if err == sql.ErrNoRows || successorID == id || successorAccountID != accountID {
+		tx.Rollback()
+		DBRqstDur.WithLabelValues(userTbl, delete, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return false, &mverr.MVError{
+			ErrCode:   mverr.InvalidSuccessorErrorCode,
+			ErrMsg:    mverr.InvalidSuccessorErrorMsg,
+			ErrDetail: fmt.Sprintf("successor %d is not another user of account %d", successorID, accountID)}
+	}
+
+	if _, err = tx.ExecContext(ut.context(), updateRoleStmt, domain.Primary, ut.timestamp(), successorID); err != nil {
+		tx.Rollback()
+		DBRqstDur.WithLabelValues(userTbl, delete, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return false, &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error making user %d the primary user of account %d", successorID, accountID),
+			WrappedErr: err}
+	}
+	if _, err = tx.ExecContext(ut.context(), deleteUserStmt, id); err != nil {
+		tx.Rollback()
+		DBRqstDur.WithLabelValues(userTbl, delete, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return false, &mverr.MVError{
+			ErrCode:    mverr.DBDeleteErrorCode,
+			ErrMsg:     mverr.DBDeleteErrorMsg,
+			ErrDetail:  fmt.Sprintf("error deleting user id %d", id),
+			WrappedErr: err}
+	}
+
+	if err = tx.Commit(); err != nil {
+		DBRqstDur.WithLabelValues(userTbl, delete, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return false, &mverr.MVError{
+			ErrCode:    mverr.DBDeleteErrorCode,
+			ErrMsg:     mverr.DBDeleteErrorMsg,
+			ErrDetail:  fmt.Sprintf("error committing deletion of user %d", id),
+			WrappedErr: err}
+	}
+
+	DBRqstDur.WithLabelValues(userTbl, delete, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return true, nil
+}
+
+// ActivateUser changes the pending user identified by 'id' to an active user. 'token' must match
+// the user's activation token and the token must not have expired.
+func (ut *Table) ActivateUser(id int, token string) *mverr.MVError {
+	start := time.Now()
+
+	r, err := ut.conn().ExecContext(ut.context(), activateUserStmt, domain.Active, ut.timestamp(), id, domain.Pending, token, ut.clock.Now())
+	if err != nil {
+		DBRqstDur.WithLabelValues(userTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error activating user id %d", id),
+			WrappedErr: err}
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		DBRqstDur.WithLabelValues(userTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("unable to determine if user id %d was activated", id),
+			WrappedErr: err}
+	}
+	if n == 0 {
+		// Don't distinguish between a non-existent user, an already active user, and a bad
+		// or expired token. Doing so would tell the caller more than it needs to know.
+		DBRqstDur.WithLabelValues(userTbl, update, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+		return &mverr.MVError{
+			ErrCode:   mverr.InvalidActivationErrorCode,
+			ErrMsg:    mverr.InvalidActivationErrorMsg,
+			ErrDetail: fmt.Sprintf("no pending user with id %d and a matching unexpired activation token", id)}
+	}
+
+	DBRqstDur.WithLabelValues(userTbl, update, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return nil
+}
+
+// DeleteExpiredUsers deletes pending users whose activation token has expired. It returns the number
+// of users deleted.
+func (ut *Table) DeleteExpiredUsers() (int, *mverr.MVError) {
+	start := time.Now()
+
+	r, err := ut.conn().ExecContext(ut.context(), deleteExpiredUsersStmt, domain.Pending, ut.clock.Now())
+	if err != nil {
+		DBRqstDur.WithLabelValues(userTbl, delete, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return 0, &mverr.MVError{
+			ErrCode:    mverr.DBDeleteErrorCode,
+			ErrMsg:     mverr.DBDeleteErrorMsg,
+			ErrDetail:  "error deleting expired pending users",
+			WrappedErr: err}
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		DBRqstDur.WithLabelValues(userTbl, delete, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return 0, &mverr.MVError{
+			ErrCode:    mverr.DBDeleteErrorCode,
+			ErrMsg:     mverr.DBDeleteErrorMsg,
+			ErrDetail:  "unable to determine the number of expired pending users deleted",
+			WrappedErr: err}
+	}
+
+	DBRqstDur.WithLabelValues(userTbl, delete, ok).Observe(float64(time.Since(start)) / float64(time.Second))
+	return int(n), nil
+}
+
+// UpdateRoles changes the roles of the users in account 'accountID' in a single transaction.
+// 'roles' maps user IDs to their new roles. Either all roles are changed or none are. The
+// change is rejected if any of the users aren't in the account or if the account wouldn't
+// have exactly one primary user afterwards.
+func (ut *Table) UpdateRoles(accountID int, roles map[int]domain.Role) *mverr.MVError {
+	start := time.Now()
+
+	for id, role := range roles {
+		if role != domain.Primary && role != domain.Unrestricted && role != domain.Restricted {
+			DBRqstDur.WithLabelValues(userTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+			return &mverr.MVError{
+				ErrCode:   mverr.UserValidationErrorCode,
+				ErrMsg:    mverr.UserValidationErrorMsg,
+				ErrDetail: fmt.Sprintf("invalid role %d for user %d, role must be one of %d, %d, or %d", role, id, domain.Primary, domain.Unrestricted, domain.Restricted)}
+		}
+	}
+
+	tx, err := beginTxn(ut.context(), ut.db, ut.tx)
+	if err != nil {
+		DBRqstDur.WithLabelValues(userTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error beginning transaction for role update in account %d", accountID),
+			WrappedErr: err}
+	}
+
+	// Lock the account's users so the primary user count can't change underneath us
+	rows, err := tx.QueryContext(ut.context(), getAccountRolesQuery, accountID)
+	if err != nil {
+		tx.Rollback()
+		DBRqstDur.WithLabelValues(userTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return &mverr.MVError{
+			ErrCode:    mverr.DBQueryErrorCode,
+			ErrMsg:     mverr.UserRqstErrorMsg,
+			ErrDetail:  fmt.Sprintf("error getting the users in account %d", accountID),
+			WrappedErr: err}
+	}
+	current := make(map[int]domain.Role)
+	for rows.Next() {
+		var id int
+		var role domain.Role
+		if err = rows.Scan(&id, &role); err != nil {
+			rows.Close()
+			tx.Rollback()
+			DBRqstDur.WithLabelValues(userTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+			return &mverr.MVError{
+				ErrCode:    mverr.DBRowScanErrorCode,
+				ErrMsg:     mverr.DBRowScanErrorMsg,
+				ErrDetail:  fmt.Sprintf("error reading the users in account %d", accountID),
+				WrappedErr: err}
+		}
+		current[id] = role
+	}
+	rows.Close()
+
+	ids := make([]int, 0, len(roles))
+	for id, role := range roles {
+		if _, found := current[id]; !found {
+			tx.Rollback()
+			DBRqstDur.WithLabelValues(userTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+			return &mverr.MVError{
+				ErrCode:   mverr.DBNoUserErrorCode,
+				ErrMsg:    mverr.DBNoUserErrorMsg,
+				ErrDetail: fmt.Sprintf("user %d is not in account %d", id, accountID)}
+		}
+		current[id] = role
+		ids = append(ids, id)
+	}
+
+	primaries := 0
+	for _, role := range current {
+		if role == domain.Primary {
+			primaries++
+		}
+	}
+	if primaries != 1 {
+		tx.Rollback()
+		DBRqstDur.WithLabelValues(userTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return &mverr.MVError{
+			ErrCode:   mverr.InvalidRoleAssignmentErrorCode,
+			ErrMsg:    mverr.InvalidRoleAssignmentErrorMsg,
+			ErrDetail: fmt.Sprintf("role update would leave account %d with %d primary users", accountID, primaries)}
+	}
+
+	// Update in a consistent order to make lock acquisition predictable
+	sort.Ints(ids)
+	now := ut.timestamp()
+	for _, id := range ids {
+		_, err = tx.ExecContext(ut.context(), updateRoleStmt, roles[id], now, id)
+		if err != nil {
+			tx.Rollback()
+			DBRqstDur.WithLabelValues(userTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+			return &mverr.MVError{
+				ErrCode:    mverr.DBUpSertErrorCode,
+				ErrMsg:     mverr.DBUpSertErrorMsg,
+				ErrDetail:  fmt.Sprintf("error updating role for user %d in account %d", id, accountID),
+				WrappedErr: err}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		DBRqstDur.WithLabelValues(userTbl, update, dbErr).Observe(float64(time.Since(start)) / float64(time.Second))
+		return &mverr.MVError{
+			ErrCode:    mverr.DBUpSertErrorCode,
+			ErrMsg:     mverr.DBUpSertErrorMsg,
+			ErrDetail:  fmt.Sprintf("error committing role update in account %d", accountID),
+			WrappedErr: err}
+	}
+
+	DBRqstDur.WithLabelValues(userTbl, update, ok).Observe(float64(time.Since(start)) / float64(time.Second))
 	return nil
 }
@@ -0,0 +1,102 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package demo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/clock"
+	"github.com/youngkin/mockvideo/internal/db/memory"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/policy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Created is when the synthetic users were created, fixed so the dataset is the same every time
+var Created = time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+// accounts are the names of the users of each synthetic account, account N is accounts[N-1]. The
+// first user of an account is its Primary user, the second is Unrestricted, and the rest are
+// Restricted.
+var accounts = [][]string{
+	{"Micky Dolenz", "Davy Jones", "Peter Tork", "Michael Nesmith"},
+	{"Porgy Tirebiter", "Penny Tirebiter"},
+	{"Ada Lovelace", "Charles Babbage", "Mary Somerville"},
+	{"Grace Hopper", "Howard Aiken"},
+	{"Alan Turing"},
+}
+
+// Users returns the synthetic users, ordered by account. Their IDs are assigned by the UserTable.
+func Users() []domain.User {
+	users := []domain.User{}
+	for i, names := range accounts {
+		for j, name := range names {
+			role := domain.Restricted
+			switch j {
+			case 0:
+				role = domain.Primary
+			case 1:
+				role = domain.Unrestricted
+			}
+			users = append(users, domain.User{
+				AccountID: i + 1,
+				Name:      name,
+				EMail:     strings.ToLower(strings.ReplaceAll(name, " ", ".")) + "@example.com",
+				Role:      role,
+				// Passwords are never returned, and a demo user can't log in
+				Password: "demo",
+				Status:   domain.Active,
+			})
+		}
+	}
+	return users
+}
+
+// NewUserTable returns a frozen memory.UserTable holding the synthetic users, see Users
+func NewUserTable() (*memory.UserTable, error) {
+	ut := memory.NewUserTable()
+	if err := ut.SetClock(clock.NewFrozen(Created)); err != nil {
+		return nil, err
+	}
+	for _, u := range Users() {
+		if _, mvErr := ut.CreateUser(u); mvErr != nil {
+			return nil, fmt.Errorf("unable to create demo user %s: %w", u.Name, mvErr)
+		}
+	}
+	ut.Freeze()
+	return ut, nil
+}
+
+// Middleware rejects requests that would change the users, i.e., that require more than the
+// 'users:read' scope, with a 403 (Forbidden) HTTP status
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if policy.RequiredScope(r.Method, r.URL.Path) != auth.ScopeUsersRead {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(mverr.HTTPStatus(mverr.DemoModeErrorCode))
+			w.Write([]byte(mverr.DemoModeErrorMsg))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UnaryServerInterceptor is the gRPC counterpart of Middleware, rejected requests fail with a
+// PermissionDenied status
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if policy.RequiredScope(policy.GRPCMethod, info.FullMethod) != auth.ScopeUsersRead {
+			return nil, status.Error(mverr.GRPCCode(mverr.DemoModeErrorCode), mverr.DemoModeErrorMsg)
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package demo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewUserTable(t *testing.T) {
+	ut, err := NewUserTable()
+	if err != nil {
+		t.Fatalf("error %s was not expected creating the demo UserTable", err)
+	}
+	users, mvErr := ut.GetUsers()
+	if mvErr != nil {
+		t.Fatalf("error %s was not expected getting the demo users", mvErr)
+	}
+	if len(users.Users) != len(Users()) {
+		t.Fatalf("expected %d demo users, got %d", len(Users()), len(users.Users))
+	}
+	primaries := make(map[int]int)
+	for _, u := range users.Users {
+		if !u.CreatedAt.Equal(Created) {
+			t.Errorf("expected user %d to be created at %s, got %s", u.ID, Created, u.CreatedAt)
+		}
+		if u.Role == domain.Primary {
+			primaries[u.AccountID]++
+		}
+	}
+	for account := 1; account <= len(accounts); account++ {
+		if primaries[account] != 1 {
+			t.Errorf("expected account %d to have 1 primary user, got %d", account, primaries[account])
+		}
+	}
+
+	u := users.Users[0]
+	u.Name = "micky"
+	u.Password = "pw"
+	if mvErr = ut.UpdateUser(*u); mvErr == nil || mvErr.ErrCode != mverr.DemoModeErrorCode {
+		t.Errorf("expected error code %d updating a demo user, got %v", mverr.DemoModeErrorCode, mvErr)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tcs := []struct {
+		testName       string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{testName: "testGet", method: http.MethodGet, path: "/users/1", expectedStatus: http.StatusOK},
+		{testName: "testHead", method: http.MethodHead, path: "/users", expectedStatus: http.StatusOK},
+		{testName: "testPost", method: http.MethodPost, path: "/users", expectedStatus: http.StatusForbidden},
+		{testName: "testPut", method: http.MethodPut, path: "/users/1", expectedStatus: http.StatusForbidden},
+		{testName: "testDelete", method: http.MethodDelete, path: "/users/1", expectedStatus: http.StatusForbidden},
+		{testName: "testSignup", method: http.MethodPost, path: "/signup", expectedStatus: http.StatusForbidden},
+		{testName: "testAdmin", method: http.MethodGet, path: "/admin/requests", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			Middleware(ok).ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	interceptor := UnaryServerInterceptor()
+
+	if _, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/accountd.UserServer/GetUser"}, handler); err != nil {
+		t.Errorf("error %s was not expected getting a user", err)
+	}
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/accountd.UserServer/DeleteUser"}, handler)
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected code %s deleting a user, got %v", codes.PermissionDenied, err)
+	}
+}
@@ -0,0 +1,16 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package demo supports running accountd in demo mode, e.g., to demo the service publicly without a
+database or the risk of abuse. In demo mode the users are a built-in synthetic dataset, a few accounts
+of fictional users, served from a memory.UserTable. They can be read but never changed.
+
+NewUserTable returns the frozen UserTable holding the dataset, every write to it fails with a
+DemoModeErrorCode error. Changes are also rejected before they reach the service. Middleware rejects
+HTTP requests, and UnaryServerInterceptor gRPC requests, that require more than the 'users:read'
+scope, see policy.RequiredScope. Rejected HTTP requests get a 403 (Forbidden) response and rejected
+gRPC requests a PermissionDenied status.
+*/
+package demo
@@ -0,0 +1,134 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+// Names of the AccountSummary fields that can be reported in AccountSummary.Unavailable
+const (
+	// SummaryUsers identifies AccountSummary.Users and AccountSummary.RoleCounts
+	SummaryUsers = "users"
+	// SummaryInvoices identifies AccountSummary.OutstandingInvoiceTotal
+	SummaryInvoices = "outstandinginvoicetotal"
+)
+
+// AccountSummary aggregates information about an account from accountd and, when available,
+// other services such as billingd. Fields that couldn't be retrieved are listed in Unavailable
+// and left empty.
+type AccountSummary struct {
+	AccountID  int          `json:"accountid"`
+	HREF       string       `json:"href"`
+	Users      []*User      `json:"users,omitempty"`
+	RoleCounts map[Role]int `json:"rolecounts,omitempty"`
+	// OutstandingInvoiceTotal is nil if billing information isn't available
+	OutstandingInvoiceTotal *float64 `json:"outstandinginvoicetotal,omitempty"`
+	Unavailable             []string `json:"unavailable,omitempty"`
+}
+
+// maxPhoneLen is the maximum length of Account.Phone, see the 'account' table
+const maxPhoneLen = 10
+
+// AccountRepository abstracts the notion of some sort of Account persistent store
+type AccountRepository interface {
+	// CreateAccount inserts 'account' and returns its ID. A DBInsertDuplicateAccountErrorCode
+	// error is returned if there's already an account with 'account.EMail'.
+	CreateAccount(account Account) (id int, err *mverr.MVError)
+}
+
+// Account represents the high level information about a customer. The users who can act on the
+// account are Users with the account's ID.
+type Account struct {
+	ID                int    `json:"id"`
+	HREF              string `json:"href"`
+	AccountHolderName string `json:"accountholdername"`
+	NickName          string `json:"nickname,omitempty"`
+	ServiceAddress    string `json:"serviceaddress"`
+	BillingAddress    string `json:"billingaddress"`
+	EMail             string `json:"email"`
+	Phone             string `json:"phone"`
+	// BillingContact is optional, the account holder is billed at BillingAddress if it's nil
+	BillingContact *BillingContact `json:"billingcontact,omitempty"`
+}
+
+// ValidateAccount will return an error if the Account is not constructed correctly. The address of
+// its BillingContact is validated with a PostalValidator using DefaultPostalFormats.
+func (a *Account) ValidateAccount() error {
+	return a.ValidateAccountWith(defaultAddressValidator)
+}
+
+// ValidateAccountWith is ValidateAccount using 'v' to validate the address of the account's
+// BillingContact
+func (a *Account) ValidateAccountWith(v AddressValidator) error {
+	errMsg := ""
+
+	if len(a.AccountHolderName) == 0 {
+		errMsg = errMsg + "; AccountHolderName must be populated"
+	}
+	if len(a.ServiceAddress) == 0 {
+		errMsg = errMsg + "; ServiceAddress must be populated"
+	}
+	if len(a.BillingAddress) == 0 {
+		errMsg = errMsg + "; BillingAddress must be populated"
+	}
+	if len(a.EMail) == 0 {
+		errMsg = errMsg + "; Email address must be populated"
+	}
+	if len(a.Phone) == 0 || len(a.Phone) > maxPhoneLen {
+		errMsg = errMsg + fmt.Sprintf("; Phone must be populated with at most %d characters", maxPhoneLen)
+	}
+	if a.BillingContact != nil {
+		if err := validateBillingContact(a.BillingContact, v); err != nil {
+			errMsg = errMsg + "; BillingContact: " + err.Error()
+		}
+	}
+
+	if len(errMsg) > 0 {
+		return fmt.Errorf("error validating account: %s", errMsg[2:])
+	}
+	return nil
+}
+
+// Signup is a new account and its primary user, see 'POST /signup'
+type Signup struct {
+	Account Account `json:"account"`
+	User    User    `json:"user"`
+}
+
+// Credentials are the email address and password a user logs in with, see 'POST /login'
+type Credentials struct {
+	EMail    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Session is issued to a user when they log in. Its Token, a JWT, authenticates the user's
+// requests until it expires.
+type Session struct {
+	UserID    int
+	Token     string
+	ExpiresAt time.Time
+}
+
+// UsageCounts are the number of API requests made on behalf of an account and how many of them
+// failed, i.e., had a 4xx or 5xx HTTP status
+type UsageCounts struct {
+	Requests  int64   `json:"requests"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"errorrate"`
+}
+
+// AccountUsage is an account's API usage in the current window, which started at WindowStart,
+// and in total since it was first tracked
+type AccountUsage struct {
+	AccountID   int         `json:"accountid"`
+	HREF        string      `json:"href"`
+	WindowStart time.Time   `json:"windowstart"`
+	Window      UsageCounts `json:"window"`
+	Total       UsageCounts `json:"total"`
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Address is a postal address, e.g., the address of an account's BillingContact
+type Address struct {
+	Street  string `json:"street"`
+	Street2 string `json:"street2,omitempty"`
+	City    string `json:"city"`
+	// Region is the state, province, county, etc., if the country has them
+	Region     string `json:"region,omitempty"`
+	PostalCode string `json:"postalcode,omitempty"`
+	// Country is an ISO 3166-1 alpha-2 country code, e.g., "US"
+	Country string `json:"country"`
+}
+
+// BillingContact is who's billed for an account, and where the bills are sent
+type BillingContact struct {
+	Name    string  `json:"name"`
+	EMail   string  `json:"email"`
+	Phone   string  `json:"phone,omitempty"`
+	Address Address `json:"address"`
+}
+
+// AddressValidator validates addresses, e.g., that a postal code is valid in the address's country.
+// Repositories validate the addresses of the accounts they create with one, see PostalValidator.
+type AddressValidator interface {
+	ValidateAddress(a Address) error
+}
+
+// maxPostalCodeLen is the maximum length of Address.PostalCode, see the 'address' table
+const maxPostalCodeLen = 16
+
+// countryCodes are the ISO 3166-1 alpha-2 country codes
+var countryCodes = make(map[string]bool)
+
+// defaultAddressValidator is the AddressValidator used by Account.ValidateAccount
+var defaultAddressValidator AddressValidator
+
+func init() {
+	for _, c := range strings.Fields(`
+		AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ
+		BR BS BT BV BW BY BZ CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ DE DJ DK DM
+		DO DZ EC EE EG EH ER ES ET FI FJ FK FM FO FR GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS
+		GT GU GW GY HK HM HN HR HT HU ID IE IL IM IN IO IQ IR IS IT JE JM JO JP KE KG KH KI KM KN
+		KP KR KW KY KZ LA LB LC LI LK LR LS LT LU LV LY MA MC MD ME MF MG MH MK ML MM MN MO MP MQ
+		MR MS MT MU MV MW MX MY MZ NA NC NE NF NG NI NL NO NP NR NU NZ OM PA PE PF PG PH PK PL PM
+		PN PR PS PT PW PY QA RE RO RS RU RW SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS ST SV
+		SX SY SZ TC TD TF TG TH TJ TK TL TM TN TO TR TT TV TW TZ UA UG UM US UY UZ VA VC VE VG VI
+		VN VU WF WS YE YT ZA ZM ZW`) {
+		countryCodes[c] = true
+	}
+
+	pv, err := NewPostalValidator(DefaultPostalFormats())
+	if err != nil {
+		panic(err)
+	}
+	defaultAddressValidator = pv
+}
+
+// ValidCountryCode returns true if 'code' is an ISO 3166-1 alpha-2 country code. Codes are upper case.
+func ValidCountryCode(code string) bool {
+	return countryCodes[code]
+}
+
+// DefaultPostalFormats returns the regular expressions PostalValidator uses, by default, to validate
+// the postal codes of the countries they're known for. The returned map is a copy, formats can be
+// added or replaced before it's passed to NewPostalValidator.
+func DefaultPostalFormats() map[string]string {
+	return map[string]string{
+		"AU": `\d{4}`,
+		"BR": `\d{5}-?\d{3}`,
+		"CA": `[A-Z]\d[A-Z] ?\d[A-Z]\d`,
+		"DE": `\d{5}`,
+		"FR": `\d{5}`,
+		"GB": `[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}`,
+		"IN": `\d{6}`,
+		"JP": `\d{3}-?\d{4}`,
+		"MX": `\d{5}`,
+		"NL": `\d{4} ?[A-Z]{2}`,
+		"US": `\d{5}(-\d{4})?`,
+	}
+}
+
+// PostalValidator is an AddressValidator that checks an address's country is an ISO 3166-1 alpha-2
+// code and, if there's a format for the country, that its postal code matches the format. Postal
+// codes are matched case insensitively. An address in a country without a format may omit its
+// postal code.
+type PostalValidator struct {
+	formats map[string]*regexp.Regexp
+}
+
+// NewPostalValidator returns a PostalValidator using 'formats', regular expressions matching the
+// whole of a valid postal code keyed by country code, e.g., DefaultPostalFormats()
+func NewPostalValidator(formats map[string]string) (*PostalValidator, error) {
+	pv := &PostalValidator{formats: make(map[string]*regexp.Regexp, len(formats))}
+	for country, format := range formats {
+		if !ValidCountryCode(country) {
+			return nil, fmt.Errorf("postal format for unknown country code %q", country)
+		}
+		re, err := regexp.Compile(`^(?:` + format + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid postal format for %s: %w", country, err)
+		}
+		pv.formats[country] = re
+	}
+	return pv, nil
+}
+
+// ValidateAddress will return an error if 'a' is incomplete, its country is unknown, or its postal
+// code isn't valid in its country
+func (pv *PostalValidator) ValidateAddress(a Address) error {
+	errMsg := ""
+
+	if len(a.Street) == 0 {
+		errMsg = errMsg + "; Street must be populated"
+	}
+	if len(a.City) == 0 {
+		errMsg = errMsg + "; City must be populated"
+	}
+	if len(a.PostalCode) > maxPostalCodeLen {
+		errMsg = errMsg + fmt.Sprintf("; PostalCode must have at most %d characters", maxPostalCodeLen)
+	}
+	switch re, ok := pv.formats[a.Country]; {
+	case !ValidCountryCode(a.Country):
+		errMsg = errMsg + fmt.Sprintf("; Country %q must be an ISO 3166-1 alpha-2 country code, e.g., US", a.Country)
+	case ok && !re.MatchString(strings.ToUpper(a.PostalCode)):
+		errMsg = errMsg + fmt.Sprintf("; PostalCode %q isn't valid in %s", a.PostalCode, a.Country)
+	}
+
+	if len(errMsg) > 0 {
+		return errors.New(errMsg[2:])
+	}
+	return nil
+}
+
+// validateBillingContact will return an error if 'bc' is incomplete or its address isn't valid
+// according to 'v'
+func validateBillingContact(bc *BillingContact, v AddressValidator) error {
+	errMsg := ""
+
+	if len(bc.Name) == 0 {
+		errMsg = errMsg + "; Name must be populated"
+	}
+	if len(bc.EMail) == 0 {
+		errMsg = errMsg + "; Email address must be populated"
+	}
+	if len(bc.Phone) > maxPhoneLen {
+		errMsg = errMsg + fmt.Sprintf("; Phone must have at most %d characters", maxPhoneLen)
+	}
+	if err := v.ValidateAddress(bc.Address); err != nil {
+		errMsg = errMsg + "; Address: " + err.Error()
+	}
+
+	if len(errMsg) > 0 {
+		return errors.New(errMsg[2:])
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package domain
+
+import "time"
+
+// ChangeOp is the kind of mutation a UserChange records
+type ChangeOp string
+
+const (
+	// ChangeCreate indicates the user was created
+	ChangeCreate ChangeOp = "create"
+	// ChangeUpdate indicates the user was updated, including being activated or having its role changed
+	ChangeUpdate ChangeOp = "update"
+	// ChangeDelete indicates the user was deleted
+	ChangeDelete ChangeOp = "delete"
+)
+
+// UserChange records a mutation of a user. Seq is assigned in the order the changes are recorded.
+// The change doesn't include the user, it should be retrieved if needed.
+type UserChange struct {
+	Seq    int64     `json:"seq"`
+	Op     ChangeOp  `json:"op"`
+	UserID int       `json:"userid"`
+	At     time.Time `json:"at"`
+}
+
+// UserChanges are the changes after a sequence number. Next is the sequence number to request
+// the following changes with, it's the Seq of the last change or, if there aren't any changes,
+// the requested sequence number.
+type UserChanges struct {
+	Changes []*UserChange `json:"changes"`
+	Next    int64         `json:"next"`
+}
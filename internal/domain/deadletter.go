@@ -0,0 +1,48 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package domain
+
+import (
+	"time"
+
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+// DeadLetterSource identifies the kind of asynchronous work a DeadLetter records
+type DeadLetterSource string
+
+const (
+	// DeadLetterUserCreate identifies queued (i.e., write-behind) user creations, DeadLetter.SourceID
+	// is the QueuedUser's ID
+	DeadLetterUserCreate DeadLetterSource = "usercreate"
+)
+
+// DeadLetterRepository abstracts the record of asynchronous work that failed too many times to be
+// retried automatically. Dead letters are added by the repository of the failed work, e.g.,
+// UserQueueRepository.DeadLetterUser.
+type DeadLetterRepository interface {
+	GetDeadLetters() (*DeadLetters, *mverr.MVError)
+	GetDeadLetter(id int) (*DeadLetter, *mverr.MVError)
+	DeleteDeadLetter(id int) *mverr.MVError
+}
+
+// DeadLetter represents asynchronous work that failed too many times to be retried automatically.
+// 'Source' and 'SourceID' identify the work, e.g., a QueuedUser, and 'ErrMsg' is the reason for its
+// last failure.
+type DeadLetter struct {
+	ID         int              `json:"id"`
+	HREF       string           `json:"href"`
+	Source     DeadLetterSource `json:"source"`
+	SourceID   int              `json:"sourceid"`
+	SourceHREF string           `json:"sourcehref,omitempty"`
+	Attempts   int              `json:"attempts"`
+	ErrMsg     string           `json:"errmsg,omitempty"`
+	Created    time.Time        `json:"created"`
+}
+
+// DeadLetters is a collection (slice) of DeadLetters, oldest first
+type DeadLetters struct {
+	DeadLetters []*DeadLetter `json:"deadletters"`
+}
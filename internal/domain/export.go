@@ -0,0 +1,41 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package domain
+
+import "time"
+
+// ExportStatus is the state of an ExportJob
+type ExportStatus string
+
+const (
+	// ExportPending indicates the export is still being generated
+	ExportPending ExportStatus = "pending"
+	// ExportComplete indicates the export is available for download
+	ExportComplete ExportStatus = "complete"
+	// ExportFailed indicates the export couldn't be generated
+	ExportFailed ExportStatus = "failed"
+)
+
+// AccountExport is the content of an account's data export. User passwords are never included.
+type AccountExport struct {
+	AccountID  int       `json:"accountid"`
+	ExportedAt time.Time `json:"exportedat"`
+	Users      []*User   `json:"users"`
+}
+
+// ExportJob tracks the asynchronous generation of an AccountExport
+type ExportJob struct {
+	ID        int          `json:"id"`
+	AccountID int          `json:"accountid"`
+	HREF      string       `json:"href"`
+	Status    ExportStatus `json:"status"`
+	Created   time.Time    `json:"created"`
+	// DownloadHREF is only set when Status is ExportComplete
+	DownloadHREF string `json:"downloadhref,omitempty"`
+	// ErrMsg is only set when Status is ExportFailed
+	ErrMsg string `json:"errmsg,omitempty"`
+	// BlobName is the name of the export in the blob.Store
+	BlobName string `json:"-"`
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package domain
+
+import (
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+// QueueStatus indicates how far along a queued (i.e., write-behind) user creation is
+type QueueStatus string
+
+const (
+	// QueuePending indicates the user creation has been accepted but not yet processed
+	QueuePending QueueStatus = "pending"
+	// QueueProcessing indicates the user creation has been claimed by a worker and is being applied
+	QueueProcessing QueueStatus = "processing"
+	// QueueComplete indicates the user has been created
+	QueueComplete QueueStatus = "complete"
+	// QueueFailed indicates the user could not be created, see QueuedUser.ErrMsg for the reason
+	QueueFailed QueueStatus = "failed"
+	// QueueDeadLettered indicates the user creation failed too many times and has been recorded as
+	// a DeadLetter, see QueuedUser.ErrMsg for the reason of the last failure. It can be replayed.
+	QueueDeadLettered QueueStatus = "deadlettered"
+)
+
+// UserQueueRepository abstracts a durable queue of user creations that are accepted
+// immediately and applied to the UserRepository later.
+type UserQueueRepository interface {
+	// EnqueueUser queues the creation of 'user'. 'user.Password' must already be hashed, see
+	// auth.HashPassword, it's stored until the creation is complete.
+	EnqueueUser(user User) (id int, err *mverr.MVError)
+	// ClaimNextUser marks the oldest pending user creation as being processed and returns it, with
+	// PasswordHashed set. A nil QueuedUser and nil error are returned if there are no pending user
+	// creations.
+	ClaimNextUser() (*QueuedUser, *mverr.MVError)
+	CompleteUser(id int, userID int) *mverr.MVError
+	FailUser(id int, errMsg string) *mverr.MVError
+	// RetryUser returns the user creation to the pending state after a failed attempt
+	RetryUser(id int, errMsg string) *mverr.MVError
+	// DeadLetterUser records the user creation as a DeadLetter after its final failed attempt
+	DeadLetterUser(id int, errMsg string) *mverr.MVError
+	// ReplayUser returns a dead-lettered user creation to the pending state. 'replayed' is false
+	// if the user creation isn't dead-lettered, e.g., because it has already been replayed.
+	ReplayUser(id int) (replayed bool, err *mverr.MVError)
+	GetQueuedUser(id int) (*QueuedUser, *mverr.MVError)
+}
+
+// QueuedUser represents a user creation that has been queued for later processing. 'ID' is
+// the provisional ID assigned when the creation was queued. 'UserID' is the ID of the created
+// User and is only populated when 'Status' is QueueComplete. 'Attempts' is the number of failed
+// attempts to create the user. 'PasswordHashed' is true if 'User.Password' is already a hash, e.g.,
+// one computed when the user was queued, that must be stored as is.
+type QueuedUser struct {
+	ID             int         `json:"id"`
+	HREF           string      `json:"href"`
+	Status         QueueStatus `json:"status"`
+	Attempts       int         `json:"attempts,omitempty"`
+	UserID         int         `json:"userid,omitempty"`
+	UserHREF       string      `json:"userhref,omitempty"`
+	ErrMsg         string      `json:"errmsg,omitempty"`
+	User           User        `json:"-"`
+	PasswordHashed bool        `json:"-"`
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package domain
+
+import (
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+// Repositories is the set of repositories available within a UnitOfWork. UserQueue and
+// Accounts are nil if the UnitOfWork wasn't configured with a user queue or account table
+// respectively.
+type Repositories struct {
+	Users     UserRepository
+	UserQueue UserQueueRepository
+	Accounts  AccountRepository
+}
+
+// UnitOfWork runs several repository operations atomically.
+type UnitOfWork interface {
+	// Do calls 'fn' with Repositories bound to a single transaction. The transaction is
+	// committed if 'fn' returns nil, otherwise it's rolled back and fn's error is returned.
+	// The Repositories must not be used after 'fn' returns.
+	Do(fn func(repos Repositories) *mverr.MVError) *mverr.MVError
+}
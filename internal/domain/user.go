@@ -5,7 +5,9 @@
 package domain
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	mverr "github.com/youngkin/mockvideo/internal/errors"
 )
@@ -22,41 +24,203 @@ const (
 	Restricted
 )
 
+// UserStatus indicates whether a User has confirmed their account
+type UserStatus string
+
+const (
+	// Pending users have been created but haven't yet confirmed their account using the emailed activation token
+	Pending UserStatus = "pending"
+	// Active users have confirmed their account
+	Active UserStatus = "active"
+)
+
 // UserRepository abstracts the notion of some sort of User persistent store
 // such as a database of file system.
 // TODO: Consider embedding 'ErrCode' inside an application specific error type. This would
 // TODO: likely require rethinking how errors are wrapped currently using 'errors.Annotate'
 type UserRepository interface {
 	GetUsers() (*Users, *mverr.MVError)
+	// GetUsersWithin is GetUsers with its query bounded by 'qt'. When the query times out the
+	// users fetched so far are returned, with Users.Truncated set, if 'qt.Partial', otherwise
+	// a QueryTimeoutErrorCode error is returned.
+	GetUsersWithin(qt QueryTimeout) (*Users, *mverr.MVError)
+	// GetUsersPage returns, ordered by ID, up to 'limit' of the users returned by GetUsers
+	// whose ID is greater than 'afterID'
+	GetUsersPage(afterID, limit int) (*Users, *mverr.MVError)
+	// SearchUsers returns, ordered by ID, up to 'limit' of the users returned by GetUsers whose
+	// name or email address contains 'query', ignoring case
+	SearchUsers(query string, limit int) (*Users, *mverr.MVError)
+	// UsersVersion returns the version of the collection returned by GetUsers without
+	// retrieving the users themselves
+	UsersVersion() (*UsersVersion, *mverr.MVError)
 	GetUser(id int) (*User, *mverr.MVError)
+	// EmailInUse returns true if a user, of any status, other than the one identified by
+	// 'exceptID' has the email address 'email'
+	EmailInUse(email string, exceptID int) (bool, *mverr.MVError)
+	// GetCredentials returns the user, of any status, with the email address 'email' and the user's
+	// stored password hash, see auth.HashPassword. Like GetUser, a nil user is returned if there's no
+	// such user. It's used to authenticate a user, the hash must never be returned to a caller.
+	GetCredentials(email string) (user *User, passwordHash string, err *mverr.MVError)
 	CreateUser(user User) (id int, err *mverr.MVError)
+	// UpsertUser creates 'user' or, if a user with the same email address exists, updates that user's
+	// name, role, and password. The user's ID and which of these happened are returned, UpsertSkipped
+	// if the existing user already matched 'user'. An existing user is never moved to another account,
+	// a DBInsertDuplicateUserErrorCode error is returned if it's in an account other than 'user.AccountID'.
+	// 'user.ID' is ignored. As with CreateUser, a new user's Status and activation token are taken from
+	// 'user', an existing user's are unchanged.
+	UpsertUser(user User) (id int, outcome UpsertOutcome, err *mverr.MVError)
+	// UpdateUser replaces an existing user. It must never create a user, a DBNoUserErrorCode
+	// error is returned if there's no user with 'user.ID'.
 	UpdateUser(user User) *mverr.MVError
-	DeleteUser(id int) *mverr.MVError
+	// GetUserDependencies returns the records that prevent the user identified by 'id' from being
+	// deleted, see UserDependency. There are none if the user doesn't exist. In a UnitOfWork the
+	// records can't change until it ends.
+	GetUserDependencies(id int) ([]UserDependency, *mverr.MVError)
+	// DeleteUser deletes the user identified by 'id', returning whether there was such a user.
+	// It's idempotent, deleting a user that doesn't exist isn't an error.
+	DeleteUser(id int) (deleted bool, err *mverr.MVError)
+	// DeleteUserWithSuccessor atomically deletes the Primary user identified by 'id' and makes the
+	// user identified by 'successorID', another user of the same account, the account's Primary
+	// user. Like DeleteUser it returns whether there was such a user and it's idempotent, deleting
+	// a user that doesn't exist isn't an error, the successor is unchanged. An InvalidSuccessorErrorCode
+	// error is returned if the user isn't a Primary user or the successor isn't another user of its account.
+	DeleteUserWithSuccessor(id, successorID int) (deleted bool, err *mverr.MVError)
+	// ActivateUser changes a Pending user to Active if 'token' matches the user's unexpired activation token
+	ActivateUser(id int, token string) *mverr.MVError
+	// DeleteExpiredUsers deletes Pending users whose activation token has expired, returning the number deleted
+	DeleteExpiredUsers() (int, *mverr.MVError)
+	// UpdateRoles atomically changes the roles of users in account 'accountID'. 'roles' maps
+	// user IDs to their new roles. The account must be left with exactly one Primary user.
+	UpdateRoles(accountID int, roles map[int]Role) *mverr.MVError
+	// RecordLogin sets the LastLogin of the user identified by 'id' to the current time. UpdatedAt
+	// is unchanged. Recording the login of a user that doesn't exist isn't an error.
+	RecordLogin(id int) *mverr.MVError
+	// GetInactiveUsers returns, ordered by ID, the Active users that haven't logged in since 'since',
+	// i.e., those whose LastLogin is before 'since' and those that have never logged in that were
+	// created before 'since'
+	GetInactiveUsers(since time.Time) (*Users, *mverr.MVError)
 }
 
+// ContextualUserRepository is implemented by UserRepositories whose operations can be bound to a
+// request's context, e.g., db.Table. The operations of the UserRepository returned by WithContext
+// are abandoned once 'ctx' is done, e.g., when a gRPC client's deadline passes.
+type ContextualUserRepository interface {
+	UserRepository
+	WithContext(ctx context.Context) UserRepository
+}
+
+// MaxPasswordLen is the maximum length, in bytes, of User.Password. bcrypt ignores any bytes after these.
+const MaxPasswordLen = 72
+
 // User represents the data about a user
 type User struct {
 	// TODO: Should a User have an accountID? It certainly does in the DB (secondary index).
-	AccountID int    `json:"accountid"`
-	HREF      string `json:"href"`
-	ID        int    `json:"id"`
-	Name      string `json:"name"`
-	EMail     string `json:"email"`
-	Role      Role   `json:"role"`
-	Password  string `json:"password,omitempty"`
+	AccountID int        `json:"accountid"`
+	HREF      string     `json:"href"`
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	EMail     string     `json:"email"`
+	Role      Role       `json:"role"`
+	Status    UserStatus `json:"status"`
+	// Password is the user's password when creating or updating a user. It's stored as a bcrypt hash,
+	// see auth.HashPassword, and is never returned by the UserRepository.
+	Password string `json:"password,omitempty"`
+	// CreatedAt and UpdatedAt are maintained by the UserRepository, they're ignored when creating
+	// or updating a user. They're in UTC and are serialized in RFC 3339 format.
+	CreatedAt time.Time `json:"createdat"`
+	UpdatedAt time.Time `json:"updatedat"`
+	// LastLogin is the time the user last logged in, it's nil if they never have. It's maintained by
+	// the UserRepository, see UserRepository.RecordLogin, and is ignored when creating or updating a user.
+	LastLogin *time.Time `json:"lastlogin,omitempty"`
+	// LastModifiedBy identifies the caller that last created or updated the user, it's empty if that
+	// wasn't an authenticated caller. It's set by the services layer, a value in a request is ignored.
+	LastModifiedBy string `json:"lastmodifiedby,omitempty"`
+	// ActivationToken and ActivationExpiry are only used when creating a Pending user
+	ActivationToken  string    `json:"-"`
+	ActivationExpiry time.Time `json:"-"`
 }
 
 // Users is a collection (slice) of User
 type Users struct {
 	Users []*User `json:"users"`
+	// Next is the opaque token identifying the next page of users when the users are a page
+	// of a larger collection. It's empty on the last page.
+	Next string `json:"next,omitempty"`
+	// NextHREF is the HREF of the next page of users, it's empty on the last page
+	NextHREF string `json:"nexthref,omitempty"`
+	// Truncated indicates the query returning the users timed out, only the users fetched
+	// before it did are included, see QueryTimeout
+	Truncated bool `json:"truncated,omitempty"`
 }
 
-// IsAuthenticatedUser will return true if the encryptedPassword matches the
-// User's real (i.e., unencrypted) password.
-// TODO: Move to usecases package/layer
-func (u *User) IsAuthenticatedUser(id int, encryptedPassword []byte) (bool, error) {
-	// TODO: implement
-	return false, fmt.Errorf("not implemented") /*errors.NewNotImplemented(nil, "Not implemented")*/
+// UpsertOutcome reports what UserRepository.UpsertUser did with a user
+type UpsertOutcome string
+
+const (
+	// UpsertCreated indicates the user didn't exist and was created
+	UpsertCreated UpsertOutcome = "created"
+	// UpsertUpdated indicates an existing user with the same email address was updated
+	UpsertUpdated UpsertOutcome = "updated"
+	// UpsertSkipped indicates an existing user with the same email address already matched the user,
+	// so nothing was changed
+	UpsertSkipped UpsertOutcome = "skipped"
+)
+
+// DependentAccountUsers is the UserDependency.Kind of the other users, of any status, of the account
+// the user is the Primary user of. The account can't be left without a Primary user, another user
+// must be made the Primary user before the user can be deleted, or be named as the user's successor
+// when it's deleted, see UserRepository.DeleteUserWithSuccessor.
+const DependentAccountUsers = "accountusers"
+
+// DependentAccountLinks is the UserDependency.Kind of the accountUser records associating the user
+// with an account. The associations must be removed before the user can be deleted.
+const DependentAccountLinks = "accountlinks"
+
+// UserDependency describes the records, of a single kind, that prevent a user from being deleted
+type UserDependency struct {
+	// Kind is the kind of record, e.g., DependentAccountUsers
+	Kind  string `json:"kind"`
+	Count int    `json:"count"`
+}
+
+// DependentsError is the error wrapped by the DeleteBlockedErrorCode error returned when a user
+// can't be deleted because of its Dependencies
+type DependentsError struct {
+	Dependencies []UserDependency
+}
+
+func (e *DependentsError) Error() string {
+	return fmt.Sprintf("user has dependent records %+v", e.Dependencies)
+}
+
+// QueryTimeout bounds the time spent on a DB query, it's distinct from the time allowed for the
+// request the query is made for. It's configured per endpoint.
+type QueryTimeout struct {
+	// Timeout is the time allowed for the query, zero doesn't bound the query
+	Timeout time.Duration
+	// Partial indicates the results fetched before the query timed out should be returned,
+	// marked as truncated, rather than failing the query
+	Partial bool
+}
+
+// UsersVersion identifies a version of the collection of active users, see UserRepository.GetUsers.
+// LastModified alone doesn't change when a user other than the most recently updated one is
+// deleted, Count does.
+type UsersVersion struct {
+	// LastModified is the latest UpdatedAt of the users, it's the zero time if there aren't any users
+	LastModified time.Time
+	Count        int
+}
+
+// Version returns the UsersVersion of 'us'
+func (us *Users) Version() UsersVersion {
+	v := UsersVersion{Count: len(us.Users)}
+	for _, u := range us.Users {
+		if u.UpdatedAt.After(v.LastModified) {
+			v.LastModified = u.UpdatedAt
+		}
+	}
+	return v
 }
 
 // ValidateUser will return an error if the User is not constructed correctly.
@@ -75,6 +239,9 @@ func (u *User) ValidateUser() error {
 	if len(u.Password) == 0 {
 		errMsg = errMsg + "; Password must be populated"
 	}
+	if len(u.Password) > MaxPasswordLen {
+		errMsg = errMsg + fmt.Sprintf("; Password must have at most %d bytes", MaxPasswordLen)
+	}
 	if u.Role != Primary && u.Role != Restricted && u.Role != Unrestricted {
 		errMsg = errMsg + fmt.Sprintf("; Invalid Role. Role must be one of %d, %d, or %d, got %d",
 			Primary, Restricted, Unrestricted, u.Role)
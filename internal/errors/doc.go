@@ -6,5 +6,9 @@
 Package errors defines the error codes and strings to be used throughout the application. The point of common
 	error codes and error strings (unformatted) is to enable searching in log aggregators like
 	ELK and Splunk.
+
+The error codes and messages are generated from errors.csv by 'go generate', each code is defined alongside its
+	message and the HTTP status and gRPC code returned for it by default so they can't get out of sync. Message,
+	HTTPStatus, and GRPCCode return these for an error code.
 */
 package errors
@@ -0,0 +1,93 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package errors
+
+import (
+	"encoding/csv"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+// TestErrorTable verifies that errors_gen.go is up to date with errors.csv, i.e., that 'go generate'
+// was run after errors.csv was last changed.
+func TestErrorTable(t *testing.T) {
+	f, err := os.Open("errors.csv")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected opening errors.csv", err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected reading errors.csv", err)
+	}
+
+	rows := records[1:]
+	if len(rows) != len(errorTable) {
+		t.Fatalf("expected %d error codes, got %d, run 'go generate'", len(rows), len(errorTable))
+	}
+	for _, row := range rows {
+		t.Run(row[0], func(t *testing.T) {
+			v, err := strconv.Atoi(row[1])
+			if err != nil {
+				t.Fatalf("error '%s' was not expected parsing value %s", err, row[1])
+			}
+			code := ErrCode(v)
+			if _, ok := errorTable[code]; !ok {
+				t.Fatalf("expected error code %d, run 'go generate'", v)
+			}
+			if msg := Message(code); msg != row[3] {
+				t.Errorf("expected message %q, got %q", row[3], msg)
+			}
+			status := strings.ReplaceAll(http.StatusText(HTTPStatus(code)), " ", "")
+			if "Status"+status != row[4] {
+				t.Errorf("expected HTTP status %s, got Status%s", row[4], status)
+			}
+			if c := GRPCCode(code); c.String() != row[5] {
+				t.Errorf("expected gRPC code %s, got %s", row[5], c)
+			}
+		})
+	}
+}
+
+// TestErrorCodeValues verifies that error codes keep the values clients and log searches depend on
+func TestErrorCodeValues(t *testing.T) {
+	tcs := []struct {
+		code     ErrCode
+		expected int
+	}{
+		{code: UnknownErrorCode, expected: 1},
+		{code: BulkRequestErrorCode, expected: 2},
+		{code: DBNoUserErrorCode, expected: 6},
+		{code: DBQueryErrorCode, expected: 7},
+		{code: RqstParsingErrorCode, expected: 16},
+		{code: UnableToOpenDBConnErrorCode, expected: 26},
+		{code: UserRqstErrorCode, expected: 1000},
+		{code: UserValidationErrorCode, expected: 1002},
+	}
+
+	for _, tc := range tcs {
+		if int(tc.code) != tc.expected {
+			t.Errorf("expected error code %d, got %d", tc.expected, tc.code)
+		}
+	}
+}
+
+func TestUnknownErrorCode(t *testing.T) {
+	code := ErrCode(-1)
+	if msg := Message(code); msg != "" {
+		t.Errorf("expected no message, got %q", msg)
+	}
+	if status := HTTPStatus(code); status != http.StatusInternalServerError {
+		t.Errorf("expected HTTP status %d, got %d", http.StatusInternalServerError, status)
+	}
+	if c := GRPCCode(code); c != codes.Internal {
+		t.Errorf("expected gRPC code %s, got %s", codes.Internal, c)
+	}
+}
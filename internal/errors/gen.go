@@ -0,0 +1,168 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build ignore
+// +build ignore
+
+// gen generates errors_gen.go, the error codes, error messages, and the table describing them,
+// from errors.csv. It's run by 'go generate' in this directory.
+//
+// Each row of errors.csv describes an error code:
+//
+//	code          the name of the error code constant, e.g., 'DBNoUserErrorCode'
+//	value         the error code, codes must be unique and are never reused or changed
+//	messageConst  the name of the error message constant, e.g., 'DBNoUserErrorMsg', it's empty for codes without a message
+//	message       the error message
+//	httpStatus    the name of the net/http status constant returned for the error by default, e.g., 'StatusNotFound'
+//	grpcCode      the name of the gRPC code returned for the error by default, e.g., 'NotFound'
+//	description   completes the sentence starting with the message constant's name, or the code's name if there isn't one
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+const (
+	srcFile = "errors.csv"
+	dstFile = "errors_gen.go"
+)
+
+var header = []string{"code", "value", "messageConst", "message", "httpStatus", "grpcCode", "description"}
+
+// grpcCodes are the names of the gRPC codes, see google.golang.org/grpc/codes
+var grpcCodes = map[string]bool{
+	"OK": true, "Canceled": true, "Unknown": true, "InvalidArgument": true, "DeadlineExceeded": true,
+	"NotFound": true, "AlreadyExists": true, "PermissionDenied": true, "ResourceExhausted": true,
+	"FailedPrecondition": true, "Aborted": true, "OutOfRange": true, "Unimplemented": true, "Internal": true,
+	"Unavailable": true, "DataLoss": true, "Unauthenticated": true,
+}
+
+var (
+	identRE      = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+	httpStatusRE = regexp.MustCompile(`^Status[A-Z][A-Za-z]*$`)
+)
+
+type errorRow struct {
+	code, messageConst, message, httpStatus, grpcCode, description string
+	value                                                          int
+}
+
+func main() {
+	f, err := os.Open(srcFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		log.Fatal(err)
+	}
+	rows, err := parse(records)
+	if err != nil {
+		log.Fatalf("%s: %s", srcFile, err)
+	}
+	src, err := generate(rows)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = ioutil.WriteFile(dstFile, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parse validates 'records' and returns the error rows they describe. Names, values, and messages
+// must be unique so that codes and messages can't be mismatched.
+func parse(records [][]string) ([]errorRow, error) {
+	if len(records) == 0 || fmt.Sprint(records[0]) != fmt.Sprint(header) {
+		return nil, fmt.Errorf("expected the header %v", header)
+	}
+	rows := []errorRow{}
+	seen := make(map[string]int)
+	for i, rec := range records[1:] {
+		line := i + 2
+		r := errorRow{code: rec[0], messageConst: rec[2], message: rec[3], httpStatus: rec[4], grpcCode: rec[5], description: rec[6]}
+		v, err := strconv.Atoi(rec[1])
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("line %d: invalid value %q", line, rec[1])
+		}
+		r.value = v
+		if !identRE.MatchString(r.code) {
+			return nil, fmt.Errorf("line %d: invalid code %q", line, r.code)
+		}
+		if r.messageConst != "" && !identRE.MatchString(r.messageConst) {
+			return nil, fmt.Errorf("line %d: invalid messageConst %q", line, r.messageConst)
+		}
+		if (r.messageConst == "") != (r.message == "") {
+			return nil, fmt.Errorf("line %d: messageConst and message must both be set or both be empty", line)
+		}
+		if !httpStatusRE.MatchString(r.httpStatus) {
+			return nil, fmt.Errorf("line %d: invalid httpStatus %q", line, r.httpStatus)
+		}
+		if !grpcCodes[r.grpcCode] {
+			return nil, fmt.Errorf("line %d: invalid grpcCode %q", line, r.grpcCode)
+		}
+		if r.description == "" {
+			return nil, fmt.Errorf("line %d: description required", line)
+		}
+		for _, key := range []string{"name " + r.code, "name " + r.messageConst, "value " + rec[1], "message " + r.message} {
+			if key == "name " || key == "message " {
+				continue
+			}
+			if prev, ok := seen[key]; ok {
+				return nil, fmt.Errorf("line %d: duplicate %s, see line %d", line, key, prev)
+			}
+			seen[key] = line
+		}
+		rows = append(rows, r)
+	}
+	return rows, nil
+}
+
+// generate returns the formatted source of errors_gen.go
+func generate(rows []errorRow) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by gen.go from %s; DO NOT EDIT.\n\n", srcFile)
+	b.WriteString("package errors\n\nimport (\n\t\"net/http\"\n\n\t\"google.golang.org/grpc/codes\"\n)\n\n")
+
+	b.WriteString("// Error codes, see errors.csv\nconst (\n")
+	for _, r := range rows {
+		if r.messageConst != "" {
+			fmt.Fprintf(&b, "\t// %s is the error code associated with %s\n", r.code, r.messageConst)
+		} else {
+			fmt.Fprintf(&b, "\t// %s %s\n", r.code, r.description)
+		}
+		fmt.Fprintf(&b, "\t%s ErrCode = %d\n", r.code, r.value)
+	}
+	b.WriteString(")\n\n")
+
+	b.WriteString("// Error messages, see errors.csv\nconst (\n")
+	for _, r := range rows {
+		if r.messageConst == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "\t// %s %s\n", r.messageConst, r.description)
+		fmt.Fprintf(&b, "\t%s = %s\n", r.messageConst, strconv.Quote(r.message))
+	}
+	b.WriteString(")\n\n")
+
+	b.WriteString("// errorTable describes each error code, see Message, HTTPStatus, and GRPCCode\nvar errorTable = map[ErrCode]errorInfo{\n")
+	for _, r := range rows {
+		msg := `""`
+		if r.messageConst != "" {
+			msg = r.messageConst
+		}
+		fmt.Fprintf(&b, "\t%s: {message: %s, httpStatus: http.%s, grpcCode: codes.%s},\n", r.code, msg, r.httpStatus, r.grpcCode)
+	}
+	b.WriteString("}\n")
+
+	return format.Source(b.Bytes())
+}
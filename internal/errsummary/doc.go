@@ -0,0 +1,11 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package errsummary maintains a rolling, in-memory, summary of the errors logged by accountd so
+// that on-call engineers can see what's going wrong without querying the log aggregator. Logger
+// wraps a logging.Logger and records every message logged at error level with an ErrorCode field
+// in a Summary. The Summary counts each code's occurrences, when it last occurred, and a sample ID
+// of a request it occurred in, taken from the message's RequestID field. Only the most recently
+// occurring codes are retained.
+package errsummary
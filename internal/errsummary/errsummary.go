@@ -0,0 +1,162 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package errsummary
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/clock"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// DefaultSize is the number of error codes retained by a Summary by default
+const DefaultSize = 50
+
+// Error summarizes the occurrences of an error code
+type Error struct {
+	Code mverr.ErrCode `json:"code"`
+	// Message is the message most recently logged with the code
+	Message string `json:"message"`
+	Count   uint64 `json:"count"`
+	// LastOccurrence is when the code was most recently logged
+	LastOccurrence time.Time `json:"lastoccurrence"`
+	// RequestID identifies the most recent request the code was logged in, if any
+	RequestID string `json:"requestid,omitempty"`
+}
+
+// Summary summarizes the errors recorded by Logger, it's safe for concurrent use
+type Summary struct {
+	mu     sync.Mutex
+	clock  clock.Clock
+	size   int
+	errors map[mverr.ErrCode]*Error
+}
+
+// NewSummary returns a Summary that retains the 'size' most recently occurring error codes. 'size'
+// must be positive.
+func NewSummary(size int) (*Summary, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid size %d, it must be positive", size)
+	}
+	return &Summary{clock: clock.System, size: size, errors: make(map[mverr.ErrCode]*Error)}, nil
+}
+
+// SetClock replaces the Clock, clock.System by default, used to timestamp errors. 'c' must be non-nil.
+func (s *Summary) SetClock(c clock.Clock) error {
+	if c == nil {
+		return errors.New("non-nil Clock required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = c
+	return nil
+}
+
+// Record records an occurrence of the error identified by 'code'. 'msg' is the message logged with
+// the error and 'rqstID', which may be empty, identifies the request it occurred in. If the Summary
+// is full the code that occurred least recently is discarded to make room for a new one.
+func (s *Summary) Record(code mverr.ErrCode, msg, rqstID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.errors[code]
+	if !ok {
+		if len(s.errors) == s.size {
+			s.evict()
+		}
+		e = &Error{Code: code}
+		s.errors[code] = e
+	}
+	e.Message = msg
+	e.Count++
+	e.LastOccurrence = s.clock.Now()
+	if rqstID != "" {
+		e.RequestID = rqstID
+	}
+}
+
+// evict discards the code that occurred least recently. The caller must hold 's.mu'.
+func (s *Summary) evict() {
+	var oldest *Error
+	for _, e := range s.errors {
+		if oldest == nil || e.LastOccurrence.Before(oldest.LastOccurrence) {
+			oldest = e
+		}
+	}
+	delete(s.errors, oldest.Code)
+}
+
+// Errors returns the summary of each error code, most recently occurring first
+func (s *Summary) Errors() []Error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errs := make([]Error, 0, len(s.errors))
+	for _, e := range s.errors {
+		errs = append(errs, *e)
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		if errs[i].LastOccurrence.Equal(errs[j].LastOccurrence) {
+			return errs[i].Code < errs[j].Code
+		}
+		return errs[i].LastOccurrence.After(errs[j].LastOccurrence)
+	})
+	return errs
+}
+
+// summaryLogger is a logging.Logger that records the errors it logs in a Summary
+type summaryLogger struct {
+	logging.Logger
+	summary *Summary
+	// code and rqstID are the values of the ErrorCode and RequestID fields added by WithFields
+	code   mverr.ErrCode
+	rqstID string
+}
+
+// Logger returns a logging.Logger that logs messages using 'next' and records the messages logged at
+// error level with a non-zero ErrorCode field in 's'
+func Logger(next logging.Logger, s *Summary) logging.Logger {
+	return summaryLogger{Logger: next, summary: s}
+}
+
+// WithFields returns a Logger that includes 'fields' in each log message and records errors in the
+// same Summary
+func (l summaryLogger) WithFields(fields logging.Fields) logging.Logger {
+	wl := l
+	wl.Logger = l.Logger.WithFields(fields)
+	switch code := fields[logging.ErrorCode].(type) {
+	case mverr.ErrCode:
+		wl.code = code
+	case int:
+		wl.code = mverr.ErrCode(code)
+	}
+	if id, ok := fields[logging.RqstID].(string); ok && id != "" {
+		wl.rqstID = id
+	}
+	return wl
+}
+
+// Error logs, and records, an error
+func (l summaryLogger) Error(args ...interface{}) {
+	l.record(fmt.Sprint(args...))
+	l.Logger.Error(args...)
+}
+
+// Errorf logs, and records, an error
+func (l summaryLogger) Errorf(format string, args ...interface{}) {
+	l.record(fmt.Sprintf(format, args...))
+	l.Logger.Errorf(format, args...)
+}
+
+func (l summaryLogger) record(msg string) {
+	if l.code != mverr.NoErrorCode {
+		l.summary.Record(l.code, msg, l.rqstID)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package errsummary
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/internal/clock"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+var start = time.Date(2020, 7, 4, 9, 30, 0, 0, time.UTC)
+
+func TestSummary(t *testing.T) {
+	if _, err := NewSummary(0); err == nil {
+		t.Errorf("expected an error creating a Summary with size 0")
+	}
+
+	s, err := NewSummary(2)
+	if err != nil {
+		t.Fatalf("error '%s' was not expected creating a Summary", err)
+	}
+	c := clock.NewFrozen(start)
+	if err = s.SetClock(c); err != nil {
+		t.Fatalf("error '%s' was not expected setting the clock", err)
+	}
+
+	s.Record(mverr.DBQueryErrorCode, "first", "1")
+	c.Advance(time.Second)
+	s.Record(mverr.DBUpSertErrorCode, "upsert", "2")
+	c.Advance(time.Second)
+	s.Record(mverr.DBQueryErrorCode, "second", "")
+	expected := []Error{
+		{Code: mverr.DBQueryErrorCode, Message: "second", Count: 2, LastOccurrence: start.Add(2 * time.Second), RequestID: "1"},
+		{Code: mverr.DBUpSertErrorCode, Message: "upsert", Count: 1, LastOccurrence: start.Add(time.Second), RequestID: "2"},
+	}
+	if actual := s.Errors(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+
+	// The code that occurred least recently is discarded
+	c.Advance(time.Second)
+	s.Record(mverr.DBDeleteErrorCode, "delete", "3")
+	expected = []Error{
+		{Code: mverr.DBDeleteErrorCode, Message: "delete", Count: 1, LastOccurrence: start.Add(3 * time.Second), RequestID: "3"},
+		expected[0],
+	}
+	if actual := s.Errors(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+	s, err := NewSummary(DefaultSize)
+	if err != nil {
+		t.Fatalf("error '%s' was not expected creating a Summary", err)
+	}
+	if err = s.SetClock(clock.NewFrozen(start)); err != nil {
+		t.Fatalf("error '%s' was not expected setting the clock", err)
+	}
+	logger := Logger(logging.Default(), s)
+
+	rqstLogger := logger.WithFields(logging.Fields{logging.RqstID: "17"})
+	rqstLogger.WithFields(logging.Fields{logging.ErrorCode: mverr.DBQueryErrorCode}).Error(mverr.DBQueryErrorMsg)
+	logger.WithFields(logging.Fields{logging.ErrorCode: mverr.DBQueryErrorCode}).Errorf("query %d failed", 2)
+	// Not recorded, either not an error or no error code
+	logger.WithFields(logging.Fields{logging.ErrorCode: mverr.DBUpSertErrorCode}).Warn(mverr.DBUpSertErrorMsg)
+	rqstLogger.Error("no error code")
+
+	expected := []Error{
+		{Code: mverr.DBQueryErrorCode, Message: "query 2 failed", Count: 2, LastOccurrence: start, RequestID: "17"},
+	}
+	if actual := s.Errors(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
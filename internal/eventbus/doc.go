@@ -0,0 +1,38 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package eventbus delivers in-process notifications, e.g., that a user was created, from the code making
+a change to the components that react to it, such as change feeds, search indexing, or cache invalidation,
+without either knowing about the other.
+
+Events are published to a Topic. Each Topic has a name and the type of its payloads, a payload of any
+other type is rejected by Publish, so a subscriber can rely on the type of the payloads it receives:
+
+		var UserCreated = eventbus.NewTopic("user.created", domain.UserChange{})
+
+		sub, err := bus.Subscribe("search", 100, eventbus.DropNewest, UserCreated)
+		...
+		for e := range sub.C() {
+			change := e.Payload.(domain.UserChange)
+			...
+		}
+
+Publish never blocks, each Subscription has its own bounded buffer of events. When a subscriber falls
+behind and its buffer is full it's a slow consumer, and its SlowConsumerPolicy decides what happens. With
+DropNewest the new event isn't delivered to it, with DropOldest the oldest buffered event is dropped to
+make room for it, and with Unsubscribe it's unsubscribed and its channel is closed so it can recover, e.g.,
+by resynchronizing and subscribing again. Either way a slow subscriber never delays the publisher or the
+other subscribers. Events are only kept in memory, they're lost when the process exits and aren't
+delivered to other processes.
+
+A Bus can also be given a Validator, e.g., an eventschema.Registry, with SetValidator. Publish then
+rejects payloads the Validator finds invalid, such as ones missing a required field, so subscribers, and
+the consumers they forward events to, only see payloads matching their topic's schema.
+
+Published and undelivered events are counted by topic in the EventsPublished and EventsDropped metrics,
+subscribers unsubscribed for being slow in SlowConsumersDisconnected, and the number of subscribers is
+reported by Subscribers.
+*/
+package eventbus
@@ -0,0 +1,287 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package eventbus
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/internal/metrics"
+)
+
+// EventsPublished counts the events published, by topic
+var EventsPublished = metrics.NewCounterVec(prometheus.CounterOpts{
+	Subsystem: "eventbus",
+	Name:      "events_published_total",
+	Help:      "number of events published by topic",
+}, []string{"topic"})
+
+// EventsDropped counts the events that weren't delivered to a subscriber because it was a slow
+// consumer, by topic and subscriber
+var EventsDropped = metrics.NewCounterVec(prometheus.CounterOpts{
+	Subsystem: "eventbus",
+	Name:      "events_dropped_total",
+	Help:      "number of events not delivered to slow consumers by topic and subscriber",
+}, []string{"topic", "subscriber"})
+
+// SlowConsumersDisconnected counts the subscribers unsubscribed because they were slow consumers, by
+// subscriber
+var SlowConsumersDisconnected = metrics.NewCounterVec(prometheus.CounterOpts{
+	Subsystem: "eventbus",
+	Name:      "slow_consumers_disconnected_total",
+	Help:      "number of subscribers unsubscribed because they were slow consumers by subscriber",
+}, []string{"subscriber"})
+
+// Subscribers is the number of subscriptions
+var Subscribers = metrics.NewGauge(prometheus.GaugeOpts{
+	Subsystem: "eventbus",
+	Name:      "subscribers",
+	Help:      "number of event subscriptions",
+})
+
+// Topic identifies a kind of event and the type of its payloads, see NewTopic
+type Topic struct {
+	name    string
+	payload reflect.Type
+}
+
+// NewTopic returns the Topic named 'name' whose payloads have the same type as 'payload'
+func NewTopic(name string, payload interface{}) Topic {
+	return Topic{name: name, payload: reflect.TypeOf(payload)}
+}
+
+// Name returns the topic's name
+func (t Topic) Name() string {
+	return t.name
+}
+
+// PayloadType returns the type of the topic's payloads
+func (t Topic) PayloadType() reflect.Type {
+	return t.payload
+}
+
+// Event is a payload published to a Topic
+type Event struct {
+	Topic   Topic
+	Payload interface{}
+}
+
+// SlowConsumerPolicy decides what happens when an event is published and a subscriber's buffer is full
+type SlowConsumerPolicy int
+
+const (
+	// DropNewest drops the new event for the subscriber, it stays subscribed
+	DropNewest SlowConsumerPolicy = iota
+	// Unsubscribe unsubscribes the subscriber, closing its channel
+	Unsubscribe
+	// DropOldest drops the oldest buffered event to make room for the new event, the subscriber stays
+	// subscribed and receives the most recent events
+	DropOldest
+)
+
+// SlowConsumerPolicyName maps a specific SlowConsumerPolicy value to a descriptive string, e.g., for
+// configuration
+var SlowConsumerPolicyName = map[SlowConsumerPolicy]string{
+	DropNewest:  "dropnewest",
+	Unsubscribe: "disconnect",
+	DropOldest:  "dropoldest",
+}
+
+// ParseSlowConsumerPolicy returns the SlowConsumerPolicy named 'name', see SlowConsumerPolicyName
+func ParseSlowConsumerPolicy(name string) (SlowConsumerPolicy, error) {
+	for p, n := range SlowConsumerPolicyName {
+		if n == name {
+			return p, nil
+		}
+	}
+	return DropNewest, fmt.Errorf("unknown slow consumer policy %q, expected one of 'dropnewest', 'dropoldest', or 'disconnect'", name)
+}
+
+// Validator validates the payloads published to a Bus, see Bus.SetValidator
+type Validator interface {
+	// Validate returns an error if 'payload' isn't a valid payload for 't'
+	Validate(t Topic, payload interface{}) error
+}
+
+// Bus delivers events published to a Topic to each of its subscribers, see the package documentation
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+	// validator is only set if payloads are validated, see SetValidator
+	validator Validator
+}
+
+// New returns a Bus without any subscribers
+func New() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// SetValidator makes Publish reject payloads that 'v' finds invalid, e.g., ones that don't match their
+// topic's schema. It must be called before anything is published. 'v' must be non-nil.
+func (b *Bus) SetValidator(v Validator) error {
+	if v == nil {
+		return errors.New("non-nil Validator required")
+	}
+	b.validator = v
+	return nil
+}
+
+// Subscription receives the events published to its topics, see Bus.Subscribe
+type Subscription struct {
+	bus     *Bus
+	name    string
+	topics  map[string]bool
+	policy  SlowConsumerPolicy
+	c       chan Event
+	dropped uint64
+}
+
+// Subscribe returns a Subscription named 'name', e.g., for the subscriber's metrics, to 'topics'. Up to
+// 'buffer' events are buffered for it, after which 'policy' applies. 'buffer' must be greater than 0 and
+// at least one topic is required.
+func (b *Bus) Subscribe(name string, buffer int, policy SlowConsumerPolicy, topics ...Topic) (*Subscription, error) {
+	if buffer < 1 {
+		return nil, errors.New("buffer must be greater than 0")
+	}
+	if len(topics) == 0 {
+		return nil, errors.New("at least one topic required")
+	}
+
+	s := &Subscription{
+		bus:    b,
+		name:   name,
+		topics: make(map[string]bool),
+		policy: policy,
+		c:      make(chan Event, buffer),
+	}
+	for _, t := range topics {
+		s.topics[t.name] = true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, errors.New("event bus is closed")
+	}
+	b.subs[s] = struct{}{}
+	Subscribers.Inc()
+	return s, nil
+}
+
+// Publish delivers 'payload' to the subscribers of 't' without waiting for them. An error is returned
+// if 'payload' isn't of the topic's type or, if the Bus has a Validator, is invalid.
+func (b *Bus) Publish(t Topic, payload interface{}) error {
+	if reflect.TypeOf(payload) != t.payload {
+		return fmt.Errorf("topic %s requires a %s payload, got %T", t.name, t.payload, payload)
+	}
+	if b.validator != nil {
+		if err := b.validator.Validate(t, payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %w", t.name, err)
+		}
+	}
+	EventsPublished.WithLabelValues(t.name).Inc()
+
+	e := Event{Topic: t, Payload: payload}
+	slow := []*Subscription{}
+	b.mu.RLock()
+	for s := range b.subs {
+		if !s.topics[t.name] {
+			continue
+		}
+		if s.policy == DropOldest {
+			s.replaceOldest(e)
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+			s.drop(t)
+			if s.policy == Unsubscribe {
+				slow = append(slow, s)
+			}
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, s := range slow {
+		// Another publisher may already have unsubscribed it
+		if s.unsubscribe() {
+			SlowConsumersDisconnected.WithLabelValues(s.name).Inc()
+		}
+	}
+	return nil
+}
+
+// replaceOldest delivers 'e' to 's', dropping the oldest buffered events until there's room for it
+func (s *Subscription) replaceOldest(e Event) {
+	for {
+		select {
+		case s.c <- e:
+			return
+		default:
+		}
+		// Another publisher, or the subscriber, may have made room in the meantime
+		select {
+		case old := <-s.c:
+			s.drop(old.Topic)
+		default:
+		}
+	}
+}
+
+// drop counts an event published to 't' that wasn't delivered to 's'
+func (s *Subscription) drop(t Topic) {
+	atomic.AddUint64(&s.dropped, 1)
+	EventsDropped.WithLabelValues(t.name, s.name).Inc()
+}
+
+// Close unsubscribes all the subscribers, later subscriptions fail
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		b.remove(s)
+	}
+}
+
+// remove unsubscribes 's' and returns true if it was subscribed, b.mu must be locked
+func (b *Bus) remove(s *Subscription) bool {
+	if _, ok := b.subs[s]; !ok {
+		return false
+	}
+	delete(b.subs, s)
+	close(s.c)
+	Subscribers.Dec()
+	return true
+}
+
+// C returns the channel events are delivered on. It's closed when the subscriber is unsubscribed.
+func (s *Subscription) C() <-chan Event {
+	return s.c
+}
+
+// Dropped returns the number of events that weren't delivered because the subscriber was a slow consumer
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Unsubscribe stops delivering events to the subscriber and closes its channel. Events already
+// buffered can still be received. It can be called more than once.
+func (s *Subscription) Unsubscribe() {
+	s.unsubscribe()
+}
+
+// unsubscribe unsubscribes the subscriber and returns true if it was subscribed
+func (s *Subscription) unsubscribe() bool {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	return s.bus.remove(s)
+}
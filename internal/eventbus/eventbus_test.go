@@ -0,0 +1,260 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package eventbus
+
+import (
+	"errors"
+	"testing"
+)
+
+type created struct {
+	id int
+}
+
+type deleted struct {
+	id int
+}
+
+var (
+	createdTopic = NewTopic("created", created{})
+	deletedTopic = NewTopic("deleted", deleted{})
+)
+
+func TestPublish(t *testing.T) {
+	tcs := []struct {
+		testName    string
+		topic       Topic
+		payload     interface{}
+		shouldPass  bool
+		expectedSub int
+		expectedAll int
+	}{
+		{
+			testName:    "testCreated",
+			topic:       createdTopic,
+			payload:     created{id: 1},
+			shouldPass:  true,
+			expectedSub: 1,
+			expectedAll: 1,
+		},
+		{
+			testName:    "testNotSubscribed",
+			topic:       deletedTopic,
+			payload:     deleted{id: 1},
+			shouldPass:  true,
+			expectedSub: 0,
+			expectedAll: 1,
+		},
+		{
+			testName:   "testWrongPayloadType",
+			topic:      createdTopic,
+			payload:    deleted{id: 1},
+			shouldPass: false,
+		},
+		{
+			testName:   "testPointerPayload",
+			topic:      createdTopic,
+			payload:    &created{id: 1},
+			shouldPass: false,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			b := New()
+			defer b.Close()
+			sub, err := b.Subscribe("sub", 10, DropNewest, createdTopic)
+			if err != nil {
+				t.Fatalf("error %s was not expected subscribing", err)
+			}
+			all, err := b.Subscribe("all", 10, DropNewest, createdTopic, deletedTopic)
+			if err != nil {
+				t.Fatalf("error %s was not expected subscribing", err)
+			}
+
+			err = b.Publish(tc.topic, tc.payload)
+			if tc.shouldPass != (err == nil) {
+				t.Fatalf("expected success %t, got error %v", tc.shouldPass, err)
+			}
+			if len(sub.C()) != tc.expectedSub {
+				t.Errorf("expected %d events for 'sub', got %d", tc.expectedSub, len(sub.C()))
+			}
+			if len(all.C()) != tc.expectedAll {
+				t.Fatalf("expected %d events for 'all', got %d", tc.expectedAll, len(all.C()))
+			}
+			if tc.expectedAll > 0 {
+				e := <-all.C()
+				if e.Topic.Name() != tc.topic.Name() || e.Payload != tc.payload {
+					t.Errorf("expected %+v published to %s, got %+v", tc.payload, tc.topic.Name(), e)
+				}
+			}
+		})
+	}
+}
+
+func TestSlowConsumer(t *testing.T) {
+	tcs := []struct {
+		testName             string
+		policy               SlowConsumerPolicy
+		expectedDropped      uint64
+		expectedUnsubscribed bool
+		// expectedFirst is the first of the 2 buffered events
+		expectedFirst int
+	}{
+		{
+			testName:        "testDropNewest",
+			policy:          DropNewest,
+			expectedDropped: 2,
+			expectedFirst:   1,
+		},
+		{
+			testName:        "testDropOldest",
+			policy:          DropOldest,
+			expectedDropped: 2,
+			expectedFirst:   3,
+		},
+		{
+			testName:             "testUnsubscribe",
+			policy:               Unsubscribe,
+			expectedDropped:      1,
+			expectedUnsubscribed: true,
+			expectedFirst:        1,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			b := New()
+			defer b.Close()
+			slow, _ := b.Subscribe("slow", 2, tc.policy, createdTopic)
+			fast, _ := b.Subscribe("fast", 10, tc.policy, createdTopic)
+
+			for i := 1; i <= 4; i++ {
+				if err := b.Publish(createdTopic, created{id: i}); err != nil {
+					t.Fatalf("error %s was not expected publishing", err)
+				}
+			}
+
+			if slow.Dropped() != tc.expectedDropped {
+				t.Errorf("expected %d dropped events, got %d", tc.expectedDropped, slow.Dropped())
+			}
+			if fast.Dropped() != 0 || len(fast.C()) != 4 {
+				t.Errorf("expected all 4 events for the fast subscriber, got %d, %d dropped", len(fast.C()), fast.Dropped())
+			}
+
+			// The buffered events are still received
+			received := 0
+			for e := range drain(slow) {
+				if e.Payload.(created).id != tc.expectedFirst+received {
+					t.Errorf("expected event %d, got %+v", tc.expectedFirst+received, e.Payload)
+				}
+				received++
+			}
+			if received != 2 {
+				t.Errorf("expected 2 buffered events, got %d", received)
+			}
+			open := true
+			select {
+			case _, open = <-slow.C():
+			default:
+			}
+			if open == tc.expectedUnsubscribed {
+				t.Errorf("expected unsubscribed %t", tc.expectedUnsubscribed)
+			}
+		})
+	}
+}
+
+func TestParseSlowConsumerPolicy(t *testing.T) {
+	for p, name := range SlowConsumerPolicyName {
+		if parsed, err := ParseSlowConsumerPolicy(name); err != nil || parsed != p {
+			t.Errorf("expected %s to be parsed as %d, got %d, error %v", name, p, parsed, err)
+		}
+	}
+	if _, err := ParseSlowConsumerPolicy("block"); err == nil {
+		t.Errorf("expected an error parsing an unknown policy")
+	}
+}
+
+func TestClose(t *testing.T) {
+	b := New()
+	sub, _ := b.Subscribe("sub", 1, DropNewest, createdTopic)
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	if _, open := <-sub.C(); open {
+		t.Errorf("expected the unsubscribed channel to be closed")
+	}
+
+	sub, _ = b.Subscribe("sub", 1, DropNewest, createdTopic)
+	b.Close()
+	if _, open := <-sub.C(); open {
+		t.Errorf("expected the channel to be closed when the bus is closed")
+	}
+	if _, err := b.Subscribe("sub", 1, DropNewest, createdTopic); err == nil {
+		t.Errorf("expected an error subscribing to a closed bus")
+	}
+	if err := b.Publish(createdTopic, created{id: 1}); err != nil {
+		t.Errorf("error %s was not expected publishing to a closed bus", err)
+	}
+}
+
+func TestSubscribeInvalid(t *testing.T) {
+	b := New()
+	defer b.Close()
+	if _, err := b.Subscribe("sub", 0, DropNewest, createdTopic); err == nil {
+		t.Errorf("expected an error subscribing with a 0 buffer")
+	}
+	if _, err := b.Subscribe("sub", 1, DropNewest); err == nil {
+		t.Errorf("expected an error subscribing without topics")
+	}
+}
+
+// positiveIDs is a Validator that only accepts 'created' payloads with a positive id
+type positiveIDs struct{}
+
+func (positiveIDs) Validate(t Topic, payload interface{}) error {
+	if c, ok := payload.(created); ok && c.id <= 0 {
+		return errors.New("id must be positive")
+	}
+	return nil
+}
+
+func TestValidator(t *testing.T) {
+	b := New()
+	defer b.Close()
+	if err := b.SetValidator(nil); err == nil {
+		t.Errorf("expected an error setting a nil Validator")
+	}
+	if err := b.SetValidator(positiveIDs{}); err != nil {
+		t.Fatalf("error %s was not expected setting a Validator", err)
+	}
+	sub, err := b.Subscribe("sub", 10, DropNewest, createdTopic, deletedTopic)
+	if err != nil {
+		t.Fatalf("error %s was not expected subscribing", err)
+	}
+
+	if err = b.Publish(createdTopic, created{id: 0}); err == nil {
+		t.Errorf("expected an error publishing an invalid payload")
+	}
+	if err = b.Publish(createdTopic, created{id: 1}); err != nil {
+		t.Errorf("error %s was not expected publishing a valid payload", err)
+	}
+	if err = b.Publish(deletedTopic, deleted{id: 0}); err != nil {
+		t.Errorf("error %s was not expected publishing to a topic the Validator accepts", err)
+	}
+	if len(sub.C()) != 2 {
+		t.Errorf("expected only the 2 valid events to be delivered, got %d", len(sub.C()))
+	}
+}
+
+// drain returns the events buffered for 's' without waiting for more
+func drain(s *Subscription) <-chan Event {
+	c := make(chan Event, len(s.C()))
+	for n := len(s.C()); n > 0; n-- {
+		c <- <-s.C()
+	}
+	close(c)
+	return c
+}
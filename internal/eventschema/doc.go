@@ -0,0 +1,31 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package eventschema is a registry of the versioned schemas of the event payloads published to an
+eventbus.Bus, so that the consumers of those events, in process or, e.g., via the /users/events
+stream, don't break when a payload changes.
+
+Each eventbus.Topic's payload struct is registered with the version of its schema:
+
+		registry := eventschema.NewRegistry()
+		if err := registry.Register(services.UserCreated, 1); err != nil {
+			...
+		}
+		if err := bus.SetValidator(registry); err != nil {
+			...
+		}
+
+The schema is derived from the payload struct the same way encoding/json marshals it, i.e., from its
+exported fields and their json tags, and can be exported as a JSON Schema (draft-07) document. A field
+is required unless it's a pointer or tagged omitempty. As the Registry's Validate, a Bus rejects
+payloads published to an unregistered topic or with a required field set to its zero value, so a field
+whose zero value is meaningful, e.g., a count, should be tagged omitempty.
+
+Breaking compares two versions of a schema and describes the changes that would break a consumer of
+the older one, i.e., a property being removed, its type or format changing, or it no longer being
+required. Adding a property isn't breaking. A payload's exported schemas are typically kept as golden
+files and compared against its current schema in tests, a breaking change requires a new version.
+*/
+package eventschema
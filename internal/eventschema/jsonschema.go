@@ -0,0 +1,234 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package eventschema
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Draft is the JSON Schema draft JSONSchema documents conform to
+const Draft = "http://json-schema.org/draft-07/schema#"
+
+// JSONSchema is the subset of a JSON Schema document needed to describe event payloads
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	ID                   string                 `json:"$id,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// field is a field of a payload struct as encoding/json marshals it
+type field struct {
+	name     string
+	index    []int
+	typ      reflect.Type
+	required bool
+	// asString is set if the field is tagged ",string", i.e., a number or bool marshaled as a string
+	asString bool
+}
+
+// fields returns the fields of struct type 't' that encoding/json marshals, in order. The fields of
+// embedded structs without a json name are included as if they were fields of 't'.
+func fields(t reflect.Type) []field {
+	var fs []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+
+		ft := sf.Type
+		if sf.Anonymous && name == "" {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for _, ef := range fields(ft) {
+					ef.index = append([]int{i}, ef.index...)
+					// The fields of an embedded pointer may be missing
+					ef.required = ef.required && sf.Type.Kind() != reflect.Ptr
+					fs = append(fs, ef)
+				}
+				continue
+			}
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fs = append(fs, field{
+			name:     name,
+			index:    []int{i},
+			typ:      ft,
+			required: !hasOpt(opts, "omitempty") && ft.Kind() != reflect.Ptr,
+			asString: hasOpt(opts, "string"),
+		})
+	}
+	return fs
+}
+
+// hasOpt returns true if 'opt' is one of the comma separated json tag options 'opts'
+func hasOpt(opts, opt string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaOf returns the JSONSchema of values of type 't'. 'seen' contains the struct types being
+// described, recursive types aren't supported.
+func schemaOf(t reflect.Type, seen map[reflect.Type]bool) (*JSONSchema, error) {
+	if t == timeType {
+		return &JSONSchema{Type: "string", Format: "date-time"}, nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}, nil
+	case reflect.String:
+		return &JSONSchema{Type: "string"}, nil
+	case reflect.Interface:
+		// Any value
+		return &JSONSchema{}, nil
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), seen)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			// []byte is marshaled as a base64 string
+			return &JSONSchema{Type: "string"}, nil
+		}
+		items, err := schemaOf(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return &JSONSchema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map %s must have string keys", t)
+		}
+		values, err := schemaOf(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return &JSONSchema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		if seen[t] {
+			return nil, fmt.Errorf("recursive type %s isn't supported", t)
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		s := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+		for _, f := range fields(t) {
+			fs, err := schemaOf(f.typ, seen)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", t, f.name, err)
+			}
+			if f.asString && (fs.Type == "integer" || fs.Type == "number" || fs.Type == "boolean") {
+				fs = &JSONSchema{Type: "string"}
+			}
+			s.Properties[f.name] = fs
+			if f.required {
+				s.Required = append(s.Required, f.name)
+			}
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("type %s can't be described", t)
+	}
+}
+
+// Breaking returns the changes from 'prev' to 'next' that would break a consumer of payloads matching
+// 'prev', i.e., a property being removed, its type or format changing, or it no longer being required.
+// Each change is described by the path to the property, e.g., "items.userid", and what changed. No
+// changes are returned if 'next' is compatible with 'prev'.
+func Breaking(prev, next *JSONSchema) []string {
+	var changes []string
+	breaking("", prev, next, &changes)
+	return changes
+}
+
+// breaking appends the breaking changes from 'prev' to 'next', the schemas of the property at 'path',
+// to 'changes'
+func breaking(path string, prev, next *JSONSchema, changes *[]string) {
+	at := path
+	if at == "" {
+		at = "payload"
+	}
+	if prev.Type != next.Type {
+		*changes = append(*changes, fmt.Sprintf("%s: type changed from %q to %q", at, prev.Type, next.Type))
+		return
+	}
+	if prev.Format != next.Format {
+		*changes = append(*changes, fmt.Sprintf("%s: format changed from %q to %q", at, prev.Format, next.Format))
+	}
+
+	names := make([]string, 0, len(prev.Properties))
+	for name := range prev.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		np, ok := next.Properties[name]
+		if !ok {
+			*changes = append(*changes, fmt.Sprintf("%s: removed", join(path, name)))
+			continue
+		}
+		breaking(join(path, name), prev.Properties[name], np, changes)
+	}
+	for _, name := range prev.Required {
+		if _, ok := next.Properties[name]; ok && !contains(next.Required, name) {
+			*changes = append(*changes, fmt.Sprintf("%s: no longer required", join(path, name)))
+		}
+	}
+
+	if prev.Items != nil && next.Items != nil {
+		breaking(join(path, "items"), prev.Items, next.Items, changes)
+	}
+	if prev.AdditionalProperties != nil && next.AdditionalProperties != nil {
+		breaking(join(path, "additionalProperties"), prev.AdditionalProperties, next.AdditionalProperties, changes)
+	}
+}
+
+// join returns the path to property 'name' of the property at 'path'
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
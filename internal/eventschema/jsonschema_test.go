@@ -0,0 +1,157 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package eventschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type audit struct {
+	By string    `json:"by"`
+	At time.Time `json:"at"`
+}
+
+type change struct {
+	audit
+	ID       int               `json:"id"`
+	Name     string            `json:"name,omitempty"`
+	Count    int64             `json:"count,string"`
+	Tags     []string          `json:"tags"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Previous *audit            `json:"previous"`
+	Data     []byte            `json:"data,omitempty"`
+	Ignored  int               `json:"-"`
+	internal int
+}
+
+func TestSchemaOf(t *testing.T) {
+	s, err := schemaOf(reflect.TypeOf(change{}), make(map[reflect.Type]bool))
+	if err != nil {
+		t.Fatalf("error %s was not expected describing a change", err)
+	}
+	got, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("error %s was not expected marshaling the schema", err)
+	}
+	expected := `{"type":"object","properties":{` +
+		`"at":{"type":"string","format":"date-time"},` +
+		`"by":{"type":"string"},` +
+		`"count":{"type":"string"},` +
+		`"data":{"type":"string"},` +
+		`"id":{"type":"integer"},` +
+		`"labels":{"type":"object","additionalProperties":{"type":"string"}},` +
+		`"name":{"type":"string"},` +
+		`"previous":{"type":"object","properties":{"at":{"type":"string","format":"date-time"},"by":{"type":"string"}},"required":["by","at"]},` +
+		`"tags":{"type":"array","items":{"type":"string"}}},` +
+		`"required":["by","at","id","count","tags"]}`
+	if string(got) != expected {
+		t.Errorf("expected schema\n%s\ngot\n%s", expected, got)
+	}
+
+	type node struct {
+		Next *node `json:"next"`
+	}
+	if _, err = schemaOf(reflect.TypeOf(node{}), make(map[reflect.Type]bool)); err == nil {
+		t.Errorf("expected an error describing a recursive type")
+	}
+	if _, err = schemaOf(reflect.TypeOf(map[int]string{}), make(map[reflect.Type]bool)); err == nil {
+		t.Errorf("expected an error describing a map without string keys")
+	}
+}
+
+func TestBreaking(t *testing.T) {
+	prev := &JSONSchema{
+		Type: "object",
+		Properties: map[string]*JSONSchema{
+			"id":   {Type: "integer"},
+			"at":   {Type: "string", Format: "date-time"},
+			"tags": {Type: "array", Items: &JSONSchema{Type: "string"}},
+		},
+		Required: []string{"id", "at"},
+	}
+
+	tcs := []struct {
+		testName        string
+		next            *JSONSchema
+		expectedChanges []string
+	}{
+		{
+			testName: "testUnchanged",
+			next:     prev,
+		},
+		{
+			testName: "testPropertyAdded",
+			next: &JSONSchema{
+				Type: "object",
+				Properties: map[string]*JSONSchema{
+					"id":   {Type: "integer"},
+					"at":   {Type: "string", Format: "date-time"},
+					"tags": {Type: "array", Items: &JSONSchema{Type: "string"}},
+					"name": {Type: "string"},
+				},
+				Required: []string{"id", "at", "name"},
+			},
+		},
+		{
+			testName: "testPropertyRemoved",
+			next: &JSONSchema{
+				Type: "object",
+				Properties: map[string]*JSONSchema{
+					"id":   {Type: "integer"},
+					"tags": {Type: "array", Items: &JSONSchema{Type: "string"}},
+				},
+				Required: []string{"id"},
+			},
+			expectedChanges: []string{"at: removed"},
+		},
+		{
+			testName: "testTypeAndFormatChanged",
+			next: &JSONSchema{
+				Type: "object",
+				Properties: map[string]*JSONSchema{
+					"id":   {Type: "string"},
+					"at":   {Type: "string", Format: "date"},
+					"tags": {Type: "array", Items: &JSONSchema{Type: "integer"}},
+				},
+				Required: []string{"id", "at"},
+			},
+			expectedChanges: []string{
+				`at: format changed from "date-time" to "date"`,
+				`id: type changed from "integer" to "string"`,
+				`tags.items: type changed from "string" to "integer"`,
+			},
+		},
+		{
+			testName: "testNoLongerRequired",
+			next: &JSONSchema{
+				Type: "object",
+				Properties: map[string]*JSONSchema{
+					"id":   {Type: "integer"},
+					"at":   {Type: "string", Format: "date-time"},
+					"tags": {Type: "array", Items: &JSONSchema{Type: "string"}},
+				},
+				Required: []string{"at"},
+			},
+			expectedChanges: []string{"id: no longer required"},
+		},
+		{
+			testName:        "testPayloadTypeChanged",
+			next:            &JSONSchema{Type: "array", Items: &JSONSchema{Type: "string"}},
+			expectedChanges: []string{`payload: type changed from "object" to "array"`},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			changes := Breaking(prev, tc.next)
+			if !reflect.DeepEqual(changes, tc.expectedChanges) {
+				t.Errorf("expected breaking changes %q, got %q", tc.expectedChanges, changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package eventschema
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/youngkin/mockvideo/internal/eventbus"
+)
+
+// Schema is the schema of the payloads published to a topic
+type Schema struct {
+	// Topic is the name of the topic
+	Topic string
+	// Version is the version of the payload's schema, it's incremented by breaking changes
+	Version int
+	// JSON is the payload's JSON Schema document
+	JSON *JSONSchema
+
+	payload reflect.Type
+}
+
+// Registry holds the Schema of each registered topic's payloads, see the package documentation. It's
+// an eventbus.Validator.
+type Registry struct {
+	mu      sync.RWMutex
+	schemas map[string]Schema
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{schemas: make(map[string]Schema)}
+}
+
+// Register registers version 'version' of the schema of the payloads published to 't'. The topic's
+// payloads must be structs and it can only be registered once.
+func (r *Registry) Register(t eventbus.Topic, version int) error {
+	if version < 1 {
+		return fmt.Errorf("topic %s schema version %d must be at least 1", t.Name(), version)
+	}
+	pt := t.PayloadType()
+	if pt == nil || pt.Kind() != reflect.Struct {
+		return fmt.Errorf("topic %s payload %s must be a struct", t.Name(), pt)
+	}
+	js, err := schemaOf(pt, make(map[reflect.Type]bool))
+	if err != nil {
+		return fmt.Errorf("unable to describe topic %s payload: %w", t.Name(), err)
+	}
+	js.Schema = Draft
+	js.ID = fmt.Sprintf("%s/v%d", t.Name(), version)
+	js.Title = t.Name()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.schemas[t.Name()]; ok {
+		return fmt.Errorf("topic %s is already registered", t.Name())
+	}
+	r.schemas[t.Name()] = Schema{Topic: t.Name(), Version: version, JSON: js, payload: pt}
+	return nil
+}
+
+// Schema returns the Schema registered for topic 'topic', false if it isn't registered
+func (r *Registry) Schema(topic string) (Schema, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.schemas[topic]
+	return s, ok
+}
+
+// Schemas returns the registered Schemas ordered by topic
+func (r *Registry) Schemas() []Schema {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ss := make([]Schema, 0, len(r.schemas))
+	for _, s := range r.schemas {
+		ss = append(ss, s)
+	}
+	sort.Slice(ss, func(i, j int) bool { return ss[i].Topic < ss[j].Topic })
+	return ss
+}
+
+// Validate returns an error if 't' isn't registered, 'payload' isn't of its registered type, or any of
+// the payload's required fields, including those of nested structs, has its zero value
+func (r *Registry) Validate(t eventbus.Topic, payload interface{}) error {
+	s, ok := r.Schema(t.Name())
+	if !ok {
+		return fmt.Errorf("topic %s has no registered schema", t.Name())
+	}
+	if reflect.TypeOf(payload) != s.payload {
+		return fmt.Errorf("topic %s schema v%d requires a %s payload, got %T", t.Name(), s.Version, s.payload, payload)
+	}
+	return validate("", reflect.ValueOf(payload))
+}
+
+// validate returns an error if a required field of 'v', the value of the property at 'path', or of
+// the structs it contains has its zero value
+func validate(path string, v reflect.Value) error {
+	if v.Type() == timeType {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return validate(path, v.Elem())
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validate(fmt.Sprintf("%s[%d]", path, i), v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := validate(fmt.Sprintf("%s[%s]", path, iter.Key()), iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		for _, f := range fields(v.Type()) {
+			fv, err := fieldByIndex(v, f.index)
+			if err != nil {
+				// A field of a nil embedded pointer is missing, it isn't required
+				continue
+			}
+			if f.required && fv.IsZero() {
+				return fmt.Errorf("%s is required", join(path, f.name))
+			}
+			if err = validate(join(path, f.name), fv); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex except that an error is returned instead of panicking
+// when an embedded pointer is nil
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, errors.New("nil embedded pointer")
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
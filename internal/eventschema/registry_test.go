@@ -0,0 +1,101 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package eventschema
+
+import (
+	"testing"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/eventbus"
+)
+
+var (
+	changedTopic = eventbus.NewTopic("changed", change{})
+	countTopic   = eventbus.NewTopic("count", 0)
+)
+
+func TestRegister(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register(changedTopic, 0); err == nil {
+		t.Errorf("expected an error registering version 0")
+	}
+	if err := r.Register(countTopic, 1); err == nil {
+		t.Errorf("expected an error registering a topic whose payload isn't a struct")
+	}
+	if err := r.Register(changedTopic, 2); err != nil {
+		t.Fatalf("error %s was not expected registering %s", err, changedTopic.Name())
+	}
+	if err := r.Register(changedTopic, 3); err == nil {
+		t.Errorf("expected an error registering %s again", changedTopic.Name())
+	}
+
+	s, ok := r.Schema(changedTopic.Name())
+	if !ok {
+		t.Fatalf("expected %s to be registered", changedTopic.Name())
+	}
+	if s.Version != 2 || s.JSON.ID != "changed/v2" || s.JSON.Schema != Draft || s.JSON.Title != "changed" {
+		t.Errorf("expected version 2 of %s, got %+v", changedTopic.Name(), s)
+	}
+	if ss := r.Schemas(); len(ss) != 1 || ss[0].Topic != changedTopic.Name() {
+		t.Errorf("expected only the %s schema, got %+v", changedTopic.Name(), ss)
+	}
+	if _, ok = r.Schema(countTopic.Name()); ok {
+		t.Errorf("expected %s not to be registered", countTopic.Name())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register(changedTopic, 1); err != nil {
+		t.Fatalf("error %s was not expected registering %s", err, changedTopic.Name())
+	}
+	now := time.Now()
+	valid := change{audit: audit{By: "user:1", At: now}, ID: 1, Count: 2, Tags: []string{"a"}}
+
+	tcs := []struct {
+		testName   string
+		topic      eventbus.Topic
+		payload    interface{}
+		shouldPass bool
+	}{
+		{testName: "testValid", topic: changedTopic, payload: valid, shouldPass: true},
+		{
+			testName:   "testOptionalSet",
+			topic:      changedTopic,
+			payload:    change{audit: valid.audit, ID: 1, Count: 2, Tags: []string{"a"}, Name: "n", Previous: &audit{By: "user:2", At: now}},
+			shouldPass: true,
+		},
+		{testName: "testUnregistered", topic: countTopic, payload: 1, shouldPass: false},
+		{testName: "testWrongType", topic: changedTopic, payload: &valid, shouldPass: false},
+		{testName: "testNil", topic: changedTopic, payload: nil, shouldPass: false},
+		{
+			testName:   "testRequiredMissing",
+			topic:      changedTopic,
+			payload:    change{audit: valid.audit, Count: 2, Tags: []string{"a"}},
+			shouldPass: false,
+		},
+		{
+			testName:   "testEmbeddedRequiredMissing",
+			topic:      changedTopic,
+			payload:    change{audit: audit{By: "user:1"}, ID: 1, Count: 2, Tags: []string{"a"}},
+			shouldPass: false,
+		},
+		{
+			testName:   "testNestedRequiredMissing",
+			topic:      changedTopic,
+			payload:    change{audit: valid.audit, ID: 1, Count: 2, Tags: []string{"a"}, Previous: &audit{At: now}},
+			shouldPass: false,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			err := r.Validate(tc.topic, tc.payload)
+			if tc.shouldPass != (err == nil) {
+				t.Errorf("expected success %t, got error %v", tc.shouldPass, err)
+			}
+		})
+	}
+}
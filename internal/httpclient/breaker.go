@@ -0,0 +1,137 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package httpclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a Breaker
+type BreakerState int
+
+const (
+	// Closed breakers allow all requests
+	Closed BreakerState = iota
+	// Open breakers reject all requests until their cooldown period has passed
+	Open
+	// HalfOpen breakers allow a single trial request to decide whether to close or open again
+	HalfOpen
+)
+
+// Breaker is a circuit breaker. It opens after 'failureThreshold' consecutive failures and
+// stays open for 'cooldown'. It's safe for concurrent use.
+type Breaker struct {
+	failureThreshold int
+	cooldown         time.Duration
+	now              func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	// trialInFlight is true while a HalfOpen breaker waits for its trial request to complete
+	trialInFlight bool
+}
+
+// NewBreaker returns a closed Breaker. 'failureThreshold' and 'cooldown' must be greater than 0.
+func NewBreaker(failureThreshold int, cooldown time.Duration) (*Breaker, error) {
+	if failureThreshold < 1 {
+		return nil, errors.New("failureThreshold must be greater than 0")
+	}
+	if cooldown <= 0 {
+		return nil, errors.New("cooldown must be greater than 0")
+	}
+	return &Breaker{failureThreshold: failureThreshold, cooldown: cooldown, now: time.Now}, nil
+}
+
+// Allow returns true if a request may be made. Every allowed request must be followed by a call
+// to Record with the request's outcome.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = HalfOpen
+		b.trialInFlight = true
+		return true
+	case HalfOpen:
+		if b.trialInFlight {
+			return false
+		}
+		b.trialInFlight = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Record updates the breaker with the outcome of an allowed request
+func (b *Breaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.state = Closed
+		b.failures = 0
+		b.trialInFlight = false
+		return
+	}
+
+	b.failures++
+	if b.state == HalfOpen || b.failures >= b.failureThreshold {
+		b.state = Open
+		b.openedAt = b.now()
+		b.trialInFlight = false
+	}
+}
+
+// Trip opens the breaker immediately, regardless of the number of failures, e.g., when a failure
+// shows that further requests are certain to fail. Like a breaker opened by failures it allows a
+// trial request once its cooldown period has passed.
+func (b *Breaker) Trip() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = Open
+	b.openedAt = b.now()
+	b.trialInFlight = false
+}
+
+// State returns the breaker's current state
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// RetryAfter returns how long until the breaker allows a request, 0 if it's closed. An Open breaker
+// allows a trial request once its cooldown period has passed. While a HalfOpen breaker's trial
+// request is in flight it returns the cooldown period, the time the breaker stays open if the trial
+// request fails.
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if remaining := b.cooldown - b.now().Sub(b.openedAt); remaining > 0 {
+			return remaining
+		}
+		return 0
+	case HalfOpen:
+		if b.trialInFlight {
+			return b.cooldown
+		}
+		return 0
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package httpclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	// step is an action on the breaker, either Allow() (expecting 'allowed'), Trip(), or
+	// Record('success'), after advancing the clock by 'elapsed'
+	type step struct {
+		allow      bool
+		trip       bool
+		allowed    bool
+		success    bool
+		elapsed    time.Duration
+		state      BreakerState
+		retryAfter time.Duration
+	}
+
+	tcs := []struct {
+		testName string
+		steps    []step
+	}{
+		{
+			testName: "testStaysClosedBelowThreshold",
+			steps: []step{
+				{allow: true, allowed: true, state: Closed},
+				{success: false, state: Closed},
+				{allow: true, allowed: true, state: Closed},
+				{success: true, state: Closed},
+				{allow: true, allowed: true, state: Closed},
+				{success: false, state: Closed},
+			},
+		},
+		{
+			testName: "testOpensAtThreshold",
+			steps: []step{
+				{allow: true, allowed: true},
+				{success: false, state: Closed},
+				{allow: true, allowed: true},
+				{success: false, state: Open, retryAfter: time.Minute},
+				{allow: true, allowed: false, state: Open, retryAfter: time.Minute},
+				{allow: true, allowed: false, elapsed: 30 * time.Second, state: Open, retryAfter: 30 * time.Second},
+			},
+		},
+		{
+			testName: "testHalfOpenTrialSucceeds",
+			steps: []step{
+				{success: false},
+				{success: false, state: Open, retryAfter: time.Minute},
+				{allow: true, allowed: true, elapsed: time.Minute, state: HalfOpen, retryAfter: time.Minute},
+				{allow: true, allowed: false, state: HalfOpen, retryAfter: time.Minute},
+				{success: true, state: Closed},
+				{allow: true, allowed: true, state: Closed},
+			},
+		},
+		{
+			testName: "testHalfOpenTrialFails",
+			steps: []step{
+				{success: false},
+				{success: false, state: Open, retryAfter: time.Minute},
+				{allow: true, allowed: true, elapsed: time.Minute, state: HalfOpen, retryAfter: time.Minute},
+				{success: false, state: Open, retryAfter: time.Minute},
+				{allow: true, allowed: false, elapsed: 15 * time.Second, state: Open, retryAfter: 45 * time.Second},
+				{allow: true, allowed: true, elapsed: 45 * time.Second, state: HalfOpen, retryAfter: time.Minute},
+			},
+		},
+		{
+			testName: "testTrip",
+			steps: []step{
+				{allow: true, allowed: true, state: Closed},
+				{trip: true, state: Open, retryAfter: time.Minute},
+				{allow: true, allowed: false, state: Open, retryAfter: time.Minute},
+				{allow: true, allowed: true, elapsed: time.Minute, state: HalfOpen, retryAfter: time.Minute},
+				{success: true, state: Closed},
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			b, err := NewBreaker(2, time.Minute)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting a Breaker", err)
+			}
+			now := time.Now()
+			b.now = func() time.Time { return now }
+
+			for i, s := range tc.steps {
+				now = now.Add(s.elapsed)
+				if s.allow {
+					if allowed := b.Allow(); allowed != s.allowed {
+						t.Errorf("step %d: expected Allow() = %t, got %t", i, s.allowed, allowed)
+					}
+				} else if s.trip {
+					b.Trip()
+				} else {
+					b.Record(s.success)
+				}
+				if state := b.State(); state != s.state {
+					t.Errorf("step %d: expected state %d, got %d", i, s.state, state)
+				}
+				if retryAfter := b.RetryAfter(); retryAfter != s.retryAfter {
+					t.Errorf("step %d: expected RetryAfter() = %s, got %s", i, s.retryAfter, retryAfter)
+				}
+			}
+		})
+	}
+}
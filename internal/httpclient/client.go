@@ -0,0 +1,201 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/youngkin/mockvideo/internal/locale"
+	"github.com/youngkin/mockvideo/internal/metrics"
+)
+
+const rqstStatus = "rqstStatus"
+
+// transportError is the rqstStatus label value used when no response was received
+const transportError = "error"
+
+const (
+	// baseBackoff is how long to wait before the first retry, the wait doubles for each retry
+	baseBackoff = 100 * time.Millisecond
+	// maxBackoff is the longest wait between retries
+	maxBackoff = 2 * time.Second
+	// maxJitter is the largest fraction of the wait added as random jitter
+	maxJitter = 0.2
+)
+
+// ErrCircuitOpen is returned, wrapped, when a request isn't made because the downstream
+// service's circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// DownstreamRqstDur is used to capture the length of each attempt of a request to a downstream service
+var DownstreamRqstDur = metrics.NewHistogramVec(prometheus.HistogramOpts{
+	Subsystem: "downstream",
+	Name:      "request_duration_seconds",
+	Help:      "downstream service request duration distribution in seconds",
+	Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+}, []string{"service", rqstStatus})
+
+// BreakerOpen is 1 when a downstream service's circuit breaker is open or half-open and 0 when it's closed
+var BreakerOpen = metrics.NewGaugeVec(prometheus.GaugeOpts{
+	Subsystem: "downstream",
+	Name:      "circuit_breaker_open",
+	Help:      "1 if the circuit breaker for a downstream service is open or half-open, 0 if it's closed",
+}, []string{"service"})
+
+// Client makes requests to a single downstream service. It's safe for concurrent use.
+type Client struct {
+	service    string
+	httpClient *http.Client
+	maxRetries int
+	breaker    *Breaker
+}
+
+// New returns a Client for the downstream service named 'service', e.g., 'billingd'. Each attempt
+// of a request must complete within 'timeout'. Idempotent requests are retried up to 'maxRetries'
+// times. 'breaker' must be non-nil and should only be used by this Client.
+func New(service string, timeout time.Duration, maxRetries int, breaker *Breaker) (*Client, error) {
+	if len(service) == 0 {
+		return nil, errors.New("non-empty service required")
+	}
+	if timeout <= 0 {
+		return nil, errors.New("timeout must be greater than 0")
+	}
+	if maxRetries < 0 {
+		return nil, errors.New("maxRetries must not be negative")
+	}
+	if breaker == nil {
+		return nil, errors.New("non-nil *Breaker required")
+	}
+	BreakerOpen.WithLabelValues(service).Set(0)
+	return &Client{
+		service:    service,
+		httpClient: &http.Client{Timeout: timeout},
+		maxRetries: maxRetries,
+		breaker:    breaker,
+	}, nil
+}
+
+// Do sends 'req' and returns the response, as http.Client.Do does. Requests with a body are only
+// retried if 'req.GetBody' is set, as it is by http.NewRequest. If all attempts fail with a
+// retryable status the last response is returned. The caller must close the response body.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	retryable := isIdempotent(req.Method) && (req.Body == nil || req.GetBody != nil)
+
+	for attempt := 0; ; attempt++ {
+		rqst, err := c.prepare(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+		if !c.breaker.Allow() {
+			return nil, fmt.Errorf("%s %s %s: %w", c.service, req.Method, req.URL.Path, ErrCircuitOpen)
+		}
+
+		start := time.Now()
+		resp, err := c.httpClient.Do(rqst)
+		status := transportError
+		if err == nil {
+			status = strconv.Itoa(resp.StatusCode)
+		}
+		DownstreamRqstDur.WithLabelValues(c.service, status).
+			Observe(float64(time.Since(start)) / float64(time.Second))
+		// Requests canceled by the caller say nothing about the downstream service's health so
+		// they're recorded as successes to avoid opening the breaker
+		c.record(ctx.Err() != nil || (err == nil && resp.StatusCode < http.StatusInternalServerError))
+
+		if !retryable || attempt >= c.maxRetries || !shouldRetry(resp, err) || ctx.Err() != nil {
+			return resp, err
+		}
+
+		if resp != nil {
+			// Drain the body so the connection can be reused
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := sleep(ctx, backoff(attempt+1)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// prepare returns the request to send for attempt number 'attempt' (starting at 0), including a
+// fresh body for retries, the traceparent header, and the caller's language preferences
+func (c *Client) prepare(req *http.Request, attempt int) (*http.Request, error) {
+	rqst := req.Clone(req.Context())
+	if attempt > 0 && req.Body != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		rqst.Body = body
+	}
+	if tp, ok := currentTracer().ChildTraceParent(req.Context()); ok {
+		rqst.Header.Set(TraceParentHeader, tp)
+	}
+	if tags, ok := locale.FromContext(req.Context()); ok && rqst.Header.Get(locale.Header) == "" {
+		rqst.Header.Set(locale.Header, locale.Format(tags))
+	}
+	return rqst, nil
+}
+
+func (c *Client) record(success bool) {
+	c.breaker.Record(success)
+	open := 0.0
+	if c.breaker.State() != Closed {
+		open = 1
+	}
+	BreakerOpen.WithLabelValues(c.service).Set(open)
+}
+
+// shouldRetry returns true if a request that resulted in 'resp' or 'err' may succeed if retried
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isIdempotent returns true if requests using 'method' can safely be retried
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// backoff returns how long to wait before retry number 'retry' (starting at 1), including jitter
+func backoff(retry int) time.Duration {
+	wait := baseBackoff << uint(retry-1)
+	if wait > maxBackoff || wait <= 0 {
+		wait = maxBackoff
+	}
+	return wait + time.Duration(rand.Float64()*maxJitter*float64(wait))
+}
+
+// sleep waits for 'd' or until 'ctx' is done, whichever comes first
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
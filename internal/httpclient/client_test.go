@@ -0,0 +1,318 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/locale"
+)
+
+func TestDo(t *testing.T) {
+	tcs := []struct {
+		testName         string
+		method           string
+		body             string
+		maxRetries       int
+		statuses         []int
+		delay            time.Duration
+		expectedStatus   int
+		expectedAttempts int32
+		shouldError      bool
+	}{
+		{
+			testName:         "testSuccess",
+			method:           http.MethodGet,
+			statuses:         []int{http.StatusOK},
+			maxRetries:       2,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 1,
+		},
+		{
+			testName:         "testRetriedThenSuccess",
+			method:           http.MethodGet,
+			statuses:         []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+			maxRetries:       2,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 3,
+		},
+		{
+			testName:         "testRetriesExhausted",
+			method:           http.MethodGet,
+			statuses:         []int{http.StatusBadGateway, http.StatusBadGateway},
+			maxRetries:       1,
+			expectedStatus:   http.StatusBadGateway,
+			expectedAttempts: 2,
+		},
+		{
+			testName:         "testPUTWithBodyRetried",
+			method:           http.MethodPut,
+			body:             `{"id":1}`,
+			statuses:         []int{http.StatusServiceUnavailable, http.StatusOK},
+			maxRetries:       1,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 2,
+		},
+		{
+			testName:         "testPOSTNotRetried",
+			method:           http.MethodPost,
+			body:             `{"id":1}`,
+			statuses:         []int{http.StatusServiceUnavailable, http.StatusOK},
+			maxRetries:       2,
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 1,
+		},
+		{
+			testName:         "testInternalServerErrorNotRetried",
+			method:           http.MethodGet,
+			statuses:         []int{http.StatusInternalServerError, http.StatusOK},
+			maxRetries:       2,
+			expectedStatus:   http.StatusInternalServerError,
+			expectedAttempts: 1,
+		},
+		{
+			testName:         "testTimeout",
+			method:           http.MethodGet,
+			statuses:         []int{http.StatusOK},
+			delay:            200 * time.Millisecond,
+			maxRetries:       0,
+			expectedAttempts: 1,
+			shouldError:      true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			var attempts int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&attempts, 1)
+				body, _ := ioutil.ReadAll(r.Body)
+				if string(body) != tc.body {
+					t.Errorf("attempt %d: expected body %s, got %s", n, tc.body, body)
+				}
+				time.Sleep(tc.delay)
+				w.WriteHeader(tc.statuses[int(n)-1])
+			}))
+			defer srv.Close()
+
+			b, _ := NewBreaker(10, time.Minute)
+			c, err := New("testsvc", 100*time.Millisecond, tc.maxRetries, b)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting a Client", err)
+			}
+
+			var body *strings.Reader
+			req, _ := http.NewRequest(tc.method, srv.URL, nil)
+			if len(tc.body) > 0 {
+				body = strings.NewReader(tc.body)
+				req, _ = http.NewRequest(tc.method, srv.URL, body)
+			}
+
+			resp, err := c.Do(req)
+			if tc.shouldError {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("expected an error, got none")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("error %s was not expected", err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != tc.expectedStatus {
+					t.Errorf("expected status %d, got %d", tc.expectedStatus, resp.StatusCode)
+				}
+			}
+			if n := atomic.LoadInt32(&attempts); n != tc.expectedAttempts {
+				t.Errorf("expected %d attempts, got %d", tc.expectedAttempts, n)
+			}
+		})
+	}
+}
+
+func TestDoCircuitOpen(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	b, _ := NewBreaker(2, time.Minute)
+	c, err := New("testsvc", time.Second, 0, b)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting a Client", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		resp, err := c.Do(req)
+		if i < 2 {
+			if err != nil {
+				t.Fatalf("request %d: error %s was not expected", i, err)
+			}
+			resp.Body.Close()
+			continue
+		}
+		if !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("request %d: expected ErrCircuitOpen, got %v", i, err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&attempts); n != 2 {
+		t.Errorf("expected 2 attempts, got %d", n)
+	}
+}
+
+func TestTracePropagation(t *testing.T) {
+	const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	tcs := []struct {
+		testName        string
+		traceParent     string
+		disabled        bool
+		expectedTraceID string
+	}{
+		{
+			testName:        "testPropagated",
+			traceParent:     incoming,
+			expectedTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			testName: "testNewTrace",
+		},
+		{
+			testName:    "testInvalidIgnored",
+			traceParent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		},
+		{
+			testName:    "testTracingDisabled",
+			traceParent: incoming,
+			disabled:    true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			var downstream string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				downstream = r.Header.Get(TraceParentHeader)
+			}))
+			defer srv.Close()
+
+			b, _ := NewBreaker(10, time.Minute)
+			c, _ := New("testsvc", time.Second, 0, b)
+			if tc.disabled {
+				SetTracer(NoopTracer{})
+				defer SetTracer(W3CTracer{})
+			}
+
+			// The handler makes a downstream request in the context of the incoming request
+			h := TraceMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, srv.URL, nil)
+				resp, err := c.Do(req)
+				if err != nil {
+					t.Fatalf("error %s was not expected", err)
+				}
+				resp.Body.Close()
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/accounts/1/summary", nil)
+			if len(tc.traceParent) > 0 {
+				req.Header.Set(TraceParentHeader, tc.traceParent)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if tc.disabled {
+				if len(downstream) > 0 {
+					t.Errorf("expected no downstream traceparent, got %s", downstream)
+				}
+				return
+			}
+			if !isValidTraceParent(downstream) {
+				t.Fatalf("expected a valid downstream traceparent, got %s", downstream)
+			}
+			parts := strings.Split(downstream, "-")
+			if len(tc.expectedTraceID) > 0 && parts[1] != tc.expectedTraceID {
+				t.Errorf("expected trace ID %s, got %s", tc.expectedTraceID, parts[1])
+			}
+			if downstream == tc.traceParent || strings.Contains(downstream, "00000000000000000000000000000000") {
+				t.Errorf("expected a new parent ID, got %s", downstream)
+			}
+		})
+	}
+}
+
+func TestLocalePropagation(t *testing.T) {
+	tcs := []struct {
+		testName       string
+		acceptLanguage string
+		rqstHeader     string
+		expected       string
+	}{
+		{testName: "testPropagated", acceptLanguage: "fr-CA, en;q=0.5", expected: "fr-CA, en;q=0.9"},
+		{testName: "testNoPreferences", expected: ""},
+		{testName: "testExplicitHeaderKept", acceptLanguage: "fr-CA", rqstHeader: "de", expected: "de"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			var downstream string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				downstream = r.Header.Get(locale.Header)
+			}))
+			defer srv.Close()
+
+			b, _ := NewBreaker(10, time.Minute)
+			c, _ := New("testsvc", time.Second, 0, b)
+
+			h := locale.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, srv.URL, nil)
+				if tc.rqstHeader != "" {
+					req.Header.Set(locale.Header, tc.rqstHeader)
+				}
+				resp, err := c.Do(req)
+				if err != nil {
+					t.Fatalf("error %s was not expected", err)
+				}
+				resp.Body.Close()
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/accounts/1/summary", nil)
+			if tc.acceptLanguage != "" {
+				req.Header.Set(locale.Header, tc.acceptLanguage)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if downstream != tc.expected {
+				t.Errorf("expected downstream %s %q, got %q", locale.Header, tc.expected, downstream)
+			}
+		})
+	}
+}
+
+func TestDoCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	b, _ := NewBreaker(10, time.Minute)
+	c, _ := New("testsvc", time.Second, 5, b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, bytes.NewReader(nil))
+	if _, err := c.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package httpclient provides the HTTP client used for all calls from a mockvideo service to another
+mockvideo service (e.g., accountd to billingd).
+
+A Client adds the following to a standard http.Client:
+
+1. A timeout for each attempt of a request.
+2. Retries, with exponential backoff and jitter, of idempotent requests (e.g., GET) that fail because
+of a network error, a 429 (Too Many Requests), or a 502, 503, or 504 response.
+3. A circuit breaker (see Breaker). After a number of consecutive failures (network errors or 5xx
+responses) requests fail immediately with ErrCircuitOpen, without calling the downstream service,
+until a cooldown period has passed. A single trial request is then allowed through. If it succeeds
+the breaker closes, otherwise it opens again.
+4. Trace propagation. A W3C 'traceparent' header is added to each request. If the request's context
+contains the traceparent of the request being handled (see TraceMiddleware) the downstream request
+is part of the same trace, otherwise a new trace is started.
+5. Metrics. The duration of each attempt and the state of each circuit breaker are reported using
+Prometheus (see DownstreamRqstDur and BreakerOpen).
+
+ObserveSince is used by request handlers to record request durations. When the request is part of a
+trace it attaches the trace ID to the observation as a Prometheus exemplar, labeled 'trace_id'. This
+lets a dashboard link a latency spike directly to a trace of an offending request. Exemplars require
+client_golang v1.4.0 or later and exposition in the OpenMetrics format (e.g., 'promhttp.HandlerOpts'
+'EnableOpenMetrics'). With earlier versions of client_golang the observation is recorded without an
+exemplar.
+*/
+package httpclient
@@ -0,0 +1,47 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package httpclient
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TraceIDLabel is the name of the exemplar label containing the trace ID
+const TraceIDLabel = "trace_id"
+
+// exemplarObserver is implemented by observers that can attach an exemplar to an observation.
+// It matches prometheus.ExemplarObserver, which was added in client_golang v1.4.0.
+type exemplarObserver interface {
+	ObserveWithExemplar(value float64, exemplar prometheus.Labels)
+}
+
+// TraceIDFromContext returns the trace ID of the traceparent in 'ctx', if any
+func TraceIDFromContext(ctx context.Context) (string, bool) {
+	tp, ok := TraceParentFromContext(ctx)
+	if !ok || !isValidTraceParent(tp) {
+		return "", false
+	}
+	return strings.Split(tp, "-")[1], true
+}
+
+// ObserveSince records the time elapsed since 'start', in seconds, using 'obs'. If 'ctx' contains
+// a traceparent, and 'obs' supports exemplars, the trace ID is attached to the observation as an
+// exemplar. This allows a latency spike on a dashboard to be linked to the trace of a request that
+// caused it. Observers that don't support exemplars record the observation without one.
+func ObserveSince(ctx context.Context, obs prometheus.Observer, start time.Time) {
+	v := float64(time.Since(start)) / float64(time.Second)
+
+	if eo, ok := obs.(exemplarObserver); ok {
+		if traceID, ok := TraceIDFromContext(ctx); ok {
+			eo.ObserveWithExemplar(v, prometheus.Labels{TraceIDLabel: traceID})
+			return
+		}
+	}
+	obs.Observe(v)
+}
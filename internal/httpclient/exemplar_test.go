@@ -0,0 +1,91 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package httpclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakeObserver records observations, it optionally supports exemplars
+type fakeObserver struct {
+	values    []float64
+	exemplars []prometheus.Labels
+}
+
+func (o *fakeObserver) Observe(v float64) {
+	o.values = append(o.values, v)
+}
+
+type fakeExemplarObserver struct {
+	fakeObserver
+}
+
+func (o *fakeExemplarObserver) ObserveWithExemplar(v float64, exemplar prometheus.Labels) {
+	o.values = append(o.values, v)
+	o.exemplars = append(o.exemplars, exemplar)
+}
+
+func TestObserveSince(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	traced := NewTraceContext(context.Background(), "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	tcs := []struct {
+		testName         string
+		ctx              context.Context
+		supportsExemplar bool
+		expectedExemplar prometheus.Labels
+	}{
+		{
+			testName:         "testObserveSinceWithExemplar",
+			ctx:              traced,
+			supportsExemplar: true,
+			expectedExemplar: prometheus.Labels{TraceIDLabel: traceID},
+		},
+		{
+			testName:         "testObserveSinceNoTrace",
+			ctx:              context.Background(),
+			supportsExemplar: true,
+		},
+		{
+			testName:         "testObserveSinceInvalidTrace",
+			ctx:              NewTraceContext(context.Background(), "00-notatrace"),
+			supportsExemplar: true,
+		},
+		{
+			testName: "testObserveSinceExemplarsUnsupported",
+			ctx:      traced,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			var obs prometheus.Observer
+			var recorded *fakeObserver
+			if tc.supportsExemplar {
+				eo := &fakeExemplarObserver{}
+				obs, recorded = eo, &eo.fakeObserver
+			} else {
+				o := &fakeObserver{}
+				obs, recorded = o, o
+			}
+
+			ObserveSince(tc.ctx, obs, time.Now().Add(-time.Second))
+
+			if len(recorded.values) != 1 || recorded.values[0] < 1 {
+				t.Fatalf("expected a single observation of at least 1 second, got %v", recorded.values)
+			}
+			switch {
+			case tc.expectedExemplar == nil && len(recorded.exemplars) != 0:
+				t.Errorf("expected no exemplar, got %v", recorded.exemplars)
+			case tc.expectedExemplar != nil && (len(recorded.exemplars) != 1 || recorded.exemplars[0][TraceIDLabel] != traceID):
+				t.Errorf("expected exemplar %v, got %v", tc.expectedExemplar, recorded.exemplars)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Span is a named, timed, unit of work within a trace, e.g., a batch of a bulk request. Spans
+// are reported by whoever starts them, e.g., by logging them.
+type Span struct {
+	Name    string
+	TraceID string
+	SpanID  string
+	// ParentID is the span ID of the span, or of the upstream request, this span is part of. It's
+	// empty if the span started a new trace.
+	ParentID string
+	Start    time.Time
+	// Duration is set by End
+	Duration time.Duration
+	flags    string
+}
+
+// StartSpan starts a span named 'name' using the Tracer set by SetTracer, see W3CTracer.StartSpan
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	return currentTracer().StartSpan(ctx, name)
+}
+
+// TraceParent returns the span's W3C traceparent
+func (s *Span) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-%s", s.TraceID, s.SpanID, s.flags)
+}
+
+// End records the span's duration. It should be called once, when the span's work is complete.
+func (s *Span) End() {
+	s.Duration = time.Since(s.Start)
+}
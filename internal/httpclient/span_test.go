@@ -0,0 +1,82 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package httpclient
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStartSpan(t *testing.T) {
+	incoming := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"
+
+	tcs := []struct {
+		testName         string
+		traceParent      string
+		expectedTraceID  string
+		expectedParentID string
+		expectedFlags    string
+	}{
+		{
+			testName:         "testChildOfRequest",
+			traceParent:      incoming,
+			expectedTraceID:  "4bf92f3577b34da6a3ce929d0e0e4736",
+			expectedParentID: "00f067aa0ba902b7",
+			expectedFlags:    "00",
+		},
+		{
+			testName:      "testNewTrace",
+			expectedFlags: "01",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.traceParent != "" {
+				ctx = NewTraceContext(ctx, tc.traceParent)
+			}
+
+			batchCtx, batch := StartSpan(ctx, "batch")
+			if tc.expectedTraceID != "" && batch.TraceID != tc.expectedTraceID {
+				t.Errorf("expected trace ID %s, got %s", tc.expectedTraceID, batch.TraceID)
+			}
+			if batch.ParentID != tc.expectedParentID {
+				t.Errorf("expected parent ID %q, got %q", tc.expectedParentID, batch.ParentID)
+			}
+			tp, _ := TraceParentFromContext(batchCtx)
+			if !isValidTraceParent(tp) || tp != batch.TraceParent() || tp[len(tp)-2:] != tc.expectedFlags {
+				t.Errorf("expected the context to contain the span's valid traceparent with flags %s, got %s", tc.expectedFlags, tp)
+			}
+
+			_, item := StartSpan(batchCtx, "item")
+			if item.TraceID != batch.TraceID || item.ParentID != batch.SpanID {
+				t.Errorf("expected a child of %+v, got %+v", batch, item)
+			}
+			item.End()
+			batch.End()
+			if batch.Duration < item.Duration {
+				t.Errorf("expected the batch to take at least as long as its item, got %s and %s", batch.Duration, item.Duration)
+			}
+		})
+	}
+}
+
+func TestNoopTracer(t *testing.T) {
+	SetTracer(NoopTracer{})
+	defer SetTracer(W3CTracer{})
+
+	ctx := NewTraceContext(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	spanCtx, s := StartSpan(ctx, "batch")
+	if spanCtx != ctx {
+		t.Errorf("expected the context to be unchanged")
+	}
+	if s.TraceID != "" || s.SpanID != "" {
+		t.Errorf("expected a span without IDs, got %+v", s)
+	}
+	if tp, ok := currentTracer().ChildTraceParent(ctx); ok {
+		t.Errorf("expected no downstream traceparent, got %s", tp)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package httpclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// TraceParentHeader is the W3C trace context header propagated to downstream services
+const TraceParentHeader = "traceparent"
+
+type traceKey struct{}
+
+// NewTraceContext returns a copy of 'ctx' containing 'traceParent', the W3C traceparent of the
+// request being handled
+func NewTraceContext(ctx context.Context, traceParent string) context.Context {
+	return context.WithValue(ctx, traceKey{}, traceParent)
+}
+
+// TraceParentFromContext returns the traceparent in 'ctx', if any
+func TraceParentFromContext(ctx context.Context) (string, bool) {
+	tp, ok := ctx.Value(traceKey{}).(string)
+	return tp, ok
+}
+
+// TraceMiddleware adds the traceparent of incoming requests to their context using the Tracer set by
+// SetTracer, see W3CTracer.Middleware
+func TraceMiddleware(next http.Handler) http.Handler {
+	return currentTracer().Middleware(next)
+}
+
+// isValidTraceParent returns true if 'tp' is a version 00 W3C traceparent, e.g.,
+// '00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01'
+func isValidTraceParent(tp string) bool {
+	parts := strings.Split(tp, "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return false
+	}
+	for _, p := range parts[1:] {
+		if _, err := hex.DecodeString(p); err != nil {
+			return false
+		}
+	}
+	// All zero trace and parent IDs are invalid
+	return strings.Trim(parts[1], "0") != "" && strings.Trim(parts[2], "0") != ""
+}
+
+func randomHex(numBytes int) string {
+	b := make([]byte, numBytes)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand doesn't fail on supported platforms, this just ensures the ID isn't all zeroes
+		b[0] = 1
+	}
+	return hex.EncodeToString(b)
+}
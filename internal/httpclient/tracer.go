@@ -0,0 +1,109 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Tracer starts spans and propagates W3C trace context from incoming requests to downstream
+// services. W3CTracer is used unless SetTracer sets another, e.g., NoopTracer.
+type Tracer interface {
+	// StartSpan starts a span named 'name'. The returned context is used for the span's work.
+	StartSpan(ctx context.Context, name string) (context.Context, *Span)
+	// Middleware adds the trace context of incoming requests to their context
+	Middleware(next http.Handler) http.Handler
+	// ChildTraceParent returns the traceparent header of a downstream request made in 'ctx'. It
+	// returns false if the request shouldn't have one.
+	ChildTraceParent(ctx context.Context) (string, bool)
+}
+
+// tracerHolder lets tracer hold any Tracer, atomic.Value requires a consistent concrete type
+type tracerHolder struct {
+	Tracer
+}
+
+var tracer atomic.Value
+
+func init() {
+	tracer.Store(tracerHolder{W3CTracer{}})
+}
+
+// SetTracer sets the Tracer used by StartSpan, TraceMiddleware, and Clients. It's meant to be
+// called at startup, e.g., with NoopTracer when traces aren't collected.
+func SetTracer(t Tracer) {
+	tracer.Store(tracerHolder{t})
+}
+
+func currentTracer() Tracer {
+	return tracer.Load().(tracerHolder).Tracer
+}
+
+// W3CTracer is the default Tracer, it propagates W3C traceparent headers
+type W3CTracer struct{}
+
+// StartSpan starts a span named 'name' that's a child of the traceparent in 'ctx', or that starts
+// a new trace if there isn't one. The returned context contains the span's traceparent, so spans
+// started, and downstream requests made, with it are children of the span.
+func (W3CTracer) StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	s := &Span{Name: name, SpanID: randomHex(8), Start: time.Now(), flags: "01"}
+	if tp, ok := TraceParentFromContext(ctx); ok && isValidTraceParent(tp) {
+		parts := strings.Split(tp, "-")
+		s.TraceID, s.ParentID, s.flags = parts[1], parts[2], parts[3]
+	} else {
+		s.TraceID = randomHex(16)
+	}
+	return NewTraceContext(ctx, s.TraceParent()), s
+}
+
+// Middleware adds the traceparent of incoming requests, if it's valid, to the request's context
+// so that it's propagated by a Client to downstream services
+func (W3CTracer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tp := r.Header.Get(TraceParentHeader); isValidTraceParent(tp) {
+			r = r.WithContext(NewTraceContext(r.Context(), tp))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ChildTraceParent returns the traceparent for a downstream request. It has the same trace ID
+// and flags as the traceparent in 'ctx' and a new parent (span) ID. If 'ctx' doesn't contain a
+// traceparent a new, sampled, trace is started.
+func (W3CTracer) ChildTraceParent(ctx context.Context) (string, bool) {
+	traceID, flags := "", "01"
+	if tp, ok := TraceParentFromContext(ctx); ok && isValidTraceParent(tp) {
+		parts := strings.Split(tp, "-")
+		traceID, flags = parts[1], parts[3]
+	} else {
+		traceID = randomHex(16)
+	}
+	return fmt.Sprintf("00-%s-%s-%s", traceID, randomHex(8), flags), true
+}
+
+// NoopTracer is a Tracer that does no tracing. Its spans are only timed, they don't have IDs and
+// aren't added to the context. Incoming traceparent headers are ignored and none are sent downstream,
+// so observations don't have trace exemplars either, see ObserveSince.
+type NoopTracer struct{}
+
+// StartSpan returns 'ctx' and a span that's only timed
+func (NoopTracer) StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	return ctx, &Span{Name: name, Start: time.Now()}
+}
+
+// Middleware returns 'next'
+func (NoopTracer) Middleware(next http.Handler) http.Handler {
+	return next
+}
+
+// ChildTraceParent returns false
+func (NoopTracer) ChildTraceParent(ctx context.Context) (string, bool) {
+	return "", false
+}
@@ -0,0 +1,18 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package idgen assigns the IDs of new records. By default repositories let the database assign IDs, e.g.,
+using MySQL's AUTO_INCREMENT, so a record's ID depends on the order records are created in. Users created
+by a bulk request are created concurrently, so their IDs can't be predicted, which makes tests of bulk
+requests brittle. A Generator set on a repository assigns IDs instead:
+
+		repo := memory.NewUserTable()
+		repo.SetIDGenerator(idgen.Keyed{"mickeyd@gmail.com": 1, "petert@gmail.com": 2})
+
+Keyed assigns the ID listed for each record's key, a user's email address, regardless of the order the
+records are created in. Sequence assigns successive IDs in the order they're requested, like the database,
+but starting from a known ID. Generators are intended for tests, production code uses the database's IDs.
+*/
+package idgen
@@ -0,0 +1,48 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package idgen
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Generator assigns the ID of a new record identified by 'key', e.g., a user's email address.
+// Implementations must be safe for concurrent use.
+type Generator interface {
+	NextID(key string) (int, error)
+}
+
+// Sequence assigns successive IDs, ignoring keys. It's safe for concurrent use.
+type Sequence struct {
+	mu   sync.Mutex
+	next int
+}
+
+// NewSequence returns a Sequence whose first ID is 'first'
+func NewSequence(first int) *Sequence {
+	return &Sequence{next: first}
+}
+
+// NextID returns the next ID in the sequence
+func (s *Sequence) NextID(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.next
+	s.next++
+	return id, nil
+}
+
+// Keyed maps keys to the IDs assigned to them. An error is returned for a key that isn't listed.
+type Keyed map[string]int
+
+// NextID returns the ID listed for 'key'
+func (k Keyed) NextID(key string) (int, error) {
+	id, ok := k[key]
+	if !ok {
+		return 0, fmt.Errorf("no ID listed for %q", key)
+	}
+	return id, nil
+}
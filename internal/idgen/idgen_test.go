@@ -0,0 +1,52 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package idgen
+
+import (
+	"testing"
+)
+
+func TestGenerators(t *testing.T) {
+	tcs := []struct {
+		testName    string
+		gen         Generator
+		keys        []string
+		expectedIDs []int
+		expectErr   bool
+	}{
+		{
+			testName:    "testSequence",
+			gen:         NewSequence(10),
+			keys:        []string{"b", "a", "b"},
+			expectedIDs: []int{10, 11, 12},
+		},
+		{
+			testName:    "testKeyed",
+			gen:         Keyed{"a": 1, "b": 2},
+			keys:        []string{"b", "a", "b"},
+			expectedIDs: []int{2, 1, 2},
+		},
+		{
+			testName:  "testKeyedUnlisted",
+			gen:       Keyed{"a": 1},
+			keys:      []string{"c"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			for i, key := range tc.keys {
+				id, err := tc.gen.NextID(key)
+				if (err != nil) != tc.expectErr {
+					t.Fatalf("expected error %t, got %v", tc.expectErr, err)
+				}
+				if err == nil && id != tc.expectedIDs[i] {
+					t.Errorf("expected ID %d for %q, got %d", tc.expectedIDs[i], key, id)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package inflight keeps track of the HTTP requests being handled so that support staff can see what
+// a busy service is doing and cancel a runaway request, e.g., a bulk request hogging the DB. Middleware
+// assigns each request an ID, returned to the client in the X-Request-ID response header, and registers
+// it with a Tracker until it has been handled. Cancelling a request cancels its context, the handler
+// stops at its next context check, e.g., before its next DB query, and the request completes with
+// whatever error that produces.
+package inflight
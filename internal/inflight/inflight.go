@@ -0,0 +1,137 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package inflight
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/clock"
+)
+
+// IDHeader is the response header identifying the request
+const IDHeader = "X-Request-ID"
+
+// Request describes a request being handled
+type Request struct {
+	ID       string    `json:"id"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Started  time.Time `json:"started"`
+	Duration string    `json:"duration"`
+}
+
+// Tracker keeps track of the requests being handled by Middleware, it's safe for concurrent use
+type Tracker struct {
+	mu     sync.Mutex
+	clock  clock.Clock
+	nextID uint64
+	rqsts  map[string]*tracked
+}
+
+// tracked is a request registered with a Tracker
+type tracked struct {
+	rqst   Request
+	cancel context.CancelFunc
+}
+
+// NewTracker returns a Tracker without any requests
+func NewTracker() *Tracker {
+	return &Tracker{clock: clock.System, rqsts: make(map[string]*tracked)}
+}
+
+// SetClock replaces the Clock, clock.System by default, used to time requests. 'c' must be non-nil.
+func (t *Tracker) SetClock(c clock.Clock) error {
+	if c == nil {
+		return errors.New("non-nil Clock required")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.clock = c
+	return nil
+}
+
+// Requests returns the requests being handled, oldest first
+func (t *Tracker) Requests() []Request {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.clock.Now()
+	rqsts := make([]Request, 0, len(t.rqsts))
+	for _, tr := range t.rqsts {
+		r := tr.rqst
+		r.Duration = now.Sub(r.Started).String()
+		rqsts = append(rqsts, r)
+	}
+	sort.Slice(rqsts, func(i, j int) bool {
+		if !rqsts[i].Started.Equal(rqsts[j].Started) {
+			return rqsts[i].Started.Before(rqsts[j].Started)
+		}
+		return rqsts[i].ID < rqsts[j].ID
+	})
+	return rqsts
+}
+
+// Cancel cancels the context of the request identified by 'id'. It returns false if the request isn't
+// being handled, e.g., because it has already completed.
+func (t *Tracker) Cancel(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tr, ok := t.rqsts[id]
+	if !ok {
+		return false
+	}
+	tr.cancel()
+	return true
+}
+
+// register starts tracking 'r', returning its ID and a context that's cancelled by Cancel
+func (t *Tracker) register(r *http.Request) (string, context.Context) {
+	ctx, cancel := context.WithCancel(r.Context())
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextID++
+	id := strconv.FormatUint(t.nextID, 10)
+	t.rqsts[id] = &tracked{
+		rqst:   Request{ID: id, Method: r.Method, Path: r.URL.Path, Started: t.clock.Now()},
+		cancel: cancel,
+	}
+	return id, ctx
+}
+
+// unregister stops tracking the request identified by 'id'
+func (t *Tracker) unregister(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tr, ok := t.rqsts[id]; ok {
+		tr.cancel()
+		delete(t.rqsts, id)
+	}
+}
+
+type contextKey struct{}
+
+// FromContext returns the ID of the request whose context is 'ctx', or "" if it isn't tracked
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Middleware registers the requests handled by the next handler with 't' until they've been handled.
+// Each request's ID is added to its context, see FromContext, and returned in the IDHeader response header.
+func Middleware(t *Tracker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, ctx := t.register(r)
+			defer t.unregister(id)
+			w.Header().Set(IDHeader, id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, contextKey{}, id)))
+		})
+	}
+}
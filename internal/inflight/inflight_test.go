@@ -0,0 +1,57 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package inflight
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/clock"
+)
+
+func TestMiddleware(t *testing.T) {
+	tracker := NewTracker()
+	clk := clock.NewFrozen(time.Date(2020, 7, 4, 9, 30, 0, 0, time.UTC))
+	if err := tracker.SetClock(clk); err != nil {
+		t.Fatalf("error %s was not expected setting the clock", err)
+	}
+
+	var during []Request
+	cancelled := false
+	h := Middleware(tracker)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clk.Advance(2 * time.Second)
+		during = tracker.Requests()
+		if !tracker.Cancel(FromContext(r.Context())) {
+			t.Errorf("expected request %q to be cancelled", FromContext(r.Context()))
+		}
+		select {
+		case <-r.Context().Done():
+			cancelled = true
+		default:
+		}
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/users", nil))
+
+	expected := Request{ID: "1", Method: http.MethodPost, Path: "/users", Started: time.Date(2020, 7, 4, 9, 30, 0, 0, time.UTC), Duration: "2s"}
+	if len(during) != 1 || during[0] != expected {
+		t.Errorf("expected %+v to be in-flight, got %+v", expected, during)
+	}
+	if !cancelled {
+		t.Errorf("expected the request's context to be cancelled")
+	}
+	if id := rr.Header().Get(IDHeader); id != "1" {
+		t.Errorf("expected %s 1, got %q", IDHeader, id)
+	}
+	if rqsts := tracker.Requests(); len(rqsts) != 0 {
+		t.Errorf("expected no requests once handled, got %+v", rqsts)
+	}
+	if tracker.Cancel("1") {
+		t.Errorf("expected a completed request not to be cancelled")
+	}
+}
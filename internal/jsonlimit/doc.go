@@ -0,0 +1,12 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package jsonlimit limits the complexity of JSON request bodies. A small body can still be costly
+// to decode, e.g., thousands of nested arrays, '[[[[...]]]]', or millions of empty objects, '{},{},...',
+// each of which is allocated by the decoder. Middleware wraps each request's body so that reads fail
+// with an error satisfying IsTooComplex once the body nests deeper than Limits.MaxDepth or contains
+// more than Limits.MaxItems object fields and array elements in total. The body is checked as it's
+// read, before the decoder sees the offending data. Handlers report the error to the client as a
+// 400 (Bad Request) HTTP status.
+package jsonlimit
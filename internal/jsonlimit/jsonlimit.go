@@ -0,0 +1,130 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package jsonlimit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrTooComplex is wrapped by the errors returned by reads of a request body that exceeds its Limits
+var ErrTooComplex = errors.New("JSON request body is too complex")
+
+// IsTooComplex returns true if 'err' indicates that a request body exceeded its Limits
+func IsTooComplex(err error) bool {
+	return errors.Is(err, ErrTooComplex)
+}
+
+// Limits are the limits on the complexity of a JSON request body. A zero limit doesn't limit the
+// body.
+type Limits struct {
+	// MaxDepth limits the nesting of objects and arrays, e.g., '{"a":[1]}' has a depth of 2
+	MaxDepth int
+	// MaxItems limits the total number of object fields and array elements, at any depth, e.g.,
+	// '{"a":[1,2]}' has 3 items
+	MaxItems int
+}
+
+// Middleware limits the complexity of each request's body to 'limits'. Bodies aren't wrapped if
+// 'limits' doesn't limit them.
+func Middleware(limits Limits) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limits.MaxDepth <= 0 && limits.MaxItems <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.Body = &body{ReadCloser: r.Body, limits: limits}
+			next.ServeHTTP(w, r2)
+		})
+	}
+}
+
+// body is a request body whose reads fail once the JSON read exceeds its limits. It scans the JSON's
+// structure, it doesn't validate it, invalid JSON is left to the decoder to report.
+type body struct {
+	io.ReadCloser
+	limits Limits
+	depth  int
+	items  int
+	// inString and escaped track whether the next byte is in a string, and escaped by '\'
+	inString bool
+	escaped  bool
+	// first is true from the start of an object or array until its first item, or its end, is read
+	first bool
+	// err is returned by every read once the limits have been exceeded
+	err error
+}
+
+// Read reads up to len(p) bytes from the underlying body, failing if they exceed the limits
+func (b *body) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.ReadCloser.Read(p)
+	if scanErr := b.scan(p[:n]); scanErr != nil {
+		b.err = scanErr
+		return 0, scanErr
+	}
+	return n, err
+}
+
+// scan updates the body's depth and item count with 'data', returning an error if they exceed its limits
+func (b *body) scan(data []byte) error {
+	for _, c := range data {
+		if b.inString {
+			switch {
+			case b.escaped:
+				b.escaped = false
+			case c == '\\':
+				b.escaped = true
+			case c == '"':
+				b.inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '}', ']':
+			b.first = false
+			if b.depth > 0 {
+				b.depth--
+			}
+			continue
+		case ',':
+			if b.depth > 0 {
+				b.items++
+			}
+		}
+		if b.first {
+			b.items++
+			b.first = false
+		}
+		if b.limits.MaxItems > 0 && b.items > b.limits.MaxItems {
+			return fmt.Errorf("%w, it has more than %d fields and array elements", ErrTooComplex, b.limits.MaxItems)
+		}
+
+		switch c {
+		case '"':
+			b.inString = true
+		case '{', '[':
+			b.depth++
+			b.first = true
+			if b.limits.MaxDepth > 0 && b.depth > b.limits.MaxDepth {
+				return fmt.Errorf("%w, it's nested more than %d levels deep", ErrTooComplex, b.limits.MaxDepth)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package jsonlimit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// decodeHandler decodes a JSON request body, responding with a 400 HTTP status if it's too complex
+// and a 422 HTTP status if it's otherwise invalid
+var decodeHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	var v interface{}
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		if IsTooComplex(err) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestMiddleware(t *testing.T) {
+	limits := Limits{MaxDepth: 3, MaxItems: 5}
+
+	tcs := []struct {
+		testName       string
+		limits         Limits
+		body           string
+		expectedStatus int
+	}{
+		{testName: "testWithinLimits", limits: limits, body: `{"a":[1,2],"b":{"c":"d"}}`, expectedStatus: http.StatusOK},
+		{testName: "testAtMaxDepth", limits: limits, body: `[[[1]]]`, expectedStatus: http.StatusOK},
+		{testName: "testTooDeep", limits: limits, body: `[[[[1]]]]`, expectedStatus: http.StatusBadRequest},
+		{testName: "testAtMaxItems", limits: limits, body: `[1,2,3,4,5]`, expectedStatus: http.StatusOK},
+		{testName: "testTooManyItems", limits: limits, body: `[1,2,3,4,5,6]`, expectedStatus: http.StatusBadRequest},
+		{testName: "testTooManyNestedItems", limits: limits, body: `{"a":[1,2],"b":[3,4]}`, expectedStatus: http.StatusBadRequest},
+		{testName: "testEmptyContainers", limits: limits, body: `{"a":[],"b":{},"c":[]}`, expectedStatus: http.StatusOK},
+		{testName: "testDelimitersInStrings", limits: limits, body: `{"a":"[[[[,,,,,,]]]]","b":"\"[[[["}`, expectedStatus: http.StatusOK},
+		{testName: "testScalar", limits: limits, body: `"[[[[1]]]]"`, expectedStatus: http.StatusOK},
+		{testName: "testInvalid", limits: limits, body: `{"a":`, expectedStatus: http.StatusUnprocessableEntity},
+		{testName: "testNoLimits", body: `[[[[1,2,3,4,5,6]]]]`, expectedStatus: http.StatusOK},
+		{testName: "testMaxDepthOnly", limits: Limits{MaxDepth: 1}, body: `[1,2,3,4,5,6]`, expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			h := Middleware(tc.limits)(decodeHandler)
+			// Reading a byte at a time checks that the body's state is kept between reads
+			r := httptest.NewRequest(http.MethodPost, "/", ioutil.NopCloser(iotest.OneByteReader(strings.NewReader(tc.body))))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			w = httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status %d reading the body at once, got %d", tc.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestReadAfterError(t *testing.T) {
+	b := &body{ReadCloser: ioutil.NopCloser(strings.NewReader(`[[1],[2]]`)), limits: Limits{MaxDepth: 1}}
+	p := make([]byte, 16)
+	if n, err := b.Read(p); n != 0 || !IsTooComplex(err) {
+		t.Fatalf("expected the read to fail as too complex, got %d bytes, error %v", n, err)
+	}
+	if n, err := b.Read(p); n != 0 || !IsTooComplex(err) {
+		t.Errorf("expected later reads to fail as too complex, got %d bytes, error %v", n, err)
+	}
+}
@@ -0,0 +1,25 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package lifecycle coordinates a process's graceful shutdown. Everything that must be stopped before
+the process exits, e.g., servers, background workers, and the database connection pool, is registered
+with a Manager as it's started. Manager.Wait blocks until the process receives SIGTERM or SIGINT and
+then stops the registered components.
+
+Components are stopped in the reverse of the order they were registered, so a component is always
+stopped before the components it depends on. For example, registering the database first, then the
+background workers, and finally the server results in the server no longer accepting requests before
+the workers are stopped, and the database being closed last.
+
+All components share a single shutdown timeout. A component that hasn't stopped by the time the
+timeout expires is abandoned and the remaining components are asked to stop with an expired context,
+so the process can exit even if a component hangs.
+
+A Drain gives load balancers time to stop routing new requests to the process before its servers are
+stopped. It's registered after the servers, so its Stop is called first. Stop marks the process as
+draining, e.g., so '/readyz' reports that it's no longer ready, calls the functions added with
+OnDrain, and then waits for the drain delay. The drain delay counts towards the shutdown timeout.
+*/
+package lifecycle
@@ -0,0 +1,83 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultDrainDelay is the time allowed for load balancers to stop routing new requests to a
+// draining process unless configured otherwise
+const DefaultDrainDelay = 5 * time.Second
+
+// Drain reports whether the process is draining, i.e., shutting down. A draining process still
+// serves the requests it receives but reports that it's no longer ready, e.g., via '/readyz', so
+// that load balancers stop routing new requests to it.
+type Drain struct {
+	delay time.Duration
+
+	mu       sync.Mutex
+	draining bool
+	onDrain  []func()
+}
+
+// NewDrain returns a Drain whose Stop waits 'delay' once draining has started. 'delay' must not
+// be negative.
+func NewDrain(delay time.Duration) (*Drain, error) {
+	if delay < 0 {
+		return nil, errors.New("delay must not be negative")
+	}
+	return &Drain{delay: delay}, nil
+}
+
+// Draining returns true once Stop has been called
+func (d *Drain) Draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.draining
+}
+
+// OnDrain adds 'f' to the functions called when draining starts, e.g., to report that a gRPC
+// server is no longer serving. 'f' is called immediately if draining has already started.
+func (d *Drain) OnDrain(f func()) {
+	d.mu.Lock()
+	if !d.draining {
+		d.onDrain = append(d.onDrain, f)
+		d.mu.Unlock()
+		return
+	}
+	d.mu.Unlock()
+	f()
+}
+
+// Stop starts draining and then waits for the drain delay, or until 'ctx' is done, so load
+// balancers have time to notice. It's a StopFunc and must be registered after the servers so it's
+// called before they're stopped.
+func (d *Drain) Stop(ctx context.Context) error {
+	d.mu.Lock()
+	onDrain := d.onDrain
+	d.onDrain = nil
+	d.draining = true
+	d.mu.Unlock()
+
+	for _, f := range onDrain {
+		f()
+	}
+
+	if d.delay == 0 {
+		return nil
+	}
+	t := time.NewTimer(d.delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
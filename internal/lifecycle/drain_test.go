@@ -0,0 +1,84 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package lifecycle
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+func TestNewDrain(t *testing.T) {
+	if _, err := NewDrain(-time.Second); err == nil {
+		t.Error("expected an error creating a Drain with a negative delay")
+	}
+	if _, err := NewDrain(0); err != nil {
+		t.Errorf("error %s was not expected creating a Drain without a delay", err)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	d, err := NewDrain(100 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a Drain", err)
+	}
+	m, err := NewManager(time.Second, logging.Default())
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a Manager", err)
+	}
+	r := &recorder{}
+	d.OnDrain(func() { r.stopFunc("gRPC health", nil)(context.Background()) })
+
+	// The server must still see the Drain as draining, and the drain delay must have passed,
+	// when it's stopped
+	drainedAt := time.Time{}
+	m.Register("server", func(ctx context.Context) error {
+		if !d.Draining() {
+			t.Error("expected draining to have started before the server is stopped")
+		}
+		drainedAt = time.Now()
+		return r.stopFunc("server", nil)(ctx)
+	})
+	m.Register("drain", d.Stop)
+
+	if d.Draining() {
+		t.Fatal("expected draining not to have started before shutdown")
+	}
+	start := time.Now()
+	if err = m.Shutdown(); err != nil {
+		t.Fatalf("error %s was not expected shutting down", err)
+	}
+	if drainedAt.Sub(start) < 100*time.Millisecond {
+		t.Errorf("expected the server to be stopped after the drain delay, got %s", drainedAt.Sub(start))
+	}
+
+	// Functions added once draining has started are called immediately
+	d.OnDrain(func() { r.stopFunc("late", nil)(context.Background()) })
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	expected := []string{"gRPC health", "server", "late"}
+	if !reflect.DeepEqual(r.stopped, expected) {
+		t.Errorf("expected %v in order, got %v", expected, r.stopped)
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	d, err := NewDrain(time.Minute)
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a Drain", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err = d.Stop(ctx); err == nil {
+		t.Error("expected an error when the context is done before the drain delay")
+	}
+	if !d.Draining() {
+		t.Error("expected draining to have started")
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// DefaultShutdownTimeout is the time allowed for all components to stop unless configured otherwise
+const DefaultShutdownTimeout = 10 * time.Second
+
+// StopFunc stops a component. It should return once the component has stopped or 'ctx' is done.
+type StopFunc func(ctx context.Context) error
+
+// Func adapts a stop function that doesn't take a context or return an error, e.g.,
+// services.WriteBehindWorker.Stop, to a StopFunc
+func Func(stop func()) StopFunc {
+	return func(ctx context.Context) error {
+		stop()
+		return nil
+	}
+}
+
+type component struct {
+	name string
+	stop StopFunc
+}
+
+// Manager stops registered components when the process is asked to shut down
+type Manager struct {
+	timeout time.Duration
+	logger  logging.Logger
+
+	mu         sync.Mutex
+	components []component
+	once       sync.Once
+	err        error
+}
+
+// NewManager returns a Manager that allows 'timeout' for all registered components to stop.
+// 'timeout' must be greater than 0 and 'logger' must be non-nil.
+func NewManager(timeout time.Duration, logger logging.Logger) (*Manager, error) {
+	if timeout <= 0 {
+		return nil, errors.New("timeout must be greater than 0")
+	}
+	if logger == nil {
+		return nil, errors.New("non-nil Logger required")
+	}
+	return &Manager{timeout: timeout, logger: logger}, nil
+}
+
+// Register adds the component 'name' which is stopped by 'stop'. Components are stopped in the
+// reverse of the order they're registered, so dependencies must be registered first.
+func (m *Manager) Register(name string, stop StopFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.components = append(m.components, component{name: name, stop: stop})
+}
+
+// Wait blocks until the process receives SIGTERM or SIGINT, or 'ctx' is done, and then calls
+// Shutdown, returning its error
+func (m *Manager) Wait(ctx context.Context) error {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	select {
+	case sig := <-sigs:
+		m.logger.Infof("%s received, shutting down with timeout %s", sig, m.timeout)
+	case <-ctx.Done():
+		m.logger.Infof("shutting down with timeout %s", m.timeout)
+	}
+
+	return m.Shutdown()
+}
+
+// Shutdown stops the registered components in the reverse of the order they were registered.
+// Every component is asked to stop even if some fail, the first error is returned. Only the
+// first call stops the components, later calls return the first call's result.
+func (m *Manager) Shutdown() error {
+	m.once.Do(func() {
+		m.mu.Lock()
+		components := make([]component, len(m.components))
+		copy(components, m.components)
+		m.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+		defer cancel()
+
+		for i := len(components) - 1; i >= 0; i-- {
+			err := stop(ctx, components[i])
+			if err != nil {
+				m.logger.WithFields(logging.Fields{
+					logging.ServiceName: components[i].name,
+					logging.ErrorDetail: err.Error(),
+				}).Warn("component shut down with error")
+				if m.err == nil {
+					m.err = fmt.Errorf("stopping %s: %w", components[i].name, err)
+				}
+				continue
+			}
+			m.logger.WithFields(logging.Fields{
+				logging.ServiceName: components[i].name,
+			}).Info("component stopped")
+		}
+	})
+	return m.err
+}
+
+// stop calls c.stop and waits for it to return or for 'ctx' to be done, whichever comes first.
+// c.stop keeps running in the background if 'ctx' is done first.
+func stop(ctx context.Context, c component) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.stop(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
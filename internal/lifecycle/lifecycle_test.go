@@ -0,0 +1,182 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+func init() {
+	// Suppress all application logging
+	logging.GetLogger().Logger.SetLevel(log.PanicLevel)
+}
+
+func TestNewManager(t *testing.T) {
+	tcs := []struct {
+		testName   string
+		timeout    time.Duration
+		logger     logging.Logger
+		shouldPass bool
+	}{
+		{testName: "testValid", timeout: time.Second, logger: logging.Default(), shouldPass: true},
+		{testName: "testZeroTimeout", timeout: 0, logger: logging.Default(), shouldPass: false},
+		{testName: "testNilLogger", timeout: time.Second, logger: nil, shouldPass: false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			_, err := NewManager(tc.timeout, tc.logger)
+			if tc.shouldPass && err != nil {
+				t.Errorf("error %s was not expected", err)
+			}
+			if !tc.shouldPass && err == nil {
+				t.Error("expected an error, got none")
+			}
+		})
+	}
+}
+
+// recorder records the order components are stopped in
+type recorder struct {
+	mu      sync.Mutex
+	stopped []string
+}
+
+func (r *recorder) stopFunc(name string, err error) StopFunc {
+	return func(ctx context.Context) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.stopped = append(r.stopped, name)
+		return err
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	failed := errors.New("close failed")
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(time.Second)
+		return nil
+	}
+
+	tcs := []struct {
+		testName        string
+		register        func(m *Manager, r *recorder)
+		expectedStopped []string
+		shouldPass      bool
+	}{
+		{
+			testName: "testReverseOrder",
+			register: func(m *Manager, r *recorder) {
+				m.Register("db", r.stopFunc("db", nil))
+				m.Register("worker", Func(func() { r.stopFunc("worker", nil)(context.Background()) }))
+				m.Register("server", r.stopFunc("server", nil))
+			},
+			expectedStopped: []string{"server", "worker", "db"},
+			shouldPass:      true,
+		},
+		{
+			testName: "testErrorStopsRemaining",
+			register: func(m *Manager, r *recorder) {
+				m.Register("db", r.stopFunc("db", nil))
+				m.Register("server", r.stopFunc("server", failed))
+			},
+			expectedStopped: []string{"server", "db"},
+			shouldPass:      false,
+		},
+		{
+			testName: "testTimeout",
+			register: func(m *Manager, r *recorder) {
+				m.Register("db", r.stopFunc("db", nil))
+				m.Register("hung", hang)
+			},
+			// db isn't stopped because the shutdown timeout expired while waiting for 'hung'
+			expectedStopped: nil,
+			shouldPass:      false,
+		},
+		{
+			testName:   "testNoComponents",
+			register:   func(m *Manager, r *recorder) {},
+			shouldPass: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			m, err := NewManager(50*time.Millisecond, logging.Default())
+			if err != nil {
+				t.Fatalf("error %s was not expected creating a Manager", err)
+			}
+			r := &recorder{}
+			tc.register(m, r)
+
+			err = m.Shutdown()
+			if tc.shouldPass && err != nil {
+				t.Errorf("error %s was not expected", err)
+			}
+			if !tc.shouldPass && err == nil {
+				t.Error("expected an error, got none")
+			}
+
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if !reflect.DeepEqual(r.stopped, tc.expectedStopped) {
+				t.Errorf("expected components stopped in order %v, got %v", tc.expectedStopped, r.stopped)
+			}
+		})
+	}
+}
+
+func TestShutdownOnce(t *testing.T) {
+	m, err := NewManager(time.Second, logging.Default())
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a Manager", err)
+	}
+	r := &recorder{}
+	m.Register("server", r.stopFunc("server", nil))
+
+	m.Shutdown()
+	m.Shutdown()
+
+	if len(r.stopped) != 1 {
+		t.Errorf("expected the component to be stopped once, got %d times", len(r.stopped))
+	}
+}
+
+func TestWait(t *testing.T) {
+	m, err := NewManager(time.Second, logging.Default())
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a Manager", err)
+	}
+	r := &recorder{}
+	m.Register("server", r.stopFunc("server", nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Wait(ctx)
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("error %s was not expected", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait didn't return after its context was canceled")
+	}
+	if !reflect.DeepEqual(r.stopped, []string{"server"}) {
+		t.Errorf("expected the server to be stopped, got %v", r.stopped)
+	}
+}
@@ -0,0 +1,18 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package listener creates the network listeners a service accepts connections on, in addition to its
+TCP port. Listeners are described by URLs, e.g.:
+
+		unix:///var/run/accountd.sock
+		tcp://:6000
+
+A Unix domain socket is useful for a sidecar proxy in the same pod. Its file permissions are set
+when it's created so only the intended processes can connect, e.g., 0660 restricts it to the
+service's user and group. A stale socket file left by a previous process that didn't shut down
+cleanly is replaced, but any other kind of file at the socket's path is an error. The socket file is
+removed when the listener is closed.
+*/
+package listener
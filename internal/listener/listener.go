@@ -0,0 +1,85 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package listener
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// DefaultSocketMode is the file mode of Unix domain sockets unless configured otherwise
+const DefaultSocketMode os.FileMode = 0660
+
+// Listen returns a listener for 'rawURL', e.g., 'unix:///var/run/accountd.sock' or 'tcp://:6000'.
+// The file mode of a Unix domain socket is set to 'socketMode'.
+func Listen(rawURL string, socketMode os.FileMode) (net.Listener, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen URL %q: %s", rawURL, err)
+	}
+
+	switch u.Scheme {
+	case "unix":
+		// 'unix:///var/run/accountd.sock' has an empty host, 'unix://accountd.sock' is relative
+		return listenUnix(u.Host+u.Path, socketMode)
+	case "tcp":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid listen URL %q: missing host:port", rawURL)
+		}
+		return net.Listen("tcp", u.Host)
+	default:
+		return nil, fmt.Errorf("invalid listen URL %q: scheme must be 'unix' or 'tcp'", rawURL)
+	}
+}
+
+// ListenAll returns a listener for each of the comma separated URLs in 'rawURLs', see Listen.
+// If any of them can't be created the others are closed.
+func ListenAll(rawURLs string, socketMode os.FileMode) ([]net.Listener, error) {
+	var lns []net.Listener
+	for _, rawURL := range strings.Split(rawURLs, ",") {
+		rawURL = strings.TrimSpace(rawURL)
+		if rawURL == "" {
+			continue
+		}
+		ln, err := Listen(rawURL, socketMode)
+		if err != nil {
+			for _, l := range lns {
+				l.Close()
+			}
+			return nil, err
+		}
+		lns = append(lns, ln)
+	}
+	return lns, nil
+}
+
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if path == "" {
+		return nil, fmt.Errorf("invalid Unix domain socket, missing path")
+	}
+
+	// Remove a socket left by a process that didn't shut down cleanly, but never anything else
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and isn't a Unix domain socket", path)
+		}
+		if err = os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package listener
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listener")
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a temp dir", err)
+	}
+	defer os.RemoveAll(dir)
+
+	regularFile := filepath.Join(dir, "regular")
+	if err = ioutil.WriteFile(regularFile, []byte("not a socket"), 0644); err != nil {
+		t.Fatalf("error %s was not expected creating a file", err)
+	}
+	staleSocket := filepath.Join(dir, "stale.sock")
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: staleSocket, Net: "unix"})
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a socket", err)
+	}
+	// Leave the socket file behind as if the process had crashed
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	tcs := []struct {
+		testName   string
+		url        string
+		mode       os.FileMode
+		network    string
+		shouldPass bool
+	}{
+		{testName: "testUnix", url: "unix://" + filepath.Join(dir, "accountd.sock"), mode: 0660, network: "unix", shouldPass: true},
+		{testName: "testUnixMode", url: "unix://" + filepath.Join(dir, "other.sock"), mode: 0600, network: "unix", shouldPass: true},
+		{testName: "testUnixStaleSocket", url: "unix://" + staleSocket, mode: 0660, network: "unix", shouldPass: true},
+		{testName: "testUnixRegularFile", url: "unix://" + regularFile, mode: 0660, shouldPass: false},
+		{testName: "testUnixNoPath", url: "unix://", mode: 0660, shouldPass: false},
+		{testName: "testTCP", url: "tcp://127.0.0.1:0", network: "tcp", shouldPass: true},
+		{testName: "testTCPNoHost", url: "tcp://", shouldPass: false},
+		{testName: "testUnsupportedScheme", url: "udp://127.0.0.1:0", shouldPass: false},
+		{testName: "testMalformedURL", url: "unix://%zz", shouldPass: false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			ln, err := Listen(tc.url, tc.mode)
+			if !tc.shouldPass {
+				if err == nil {
+					ln.Close()
+					t.Errorf("expected an error listening on %s", tc.url)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error %s was not expected listening on %s", err, tc.url)
+			}
+
+			s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("hello"))
+			}))
+			s.Listener.Close()
+			s.Listener = ln
+			s.Start()
+			defer s.Close()
+
+			if tc.network == "unix" {
+				fi, err := os.Stat(ln.Addr().String())
+				if err != nil {
+					t.Fatalf("error %s was not expected getting the socket's file info", err)
+				}
+				if fi.Mode().Perm() != tc.mode {
+					t.Errorf("expected socket mode %s, got %s", tc.mode, fi.Mode().Perm())
+				}
+			}
+
+			client := &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, tc.network, ln.Addr().String())
+				},
+			}}
+			resp, err := client.Get("http://accountd/")
+			if err != nil {
+				t.Fatalf("error %s was not expected making a request", err)
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+			if string(body) != "hello" {
+				t.Errorf("expected body %q, got %q", "hello", body)
+			}
+		})
+	}
+}
+
+func TestListenAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listener")
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a temp dir", err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "accountd.sock")
+
+	tcs := []struct {
+		testName      string
+		urls          string
+		expectedCount int
+		shouldPass    bool
+	}{
+		{testName: "testEmpty", urls: "", expectedCount: 0, shouldPass: true},
+		{testName: "testMultiple", urls: "unix://" + socket + ", tcp://127.0.0.1:0", expectedCount: 2, shouldPass: true},
+		{testName: "testInvalid", urls: "unix://" + socket + ",bogus://", shouldPass: false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			lns, err := ListenAll(tc.urls, DefaultSocketMode)
+			defer func() {
+				for _, ln := range lns {
+					ln.Close()
+				}
+			}()
+			if !tc.shouldPass {
+				if err == nil {
+					t.Fatalf("expected an error listening on %s", tc.urls)
+				}
+				// The listeners created before the error must have been closed, removing the socket
+				if _, err := os.Stat(socket); !os.IsNotExist(err) {
+					t.Errorf("expected %s to be removed, got %v", socket, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error %s was not expected listening on %s", err, tc.urls)
+			}
+			if len(lns) != tc.expectedCount {
+				t.Errorf("expected %d listeners, got %d", tc.expectedCount, len(lns))
+			}
+		})
+	}
+}
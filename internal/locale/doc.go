@@ -0,0 +1,19 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package locale carries the caller's language preferences, from the HTTP 'Accept-Language' header,
+through a request's context. Middleware parses the header once, when the request is received, so
+services and the components they use (e.g., a Mailer rendering a notification) get the preferences
+from the context rather than re-parsing headers deep in the stack.
+
+Preferences are BCP 47 language tags, e.g., 'en-US' or 'fr', ordered from most to least preferred.
+Match chooses the best of the locales a component supports:
+
+		tag := locale.Match(ctx, "en", "fr", "de")
+
+Requests without an 'Accept-Language' header, or whose header has no valid tags, don't carry any
+preferences and Match returns the first supported locale.
+*/
+package locale
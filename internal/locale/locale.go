@@ -0,0 +1,191 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package locale
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Header is the HTTP header containing the caller's language preferences
+const Header = "Accept-Language"
+
+// Default is the locale used when the caller has no preferences and no supported locales are given
+const Default = "en"
+
+type localeKey struct{}
+
+// NewContext returns a copy of 'ctx' carrying the language preferences 'tags', ordered from most
+// to least preferred
+func NewContext(ctx context.Context, tags []string) context.Context {
+	return context.WithValue(ctx, localeKey{}, tags)
+}
+
+// FromContext returns the language preferences carried by 'ctx', ordered from most to least
+// preferred. The returned bool is false if 'ctx' doesn't carry any preferences.
+func FromContext(ctx context.Context) ([]string, bool) {
+	tags, ok := ctx.Value(localeKey{}).([]string)
+	return tags, ok && len(tags) > 0
+}
+
+// Preferred returns the caller's most preferred language in 'ctx', or Default if there is none
+func Preferred(ctx context.Context) string {
+	if tags, ok := FromContext(ctx); ok {
+		return tags[0]
+	}
+	return Default
+}
+
+// Match returns the element of 'supported' that best matches the language preferences in 'ctx'.
+// A preference matches a supported locale with the same tag, e.g., 'fr-CA' matches 'fr-CA', or the
+// same base language, e.g., 'fr-CA' matches 'fr'. The first supported locale is returned if none
+// match, or Default if 'supported' is empty.
+func Match(ctx context.Context, supported ...string) string {
+	if len(supported) == 0 {
+		return Default
+	}
+
+	tags, _ := FromContext(ctx)
+	for _, tag := range tags {
+		for _, s := range supported {
+			if strings.EqualFold(tag, s) {
+				return s
+			}
+		}
+		for _, s := range supported {
+			if strings.EqualFold(base(tag), base(s)) {
+				return s
+			}
+		}
+	}
+	return supported[0]
+}
+
+// Middleware adds the language preferences in the request's Accept-Language header, if any, to the
+// request's context
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tags := Parse(r.Header.Get(Header)); len(tags) > 0 {
+			r = r.WithContext(NewContext(r.Context(), tags))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Parse returns the language tags in the Accept-Language header value 'header' ordered by their
+// quality values, most preferred first. Invalid tags, the wildcard '*', and tags with a quality of
+// 0 are omitted. Tags are normalized, e.g., 'EN-us' becomes 'en-US'.
+func Parse(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var prefs []weighted
+	for _, item := range strings.Split(header, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ";")
+		tag, ok := normalize(strings.TrimSpace(parts[0]))
+		if !ok {
+			continue
+		}
+		q := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+			if err != nil || v < 0 || v > 1 {
+				q = 0
+			} else {
+				q = v
+			}
+		}
+		if q > 0 {
+			prefs = append(prefs, weighted{tag: tag, q: q})
+		}
+	}
+
+	// Stable so tags with equal quality stay in the order they were listed
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	tags := make([]string, 0, len(prefs))
+	for _, p := range prefs {
+		tags = append(tags, p.tag)
+	}
+	return tags
+}
+
+// Format returns an Accept-Language header value for 'tags', ordered from most to least preferred,
+// e.g., 'fr-CA, fr;q=0.9, en;q=0.8'
+func Format(tags []string) string {
+	items := make([]string, 0, len(tags))
+	for i, tag := range tags {
+		if i == 0 {
+			items = append(items, tag)
+			continue
+		}
+		q := 1 - float64(i)/10
+		if q < 0.1 {
+			q = 0.1
+		}
+		items = append(items, fmt.Sprintf("%s;q=%.1f", tag, q))
+	}
+	return strings.Join(items, ", ")
+}
+
+// normalize returns the canonical form of the BCP 47 language tag 'tag', e.g., 'zh-Hant-TW'. The
+// returned bool is false if 'tag' isn't a valid language tag.
+func normalize(tag string) (string, bool) {
+	subtags := strings.Split(tag, "-")
+	for i, st := range subtags {
+		if len(st) == 0 || len(st) > 8 || !isAlphaNum(st) {
+			return "", false
+		}
+		switch {
+		case i == 0:
+			if len(st) < 2 || len(st) > 3 || !isAlpha(st) {
+				return "", false
+			}
+			subtags[i] = strings.ToLower(st)
+		case len(st) == 2 && isAlpha(st):
+			// Region, e.g., 'US'
+			subtags[i] = strings.ToUpper(st)
+		case len(st) == 4 && isAlpha(st):
+			// Script, e.g., 'Hant'
+			subtags[i] = strings.ToUpper(st[:1]) + strings.ToLower(st[1:])
+		default:
+			subtags[i] = strings.ToLower(st)
+		}
+	}
+	return strings.Join(subtags, "-"), true
+}
+
+// base returns the language subtag of 'tag', e.g., 'fr' for 'fr-CA'
+func base(tag string) string {
+	return strings.SplitN(tag, "-", 2)[0]
+}
+
+func isAlpha(s string) bool {
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlphaNum(s string) bool {
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package locale
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tcs := []struct {
+		testName string
+		header   string
+		expected []string
+	}{
+		{testName: "testEmpty", header: "", expected: []string{}},
+		{testName: "testSingle", header: "fr-CA", expected: []string{"fr-CA"}},
+		{testName: "testQualityOrder", header: "en;q=0.5, fr-CA, fr;q=0.9", expected: []string{"fr-CA", "fr", "en"}},
+		{testName: "testEqualQualityKeepsOrder", header: "de;q=0.8, es;q=0.8", expected: []string{"de", "es"}},
+		{testName: "testNormalized", header: "EN-us, zh-hant-tw", expected: []string{"en-US", "zh-Hant-TW"}},
+		{testName: "testWildcardOmitted", header: "fr, *;q=0.5", expected: []string{"fr"}},
+		{testName: "testZeroQualityOmitted", header: "fr, en;q=0", expected: []string{"fr"}},
+		{testName: "testInvalidQualityOmitted", header: "fr, en;q=2", expected: []string{"fr"}},
+		{testName: "testInvalidTagsOmitted", header: "f, english-language, 12, fr", expected: []string{"fr"}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			if tags := Parse(tc.header); !reflect.DeepEqual(tags, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, tags)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tcs := []struct {
+		tags     []string
+		expected string
+	}{
+		{tags: nil, expected: ""},
+		{tags: []string{"fr-CA"}, expected: "fr-CA"},
+		{tags: []string{"fr-CA", "fr", "en"}, expected: "fr-CA, fr;q=0.9, en;q=0.8"},
+	}
+
+	for _, tc := range tcs {
+		if header := Format(tc.tags); header != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, header)
+		}
+		if tags := Parse(Format(tc.tags)); len(tc.tags) > 0 && !reflect.DeepEqual(tags, tc.tags) {
+			t.Errorf("expected %v to survive a round trip, got %v", tc.tags, tags)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tcs := []struct {
+		testName  string
+		tags      []string
+		supported []string
+		expected  string
+	}{
+		{testName: "testExact", tags: []string{"fr-CA"}, supported: []string{"en", "fr", "fr-CA"}, expected: "fr-CA"},
+		{testName: "testBaseLanguage", tags: []string{"fr-CA"}, supported: []string{"en", "fr"}, expected: "fr"},
+		{testName: "testSecondPreference", tags: []string{"ja", "de"}, supported: []string{"en", "de"}, expected: "de"},
+		{testName: "testNoMatch", tags: []string{"ja"}, supported: []string{"en", "de"}, expected: "en"},
+		{testName: "testNoPreferences", supported: []string{"es", "en"}, expected: "es"},
+		{testName: "testNoSupported", tags: []string{"ja"}, expected: Default},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.tags != nil {
+				ctx = NewContext(ctx, tc.tags)
+			}
+			if tag := Match(ctx, tc.supported...); tag != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, tag)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	tcs := []struct {
+		testName          string
+		header            string
+		expectedTags      []string
+		expectedPreferred string
+	}{
+		{testName: "testHeader", header: "fr-CA, en;q=0.5", expectedTags: []string{"fr-CA", "en"}, expectedPreferred: "fr-CA"},
+		{testName: "testNoHeader", expectedPreferred: Default},
+		{testName: "testInvalidHeader", header: "*", expectedPreferred: Default},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			var (
+				tags      []string
+				preferred string
+			)
+			h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tags, _ = FromContext(r.Context())
+				preferred = Preferred(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if tc.header != "" {
+				req.Header.Set(Header, tc.header)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !reflect.DeepEqual(tags, tc.expectedTags) {
+				t.Errorf("expected tags %v, got %v", tc.expectedTags, tags)
+			}
+			if preferred != tc.expectedPreferred {
+				t.Errorf("expected preferred locale %s, got %s", tc.expectedPreferred, preferred)
+			}
+		})
+	}
+}
@@ -13,30 +13,60 @@ package logging
 // fields used in log messages.
 //
 const (
+	AccountID      string = "AccountID"
+	Address        string = "Address"
+	Admin          string = "Admin"
+	APIKey         string = "APIKey"
 	Application    string = "Application"
+	Attempts       string = "Attempts"
+	Audit          string = "Audit"
+	Client         string = "Client"
 	ConfigFileName string = "ConfigFileName"
 
 	DBHost string = "DBHost"
 	DBName string = "DBName"
 	DBPort string = "DBPort"
 
+	DeadLetterID string = "DeadLetterID"
+	Decision     string = "Decision"
+	Duration     string = "Duration"
+
+	Environment string = "Environment"
 	ErrorCode   string = "ErrorCode"
 	ErrorDetail string = "ErrorDetail"
 	ErrorMsg    string = "ErrorMessage"
+	Expires     string = "Expires"
+
+	GRPCCode string = "GRPCCode"
 
-	HostName   string = "HostName"
-	HTTPStatus string = "HTTPStatus"
+	HostName     string = "HostName"
+	HTTPStatus   string = "HTTPStatus"
+	Impersonator string = "Impersonator"
 
+	Locale   string = "Locale"
+	Location string = "Location"
 	LogLevel string = "LogLevel"
 	Method   string = "HTTPMethod"
 
-	Path string = "URLPath"
-	Port string = "Port"
+	ParentSpanID string = "ParentSpanID"
+	Path         string = "URLPath"
+	PolicyRule   string = "PolicyRule"
+	Port         string = "Port"
+
+	QueueID string = "QueueID"
 
+	Reason         string = "Reason"
 	RemoteAddr     string = "RemoteAddr"
+	Resource       string = "Resource"
+	Role           string = "Role"
 	RPCFunc        string = "RPCFunc"
+	RqstID         string = "RequestID"
+	Scope          string = "Scope"
 	ServiceName    string = "ServiceName"
 	SecretsDirName string = "SecretsDirName"
+	SlowRPC        string = "slow_rpc"
+	SpanID         string = "SpanID"
+	SpanName       string = "SpanName"
 
 	UserID    string = "UserID"
 	UserEMail string = "UserEMail"
@@ -44,6 +74,8 @@ const (
 	Status string = "Status"
 
 	TestName string = "TestName"
+	TLS      string = "TLS"
+	TraceID  string = "TraceID"
 
 	WrappedError string = "WrappedError"
 )
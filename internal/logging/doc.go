@@ -6,5 +6,13 @@
 Package logging provides capability needed to create a service level logger properly configured and ready to use.
 
 It sets the log format, output location (stdout usually), default logging level, and common log fields (e.g., hostname).
+
+Handlers, services, and repositories log using the Logger interface. Logrus is the default
+implementation, zap and zerolog implementations are available by building with the 'zap' or
+'zerolog' build tag, e.g., 'go build -tags zap'. Their tests also require the tag, e.g.,
+'go test -tags zap ./internal/logging'. New returns a Logger for the implementation named by the
+service's 'logger' configuration.
+
+Log, AcquireFields, and ReleaseFields reduce the allocations made when logging on high request rate paths.
 */
 package logging
@@ -0,0 +1,62 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"sync"
+)
+
+// fieldsSize is the initial capacity of pooled Fields. It's large enough for the fields
+// logged by the request handling paths.
+const fieldsSize = 8
+
+var fieldsPool = sync.Pool{
+	New: func() interface{} {
+		return make(Fields, fieldsSize)
+	},
+}
+
+// AcquireFields returns an empty Fields from a pool. It must be returned to the pool, using
+// ReleaseFields, after it's been passed to 'Logger.WithFields()'.
+func AcquireFields() Fields {
+	return fieldsPool.Get().(Fields)
+}
+
+// ReleaseFields clears 'fields' and returns it to the pool. 'fields' must not be used afterwards.
+func ReleaseFields(fields Fields) {
+	for k := range fields {
+		delete(fields, k)
+	}
+	fieldsPool.Put(fields)
+}
+
+// Log logs 'msg' at 'level' using 'logger'. 'setFields' adds the fields to be included in the log
+// message to a pooled Fields. If 'level' isn't enabled nothing is allocated and 'setFields'
+// isn't called. This makes Log suitable for high request rate paths, e.g.,
+//
+//	logging.Log(logger, logging.InfoLevel, "HTTP request received", func(f logging.Fields) {
+//		f[logging.Method] = r.Method
+//		f[logging.Path] = r.URL.Path
+//	})
+func Log(logger Logger, level Level, msg string, setFields func(Fields)) {
+	if !logger.IsLevelEnabled(level) {
+		return
+	}
+
+	fields := AcquireFields()
+	setFields(fields)
+	l := logger.WithFields(fields)
+	switch {
+	case level <= ErrorLevel:
+		l.Error(msg)
+	case level == WarnLevel:
+		l.Warn(msg)
+	case level == InfoLevel:
+		l.Info(msg)
+	default:
+		l.Debug(msg)
+	}
+	ReleaseFields(fields)
+}
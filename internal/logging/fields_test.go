@@ -0,0 +1,118 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func newTestLogger(w io.Writer, level Level) Logger {
+	l := log.New()
+	l.SetOutput(w)
+	l.SetFormatter(&log.JSONFormatter{})
+	l.SetLevel(log.Level(level))
+	return NewLogrusLogger(log.NewEntry(l))
+}
+
+func TestLog(t *testing.T) {
+	tcs := []struct {
+		testName       string
+		level          Level
+		expectedFields map[string]interface{}
+	}{
+		{
+			testName: "testLogEnabled",
+			level:    InfoLevel,
+			expectedFields: map[string]interface{}{
+				Method: "GET",
+				Path:   "/users/1",
+				"msg":  "HTTP request received",
+			},
+		},
+		{
+			testName: "testLogDisabled",
+			level:    ErrorLevel,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			buf := bytes.Buffer{}
+			logger := newTestLogger(&buf, tc.level)
+
+			called := false
+			Log(logger, InfoLevel, "HTTP request received", func(f Fields) {
+				called = true
+				f[Method] = "GET"
+				f[Path] = "/users/1"
+			})
+
+			if tc.expectedFields == nil {
+				if called || buf.Len() != 0 {
+					t.Errorf("expected nothing to be logged, got %s", buf.String())
+				}
+				return
+			}
+
+			actual := map[string]interface{}{}
+			if err := json.Unmarshal(buf.Bytes(), &actual); err != nil {
+				t.Fatalf("error %s was not expected unmarshaling %s", err, buf.String())
+			}
+			for k, v := range tc.expectedFields {
+				if actual[k] != v {
+					t.Errorf("expected field %s = %v, got %v", k, v, actual[k])
+				}
+			}
+		})
+	}
+}
+
+func TestReleaseFields(t *testing.T) {
+	fields := AcquireFields()
+	fields[UserID] = 1
+	ReleaseFields(fields)
+
+	if len(fields) != 0 {
+		t.Errorf("expected released fields to be empty, got %v", fields)
+	}
+}
+
+// BenchmarkRequestLogging compares logging the fields of a request using 'Fields' literals
+// with using Log()
+func BenchmarkRequestLogging(b *testing.B) {
+	method, path, remoteAddr := "GET", "/users/1", "127.0.0.1:53112"
+
+	for _, level := range []Level{InfoLevel, ErrorLevel} {
+		logger := newTestLogger(ioutil.Discard, level)
+
+		b.Run("WithFields/"+level.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				logger.WithFields(Fields{
+					Method:     method,
+					Path:       path,
+					RemoteAddr: remoteAddr,
+				}).Info("HTTP request received")
+			}
+		})
+
+		b.Run("Log/"+level.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				Log(logger, InfoLevel, "HTTP request received", func(f Fields) {
+					f[Method] = method
+					f[Path] = path
+					f[RemoteAddr] = remoteAddr
+				})
+			}
+		})
+	}
+}
@@ -5,11 +5,74 @@
 package logging
 
 import (
+	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// Fields contains the structured fields included in a log message
+type Fields map[string]interface{}
+
+// Level is the severity of a log message. The values are the same as logrus' levels so that
+// existing 'logLevel' configurations remain valid.
+type Level uint32
+
+const (
+	// PanicLevel is the highest severity
+	PanicLevel Level = iota
+	// FatalLevel is used for errors that cause the service to exit
+	FatalLevel
+	// ErrorLevel is used for errors that should be addressed
+	ErrorLevel
+	// WarnLevel is used for non-critical issues
+	WarnLevel
+	// InfoLevel is used for general operational information
+	InfoLevel
+	// DebugLevel is used for verbose, diagnostic, information
+	DebugLevel
+	// TraceLevel is the lowest severity
+	TraceLevel
+)
+
+// String returns the level's name, e.g., 'info'
+func (l Level) String() string {
+	return log.Level(l).String()
+}
+
+// Logger is the logging interface used by the handlers, services, and repositories. Implementations
+// must not retain the Fields passed to WithFields, they may be reused once WithFields returns.
+type Logger interface {
+	// WithFields returns a Logger that includes 'fields', in addition to this Logger's fields, in
+	// each log message
+	WithFields(fields Fields) Logger
+	// IsLevelEnabled returns true if messages at 'level' will be logged
+	IsLevelEnabled(level Level) bool
+
+	Debug(args ...interface{})
+	Debugf(format string, args ...interface{})
+	Info(args ...interface{})
+	Infof(format string, args ...interface{})
+	Warn(args ...interface{})
+	Warnf(format string, args ...interface{})
+	Error(args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// backend creates a Logger that logs messages at 'level' or higher severity
+type backend func(level Level) (Logger, error)
+
+// backends contains the available Logger implementations. Logrus is always available, others are
+// added by building with the corresponding build tag, e.g., 'go build -tags zap'.
+var backends = map[string]backend{
+	Logrus: newLogrusBackend,
+}
+
+// Logrus is the name of the default Logger implementation
+const Logrus = "logrus"
+
 var logger *log.Entry
 
 func init() {
@@ -21,18 +84,42 @@ func init() {
 
 	// Only log the DEBUG severity or above.
 	log.SetLevel(log.InfoLevel)
-	hostName, err := os.Hostname()
-	if err != nil {
-		hostName = "unknown"
-	}
 
 	logger = log.WithFields(log.Fields{
-		HostName: hostName,
+		HostName: hostName(),
 	})
 
 }
 
-// GetLogger gets the common logger for the customer service
+// GetLogger gets the common logrus logger for the customer service
 func GetLogger() *log.Entry {
 	return logger
 }
+
+// Default returns the common logger, as a Logger, for the customer service
+func Default() Logger {
+	return NewLogrusLogger(logger)
+}
+
+// New returns a Logger, implemented by the backend identified by 'name' (e.g., 'logrus'), that
+// logs messages at 'level' or higher severity
+func New(name string, level Level) (Logger, error) {
+	b, ok := backends[name]
+	if !ok {
+		names := []string{}
+		for n := range backends {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown logger %s, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return b(level)
+}
+
+func hostName() string {
+	hostName, err := os.Hostname()
+	if err != nil {
+		hostName = "unknown"
+	}
+	return hostName
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestNew(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+
+	tcs := []struct {
+		testName    string
+		backend     string
+		level       Level
+		shouldError bool
+	}{
+		{
+			testName: "testLogrus",
+			backend:  Logrus,
+			level:    WarnLevel,
+		},
+		{
+			testName:    "testUnknownBackend",
+			backend:     "log4go",
+			level:       InfoLevel,
+			shouldError: true,
+		},
+		{
+			testName:    "testInvalidLevel",
+			backend:     Logrus,
+			level:       TraceLevel + 1,
+			shouldError: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			logger, err := New(tc.backend, tc.level)
+			if tc.shouldError {
+				if err == nil {
+					t.Errorf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error %s was not expected", err)
+			}
+
+			if !logger.IsLevelEnabled(tc.level) {
+				t.Errorf("expected level %s to be enabled", tc.level)
+			}
+			if logger.IsLevelEnabled(tc.level + 1) {
+				t.Errorf("expected level %s to be disabled", tc.level+1)
+			}
+		})
+	}
+}
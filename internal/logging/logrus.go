@@ -0,0 +1,55 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// logrusLogger is a Logger implemented by logrus
+type logrusLogger struct {
+	entry *log.Entry
+}
+
+// NewLogrusLogger returns a Logger that logs using 'entry'
+func NewLogrusLogger(entry *log.Entry) Logger {
+	return logrusLogger{entry: entry}
+}
+
+// newLogrusBackend returns the common logger after setting the logrus level to 'level'
+func newLogrusBackend(level Level) (Logger, error) {
+	if level > TraceLevel {
+		return nil, errors.New("invalid log level")
+	}
+	log.SetLevel(log.Level(level))
+	return Default(), nil
+}
+
+func (l logrusLogger) WithFields(fields Fields) Logger {
+	return logrusLogger{entry: l.entry.WithFields(log.Fields(fields))}
+}
+
+func (l logrusLogger) IsLevelEnabled(level Level) bool {
+	return l.entry.Logger.IsLevelEnabled(log.Level(level))
+}
+
+func (l logrusLogger) Debug(args ...interface{}) { l.entry.Debug(args...) }
+func (l logrusLogger) Debugf(format string, args ...interface{}) {
+	l.entry.Debugf(format, args...)
+}
+func (l logrusLogger) Info(args ...interface{}) { l.entry.Info(args...) }
+func (l logrusLogger) Infof(format string, args ...interface{}) {
+	l.entry.Infof(format, args...)
+}
+func (l logrusLogger) Warn(args ...interface{}) { l.entry.Warn(args...) }
+func (l logrusLogger) Warnf(format string, args ...interface{}) {
+	l.entry.Warnf(format, args...)
+}
+func (l logrusLogger) Error(args ...interface{}) { l.entry.Error(args...) }
+func (l logrusLogger) Errorf(format string, args ...interface{}) {
+	l.entry.Errorf(format, args...)
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build zap
+// +build zap
+
+package logging
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func init() {
+	backends["zap"] = newZapBackend
+}
+
+// zapLogger is a Logger implemented by zap's SugaredLogger
+type zapLogger struct {
+	s     *zap.SugaredLogger
+	level Level
+}
+
+func newZapBackend(level Level) (Logger, error) {
+	cfg := zap.NewProductionConfig()
+	cfg.Level = zap.NewAtomicLevelAt(toZapLevel(level))
+	cfg.OutputPaths = []string{"stdout"}
+	l, err := cfg.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return zapLogger{s: l.Sugar().With(HostName, hostName()), level: level}, nil
+}
+
+// toZapLevel converts 'level' to the equivalent zap level. zap doesn't have a trace level so
+// TraceLevel is treated as DebugLevel.
+func toZapLevel(level Level) zapcore.Level {
+	switch level {
+	case PanicLevel:
+		return zapcore.PanicLevel
+	case FatalLevel:
+		return zapcore.FatalLevel
+	case ErrorLevel:
+		return zapcore.ErrorLevel
+	case WarnLevel:
+		return zapcore.WarnLevel
+	case InfoLevel:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
+	}
+}
+
+func (l zapLogger) WithFields(fields Fields) Logger {
+	kvs := make([]interface{}, 0, 2*len(fields))
+	for k, v := range fields {
+		kvs = append(kvs, k, v)
+	}
+	return zapLogger{s: l.s.With(kvs...), level: l.level}
+}
+
+func (l zapLogger) IsLevelEnabled(level Level) bool {
+	return level <= l.level
+}
+
+func (l zapLogger) Debug(args ...interface{}) { l.s.Debug(args...) }
+func (l zapLogger) Debugf(format string, args ...interface{}) {
+	l.s.Debugf(format, args...)
+}
+func (l zapLogger) Info(args ...interface{}) { l.s.Info(args...) }
+func (l zapLogger) Infof(format string, args ...interface{}) {
+	l.s.Infof(format, args...)
+}
+func (l zapLogger) Warn(args ...interface{}) { l.s.Warn(args...) }
+func (l zapLogger) Warnf(format string, args ...interface{}) {
+	l.s.Warnf(format, args...)
+}
+func (l zapLogger) Error(args ...interface{}) { l.s.Error(args...) }
+func (l zapLogger) Errorf(format string, args ...interface{}) {
+	l.s.Errorf(format, args...)
+}
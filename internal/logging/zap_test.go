@@ -0,0 +1,85 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build zap
+// +build zap
+
+package logging
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNewZap(t *testing.T) {
+	logger, err := New("zap", WarnLevel)
+	if err != nil {
+		t.Fatalf("error %s was not expected", err)
+	}
+	if _, ok := logger.(zapLogger); !ok {
+		t.Fatalf("expected a zapLogger, got %T", logger)
+	}
+	if !logger.IsLevelEnabled(WarnLevel) {
+		t.Errorf("expected level %s to be enabled", WarnLevel)
+	}
+	if logger.IsLevelEnabled(InfoLevel) {
+		t.Errorf("expected level %s to be disabled", InfoLevel)
+	}
+}
+
+func TestZapLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	var logger Logger = zapLogger{s: zap.New(core).Sugar(), level: InfoLevel}
+
+	fields := Fields{"userID": 1}
+	logger = logger.WithFields(fields)
+	// WithFields must not retain 'fields'
+	fields["userID"] = 2
+
+	logger.Debug("not logged")
+	logger.Infof("user %s", "created")
+	logger.Error("failed")
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 log messages, got %d", len(entries))
+	}
+	if entries[0].Level != zapcore.InfoLevel || entries[0].Message != "user created" {
+		t.Errorf("expected info message 'user created', got %s message %q", entries[0].Level, entries[0].Message)
+	}
+	if entries[1].Level != zapcore.ErrorLevel || entries[1].Message != "failed" {
+		t.Errorf("expected error message 'failed', got %s message %q", entries[1].Level, entries[1].Message)
+	}
+	for _, e := range entries {
+		if got := e.ContextMap()["userID"]; got != int64(1) {
+			t.Errorf("expected field userID = 1, got %v", got)
+		}
+	}
+}
+
+func TestToZapLevel(t *testing.T) {
+	tcs := []struct {
+		level    Level
+		expected zapcore.Level
+	}{
+		{level: PanicLevel, expected: zapcore.PanicLevel},
+		{level: FatalLevel, expected: zapcore.FatalLevel},
+		{level: ErrorLevel, expected: zapcore.ErrorLevel},
+		{level: WarnLevel, expected: zapcore.WarnLevel},
+		{level: InfoLevel, expected: zapcore.InfoLevel},
+		{level: DebugLevel, expected: zapcore.DebugLevel},
+		{level: TraceLevel, expected: zapcore.DebugLevel},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.level.String(), func(t *testing.T) {
+			if got := toZapLevel(tc.level); got != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build zerolog
+// +build zerolog
+
+package logging
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog"
+)
+
+func init() {
+	backends["zerolog"] = newZerologBackend
+}
+
+// zerologLogger is a Logger implemented by zerolog
+type zerologLogger struct {
+	l     zerolog.Logger
+	level Level
+}
+
+func newZerologBackend(level Level) (Logger, error) {
+	l := zerolog.New(os.Stdout).
+		Level(toZerologLevel(level)).
+		With().
+		Timestamp().
+		Str(HostName, hostName()).
+		Logger()
+
+	return zerologLogger{l: l, level: level}, nil
+}
+
+// toZerologLevel converts 'level' to the equivalent zerolog level
+func toZerologLevel(level Level) zerolog.Level {
+	switch level {
+	case PanicLevel:
+		return zerolog.PanicLevel
+	case FatalLevel:
+		return zerolog.FatalLevel
+	case ErrorLevel:
+		return zerolog.ErrorLevel
+	case WarnLevel:
+		return zerolog.WarnLevel
+	case InfoLevel:
+		return zerolog.InfoLevel
+	case DebugLevel:
+		return zerolog.DebugLevel
+	default:
+		return zerolog.TraceLevel
+	}
+}
+
+func (l zerologLogger) WithFields(fields Fields) Logger {
+	return zerologLogger{l: l.l.With().Fields(map[string]interface{}(fields)).Logger(), level: l.level}
+}
+
+func (l zerologLogger) IsLevelEnabled(level Level) bool {
+	return level <= l.level
+}
+
+func (l zerologLogger) Debug(args ...interface{}) { l.l.Debug().Msg(fmt.Sprint(args...)) }
+func (l zerologLogger) Debugf(format string, args ...interface{}) {
+	l.l.Debug().Msgf(format, args...)
+}
+func (l zerologLogger) Info(args ...interface{}) { l.l.Info().Msg(fmt.Sprint(args...)) }
+func (l zerologLogger) Infof(format string, args ...interface{}) {
+	l.l.Info().Msgf(format, args...)
+}
+func (l zerologLogger) Warn(args ...interface{}) { l.l.Warn().Msg(fmt.Sprint(args...)) }
+func (l zerologLogger) Warnf(format string, args ...interface{}) {
+	l.l.Warn().Msgf(format, args...)
+}
+func (l zerologLogger) Error(args ...interface{}) { l.l.Error().Msg(fmt.Sprint(args...)) }
+func (l zerologLogger) Errorf(format string, args ...interface{}) {
+	l.l.Error().Msgf(format, args...)
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build zerolog
+// +build zerolog
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestNewZerolog(t *testing.T) {
+	logger, err := New("zerolog", WarnLevel)
+	if err != nil {
+		t.Fatalf("error %s was not expected", err)
+	}
+	if _, ok := logger.(zerologLogger); !ok {
+		t.Fatalf("expected a zerologLogger, got %T", logger)
+	}
+	if !logger.IsLevelEnabled(WarnLevel) {
+		t.Errorf("expected level %s to be enabled", WarnLevel)
+	}
+	if logger.IsLevelEnabled(InfoLevel) {
+		t.Errorf("expected level %s to be disabled", InfoLevel)
+	}
+}
+
+func TestZerologLogger(t *testing.T) {
+	var buf bytes.Buffer
+	var logger Logger = zerologLogger{l: zerolog.New(&buf).Level(zerolog.InfoLevel), level: InfoLevel}
+
+	fields := Fields{"userID": 1}
+	logger = logger.WithFields(fields)
+	// WithFields must not retain 'fields'
+	fields["userID"] = 2
+
+	logger.Debug("not logged")
+	logger.Infof("user %s", "created")
+	logger.Error("failed")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log messages, got %d: %s", len(lines), buf.String())
+	}
+
+	expected := []struct {
+		level, msg string
+	}{
+		{level: "info", msg: "user created"},
+		{level: "error", msg: "failed"},
+	}
+	for i, line := range lines {
+		msg := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatalf("error %s was not expected unmarshaling %s", err, line)
+		}
+		if msg["level"] != expected[i].level || msg["message"] != expected[i].msg {
+			t.Errorf("expected %s message %q, got %s", expected[i].level, expected[i].msg, line)
+		}
+		if msg["userID"] != float64(1) {
+			t.Errorf("expected field userID = 1, got %s", line)
+		}
+	}
+}
+
+func TestToZerologLevel(t *testing.T) {
+	tcs := []struct {
+		level    Level
+		expected zerolog.Level
+	}{
+		{level: PanicLevel, expected: zerolog.PanicLevel},
+		{level: FatalLevel, expected: zerolog.FatalLevel},
+		{level: ErrorLevel, expected: zerolog.ErrorLevel},
+		{level: WarnLevel, expected: zerolog.WarnLevel},
+		{level: InfoLevel, expected: zerolog.InfoLevel},
+		{level: DebugLevel, expected: zerolog.DebugLevel},
+		{level: TraceLevel, expected: zerolog.TraceLevel},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.level.String(), func(t *testing.T) {
+			if got := toZerologLevel(tc.level); got != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package metrics registers a service's Prometheus metrics under a configurable namespace and, optionally,
+subsystem. Metrics are defined in the packages that use them without a Namespace, e.g.:
+
+	var UserRqstDur = metrics.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: "user",
+		Name:      "user_request_duration_seconds",
+		...
+
+Each binary registers them with Register, supplying its own default namespace when one isn't configured.
+With the namespace 'mockvideo' and no subsystem the metric above is named
+'mockvideo_user_user_request_duration_seconds'. Configuring a different namespace, or a subsystem such as
+'accountd', keeps the metrics of several services deployed to one cluster from colliding, e.g.,
+'mockvideo_accountd_user_user_request_duration_seconds'.
+
+Registering a collector twice, e.g., because it's listed twice, doesn't fail or panic, the existing
+collector is kept. RegisterCollector returns the existing collector for callers that create collectors
+dynamically and need to observe through the registered one. Names lists the names the metrics are
+registered under so a binary can log them at startup.
+
+Collectors that follow their own naming conventions, e.g., prometheus.NewBuildInfoCollector, shouldn't be
+registered with Register.
+
+Metrics are created with NewCounter, NewGauge, NewCounterVec, NewGaugeVec, and NewHistogramVec rather than
+their prometheus equivalents. They behave the same until Disable is called, after which they're no-ops:
+updating them records nothing and creates no series. A service that doesn't expose its metrics, e.g., to
+keep its footprint small, calls Disable at startup so its request paths don't pay for them.
+*/
+package metrics
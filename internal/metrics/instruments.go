@@ -0,0 +1,206 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// disabled is 1 once Disable has been called
+var disabled int32
+
+// Disable makes the metrics created by NewCounter, NewGauge, NewCounterVec, NewGaugeVec, and
+// NewHistogramVec no-ops. Updating them records nothing and creates no series. It's meant to be
+// called at startup, before any metrics are updated, by a service that doesn't expose its metrics.
+func Disable() {
+	atomic.StoreInt32(&disabled, 1)
+}
+
+// Enable reverses Disable
+func Enable() {
+	atomic.StoreInt32(&disabled, 0)
+}
+
+// Enabled returns false if Disable has been called
+func Enabled() bool {
+	return atomic.LoadInt32(&disabled) == 0
+}
+
+// Counter is a counter that's a no-op once metrics are disabled, see Disable. prometheus.Counter
+// implements it.
+type Counter interface {
+	prometheus.Collector
+	Inc()
+	Add(v float64)
+}
+
+// Gauge is a gauge that's a no-op once metrics are disabled, see Disable. prometheus.Gauge
+// implements it.
+type Gauge interface {
+	prometheus.Collector
+	Set(v float64)
+	Inc()
+	Dec()
+	Add(v float64)
+	Sub(v float64)
+}
+
+// noop is the Counter, Gauge, and prometheus.Observer used once metrics are disabled
+type noop struct{}
+
+func (noop) Describe(chan<- *prometheus.Desc) {}
+func (noop) Collect(chan<- prometheus.Metric) {}
+func (noop) Set(float64)                      {}
+func (noop) Inc()                             {}
+func (noop) Dec()                             {}
+func (noop) Add(float64)                      {}
+func (noop) Sub(float64)                      {}
+func (noop) Observe(float64)                  {}
+
+// counter is a Counter that updates a prometheus.Counter while metrics are enabled
+type counter struct {
+	prometheus.Counter
+}
+
+// NewCounter returns a Counter, see prometheus.NewCounter
+func NewCounter(opts prometheus.CounterOpts) Counter {
+	return counter{Counter: prometheus.NewCounter(opts)}
+}
+
+func (c counter) Inc() {
+	if Enabled() {
+		c.Counter.Inc()
+	}
+}
+
+func (c counter) Add(v float64) {
+	if Enabled() {
+		c.Counter.Add(v)
+	}
+}
+
+// gauge is a Gauge that updates a prometheus.Gauge while metrics are enabled
+type gauge struct {
+	prometheus.Gauge
+}
+
+// NewGauge returns a Gauge, see prometheus.NewGauge
+func NewGauge(opts prometheus.GaugeOpts) Gauge {
+	return gauge{Gauge: prometheus.NewGauge(opts)}
+}
+
+func (g gauge) Set(v float64) {
+	if Enabled() {
+		g.Gauge.Set(v)
+	}
+}
+
+func (g gauge) Inc() {
+	if Enabled() {
+		g.Gauge.Inc()
+	}
+}
+
+func (g gauge) Dec() {
+	if Enabled() {
+		g.Gauge.Dec()
+	}
+}
+
+func (g gauge) Add(v float64) {
+	if Enabled() {
+		g.Gauge.Add(v)
+	}
+}
+
+func (g gauge) Sub(v float64) {
+	if Enabled() {
+		g.Gauge.Sub(v)
+	}
+}
+
+// CounterVec is a prometheus.CounterVec whose counters are no-ops once metrics are disabled
+type CounterVec struct {
+	vec *prometheus.CounterVec
+}
+
+// NewCounterVec returns a CounterVec, see prometheus.NewCounterVec
+func NewCounterVec(opts prometheus.CounterOpts, labelNames []string) *CounterVec {
+	return &CounterVec{vec: prometheus.NewCounterVec(opts, labelNames)}
+}
+
+// WithLabelValues returns the Counter for 'lvs', see prometheus.CounterVec.WithLabelValues
+func (v *CounterVec) WithLabelValues(lvs ...string) Counter {
+	if !Enabled() {
+		return noop{}
+	}
+	return v.vec.WithLabelValues(lvs...)
+}
+
+// Reset deletes all of the CounterVec's counters
+func (v *CounterVec) Reset() { v.vec.Reset() }
+
+// Describe implements prometheus.Collector
+func (v *CounterVec) Describe(ch chan<- *prometheus.Desc) { v.vec.Describe(ch) }
+
+// Collect implements prometheus.Collector
+func (v *CounterVec) Collect(ch chan<- prometheus.Metric) { v.vec.Collect(ch) }
+
+// GaugeVec is a prometheus.GaugeVec whose gauges are no-ops once metrics are disabled
+type GaugeVec struct {
+	vec *prometheus.GaugeVec
+}
+
+// NewGaugeVec returns a GaugeVec, see prometheus.NewGaugeVec
+func NewGaugeVec(opts prometheus.GaugeOpts, labelNames []string) *GaugeVec {
+	return &GaugeVec{vec: prometheus.NewGaugeVec(opts, labelNames)}
+}
+
+// WithLabelValues returns the Gauge for 'lvs', see prometheus.GaugeVec.WithLabelValues
+func (v *GaugeVec) WithLabelValues(lvs ...string) Gauge {
+	if !Enabled() {
+		return noop{}
+	}
+	return v.vec.WithLabelValues(lvs...)
+}
+
+// Reset deletes all of the GaugeVec's gauges
+func (v *GaugeVec) Reset() { v.vec.Reset() }
+
+// Describe implements prometheus.Collector
+func (v *GaugeVec) Describe(ch chan<- *prometheus.Desc) { v.vec.Describe(ch) }
+
+// Collect implements prometheus.Collector
+func (v *GaugeVec) Collect(ch chan<- prometheus.Metric) { v.vec.Collect(ch) }
+
+// HistogramVec is a prometheus.HistogramVec whose observers are no-ops once metrics are disabled
+type HistogramVec struct {
+	vec *prometheus.HistogramVec
+}
+
+// NewHistogramVec returns a HistogramVec, see prometheus.NewHistogramVec
+func NewHistogramVec(opts prometheus.HistogramOpts, labelNames []string) *HistogramVec {
+	return &HistogramVec{vec: prometheus.NewHistogramVec(opts, labelNames)}
+}
+
+// WithLabelValues returns the prometheus.Observer for 'lvs', see
+// prometheus.HistogramVec.WithLabelValues
+func (v *HistogramVec) WithLabelValues(lvs ...string) prometheus.Observer {
+	if !Enabled() {
+		return noop{}
+	}
+	return v.vec.WithLabelValues(lvs...)
+}
+
+// Reset deletes all of the HistogramVec's histograms
+func (v *HistogramVec) Reset() { v.vec.Reset() }
+
+// Describe implements prometheus.Collector
+func (v *HistogramVec) Describe(ch chan<- *prometheus.Desc) { v.vec.Describe(ch) }
+
+// Collect implements prometheus.Collector
+func (v *HistogramVec) Collect(ch chan<- prometheus.Metric) { v.vec.Collect(ch) }
@@ -0,0 +1,84 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// collected returns the number of metrics 'c' collects
+func collected(c prometheus.Collector) int {
+	ch := make(chan prometheus.Metric, 10)
+	c.Collect(ch)
+	close(ch)
+	return len(ch)
+}
+
+func TestDisable(t *testing.T) {
+	tcs := []struct {
+		testName string
+		disabled bool
+		expected int
+	}{
+		{testName: "testEnabled", expected: 1},
+		{testName: "testDisabled", disabled: true, expected: 0},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			if tc.disabled {
+				Disable()
+				defer Enable()
+			}
+			cv := NewCounterVec(prometheus.CounterOpts{Name: "test_total", Help: "test"}, []string{"code"})
+			gv := NewGaugeVec(prometheus.GaugeOpts{Name: "test_gauge", Help: "test"}, []string{"code"})
+			hv := NewHistogramVec(prometheus.HistogramOpts{Name: "test_seconds", Help: "test"}, []string{"code"})
+			c := NewCounter(prometheus.CounterOpts{Name: "test_counter_total", Help: "test"})
+			g := NewGauge(prometheus.GaugeOpts{Name: "test_single_gauge", Help: "test"})
+
+			update := func() {
+				cv.WithLabelValues("200").Inc()
+				gv.WithLabelValues("200").Set(1)
+				hv.WithLabelValues("200").Observe(0.1)
+				c.Add(2)
+				g.Inc()
+			}
+			update()
+			for _, coll := range []prometheus.Collector{cv, gv, hv} {
+				if n := collected(coll); n != tc.expected {
+					t.Errorf("expected %d series, got %d", tc.expected, n)
+				}
+			}
+			if tc.disabled {
+				if v := testValue(t, c); v != 0 {
+					t.Errorf("expected the counter to be 0, got %f", v)
+				}
+				if v := testValue(t, g); v != 0 {
+					t.Errorf("expected the gauge to be 0, got %f", v)
+				}
+				if allocs := testing.AllocsPerRun(100, func() { c.Inc(); g.Set(1) }); allocs != 0 {
+					t.Errorf("expected disabled metrics to do no work, got %f allocations", allocs)
+				}
+			}
+		})
+	}
+}
+
+// testValue returns the value of the counter or gauge 'c'
+func testValue(t *testing.T, c prometheus.Collector) float64 {
+	ch := make(chan prometheus.Metric, 1)
+	c.Collect(ch)
+	m := &dto.Metric{}
+	if err := (<-ch).Write(m); err != nil {
+		t.Fatalf("error %s was not expected", err)
+	}
+	if m.Counter != nil {
+		return m.Counter.GetValue()
+	}
+	return m.Gauge.GetValue()
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultNamespace is the namespace of the mockvideo services' metrics unless one is configured
+const DefaultNamespace = "mockvideo"
+
+// validName matches valid metric name components, see https://prometheus.io/docs/concepts/data_model/.
+// Colons are excluded since they're reserved for recording rules.
+var validName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Prefix returns the prefix added to the names of the metrics registered with Register, e.g.,
+// 'mockvideo_accountd_' for the namespace 'mockvideo' and subsystem 'accountd'. 'namespace' is
+// required, 'subsystem' is optional.
+func Prefix(namespace, subsystem string) (string, error) {
+	if !validName.MatchString(namespace) {
+		return "", fmt.Errorf("invalid metrics namespace %q, it must match %s", namespace, validName)
+	}
+	parts := []string{namespace}
+	if subsystem != "" {
+		if !validName.MatchString(subsystem) {
+			return "", fmt.Errorf("invalid metrics subsystem %q, it must match %s", subsystem, validName)
+		}
+		parts = append(parts, subsystem)
+	}
+	return strings.Join(parts, "_") + "_", nil
+}
+
+// Register registers 'cs' with 'reg', prefixing their names with Prefix(namespace, subsystem).
+// A collector that's already registered, e.g., because it's registered twice, isn't an error, the
+// existing collector is kept, see RegisterCollector. An error is returned if the namespace or
+// subsystem is invalid, or if any of 'cs' can't be registered, e.g., because it conflicts with a
+// different metric of the same name.
+func Register(reg prometheus.Registerer, namespace, subsystem string, cs ...prometheus.Collector) error {
+	for _, c := range cs {
+		if _, err := RegisterCollector(reg, namespace, subsystem, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterCollector registers 'c' as Register does and returns the collector that collects its
+// metrics. That's 'c' unless an equal collector, one describing the same metrics, is already
+// registered, then it's the existing collector. Observations must be made on the returned
+// collector, those made on a duplicate 'c' aren't collected.
+func RegisterCollector(reg prometheus.Registerer, namespace, subsystem string, c prometheus.Collector) (prometheus.Collector, error) {
+	prefix, err := Prefix(namespace, subsystem)
+	if err != nil {
+		return nil, err
+	}
+	err = prometheus.WrapRegistererWithPrefix(prefix, reg).Register(c)
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		return are.ExistingCollector, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// fqName extracts a metric's fully qualified name from the description returned by Desc.String,
+// prometheus.Desc doesn't otherwise expose it
+var fqName = regexp.MustCompile(`fqName: "([^"]*)"`)
+
+// Names returns the sorted names of the metrics described by 'cs' as they're named when registered
+// with Register, i.e., prefixed with Prefix(namespace, subsystem). It's intended for logging which
+// metrics are exported.
+func Names(namespace, subsystem string, cs ...prometheus.Collector) ([]string, error) {
+	prefix, err := Prefix(namespace, subsystem)
+	if err != nil {
+		return nil, err
+	}
+
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		for _, c := range cs {
+			c.Describe(descs)
+		}
+		close(descs)
+	}()
+	unique := map[string]bool{}
+	for d := range descs {
+		if m := fqName.FindStringSubmatch(d.String()); m != nil {
+			unique[prefix+m[1]] = true
+		}
+	}
+
+	names := make([]string, 0, len(unique))
+	for name := range unique {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPrefix(t *testing.T) {
+	tcs := []struct {
+		testName   string
+		namespace  string
+		subsystem  string
+		expected   string
+		shouldPass bool
+	}{
+		{testName: "testNamespace", namespace: DefaultNamespace, expected: "mockvideo_", shouldPass: true},
+		{testName: "testSubsystem", namespace: DefaultNamespace, subsystem: "accountd", expected: "mockvideo_accountd_", shouldPass: true},
+		{testName: "testUnderscores", namespace: "_mock_video", subsystem: "account_d2", expected: "_mock_video_account_d2_", shouldPass: true},
+		{testName: "testNoNamespace", namespace: "", subsystem: "accountd", shouldPass: false},
+		{testName: "testInvalidNamespace", namespace: "mock-video", shouldPass: false},
+		{testName: "testLeadingDigit", namespace: "2mockvideo", shouldPass: false},
+		{testName: "testInvalidSubsystem", namespace: DefaultNamespace, subsystem: "account:d", shouldPass: false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			prefix, err := Prefix(tc.namespace, tc.subsystem)
+			if !tc.shouldPass {
+				if err == nil {
+					t.Errorf("expected an error, got prefix %q", prefix)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error %s was not expected", err)
+			}
+			if prefix != tc.expected {
+				t.Errorf("expected prefix %q, got %q", tc.expected, prefix)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	c := prometheus.NewCounter(prometheus.CounterOpts{Subsystem: "test", Name: "requests_total", Help: "test requests"})
+	reg := prometheus.NewRegistry()
+	if err := Register(reg, "bogus-namespace", "", c); err == nil {
+		t.Error("expected an error registering with an invalid namespace")
+	}
+	if err := Register(reg, DefaultNamespace, "accountd", c); err != nil {
+		t.Fatalf("error %s was not expected registering a collector", err)
+	}
+	if err := Register(reg, DefaultNamespace, "accountd", c, c); err != nil {
+		t.Errorf("error %s was not expected registering a collector twice", err)
+	}
+
+	// An equal collector is replaced by the existing one
+	dup := prometheus.NewCounter(prometheus.CounterOpts{Subsystem: "test", Name: "requests_total", Help: "test requests"})
+	existing, err := RegisterCollector(reg, DefaultNamespace, "accountd", dup)
+	if err != nil {
+		t.Fatalf("error %s was not expected registering an equal collector", err)
+	}
+	if existing != c {
+		t.Errorf("expected the existing collector to be returned, got %v", existing)
+	}
+
+	// A different metric with the same name can't be registered
+	conflict := prometheus.NewGauge(prometheus.GaugeOpts{Subsystem: "test", Name: "requests_total", Help: "other requests"})
+	if err = Register(reg, DefaultNamespace, "accountd", conflict); err == nil {
+		t.Error("expected an error registering a conflicting collector")
+	}
+}
+
+func TestNames(t *testing.T) {
+	c := prometheus.NewCounter(prometheus.CounterOpts{Subsystem: "test", Name: "requests_total", Help: "test requests"})
+	v := prometheus.NewHistogramVec(prometheus.HistogramOpts{Subsystem: "test", Name: "request_duration_seconds", Help: "test durations"}, []string{"code"})
+
+	names, err := Names(DefaultNamespace, "accountd", c, v, c)
+	if err != nil {
+		t.Fatalf("error %s was not expected", err)
+	}
+	// Vectors are named even if they don't have any metrics yet
+	expected := []string{"mockvideo_accountd_test_request_duration_seconds", "mockvideo_accountd_test_requests_total"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected names %v, got %v", expected, names)
+	}
+	if _, err = Names("bogus-namespace", "", c); err == nil {
+		t.Error("expected an error with an invalid namespace")
+	}
+}
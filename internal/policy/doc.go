@@ -0,0 +1,37 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package policy provides a small, declarative, authorization policy engine. A policy is a list of rules
+mapping a caller's role, a request method, and a resource to an 'allow' or 'deny' effect. Policies are
+read from a file, one rule per line:
+
+		# role          method  resource                        effect
+		primary         *       *                               allow
+		*               GET     /users                          allow
+		*               GET     /users/{id}                     allow
+		*               PUT     /users/{id}                     allow
+		restricted      *       /accounts/*                     deny
+		unrestricted    GRPC    /accountd.UserServer/*        allow
+
+The role is one of 'primary', 'unrestricted', or 'restricted'. The method is an HTTP method or, for gRPC
+requests, 'GRPC'. The resource is a URL path for HTTP requests or the full method name, e.g.,
+'/accountd.UserServer/GetUser', for gRPC requests. In resource patterns a '{name}' segment matches any
+single path segment and a final '*' segment matches any remaining segments. '*' matches any role or method.
+Blank lines and lines starting with '#' are ignored.
+
+Rules are evaluated in order and the first matching rule decides. A request that doesn't match any rule
+is denied. Only requests with an auth.Caller are evaluated, requests without one, e.g., those that weren't
+authenticated, are left to the services layer, which still checks that callers only act on their own
+account.
+
+Before a policy is evaluated the caller's Scopes must grant the scope the request requires, see
+RequiredScope. A request whose caller lacks the scope is denied whatever the policy says. Middleware
+and UnaryServerInterceptor can be used without an Engine to only check scopes.
+
+Middleware and UnaryServerInterceptor evaluate the policy for HTTP and gRPC requests respectively. When
+the Engine's decision log is enabled, see SetDecisionLog, every decision is logged along with the rule
+that made it, which helps troubleshoot a policy.
+*/
+package policy
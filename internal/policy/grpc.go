@@ -0,0 +1,49 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"context"
+
+	"github.com/youngkin/mockvideo/internal/auth"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor is the gRPC counterpart of Middleware. Requests are evaluated with the
+// GRPCMethod method and their full method name, e.g., '/accountd.UserServer/GetUser', as the
+// resource. Denied requests fail with a PermissionDenied status. Only scopes are checked if 'e' is nil.
+func UnaryServerInterceptor(e *Engine, logger logging.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		caller, ok := auth.FromContext(ctx)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		code, msg := mverr.NoErrorCode, ""
+		scope := RequiredScope(GRPCMethod, info.FullMethod)
+		if !caller.HasScope(scope) {
+			code, msg = mverr.InsufficientScopeErrorCode, mverr.InsufficientScopeErrorMsg
+		} else if e != nil && !e.Decide(Request{Role: caller.Role, Method: GRPCMethod, Resource: info.FullMethod}).Allowed {
+			code, msg = mverr.PolicyDeniedErrorCode, mverr.PolicyDeniedErrorMsg
+		}
+		if code != mverr.NoErrorCode {
+			logger.WithFields(logging.Fields{
+				logging.Audit:     true,
+				logging.ErrorCode: code,
+				logging.UserID:    caller.UserID,
+				logging.AccountID: caller.AccountID,
+				logging.APIKey:    caller.APIKey,
+				logging.Role:      caller.Role,
+				logging.Scope:     scope,
+				logging.RPCFunc:   info.FullMethod,
+			}).Warn(msg)
+			return nil, status.Error(mverr.GRPCCode(code), msg)
+		}
+		return handler(ctx, req)
+	}
+}
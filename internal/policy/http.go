@@ -0,0 +1,58 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"net/http"
+
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/clientinfo"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// Middleware evaluates the policy for requests with an auth.Caller. Requests whose caller doesn't have
+// the scope the request requires, see RequiredScope, or that are denied by the policy are rejected with
+// a 403 HTTP status and recorded in an audit log entry. Only scopes are checked if 'e' is nil. It must
+// be applied after the middleware that adds the auth.Caller to the request's context.
+func Middleware(e *Engine, logger logging.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			caller, ok := auth.FromContext(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			code, msg := mverr.NoErrorCode, ""
+			scope := RequiredScope(r.Method, r.URL.Path)
+			if !caller.HasScope(scope) {
+				code, msg = mverr.InsufficientScopeErrorCode, mverr.InsufficientScopeErrorMsg
+			} else if e != nil && !e.Decide(Request{Role: caller.Role, Method: r.Method, Resource: r.URL.Path}).Allowed {
+				code, msg = mverr.PolicyDeniedErrorCode, mverr.PolicyDeniedErrorMsg
+			}
+			if code != mverr.NoErrorCode {
+				logger.WithFields(logging.Fields{
+					logging.Audit:      true,
+					logging.Client:     clientinfo.FromContext(r.Context()),
+					logging.ErrorCode:  code,
+					logging.HTTPStatus: http.StatusForbidden,
+					logging.UserID:     caller.UserID,
+					logging.AccountID:  caller.AccountID,
+					logging.APIKey:     caller.APIKey,
+					logging.Role:       caller.Role,
+					logging.Scope:      scope,
+					logging.Method:     r.Method,
+					logging.Path:       r.URL.Path,
+				}).Warn(msg)
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(msg))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+// Any matches any role, method, or remaining resource path segments in a Rule
+const Any = "*"
+
+// GRPCMethod is the method of gRPC requests
+const GRPCMethod = "GRPC"
+
+// Effect is the outcome of a Rule that matches a request
+type Effect string
+
+const (
+	// Allow allows the request
+	Allow Effect = "allow"
+	// Deny denies the request
+	Deny Effect = "deny"
+)
+
+// roles maps the role names used in rules to Roles
+var roles = map[string]domain.Role{
+	"primary":      domain.Primary,
+	"unrestricted": domain.Unrestricted,
+	"restricted":   domain.Restricted,
+}
+
+// Rule maps a role, method, and resource to an Effect, see the package documentation
+type Rule struct {
+	Role     string
+	Method   string
+	Resource string
+	Effect   Effect
+}
+
+// String returns the rule as it's written in a policy file
+func (r Rule) String() string {
+	return fmt.Sprintf("%s %s %s %s", r.Role, r.Method, r.Resource, r.Effect)
+}
+
+// matches returns true if the rule applies to 'req'
+func (r Rule) matches(req Request) bool {
+	if r.Role != Any && roles[r.Role] != req.Role {
+		return false
+	}
+	if r.Method != Any && !strings.EqualFold(r.Method, req.Method) {
+		return false
+	}
+	return matchResource(r.Resource, req.Resource)
+}
+
+// matchResource returns true if 'resource' matches 'pattern'
+func matchResource(pattern, resource string) bool {
+	if pattern == Any {
+		return true
+	}
+	patternNodes := strings.Split(strings.Trim(pattern, "/"), "/")
+	resourceNodes := strings.Split(strings.Trim(resource, "/"), "/")
+	for i, node := range patternNodes {
+		if node == Any && i == len(patternNodes)-1 {
+			return true
+		}
+		if i >= len(resourceNodes) {
+			return false
+		}
+		if strings.HasPrefix(node, "{") && strings.HasSuffix(node, "}") {
+			continue
+		}
+		if node != resourceNodes[i] {
+			return false
+		}
+	}
+	return len(patternNodes) == len(resourceNodes)
+}
+
+// Request is the part of a request that a policy is evaluated against
+type Request struct {
+	Role     domain.Role
+	Method   string
+	Resource string
+}
+
+// Decision is the outcome of evaluating a policy
+type Decision struct {
+	Allowed bool
+	// Rule is the rule that made the decision, it's nil if no rule matched the request
+	Rule *Rule
+}
+
+// Engine evaluates a policy. It's safe for concurrent use.
+type Engine struct {
+	rules []Rule
+	// logger is only set when the decision log is enabled
+	logger logging.Logger
+}
+
+// NewEngine returns an Engine that evaluates the policy defined by 'rules'
+func NewEngine(rules []Rule) (*Engine, error) {
+	for i, r := range rules {
+		if err := validate(r); err != nil {
+			return nil, fmt.Errorf("invalid rule %d, %q: %s", i+1, r, err)
+		}
+	}
+	return &Engine{rules: rules}, nil
+}
+
+// SetDecisionLog enables the decision log. Each decision is logged to 'logger', which must be non-nil.
+func (e *Engine) SetDecisionLog(logger logging.Logger) error {
+	if logger == nil {
+		return errors.New("non-nil Logger required")
+	}
+	e.logger = logger
+	return nil
+}
+
+// Decide evaluates the policy for 'req'. The first matching rule decides, if no rule matches the
+// request is denied.
+func (e *Engine) Decide(req Request) Decision {
+	d := Decision{}
+	for i := range e.rules {
+		if e.rules[i].matches(req) {
+			d = Decision{Allowed: e.rules[i].Effect == Allow, Rule: &e.rules[i]}
+			break
+		}
+	}
+
+	if e.logger != nil {
+		rule := "none"
+		if d.Rule != nil {
+			rule = d.Rule.String()
+		}
+		e.logger.WithFields(logging.Fields{
+			logging.Role:       req.Role,
+			logging.Method:     req.Method,
+			logging.Resource:   req.Resource,
+			logging.Decision:   decisionName(d.Allowed),
+			logging.PolicyRule: rule,
+		}).Info("authorization policy decision")
+	}
+	return d
+}
+
+// decisionName returns the Effect name of a decision
+func decisionName(allowed bool) Effect {
+	if allowed {
+		return Allow
+	}
+	return Deny
+}
+
+// validate returns an error if 'r' isn't a valid rule
+func validate(r Rule) error {
+	if _, ok := roles[r.Role]; !ok && r.Role != Any {
+		return fmt.Errorf("unknown role %q, must be one of 'primary', 'unrestricted', 'restricted', or '*'", r.Role)
+	}
+	if r.Method == "" {
+		return errors.New("method required")
+	}
+	if !strings.HasPrefix(r.Resource, "/") && r.Resource != Any {
+		return fmt.Errorf("resource %q must start with '/' or be '*'", r.Resource)
+	}
+	if r.Effect != Allow && r.Effect != Deny {
+		return fmt.Errorf("unknown effect %q, must be 'allow' or 'deny'", r.Effect)
+	}
+	return nil
+}
+
+// Parse reads the rules of a policy file, see the package documentation
+func Parse(policy io.Reader) ([]Rule, error) {
+	var rules []Rule
+	lineReader := bufio.NewScanner(policy)
+	for lineNum := 1; lineReader.Scan(); lineNum++ {
+		line := strings.TrimSpace(lineReader.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("line %d: expected 'role method resource effect', got %q", lineNum, line)
+		}
+		r := Rule{
+			Role:     strings.ToLower(fields[0]),
+			Method:   strings.ToUpper(fields[1]),
+			Resource: fields[2],
+			Effect:   Effect(strings.ToLower(fields[3])),
+		}
+		if err := validate(r); err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNum, err)
+		}
+		rules = append(rules, r)
+	}
+	if err := lineReader.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
@@ -0,0 +1,308 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/youngkin/mockvideo/internal/auth"
+	"github.com/youngkin/mockvideo/internal/domain"
+	"github.com/youngkin/mockvideo/internal/logging"
+)
+
+const testPolicy = `
+# role          method  resource                        effect
+primary         *       *                               allow
+*               GET     /users                          allow
+*               GET     /users/{id}                     allow
+restricted      *       /accounts/*                     deny
+unrestricted    *       /accounts/*                     allow
+unrestricted    GRPC    /accountd.UserServer/*          allow
+`
+
+func newEngine(t *testing.T) *Engine {
+	rules, err := Parse(strings.NewReader(testPolicy))
+	if err != nil {
+		t.Fatalf("error %s was not expected parsing the policy", err)
+	}
+	e, err := NewEngine(rules)
+	if err != nil {
+		t.Fatalf("error %s was not expected creating an Engine", err)
+	}
+	return e
+}
+
+func TestParse(t *testing.T) {
+	tcs := []struct {
+		testName   string
+		policy     string
+		expected   []Rule
+		shouldPass bool
+	}{
+		{
+			testName:   "testParseValid",
+			policy:     "# comment\n\nPrimary get /users/{id} Allow\n* * * deny\n",
+			expected:   []Rule{{Role: "primary", Method: "GET", Resource: "/users/{id}", Effect: Allow}, {Role: Any, Method: Any, Resource: Any, Effect: Deny}},
+			shouldPass: true,
+		},
+		{
+			testName:   "testParseEmpty",
+			policy:     "",
+			shouldPass: true,
+		},
+		{
+			testName:   "testParseMissingField",
+			policy:     "primary GET /users",
+			shouldPass: false,
+		},
+		{
+			testName:   "testParseUnknownRole",
+			policy:     "admin GET /users allow",
+			shouldPass: false,
+		},
+		{
+			testName:   "testParseRelativeResource",
+			policy:     "primary GET users allow",
+			shouldPass: false,
+		},
+		{
+			testName:   "testParseUnknownEffect",
+			policy:     "primary GET /users permit",
+			shouldPass: false,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			rules, err := Parse(strings.NewReader(tc.policy))
+			if tc.shouldPass != (err == nil) {
+				t.Fatalf("expected shouldPass %t, got error %v", tc.shouldPass, err)
+			}
+			if len(rules) != len(tc.expected) {
+				t.Fatalf("expected %d rules, got %d: %+v", len(tc.expected), len(rules), rules)
+			}
+			for i := range rules {
+				if rules[i] != tc.expected[i] {
+					t.Errorf("expected rule %d to be %+v, got %+v", i, tc.expected[i], rules[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewEngineInvalidRule(t *testing.T) {
+	if _, err := NewEngine([]Rule{{Role: "primary", Method: "GET", Resource: "/users", Effect: "maybe"}}); err == nil {
+		t.Error("expected an error for an invalid rule, got nil")
+	}
+}
+
+func TestDecide(t *testing.T) {
+	tcs := []struct {
+		testName        string
+		req             Request
+		expectedAllowed bool
+		expectedRule    string
+	}{
+		{
+			testName:        "testDecidePrimaryAnything",
+			req:             Request{Role: domain.Primary, Method: http.MethodDelete, Resource: "/users/1"},
+			expectedAllowed: true,
+			expectedRule:    "primary * * allow",
+		},
+		{
+			testName:        "testDecideAnyRoleGETUsers",
+			req:             Request{Role: domain.Restricted, Method: http.MethodGet, Resource: "/users"},
+			expectedAllowed: true,
+			expectedRule:    "* GET /users allow",
+		},
+		{
+			testName:        "testDecidePathParameter",
+			req:             Request{Role: domain.Restricted, Method: http.MethodGet, Resource: "/users/42"},
+			expectedAllowed: true,
+			expectedRule:    "* GET /users/{id} allow",
+		},
+		{
+			testName:        "testDecidePathParameterExtraSegment",
+			req:             Request{Role: domain.Restricted, Method: http.MethodGet, Resource: "/users/42/roles"},
+			expectedAllowed: false,
+		},
+		{
+			testName:        "testDecideTrailingWildcardDeny",
+			req:             Request{Role: domain.Restricted, Method: http.MethodGet, Resource: "/accounts/1/summary"},
+			expectedAllowed: false,
+			expectedRule:    "restricted * /accounts/* deny",
+		},
+		{
+			testName:        "testDecideTrailingWildcardAllow",
+			req:             Request{Role: domain.Unrestricted, Method: http.MethodGet, Resource: "/accounts/1/summary"},
+			expectedAllowed: true,
+			expectedRule:    "unrestricted * /accounts/* allow",
+		},
+		{
+			testName:        "testDecideGRPC",
+			req:             Request{Role: domain.Unrestricted, Method: GRPCMethod, Resource: "/accountd.UserServer/GetUser"},
+			expectedAllowed: true,
+			expectedRule:    "unrestricted GRPC /accountd.UserServer/* allow",
+		},
+		{
+			testName:        "testDecideDefaultDeny",
+			req:             Request{Role: domain.Restricted, Method: http.MethodPut, Resource: "/users/1"},
+			expectedAllowed: false,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			e := newEngine(t)
+			testLogger, hook := test.NewNullLogger()
+			if err := e.SetDecisionLog(logging.NewLogrusLogger(log.NewEntry(testLogger))); err != nil {
+				t.Fatalf("error %s was not expected enabling the decision log", err)
+			}
+
+			d := e.Decide(tc.req)
+			if d.Allowed != tc.expectedAllowed {
+				t.Errorf("expected Allowed %t, got %t", tc.expectedAllowed, d.Allowed)
+			}
+			rule := ""
+			if d.Rule != nil {
+				rule = d.Rule.String()
+			}
+			if rule != tc.expectedRule {
+				t.Errorf("expected rule %q, got %q", tc.expectedRule, rule)
+			}
+			if len(hook.Entries) != 1 {
+				t.Fatalf("expected 1 decision log entry, got %d", len(hook.Entries))
+			}
+			if hook.LastEntry().Data[logging.Decision] != decisionName(tc.expectedAllowed) {
+				t.Errorf("expected logged decision %s, got %v", decisionName(tc.expectedAllowed), hook.LastEntry().Data[logging.Decision])
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	tcs := []struct {
+		testName           string
+		caller             *auth.Caller
+		method             string
+		url                string
+		scopesOnly         bool
+		expectedHTTPStatus int
+		expectAudit        bool
+	}{
+		{
+			testName:           "testMiddlewareNoCaller",
+			method:             http.MethodDelete,
+			url:                "/users/1",
+			expectedHTTPStatus: http.StatusOK,
+		},
+		{
+			testName:           "testMiddlewareAllowed",
+			caller:             &auth.Caller{UserID: 2, AccountID: 1, Role: domain.Restricted},
+			method:             http.MethodGet,
+			url:                "/users/2",
+			expectedHTTPStatus: http.StatusOK,
+		},
+		{
+			testName:           "testMiddlewareDenied",
+			caller:             &auth.Caller{UserID: 2, AccountID: 1, Role: domain.Restricted},
+			method:             http.MethodDelete,
+			url:                "/users/2",
+			expectedHTTPStatus: http.StatusForbidden,
+			expectAudit:        true,
+		},
+		{
+			testName:           "testMiddlewareReadScopeAllowed",
+			caller:             &auth.Caller{AccountID: 1, Role: domain.Unrestricted, APIKey: "billing", Scopes: []auth.Scope{auth.ScopeUsersRead}},
+			method:             http.MethodGet,
+			url:                "/accounts/1/summary",
+			expectedHTTPStatus: http.StatusOK,
+		},
+		{
+			testName:           "testMiddlewareWriteScopeMissing",
+			caller:             &auth.Caller{AccountID: 1, Role: domain.Primary, APIKey: "billing", Scopes: []auth.Scope{auth.ScopeUsersRead}},
+			method:             http.MethodPut,
+			url:                "/users/2",
+			expectedHTTPStatus: http.StatusForbidden,
+			expectAudit:        true,
+		},
+		{
+			testName:           "testMiddlewareScopesOnly",
+			caller:             &auth.Caller{AccountID: 1, Role: domain.Restricted, APIKey: "crm", Scopes: []auth.Scope{auth.ScopeUsersWrite}},
+			method:             http.MethodDelete,
+			url:                "/users/2",
+			scopesOnly:         true,
+			expectedHTTPStatus: http.StatusOK,
+		},
+		{
+			testName:           "testMiddlewareScopesOnlyMissing",
+			caller:             &auth.Caller{AccountID: 1, Role: domain.Restricted, APIKey: "crm", Scopes: []auth.Scope{auth.ScopeUsersWrite}},
+			method:             http.MethodGet,
+			url:                "/users/2",
+			scopesOnly:         true,
+			expectedHTTPStatus: http.StatusForbidden,
+			expectAudit:        true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			testLogger, hook := test.NewNullLogger()
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			e := newEngine(t)
+			if tc.scopesOnly {
+				e = nil
+			}
+			h := Middleware(e, logging.NewLogrusLogger(log.NewEntry(testLogger)))(next)
+
+			req := httptest.NewRequest(tc.method, tc.url, nil)
+			if tc.caller != nil {
+				req = req.WithContext(auth.NewContext(req.Context(), *tc.caller))
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedHTTPStatus {
+				t.Errorf("expected StatusCode = %d, got %d", tc.expectedHTTPStatus, rr.Code)
+			}
+			audited := len(hook.Entries) == 1 && hook.LastEntry().Data[logging.Audit] == true
+			if audited != tc.expectAudit {
+				t.Errorf("expected audit %t, got entries %+v", tc.expectAudit, hook.Entries)
+			}
+		})
+	}
+}
+
+func TestRequiredScope(t *testing.T) {
+	tcs := []struct {
+		testName string
+		method   string
+		resource string
+		expected auth.Scope
+	}{
+		{testName: "testGET", method: http.MethodGet, resource: "/users/1", expected: auth.ScopeUsersRead},
+		{testName: "testHEAD", method: http.MethodHead, resource: "/users", expected: auth.ScopeUsersRead},
+		{testName: "testPOST", method: http.MethodPost, resource: "/users", expected: auth.ScopeUsersWrite},
+		{testName: "testDELETE", method: http.MethodDelete, resource: "/users/1", expected: auth.ScopeUsersWrite},
+		{testName: "testAdmin", method: http.MethodGet, resource: "/admin/requests", expected: auth.ScopeAdmin},
+		{testName: "testAdministrators", method: http.MethodGet, resource: "/administrators", expected: auth.ScopeUsersRead},
+		{testName: "testGRPCRead", method: GRPCMethod, resource: "/accountd.UserServer/GetUsers", expected: auth.ScopeUsersRead},
+		{testName: "testGRPCHealthCheck", method: GRPCMethod, resource: "/grpc.health.v1.Health/Check", expected: auth.ScopeUsersRead},
+		{testName: "testGRPCWrite", method: GRPCMethod, resource: "/accountd.UserServer/CreateUser", expected: auth.ScopeUsersWrite},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			if scope := RequiredScope(tc.method, tc.resource); scope != tc.expected {
+				t.Errorf("expected scope %s, got %s", tc.expected, scope)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/youngkin/mockvideo/internal/auth"
+)
+
+// readRPCs are the gRPC methods, by name, that only read
+var readRPCs = map[string]bool{
+	"GetUser":  true,
+	"GetUsers": true,
+	"Health":   true,
+	// The standard gRPC health service's, grpc.health.v1.Health
+	"Check": true,
+}
+
+// RequiredScope returns the auth.Scope a caller needs for a request with 'method' and 'resource', see
+// Request. The admin endpoints require auth.ScopeAdmin. Otherwise HTTP GET, HEAD, and OPTIONS
+// requests, and gRPC methods that only read, require auth.ScopeUsersRead and all other requests
+// require auth.ScopeUsersWrite.
+func RequiredScope(method, resource string) auth.Scope {
+	if resource == "/admin" || strings.HasPrefix(resource, "/admin/") {
+		return auth.ScopeAdmin
+	}
+	if method == GRPCMethod {
+		if readRPCs[path.Base(resource)] {
+			return auth.ScopeUsersRead
+		}
+		return auth.ScopeUsersWrite
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return auth.ScopeUsersRead
+	}
+	return auth.ScopeUsersWrite
+}
@@ -0,0 +1,21 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package secrets encrypts and decrypts secrets, e.g., the DB password, so they can be committed safely
+alongside the configuration of the demo environments. An encrypted secret is an age file
+(https://age-encryption.org/v1), ASCII armored so it can be reviewed like any other text file:
+
+		-----BEGIN AGE ENCRYPTED FILE-----
+		YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSBvT0VmM2Zr...
+		-----END AGE ENCRYPTED FILE-----
+
+A secret is encrypted with a random file key that's wrapped for each of its recipients, i.e., age X25519
+public keys ('age1...'). It's decrypted by any of the recipients' identities, i.e., their secret keys
+('AGE-SECRET-KEY-1...'). The identities are provided by the ACCOUNTD_SECRETS_KEY environment variable
+or by a key file, e.g., one mounted by a KMS integration, see LoadIdentities. Identities are generated
+and secrets encrypted using 'accountctl secrets', or the age and age-keygen tools, the files are
+interchangeable.
+*/
+package secrets
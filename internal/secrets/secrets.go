@@ -0,0 +1,139 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+const (
+	// KeyEnv is the environment variable that provides the age identities, i.e., the secret keys, that
+	// decrypt secrets
+	KeyEnv = "ACCOUNTD_SECRETS_KEY"
+
+	// binaryHeader is the first line of a binary, i.e., not armored, age file
+	binaryHeader = "age-encryption.org/v1"
+)
+
+// ParseIdentities parses 'content', age identities in the format written by GenerateIdentity and
+// 'age-keygen', one per line. Empty lines and lines starting with '#' are ignored.
+func ParseIdentities(content string) ([]age.Identity, error) {
+	identities, err := age.ParseIdentities(strings.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("the secrets key isn't a valid age identity: %w", err)
+	}
+	return identities, nil
+}
+
+// LoadIdentities returns the age identities that decrypt secrets. They're read from 'keyFile', e.g., a
+// file a KMS integration mounts into the container, if it's set. Otherwise they're parsed from 'envKey',
+// the value of KeyEnv. No identities are returned if neither is set, in which case only plaintext
+// secrets can be used.
+func LoadIdentities(envKey, keyFile string) ([]age.Identity, error) {
+	if keyFile != "" {
+		content, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("secrets key file %s could not be read: %w", keyFile, err)
+		}
+		return ParseIdentities(string(content))
+	}
+	if envKey == "" {
+		return nil, nil
+	}
+	return ParseIdentities(envKey)
+}
+
+// GenerateIdentity returns a new age X25519 identity in the format written by 'age-keygen', a comment
+// containing its recipient, i.e., its public key, followed by the identity
+func GenerateIdentity() (string, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("# public key: %s\n%s\n", identity.Recipient(), identity), nil
+}
+
+// ParseRecipients parses 'recipients', age X25519 recipients, e.g., 'age1...'
+func ParseRecipients(recipients []string) ([]age.Recipient, error) {
+	parsed, err := age.ParseRecipients(strings.NewReader(strings.Join(recipients, "\n")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid age recipient: %w", err)
+	}
+	return parsed, nil
+}
+
+// Recipients returns the recipients of 'identities', i.e., the public keys that secrets they decrypt
+// are encrypted to. Only X25519 identities, e.g., those returned by GenerateIdentity, are supported.
+func Recipients(identities []age.Identity) ([]age.Recipient, error) {
+	recipients := make([]age.Recipient, 0, len(identities))
+	for _, identity := range identities {
+		x25519, ok := identity.(*age.X25519Identity)
+		if !ok {
+			return nil, errors.New("only age X25519 identities are supported")
+		}
+		recipients = append(recipients, x25519.Recipient())
+	}
+	return recipients, nil
+}
+
+// Encrypt encrypts 'plaintext' to 'recipients'. The age file returned is ASCII armored so that it can be
+// committed and reviewed like any other text file. It can be decrypted by any of the recipients'
+// identities, with Decrypt or 'age --decrypt'.
+func Encrypt(plaintext []byte, recipients ...age.Recipient) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("at least one age recipient is required")
+	}
+	var b bytes.Buffer
+	armored := armor.NewWriter(&b)
+	w, err := age.Encrypt(armored, recipients...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	if err = armored.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Decrypt decrypts 'encrypted', an age file, armored or binary, of secret 'name', with 'identities'. Whitespace
+// surrounding an armored file, e.g., a trailing newline, is ignored.
+func Decrypt(name string, encrypted []byte, identities ...age.Identity) ([]byte, error) {
+	if !IsEncrypted(encrypted) {
+		return nil, fmt.Errorf("secret %s isn't encrypted", name)
+	}
+	var src io.Reader = bytes.NewReader(encrypted)
+	if !bytes.HasPrefix(encrypted, []byte(binaryHeader)) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(encrypted)))
+	}
+	r, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s could not be decrypted, it isn't encrypted to the secrets key or has been altered: %w", name, err)
+	}
+	plaintext, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s could not be decrypted, it has been altered: %w", name, err)
+	}
+	return plaintext, nil
+}
+
+// IsEncrypted returns true if 'content' is an age file, armored or binary
+func IsEncrypted(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(binaryHeader)) ||
+		bytes.HasPrefix(bytes.TrimSpace(content), []byte(armor.Header))
+}
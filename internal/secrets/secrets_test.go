@@ -0,0 +1,147 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package secrets
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+// testKey and otherKey are age identities used by the tests
+const (
+	testKey  = "AGE-SECRET-KEY-1VD35AVAPCDS64SU5QHF37JEUFCGT7T2WVT45KX88C7NHMDRJSP3S7G0NYE"
+	otherKey = "AGE-SECRET-KEY-1SU7EURA2DZ6RGTVX7HLZQ09DD2AMS839P892K4M9UQCS0TADKZYSAH0386"
+)
+
+// identities parses 'key', failing the test if it's invalid
+func identities(t *testing.T, key string) []age.Identity {
+	ids, err := ParseIdentities(key)
+	if err != nil {
+		t.Fatalf("error %s was not expected parsing the key", err)
+	}
+	return ids
+}
+
+func TestEncrypt(t *testing.T) {
+	key := identities(t, testKey)
+	recipients, err := Recipients(key)
+	if err != nil {
+		t.Fatalf("error %s was not expected getting the key's recipients", err)
+	}
+	encrypted, err := Encrypt([]byte("somepassword"), recipients...)
+	if err != nil {
+		t.Fatalf("error %s was not expected encrypting the secret", err)
+	}
+	if !IsEncrypted(encrypted) {
+		t.Fatalf("expected an encrypted secret, got %q", encrypted)
+	}
+
+	// binary is the secret encrypted without armor, as 'age --encrypt' does by default
+	var binary bytes.Buffer
+	w, err := age.Encrypt(&binary, recipients...)
+	if err != nil {
+		t.Fatalf("error %s was not expected encrypting the secret", err)
+	}
+	w.Write([]byte("somepassword"))
+	w.Close()
+
+	altered := append([]byte{}, encrypted...)
+	altered[len(altered)/2] ^= 1
+
+	tcs := []struct {
+		testName   string
+		encrypted  []byte
+		key        []age.Identity
+		expectFail bool
+	}{
+		{testName: "testRoundTrip", encrypted: encrypted, key: key},
+		{testName: "testSurroundingWhitespace", encrypted: append(append([]byte("\n"), encrypted...), '\n'), key: key},
+		{testName: "testBinary", encrypted: binary.Bytes(), key: key},
+		{testName: "testMultipleKeys", encrypted: encrypted, key: identities(t, otherKey+"\n"+testKey)},
+		{testName: "testWrongKey", encrypted: encrypted, key: identities(t, otherKey), expectFail: true},
+		{testName: "testNoKey", encrypted: encrypted, expectFail: true},
+		{testName: "testAltered", encrypted: altered, key: key, expectFail: true},
+		{testName: "testNotEncrypted", encrypted: []byte("somepassword"), key: key, expectFail: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			plaintext, err := Decrypt("dbpassword", tc.encrypted, tc.key...)
+			if (err != nil) != tc.expectFail {
+				t.Fatalf("expected failure %t, got %v", tc.expectFail, err)
+			}
+			if !tc.expectFail && string(plaintext) != "somepassword" {
+				t.Errorf("expected 'somepassword', got %q", plaintext)
+			}
+		})
+	}
+}
+
+func TestLoadIdentities(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secretskey")
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a temp directory", err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "key")
+	if err = ioutil.WriteFile(keyFile, []byte("# created by a test\n"+testKey+"\n"), 0600); err != nil {
+		t.Fatalf("error %s was not expected writing the key file", err)
+	}
+
+	tcs := []struct {
+		testName   string
+		envKey     string
+		keyFile    string
+		expected   int
+		expectFail bool
+	}{
+		{testName: "testNoKey"},
+		{testName: "testEnvKey", envKey: testKey, expected: 1},
+		{testName: "testMultipleKeys", envKey: testKey + "\n" + otherKey, expected: 2},
+		{testName: "testKeyFile", keyFile: keyFile, expected: 1},
+		{testName: "testKeyFileWins", envKey: "notakey", keyFile: keyFile, expected: 1},
+		{testName: "testMissingKeyFile", keyFile: filepath.Join(dir, "missing"), expectFail: true},
+		{testName: "testInvalidKey", envKey: "notakey", expectFail: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			ids, err := LoadIdentities(tc.envKey, tc.keyFile)
+			if (err != nil) != tc.expectFail {
+				t.Fatalf("expected failure %t, got %v", tc.expectFail, err)
+			}
+			if !tc.expectFail && len(ids) != tc.expected {
+				t.Errorf("expected %d identities, got %d", tc.expected, len(ids))
+			}
+		})
+	}
+}
+
+func TestGenerateIdentity(t *testing.T) {
+	identity, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("error %s was not expected generating an identity", err)
+	}
+	ids, err := ParseIdentities(identity)
+	if err != nil {
+		t.Fatalf("expected a valid identity, got %s", err)
+	}
+	recipients, err := Recipients(ids)
+	if err != nil {
+		t.Fatalf("error %s was not expected getting the identity's recipients", err)
+	}
+	if !strings.Contains(identity, "# public key: "+recipients[0].(*age.X25519Recipient).String()) {
+		t.Errorf("expected the identity to include its public key, got %q", identity)
+	}
+	if _, err = ParseRecipients([]string{recipients[0].(*age.X25519Recipient).String()}); err != nil {
+		t.Errorf("expected a valid recipient, got %s", err)
+	}
+}
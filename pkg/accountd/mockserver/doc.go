@@ -0,0 +1,33 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package mockserver is an in-memory implementation of the accountd HTTP and gRPC APIs. It's intended
+for the tests of services that depend on accountd. These tests can run against the mock server
+instead of a real accountd backed by a database.
+
+Here's an example test:
+
+		func TestBilling(t *testing.T) {
+			ms := mockserver.New()
+			defer ms.Close()
+
+			// Fixtures
+			id, err := ms.AddUser(domain.User{AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary, Password: "pw"})
+			...
+			url := ms.StartHTTP()    // e.g., 'http://127.0.0.1:53112'
+			addr, err := ms.StartGRPC() // e.g., '127.0.0.1:53113'
+			...
+
+			// Fault injection, the next 2 GetUser requests fail with a 503 (HTTP) or Unavailable (gRPC)
+			ms.InjectFault(mockserver.GetUser, mockserver.Fault{HTTPStatus: http.StatusServiceUnavailable, GRPCCode: codes.Unavailable, Count: 2})
+			...
+		}
+
+The mock server supports the single user operations, i.e., GET /users, GET, PUT, and DELETE
+/users/{id}, and POST /users, and all of the gRPC UserServer methods except Login. The HTTP bulk
+request operations aren't supported, and neither are logins. Users are created in the "active"
+state, there is no activation workflow. The HTTP and gRPC APIs share the same users.
+*/
+package mockserver
@@ -0,0 +1,235 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mockserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	pb "github.com/youngkin/mockvideo/cmd/accountd/grpc/users"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// StartGRPC starts the gRPC API, and the server reflection service, on a local port and returns
+// its address, e.g., '127.0.0.1:53113'
+func (s *Server) StartGRPC() (string, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+
+	grpcSrv := grpc.NewServer()
+	pb.RegisterUserServerServer(grpcSrv, &grpcServer{s: s})
+	reflection.Register(grpcSrv)
+	go grpcSrv.Serve(lis)
+
+	s.mu.Lock()
+	s.closeFns = append(s.closeFns, grpcSrv.Stop)
+	s.mu.Unlock()
+
+	return lis.Addr().String(), nil
+}
+
+// grpcServer implements the accountd gRPC API
+type grpcServer struct {
+	s *Server
+}
+
+// GetUser returns the User identified by 'rqst.Id'
+func (g *grpcServer) GetUser(ctx context.Context, rqst *pb.UserID) (*pb.User, error) {
+	if err := g.fault(GetUser); err != nil {
+		return nil, err
+	}
+
+	u, err := g.s.repo().GetUser(int(rqst.Id))
+	if err != nil {
+		return nil, errToStatus(err)
+	}
+	if u == nil {
+		return nil, status.Errorf(codes.NotFound, "user %d not found", rqst.Id)
+	}
+	u.HREF = fmt.Sprintf("/users/%d", u.ID)
+	return pb.DomainUserToProtobuf(u), nil
+}
+
+// GetUsers returns all users
+func (g *grpcServer) GetUsers(ctx context.Context, _ *empty.Empty) (*pb.Users, error) {
+	if err := g.fault(GetUsers); err != nil {
+		return nil, err
+	}
+
+	users, err := g.s.repo().GetUsers()
+	if err != nil {
+		return nil, errToStatus(err)
+	}
+	for _, u := range users.Users {
+		u.HREF = fmt.Sprintf("/users/%d", u.ID)
+	}
+	return pb.DomainUsersToProtobuf(users), nil
+}
+
+// CreateUser creates a new User
+func (g *grpcServer) CreateUser(ctx context.Context, u *pb.User) (*pb.UserID, error) {
+	if err := g.fault(CreateUser); err != nil {
+		return nil, err
+	}
+
+	du, err := pb.ProtobufToUser(u)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid protobuf.User value provided: Error: %s", err)
+	}
+	id, mvErr := g.s.addUser(*du)
+	if mvErr != nil {
+		return nil, errToStatus(mvErr)
+	}
+	return &pb.UserID{Id: int64(id)}, nil
+}
+
+// CreateUsers creates each of 'users'
+func (g *grpcServer) CreateUsers(ctx context.Context, users *pb.Users) (*pb.BulkResponse, error) {
+	if err := g.fault(CreateUser); err != nil {
+		return nil, err
+	}
+
+	return g.bulk(users, pb.StatusEnum_StatusCreated, func(u domain.User) (int, *mverr.MVError) {
+		return g.s.addUser(u)
+	})
+}
+
+// UpdateUser updates an existing User
+func (g *grpcServer) UpdateUser(ctx context.Context, u *pb.User) (*empty.Empty, error) {
+	if err := g.fault(UpdateUser); err != nil {
+		return nil, err
+	}
+
+	du, err := pb.ProtobufToUser(u)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid protobuf.User value provided: Error: %s", err)
+	}
+	if mvErr := g.s.repo().UpdateUser(*du); mvErr != nil {
+		return nil, errToStatus(mvErr)
+	}
+	return &empty.Empty{}, nil
+}
+
+// UpdateUsers updates each of 'users'
+func (g *grpcServer) UpdateUsers(ctx context.Context, users *pb.Users) (*pb.BulkResponse, error) {
+	if err := g.fault(UpdateUser); err != nil {
+		return nil, err
+	}
+
+	return g.bulk(users, pb.StatusEnum_StatusOK, func(u domain.User) (int, *mverr.MVError) {
+		return u.ID, g.s.repo().UpdateUser(u)
+	})
+}
+
+// DeleteUser deletes the User identified by 'id'
+func (g *grpcServer) DeleteUser(ctx context.Context, id *pb.UserID) (*empty.Empty, error) {
+	if err := g.fault(DeleteUser); err != nil {
+		return nil, err
+	}
+
+	if _, mvErr := g.s.repo().DeleteUser(int(id.GetId())); mvErr != nil {
+		return nil, errToStatus(mvErr)
+	}
+	return &empty.Empty{}, nil
+}
+
+// DeleteUsers deletes each of the users identified by 'ids'
+func (g *grpcServer) DeleteUsers(ctx context.Context, ids *pb.UserIDs) (*pb.BulkResponse, error) {
+	if err := g.fault(DeleteUser); err != nil {
+		return nil, err
+	}
+
+	bulkResponse := &pb.BulkResponse{OverallStatus: pb.StatusEnum_StatusOK}
+	for _, id := range ids.GetUserID() {
+		response := &pb.Response{Status: pb.StatusEnum_StatusOK, UserID: &pb.UserID{Id: id.GetId()}}
+		if _, mvErr := g.s.repo().DeleteUser(int(id.GetId())); mvErr != nil {
+			response.Status = pb.StatusEnum_StatusServerError
+			response.ErrMsg = mvErr.ErrMsg
+			response.ErrReason = int64(mvErr.ErrCode)
+			bulkResponse.OverallStatus = pb.StatusEnum_StatusConflict
+		}
+		bulkResponse.Response = append(bulkResponse.Response, response)
+	}
+
+	if bulkResponse.OverallStatus != pb.StatusEnum_StatusOK {
+		return bulkResponse, fmt.Errorf("part or all of a bulk request failed")
+	}
+	return bulkResponse, nil
+}
+
+// Health is used to determine the status or health of the service
+func (g *grpcServer) Health(ctx context.Context, _ *empty.Empty) (*pb.HealthMsg, error) {
+	return &pb.HealthMsg{Status: "gRPC mock User Service is healthy"}, nil
+}
+
+// Login isn't supported, the mock server doesn't issue session tokens
+func (g *grpcServer) Login(ctx context.Context, _ *pb.LoginRequest) (*pb.Session, error) {
+	return nil, status.Error(codes.Unimplemented, "login isn't supported by the mock server")
+}
+
+// bulk applies 'op' to each of 'users'. Successful operations have a status of 'okStatus'.
+func (g *grpcServer) bulk(users *pb.Users, okStatus pb.StatusEnum, op func(domain.User) (int, *mverr.MVError)) (*pb.BulkResponse, error) {
+	bulkResponse := &pb.BulkResponse{OverallStatus: okStatus}
+	for _, u := range users.Users {
+		response := &pb.Response{Status: okStatus, UserID: &pb.UserID{Id: u.ID}}
+		du, err := pb.ProtobufToUser(u)
+		if err != nil {
+			response.Status = pb.StatusEnum_StatusBadRequest
+			response.ErrMsg = err.Error()
+			response.ErrReason = int64(mverr.UserValidationErrorCode)
+		} else if id, mvErr := op(*du); mvErr != nil {
+			response.Status = pb.StatusEnum_StatusBadRequest
+			if errToHTTPStatus(mvErr) == http.StatusInternalServerError {
+				response.Status = pb.StatusEnum_StatusServerError
+			}
+			response.ErrMsg = mvErr.ErrMsg
+			response.ErrReason = int64(mvErr.ErrCode)
+		} else {
+			response.UserID.Id = int64(id)
+		}
+		if response.Status != okStatus {
+			bulkResponse.OverallStatus = pb.StatusEnum_StatusConflict
+		}
+		bulkResponse.Response = append(bulkResponse.Response, response)
+	}
+
+	if bulkResponse.OverallStatus != okStatus {
+		return bulkResponse, fmt.Errorf("part or all of a bulk request failed")
+	}
+	return bulkResponse, nil
+}
+
+// fault returns the gRPC status error for an injected fault for 'op', or nil if there isn't one
+func (g *grpcServer) fault(op Op) error {
+	f, found := g.s.fault(op)
+	if !found {
+		return nil
+	}
+
+	code := f.GRPCCode
+	if code == codes.OK {
+		code = codes.Internal
+	}
+	return status.Errorf(code, "injected fault for %s", op)
+}
+
+// errToStatus maps the repository errors to gRPC status errors
+func errToStatus(err *mverr.MVError) error {
+	code := codes.Internal
+	if errToHTTPStatus(err) != http.StatusInternalServerError {
+		code = codes.InvalidArgument
+	}
+	return status.Error(code, err.ErrMsg)
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+)
+
+// httpHandler implements the accountd HTTP API
+type httpHandler struct {
+	s *Server
+}
+
+// ServeHTTP handles the request
+func (h httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Expecting a URL.Path like '/users' or '/users/{id}'
+	pathNodes := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if pathNodes[0] != "users" || len(pathNodes) > 2 {
+		http.Error(w, mverr.MalformedURLMsg, http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Bulk-Request") == "true" {
+		http.Error(w, "bulk requests aren't supported by the mock server", http.StatusNotImplemented)
+		return
+	}
+
+	id := 0
+	if len(pathNodes) == 2 {
+		var err error
+		id, err = strconv.Atoi(pathNodes[1])
+		if err != nil {
+			http.Error(w, mverr.MalformedURLMsg, http.StatusBadRequest)
+			return
+		}
+	}
+
+	switch {
+	case r.Method == http.MethodGet && id == 0:
+		h.getUsers(w)
+	case r.Method == http.MethodGet:
+		h.getUser(w, id)
+	case r.Method == http.MethodPost && id == 0:
+		h.createUser(w, r)
+	case r.Method == http.MethodPut && id != 0:
+		h.updateUser(w, r, id)
+	case r.Method == http.MethodDelete && id != 0:
+		h.deleteUser(w, id)
+	case r.Method == http.MethodPost, r.Method == http.MethodPut, r.Method == http.MethodDelete:
+		http.Error(w, mverr.MalformedURLMsg, http.StatusBadRequest)
+	default:
+		http.Error(w, "Sorry, only GET, PUT, POST, and DELETE methods are supported.", http.StatusNotImplemented)
+	}
+}
+
+func (h httpHandler) getUsers(w http.ResponseWriter) {
+	if h.writeFault(w, GetUsers) {
+		return
+	}
+
+	users, err := h.s.repo().GetUsers()
+	if err != nil {
+		http.Error(w, err.ErrMsg, errToHTTPStatus(err))
+		return
+	}
+	for _, u := range users.Users {
+		u.HREF = fmt.Sprintf("/users/%d", u.ID)
+	}
+	writeJSON(w, users)
+}
+
+func (h httpHandler) getUser(w http.ResponseWriter, id int) {
+	if h.writeFault(w, GetUser) {
+		return
+	}
+
+	u, err := h.s.repo().GetUser(id)
+	if err != nil {
+		http.Error(w, err.ErrMsg, errToHTTPStatus(err))
+		return
+	}
+	if u == nil {
+		http.Error(w, mverr.DBNoUserErrorMsg, http.StatusNotFound)
+		return
+	}
+	u.HREF = fmt.Sprintf("/users/%d", u.ID)
+	writeJSON(w, u)
+}
+
+func (h httpHandler) createUser(w http.ResponseWriter, r *http.Request) {
+	if h.writeFault(w, CreateUser) {
+		return
+	}
+
+	u, ok := decodeUser(w, r)
+	if !ok {
+		return
+	}
+	if u.ID != 0 {
+		http.Error(w, mverr.InvalidInsertErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	id, err := h.s.addUser(u)
+	if err != nil {
+		http.Error(w, err.ErrMsg, errToHTTPStatus(err))
+		return
+	}
+
+	w.Header().Add("Location", fmt.Sprintf("/users/%d", id))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h httpHandler) updateUser(w http.ResponseWriter, r *http.Request, id int) {
+	if h.writeFault(w, UpdateUser) {
+		return
+	}
+
+	u, ok := decodeUser(w, r)
+	if !ok {
+		return
+	}
+	if u.ID != id {
+		http.Error(w, mverr.MalformedURLMsg, http.StatusBadRequest)
+		return
+	}
+
+	err := h.s.repo().UpdateUser(u)
+	if err != nil {
+		http.Error(w, err.ErrMsg, errToHTTPStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h httpHandler) deleteUser(w http.ResponseWriter, id int) {
+	if h.writeFault(w, DeleteUser) {
+		return
+	}
+
+	_, err := h.s.repo().DeleteUser(id)
+	if err != nil {
+		http.Error(w, err.ErrMsg, errToHTTPStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeFault writes the response for an injected fault for 'op', if any. It returns true if
+// the response was written.
+func (h httpHandler) writeFault(w http.ResponseWriter, op Op) bool {
+	f, found := h.s.fault(op)
+	if !found {
+		return false
+	}
+
+	status := f.HTTPStatus
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	if f.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(f.RetryAfter.Seconds()))))
+	}
+	http.Error(w, fmt.Sprintf("injected fault for %s", op), status)
+	return true
+}
+
+func decodeUser(w http.ResponseWriter, r *http.Request) (domain.User, bool) {
+	u := domain.User{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&u); err != nil {
+		http.Error(w, mverr.JSONDecodingErrorMsg, http.StatusBadRequest)
+		return domain.User{}, false
+	}
+	return u, true
+}
+
+func writeJSON(w http.ResponseWriter, payload interface{}) {
+	marshPayload, err := json.Marshal(payload)
+	if err != nil {
+		http.Error(w, mverr.JSONMarshalingErrorMsg, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(marshPayload)
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mockserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/db/memory"
+	"github.com/youngkin/mockvideo/internal/domain"
+	mverr "github.com/youngkin/mockvideo/internal/errors"
+	"google.golang.org/grpc/codes"
+)
+
+// Op identifies an API operation that faults can be injected into
+type Op string
+
+const (
+	// GetUsers gets all users
+	GetUsers Op = "GetUsers"
+	// GetUser gets a single user
+	GetUser Op = "GetUser"
+	// CreateUser creates one or more users
+	CreateUser Op = "CreateUser"
+	// UpdateUser updates one or more users
+	UpdateUser Op = "UpdateUser"
+	// DeleteUser deletes a user
+	DeleteUser Op = "DeleteUser"
+)
+
+// Fault describes how requests for an Op should fail
+type Fault struct {
+	// HTTPStatus is the HTTP status returned by the HTTP API. It defaults to 500 (Internal Server Error).
+	HTTPStatus int
+	// GRPCCode is the status code returned by the gRPC API. It defaults to 'Internal'.
+	GRPCCode codes.Code
+	// RetryAfter, if non-zero, is returned in the HTTP 'Retry-After' header
+	RetryAfter time.Duration
+	// Delay is how long to wait before responding. If 'HTTPStatus' and 'GRPCCode' are both
+	// unset, and 'Delay' is non-zero, the request is delayed but doesn't fail.
+	Delay time.Duration
+	// Count is the number of requests the fault applies to. It applies to all requests if 0.
+	Count int
+}
+
+// delayOnly returns true if the fault delays requests without failing them
+func (f Fault) delayOnly() bool {
+	return f.HTTPStatus == 0 && f.GRPCCode == codes.OK && f.Delay > 0
+}
+
+// Server is an in-memory accountd server. It's safe for concurrent use.
+type Server struct {
+	users *memory.UserTable
+
+	mu       sync.Mutex
+	faults   map[Op]*Fault
+	httpSrv  *httptest.Server
+	closeFns []func()
+}
+
+// New returns a Server without any users or faults. StartHTTP() and/or StartGRPC() must be called
+// to begin serving requests.
+func New() *Server {
+	return &Server{
+		users:  memory.NewUserTable(),
+		faults: make(map[Op]*Fault),
+	}
+}
+
+// StartHTTP starts the HTTP API on a local port and returns its base URL, e.g., 'http://127.0.0.1:53112'.
+// Calling StartHTTP more than once returns the same URL.
+func (s *Server) StartHTTP() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.httpSrv == nil {
+		s.httpSrv = httptest.NewServer(s.Handler())
+		s.closeFns = append(s.closeFns, s.httpSrv.Close)
+	}
+	return s.httpSrv.URL
+}
+
+// Handler returns the http.Handler implementing the HTTP API. It's useful for tests that
+// want to call the API directly, e.g., via 'httptest.NewRecorder()'.
+func (s *Server) Handler() http.Handler {
+	return httpHandler{s: s}
+}
+
+// Close stops the server
+func (s *Server) Close() {
+	s.mu.Lock()
+	closeFns := s.closeFns
+	s.closeFns = nil
+	s.httpSrv = nil
+	s.mu.Unlock()
+
+	for _, fn := range closeFns {
+		fn()
+	}
+}
+
+// AddUser adds 'u' to the server's users and returns its assigned ID. 'u.ID' is ignored.
+func (s *Server) AddUser(u domain.User) (int, error) {
+	id, err := s.addUser(u)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// User returns the user identified by 'id', or nil if there isn't one. It's useful for
+// verifying the results of create, update, and delete requests.
+func (s *Server) User(id int) *domain.User {
+	u, _ := s.repo().GetUser(id)
+	return u
+}
+
+// addUser creates 'u' as an active user, there is no activation workflow
+func (s *Server) addUser(u domain.User) (int, *mverr.MVError) {
+	u.Status = domain.Active
+	return s.repo().CreateUser(u)
+}
+
+// Reset removes all users and faults
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users = memory.NewUserTable()
+	s.faults = make(map[Op]*Fault)
+}
+
+// InjectFault causes requests for 'op' to fail as described by 'f'. It replaces any fault
+// previously injected for 'op'.
+func (s *Server) InjectFault(op Op, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults[op] = &f
+}
+
+// ClearFaults removes all injected faults
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = make(map[Op]*Fault)
+}
+
+// repo returns the current user repository. Reset() replaces it.
+func (s *Server) repo() *memory.UserTable {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.users
+}
+
+// fault returns the fault to apply to the current request for 'op', if any. Faults with
+// a 'Delay' are applied here. The returned bool is false if the request should proceed.
+func (s *Server) fault(op Op) (Fault, bool) {
+	s.mu.Lock()
+	f, found := s.faults[op]
+	if !found {
+		s.mu.Unlock()
+		return Fault{}, false
+	}
+	applied := *f
+	if f.Count > 0 {
+		f.Count--
+		if f.Count == 0 {
+			delete(s.faults, op)
+		}
+	}
+	s.mu.Unlock()
+
+	if applied.Delay > 0 {
+		time.Sleep(applied.Delay)
+	}
+	if applied.delayOnly() {
+		return Fault{}, false
+	}
+	return applied, true
+}
+
+// errToHTTPStatus maps the repository errors to the HTTP statuses returned by accountd
+func errToHTTPStatus(err *mverr.MVError) int {
+	switch err.ErrCode {
+	case mverr.UserValidationErrorCode, mverr.DBInsertDuplicateUserErrorCode:
+		return http.StatusBadRequest
+	case mverr.DBNoUserErrorCode:
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mockserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/youngkin/mockvideo/internal/domain"
+	"github.com/youngkin/mockvideo/pkg/client"
+)
+
+func TestHTTPAPI(t *testing.T) {
+	ms := New()
+	defer ms.Close()
+
+	id, err := ms.AddUser(domain.User{AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary, Password: "pw"})
+	if err != nil {
+		t.Fatalf("error %s was not expected adding a fixture", err)
+	}
+
+	c, err := client.NewClient(ms.StartHTTP(), 0, time.Millisecond)
+	if err != nil {
+		t.Fatalf("error %s was not expected when getting a Client", err)
+	}
+	ctx := context.Background()
+
+	u, err := c.GetUser(ctx, id)
+	if err != nil {
+		t.Fatalf("error %s was not expected getting user %d", err, id)
+	}
+	if u.Name != "mickey dolenz" || u.HREF != "/users/1" || u.Status != string(domain.Active) {
+		t.Errorf("unexpected user %+v", u)
+	}
+
+	href, err := c.CreateUser(ctx, client.User{AccountID: 1, Name: "davy jones", EMail: "davyj@gmail.com", Role: 1, Password: "pw"})
+	if err != nil {
+		t.Fatalf("error %s was not expected creating a user", err)
+	}
+	if href != "/users/2" {
+		t.Errorf("expected HREF /users/2, got %s", href)
+	}
+
+	_, err = c.CreateUser(ctx, client.User{AccountID: 1, Name: "davy jones", EMail: "davyj@gmail.com", Role: 1, Password: "pw"})
+	var se *client.StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a 400 status creating a duplicate user, got %v", err)
+	}
+
+	err = c.UpdateUser(ctx, client.User{ID: 2, AccountID: 1, Name: "davy jones", EMail: "daydreambeliever@gmail.com", Role: 1, Password: "pw"})
+	if err != nil {
+		t.Fatalf("error %s was not expected updating a user", err)
+	}
+	if ms.User(2).EMail != "daydreambeliever@gmail.com" {
+		t.Errorf("expected user 2 to be updated, got %+v", ms.User(2))
+	}
+
+	users, err := c.GetUsers(ctx)
+	if err != nil {
+		t.Fatalf("error %s was not expected getting users", err)
+	}
+	if len(users.Users) != 2 {
+		t.Errorf("expected 2 users, got %d", len(users.Users))
+	}
+
+	err = c.DeleteUser(ctx, 2)
+	if err != nil {
+		t.Fatalf("error %s was not expected deleting a user", err)
+	}
+	if ms.User(2) != nil {
+		t.Errorf("expected user 2 to be deleted")
+	}
+
+	_, err = c.GetUser(ctx, 2)
+	if !errors.As(err, &se) || se.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a 404 status getting a deleted user, got %v", err)
+	}
+
+	ms.Reset()
+	if ms.User(id) != nil {
+		t.Errorf("expected no users after Reset()")
+	}
+}
+
+func TestFaultInjection(t *testing.T) {
+	tcs := []struct {
+		testName       string
+		fault          Fault
+		maxRetries     int
+		expectedStatus int
+		expectQuotaErr bool
+	}{
+		{
+			testName:       "testFaultDefaultStatus",
+			fault:          Fault{Count: 1},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			testName:       "testFaultRetriedBySDK",
+			fault:          Fault{HTTPStatus: http.StatusTooManyRequests, RetryAfter: time.Second, Count: 2},
+			maxRetries:     2,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			testName:       "testFaultRetriesExhausted",
+			fault:          Fault{HTTPStatus: http.StatusServiceUnavailable, RetryAfter: time.Second},
+			maxRetries:     2,
+			expectQuotaErr: true,
+		},
+		{
+			testName:       "testFaultDelayOnly",
+			fault:          Fault{Delay: 10 * time.Millisecond},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			ms := New()
+			defer ms.Close()
+
+			id, err := ms.AddUser(domain.User{AccountID: 1, Name: "mickey dolenz", EMail: "mickeyd@gmail.com", Role: domain.Primary, Password: "pw"})
+			if err != nil {
+				t.Fatalf("error %s was not expected adding a fixture", err)
+			}
+			ms.InjectFault(GetUser, tc.fault)
+
+			c, err := client.NewClient(ms.StartHTTP(), tc.maxRetries, time.Millisecond)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting a Client", err)
+			}
+
+			start := time.Now()
+			_, err = c.GetUser(context.Background(), id)
+
+			var qe *client.QuotaExceededError
+			var se *client.StatusError
+			switch {
+			case tc.expectQuotaErr:
+				if !errors.As(err, &qe) {
+					t.Errorf("expected a *client.QuotaExceededError, got %v", err)
+				}
+			case tc.expectedStatus == http.StatusOK:
+				if err != nil {
+					t.Errorf("error %s was not expected", err)
+				}
+			default:
+				if !errors.As(err, &se) || se.StatusCode != tc.expectedStatus {
+					t.Errorf("expected status %d, got %v", tc.expectedStatus, err)
+				}
+			}
+
+			if time.Since(start) < tc.fault.Delay {
+				t.Errorf("expected the request to be delayed at least %s", tc.fault.Delay)
+			}
+
+			// Faults with a Count are removed once they've been applied Count times
+			if tc.fault.Count > 0 {
+				if _, err = c.GetUser(context.Background(), id); err != nil {
+					t.Errorf("expected fault to be removed, got %s", err)
+				}
+			}
+		})
+	}
+}
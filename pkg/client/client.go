@@ -0,0 +1,195 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// User is the accountd representation of a user
+type User struct {
+	AccountID int    `json:"accountid"`
+	HREF      string `json:"href,omitempty"`
+	ID        int    `json:"id,omitempty"`
+	Name      string `json:"name"`
+	EMail     string `json:"email"`
+	Role      int    `json:"role"`
+	Status    string `json:"status,omitempty"`
+	Password  string `json:"password,omitempty"`
+}
+
+// Users is a collection of User
+type Users struct {
+	Users []*User `json:"users"`
+}
+
+// StatusError indicates the service responded with an unexpected HTTP status
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status %d: %s", e.StatusCode, e.Body)
+}
+
+// Client accesses the accountd HTTP API. It's safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	transport  *http.Transport
+	pool       *poolCounters
+	maxRetries int
+	maxBackoff time.Duration
+}
+
+// NewClient returns a Client for the accountd service at 'baseURL', e.g., 'http://accountd.kube'.
+// Idempotent requests that are rejected because the service is overloaded are retried up to
+// 'maxRetries' times, waiting no more than 'maxBackoff' between attempts. Connections are kept
+// alive and reused, see SetMaxConnsPerHost.
+func NewClient(baseURL string, maxRetries int, maxBackoff time.Duration) (*Client, error) {
+	if len(baseURL) == 0 {
+		return nil, errors.New("non-empty baseURL required")
+	}
+	if maxRetries < 0 {
+		return nil, errors.New("maxRetries must not be negative")
+	}
+	if maxBackoff <= 0 {
+		return nil, errors.New("maxBackoff must be greater than 0")
+	}
+	transport := newTransport(DefaultMaxConnsPerHost)
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second, Transport: transport},
+		transport:  transport,
+		pool:       &poolCounters{},
+		maxRetries: maxRetries,
+		maxBackoff: maxBackoff,
+	}, nil
+}
+
+// GetUsers returns all users
+func (c *Client) GetUsers(ctx context.Context) (*Users, error) {
+	users := &Users{}
+	_, err := c.do(ctx, http.MethodGet, "/users", nil, http.StatusOK, users)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUser returns the user identified by 'id'
+func (c *Client) GetUser(ctx context.Context, id int) (*User, error) {
+	u := &User{}
+	_, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/users/%d", id), nil, http.StatusOK, u)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// CreateUser creates 'u' and returns its HREF, e.g., '/users/42'. If the service is running in
+// write-behind mode the HREF is the provisional resource path, e.g., '/users/pending/7'.
+func (c *Client) CreateUser(ctx context.Context, u User) (string, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/users", u, 0, nil)
+	if err != nil {
+		return "", err
+	}
+	return resp.Header.Get("Location"), nil
+}
+
+// UpdateUser replaces the user identified by 'u.ID' with 'u'
+func (c *Client) UpdateUser(ctx context.Context, u User) error {
+	_, err := c.do(ctx, http.MethodPut, fmt.Sprintf("/users/%d", u.ID), u, http.StatusOK, nil)
+	return err
+}
+
+// DeleteUser deletes the user identified by 'id'. Deleting a user that doesn't exist isn't an error.
+func (c *Client) DeleteUser(ctx context.Context, id int) error {
+	_, err := c.do(ctx, http.MethodDelete, fmt.Sprintf("/users/%d", id), nil, http.StatusNoContent, nil)
+	return err
+}
+
+// do sends the request, retrying idempotent requests that are rate limited. The response body
+// is decoded into 'result' if it's non-nil. The response status must match 'expectedStatus',
+// any 2xx status is accepted if 'expectedStatus' is 0.
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, expectedStatus int, result interface{}) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		var rqstBody io.Reader
+		if payload != nil {
+			rqstBody = bytes.NewReader(payload)
+		}
+		rqst, err := http.NewRequest(method, c.baseURL+path, rqstBody)
+		if err != nil {
+			return nil, err
+		}
+		rqst = rqst.WithContext(c.withConnTrace(ctx))
+		if payload != nil {
+			rqst.Header.Set("Content-Type", "application/json")
+		}
+
+		atomic.AddInt64(&c.pool.requests, 1)
+		resp, err := c.httpClient.Do(rqst)
+		if err != nil {
+			return nil, err
+		}
+		// The body is read completely so the connection can be reused
+		respBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if isRateLimited(resp.StatusCode) {
+			hint, _ := retryAfter(resp.Header, time.Now())
+			if !isIdempotent(method) || attempt > c.maxRetries {
+				retryIn := hint
+				if retryIn <= 0 {
+					retryIn = defaultRetryAfter
+				}
+				return nil, &QuotaExceededError{
+					RetryAfter: retryIn,
+					Attempts:   attempt,
+					Err:        &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)},
+				}
+			}
+			if err = sleep(ctx, backoff(attempt, hint, c.maxBackoff)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if (expectedStatus != 0 && resp.StatusCode != expectedStatus) ||
+			(expectedStatus == 0 && (resp.StatusCode < 200 || resp.StatusCode > 299)) {
+			return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+		}
+
+		if result != nil {
+			if err = json.Unmarshal(respBody, result); err != nil {
+				return nil, err
+			}
+		}
+		return resp, nil
+	}
+}
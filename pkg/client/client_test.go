@@ -0,0 +1,205 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetries(t *testing.T) {
+	tcs := []struct {
+		testName         string
+		method           string
+		rejections       int32
+		rejectStatus     int
+		maxRetries       int
+		expectedAttempts int32
+		expectQuotaErr   bool
+	}{
+		{
+			testName:         "testGetRetriedUntilSuccess",
+			method:           http.MethodGet,
+			rejections:       2,
+			rejectStatus:     http.StatusTooManyRequests,
+			maxRetries:       3,
+			expectedAttempts: 3,
+		},
+		{
+			testName:         "testDeleteRetriedOnServiceUnavailable",
+			method:           http.MethodDelete,
+			rejections:       1,
+			rejectStatus:     http.StatusServiceUnavailable,
+			maxRetries:       3,
+			expectedAttempts: 2,
+		},
+		{
+			testName:         "testGetRetriesExhausted",
+			method:           http.MethodGet,
+			rejections:       5,
+			rejectStatus:     http.StatusTooManyRequests,
+			maxRetries:       2,
+			expectedAttempts: 3,
+			expectQuotaErr:   true,
+		},
+		{
+			testName:         "testPostNotRetried",
+			method:           http.MethodPost,
+			rejections:       1,
+			rejectStatus:     http.StatusTooManyRequests,
+			maxRetries:       3,
+			expectedAttempts: 1,
+			expectQuotaErr:   true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			var attempts int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&attempts, 1) <= tc.rejections {
+					w.Header().Set("Retry-After", "1")
+					w.WriteHeader(tc.rejectStatus)
+					return
+				}
+				switch r.Method {
+				case http.MethodPost:
+					w.Header().Set("Location", "/users/42")
+					w.WriteHeader(http.StatusCreated)
+				case http.MethodGet:
+					w.Write([]byte(`{"id":1,"accountid":1,"name":"mickey dolenz"}`))
+				case http.MethodDelete:
+					w.WriteHeader(http.StatusNoContent)
+				}
+			}))
+			defer srv.Close()
+
+			c, err := NewClient(srv.URL, tc.maxRetries, time.Millisecond)
+			if err != nil {
+				t.Fatalf("error %s was not expected when getting a Client", err)
+			}
+
+			switch tc.method {
+			case http.MethodGet:
+				_, err = c.GetUser(context.Background(), 1)
+			case http.MethodPost:
+				_, err = c.CreateUser(context.Background(), User{AccountID: 1, Name: "mickey dolenz"})
+			case http.MethodDelete:
+				err = c.DeleteUser(context.Background(), 1)
+			}
+
+			if attempts != tc.expectedAttempts {
+				t.Errorf("expected %d attempts, got %d", tc.expectedAttempts, attempts)
+			}
+
+			var qe *QuotaExceededError
+			if tc.expectQuotaErr {
+				if !errors.As(err, &qe) {
+					t.Fatalf("expected a *QuotaExceededError, got %v", err)
+				}
+				if qe.RetryAfter != time.Second {
+					t.Errorf("expected RetryAfter %s, got %s", time.Second, qe.RetryAfter)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("error %s was not expected", err)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tcs := []struct {
+		testName      string
+		headers       map[string]string
+		expectedWait  time.Duration
+		expectedFound bool
+	}{
+		{
+			testName:      "testRetryAfterSeconds",
+			headers:       map[string]string{"Retry-After": "5"},
+			expectedWait:  5 * time.Second,
+			expectedFound: true,
+		},
+		{
+			testName:      "testRetryAfterDate",
+			headers:       map[string]string{"Retry-After": now.Add(30 * time.Second).Format(http.TimeFormat)},
+			expectedWait:  30 * time.Second,
+			expectedFound: true,
+		},
+		{
+			testName:      "testRetryAfterDateInPast",
+			headers:       map[string]string{"Retry-After": now.Add(-30 * time.Second).Format(http.TimeFormat)},
+			expectedWait:  0,
+			expectedFound: true,
+		},
+		{
+			testName:      "testRateLimitReset",
+			headers:       map[string]string{"RateLimit-Reset": "7"},
+			expectedWait:  7 * time.Second,
+			expectedFound: true,
+		},
+		{
+			testName:      "testXRateLimitReset",
+			headers:       map[string]string{"X-RateLimit-Reset": "9"},
+			expectedWait:  9 * time.Second,
+			expectedFound: true,
+		},
+		{
+			testName:      "testRetryAfterPreferred",
+			headers:       map[string]string{"Retry-After": "2", "RateLimit-Reset": "7"},
+			expectedWait:  2 * time.Second,
+			expectedFound: true,
+		},
+		{
+			testName:      "testRetryAfterInvalid",
+			headers:       map[string]string{"Retry-After": "soon"},
+			expectedFound: false,
+		},
+		{
+			testName:      "testNoHeaders",
+			headers:       map[string]string{},
+			expectedFound: false,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tc.headers {
+				h.Set(k, v)
+			}
+
+			wait, found := retryAfter(h, now)
+			if found != tc.expectedFound {
+				t.Errorf("expected found %t, got %t", tc.expectedFound, found)
+			}
+			if wait != tc.expectedWait {
+				t.Errorf("expected wait %s, got %s", tc.expectedWait, wait)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 1; attempt <= 5; attempt++ {
+		wait := backoff(attempt, 2*time.Second, time.Minute)
+		if wait < 2*time.Second || wait > 2*time.Second+time.Duration(maxJitter*float64(2*time.Second)) {
+			t.Errorf("attempt %d: wait %s outside of expected range", attempt, wait)
+		}
+	}
+
+	if wait := backoff(10, 0, 5*time.Second); wait != 5*time.Second {
+		t.Errorf("expected wait to be capped at %s, got %s", 5*time.Second, wait)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+/*
+Package client is an SDK for the accountd service. It supports both the HTTP and gRPC APIs.
+
+The HTTP API is accessed via a Client:
+
+		c, err := client.NewClient("http://accountd.kube", 3, 30*time.Second)
+		...
+		u, err := c.GetUser(ctx, 1)
+
+gRPC clients add the RetryInterceptor to their connection:
+
+		cc, err := grpc.Dial("accountd:5000", grpc.WithInsecure(),
+			grpc.WithUnaryInterceptor(client.RetryInterceptor(3, 30*time.Second)))
+
+or use DialGRPC, which also adds the RetryInterceptor:
+
+		conn, err := client.DialGRPC("accountd:5000", 3, 30*time.Second, grpc.WithInsecure())
+		...
+		u, err := users.NewUserServerClient(conn.ClientConn).GetUser(ctx, &users.UserID{Id: 1})
+
+A Client keeps connections alive and reuses them for later requests, by default up to
+DefaultMaxConnsPerHost connections are opened. SetMaxConnsPerHost changes the limit, requests wait
+for a connection once it's reached. A GRPCConn multiplexes all RPCs over a single connection, it
+should be shared rather than dialed per RPC. Both report their connection statistics via Stats, e.g.,
+so that a load test can verify it's measuring the service rather than connection setup:
+
+		s := c.Stats()
+		fmt.Printf("%d requests, %d new connections, %d reused\n", s.Requests, s.NewConns, s.ReusedConns)
+
+The service may reject requests when it's overloaded or a caller has exceeded its quota. It
+indicates when a request can be retried via the 'Retry-After' or 'RateLimit-Reset' HTTP headers,
+or a 'RetryInfo' detail in a gRPC 'ResourceExhausted' status. Idempotent requests (e.g., GET,
+PUT, and DELETE) are automatically retried, up to the configured maximum number of retries,
+after waiting the indicated time plus some random jitter. The jitter prevents many clients from
+retrying at the same instant. Non-idempotent requests (e.g., POST) aren't retried since they
+may have been partially applied.
+
+When a request is rejected and won't be retried, either because it isn't idempotent or the
+retries have been exhausted, a *QuotaExceededError is returned. Its 'RetryAfter' field
+indicates how long the caller should wait before trying again.
+*/
+package client
@@ -0,0 +1,71 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// idempotentMethods are the accountd gRPC methods that can safely be retried
+var idempotentMethods = map[string]bool{
+	"/accountd.UserServer/GetUser":     true,
+	"/accountd.UserServer/GetUsers":    true,
+	"/accountd.UserServer/UpdateUser":  true,
+	"/accountd.UserServer/UpdateUsers": true,
+	"/accountd.UserServer/DeleteUser":  true,
+	"/accountd.UserServer/DeleteUsers": true,
+	"/accountd.UserServer/Health":      true,
+}
+
+// RetryInterceptor returns a gRPC client interceptor that retries idempotent calls rejected with
+// a 'ResourceExhausted' status up to 'maxRetries' times, waiting no more than 'maxBackoff' between
+// attempts. Rejected calls that aren't retried return a *QuotaExceededError.
+func RetryInterceptor(maxRetries int, maxBackoff time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		for attempt := 1; ; attempt++ {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			st, ok := status.FromError(err)
+			if err == nil || !ok || st.Code() != codes.ResourceExhausted {
+				return err
+			}
+
+			hint, _ := retryDelay(st)
+			if !idempotentMethods[method] || attempt > maxRetries {
+				retryIn := hint
+				if retryIn <= 0 {
+					retryIn = defaultRetryAfter
+				}
+				return &QuotaExceededError{RetryAfter: retryIn, Attempts: attempt, Err: err}
+			}
+			if err = sleep(ctx, backoff(attempt, hint, maxBackoff)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// retryDelay returns the delay from the 'RetryInfo' detail of 'st'. The returned bool is
+// false if 'st' doesn't have a valid 'RetryInfo' detail.
+func retryDelay(st *status.Status) (time.Duration, bool) {
+	for _, detail := range st.Details() {
+		ri, ok := detail.(*errdetails.RetryInfo)
+		if !ok || ri.RetryDelay == nil {
+			continue
+		}
+		d, err := ptypes.Duration(ri.RetryDelay)
+		if err != nil || d < 0 {
+			continue
+		}
+		return d, true
+	}
+	return 0, false
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryInterceptor(t *testing.T) {
+	tcs := []struct {
+		testName         string
+		method           string
+		rejections       int
+		maxRetries       int
+		expectedAttempts int
+		expectQuotaErr   bool
+	}{
+		{
+			testName:         "testGetUserRetriedUntilSuccess",
+			method:           "/accountd.UserServer/GetUser",
+			rejections:       2,
+			maxRetries:       3,
+			expectedAttempts: 3,
+		},
+		{
+			testName:         "testGetUserRetriesExhausted",
+			method:           "/accountd.UserServer/GetUser",
+			rejections:       5,
+			maxRetries:       1,
+			expectedAttempts: 2,
+			expectQuotaErr:   true,
+		},
+		{
+			testName:         "testCreateUserNotRetried",
+			method:           "/accountd.UserServer/CreateUser",
+			rejections:       1,
+			maxRetries:       3,
+			expectedAttempts: 1,
+			expectQuotaErr:   true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			st := status.New(codes.ResourceExhausted, "too many requests")
+			st, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(3 * time.Second)})
+			if err != nil {
+				t.Fatalf("error %s was not expected adding status details", err)
+			}
+
+			attempts := 0
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				attempts++
+				if attempts <= tc.rejections {
+					return st.Err()
+				}
+				return nil
+			}
+
+			interceptor := RetryInterceptor(tc.maxRetries, time.Millisecond)
+			err = interceptor(context.Background(), tc.method, nil, nil, nil, invoker)
+
+			if attempts != tc.expectedAttempts {
+				t.Errorf("expected %d attempts, got %d", tc.expectedAttempts, attempts)
+			}
+
+			var qe *QuotaExceededError
+			if tc.expectQuotaErr {
+				if !errors.As(err, &qe) {
+					t.Fatalf("expected a *QuotaExceededError, got %v", err)
+				}
+				if qe.RetryAfter != 3*time.Second {
+					t.Errorf("expected RetryAfter %s, got %s", 3*time.Second, qe.RetryAfter)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("error %s was not expected", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+)
+
+const (
+	// DefaultMaxConnsPerHost is the default maximum number of connections a Client keeps open to
+	// the service, see Client.SetMaxConnsPerHost
+	DefaultMaxConnsPerHost = 100
+	// idleConnTimeout is how long an unused keep-alive connection is kept open
+	idleConnTimeout = 90 * time.Second
+)
+
+// PoolStats are the connection statistics of a Client or GRPCConn. Connections are opened as needed
+// and reused for later requests, a high ratio of NewConns to Requests indicates connection churn,
+// e.g., because the connection limit is lower than the number of concurrent requests.
+type PoolStats struct {
+	// Requests is the number of requests, or RPCs, sent
+	Requests int64 `json:"requests"`
+	// NewConns is the number of connections opened
+	NewConns int64 `json:"newconns"`
+	// ReusedConns is the number of HTTP requests sent on a previously used keep-alive connection,
+	// it's always 0 for a GRPCConn since all RPCs share the same connection
+	ReusedConns int64 `json:"reusedconns"`
+	// OpenConns is the number of connections currently open, it's only reported by a GRPCConn
+	OpenConns int64 `json:"openconns"`
+}
+
+// poolCounters accumulates PoolStats, it's safe for concurrent use
+type poolCounters struct {
+	requests, newConns, reusedConns, openConns int64
+}
+
+func (p *poolCounters) stats() PoolStats {
+	return PoolStats{
+		Requests:    atomic.LoadInt64(&p.requests),
+		NewConns:    atomic.LoadInt64(&p.newConns),
+		ReusedConns: atomic.LoadInt64(&p.reusedConns),
+		OpenConns:   atomic.LoadInt64(&p.openConns),
+	}
+}
+
+// newTransport returns the transport used by a Client. Unlike http.DefaultTransport, which only
+// keeps 2 idle connections per host, it keeps up to 'maxConnsPerHost' idle connections so that
+// concurrent requests reuse connections rather than opening, and closing, a connection each.
+func newTransport(maxConnsPerHost int) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          maxConnsPerHost,
+		MaxIdleConnsPerHost:   maxConnsPerHost,
+		MaxConnsPerHost:       maxConnsPerHost,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// SetMaxConnsPerHost limits the number of connections the Client opens to the service,
+// DefaultMaxConnsPerHost by default. Requests wait for a connection once the limit is reached. 0
+// means no limit, in which case up to DefaultMaxConnsPerHost idle connections are kept open. It
+// must be called before the Client is used.
+func (c *Client) SetMaxConnsPerHost(n int) error {
+	if n < 0 {
+		return errors.New("maxConnsPerHost must not be negative")
+	}
+	c.transport.MaxConnsPerHost = n
+	idle := n
+	if idle == 0 {
+		idle = DefaultMaxConnsPerHost
+	}
+	c.transport.MaxIdleConns = idle
+	c.transport.MaxIdleConnsPerHost = idle
+	return nil
+}
+
+// Stats returns the Client's connection statistics
+func (c *Client) Stats() PoolStats {
+	return c.pool.stats()
+}
+
+// CloseIdleConnections closes the Client's idle keep-alive connections, connections in use
+// aren't affected
+func (c *Client) CloseIdleConnections() {
+	c.transport.CloseIdleConnections()
+}
+
+// withConnTrace returns 'ctx' with an httptrace.ClientTrace that counts whether a request's
+// connection was new or reused
+func (c *Client) withConnTrace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddInt64(&c.pool.reusedConns, 1)
+				return
+			}
+			atomic.AddInt64(&c.pool.newConns, 1)
+		},
+	})
+}
+
+// GRPCConn is a gRPC connection, or channel, to the accountd service. A single GRPCConn should be
+// shared by all of a process's RPCs, it multiplexes concurrent RPCs over one HTTP/2 connection and
+// reconnects as needed. It's safe for concurrent use.
+type GRPCConn struct {
+	*grpc.ClientConn
+	pool *poolCounters
+}
+
+// DialGRPC returns a GRPCConn to the accountd service at 'target', e.g., 'accountd:5000'. The
+// RetryInterceptor is added to the connection with 'maxRetries' and 'maxBackoff'. 'opts' are
+// additional dial options, e.g., grpc.WithInsecure().
+func DialGRPC(target string, maxRetries int, maxBackoff time.Duration, opts ...grpc.DialOption) (*GRPCConn, error) {
+	if len(target) == 0 {
+		return nil, errors.New("non-empty target required")
+	}
+	if maxRetries < 0 {
+		return nil, errors.New("maxRetries must not be negative")
+	}
+	if maxBackoff <= 0 {
+		return nil, errors.New("maxBackoff must be greater than 0")
+	}
+	pool := &poolCounters{}
+	opts = append(opts,
+		grpc.WithUnaryInterceptor(RetryInterceptor(maxRetries, maxBackoff)),
+		grpc.WithStatsHandler(connStatsHandler{pool: pool}))
+	cc, err := grpc.Dial(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCConn{ClientConn: cc, pool: pool}, nil
+}
+
+// Stats returns the GRPCConn's connection statistics
+func (g *GRPCConn) Stats() PoolStats {
+	return g.pool.stats()
+}
+
+// connStatsHandler is a gRPC stats.Handler that counts RPCs and connections
+type connStatsHandler struct {
+	pool *poolCounters
+}
+
+func (h connStatsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h connStatsHandler) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if _, ok := s.(*stats.Begin); ok {
+		atomic.AddInt64(&h.pool.requests, 1)
+	}
+}
+
+func (h connStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h connStatsHandler) HandleConn(_ context.Context, s stats.ConnStats) {
+	switch s.(type) {
+	case *stats.ConnBegin:
+		atomic.AddInt64(&h.pool.newConns, 1)
+		atomic.AddInt64(&h.pool.openConns, 1)
+	case *stats.ConnEnd:
+		atomic.AddInt64(&h.pool.openConns, -1)
+	}
+}
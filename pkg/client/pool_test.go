@@ -0,0 +1,158 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+)
+
+func TestConnectionReuse(t *testing.T) {
+	tcs := []struct {
+		testName        string
+		maxConnsPerHost int
+		concurrency     int
+		requests        int
+		maxNewConns     int64
+	}{
+		{
+			testName:        "testSequentialRequestsShareConn",
+			maxConnsPerHost: DefaultMaxConnsPerHost,
+			concurrency:     1,
+			requests:        10,
+			maxNewConns:     1,
+		},
+		{
+			testName:        "testConcurrentRequestsLimited",
+			maxConnsPerHost: 2,
+			concurrency:     8,
+			requests:        40,
+			maxNewConns:     2,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(time.Millisecond)
+				w.Write([]byte(`{"id":1,"accountid":1,"name":"mickey dolenz"}`))
+			}))
+			defer srv.Close()
+
+			c, err := NewClient(srv.URL, 0, time.Millisecond)
+			if err != nil {
+				t.Fatalf("error %s was not expected creating the client", err)
+			}
+			defer c.CloseIdleConnections()
+			if err = c.SetMaxConnsPerHost(tc.maxConnsPerHost); err != nil {
+				t.Fatalf("error %s was not expected setting maxConnsPerHost", err)
+			}
+
+			var wg sync.WaitGroup
+			var failures int32
+			for i := 0; i < tc.concurrency; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < tc.requests/tc.concurrency; j++ {
+						if _, err := c.GetUser(context.Background(), 1); err != nil {
+							atomic.AddInt32(&failures, 1)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			if failures > 0 {
+				t.Fatalf("expected no failed requests, got %d", failures)
+			}
+
+			s := c.Stats()
+			if s.Requests != int64(tc.requests) {
+				t.Errorf("expected %d requests, got %+v", tc.requests, s)
+			}
+			if s.NewConns < 1 || s.NewConns > tc.maxNewConns {
+				t.Errorf("expected at most %d new connections, got %+v", tc.maxNewConns, s)
+			}
+			if s.NewConns+s.ReusedConns != s.Requests {
+				t.Errorf("expected every request to use a new or reused connection, got %+v", s)
+			}
+		})
+	}
+}
+
+func TestSetMaxConnsPerHost(t *testing.T) {
+	c, err := NewClient("http://accountd.kube", 0, time.Millisecond)
+	if err != nil {
+		t.Fatalf("error %s was not expected creating the client", err)
+	}
+	if err = c.SetMaxConnsPerHost(-1); err == nil {
+		t.Errorf("expected an error for a negative maxConnsPerHost")
+	}
+	if err = c.SetMaxConnsPerHost(0); err != nil {
+		t.Fatalf("error %s was not expected setting an unlimited maxConnsPerHost", err)
+	}
+	if c.transport.MaxConnsPerHost != 0 || c.transport.MaxIdleConnsPerHost != DefaultMaxConnsPerHost {
+		t.Errorf("expected no connection limit and %d idle connections, got %d and %d",
+			DefaultMaxConnsPerHost, c.transport.MaxConnsPerHost, c.transport.MaxIdleConnsPerHost)
+	}
+}
+
+func TestDialGRPC(t *testing.T) {
+	tcs := []struct {
+		testName   string
+		target     string
+		maxRetries int
+		maxBackoff time.Duration
+		expectErr  bool
+	}{
+		{testName: "testDial", target: "accountd:5000", maxRetries: 3, maxBackoff: time.Second},
+		{testName: "testEmptyTarget", target: "", maxRetries: 3, maxBackoff: time.Second, expectErr: true},
+		{testName: "testNegativeRetries", target: "accountd:5000", maxRetries: -1, maxBackoff: time.Second, expectErr: true},
+		{testName: "testZeroBackoff", target: "accountd:5000", maxRetries: 3, expectErr: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.testName, func(t *testing.T) {
+			conn, err := DialGRPC(tc.target, tc.maxRetries, tc.maxBackoff, grpc.WithInsecure())
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error %t, got %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			if s := conn.Stats(); s.Requests != 0 {
+				t.Errorf("expected no RPCs, got %+v", s)
+			}
+		})
+	}
+}
+
+func TestConnStatsHandler(t *testing.T) {
+	pool := &poolCounters{}
+	h := connStatsHandler{pool: pool}
+	ctx := context.Background()
+
+	h.HandleConn(ctx, &stats.ConnBegin{})
+	h.HandleConn(ctx, &stats.ConnBegin{})
+	h.HandleConn(ctx, &stats.ConnEnd{})
+	for i := 0; i < 3; i++ {
+		h.HandleRPC(ctx, &stats.Begin{})
+		h.HandleRPC(ctx, &stats.End{})
+	}
+
+	expected := PoolStats{Requests: 3, NewConns: 2, OpenConns: 1}
+	if s := pool.stats(); s != expected {
+		t.Errorf("expected %+v, got %+v", expected, s)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2020 Richard Youngkin. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultRetryAfter is how long to wait before retrying when the service doesn't say
+const defaultRetryAfter = time.Second
+
+// maxJitter is the largest fraction of the wait time added as random jitter
+const maxJitter = 0.2
+
+// QuotaExceededError indicates the service rejected a request because it was overloaded
+// or the caller exceeded its quota, and the request wasn't retried
+type QuotaExceededError struct {
+	// RetryAfter is how long the caller should wait before trying again
+	RetryAfter time.Duration
+	// Attempts is the number of times the request was tried
+	Attempts int
+	// Err is the underlying error returned by the service
+	Err error
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded after %d attempt(s), retry after %s: %s", e.Attempts, e.RetryAfter, e.Err)
+}
+
+// Unwrap returns the underlying error returned by the service
+func (e *QuotaExceededError) Unwrap() error {
+	return e.Err
+}
+
+// isRateLimited returns true if 'statusCode' indicates the request can be retried later
+func isRateLimited(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable
+}
+
+// isIdempotent returns true if requests using 'method' can safely be retried
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns how long to wait before retrying, based on the 'Retry-After' header or,
+// if that's missing, the 'RateLimit-Reset' or 'X-RateLimit-Reset' headers. 'Retry-After' may be
+// in seconds or an HTTP date. The reset headers are in seconds. The returned bool is false if
+// none of the headers are present and valid.
+func retryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	if v := strings.TrimSpace(h.Get("Retry-After")); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second, true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			d := t.Sub(now)
+			if d < 0 {
+				d = 0
+			}
+			return d, true
+		}
+	}
+
+	for _, name := range []string{"RateLimit-Reset", "X-RateLimit-Reset"} {
+		if v := strings.TrimSpace(h.Get(name)); v != "" {
+			if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+				return time.Duration(secs) * time.Second, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// backoff returns how long to wait before retry number 'attempt' (starting at 1). 'hint' is
+// how long the service asked the client to wait, it's 0 if the service didn't say. Without a
+// hint the wait grows exponentially. The result includes random jitter and is never more
+// than 'maxBackoff'.
+func backoff(attempt int, hint, maxBackoff time.Duration) time.Duration {
+	wait := hint
+	if wait <= 0 {
+		wait = defaultRetryAfter << uint(attempt-1)
+	}
+	wait += time.Duration(rand.Float64() * maxJitter * float64(wait))
+	if wait > maxBackoff || wait < 0 {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+// sleep waits for 'd' or until 'ctx' is done, whichever comes first
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
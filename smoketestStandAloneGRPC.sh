@@ -15,7 +15,7 @@ echo "    Pre-populate tables with test data"
 echo ""
 echo ""
 echo "Start the accountd service"
-cd cmd/accountd; go build; ./accountd -configFile "testdata/config/config" -secretsDir "testdata/secrets" -protocol "grpc" &
+cd cmd/accountd; go build; ./accountd -configFile "testdata/config/config" -env dev -secretsDir "testdata/secrets" -protocol "grpc" &
 echo "Wait for the accountd service to start..."
 sleep 2
 